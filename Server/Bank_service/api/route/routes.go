@@ -5,6 +5,7 @@ import (
 	"bank/config"
 	"bank/internal/service"
 	"bank/utils"
+	"shared/outbox"
 
 	// Cho custom validator
 
@@ -14,7 +15,7 @@ import (
 )
 
 // SetupRoutes thiết lập tất cả các routes cho ứng dụng.
-func SetupRoutes(router *gin.Engine, accountSvc service.AccountService, cfg config.Config) {
+func SetupRoutes(router *gin.Engine, accountSvc service.AccountService, outboxAdminHandler *outbox.AdminHandler, cfg config.Config) {
 	// Đăng ký custom validator
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("currency", utils.ValidCurrency) // Đăng ký validator 'currency'
@@ -58,6 +59,9 @@ func SetupRoutes(router *gin.Engine, accountSvc service.AccountService, cfg conf
 			*/
 		}
 
+		// Quản trị outbox: liệt kê, replay hoặc huỷ các event publish thất bại (ROLE_ADMIN)
+		outboxAdminHandler.RegisterRoutes(apiV1.Group("/admin/bank-outbox"))
+
 		// Thêm các nhóm route khác ở đây (ví dụ: /users, /transactions)
 	}
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"bank/internal/service" // Thư mục util cho các hàm tiện ích (ví dụ: random)
 	"bank/pkg/kafkaclient"
 	"bank/utils"
+	"shared/outbox"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq" // PostgreSQL driver
@@ -44,6 +46,11 @@ func main() {
 	accountSvc := service.NewAccountService(accountRepo, kafkaClient)
 	// Khởi tạo các service khác nếu có...
 
+	// Relay outbox_events -> Kafka (dùng chung shared/outbox với ticket_service, payment_service)
+	outboxStore := outbox.NewStore(conn)
+	outboxPoller := outbox.NewPoller(outboxStore, kafkaClient, nil, outbox.DefaultConfig())
+	go outboxPoller.Start(context.Background())
+
 	// Thiết lập Gin router
 	router := gin.Default()
 
@@ -57,7 +64,7 @@ func main() {
 
 	// Setup routes
 	// Truyền các service cần thiết vào route setup
-	route.SetupRoutes(router, accountSvc, cfg) // Truyền cfg nếu cần thiết cho middleware hoặc controller
+	route.SetupRoutes(router, accountSvc, outbox.NewAdminHandler(outboxStore), cfg) // Truyền cfg nếu cần thiết cho middleware hoặc controller
 
 	log.Printf("Server đang chạy tại địa chỉ %s", cfg.ServerAddress())
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -29,4 +29,32 @@ CREATE INDEX ON "transaction_history" ("account_id");
 
 CREATE INDEX ON "transaction_history" ("transaction_type");
 
-CREATE INDEX ON "transaction_history" ("created_at");
\ No newline at end of file
+CREATE INDEX ON "transaction_history" ("created_at");
+
+-- Transactional outbox dùng chung với ticket_service (shared/outbox): event ghi trong transaction nghiệp vụ,
+-- poller publish lên Kafka với retry backoff và chuyển event lỗi quá số lần cho phép sang outbox_dead_letters.
+CREATE TABLE outbox_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    topic VARCHAR(255) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT
+);
+
+CREATE INDEX idx_outbox_events_next_attempt_at ON outbox_events(next_attempt_at, created_at);
+
+CREATE TABLE outbox_dead_letters (
+    id UUID PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_outbox_dead_letters_failed_at ON outbox_dead_letters(failed_at);
@@ -27,6 +27,8 @@ type CreateAccountRequest struct {
 type DepositRequest struct {
 	Amount   int64  `json:"amount" binding:"required,gt=0"`       // Số tiền phải lớn hơn 0
 	Currency string `json:"currency" binding:"required,currency"` // Kiểm tra currency nếu cần
+	// Description tuỳ chọn, ghi vào lịch sử giao dịch (vd: hoàn tiền hoá đơn từ payment service)
+	Description string `json:"description,omitempty"`
 }
 
 // PaymentRequest định nghĩa cấu trúc request để thanh toán.
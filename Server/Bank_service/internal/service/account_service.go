@@ -130,12 +130,16 @@ func (s *accountService) DepositToAccount(ctx context.Context, accountID int64,
 			return err
 		}
 
+		description := fmt.Sprintf("Deposited %d %s. New balance: %d %s", req.Amount, req.Currency, updatedAccount.Balance, updatedAccount.Currency)
+		if req.Description != "" {
+			description = req.Description + ". " + description
+		}
 		_, errLog := q.CreateTransactionHistory(ctx, db.CreateTransactionHistoryParams{
 			AccountID:       updatedAccount.ID,
 			TransactionType: string(models.TransactionTypeDeposit),
 			Amount:          sql.NullInt64{Int64: req.Amount, Valid: true},
 			Currency:        sql.NullString{String: req.Currency, Valid: true},
-			Description:     fmt.Sprintf("Deposited %d %s. New balance: %d %s", req.Amount, req.Currency, updatedAccount.Balance, updatedAccount.Currency),
+			Description:     description,
 		})
 		if errLog != nil {
 			return utils.NewInternalServerError("không thể ghi lịch sử giao dịch khi nạp tiền", errLog)
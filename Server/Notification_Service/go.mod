@@ -2,7 +2,10 @@ module notification-service
 
 go 1.24.2
 
-require github.com/joho/godotenv v1.5.1
+require (
+	cloud.google.com/go/firestore v1.18.0
+	github.com/joho/godotenv v1.5.1
+)
 
 require (
 	cel.dev/expr v0.20.0 // indirect
@@ -10,7 +13,6 @@ require (
 	cloud.google.com/go/auth v0.16.1 // indirect
 	cloud.google.com/go/auth/oauth2adapt v0.2.8 // indirect
 	cloud.google.com/go/compute/metadata v0.7.0 // indirect
-	cloud.google.com/go/firestore v1.18.0 // indirect
 	cloud.google.com/go/iam v1.5.2 // indirect
 	cloud.google.com/go/longrunning v0.6.7 // indirect
 	cloud.google.com/go/monitoring v1.24.2 // indirect
@@ -102,7 +104,7 @@ require (
 	firebase.google.com/go v3.13.0+incompatible
 	github.com/IBM/sarama v1.45.2
 	github.com/gin-gonic/gin v1.10.1
-	github.com/google/uuid v1.6.0 // indirect
+	github.com/google/uuid v1.6.0
 	github.com/jackc/pgx/v5 v5.7.5
 	github.com/twmb/franz-go v1.19.5
 	google.golang.org/api v0.236.0
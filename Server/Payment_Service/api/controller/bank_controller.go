@@ -51,6 +51,9 @@ func (c *BankController) CreateBankPaymentRequestHandler(ctx *gin.Context) {
 
 	bankPaymentDetails, err := c.bankService.CreateBankPaymentRequest(ctx.Request.Context(), req)
 	if err != nil {
+		if respondVoucherError(ctx, err) || respondCurrencyError(ctx, err) {
+			return
+		}
 		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create bank payment request", err.Error())
 		return
 	}
@@ -46,6 +46,9 @@ func (c *StaffAssistedPaymentController) HandleDirectPayment(ctx *gin.Context) {
 
 	dbInvoice, err := c.invoiceService.ProcessStaffDirectPayment(ctx.Request.Context(), req)
 	if err != nil {
+		if respondVoucherError(ctx, err) {
+			return
+		}
 		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to process staff direct payment", err.Error())
 		return
 	}
@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"payment_service/domain/model"
+	"payment_service/internal/service"
+	"payment_service/pkg/utils"
+)
+
+// maxCallbackBodyBytes giới hạn body IPN / webhook từ cổng thanh toán
+const maxCallbackBodyBytes = 64 << 10
+
+// PaymentGatewayController xử lý API chung /payments/:provider cho mọi cổng đã đăng ký trong PaymentProviderRegistry
+type PaymentGatewayController struct {
+	providers  *service.PaymentProviderRegistry
+	invoiceSvc service.InvoiceServiceInterface
+}
+
+// NewPaymentGatewayController tạo một PaymentGatewayController mới
+func NewPaymentGatewayController(providers *service.PaymentProviderRegistry, invoiceSvc service.InvoiceServiceInterface) *PaymentGatewayController {
+	return &PaymentGatewayController{
+		providers:  providers,
+		invoiceSvc: invoiceSvc,
+	}
+}
+
+// provider lấy PaymentProvider theo path param :provider (momo, zalopay, vnpay, stripe, bank); trả về nil và 404 nếu không có
+func (c *PaymentGatewayController) provider(ctx *gin.Context) service.PaymentProvider {
+	name := ctx.Param("provider")
+	p, err := c.providers.Get(model.PaymentMethod(strings.ToUpper(name)))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Unknown payment provider", name)
+		return nil
+	}
+	return p
+}
+
+// CreatePayment tạo hoá đơn và yêu cầu thanh toán trên cổng
+// POST /api/v1/payments/:provider/create
+func (c *PaymentGatewayController) CreatePayment(ctx *gin.Context) {
+	p := c.provider(ctx)
+	if p == nil {
+		return
+	}
+
+	var req model.ProviderPaymentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
+	req.ClientIP = ctx.ClientIP()
+
+	resp, err := p.CreatePayment(ctx, req)
+	if err != nil {
+		if respondVoucherError(ctx, err) || respondCurrencyError(ctx, err) {
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create "+string(p.Method())+" payment", err.Error())
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, string(p.Method())+" payment created successfully", resp)
+}
+
+// HandleReturn xử lý khi cổng redirect trình duyệt của khách về
+// GET /api/v1/payments/:provider/return
+func (c *PaymentGatewayController) HandleReturn(ctx *gin.Context) {
+	p := c.provider(ctx)
+	if p == nil {
+		return
+	}
+
+	result, err := p.HandleCallback(ctx, model.ProviderCallback{
+		Kind:   model.ProviderCallbackReturn,
+		Query:  ctx.Request.URL.Query(),
+		Header: ctx.Request.Header,
+	})
+	switch {
+	case errors.Is(err, service.ErrCallbackNotSupported):
+		utils.RespondWithError(ctx, http.StatusNotFound, "Provider does not support return callbacks", nil)
+	case errors.Is(err, service.ErrInvalidSignature):
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid signature", result)
+	case err != nil && result == nil:
+		log.Printf("Error processing %s return: %v. Query: %s", p.Method(), err, ctx.Request.URL.RawQuery)
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to process "+string(p.Method())+" return", err.Error())
+	case err != nil:
+		log.Printf("Warning: %s return processed with error: %v", p.Method(), err)
+		utils.RespondWithError(ctx, http.StatusConflict, result.Message, result)
+	default:
+		utils.RespondWithSuccess(ctx, http.StatusOK, string(p.Method())+" return processed", result)
+	}
+}
+
+// HandleIPN nhận thông báo server-to-server từ cổng (IPN MoMo, callback ZaloPay, IPN VNPay, webhook Stripe)
+// GET|POST /api/v1/payments/:provider/ipn
+func (c *PaymentGatewayController) HandleIPN(ctx *gin.Context) {
+	p := c.provider(ctx)
+	if p == nil {
+		return
+	}
+
+	var body []byte
+	if ctx.Request.Method == http.MethodPost {
+		var err error
+		body, err = io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxCallbackBodyBytes))
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusRequestEntityTooLarge, "Error reading request body", err.Error())
+			return
+		}
+	}
+
+	result, err := p.HandleCallback(ctx, model.ProviderCallback{
+		Kind:   model.ProviderCallbackIPN,
+		Query:  ctx.Request.URL.Query(),
+		Header: ctx.Request.Header,
+		Body:   body,
+	})
+	if errors.Is(err, service.ErrCallbackNotSupported) {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Provider does not support IPN", nil)
+		return
+	}
+	if result == nil {
+		// Lỗi hệ thống: trả 500 để cổng gửi lại IPN
+		log.Printf("CRITICAL: System error processing %s IPN: %v", p.Method(), err)
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Internal Server Error", nil)
+		return
+	}
+	if err != nil {
+		log.Printf("Warning: %s IPN for TxnRef %s: %v", p.Method(), result.TxnRef, err)
+	}
+
+	status := result.AckStatus
+	if status == 0 {
+		status = http.StatusOK
+	}
+	switch {
+	case status == http.StatusNoContent:
+		ctx.Status(status)
+	case result.Ack != nil:
+		ctx.JSON(status, result.Ack)
+	default:
+		ctx.JSON(status, gin.H{"message": result.Message})
+	}
+}
+
+// QueryPayment truy vấn trạng thái thanh toán của hoá đơn trực tiếp trên cổng
+// GET /api/v1/payments/:provider/query/:invoice_id
+func (c *PaymentGatewayController) QueryPayment(ctx *gin.Context) {
+	p := c.provider(ctx)
+	if p == nil {
+		return
+	}
+
+	invoiceID, err := uuid.Parse(ctx.Param("invoice_id"))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid invoice ID format", err.Error())
+		return
+	}
+	invoice, err := c.invoiceSvc.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Invoice not found", err.Error())
+		return
+	}
+	if model.PaymentMethod(invoice.PaymentMethod.String) != p.Method() {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invoice was not paid via "+string(p.Method()), invoice.PaymentMethod.String)
+		return
+	}
+
+	status, err := p.QueryPayment(ctx, invoice)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to query "+string(p.Method())+" payment", err.Error())
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Payment status retrieved successfully", status)
+}
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"payment_service/domain/model"
+	"payment_service/internal/service"
+	"payment_service/pkg/utils"
+)
+
+// maxSettlementFileSize giới hạn kích thước file quyết toán tải lên
+const maxSettlementFileSize = 20 << 20
+
+// ReconciliationController là API đối soát cho kế toán (ROLE_ADMIN qua api_gateway)
+type ReconciliationController struct {
+	reconciliationSvc service.ReconciliationServiceInterface
+}
+
+// NewReconciliationController tạo một ReconciliationController mới
+func NewReconciliationController(reconciliationSvc service.ReconciliationServiceInterface) *ReconciliationController {
+	return &ReconciliationController{reconciliationSvc: reconciliationSvc}
+}
+
+// StartRun đối soát ngay một cổng cho một ngày qua API của cổng
+// POST /api/v1/reconciliation/runs
+func (c *ReconciliationController) StartRun(ctx *gin.Context) {
+	var req model.StartReconciliationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload for reconciliation", err.Error())
+		return
+	}
+	businessDate, err := time.ParseInLocation("2006-01-02", req.Date, c.reconciliationSvc.Location())
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid date format, expected YYYY-MM-DD", err.Error())
+		return
+	}
+
+	report, err := c.reconciliationSvc.Reconcile(ctx.Request.Context(), model.PaymentMethod(req.Provider), businessDate, ctx.GetHeader("X-User-ID"))
+	if err != nil {
+		c.respondRunError(ctx, req.Provider, err)
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Reconciliation completed", report)
+}
+
+// UploadSettlementFile đối soát theo file quyết toán CSV của cổng (multipart: provider, date, file)
+// POST /api/v1/reconciliation/runs/upload
+func (c *ReconciliationController) UploadSettlementFile(ctx *gin.Context) {
+	var req model.StartReconciliationRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload for reconciliation", err.Error())
+		return
+	}
+	businessDate, err := time.ParseInLocation("2006-01-02", req.Date, c.reconciliationSvc.Location())
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid date format, expected YYYY-MM-DD", err.Error())
+		return
+	}
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Settlement file is required", err.Error())
+		return
+	}
+	if fileHeader.Size > maxSettlementFileSize {
+		utils.RespondWithError(ctx, http.StatusRequestEntityTooLarge, "Settlement file is too large", nil)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Failed to read settlement file", err.Error())
+		return
+	}
+	defer file.Close()
+
+	report, err := c.reconciliationSvc.ReconcileSettlementFile(ctx.Request.Context(), model.PaymentMethod(req.Provider), businessDate, file, ctx.GetHeader("X-User-ID"))
+	if err != nil {
+		c.respondRunError(ctx, req.Provider, err)
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Reconciliation completed", report)
+}
+
+// ListRuns liệt kê các lần đối soát (GET /runs?provider=&from=&to=&page=1&limit=20), mặc định 30 ngày gần nhất
+// GET /api/v1/reconciliation/runs
+func (c *ReconciliationController) ListRuns(ctx *gin.Context) {
+	location := c.reconciliationSvc.Location()
+	to := time.Now().In(location)
+	if v := ctx.Query("to"); v != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", v, location)
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid 'to' date, expected YYYY-MM-DD", err.Error())
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -30)
+	if v := ctx.Query("from"); v != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", v, location)
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid 'from' date, expected YYYY-MM-DD", err.Error())
+			return
+		}
+		from = parsed
+	}
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	resp, err := c.reconciliationSvc.ListRuns(ctx.Request.Context(), ctx.Query("provider"), from, to, page, limit)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to list reconciliation runs", err.Error())
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Reconciliation runs retrieved successfully", resp)
+}
+
+// GetReport trả về báo cáo của một lần đối soát (?only_unresolved=true để chỉ lấy chênh lệch chưa xử lý)
+// GET /api/v1/reconciliation/runs/:run_id
+func (c *ReconciliationController) GetReport(ctx *gin.Context) {
+	runID, err := uuid.Parse(ctx.Param("run_id"))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid run ID format", err.Error())
+		return
+	}
+	onlyUnresolved, _ := strconv.ParseBool(ctx.DefaultQuery("only_unresolved", "false"))
+
+	report, err := c.reconciliationSvc.GetReport(ctx.Request.Context(), runID, onlyUnresolved)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(ctx, http.StatusNotFound, "Reconciliation run not found", nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve reconciliation report", err.Error())
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Reconciliation report retrieved successfully", report)
+}
+
+// ResolveItem đánh dấu một chênh lệch đã được xử lý, người xử lý lấy từ X-User-ID
+// POST /api/v1/reconciliation/items/:item_id/resolve
+func (c *ReconciliationController) ResolveItem(ctx *gin.Context) {
+	itemID, err := uuid.Parse(ctx.Param("item_id"))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid item ID format", err.Error())
+		return
+	}
+	var req model.ResolveReconciliationItemRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload for resolving reconciliation item", err.Error())
+		return
+	}
+	resolvedBy := ctx.GetHeader("X-User-ID")
+	if resolvedBy == "" {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "X-User-ID header is required", nil)
+		return
+	}
+
+	item, err := c.reconciliationSvc.ResolveItem(ctx.Request.Context(), itemID, resolvedBy, req.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			utils.RespondWithError(ctx, http.StatusNotFound, "Reconciliation item not found", nil)
+		case errors.Is(err, service.ErrReconciliationItemResolved):
+			utils.RespondWithError(ctx, http.StatusConflict, "Reconciliation item already resolved", item)
+		default:
+			utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to resolve reconciliation item", err.Error())
+		}
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Reconciliation item resolved", item)
+}
+
+func (c *ReconciliationController) respondRunError(ctx *gin.Context, provider string, err error) {
+	log.Printf("Error reconciling %s: %v", provider, err)
+	switch {
+	case errors.Is(err, service.ErrInvalidSettlementFile):
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid settlement file", err.Error())
+	case errors.Is(err, service.ErrReconciliationNotSupported):
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Reconciliation is not supported for this provider", err.Error())
+	default:
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Reconciliation failed", err.Error())
+	}
+}
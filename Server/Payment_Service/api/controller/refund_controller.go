@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"payment_service/domain/model"
+	"payment_service/internal/service"
+	"payment_service/pkg/utils"
+)
+
+// RefundController xử lý API hoàn tiền hoá đơn, mọi lần hoàn đều được ghi vào sổ hoàn tiền
+type RefundController struct {
+	refundSvc  service.RefundServiceInterface
+	invoiceSvc service.InvoiceServiceInterface
+}
+
+// NewRefundController tạo một RefundController mới
+func NewRefundController(refundSvc service.RefundServiceInterface, invoiceSvc service.InvoiceServiceInterface) *RefundController {
+	return &RefundController{
+		refundSvc:  refundSvc,
+		invoiceSvc: invoiceSvc,
+	}
+}
+
+// CreateRefund hoàn (toàn phần hoặc một phần) một hoá đơn qua phương thức thanh toán gốc
+// POST /api/v1/refunds
+func (c *RefundController) CreateRefund(ctx *gin.Context) {
+	var req model.InvoiceRefundRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload for refund", err.Error())
+		return
+	}
+	// Nhân viên đã đăng nhập (api_gateway gắn X-User-ID) được ưu tiên hơn giá trị trong body
+	if userID := ctx.GetHeader("X-User-ID"); userID != "" {
+		req.InitiatedBy = userID
+	}
+	if req.InitiatedBy == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "initiated_by is required", nil)
+		return
+	}
+
+	c.refund(ctx, req)
+}
+
+// ListInvoiceRefunds trả về sổ hoàn tiền của hoá đơn
+// GET /api/v1/refunds/invoice/:invoice_id
+func (c *RefundController) ListInvoiceRefunds(ctx *gin.Context) {
+	invoiceID, err := uuid.Parse(ctx.Param("invoice_id"))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid invoice ID format", err.Error())
+		return
+	}
+
+	resp, err := c.invoiceSvc.ListRefunds(ctx.Request.Context(), invoiceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(ctx, http.StatusNotFound, "Invoice not found", nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to list refunds", err.Error())
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Refunds retrieved successfully", resp)
+}
+
+// RefundVNPayTransaction hoàn tiền hoá đơn VNPay của vé, trừ percentage_deduction trên final_amount
+// POST /api/v1/vnpay/refund-transaction
+func (c *RefundController) RefundVNPayTransaction(ctx *gin.Context) {
+	var req model.VNPayInitiateRefundRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload for VNPay refund initiation", err.Error())
+		return
+	}
+	c.refundTicket(ctx, model.PaymentMethodVNPay, req.TicketID, req.PercentageDeduction, req.Reason, req.RefundInitiator)
+}
+
+// RefundStripePayment hoàn tiền hoá đơn Stripe của vé, trừ percentage_deduction trên final_amount
+// POST /api/v1/stripe/refund
+func (c *RefundController) RefundStripePayment(ctx *gin.Context) {
+	var req model.StripeInitiateRefundRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload for Stripe refund", err.Error())
+		return
+	}
+	c.refundTicket(ctx, model.PaymentMethodStripe, req.TicketID, req.PercentageDeduction, req.Reason, req.RefundInitiator)
+}
+
+// RefundBankPaymentHandler hoàn tiền hoá đơn BANK vào tài khoản đã thanh toán trên Bank_service
+// POST /api/v1/bank/refund
+func (c *RefundController) RefundBankPaymentHandler(ctx *gin.Context) {
+	var req model.BankRefundRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload for bank refund", err.Error())
+		return
+	}
+
+	invoice, err := c.invoiceSvc.GetInvoiceByID(ctx.Request.Context(), req.InvoiceID)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Invoice not found", err.Error())
+		return
+	}
+	if invoice.PaymentMethod.String != string(model.PaymentMethodBank) {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invoice was not paid by bank account", invoice.PaymentMethod.String)
+		return
+	}
+
+	c.refund(ctx, model.InvoiceRefundRequest{
+		InvoiceID:   req.InvoiceID,
+		Amount:      req.Amount,
+		Reason:      req.Reason,
+		InitiatedBy: req.RefundInitiator,
+	})
+}
+
+// refundTicket hoàn hoá đơn đã thanh toán gần nhất của vé, chỉ khi hoá đơn được thanh toán bằng method
+func (c *RefundController) refundTicket(ctx *gin.Context, method model.PaymentMethod, ticketID string, percentageDeduction float64, reason, initiator string) {
+	invoice, err := c.invoiceSvc.GetLatestCompletedInvoiceByTicketID(ctx.Request.Context(), ticketID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(ctx, http.StatusNotFound, fmt.Sprintf("No completed invoice found for ticket ID %s to refund", ticketID), nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve invoice for refund", err.Error())
+		return
+	}
+	if invoice.PaymentMethod.String != string(method) {
+		utils.RespondWithError(ctx, http.StatusBadRequest, fmt.Sprintf("Invoice is not a refundable %s transaction", method), invoice.PaymentMethod.String)
+		return
+	}
+
+	amount := utils.RoundFloat(invoice.FinalAmount*(1.0-percentageDeduction), 2)
+	if amount <= 0 {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Calculated refund amount is zero", nil)
+		return
+	}
+
+	c.refund(ctx, model.InvoiceRefundRequest{
+		InvoiceID:   invoice.InvoiceID,
+		Amount:      amount,
+		Reason:      reason,
+		InitiatedBy: initiator,
+	})
+}
+
+func (c *RefundController) refund(ctx *gin.Context, req model.InvoiceRefundRequest) {
+	refund, invoice, err := c.refundSvc.RefundInvoice(ctx.Request.Context(), req)
+	if err != nil {
+		log.Printf("Error refunding invoice %s: %v", req.InvoiceID, err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			utils.RespondWithError(ctx, http.StatusNotFound, "Invoice not found", err.Error())
+		case errors.Is(err, service.ErrInvoiceNotRefundable), errors.Is(err, service.ErrDuplicateRefundRequest):
+			utils.RespondWithError(ctx, http.StatusConflict, "Invoice cannot be refunded", err.Error())
+		case errors.Is(err, service.ErrRefundExceedsInvoice):
+			utils.RespondWithError(ctx, http.StatusUnprocessableEntity, "Refund amount exceeds refundable amount", err.Error())
+		case errors.Is(err, service.ErrRefundNotApplicable):
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Refund is not supported for this invoice", err.Error())
+		default:
+			utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to process refund", err.Error())
+		}
+		return
+	}
+
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Refund processed successfully", model.RefundResult{
+		Refund:  c.invoiceSvc.MapDbRefundToAPIResponse(refund),
+		Invoice: c.invoiceSvc.MapDbInvoiceToAPIResponse(invoice),
+	})
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"io"
 	"log"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"payment_service/config"
 	"payment_service/domain/model"
 	"payment_service/internal/service"
+	"payment_service/pkg/currency"
 	"payment_service/pkg/utils"
 )
 
@@ -46,6 +48,9 @@ func (c *StripeController) CreatePaymentIntent(ctx *gin.Context) {
 
 	resp, err := c.stripeService.CreatePaymentIntent(ctx, req)
 	if err != nil {
+		if respondVoucherError(ctx, err) || respondCurrencyError(ctx, err) {
+			return
+		}
 		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create Payment Intent", err.Error())
 		return
 	}
@@ -113,37 +118,16 @@ func (c *StripeController) HandleStripeWebhook(ctx *gin.Context) {
 	utils.RespondWithSuccess(ctx, http.StatusOK, "Webhook received and processed", nil)
 }
 
-// RefundStripePayment handles initiating a Stripe refund.
-// POST /api/v1/stripe/refund
-func (c *StripeController) RefundStripePayment(ctx *gin.Context) {
-	var req model.StripeInitiateRefundRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload for Stripe refund", err.Error())
-		return
-	}
-
-	// Call the stripe service to process the refund
-	// The service will handle fetching invoice, calculating amount, calling Stripe API, and updating DB.
-	updatedDbInvoice, err := c.stripeService.RefundPayment(ctx, req)
-	if err != nil {
-		// Service layer should return specific errors that can be mapped to HTTP status codes
-		// e.g., if invoice not found, already refunded, Stripe API error, DB update error after successful refund.
-		log.Printf("Error during Stripe refund for ticket %s: %v", req.TicketID, err)
-		// Example of more granular error handling:
-		// if errors.Is(err, service.ErrInvoiceNotFound) {
-		// 	utils.RespondWithError(ctx, http.StatusNotFound, "Invoice not found for refund", err.Error())
-		// } else if errors.Is(err, service.ErrInvoiceAlreadyRefunded) {
-		// 	utils.RespondWithError(ctx, http.StatusBadRequest, "Invoice already refunded", err.Error())
-		// } else if strings.Contains(err.Error(), "stripe API error") {
-		// 	utils.RespondWithError(ctx, http.StatusFailedDependency, "Stripe API error during refund", err.Error()) // 424
-		// } else if strings.Contains(err.Error(), "MANUAL INTERVENTION REQUIRED") {
-		//  utils.RespondWithError(ctx, http.StatusInternalServerError, "Refund processed by Stripe but failed to update local data. Please check.", err.Error())
-		// }
-		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to process Stripe refund", err.Error())
-		return
+// respondCurrencyError trả lỗi tiền tệ không hỗ trợ (400) hoặc chưa có tỷ giá quy đổi (422) thay vì 500.
+// Trả về false nếu err không phải lỗi tiền tệ.
+func respondCurrencyError(ctx *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, currency.ErrUnsupportedCurrency):
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Currency is not supported", err.Error())
+	case errors.Is(err, currency.ErrRateNotFound):
+		utils.RespondWithError(ctx, http.StatusUnprocessableEntity, "Exchange rate is not available for this currency", err.Error())
+	default:
+		return false
 	}
-
-	// Map the updated DB invoice to API response
-	apiInvoiceResponse := c.invoiceService.MapDbInvoiceToAPIResponse(updatedDbInvoice)
-	utils.RespondWithSuccess(ctx, http.StatusOK, "Stripe refund processed successfully", apiInvoiceResponse)
+	return true
 }
@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"fmt"
 	"log"
 	"net/http"
 	"payment_service/domain/model"
@@ -53,6 +52,9 @@ func (c *VNPayController) CreatePayment(ctx *gin.Context) {
 
 	resp, err := c.vnpaySvc.CreatePayment(ctx, req) // Assuming service handles IP
 	if err != nil {
+		if respondVoucherError(ctx, err) {
+			return
+		}
 		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create VNPay payment", err.Error())
 		return
 	}
@@ -188,109 +190,6 @@ func (c *VNPayController) VNPayQueryTransaction(ctx *gin.Context) {
 	})
 }
 
-// VNPayRefundTransaction handles initiating a VNPay refund.
-// POST /api/v1/vnpay/refund-transaction
-func (c *VNPayController) VNPayRefundTransaction(ctx *gin.Context) {
-	var req model.VNPayInitiateRefundRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload for VNPay refund initiation", err.Error())
-		return
-	}
-
-	clientIP := ctx.ClientIP()
-	if clientIP == "" {
-		clientIP = "127.0.0.1" // Fallback
-	}
-
-	// 1. Get the completed invoice by TicketID
-	invoice, err := c.invoiceSvc.GetLatestCompletedInvoiceByTicketID(ctx, req.TicketID)
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			utils.RespondWithError(ctx, http.StatusNotFound, fmt.Sprintf("No completed invoice found for ticket ID %s to refund", req.TicketID), err.Error())
-		} else {
-			utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve invoice for refund", err.Error())
-		}
-		return
-	}
-
-	// Ensure it's a VNPay invoice
-	if !invoice.PaymentMethod.Valid || invoice.PaymentMethod.String != string(model.PaymentMethodVNPay) || !invoice.VnpayTxnRef.Valid {
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Invoice is not a refundable VNPay transaction", nil)
-		return
-	}
-	if invoice.PaymentStatus.String == string(model.PaymentStatusRefunded) {
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Invoice is already refunded", nil)
-		return
-	}
-	if invoice.PaymentStatus.String != string(model.PaymentStatusCompleted) {
-		utils.RespondWithError(ctx, http.StatusBadRequest, fmt.Sprintf("Invoice status is %s, cannot refund", invoice.PaymentStatus.String), nil)
-		return
-	}
-
-	// 2. Calculate refund amount
-	refundAmount := invoice.FinalAmount * (1.0 - req.PercentageDeduction)
-	if refundAmount < 0 { // Should not happen with percentage_deduction <= 1
-		refundAmount = 0
-	}
-	// VNPay amounts are typically positive. Round to 2 decimal places for float comparison.
-	refundAmount = utils.RoundFloat(refundAmount, 2)
-
-	// 3. Determine TransactionType
-	var transactionType string
-	// Compare float64 carefully or convert to integer cents for comparison if that's how VNPay determines this.
-	// For simplicity: if percentage deduction is 0, assume full refund of the final_amount.
-	// VNPay "02" for full refund of original transaction, "03" for partial.
-	// If the calculated refundAmount equals the invoice.FinalAmount, it's a full refund of that final_amount.
-	if req.PercentageDeduction == 0.0 && refundAmount == invoice.FinalAmount {
-		transactionType = "02" // Full refund of the final amount
-	} else {
-		transactionType = "03" // Partial refund
-	}
-	if refundAmount == 0 && invoice.FinalAmount > 0 { // If refunding zero for a non-zero payment
-		transactionType = "03" // Still a type of partial refund (refunding zero amount)
-	}
-
-	// 4. Get TransactionDate (original payment date in YYYYMMDD)
-	var transactionDateStr string
-	if invoice.VnpayPayDate.Valid && len(invoice.VnpayPayDate.String) >= 8 {
-		transactionDateStr = invoice.VnpayPayDate.String[:8] // YYYYMMDD from YYYYMMDDHHMMSS
-	} else if invoice.IssueDate.Valid {
-		transactionDateStr = invoice.IssueDate.Time.Format("20060102")
-	} else {
-		utils.RespondWithError(ctx, http.StatusInternalServerError, "Cannot determine original transaction date for refund", nil)
-		return
-	}
-
-	// 5. Prepare VNPayRefundRequest for the service
-	vnpayRefundServiceReq := model.VNPayRefundRequest{
-		TxnRef:          invoice.VnpayTxnRef.String,
-		TransactionDate: transactionDateStr,
-		Amount:          refundAmount,
-		TransactionType: transactionType,
-		CreateBy:        req.RefundInitiator,
-	}
-
-	// 6. Call VNPayService to get refund request data (service will also update DB optimistically or based on IPN)
-	// The service's RefundTransaction now also takes the 'reason'.
-	refundDataToVNPay, err := c.vnpaySvc.RefundTransaction(ctx, vnpayRefundServiceReq, clientIP, req.Reason)
-	if err != nil {
-		// Check if it's a "already refunded" or "not completed" type error from the service if it pre-checks
-		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to prepare/process VNPay refund data", err.Error())
-		return
-	}
-
-	// The refundDataToVNPay is what should be POSTed to VNPay.
-	// The controller or a dedicated client would make this HTTP POST.
-	// For this example, we return the prepared data.
-	// The invoice status update to REFUNDED is now handled within vnpaySvc.RefundTransaction (or should be triggered by IPN).
-	utils.RespondWithSuccess(ctx, http.StatusOK, "VNPay refund data prepared. POST this to VNPay. Invoice status updated/pending.", gin.H{
-		"prepared_refund_data": refundDataToVNPay,
-		"vnpay_refund_api_url": c.cfg.TransactionAPI, // Ensure this is the correct refund API URL
-		"invoice_id":           invoice.InvoiceID,
-		"refunded_amount":      refundAmount,
-	})
-}
-
 func (c *VNPayController) FailInvoice(ctx *gin.Context) {
 	var req model.TicketStatusFailureRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
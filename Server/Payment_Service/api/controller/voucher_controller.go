@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"payment_service/domain/model"
+	"payment_service/internal/service"
+	"payment_service/pkg/utils"
+)
+
+// VoucherController quản lý voucher (ROLE_ADMIN) và cho khách kiểm tra / áp voucher qua api_gateway
+type VoucherController struct {
+	voucherSvc service.VoucherServiceInterface
+	invoiceSvc service.InvoiceServiceInterface
+}
+
+// NewVoucherController tạo một VoucherController mới
+func NewVoucherController(voucherSvc service.VoucherServiceInterface, invoiceSvc service.InvoiceServiceInterface) *VoucherController {
+	return &VoucherController{
+		voucherSvc: voucherSvc,
+		invoiceSvc: invoiceSvc,
+	}
+}
+
+// CreateVoucher tạo voucher mới, người tạo lấy từ X-User-ID
+// POST /api/v1/vouchers
+func (c *VoucherController) CreateVoucher(ctx *gin.Context) {
+	var req model.CreateVoucherRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload for voucher", err.Error())
+		return
+	}
+	createdBy := ctx.GetHeader("X-User-ID")
+	if createdBy == "" {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "X-User-ID header is required", nil)
+		return
+	}
+
+	voucher, err := c.voucherSvc.CreateVoucher(ctx.Request.Context(), req, createdBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrVoucherCodeExists):
+			utils.RespondWithError(ctx, http.StatusConflict, "Voucher code already exists", err.Error())
+		case errors.Is(err, service.ErrInvalidVoucher):
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid voucher", err.Error())
+		default:
+			utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create voucher", err.Error())
+		}
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusCreated, "Voucher created successfully", voucher)
+}
+
+// ListVouchers liệt kê voucher (GET /vouchers?active_only=true&page=1&limit=20)
+// GET /api/v1/vouchers
+func (c *VoucherController) ListVouchers(ctx *gin.Context) {
+	activeOnly, _ := strconv.ParseBool(ctx.DefaultQuery("active_only", "false"))
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	resp, err := c.voucherSvc.ListVouchers(ctx.Request.Context(), activeOnly, page, limit)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to list vouchers", err.Error())
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Vouchers retrieved successfully", resp)
+}
+
+// GetVoucher trả về voucher cùng các lượt dùng gần nhất
+// GET /api/v1/vouchers/:voucher_id
+func (c *VoucherController) GetVoucher(ctx *gin.Context) {
+	voucherID, err := uuid.Parse(ctx.Param("voucher_id"))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid voucher ID format", err.Error())
+		return
+	}
+
+	resp, err := c.voucherSvc.GetVoucher(ctx.Request.Context(), voucherID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(ctx, http.StatusNotFound, "Voucher not found", nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve voucher", err.Error())
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Voucher retrieved successfully", resp)
+}
+
+// DeactivateVoucher ngừng voucher, các hoá đơn đã giữ lượt dùng không bị ảnh hưởng
+// POST /api/v1/vouchers/:voucher_id/deactivate
+func (c *VoucherController) DeactivateVoucher(ctx *gin.Context) {
+	voucherID, err := uuid.Parse(ctx.Param("voucher_id"))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid voucher ID format", err.Error())
+		return
+	}
+
+	voucher, err := c.voucherSvc.DeactivateVoucher(ctx.Request.Context(), voucherID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(ctx, http.StatusNotFound, "Voucher not found", nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to deactivate voucher", err.Error())
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Voucher deactivated", voucher)
+}
+
+// ValidateVoucher tính số tiền được giảm cho một khoản thanh toán, không giữ lượt dùng
+// POST /api/v1/vouchers/validate
+func (c *VoucherController) ValidateVoucher(ctx *gin.Context) {
+	var req model.ValidateVoucherRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload for voucher validation", err.Error())
+		return
+	}
+
+	quote, err := c.voucherSvc.Validate(ctx.Request.Context(), req)
+	if err != nil {
+		if respondVoucherError(ctx, err) {
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to validate voucher", err.Error())
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Voucher is applicable", quote)
+}
+
+// ApplyVoucher áp voucher vào hoá đơn chuyển khoản đang chờ xác nhận
+// POST /api/v1/vouchers/apply
+func (c *VoucherController) ApplyVoucher(ctx *gin.Context) {
+	var req model.ApplyVoucherRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload for applying voucher", err.Error())
+		return
+	}
+
+	invoice, _, err := c.voucherSvc.Apply(ctx.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(ctx, http.StatusNotFound, "Invoice or voucher not found", nil)
+			return
+		}
+		if respondVoucherError(ctx, err) {
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to apply voucher", err.Error())
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Voucher applied to invoice", c.invoiceSvc.MapDbInvoiceToAPIResponse(invoice))
+}
+
+// RedeemVoucher ghi nhận thủ công lượt dùng voucher của hoá đơn đã thanh toán
+// POST /api/v1/vouchers/redeem
+func (c *VoucherController) RedeemVoucher(ctx *gin.Context) {
+	var req model.RedeemVoucherRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload for redeeming voucher", err.Error())
+		return
+	}
+
+	redemption, err := c.voucherSvc.RedeemForPaidInvoice(ctx.Request.Context(), req.InvoiceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			utils.RespondWithError(ctx, http.StatusNotFound, "Invoice not found", nil)
+		case errors.Is(err, service.ErrVoucherRedemptionNotFound):
+			utils.RespondWithError(ctx, http.StatusNotFound, "Invoice has no voucher", err.Error())
+		case errors.Is(err, service.ErrVoucherNotApplicable):
+			utils.RespondWithError(ctx, http.StatusConflict, "Invoice has not been paid", err.Error())
+		default:
+			utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to redeem voucher", err.Error())
+		}
+		return
+	}
+	utils.RespondWithSuccess(ctx, http.StatusOK, "Voucher redeemed", redemption)
+}
+
+// respondVoucherError trả lỗi voucher khách chọn (mã sai, không áp dụng được, hết lượt) thay vì 500.
+// Trả về false nếu err không phải lỗi voucher.
+func respondVoucherError(ctx *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrVoucherNotFound):
+		utils.RespondWithError(ctx, http.StatusNotFound, "Voucher not found", err.Error())
+	case errors.Is(err, service.ErrVoucherNotApplicable):
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Voucher is not applicable", err.Error())
+	case errors.Is(err, service.ErrVoucherUsageLimitReached):
+		utils.RespondWithError(ctx, http.StatusConflict, "Voucher usage limit reached", err.Error())
+	case errors.Is(err, service.ErrVoucherAlreadyApplied):
+		utils.RespondWithError(ctx, http.StatusConflict, "Invoice already has a voucher", err.Error())
+	default:
+		return false
+	}
+	return true
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"payment_service/api/controller" // Đảm bảo import đúng controller
+	"shared/outbox"
 )
 
 // SetupRoutes cấu hình tất cả các API route cho ứng dụng
@@ -15,39 +16,78 @@ func SetupRoutes(
 	stripeCtrl *controller.StripeController,
 	bankCtrl *controller.BankController,
 	staffCtrl *controller.StaffAssistedPaymentController,
+	paymentCtrl *controller.PaymentGatewayController,
+	refundCtrl *controller.RefundController,
+	reconciliationCtrl *controller.ReconciliationController,
+	voucherCtrl *controller.VoucherController,
+	outboxAdminHandler *outbox.AdminHandler,
+	idempotent gin.HandlerFunc, // Idempotency-Key cho các endpoint tạo / xác nhận thanh toán
 ) {
 	apiV1 := r.Group("/api/v1")
 
 	// VNPay routes
 	vnpayRoutes := apiV1.Group("/vnpay")
 	{
-		vnpayRoutes.POST("/create-payment", vnpayCtrl.CreatePayment)
+		vnpayRoutes.POST("/create-payment", idempotent, vnpayCtrl.CreatePayment)
 		vnpayRoutes.GET("/return", vnpayCtrl.HandleReturn) // VNPay return URL
 		vnpayRoutes.GET("/ipn", vnpayCtrl.HandleIPN)       // VNPay IPN URL
 		vnpayRoutes.POST("/query-transaction", vnpayCtrl.VNPayQueryTransaction)
-		vnpayRoutes.POST("/refund-transaction", vnpayCtrl.VNPayRefundTransaction) // Expects VNPayInitiateRefundRequest
+		vnpayRoutes.POST("/refund-transaction", idempotent, refundCtrl.RefundVNPayTransaction) // Expects VNPayInitiateRefundRequest
 	}
 
 	// Stripe routes
 	stripeRoutes := apiV1.Group("/stripe")
 	{
-		stripeRoutes.POST("/create-payment-intent", stripeCtrl.CreatePaymentIntent)
+		stripeRoutes.POST("/create-payment-intent", idempotent, stripeCtrl.CreatePaymentIntent)
 		stripeRoutes.POST("/confirm-payment", stripeCtrl.ConfirmStripePayment)
 		stripeRoutes.POST("/webhook", stripeCtrl.HandleStripeWebhook)
-		stripeRoutes.POST("/refund", stripeCtrl.RefundStripePayment) // New Stripe refund route
+		stripeRoutes.POST("/refund", idempotent, refundCtrl.RefundStripePayment)
 	}
 	// Bank Transfer routes
 	bankRoutes := apiV1.Group("/bank")
 	{
-		bankRoutes.POST("/create-payment-request", bankCtrl.CreateBankPaymentRequestHandler)
+		bankRoutes.POST("/create-payment-request", idempotent, bankCtrl.CreateBankPaymentRequestHandler)
 		// The confirm-payment and payment-failed routes should be protected (e.g., admin only)
 		// bankRoutes.POST("/confirm-payment", authMiddleware, bankCtrl.ConfirmBankPaymentHandler)
 		// bankRoutes.POST("/payment-failed", authMiddleware, bankCtrl.HandleBankPaymentFailedHandler)
-		bankRoutes.POST("/confirm-payment", bankCtrl.ConfirmBankPaymentHandler)     // Add appropriate middleware
-		bankRoutes.POST("/payment-failed", bankCtrl.HandleBankPaymentFailedHandler) // Add appropriate middleware
-
-		// Add refund route if needed
-		// bankRoutes.POST("/refund", authMiddleware, bankCtrl.RefundBankPaymentHandler)
+		bankRoutes.POST("/confirm-payment", idempotent, bankCtrl.ConfirmBankPaymentHandler) // Add appropriate middleware
+		bankRoutes.POST("/payment-failed", bankCtrl.HandleBankPaymentFailedHandler)         // Add appropriate middleware
+		bankRoutes.POST("/refund", idempotent, refundCtrl.RefundBankPaymentHandler)         // Hoàn tiền vào tài khoản đã thanh toán
+	}
+	// Cổng thanh toán dùng chung qua PaymentProviderRegistry: momo, zalopay, vnpay, stripe, bank
+	paymentRoutes := apiV1.Group("/payments/:provider")
+	{
+		paymentRoutes.POST("/create", idempotent, paymentCtrl.CreatePayment)
+		paymentRoutes.GET("/return", paymentCtrl.HandleReturn)
+		paymentRoutes.GET("/ipn", paymentCtrl.HandleIPN)
+		paymentRoutes.POST("/ipn", paymentCtrl.HandleIPN)
+		paymentRoutes.GET("/query/:invoice_id", paymentCtrl.QueryPayment)
+	}
+	// Sổ hoàn tiền: hoàn toàn phần / một phần hoá đơn qua cổng gốc (ROLE_ADMIN, ROLE_RECEPTION qua api_gateway)
+	refundRoutes := apiV1.Group("/refunds")
+	{
+		refundRoutes.POST("", idempotent, refundCtrl.CreateRefund)
+		refundRoutes.GET("/invoice/:invoice_id", refundCtrl.ListInvoiceRefunds)
+	}
+	// Đối soát hoá đơn với VNPay / Stripe và báo cáo chênh lệch cho kế toán (ROLE_ADMIN qua api_gateway)
+	reconciliationRoutes := apiV1.Group("/reconciliation")
+	{
+		reconciliationRoutes.POST("/runs", reconciliationCtrl.StartRun)
+		reconciliationRoutes.POST("/runs/upload", reconciliationCtrl.UploadSettlementFile)
+		reconciliationRoutes.GET("/runs", reconciliationCtrl.ListRuns)
+		reconciliationRoutes.GET("/runs/:run_id", reconciliationCtrl.GetReport)
+		reconciliationRoutes.POST("/items/:item_id/resolve", reconciliationCtrl.ResolveItem)
+	}
+	// Voucher: quản trị chiến dịch (ROLE_ADMIN), khách kiểm tra / áp voucher trước khi thanh toán
+	voucherRoutes := apiV1.Group("/vouchers")
+	{
+		voucherRoutes.POST("", voucherCtrl.CreateVoucher)
+		voucherRoutes.GET("", voucherCtrl.ListVouchers)
+		voucherRoutes.POST("/validate", voucherCtrl.ValidateVoucher)
+		voucherRoutes.POST("/apply", idempotent, voucherCtrl.ApplyVoucher)
+		voucherRoutes.POST("/redeem", voucherCtrl.RedeemVoucher)
+		voucherRoutes.GET("/:voucher_id", voucherCtrl.GetVoucher)
+		voucherRoutes.POST("/:voucher_id/deactivate", voucherCtrl.DeactivateVoucher)
 	}
 	// Invoice routes (can be shared or have a dedicated InvoiceController)
 	// Assuming VNPayController handles these for now, or you can refactor to a new InvoiceController.
@@ -62,8 +102,11 @@ func SetupRoutes(
 	// TODO: Apply staff-only authentication middleware to this group or specific routes
 	// staffPaymentRoutes.Use(YourStaffAuthMiddleware())
 	{
-		staffPaymentRoutes.POST("/direct-payment", staffCtrl.HandleDirectPayment)
+		staffPaymentRoutes.POST("/direct-payment", idempotent, staffCtrl.HandleDirectPayment)
 	}
+
+	// Quản trị outbox: liệt kê, replay hoặc huỷ các event publish thất bại (ROLE_ADMIN)
+	outboxAdminHandler.RegisterRoutes(apiV1.Group("/admin/payment-outbox"))
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "UP"})
 	})
@@ -0,0 +1,33 @@
+// Command fakegateway chạy cổng MoMo / ZaloPay giả lập để thử luồng thanh toán cục bộ.
+// Dùng cùng biến môi trường với payment service, trỏ endpoint của merchant về cổng giả lập:
+//
+//	MOMO_ENDPOINT=http://localhost:9090/momo
+//	ZALOPAY_ENDPOINT=http://localhost:9090/zalopay
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"payment_service/config"
+	"payment_service/pkg/fakegateway"
+)
+
+func main() {
+	cfg := config.LoadConfig()
+
+	port := os.Getenv("FAKE_GATEWAY_PORT")
+	if port == "" {
+		port = "9090"
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/momo/", http.StripPrefix("/momo", fakegateway.NewMoMo(cfg.MoMo)))
+	mux.Handle("/zalopay/", http.StripPrefix("/zalopay", fakegateway.NewZaloPay(cfg.ZaloPay)))
+
+	log.Printf("Fake payment gateway listening on :%s (MoMo: /momo, ZaloPay: /zalopay)", port)
+	if err := http.ListenAndServe(":"+port, mux); err != nil {
+		log.Fatalf("Fake gateway stopped: %v", err)
+	}
+}
@@ -22,9 +22,14 @@ import (
 	"payment_service/internal/repository"
 	"payment_service/internal/service"
 	"payment_service/internal/worker"
+	"payment_service/pkg/currency"
 	"payment_service/pkg/kafkaclient"
+	"payment_service/pkg/momo"
 	"payment_service/pkg/redisclient"
 	"payment_service/pkg/utils"
+	"payment_service/pkg/zalopay"
+	"shared/idempotency"
+	"shared/outbox"
 )
 
 func main() {
@@ -65,29 +70,67 @@ func main() {
 	// Initialize repositories
 	// Sử dụng *sql.DB cho NewInvoiceRepository
 	invoiceRepo := repository.NewInvoiceRepository(dbConn)
+	voucherRepo := repository.NewVoucherRepository(dbConn)
 
 	// Initialize services
 	// Truyền interface repository cho service
-	invoiceService := service.NewInvoiceService(invoiceRepo, kafkaClient, redisClient)
+	voucherService := service.NewVoucherService(voucherRepo, invoiceRepo)
+	invoiceService := service.NewInvoiceService(invoiceRepo, kafkaClient, redisClient, voucherService, currency.NewConverter(newRateProvider(cfg.ExchangeRate, dbConn)))
 	vnpayService := service.NewVNPayService(&cfg.VNPay, invoiceService) // Thêm ServerConfig nếu cần cho ReturnURL
 	stripeService := service.NewStripeService(&cfg.Stripe, invoiceService)
-	bankService := service.NewBankService(invoiceRepo, invoiceService, "http://bank-service:8086", &http.Client{})
+	bankService := service.NewBankService(invoiceRepo, invoiceService, voucherService, "http://bank-service:8086", &http.Client{})
+
+	// Các cổng thanh toán dùng chung, RefundService hoàn tiền qua cổng gốc của hoá đơn
+	gatewayHTTPClient := &http.Client{Timeout: 30 * time.Second}
+	paymentProviders := service.NewPaymentProviderRegistry(
+		service.NewVNPayProvider(vnpayService),
+		service.NewStripeProvider(stripeService, invoiceService),
+		service.NewBankProvider(bankService),
+		service.NewMoMoProvider(momo.NewClient(&cfg.MoMo, gatewayHTTPClient), invoiceService),
+		service.NewZaloPayProvider(zalopay.NewClient(&cfg.ZaloPay, gatewayHTTPClient), invoiceService),
+	)
+	refundService := service.NewRefundService(invoiceService, paymentProviders)
+
+	reconciliationLocation, err := time.LoadLocation(cfg.Reconciliation.Timezone)
+	if err != nil {
+		log.Printf("Warning: invalid RECONCILIATION_TIMEZONE %q, using local time: %v", cfg.Reconciliation.Timezone, err)
+		reconciliationLocation = time.Local
+	}
+	reconciliationService := service.NewReconciliationService(repository.NewReconciliationRepository(dbConn), paymentProviders, stripeService, invoiceService, reconciliationLocation)
 
 	// Initialize controllers
 	vnpayController := controller.NewVNPayController(*vnpayService, invoiceService, &cfg.VNPay, authUtil)
 	stripeController := controller.NewStripeController(stripeService, invoiceService, &cfg.Stripe)
 	bankController := controller.NewBankController(bankService, invoiceService)
 	staffCtrl := controller.NewStaffAssistedPaymentController(invoiceService)
+	paymentCtrl := controller.NewPaymentGatewayController(paymentProviders, invoiceService)
+	refundCtrl := controller.NewRefundController(refundService, invoiceService)
+	reconciliationCtrl := controller.NewReconciliationController(reconciliationService)
+	voucherCtrl := controller.NewVoucherController(voucherService, invoiceService)
 
 	expirySubscriber := worker.NewExpirySubscriber(redisClient, invoiceService)
 	go expirySubscriber.Start(context.Background())
 
+	refundConsumer := worker.NewRefundConsumer(cfg.KafkaConfig, refundService)
+	go refundConsumer.Start(context.Background())
+
+	// Đối soát hằng ngày giao dịch ngày hôm trước với VNPay / Stripe
+	reconciliationWorker := worker.NewReconciliationWorker(redisClient, reconciliationService, cfg.Reconciliation)
+	go reconciliationWorker.Start(context.Background())
+
+	// Relay outbox_events -> Kafka, an toàn khi chạy nhiều instance
+	outboxStore := outbox.NewStore(dbConn)
+	outboxPoller := outbox.NewPoller(outboxStore, kafkaClient, utils.NewDefaultLogger(), outbox.DefaultConfig())
+	go outboxPoller.Start(context.Background())
+	outboxAdminHandler := outbox.NewAdminHandler(outboxStore)
+
 	// Initialize Gin router
 	// gin.SetMode(gin.ReleaseMode) // Chuyển sang ReleaseMode cho production
 	router := gin.Default()
 
 	// Setup routes
-	route.SetupRoutes(router, vnpayController, stripeController, bankController, staffCtrl)
+	idempotencyStore := idempotency.NewStore(redisClient, idempotency.DefaultConfig("idempotency:payment"))
+	route.SetupRoutes(router, vnpayController, stripeController, bankController, staffCtrl, paymentCtrl, refundCtrl, reconciliationCtrl, voucherCtrl, outboxAdminHandler, idempotencyStore.Middleware())
 
 	// Configure server
 	srv := &http.Server{
@@ -160,3 +203,17 @@ func ConnectToDatabase(cfg config.DatabaseConfig) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// newRateProvider chọn nguồn tỷ giá theo EXCHANGE_RATE_SOURCE: file JSON cục bộ hoặc bảng exchange_rates.
+// File không đọc được thì dùng bảng exchange_rates.
+func newRateProvider(cfg config.ExchangeRateConfig, dbConn *sql.DB) currency.RateProvider {
+	if strings.ToLower(cfg.Source) == "file" {
+		provider, err := currency.NewFileRateProvider(cfg.FilePath)
+		if err == nil {
+			log.Printf("Using exchange rates from file %s", cfg.FilePath)
+			return provider
+		}
+		log.Printf("Warning: failed to load exchange rate file %s, falling back to database: %v", cfg.FilePath, err)
+	}
+	return repository.NewExchangeRateRepository(dbConn)
+}
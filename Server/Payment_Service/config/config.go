@@ -8,14 +8,18 @@ import (
 
 // Config holds the application configuration
 type Config struct {
-	Server        ServerConfig
-	Database      DatabaseConfig
-	VNPay         VNPayConfig
-	JWT           JWTConfig
-	Stripe        StripeConfig        // <--- Thêm dòng này
-	TicketService TicketServiceConfig // <--- Thêm dòng này
-	KafkaConfig   KafkaConfig
-	RedisConfig   RedisConfig
+	Server         ServerConfig
+	Database       DatabaseConfig
+	VNPay          VNPayConfig
+	JWT            JWTConfig
+	Stripe         StripeConfig        // <--- Thêm dòng này
+	TicketService  TicketServiceConfig // <--- Thêm dòng này
+	KafkaConfig    KafkaConfig
+	RedisConfig    RedisConfig
+	MoMo           MoMoConfig
+	ZaloPay        ZaloPayConfig
+	Reconciliation ReconciliationConfig
+	ExchangeRate   ExchangeRateConfig
 }
 
 // ServerConfig holds the server configuration
@@ -45,6 +49,26 @@ type VNPayConfig struct {
 	TransactionAPI string
 }
 
+// MoMoConfig holds the configuration for MoMo e-wallet (API v2, captureWallet)
+type MoMoConfig struct {
+	PartnerCode string
+	AccessKey   string
+	SecretKey   string
+	Endpoint    string // https://test-payment.momo.vn, hoặc fake gateway khi chạy local
+	RedirectURL string
+	IPNURL      string
+}
+
+// ZaloPayConfig holds the configuration for ZaloPay e-wallet (API v2)
+type ZaloPayConfig struct {
+	AppID       int
+	Key1        string // Ký request gửi sang ZaloPay
+	Key2        string // Xác thực callback / redirect từ ZaloPay
+	Endpoint    string // https://sb-openapi.zalopay.vn, hoặc fake gateway khi chạy local
+	RedirectURL string
+	CallbackURL string
+}
+
 type JWTConfig struct {
 	SecretKey string
 }
@@ -64,15 +88,36 @@ type KafkaConfig struct {
 	EnableTLS bool     `mapstructure:"KAFKA_ENABLE_TLS"`
 	SASLUser  string   `mapstructure:"KAFKA_SASL_USER"`
 	SASLPass  string   `mapstructure:"KAFKA_SASL_PASS"`
+	// Topic ticket-service gửi yêu cầu hoàn tiền khi khách huỷ vé
+	RefundRequestsTopic   string `mapstructure:"KAFKA_TOPIC_REFUND_REQUESTS"`
+	RefundRequestsGroupID string `mapstructure:"KAFKA_GROUP_ID_REFUND_REQUESTS"`
+	FareAdjustmentsTopic  string `mapstructure:"KAFKA_TOPIC_FARE_ADJUSTMENTS"`
+	// Yêu cầu hoàn tiền / điều chỉnh giá thất bại quá RefundMaxAttempts lần được chuyển sang topic này để xử lý thủ công
+	RefundDeadLetterTopic string `mapstructure:"KAFKA_TOPIC_REFUND_DEAD_LETTERS"`
+	RefundMaxAttempts     int    `mapstructure:"KAFKA_REFUND_MAX_ATTEMPTS"`
 }
 
 type RedisConfig struct {
 	URL string
 }
 
+// ReconciliationConfig holds the configuration for the daily reconciliation worker
+type ReconciliationConfig struct {
+	Enabled  bool
+	Hour     int    // Giờ chạy đối soát hằng ngày cho ngày hôm trước (0-23)
+	Timezone string // Múi giờ tính ngày giao dịch, VD: Asia/Ho_Chi_Minh
+}
+
+// ExchangeRateConfig holds the configuration for the exchange-rate source used to convert fares to the charged currency
+type ExchangeRateConfig struct {
+	Source   string // "database" (bảng exchange_rates) hoặc "file"
+	FilePath string // File JSON tỷ giá khi Source = "file"
+}
+
 // LoadConfig loads configuration from environment variables
 func LoadConfig() *Config {
 	kafkaEnableTLS, _ := strconv.ParseBool(getEnv("KAFKA_ENABLE_TLS", "false"))
+	reconciliationEnabled, _ := strconv.ParseBool(getEnv("RECONCILIATION_ENABLED", "true"))
 
 	return &Config{
 		Server: ServerConfig{
@@ -112,11 +157,42 @@ func LoadConfig() *Config {
 			EnableTLS: kafkaEnableTLS,
 			SASLUser:  getEnv("KAFKA_SASL_USER", ""),
 			SASLPass:  getEnv("KAFKA_SASL_PASS", ""),
+
+			RefundRequestsTopic:   getEnv("KAFKA_TOPIC_REFUND_REQUESTS", "refund_requests"),
+			RefundRequestsGroupID: getEnv("KAFKA_GROUP_ID_REFUND_REQUESTS", "payment_service_refund_group"),
+			FareAdjustmentsTopic:  getEnv("KAFKA_TOPIC_FARE_ADJUSTMENTS", "fare_adjustments"),
+			RefundDeadLetterTopic: getEnv("KAFKA_TOPIC_REFUND_DEAD_LETTERS", "refund_dead_letters"),
+			RefundMaxAttempts:     getEnvAsInt("KAFKA_REFUND_MAX_ATTEMPTS", 5),
+		},
+		MoMo: MoMoConfig{
+			PartnerCode: getEnv("MOMO_PARTNER_CODE", "MOMO"),
+			AccessKey:   getEnv("MOMO_ACCESS_KEY", "F8BBA842ECF85"),
+			SecretKey:   getEnv("MOMO_SECRET_KEY", "K951B6PE1waDMi640xX08PD3vg6EkVlz"),
+			Endpoint:    getEnv("MOMO_ENDPOINT", "https://test-payment.momo.vn"),
+			RedirectURL: getEnv("MOMO_REDIRECT_URL", "http://localhost:3000/ket-qua-dat-ve"),
+			IPNURL:      getEnv("MOMO_IPN_URL", "http://localhost:8083/api/v1/payments/momo/ipn"),
+		},
+		ZaloPay: ZaloPayConfig{
+			AppID:       getEnvAsInt("ZALOPAY_APP_ID", 2553),
+			Key1:        getEnv("ZALOPAY_KEY1", "PcY4iZIKFCIdgZvA6ueMcMHHUbRLYjPL"),
+			Key2:        getEnv("ZALOPAY_KEY2", "kLtgPl8HHhfvMuDHPwKfgfsY4Ydm9eIz"),
+			Endpoint:    getEnv("ZALOPAY_ENDPOINT", "https://sb-openapi.zalopay.vn"),
+			RedirectURL: getEnv("ZALOPAY_REDIRECT_URL", "http://localhost:3000/ket-qua-dat-ve"),
+			CallbackURL: getEnv("ZALOPAY_CALLBACK_URL", "http://localhost:8083/api/v1/payments/zalopay/ipn"),
 		},
 		// THAY ĐỔI: Gán giá trị cho Redis URL
 		RedisConfig: RedisConfig{
 			URL: getEnv("REDIS_URL", "redis://localhost:6379"),
 		},
+		Reconciliation: ReconciliationConfig{
+			Enabled:  reconciliationEnabled,
+			Hour:     getEnvAsInt("RECONCILIATION_HOUR", 2),
+			Timezone: getEnv("RECONCILIATION_TIMEZONE", "Asia/Ho_Chi_Minh"),
+		},
+		ExchangeRate: ExchangeRateConfig{
+			Source:   getEnv("EXCHANGE_RATE_SOURCE", "database"),
+			FilePath: getEnv("EXCHANGE_RATES_FILE", "config/exchange_rates.json"),
+		},
 	}
 }
 
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Giao dịch trên các cổng ví điện tử (MoMo, ZaloPay...) đi qua PaymentProvider chung.
+-- Mỗi lần tạo thanh toán là một txn_ref riêng gửi sang cổng; IPN/callback gửi lặp chỉ được áp dụng một lần.
+CREATE TABLE IF NOT EXISTS payment_transactions (
+    txn_ref VARCHAR(64) PRIMARY KEY, -- Mã đơn gửi sang cổng (MoMo orderId, ZaloPay app_trans_id)
+    invoice_id UUID NOT NULL REFERENCES invoices (invoice_id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL, -- MOMO, ZALOPAY
+    amount DECIMAL(15, 2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING', -- PENDING, COMPLETED, FAILED
+    provider_txn_id VARCHAR(100), -- MoMo transId, ZaloPay zp_trans_id
+    paid_at TIMESTAMPTZ,
+    failure_reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_transactions_invoice_id ON payment_transactions (invoice_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_payment_transactions_provider_txn_id ON payment_transactions (provider, provider_txn_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS payment_transactions;
+
+-- +goose StatementEnd
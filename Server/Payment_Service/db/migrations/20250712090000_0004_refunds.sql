@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Sổ hoàn tiền của hoá đơn: một hoá đơn có thể được hoàn nhiều lần (một phần), tổng các lần PENDING + COMPLETED
+-- không vượt quá final_amount. Hoá đơn chuyển PARTIALLY_REFUNDED, rồi REFUNDED khi đã hoàn đủ.
+CREATE TABLE IF NOT EXISTS refunds (
+    refund_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    invoice_id UUID NOT NULL REFERENCES invoices (invoice_id) ON DELETE CASCADE,
+    amount DECIMAL(15, 2) NOT NULL CHECK (amount > 0),
+    reason TEXT NOT NULL DEFAULT '',
+    initiated_by VARCHAR(100) NOT NULL, -- Nhân viên / hệ thống yêu cầu hoàn
+    provider VARCHAR(50) NOT NULL, -- Phương thức thanh toán gốc của hoá đơn (VNPAY, STRIPE, BANK, MOMO, ZALOPAY, STAFF_*)
+    provider_reference VARCHAR(255), -- Mã lần hoàn trên cổng (Stripe refund ID, vnp_TransactionNo, m_refund_id...)
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING', -- PENDING, COMPLETED, FAILED
+    failure_reason TEXT NOT NULL DEFAULT '',
+    request_key VARCHAR(150) UNIQUE, -- Khoá chống hoàn lặp khi cùng một yêu cầu được gửi lại (vd: sự kiện Kafka)
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_invoice_id ON refunds (invoice_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_status ON refunds (status);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS refunds;
+
+-- +goose StatementEnd
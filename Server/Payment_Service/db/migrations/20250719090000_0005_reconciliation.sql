@@ -0,0 +1,56 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Mỗi lần đối soát một cổng thanh toán cho một ngày (qua API của cổng hoặc file quyết toán CSV).
+CREATE TABLE IF NOT EXISTS reconciliation_runs (
+    run_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    provider VARCHAR(20) NOT NULL, -- VNPAY, STRIPE
+    business_date DATE NOT NULL, -- Ngày giao dịch được đối soát
+    source VARCHAR(20) NOT NULL, -- API, CSV
+    status VARCHAR(20) NOT NULL DEFAULT 'RUNNING', -- RUNNING, COMPLETED, FAILED
+    invoice_count INTEGER NOT NULL DEFAULT 0, -- Số hoá đơn nội bộ được đối soát
+    provider_count INTEGER NOT NULL DEFAULT 0, -- Số giao dịch theo báo cáo của cổng
+    matched_count INTEGER NOT NULL DEFAULT 0,
+    mismatch_count INTEGER NOT NULL DEFAULT 0,
+    error_message TEXT NOT NULL DEFAULT '',
+    started_by VARCHAR(100) NOT NULL DEFAULT 'system',
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_runs_provider_date ON reconciliation_runs (provider, business_date);
+
+-- Các chênh lệch phát hiện trong một lần đối soát, kế toán xử lý và đánh dấu resolved.
+CREATE TABLE IF NOT EXISTS reconciliation_items (
+    item_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    run_id UUID NOT NULL REFERENCES reconciliation_runs (run_id) ON DELETE CASCADE,
+    invoice_id UUID REFERENCES invoices (invoice_id) ON DELETE SET NULL, -- NULL khi giao dịch không có hoá đơn nội bộ
+    reference VARCHAR(255) NOT NULL, -- vnpay_txn_ref / stripe_payment_intent_id
+    mismatch_type VARCHAR(50) NOT NULL, -- PAID_AT_PROVIDER_PENDING_LOCAL, PAID_LOCAL_NOT_AT_PROVIDER, AMOUNT_MISMATCH, ORPHAN_AT_PROVIDER, MISSING_AT_PROVIDER, QUERY_FAILED
+    local_status VARCHAR(50) NOT NULL DEFAULT '',
+    provider_status VARCHAR(50) NOT NULL DEFAULT '',
+    local_amount DECIMAL(15, 2) NOT NULL DEFAULT 0,
+    provider_amount DECIMAL(15, 2) NOT NULL DEFAULT 0,
+    details TEXT NOT NULL DEFAULT '',
+    resolved_by VARCHAR(100),
+    resolution_note TEXT NOT NULL DEFAULT '',
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_items_run_id ON reconciliation_items (run_id);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_payment_method_created_at ON invoices (payment_method, created_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_invoices_payment_method_created_at;
+
+DROP TABLE IF EXISTS reconciliation_items;
+
+DROP TABLE IF EXISTS reconciliation_runs;
+
+-- +goose StatementEnd
@@ -0,0 +1,63 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Chiến dịch voucher của marketing: giảm theo phần trăm hoặc số tiền cố định, áp dụng khi tạo hoá đơn.
+CREATE TABLE IF NOT EXISTS vouchers (
+    voucher_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    code VARCHAR(50) NOT NULL UNIQUE, -- Luôn lưu chữ hoa
+    description TEXT NOT NULL DEFAULT '',
+    discount_type VARCHAR(20) NOT NULL, -- PERCENTAGE, FIXED
+    discount_value DECIMAL(15, 2) NOT NULL, -- Phần trăm (0-100] hoặc số tiền theo currency
+    max_discount_amount DECIMAL(15, 2), -- Trần giảm của voucher PERCENTAGE, NULL = không giới hạn
+    min_spend DECIMAL(15, 2) NOT NULL DEFAULT 0, -- Tổng tiền tối thiểu của hoá đơn
+    currency VARCHAR(10) NOT NULL DEFAULT 'vnd',
+    route_ids TEXT[] NOT NULL DEFAULT '{}', -- Các tuyến được áp dụng, rỗng = mọi tuyến
+    departure_from DATE, -- Khoảng ngày khởi hành được áp dụng, NULL = không giới hạn
+    departure_to DATE,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_until TIMESTAMPTZ NOT NULL,
+    usage_limit INTEGER, -- Tổng lượt dùng tối đa, NULL = không giới hạn
+    per_user_limit INTEGER, -- Lượt dùng tối đa của mỗi khách, NULL = không giới hạn
+    used_count INTEGER NOT NULL DEFAULT 0, -- Số lượt đang giữ (RESERVED) và đã dùng (REDEEMED)
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_vouchers_discount_type CHECK (discount_type IN ('PERCENTAGE', 'FIXED')),
+    CONSTRAINT chk_vouchers_valid_window CHECK (valid_until > valid_from)
+);
+
+-- Mỗi lượt dùng voucher gắn với một hoá đơn: RESERVED khi hoá đơn được tạo, REDEEMED khi hoá đơn được thanh toán,
+-- RELEASED khi hoá đơn hết hạn / thất bại. Lượt được giữ trước khi ghi hoá đơn nên invoice_id không có khoá ngoại.
+CREATE TABLE IF NOT EXISTS voucher_redemptions (
+    redemption_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    voucher_id UUID NOT NULL REFERENCES vouchers (voucher_id) ON DELETE CASCADE,
+    invoice_id UUID NOT NULL,
+    customer_id VARCHAR(100) NOT NULL,
+    discount_amount DECIMAL(15, 2) NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'RESERVED', -- RESERVED, REDEEMED, RELEASED
+    release_reason TEXT NOT NULL DEFAULT '',
+    redeemed_at TIMESTAMPTZ,
+    released_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Mỗi hoá đơn chỉ dùng một voucher
+CREATE UNIQUE INDEX IF NOT EXISTS idx_voucher_redemptions_invoice_active ON voucher_redemptions (invoice_id)
+WHERE
+    status <> 'RELEASED';
+
+CREATE INDEX IF NOT EXISTS idx_voucher_redemptions_voucher_customer ON voucher_redemptions (voucher_id, customer_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS voucher_redemptions;
+
+DROP TABLE IF EXISTS vouchers;
+
+-- +goose StatementEnd
@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Tiền tệ cổng thu và tỷ giá tại thời điểm tạo hoá đơn. currency / final_amount vẫn là tiền tệ và số tiền của giá vé,
+-- charged_* là số tiền thực thu (khách nước ngoài trả Stripe bằng USD/EUR, tài khoản ngân hàng khác tiền tệ).
+ALTER TABLE invoices
+    ADD COLUMN IF NOT EXISTS charged_currency VARCHAR(10),
+    ADD COLUMN IF NOT EXISTS charged_amount DECIMAL(15, 3),
+    ADD COLUMN IF NOT EXISTS exchange_rate NUMERIC(24, 12), -- 1 currency = exchange_rate charged_currency
+    ADD COLUMN IF NOT EXISTS exchange_rate_source VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS exchange_rate_at TIMESTAMPTZ;
+
+-- Nguồn tỷ giá dạng bảng (EXCHANGE_RATE_SOURCE=database): mỗi lần cập nhật là một dòng mới, hoá đơn dùng dòng mới nhất.
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    rate_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    base_currency VARCHAR(10) NOT NULL, -- 1 base_currency = rate quote_currency
+    quote_currency VARCHAR(10) NOT NULL,
+    rate NUMERIC(24, 12) NOT NULL CHECK (rate > 0),
+    source VARCHAR(100) NOT NULL,
+    effective_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_exchange_rates_pair_effective_at ON exchange_rates (base_currency, quote_currency, effective_at DESC);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS exchange_rates;
+
+ALTER TABLE invoices
+    DROP COLUMN IF EXISTS exchange_rate_at,
+    DROP COLUMN IF EXISTS exchange_rate_source,
+    DROP COLUMN IF EXISTS exchange_rate,
+    DROP COLUMN IF EXISTS charged_amount,
+    DROP COLUMN IF EXISTS charged_currency;
+
+-- +goose StatementEnd
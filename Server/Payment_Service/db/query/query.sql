@@ -26,9 +26,15 @@ INSERT INTO invoices (
     stripe_payment_method_details,
     -- bank transfer fields
     bank_transfer_code,
-    bank_payment_details -- Other bank fields like account_name, account_number, bank_name might be updated later upon confirmation
+    bank_payment_details, -- Other bank fields like account_name, account_number, bank_name might be updated later upon confirmation
+    -- charged currency and exchange-rate snapshot
+    charged_currency,
+    charged_amount,
+    exchange_rate,
+    exchange_rate_source,
+    exchange_rate_at
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29
 ) RETURNING *;
 
 -- name: GetInvoiceByID :one
@@ -48,8 +54,9 @@ SELECT * FROM invoices
 WHERE bank_transfer_code = $1 LIMIT 1;
 
 -- name: GetLatestCompletedInvoiceByTicketID :one
+-- Paid invoices, including those that have already been partially refunded
 SELECT * FROM invoices
-WHERE ticket_id = $1 AND payment_status = 'COMPLETED'
+WHERE ticket_id = $1 AND payment_status IN ('COMPLETED', 'PARTIALLY_REFUNDED')
 ORDER BY created_at DESC
 LIMIT 1;
 
@@ -137,3 +144,312 @@ SET
     updated_at = NOW()
 WHERE invoice_id = $1
 RETURNING *;
+
+-- name: CreatePaymentTransaction :one
+INSERT INTO payment_transactions (
+    txn_ref,
+    invoice_id,
+    provider,
+    amount
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: GetPaymentTransactionByTxnRef :one
+SELECT * FROM payment_transactions
+WHERE txn_ref = $1 LIMIT 1;
+
+-- name: GetLatestPaymentTransactionByInvoiceID :one
+SELECT * FROM payment_transactions
+WHERE invoice_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: CompletePaymentTransaction :one
+-- Only a PENDING transaction is completed so that repeated IPN/callbacks are applied once
+UPDATE payment_transactions
+SET
+    status = 'COMPLETED',
+    provider_txn_id = $2,
+    paid_at = $3,
+    updated_at = NOW()
+WHERE txn_ref = $1 AND status = 'PENDING'
+RETURNING *;
+
+-- name: FailPendingPaymentTransactions :exec
+-- Close the open gateway transactions of an invoice that failed or expired
+UPDATE payment_transactions
+SET
+    status = 'FAILED',
+    failure_reason = $2,
+    updated_at = NOW()
+WHERE invoice_id = $1 AND status = 'PENDING';
+
+-- name: GetInvoiceByIDForUpdate :one
+SELECT * FROM invoices
+WHERE invoice_id = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: CreateRefund :one
+INSERT INTO refunds (
+    refund_id,
+    invoice_id,
+    amount,
+    reason,
+    initiated_by,
+    provider,
+    request_key
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+) RETURNING *;
+
+-- name: GetRefundByRequestKey :one
+SELECT * FROM refunds
+WHERE request_key = $1 LIMIT 1;
+
+-- name: ListRefundsByInvoiceID :many
+SELECT * FROM refunds
+WHERE invoice_id = $1
+ORDER BY created_at;
+
+-- name: SumRefundsByInvoiceID :one
+SELECT
+    COALESCE(SUM(amount) FILTER (WHERE status = 'COMPLETED'), 0)::DECIMAL(15, 2) AS completed_amount,
+    COALESCE(SUM(amount) FILTER (WHERE status = 'PENDING'), 0)::DECIMAL(15, 2) AS pending_amount
+FROM refunds
+WHERE invoice_id = $1;
+
+-- name: CompleteRefund :one
+-- Only a PENDING refund is completed so that the invoice status is settled once per refund
+UPDATE refunds
+SET
+    status = 'COMPLETED',
+    provider_reference = $2,
+    updated_at = NOW()
+WHERE refund_id = $1 AND status = 'PENDING'
+RETURNING *;
+
+-- name: FailRefund :one
+-- A failed refund releases its request key so that the same request can be retried
+UPDATE refunds
+SET
+    status = 'FAILED',
+    failure_reason = $2,
+    request_key = NULL,
+    updated_at = NOW()
+WHERE refund_id = $1 AND status = 'PENDING'
+RETURNING *;
+
+-- name: GetRefundByID :one
+SELECT * FROM refunds
+WHERE refund_id = $1 LIMIT 1;
+
+-- name: ListInvoicesForReconciliation :many
+SELECT * FROM invoices
+WHERE payment_method = sqlc.arg(payment_method)
+    AND created_at >= sqlc.arg(from_time)::timestamptz
+    AND created_at < sqlc.arg(to_time)::timestamptz
+ORDER BY created_at;
+
+-- name: CreateReconciliationRun :one
+INSERT INTO reconciliation_runs (
+    provider,
+    business_date,
+    source,
+    started_by
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: FinishReconciliationRun :one
+UPDATE reconciliation_runs
+SET
+    status = $2,
+    invoice_count = $3,
+    provider_count = $4,
+    matched_count = $5,
+    mismatch_count = $6,
+    error_message = $7,
+    finished_at = NOW()
+WHERE run_id = $1
+RETURNING *;
+
+-- name: GetReconciliationRun :one
+SELECT * FROM reconciliation_runs
+WHERE run_id = $1 LIMIT 1;
+
+-- name: ListReconciliationRuns :many
+SELECT * FROM reconciliation_runs
+WHERE (sqlc.arg(provider)::text = '' OR provider = sqlc.arg(provider))
+    AND business_date BETWEEN sqlc.arg(from_date)::date AND sqlc.arg(to_date)::date
+ORDER BY business_date DESC, started_at DESC
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: CountReconciliationRuns :one
+SELECT COUNT(*) FROM reconciliation_runs
+WHERE (sqlc.arg(provider)::text = '' OR provider = sqlc.arg(provider))
+    AND business_date BETWEEN sqlc.arg(from_date)::date AND sqlc.arg(to_date)::date;
+
+-- name: CreateReconciliationItem :one
+INSERT INTO reconciliation_items (
+    run_id,
+    invoice_id,
+    reference,
+    mismatch_type,
+    local_status,
+    provider_status,
+    local_amount,
+    provider_amount,
+    details
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+) RETURNING *;
+
+-- name: ListReconciliationItemsByRunID :many
+SELECT * FROM reconciliation_items
+WHERE run_id = $1
+ORDER BY created_at, reference;
+
+-- name: GetReconciliationItem :one
+SELECT * FROM reconciliation_items
+WHERE item_id = $1 LIMIT 1;
+
+-- name: ResolveReconciliationItem :one
+-- An item is resolved once; the first resolution note is kept
+UPDATE reconciliation_items
+SET
+    resolved_by = $2,
+    resolution_note = $3,
+    resolved_at = NOW()
+WHERE item_id = $1 AND resolved_at IS NULL
+RETURNING *;
+
+-- name: CreateVoucher :one
+INSERT INTO vouchers (
+    code,
+    description,
+    discount_type,
+    discount_value,
+    max_discount_amount,
+    min_spend,
+    currency,
+    route_ids,
+    departure_from,
+    departure_to,
+    valid_from,
+    valid_until,
+    usage_limit,
+    per_user_limit,
+    created_by
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
+) RETURNING *;
+
+-- name: GetVoucherByID :one
+SELECT * FROM vouchers
+WHERE voucher_id = $1 LIMIT 1;
+
+-- name: GetVoucherByCode :one
+SELECT * FROM vouchers
+WHERE code = $1 LIMIT 1;
+
+-- name: GetVoucherByCodeForUpdate :one
+-- Locks the voucher while its usage caps are checked and a redemption is reserved
+SELECT * FROM vouchers
+WHERE code = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: ListVouchers :many
+SELECT * FROM vouchers
+WHERE (NOT sqlc.arg(active_only)::boolean OR is_active)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: CountVouchers :one
+SELECT COUNT(*) FROM vouchers
+WHERE (NOT sqlc.arg(active_only)::boolean OR is_active);
+
+-- name: DeactivateVoucher :one
+UPDATE vouchers
+SET
+    is_active = FALSE,
+    updated_at = NOW()
+WHERE voucher_id = $1
+RETURNING *;
+
+-- name: IncrementVoucherUsedCount :exec
+UPDATE vouchers
+SET
+    used_count = used_count + 1,
+    updated_at = NOW()
+WHERE voucher_id = $1;
+
+-- name: DecrementVoucherUsedCount :exec
+UPDATE vouchers
+SET
+    used_count = GREATEST(used_count - 1, 0),
+    updated_at = NOW()
+WHERE voucher_id = $1;
+
+-- name: CountActiveVoucherRedemptionsByCustomer :one
+SELECT COUNT(*) FROM voucher_redemptions
+WHERE voucher_id = $1 AND customer_id = $2 AND status IN ('RESERVED', 'REDEEMED');
+
+-- name: CreateVoucherRedemption :one
+INSERT INTO voucher_redemptions (
+    voucher_id,
+    invoice_id,
+    customer_id,
+    discount_amount,
+    currency
+) VALUES (
+    $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: GetActiveVoucherRedemptionByInvoiceID :one
+SELECT * FROM voucher_redemptions
+WHERE invoice_id = $1 AND status <> 'RELEASED' LIMIT 1;
+
+-- name: ListVoucherRedemptionsByVoucherID :many
+SELECT * FROM voucher_redemptions
+WHERE voucher_id = $1
+ORDER BY created_at DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: RedeemVoucherRedemption :one
+-- Only a RESERVED redemption is redeemed; callbacks delivered twice are no-ops
+UPDATE voucher_redemptions
+SET
+    status = 'REDEEMED',
+    redeemed_at = NOW(),
+    updated_at = NOW()
+WHERE invoice_id = $1 AND status = 'RESERVED'
+RETURNING *;
+
+-- name: ReleaseVoucherRedemption :one
+UPDATE voucher_redemptions
+SET
+    status = 'RELEASED',
+    release_reason = $2,
+    released_at = NOW(),
+    updated_at = NOW()
+WHERE invoice_id = $1 AND status = 'RESERVED'
+RETURNING *;
+
+-- name: UpdateInvoiceDiscount :one
+-- Used when a voucher is applied to an invoice that is still awaiting payment
+UPDATE invoices
+SET
+    discount_amount = $2,
+    final_amount = $3,
+    notes = $4,
+    charged_amount = $5,
+    updated_at = NOW()
+WHERE invoice_id = $1
+RETURNING *;
+
+-- name: GetLatestExchangeRate :one
+SELECT * FROM exchange_rates
+WHERE base_currency = $1 AND quote_currency = $2 AND effective_at <= NOW()
+ORDER BY effective_at DESC
+LIMIT 1;
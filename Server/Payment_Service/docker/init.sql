@@ -13,7 +13,7 @@ CREATE TABLE
         tax_amount DECIMAL(15, 2) DEFAULT 0.00,
         final_amount DECIMAL(15, 2) NOT NULL,
         currency VARCHAR(10),
-        payment_status VARCHAR(50) DEFAULT 'PENDING', -- PENDING, COMPLETED, FAILED, REFUNDED, AWAITING_CONFIRMATION (for bank)
+        payment_status VARCHAR(50) DEFAULT 'PENDING', -- PENDING, COMPLETED, FAILED, PARTIALLY_REFUNDED, REFUNDED, AWAITING_CONFIRMATION (for bank)
         payment_method VARCHAR(50), -- VNPAY, STRIPE, BANK
         issue_date TIMESTAMP,
         notes TEXT,
@@ -57,4 +57,185 @@ CREATE INDEX IF NOT EXISTS idx_invoices_stripe_charge_id ON invoices (stripe_cha
 
 CREATE INDEX IF NOT EXISTS idx_invoices_bank_transfer_code ON invoices (bank_transfer_code);
 
-CREATE INDEX IF NOT EXISTS idx_invoices_bank_transaction_id ON invoices (bank_transaction_id);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_invoices_bank_transaction_id ON invoices (bank_transaction_id);
+
+-- Transactional outbox dùng chung với ticket_service (shared/outbox): event ghi trong transaction nghiệp vụ,
+-- poller publish lên Kafka với retry backoff và chuyển event lỗi quá số lần cho phép sang outbox_dead_letters.
+CREATE TABLE outbox_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    topic VARCHAR(255) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT
+);
+
+CREATE INDEX idx_outbox_events_next_attempt_at ON outbox_events(next_attempt_at, created_at);
+
+CREATE TABLE outbox_dead_letters (
+    id UUID PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_outbox_dead_letters_failed_at ON outbox_dead_letters(failed_at);
+
+-- Giao dịch trên các cổng ví điện tử (MoMo, ZaloPay...) đi qua PaymentProvider chung.
+-- Mỗi lần tạo thanh toán là một txn_ref riêng gửi sang cổng; IPN/callback gửi lặp chỉ được áp dụng một lần.
+CREATE TABLE IF NOT EXISTS payment_transactions (
+    txn_ref VARCHAR(64) PRIMARY KEY, -- Mã đơn gửi sang cổng (MoMo orderId, ZaloPay app_trans_id)
+    invoice_id UUID NOT NULL REFERENCES invoices (invoice_id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL, -- MOMO, ZALOPAY
+    amount DECIMAL(15, 2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING', -- PENDING, COMPLETED, FAILED
+    provider_txn_id VARCHAR(100), -- MoMo transId, ZaloPay zp_trans_id
+    paid_at TIMESTAMPTZ,
+    failure_reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_transactions_invoice_id ON payment_transactions (invoice_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_payment_transactions_provider_txn_id ON payment_transactions (provider, provider_txn_id);
+
+-- Sổ hoàn tiền của hoá đơn: một hoá đơn có thể được hoàn nhiều lần (một phần), tổng các lần PENDING + COMPLETED
+-- không vượt quá final_amount. Hoá đơn chuyển PARTIALLY_REFUNDED, rồi REFUNDED khi đã hoàn đủ.
+CREATE TABLE IF NOT EXISTS refunds (
+    refund_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    invoice_id UUID NOT NULL REFERENCES invoices (invoice_id) ON DELETE CASCADE,
+    amount DECIMAL(15, 2) NOT NULL CHECK (amount > 0),
+    reason TEXT NOT NULL DEFAULT '',
+    initiated_by VARCHAR(100) NOT NULL, -- Nhân viên / hệ thống yêu cầu hoàn
+    provider VARCHAR(50) NOT NULL, -- Phương thức thanh toán gốc của hoá đơn (VNPAY, STRIPE, BANK, MOMO, ZALOPAY, STAFF_*)
+    provider_reference VARCHAR(255), -- Mã lần hoàn trên cổng (Stripe refund ID, vnp_TransactionNo, m_refund_id...)
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING', -- PENDING, COMPLETED, FAILED
+    failure_reason TEXT NOT NULL DEFAULT '',
+    request_key VARCHAR(150) UNIQUE, -- Khoá chống hoàn lặp khi cùng một yêu cầu được gửi lại (vd: sự kiện Kafka)
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_invoice_id ON refunds (invoice_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_status ON refunds (status);
+
+-- Mỗi lần đối soát một cổng thanh toán cho một ngày (qua API của cổng hoặc file quyết toán CSV).
+CREATE TABLE IF NOT EXISTS reconciliation_runs (
+    run_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    provider VARCHAR(20) NOT NULL, -- VNPAY, STRIPE
+    business_date DATE NOT NULL, -- Ngày giao dịch được đối soát
+    source VARCHAR(20) NOT NULL, -- API, CSV
+    status VARCHAR(20) NOT NULL DEFAULT 'RUNNING', -- RUNNING, COMPLETED, FAILED
+    invoice_count INTEGER NOT NULL DEFAULT 0, -- Số hoá đơn nội bộ được đối soát
+    provider_count INTEGER NOT NULL DEFAULT 0, -- Số giao dịch theo báo cáo của cổng
+    matched_count INTEGER NOT NULL DEFAULT 0,
+    mismatch_count INTEGER NOT NULL DEFAULT 0,
+    error_message TEXT NOT NULL DEFAULT '',
+    started_by VARCHAR(100) NOT NULL DEFAULT 'system',
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_runs_provider_date ON reconciliation_runs (provider, business_date);
+
+-- Các chênh lệch phát hiện trong một lần đối soát, kế toán xử lý và đánh dấu resolved.
+CREATE TABLE IF NOT EXISTS reconciliation_items (
+    item_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    run_id UUID NOT NULL REFERENCES reconciliation_runs (run_id) ON DELETE CASCADE,
+    invoice_id UUID REFERENCES invoices (invoice_id) ON DELETE SET NULL, -- NULL khi giao dịch không có hoá đơn nội bộ
+    reference VARCHAR(255) NOT NULL, -- vnpay_txn_ref / stripe_payment_intent_id
+    mismatch_type VARCHAR(50) NOT NULL, -- PAID_AT_PROVIDER_PENDING_LOCAL, PAID_LOCAL_NOT_AT_PROVIDER, AMOUNT_MISMATCH, ORPHAN_AT_PROVIDER, MISSING_AT_PROVIDER, QUERY_FAILED
+    local_status VARCHAR(50) NOT NULL DEFAULT '',
+    provider_status VARCHAR(50) NOT NULL DEFAULT '',
+    local_amount DECIMAL(15, 2) NOT NULL DEFAULT 0,
+    provider_amount DECIMAL(15, 2) NOT NULL DEFAULT 0,
+    details TEXT NOT NULL DEFAULT '',
+    resolved_by VARCHAR(100),
+    resolution_note TEXT NOT NULL DEFAULT '',
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_items_run_id ON reconciliation_items (run_id);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_payment_method_created_at ON invoices (payment_method, created_at);
+
+-- Chiến dịch voucher của marketing: giảm theo phần trăm hoặc số tiền cố định, áp dụng khi tạo hoá đơn.
+CREATE TABLE IF NOT EXISTS vouchers (
+    voucher_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    code VARCHAR(50) NOT NULL UNIQUE, -- Luôn lưu chữ hoa
+    description TEXT NOT NULL DEFAULT '',
+    discount_type VARCHAR(20) NOT NULL, -- PERCENTAGE, FIXED
+    discount_value DECIMAL(15, 2) NOT NULL, -- Phần trăm (0-100] hoặc số tiền theo currency
+    max_discount_amount DECIMAL(15, 2), -- Trần giảm của voucher PERCENTAGE, NULL = không giới hạn
+    min_spend DECIMAL(15, 2) NOT NULL DEFAULT 0, -- Tổng tiền tối thiểu của hoá đơn
+    currency VARCHAR(10) NOT NULL DEFAULT 'vnd',
+    route_ids TEXT[] NOT NULL DEFAULT '{}', -- Các tuyến được áp dụng, rỗng = mọi tuyến
+    departure_from DATE, -- Khoảng ngày khởi hành được áp dụng, NULL = không giới hạn
+    departure_to DATE,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_until TIMESTAMPTZ NOT NULL,
+    usage_limit INTEGER, -- Tổng lượt dùng tối đa, NULL = không giới hạn
+    per_user_limit INTEGER, -- Lượt dùng tối đa của mỗi khách, NULL = không giới hạn
+    used_count INTEGER NOT NULL DEFAULT 0, -- Số lượt đang giữ (RESERVED) và đã dùng (REDEEMED)
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_vouchers_discount_type CHECK (discount_type IN ('PERCENTAGE', 'FIXED')),
+    CONSTRAINT chk_vouchers_valid_window CHECK (valid_until > valid_from)
+);
+
+-- Mỗi lượt dùng voucher gắn với một hoá đơn: RESERVED khi hoá đơn được tạo, REDEEMED khi hoá đơn được thanh toán,
+-- RELEASED khi hoá đơn hết hạn / thất bại. Lượt được giữ trước khi ghi hoá đơn nên invoice_id không có khoá ngoại.
+CREATE TABLE IF NOT EXISTS voucher_redemptions (
+    redemption_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    voucher_id UUID NOT NULL REFERENCES vouchers (voucher_id) ON DELETE CASCADE,
+    invoice_id UUID NOT NULL,
+    customer_id VARCHAR(100) NOT NULL,
+    discount_amount DECIMAL(15, 2) NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'RESERVED', -- RESERVED, REDEEMED, RELEASED
+    release_reason TEXT NOT NULL DEFAULT '',
+    redeemed_at TIMESTAMPTZ,
+    released_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Mỗi hoá đơn chỉ dùng một voucher
+CREATE UNIQUE INDEX IF NOT EXISTS idx_voucher_redemptions_invoice_active ON voucher_redemptions (invoice_id)
+WHERE
+    status <> 'RELEASED';
+
+CREATE INDEX IF NOT EXISTS idx_voucher_redemptions_voucher_customer ON voucher_redemptions (voucher_id, customer_id);
+
+-- Tiền tệ cổng thu và tỷ giá tại thời điểm tạo hoá đơn. currency / final_amount vẫn là tiền tệ và số tiền của giá vé,
+-- charged_* là số tiền thực thu (khách nước ngoài trả Stripe bằng USD/EUR, tài khoản ngân hàng khác tiền tệ).
+ALTER TABLE invoices
+    ADD COLUMN IF NOT EXISTS charged_currency VARCHAR(10),
+    ADD COLUMN IF NOT EXISTS charged_amount DECIMAL(15, 3),
+    ADD COLUMN IF NOT EXISTS exchange_rate NUMERIC(24, 12), -- 1 currency = exchange_rate charged_currency
+    ADD COLUMN IF NOT EXISTS exchange_rate_source VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS exchange_rate_at TIMESTAMPTZ;
+
+-- Nguồn tỷ giá dạng bảng (EXCHANGE_RATE_SOURCE=database): mỗi lần cập nhật là một dòng mới, hoá đơn dùng dòng mới nhất.
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    rate_id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    base_currency VARCHAR(10) NOT NULL, -- 1 base_currency = rate quote_currency
+    quote_currency VARCHAR(10) NOT NULL,
+    rate NUMERIC(24, 12) NOT NULL CHECK (rate > 0),
+    source VARCHAR(100) NOT NULL,
+    effective_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_exchange_rates_pair_effective_at ON exchange_rates (base_currency, quote_currency, effective_at DESC);
@@ -12,6 +12,7 @@ const (
 	PaymentStatusPending              PaymentStatus = "PENDING"
 	PaymentStatusCompleted            PaymentStatus = "COMPLETED"
 	PaymentStatusFailed               PaymentStatus = "FAILED"
+	PaymentStatusPartiallyRefunded    PaymentStatus = "PARTIALLY_REFUNDED" // Đã hoàn một phần final_amount
 	PaymentStatusRefunded             PaymentStatus = "REFUNDED"
 	PaymentStatusAwaitingConfirmation PaymentStatus = "AWAITING_CONFIRMATION" // For bank transfers
 	PaymentStatusRequiresAction       PaymentStatus = "REQUIRES_ACTION"       // From Stripe
@@ -28,9 +29,11 @@ const (
 type PaymentMethod string
 
 const (
-	PaymentMethodVNPay  PaymentMethod = "VNPAY"
-	PaymentMethodStripe PaymentMethod = "STRIPE"
-	PaymentMethodBank   PaymentMethod = "BANK"
+	PaymentMethodVNPay   PaymentMethod = "VNPAY"
+	PaymentMethodStripe  PaymentMethod = "STRIPE"
+	PaymentMethodBank    PaymentMethod = "BANK"
+	PaymentMethodMoMo    PaymentMethod = "MOMO"    // Ví MoMo
+	PaymentMethodZaloPay PaymentMethod = "ZALOPAY" // Ví ZaloPay
 )
 
 type StaffDirectPaymentRequest struct {
@@ -45,6 +48,7 @@ type StaffDirectPaymentRequest struct {
 	InvoiceType          string  `json:"invoice_type,omitempty"`
 	DiscountAmount       float64 `json:"discount_amount,omitempty"`
 	TaxAmount            float64 `json:"tax_amount,omitempty"`
+	VoucherSelection
 }
 
 // VNPayPaymentRequest là request body cho việc tạo thanh toán VNPay
@@ -58,6 +62,7 @@ type VNPayPaymentRequest struct {
 	DiscountAmount float64 `json:"discount_amount"`
 	TaxAmount      float64 `json:"tax_amount"`
 	Notes          string  `json:"notes"` // Ghi chú thêm cho hóa đơn
+	VoucherSelection
 }
 
 // VNPayPaymentResponse là response trả về sau khi tạo yêu cầu thanh toán VNPay
@@ -97,6 +102,8 @@ type InitialStripePaymentRequest struct {
 	DiscountAmount int64  `json:"discount_amount,omitempty"` // Để ghi nhận, số tiền thực tế gửi cho Stripe là final_amount
 	TaxAmount      int64  `json:"tax_amount,omitempty"`      // Để ghi nhận
 	Notes          string `json:"notes,omitempty"`           // Ghi chú thêm cho hóa đơn
+	ChargeCurrency string `json:"charge_currency,omitempty"` // Tiền tệ thu qua Stripe (VD: "usd", "eur"), mặc định bằng currency của giá vé
+	VoucherSelection
 }
 
 // StripePaymentIntentResponse trả về cho frontend sau khi tạo PaymentIntent
@@ -173,6 +180,12 @@ type GetInvoiceResponse struct {
 	StripeChargeID             string    `json:"stripe_charge_id,omitempty"`
 	StripeCustomerID           string    `json:"stripe_customer_id,omitempty"`
 	StripePaymentMethodDetails string    `json:"stripe_payment_method_details,omitempty"`
+	// Tiền tệ / số tiền thực thu của khách và tỷ giá từ currency sang charged_currency tại lúc tạo hoá đơn
+	ChargedCurrency    string  `json:"charged_currency,omitempty"`
+	ChargedAmount      float64 `json:"charged_amount,omitempty"`
+	ExchangeRate       float64 `json:"exchange_rate,omitempty"`
+	ExchangeRateSource string  `json:"exchange_rate_source,omitempty"`
+	ExchangeRateAt     string  `json:"exchange_rate_at,omitempty"`
 }
 
 // VNPayQueryRequest cho VNPay QueryDR
@@ -203,7 +216,28 @@ type StripeInitiateRefundRequest struct {
 	TicketID            string  `json:"ticket_id" binding:"required"`
 	PercentageDeduction float64 `json:"percentage_deduction" binding:"min=0,max=1"` // Ví dụ: 0.0 cho không trừ, 0.1 cho trừ 10%
 	Reason              string  `json:"reason" binding:"required"`
-	// RefundInitiator có thể thêm nếu cần cho audit log hoặc metadata Stripe
+	RefundInitiator     string  `json:"refund_initiator" binding:"required"` // Người/hệ thống yêu cầu hoàn tiền
+}
+
+// TicketRefundRequest là sự kiện ticket-service gửi qua Kafka (topic refund_requests) khi khách huỷ vé.
+type TicketRefundRequest struct {
+	TicketID      string    `json:"ticket_id"`
+	RefundPercent float64   `json:"refund_percent"` // 0-100, áp dụng trên final_amount của hoá đơn
+	RefundAmount  float64   `json:"refund_amount"`  // Số tiền ticket-service tính trên giá vé hiện tại, ưu tiên dùng nếu > 0
+	Reason        string    `json:"reason"`
+	RequestedBy   string    `json:"requested_by"`
+	RequestedAt   time.Time `json:"requested_at"`
+}
+
+// FareAdjustmentRequest là sự kiện ticket-service gửi qua Kafka (topic fare_adjustments) khi giá vé thay đổi sau đổi ghế.
+// Amount > 0: khách trả thêm (đã thu tại quầy bởi CollectedBy); Amount < 0: hoàn lại phần chênh lệch.
+type FareAdjustmentRequest struct {
+	TicketID    string    `json:"ticket_id"`
+	Amount      float64   `json:"amount"`
+	Reason      string    `json:"reason"`
+	RequestedBy string    `json:"requested_by"`
+	CollectedBy string    `json:"collected_by,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
 }
 
 // PaymentMethod represents the type of payment.
@@ -215,6 +249,7 @@ type InitialBankPaymentRequest struct {
 	Amount      float64 `json:"amount" binding:"required,gt=0"`
 	Currency    string  `json:"currency" binding:"required"` // e.g., "VND", "USD"
 	InvoiceType string  `json:"invoice_type,omitempty"`
+	VoucherSelection
 	// Add any other fields needed to create the initial invoice
 }
 
@@ -224,6 +259,9 @@ type BankPaymentDetailsResponse struct {
 	BankTransferCode             string    `json:"bank_transfer_code"` // Unique code for user to put in reference
 	PayableAmount                float64   `json:"payable_amount"`
 	Currency                     string    `json:"currency"`
+	ChargedAmount                float64   `json:"charged_amount,omitempty"`   // Số tiền bị trừ trong tài khoản khách (tiền tệ của tài khoản)
+	ChargedCurrency              string    `json:"charged_currency,omitempty"` // Tiền tệ của tài khoản khách
+	ExchangeRate                 float64   `json:"exchange_rate,omitempty"`    // 1 currency = exchange_rate charged_currency
 	OurBankAccountName           string    `json:"our_bank_account_name"`
 	OurBankAccountNumber         string    `json:"our_bank_account_number"`
 	OurBankName                  string    `json:"our_bank_name"`
@@ -247,12 +285,13 @@ type BankPaymentConfirmationRequest struct {
 	ConfirmedBy           string    `json:"confirmed_by,omitempty"`         // User ID of admin or system
 }
 
-// BankRefundRequest might be needed later
-// type BankRefundRequest struct {
-// 	InvoiceID string `json:"invoice_id" binding:"required"`
-// 	Amount    float64 `json:"amount,omitempty"` // Optional: if partial refund, otherwise full
-// 	Reason    string `json:"reason" binding:"required"`
-// }
+// BankRefundRequest là request body cho việc hoàn tiền hoá đơn BANK vào tài khoản của khách trên Bank_service
+type BankRefundRequest struct {
+	InvoiceID       uuid.UUID `json:"invoice_id" binding:"required"`
+	Amount          float64   `json:"amount,omitempty" binding:"omitempty,gt=0"` // Bỏ trống để hoàn toàn bộ phần còn lại
+	Reason          string    `json:"reason" binding:"required"`
+	RefundInitiator string    `json:"refund_initiator" binding:"required"` // Nhân viên yêu cầu hoàn tiền
+}
 
 const (
 	TicketStatusPaid     = "1" // Trạng thái vé: Đã thanh toán
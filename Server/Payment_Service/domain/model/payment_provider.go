@@ -0,0 +1,90 @@
+package model
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProviderPaymentRequest là request body chung để tạo thanh toán qua một PaymentProvider
+// POST /api/v1/payments/:provider/create
+type ProviderPaymentRequest struct {
+	Amount         float64 `json:"amount" binding:"required,gt=0"` // Số tiền ở đơn vị chính (VD: 150000 VND, 12.5 USD)
+	Currency       string  `json:"currency"`                       // Mặc định "vnd"; ví điện tử chỉ nhận VND
+	CustomerID     string  `json:"customer_id" binding:"required"`
+	TicketID       string  `json:"ticket_id" binding:"required"`
+	InvoiceType    string  `json:"invoice_type,omitempty"`
+	DiscountAmount float64 `json:"discount_amount,omitempty"`
+	TaxAmount      float64 `json:"tax_amount,omitempty"`
+	Notes          string  `json:"notes,omitempty"`
+	Language       string  `json:"language,omitempty"`        // vn | en
+	BankCode       string  `json:"bank_code,omitempty"`       // Tuỳ chọn: chuyển thẳng tới ngân hàng (VNPay, ZaloPay)
+	ChargeCurrency string  `json:"charge_currency,omitempty"` // Tiền tệ thu (chỉ Stripe), mặc định bằng currency
+	ClientIP       string  `json:"-"`
+	VoucherSelection
+}
+
+// ProviderPaymentResponse là kết quả tạo thanh toán, tuỳ cổng mà khách được chuyển tới PaymentURL
+// hoặc frontend dùng ClientSecret / Details để hoàn tất.
+type ProviderPaymentResponse struct {
+	Provider     PaymentMethod `json:"provider"`
+	InvoiceID    uuid.UUID     `json:"invoice_id"`
+	TxnRef       string        `json:"txn_ref"`
+	PaymentURL   string        `json:"payment_url,omitempty"`
+	Deeplink     string        `json:"deeplink,omitempty"`
+	QRCode       string        `json:"qr_code,omitempty"`
+	ClientSecret string        `json:"client_secret,omitempty"`
+	Details      interface{}   `json:"details,omitempty"`
+}
+
+// ProviderCallbackKind phân biệt redirect trình duyệt của khách với thông báo server-to-server của cổng
+type ProviderCallbackKind string
+
+const (
+	ProviderCallbackReturn ProviderCallbackKind = "RETURN" // Khách được redirect về sau khi thanh toán
+	ProviderCallbackIPN    ProviderCallbackKind = "IPN"    // IPN / webhook / callback từ cổng
+)
+
+// ProviderCallback là request cổng thanh toán (hoặc trình duyệt khách) gọi về, giữ nguyên dữ liệu gốc để provider tự xác thực chữ ký
+type ProviderCallback struct {
+	Kind   ProviderCallbackKind
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// ProviderCallbackResult là kết quả xử lý callback.
+// Ack là body phải trả lại cho cổng (mỗi cổng một định dạng); AckStatus = 0 nghĩa là 200.
+type ProviderCallbackResult struct {
+	Provider      PaymentMethod `json:"provider"`
+	InvoiceID     uuid.UUID     `json:"invoice_id,omitempty"`
+	TxnRef        string        `json:"txn_ref,omitempty"`
+	ProviderTxnID string        `json:"provider_txn_id,omitempty"`
+	Status        PaymentStatus `json:"status,omitempty"`
+	Message       string        `json:"message"`
+	Ack           interface{}   `json:"-"`
+	AckStatus     int           `json:"-"`
+}
+
+// ProviderPaymentStatus là trạng thái giao dịch do cổng thanh toán trả về khi truy vấn
+type ProviderPaymentStatus struct {
+	Provider      PaymentMethod `json:"provider"`
+	InvoiceID     uuid.UUID     `json:"invoice_id"`
+	TxnRef        string        `json:"txn_ref"`
+	ProviderTxnID string        `json:"provider_txn_id,omitempty"`
+	Status        PaymentStatus `json:"status"`
+	Amount        float64       `json:"amount"`
+	PaidAt        *time.Time    `json:"paid_at,omitempty"`
+	Message       string        `json:"message,omitempty"`
+}
+
+// ProviderRefundRequest là yêu cầu hoàn tiền (toàn phần hoặc một phần) gửi tới cổng thanh toán của hoá đơn
+type ProviderRefundRequest struct {
+	RefundID     uuid.UUID // Lần hoàn trong sổ hoàn tiền (bảng refunds)
+	Amount       float64
+	Reason       string
+	RequestedBy  string
+	PriorRefunds []float64 // Các lần hoàn trước của hoá đơn (tiền tệ giá vé, theo thứ tự), để quy đổi theo phần còn lại
+}
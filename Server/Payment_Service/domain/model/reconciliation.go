@@ -0,0 +1,110 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReconciliationSource là nguồn dữ liệu giao dịch của cổng thanh toán khi đối soát
+type ReconciliationSource string
+
+const (
+	ReconciliationSourceAPI ReconciliationSource = "API" // Hỏi trực tiếp cổng (VNPay querydr, Stripe list PaymentIntents)
+	ReconciliationSourceCSV ReconciliationSource = "CSV" // File quyết toán cổng gửi cho kế toán
+)
+
+type ReconciliationRunStatus string
+
+const (
+	ReconciliationRunStatusRunning   ReconciliationRunStatus = "RUNNING"
+	ReconciliationRunStatusCompleted ReconciliationRunStatus = "COMPLETED"
+	ReconciliationRunStatusFailed    ReconciliationRunStatus = "FAILED"
+)
+
+// ReconciliationMismatchType là loại chênh lệch giữa hoá đơn nội bộ và giao dịch trên cổng
+type ReconciliationMismatchType string
+
+const (
+	MismatchPaidAtProviderPendingLocal ReconciliationMismatchType = "PAID_AT_PROVIDER_PENDING_LOCAL" // Cổng đã thu tiền nhưng hoá đơn chưa được ghi nhận thanh toán
+	MismatchPaidLocalNotAtProvider     ReconciliationMismatchType = "PAID_LOCAL_NOT_AT_PROVIDER"     // Hoá đơn đã thanh toán nhưng cổng không ghi nhận thu tiền
+	MismatchAmount                     ReconciliationMismatchType = "AMOUNT_MISMATCH"                // Số tiền cổng thu khác final_amount
+	MismatchOrphanAtProvider           ReconciliationMismatchType = "ORPHAN_AT_PROVIDER"             // Cổng đã thu tiền nhưng không có hoá đơn nào khớp mã tham chiếu
+	MismatchMissingAtProvider          ReconciliationMismatchType = "MISSING_AT_PROVIDER"            // Hoá đơn có mã tham chiếu nhưng không có trong dữ liệu của cổng
+	MismatchQueryFailed                ReconciliationMismatchType = "QUERY_FAILED"                   // Không hỏi được trạng thái giao dịch trên cổng
+)
+
+// ProviderSettlement là một giao dịch theo dữ liệu của cổng thanh toán (API hoặc file quyết toán)
+type ProviderSettlement struct {
+	Reference     string        // vnp_TxnRef / PaymentIntent ID
+	ProviderTxnID string        // vnp_TransactionNo / Charge ID
+	Status        PaymentStatus // COMPLETED khi cổng đã thu tiền
+	RawStatus     string        // Trạng thái gốc của cổng, để kế toán đối chiếu
+	Amount        float64       // Số tiền cổng đã thu, đơn vị chính (VND, USD)
+	PaidAt        *time.Time
+}
+
+// StartReconciliationRequest chạy đối soát ngay cho một cổng và một ngày
+// POST /api/v1/reconciliation/runs
+type StartReconciliationRequest struct {
+	Provider string `json:"provider" form:"provider" binding:"required,oneof=VNPAY STRIPE"`
+	Date     string `json:"date" form:"date" binding:"required"` // YYYY-MM-DD
+}
+
+// ResolveReconciliationItemRequest đánh dấu một chênh lệch đã được kế toán xử lý
+// POST /api/v1/reconciliation/items/:item_id/resolve
+type ResolveReconciliationItemRequest struct {
+	Note string `json:"note" binding:"required"`
+}
+
+// ReconciliationRunResponse là tóm tắt một lần đối soát
+type ReconciliationRunResponse struct {
+	RunID         uuid.UUID `json:"run_id"`
+	Provider      string    `json:"provider"`
+	BusinessDate  string    `json:"business_date"`
+	Source        string    `json:"source"`
+	Status        string    `json:"status"`
+	InvoiceCount  int32     `json:"invoice_count"`
+	ProviderCount int32     `json:"provider_count"`
+	MatchedCount  int32     `json:"matched_count"`
+	MismatchCount int32     `json:"mismatch_count"`
+	ErrorMessage  string    `json:"error_message,omitempty"`
+	StartedBy     string    `json:"started_by"`
+	StartedAt     string    `json:"started_at"`
+	FinishedAt    string    `json:"finished_at,omitempty"`
+}
+
+// ReconciliationItemResponse là một chênh lệch phát hiện trong lần đối soát
+type ReconciliationItemResponse struct {
+	ItemID         uuid.UUID  `json:"item_id"`
+	RunID          uuid.UUID  `json:"run_id"`
+	InvoiceID      *uuid.UUID `json:"invoice_id,omitempty"`
+	Reference      string     `json:"reference"`
+	MismatchType   string     `json:"mismatch_type"`
+	LocalStatus    string     `json:"local_status,omitempty"`
+	ProviderStatus string     `json:"provider_status,omitempty"`
+	LocalAmount    float64    `json:"local_amount"`
+	ProviderAmount float64    `json:"provider_amount"`
+	Details        string     `json:"details,omitempty"`
+	Resolved       bool       `json:"resolved"`
+	ResolvedBy     string     `json:"resolved_by,omitempty"`
+	ResolutionNote string     `json:"resolution_note,omitempty"`
+	ResolvedAt     string     `json:"resolved_at,omitempty"`
+	CreatedAt      string     `json:"created_at"`
+}
+
+// ReconciliationReportResponse là báo cáo đối soát cho kế toán
+// GET /api/v1/reconciliation/runs/:run_id
+type ReconciliationReportResponse struct {
+	Run   ReconciliationRunResponse    `json:"run"`
+	Items []ReconciliationItemResponse `json:"items"`
+}
+
+// ReconciliationRunsResponse là danh sách các lần đối soát có phân trang
+// GET /api/v1/reconciliation/runs
+type ReconciliationRunsResponse struct {
+	Items []ReconciliationRunResponse `json:"items"`
+	Total int64                       `json:"total"`
+	Page  int                         `json:"page"`
+	Limit int                         `json:"limit"`
+}
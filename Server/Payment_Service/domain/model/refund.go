@@ -0,0 +1,59 @@
+package model
+
+import (
+	"github.com/google/uuid"
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "PENDING"   // Đã ghi sổ, đang chờ cổng thanh toán xác nhận
+	RefundStatusCompleted RefundStatus = "COMPLETED" // Tiền đã được hoàn cho khách
+	RefundStatusFailed    RefundStatus = "FAILED"    // Cổng từ chối hoặc lỗi khi gọi cổng, không tính vào số đã hoàn
+)
+
+// InvoiceRefundRequest là yêu cầu hoàn (toàn phần hoặc một phần) một hoá đơn đã thanh toán
+// POST /api/v1/refunds
+type InvoiceRefundRequest struct {
+	InvoiceID   uuid.UUID `json:"invoice_id" binding:"required"`
+	Amount      float64   `json:"amount,omitempty" binding:"omitempty,gt=0"` // Bỏ trống để hoàn toàn bộ phần còn lại
+	Reason      string    `json:"reason" binding:"required"`
+	InitiatedBy string    `json:"initiated_by,omitempty"` // Mặc định lấy từ X-User-ID do api_gateway gắn vào
+	// RequestKey chống ghi trùng cùng một yêu cầu hoàn (vd: message Kafka bị gửi lại)
+	RequestKey string `json:"-"`
+	// CapToRefundable hoàn tối đa phần còn lại thay vì từ chối khi Amount vượt quá
+	CapToRefundable bool `json:"-"`
+}
+
+// RefundResponse là một lần hoàn trong sổ hoàn tiền của hoá đơn
+type RefundResponse struct {
+	RefundID          uuid.UUID `json:"refund_id"`
+	InvoiceID         uuid.UUID `json:"invoice_id"`
+	Amount            float64   `json:"amount"`
+	Reason            string    `json:"reason"`
+	InitiatedBy       string    `json:"initiated_by"`
+	Provider          string    `json:"provider"`
+	ProviderReference string    `json:"provider_reference,omitempty"`
+	Status            string    `json:"status"`
+	FailureReason     string    `json:"failure_reason,omitempty"`
+	CreatedAt         string    `json:"created_at"`
+	UpdatedAt         string    `json:"updated_at"`
+}
+
+// InvoiceRefundsResponse là sổ hoàn tiền của một hoá đơn
+// GET /api/v1/refunds/invoice/:invoice_id
+type InvoiceRefundsResponse struct {
+	InvoiceID        uuid.UUID        `json:"invoice_id"`
+	PaymentStatus    string           `json:"payment_status"`
+	FinalAmount      float64          `json:"final_amount"`
+	RefundedAmount   float64          `json:"refunded_amount"`   // Tổng các lần hoàn COMPLETED
+	PendingAmount    float64          `json:"pending_amount"`    // Tổng các lần hoàn đang chờ cổng xác nhận
+	RefundableAmount float64          `json:"refundable_amount"` // Số tiền còn có thể hoàn
+	Refunds          []RefundResponse `json:"refunds"`
+}
+
+// RefundResult là kết quả của một lần hoàn cùng hoá đơn sau khi cập nhật
+type RefundResult struct {
+	Refund  RefundResponse     `json:"refund"`
+	Invoice GetInvoiceResponse `json:"invoice"`
+}
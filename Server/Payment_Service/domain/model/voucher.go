@@ -0,0 +1,139 @@
+package model
+
+import (
+	"github.com/google/uuid"
+)
+
+type VoucherDiscountType string
+
+const (
+	VoucherDiscountPercentage VoucherDiscountType = "PERCENTAGE" // Giảm theo phần trăm tổng tiền, có thể có trần max_discount_amount
+	VoucherDiscountFixed      VoucherDiscountType = "FIXED"      // Giảm một số tiền cố định theo currency của voucher
+)
+
+// VoucherRedemptionStatus là trạng thái một lượt dùng voucher gắn với hoá đơn
+type VoucherRedemptionStatus string
+
+const (
+	VoucherRedemptionReserved VoucherRedemptionStatus = "RESERVED" // Hoá đơn đã tạo, đang chờ thanh toán
+	VoucherRedemptionRedeemed VoucherRedemptionStatus = "REDEEMED" // Hoá đơn đã được thanh toán
+	VoucherRedemptionReleased VoucherRedemptionStatus = "RELEASED" // Hoá đơn hết hạn / thất bại, lượt dùng được trả lại
+)
+
+// VoucherSelection là voucher khách chọn khi tạo thanh toán. RouteID và DepartureDate dùng để kiểm tra
+// giới hạn tuyến / ngày khởi hành của voucher.
+type VoucherSelection struct {
+	VoucherCode   string `json:"voucher_code,omitempty"`
+	RouteID       string `json:"route_id,omitempty"`
+	DepartureDate string `json:"departure_date,omitempty"` // YYYY-MM-DD
+}
+
+// CreateVoucherRequest tạo một voucher mới cho chiến dịch marketing
+// POST /api/v1/vouchers
+type CreateVoucherRequest struct {
+	Code              string   `json:"code" binding:"required,max=50"`
+	Description       string   `json:"description,omitempty"`
+	DiscountType      string   `json:"discount_type" binding:"required,oneof=PERCENTAGE FIXED"`
+	DiscountValue     float64  `json:"discount_value" binding:"required,gt=0"`
+	MaxDiscountAmount *float64 `json:"max_discount_amount,omitempty" binding:"omitempty,gt=0"`
+	MinSpend          float64  `json:"min_spend,omitempty" binding:"gte=0"`
+	Currency          string   `json:"currency" binding:"required,oneof=vnd usd VND USD"`
+	RouteIDs          []string `json:"route_ids,omitempty"`
+	DepartureFrom     string   `json:"departure_from,omitempty"`       // YYYY-MM-DD
+	DepartureTo       string   `json:"departure_to,omitempty"`         // YYYY-MM-DD
+	ValidFrom         string   `json:"valid_from" binding:"required"`  // RFC3339
+	ValidUntil        string   `json:"valid_until" binding:"required"` // RFC3339
+	UsageLimit        *int32   `json:"usage_limit,omitempty" binding:"omitempty,gt=0"`
+	PerUserLimit      *int32   `json:"per_user_limit,omitempty" binding:"omitempty,gt=0"`
+}
+
+// ValidateVoucherRequest kiểm tra voucher và tính số tiền được giảm, không giữ lượt dùng
+// POST /api/v1/vouchers/validate
+type ValidateVoucherRequest struct {
+	Code          string  `json:"code" binding:"required"`
+	CustomerID    string  `json:"customer_id" binding:"required"`
+	Amount        float64 `json:"amount" binding:"required,gt=0"` // Tổng tiền trước giảm giá, đơn vị chính (VND, USD)
+	Currency      string  `json:"currency" binding:"required"`
+	RouteID       string  `json:"route_id,omitempty"`
+	DepartureDate string  `json:"departure_date,omitempty"` // YYYY-MM-DD
+}
+
+// ApplyVoucherRequest áp voucher vào hoá đơn chuyển khoản đang chờ thanh toán
+// POST /api/v1/vouchers/apply
+type ApplyVoucherRequest struct {
+	InvoiceID     uuid.UUID `json:"invoice_id" binding:"required"`
+	Code          string    `json:"code" binding:"required"`
+	RouteID       string    `json:"route_id,omitempty"`
+	DepartureDate string    `json:"departure_date,omitempty"` // YYYY-MM-DD
+}
+
+// RedeemVoucherRequest ghi nhận lượt dùng voucher của hoá đơn đã thanh toán
+// POST /api/v1/vouchers/redeem
+type RedeemVoucherRequest struct {
+	InvoiceID uuid.UUID `json:"invoice_id" binding:"required"`
+}
+
+// VoucherQuoteResponse là số tiền được giảm khi áp voucher vào một khoản thanh toán
+type VoucherQuoteResponse struct {
+	VoucherID      uuid.UUID `json:"voucher_id"`
+	Code           string    `json:"code"`
+	DiscountType   string    `json:"discount_type"`
+	Amount         float64   `json:"amount"`
+	DiscountAmount float64   `json:"discount_amount"`
+	FinalAmount    float64   `json:"final_amount"`
+	Currency       string    `json:"currency"`
+}
+
+// VoucherResponse là thông tin một voucher cho trang quản trị
+type VoucherResponse struct {
+	VoucherID         uuid.UUID `json:"voucher_id"`
+	Code              string    `json:"code"`
+	Description       string    `json:"description,omitempty"`
+	DiscountType      string    `json:"discount_type"`
+	DiscountValue     float64   `json:"discount_value"`
+	MaxDiscountAmount *float64  `json:"max_discount_amount,omitempty"`
+	MinSpend          float64   `json:"min_spend"`
+	Currency          string    `json:"currency"`
+	RouteIDs          []string  `json:"route_ids"`
+	DepartureFrom     string    `json:"departure_from,omitempty"`
+	DepartureTo       string    `json:"departure_to,omitempty"`
+	ValidFrom         string    `json:"valid_from"`
+	ValidUntil        string    `json:"valid_until"`
+	UsageLimit        *int32    `json:"usage_limit,omitempty"`
+	PerUserLimit      *int32    `json:"per_user_limit,omitempty"`
+	UsedCount         int32     `json:"used_count"`
+	IsActive          bool      `json:"is_active"`
+	CreatedBy         string    `json:"created_by"`
+	CreatedAt         string    `json:"created_at"`
+}
+
+// VoucherRedemptionResponse là một lượt dùng voucher
+type VoucherRedemptionResponse struct {
+	RedemptionID   uuid.UUID `json:"redemption_id"`
+	VoucherID      uuid.UUID `json:"voucher_id"`
+	InvoiceID      uuid.UUID `json:"invoice_id"`
+	CustomerID     string    `json:"customer_id"`
+	DiscountAmount float64   `json:"discount_amount"`
+	Currency       string    `json:"currency"`
+	Status         string    `json:"status"`
+	ReleaseReason  string    `json:"release_reason,omitempty"`
+	RedeemedAt     string    `json:"redeemed_at,omitempty"`
+	ReleasedAt     string    `json:"released_at,omitempty"`
+	CreatedAt      string    `json:"created_at"`
+}
+
+// VoucherDetailResponse là voucher cùng các lượt dùng gần nhất
+// GET /api/v1/vouchers/:voucher_id
+type VoucherDetailResponse struct {
+	Voucher     VoucherResponse             `json:"voucher"`
+	Redemptions []VoucherRedemptionResponse `json:"redemptions"`
+}
+
+// VouchersResponse là danh sách voucher có phân trang
+// GET /api/v1/vouchers
+type VouchersResponse struct {
+	Items []VoucherResponse `json:"items"`
+	Total int64             `json:"total"`
+	Page  int               `json:"page"`
+	Limit int               `json:"limit"`
+}
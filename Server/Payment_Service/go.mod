@@ -48,4 +48,7 @@ require (
 	github.com/twmb/franz-go v1.19.5
 	golang.org/x/crypto v0.38.0 // indirect
 	golang.org/x/text v0.25.0 // indirect
+	shared v0.0.0-00010101000000-000000000000
 )
+
+replace shared => ../shared
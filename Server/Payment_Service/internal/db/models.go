@@ -6,10 +6,21 @@ package db
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+type ExchangeRate struct {
+	RateID        uuid.UUID `json:"rate_id"`
+	BaseCurrency  string    `json:"base_currency"`
+	QuoteCurrency string    `json:"quote_currency"`
+	Rate          float64   `json:"rate"`
+	Source        string    `json:"source"`
+	EffectiveAt   time.Time `json:"effective_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
 type Invoice struct {
 	InvoiceID                  uuid.UUID      `json:"invoice_id"`
 	InvoiceNumber              string         `json:"invoice_number"`
@@ -41,4 +52,108 @@ type Invoice struct {
 	BankName                   sql.NullString `json:"bank_name"`
 	BankTransactionID          sql.NullString `json:"bank_transaction_id"`
 	BankPaymentDetails         string         `json:"bank_payment_details"`
+	ChargedCurrency            sql.NullString `json:"charged_currency"`
+	ChargedAmount              sql.NullString `json:"charged_amount"`
+	ExchangeRate               sql.NullString `json:"exchange_rate"`
+	ExchangeRateSource         sql.NullString `json:"exchange_rate_source"`
+	ExchangeRateAt             sql.NullTime   `json:"exchange_rate_at"`
+}
+
+type PaymentTransaction struct {
+	TxnRef        string         `json:"txn_ref"`
+	InvoiceID     uuid.UUID      `json:"invoice_id"`
+	Provider      string         `json:"provider"`
+	Amount        float64        `json:"amount"`
+	Status        string         `json:"status"`
+	ProviderTxnID sql.NullString `json:"provider_txn_id"`
+	PaidAt        sql.NullTime   `json:"paid_at"`
+	FailureReason string         `json:"failure_reason"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+type ReconciliationItem struct {
+	ItemID         uuid.UUID      `json:"item_id"`
+	RunID          uuid.UUID      `json:"run_id"`
+	InvoiceID      uuid.NullUUID  `json:"invoice_id"`
+	Reference      string         `json:"reference"`
+	MismatchType   string         `json:"mismatch_type"`
+	LocalStatus    string         `json:"local_status"`
+	ProviderStatus string         `json:"provider_status"`
+	LocalAmount    float64        `json:"local_amount"`
+	ProviderAmount float64        `json:"provider_amount"`
+	Details        string         `json:"details"`
+	ResolvedBy     sql.NullString `json:"resolved_by"`
+	ResolutionNote string         `json:"resolution_note"`
+	ResolvedAt     sql.NullTime   `json:"resolved_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+type ReconciliationRun struct {
+	RunID         uuid.UUID    `json:"run_id"`
+	Provider      string       `json:"provider"`
+	BusinessDate  time.Time    `json:"business_date"`
+	Source        string       `json:"source"`
+	Status        string       `json:"status"`
+	InvoiceCount  int32        `json:"invoice_count"`
+	ProviderCount int32        `json:"provider_count"`
+	MatchedCount  int32        `json:"matched_count"`
+	MismatchCount int32        `json:"mismatch_count"`
+	ErrorMessage  string       `json:"error_message"`
+	StartedBy     string       `json:"started_by"`
+	StartedAt     time.Time    `json:"started_at"`
+	FinishedAt    sql.NullTime `json:"finished_at"`
+}
+
+type Refund struct {
+	RefundID          uuid.UUID      `json:"refund_id"`
+	InvoiceID         uuid.UUID      `json:"invoice_id"`
+	Amount            float64        `json:"amount"`
+	Reason            string         `json:"reason"`
+	InitiatedBy       string         `json:"initiated_by"`
+	Provider          string         `json:"provider"`
+	ProviderReference sql.NullString `json:"provider_reference"`
+	Status            string         `json:"status"`
+	FailureReason     string         `json:"failure_reason"`
+	RequestKey        sql.NullString `json:"request_key"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+}
+
+type Voucher struct {
+	VoucherID         uuid.UUID      `json:"voucher_id"`
+	Code              string         `json:"code"`
+	Description       string         `json:"description"`
+	DiscountType      string         `json:"discount_type"`
+	DiscountValue     float64        `json:"discount_value"`
+	MaxDiscountAmount sql.NullString `json:"max_discount_amount"`
+	MinSpend          float64        `json:"min_spend"`
+	Currency          string         `json:"currency"`
+	RouteIds          []string       `json:"route_ids"`
+	DepartureFrom     sql.NullTime   `json:"departure_from"`
+	DepartureTo       sql.NullTime   `json:"departure_to"`
+	ValidFrom         time.Time      `json:"valid_from"`
+	ValidUntil        time.Time      `json:"valid_until"`
+	UsageLimit        sql.NullInt32  `json:"usage_limit"`
+	PerUserLimit      sql.NullInt32  `json:"per_user_limit"`
+	UsedCount         int32          `json:"used_count"`
+	IsActive          bool           `json:"is_active"`
+	CreatedBy         string         `json:"created_by"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+}
+
+type VoucherRedemption struct {
+	RedemptionID   uuid.UUID    `json:"redemption_id"`
+	VoucherID      uuid.UUID    `json:"voucher_id"`
+	InvoiceID      uuid.UUID    `json:"invoice_id"`
+	CustomerID     string       `json:"customer_id"`
+	DiscountAmount float64      `json:"discount_amount"`
+	Currency       string       `json:"currency"`
+	Status         string       `json:"status"`
+	ReleaseReason  string       `json:"release_reason"`
+	RedeemedAt     sql.NullTime `json:"redeemed_at"`
+	ReleasedAt     sql.NullTime `json:"released_at"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
 }
@@ -12,17 +12,66 @@ import (
 )
 
 type Querier interface {
+	// Only a PENDING transaction is completed so that repeated IPN/callbacks are applied once
+	CompletePaymentTransaction(ctx context.Context, arg CompletePaymentTransactionParams) (PaymentTransaction, error)
+	// Only a PENDING refund is completed so that the invoice status is settled once per refund
+	CompleteRefund(ctx context.Context, arg CompleteRefundParams) (Refund, error)
+	CountActiveVoucherRedemptionsByCustomer(ctx context.Context, arg CountActiveVoucherRedemptionsByCustomerParams) (int64, error)
+	CountReconciliationRuns(ctx context.Context, arg CountReconciliationRunsParams) (int64, error)
+	CountVouchers(ctx context.Context, activeOnly bool) (int64, error)
 	CreateInvoice(ctx context.Context, arg CreateInvoiceParams) (Invoice, error)
+	CreatePaymentTransaction(ctx context.Context, arg CreatePaymentTransactionParams) (PaymentTransaction, error)
+	CreateReconciliationItem(ctx context.Context, arg CreateReconciliationItemParams) (ReconciliationItem, error)
+	CreateReconciliationRun(ctx context.Context, arg CreateReconciliationRunParams) (ReconciliationRun, error)
+	CreateRefund(ctx context.Context, arg CreateRefundParams) (Refund, error)
+	CreateVoucher(ctx context.Context, arg CreateVoucherParams) (Voucher, error)
+	CreateVoucherRedemption(ctx context.Context, arg CreateVoucherRedemptionParams) (VoucherRedemption, error)
+	DeactivateVoucher(ctx context.Context, voucherID uuid.UUID) (Voucher, error)
+	DecrementVoucherUsedCount(ctx context.Context, voucherID uuid.UUID) error
+	// Close the open gateway transactions of an invoice that failed or expired
+	FailPendingPaymentTransactions(ctx context.Context, arg FailPendingPaymentTransactionsParams) error
+	// A failed refund releases its request key so that the same request can be retried
+	FailRefund(ctx context.Context, arg FailRefundParams) (Refund, error)
+	FinishReconciliationRun(ctx context.Context, arg FinishReconciliationRunParams) (ReconciliationRun, error)
+	GetActiveVoucherRedemptionByInvoiceID(ctx context.Context, invoiceID uuid.UUID) (VoucherRedemption, error)
 	GetInvoiceByBankTransferCode(ctx context.Context, bankTransferCode sql.NullString) (Invoice, error)
 	GetInvoiceByID(ctx context.Context, invoiceID uuid.UUID) (Invoice, error)
+	GetInvoiceByIDForUpdate(ctx context.Context, invoiceID uuid.UUID) (Invoice, error)
 	GetInvoiceByStripePaymentIntentID(ctx context.Context, stripePaymentIntentID sql.NullString) (Invoice, error)
 	GetInvoiceByVNPayTxnRef(ctx context.Context, vnpayTxnRef sql.NullString) (Invoice, error)
+	// Paid invoices, including those that have already been partially refunded
 	GetLatestCompletedInvoiceByTicketID(ctx context.Context, ticketID string) (Invoice, error)
+	GetLatestExchangeRate(ctx context.Context, arg GetLatestExchangeRateParams) (ExchangeRate, error)
+	GetLatestPaymentTransactionByInvoiceID(ctx context.Context, invoiceID uuid.UUID) (PaymentTransaction, error)
+	GetPaymentTransactionByTxnRef(ctx context.Context, txnRef string) (PaymentTransaction, error)
+	GetReconciliationItem(ctx context.Context, itemID uuid.UUID) (ReconciliationItem, error)
+	GetReconciliationRun(ctx context.Context, runID uuid.UUID) (ReconciliationRun, error)
+	GetRefundByID(ctx context.Context, refundID uuid.UUID) (Refund, error)
+	GetRefundByRequestKey(ctx context.Context, requestKey sql.NullString) (Refund, error)
+	GetVoucherByCode(ctx context.Context, code string) (Voucher, error)
+	// Locks the voucher while its usage caps are checked and a redemption is reserved
+	GetVoucherByCodeForUpdate(ctx context.Context, code string) (Voucher, error)
+	GetVoucherByID(ctx context.Context, voucherID uuid.UUID) (Voucher, error)
+	IncrementVoucherUsedCount(ctx context.Context, voucherID uuid.UUID) error
 	ListInvoicesByCustomerID(ctx context.Context, customerID string) ([]Invoice, error)
+	ListInvoicesForReconciliation(ctx context.Context, arg ListInvoicesForReconciliationParams) ([]Invoice, error)
+	ListReconciliationItemsByRunID(ctx context.Context, runID uuid.UUID) ([]ReconciliationItem, error)
+	ListReconciliationRuns(ctx context.Context, arg ListReconciliationRunsParams) ([]ReconciliationRun, error)
+	ListRefundsByInvoiceID(ctx context.Context, invoiceID uuid.UUID) ([]Refund, error)
+	ListVoucherRedemptionsByVoucherID(ctx context.Context, arg ListVoucherRedemptionsByVoucherIDParams) ([]VoucherRedemption, error)
+	ListVouchers(ctx context.Context, arg ListVouchersParams) ([]Voucher, error)
+	// Only a RESERVED redemption is redeemed; callbacks delivered twice are no-ops
+	RedeemVoucherRedemption(ctx context.Context, invoiceID uuid.UUID) (VoucherRedemption, error)
+	ReleaseVoucherRedemption(ctx context.Context, arg ReleaseVoucherRedemptionParams) (VoucherRedemption, error)
+	// An item is resolved once; the first resolution note is kept
+	ResolveReconciliationItem(ctx context.Context, arg ResolveReconciliationItemParams) (ReconciliationItem, error)
+	SumRefundsByInvoiceID(ctx context.Context, invoiceID uuid.UUID) (SumRefundsByInvoiceIDRow, error)
 	// Used when an admin/system confirms a bank payment
 	UpdateInvoiceBankPaymentConfirmation(ctx context.Context, arg UpdateInvoiceBankPaymentConfirmationParams) (Invoice, error)
 	// Used when creating a bank payment request (invoice is PENDING or AWAITING_CONFIRMATION)
 	UpdateInvoiceBankPaymentRequest(ctx context.Context, arg UpdateInvoiceBankPaymentRequestParams) (Invoice, error)
+	// Used when a voucher is applied to an invoice that is still awaiting payment
+	UpdateInvoiceDiscount(ctx context.Context, arg UpdateInvoiceDiscountParams) (Invoice, error)
 	// Update status when payment fails (generic for Stripe, VNPay, or Bank based on invoice_id)
 	UpdateInvoicePaymentFailed(ctx context.Context, arg UpdateInvoicePaymentFailedParams) (Invoice, error)
 	// Update general status (e.g., REFUNDED, CANCELED)
@@ -8,10 +8,132 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const completePaymentTransaction = `-- name: CompletePaymentTransaction :one
+UPDATE payment_transactions
+SET
+    status = 'COMPLETED',
+    provider_txn_id = $2,
+    paid_at = $3,
+    updated_at = NOW()
+WHERE txn_ref = $1 AND status = 'PENDING'
+RETURNING txn_ref, invoice_id, provider, amount, status, provider_txn_id, paid_at, failure_reason, created_at, updated_at
+`
+
+type CompletePaymentTransactionParams struct {
+	TxnRef        string         `json:"txn_ref"`
+	ProviderTxnID sql.NullString `json:"provider_txn_id"`
+	PaidAt        sql.NullTime   `json:"paid_at"`
+}
+
+// Only a PENDING transaction is completed so that repeated IPN/callbacks are applied once
+func (q *Queries) CompletePaymentTransaction(ctx context.Context, arg CompletePaymentTransactionParams) (PaymentTransaction, error) {
+	row := q.db.QueryRowContext(ctx, completePaymentTransaction, arg.TxnRef, arg.ProviderTxnID, arg.PaidAt)
+	var i PaymentTransaction
+	err := row.Scan(
+		&i.TxnRef,
+		&i.InvoiceID,
+		&i.Provider,
+		&i.Amount,
+		&i.Status,
+		&i.ProviderTxnID,
+		&i.PaidAt,
+		&i.FailureReason,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const completeRefund = `-- name: CompleteRefund :one
+UPDATE refunds
+SET
+    status = 'COMPLETED',
+    provider_reference = $2,
+    updated_at = NOW()
+WHERE refund_id = $1 AND status = 'PENDING'
+RETURNING refund_id, invoice_id, amount, reason, initiated_by, provider, provider_reference, status, failure_reason, request_key, created_at, updated_at
+`
+
+type CompleteRefundParams struct {
+	RefundID          uuid.UUID      `json:"refund_id"`
+	ProviderReference sql.NullString `json:"provider_reference"`
+}
+
+// Only a PENDING refund is completed so that the invoice status is settled once per refund
+func (q *Queries) CompleteRefund(ctx context.Context, arg CompleteRefundParams) (Refund, error) {
+	row := q.db.QueryRowContext(ctx, completeRefund, arg.RefundID, arg.ProviderReference)
+	var i Refund
+	err := row.Scan(
+		&i.RefundID,
+		&i.InvoiceID,
+		&i.Amount,
+		&i.Reason,
+		&i.InitiatedBy,
+		&i.Provider,
+		&i.ProviderReference,
+		&i.Status,
+		&i.FailureReason,
+		&i.RequestKey,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const countActiveVoucherRedemptionsByCustomer = `-- name: CountActiveVoucherRedemptionsByCustomer :one
+SELECT COUNT(*) FROM voucher_redemptions
+WHERE voucher_id = $1 AND customer_id = $2 AND status IN ('RESERVED', 'REDEEMED')
+`
+
+type CountActiveVoucherRedemptionsByCustomerParams struct {
+	VoucherID  uuid.UUID `json:"voucher_id"`
+	CustomerID string    `json:"customer_id"`
+}
+
+func (q *Queries) CountActiveVoucherRedemptionsByCustomer(ctx context.Context, arg CountActiveVoucherRedemptionsByCustomerParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countActiveVoucherRedemptionsByCustomer, arg.VoucherID, arg.CustomerID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countReconciliationRuns = `-- name: CountReconciliationRuns :one
+SELECT COUNT(*) FROM reconciliation_runs
+WHERE ($1::text = '' OR provider = $1)
+    AND business_date BETWEEN $2::date AND $3::date
+`
+
+type CountReconciliationRunsParams struct {
+	Provider string    `json:"provider"`
+	FromDate time.Time `json:"from_date"`
+	ToDate   time.Time `json:"to_date"`
+}
+
+func (q *Queries) CountReconciliationRuns(ctx context.Context, arg CountReconciliationRunsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countReconciliationRuns, arg.Provider, arg.FromDate, arg.ToDate)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countVouchers = `-- name: CountVouchers :one
+SELECT COUNT(*) FROM vouchers
+WHERE (NOT $1::boolean OR is_active)
+`
+
+func (q *Queries) CountVouchers(ctx context.Context, activeOnly bool) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countVouchers, activeOnly)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createInvoice = `-- name: CreateInvoice :one
 INSERT INTO invoices (
     invoice_id,
@@ -40,10 +162,16 @@ INSERT INTO invoices (
     stripe_payment_method_details,
     -- bank transfer fields
     bank_transfer_code,
-    bank_payment_details -- Other bank fields like account_name, account_number, bank_name might be updated later upon confirmation
+    bank_payment_details, -- Other bank fields like account_name, account_number, bank_name might be updated later upon confirmation
+    -- charged currency and exchange-rate snapshot
+    charged_currency,
+    charged_amount,
+    exchange_rate,
+    exchange_rate_source,
+    exchange_rate_at
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24
-) RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29
+) RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at
 `
 
 type CreateInvoiceParams struct {
@@ -71,6 +199,11 @@ type CreateInvoiceParams struct {
 	StripePaymentMethodDetails string         `json:"stripe_payment_method_details"`
 	BankTransferCode           sql.NullString `json:"bank_transfer_code"`
 	BankPaymentDetails         string         `json:"bank_payment_details"`
+	ChargedCurrency            sql.NullString `json:"charged_currency"`
+	ChargedAmount              sql.NullString `json:"charged_amount"`
+	ExchangeRate               sql.NullString `json:"exchange_rate"`
+	ExchangeRateSource         sql.NullString `json:"exchange_rate_source"`
+	ExchangeRateAt             sql.NullTime   `json:"exchange_rate_at"`
 }
 
 func (q *Queries) CreateInvoice(ctx context.Context, arg CreateInvoiceParams) (Invoice, error) {
@@ -99,6 +232,11 @@ func (q *Queries) CreateInvoice(ctx context.Context, arg CreateInvoiceParams) (I
 		arg.StripePaymentMethodDetails,
 		arg.BankTransferCode,
 		arg.BankPaymentDetails,
+		arg.ChargedCurrency,
+		arg.ChargedAmount,
+		arg.ExchangeRate,
+		arg.ExchangeRateSource,
+		arg.ExchangeRateAt,
 	)
 	var i Invoice
 	err := row.Scan(
@@ -132,12 +270,529 @@ func (q *Queries) CreateInvoice(ctx context.Context, arg CreateInvoiceParams) (I
 		&i.BankName,
 		&i.BankTransactionID,
 		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
+	)
+	return i, err
+}
+
+const createPaymentTransaction = `-- name: CreatePaymentTransaction :one
+INSERT INTO payment_transactions (
+    txn_ref,
+    invoice_id,
+    provider,
+    amount
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING txn_ref, invoice_id, provider, amount, status, provider_txn_id, paid_at, failure_reason, created_at, updated_at
+`
+
+type CreatePaymentTransactionParams struct {
+	TxnRef    string    `json:"txn_ref"`
+	InvoiceID uuid.UUID `json:"invoice_id"`
+	Provider  string    `json:"provider"`
+	Amount    float64   `json:"amount"`
+}
+
+func (q *Queries) CreatePaymentTransaction(ctx context.Context, arg CreatePaymentTransactionParams) (PaymentTransaction, error) {
+	row := q.db.QueryRowContext(ctx, createPaymentTransaction,
+		arg.TxnRef,
+		arg.InvoiceID,
+		arg.Provider,
+		arg.Amount,
+	)
+	var i PaymentTransaction
+	err := row.Scan(
+		&i.TxnRef,
+		&i.InvoiceID,
+		&i.Provider,
+		&i.Amount,
+		&i.Status,
+		&i.ProviderTxnID,
+		&i.PaidAt,
+		&i.FailureReason,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createReconciliationItem = `-- name: CreateReconciliationItem :one
+INSERT INTO reconciliation_items (
+    run_id,
+    invoice_id,
+    reference,
+    mismatch_type,
+    local_status,
+    provider_status,
+    local_amount,
+    provider_amount,
+    details
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+) RETURNING item_id, run_id, invoice_id, reference, mismatch_type, local_status, provider_status, local_amount, provider_amount, details, resolved_by, resolution_note, resolved_at, created_at
+`
+
+type CreateReconciliationItemParams struct {
+	RunID          uuid.UUID     `json:"run_id"`
+	InvoiceID      uuid.NullUUID `json:"invoice_id"`
+	Reference      string        `json:"reference"`
+	MismatchType   string        `json:"mismatch_type"`
+	LocalStatus    string        `json:"local_status"`
+	ProviderStatus string        `json:"provider_status"`
+	LocalAmount    float64       `json:"local_amount"`
+	ProviderAmount float64       `json:"provider_amount"`
+	Details        string        `json:"details"`
+}
+
+func (q *Queries) CreateReconciliationItem(ctx context.Context, arg CreateReconciliationItemParams) (ReconciliationItem, error) {
+	row := q.db.QueryRowContext(ctx, createReconciliationItem,
+		arg.RunID,
+		arg.InvoiceID,
+		arg.Reference,
+		arg.MismatchType,
+		arg.LocalStatus,
+		arg.ProviderStatus,
+		arg.LocalAmount,
+		arg.ProviderAmount,
+		arg.Details,
+	)
+	var i ReconciliationItem
+	err := row.Scan(
+		&i.ItemID,
+		&i.RunID,
+		&i.InvoiceID,
+		&i.Reference,
+		&i.MismatchType,
+		&i.LocalStatus,
+		&i.ProviderStatus,
+		&i.LocalAmount,
+		&i.ProviderAmount,
+		&i.Details,
+		&i.ResolvedBy,
+		&i.ResolutionNote,
+		&i.ResolvedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createReconciliationRun = `-- name: CreateReconciliationRun :one
+INSERT INTO reconciliation_runs (
+    provider,
+    business_date,
+    source,
+    started_by
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING run_id, provider, business_date, source, status, invoice_count, provider_count, matched_count, mismatch_count, error_message, started_by, started_at, finished_at
+`
+
+type CreateReconciliationRunParams struct {
+	Provider     string    `json:"provider"`
+	BusinessDate time.Time `json:"business_date"`
+	Source       string    `json:"source"`
+	StartedBy    string    `json:"started_by"`
+}
+
+func (q *Queries) CreateReconciliationRun(ctx context.Context, arg CreateReconciliationRunParams) (ReconciliationRun, error) {
+	row := q.db.QueryRowContext(ctx, createReconciliationRun,
+		arg.Provider,
+		arg.BusinessDate,
+		arg.Source,
+		arg.StartedBy,
+	)
+	var i ReconciliationRun
+	err := row.Scan(
+		&i.RunID,
+		&i.Provider,
+		&i.BusinessDate,
+		&i.Source,
+		&i.Status,
+		&i.InvoiceCount,
+		&i.ProviderCount,
+		&i.MatchedCount,
+		&i.MismatchCount,
+		&i.ErrorMessage,
+		&i.StartedBy,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const createRefund = `-- name: CreateRefund :one
+INSERT INTO refunds (
+    refund_id,
+    invoice_id,
+    amount,
+    reason,
+    initiated_by,
+    provider,
+    request_key
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+) RETURNING refund_id, invoice_id, amount, reason, initiated_by, provider, provider_reference, status, failure_reason, request_key, created_at, updated_at
+`
+
+type CreateRefundParams struct {
+	RefundID    uuid.UUID      `json:"refund_id"`
+	InvoiceID   uuid.UUID      `json:"invoice_id"`
+	Amount      float64        `json:"amount"`
+	Reason      string         `json:"reason"`
+	InitiatedBy string         `json:"initiated_by"`
+	Provider    string         `json:"provider"`
+	RequestKey  sql.NullString `json:"request_key"`
+}
+
+func (q *Queries) CreateRefund(ctx context.Context, arg CreateRefundParams) (Refund, error) {
+	row := q.db.QueryRowContext(ctx, createRefund,
+		arg.RefundID,
+		arg.InvoiceID,
+		arg.Amount,
+		arg.Reason,
+		arg.InitiatedBy,
+		arg.Provider,
+		arg.RequestKey,
+	)
+	var i Refund
+	err := row.Scan(
+		&i.RefundID,
+		&i.InvoiceID,
+		&i.Amount,
+		&i.Reason,
+		&i.InitiatedBy,
+		&i.Provider,
+		&i.ProviderReference,
+		&i.Status,
+		&i.FailureReason,
+		&i.RequestKey,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createVoucher = `-- name: CreateVoucher :one
+INSERT INTO vouchers (
+    code,
+    description,
+    discount_type,
+    discount_value,
+    max_discount_amount,
+    min_spend,
+    currency,
+    route_ids,
+    departure_from,
+    departure_to,
+    valid_from,
+    valid_until,
+    usage_limit,
+    per_user_limit,
+    created_by
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
+) RETURNING voucher_id, code, description, discount_type, discount_value, max_discount_amount, min_spend, currency, route_ids, departure_from, departure_to, valid_from, valid_until, usage_limit, per_user_limit, used_count, is_active, created_by, created_at, updated_at
+`
+
+type CreateVoucherParams struct {
+	Code              string         `json:"code"`
+	Description       string         `json:"description"`
+	DiscountType      string         `json:"discount_type"`
+	DiscountValue     float64        `json:"discount_value"`
+	MaxDiscountAmount sql.NullString `json:"max_discount_amount"`
+	MinSpend          float64        `json:"min_spend"`
+	Currency          string         `json:"currency"`
+	RouteIds          []string       `json:"route_ids"`
+	DepartureFrom     sql.NullTime   `json:"departure_from"`
+	DepartureTo       sql.NullTime   `json:"departure_to"`
+	ValidFrom         time.Time      `json:"valid_from"`
+	ValidUntil        time.Time      `json:"valid_until"`
+	UsageLimit        sql.NullInt32  `json:"usage_limit"`
+	PerUserLimit      sql.NullInt32  `json:"per_user_limit"`
+	CreatedBy         string         `json:"created_by"`
+}
+
+func (q *Queries) CreateVoucher(ctx context.Context, arg CreateVoucherParams) (Voucher, error) {
+	row := q.db.QueryRowContext(ctx, createVoucher,
+		arg.Code,
+		arg.Description,
+		arg.DiscountType,
+		arg.DiscountValue,
+		arg.MaxDiscountAmount,
+		arg.MinSpend,
+		arg.Currency,
+		pq.Array(arg.RouteIds),
+		arg.DepartureFrom,
+		arg.DepartureTo,
+		arg.ValidFrom,
+		arg.ValidUntil,
+		arg.UsageLimit,
+		arg.PerUserLimit,
+		arg.CreatedBy,
+	)
+	var i Voucher
+	err := row.Scan(
+		&i.VoucherID,
+		&i.Code,
+		&i.Description,
+		&i.DiscountType,
+		&i.DiscountValue,
+		&i.MaxDiscountAmount,
+		&i.MinSpend,
+		&i.Currency,
+		pq.Array(&i.RouteIds),
+		&i.DepartureFrom,
+		&i.DepartureTo,
+		&i.ValidFrom,
+		&i.ValidUntil,
+		&i.UsageLimit,
+		&i.PerUserLimit,
+		&i.UsedCount,
+		&i.IsActive,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createVoucherRedemption = `-- name: CreateVoucherRedemption :one
+INSERT INTO voucher_redemptions (
+    voucher_id,
+    invoice_id,
+    customer_id,
+    discount_amount,
+    currency
+) VALUES (
+    $1, $2, $3, $4, $5
+) RETURNING redemption_id, voucher_id, invoice_id, customer_id, discount_amount, currency, status, release_reason, redeemed_at, released_at, created_at, updated_at
+`
+
+type CreateVoucherRedemptionParams struct {
+	VoucherID      uuid.UUID `json:"voucher_id"`
+	InvoiceID      uuid.UUID `json:"invoice_id"`
+	CustomerID     string    `json:"customer_id"`
+	DiscountAmount float64   `json:"discount_amount"`
+	Currency       string    `json:"currency"`
+}
+
+func (q *Queries) CreateVoucherRedemption(ctx context.Context, arg CreateVoucherRedemptionParams) (VoucherRedemption, error) {
+	row := q.db.QueryRowContext(ctx, createVoucherRedemption,
+		arg.VoucherID,
+		arg.InvoiceID,
+		arg.CustomerID,
+		arg.DiscountAmount,
+		arg.Currency,
+	)
+	var i VoucherRedemption
+	err := row.Scan(
+		&i.RedemptionID,
+		&i.VoucherID,
+		&i.InvoiceID,
+		&i.CustomerID,
+		&i.DiscountAmount,
+		&i.Currency,
+		&i.Status,
+		&i.ReleaseReason,
+		&i.RedeemedAt,
+		&i.ReleasedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deactivateVoucher = `-- name: DeactivateVoucher :one
+UPDATE vouchers
+SET
+    is_active = FALSE,
+    updated_at = NOW()
+WHERE voucher_id = $1
+RETURNING voucher_id, code, description, discount_type, discount_value, max_discount_amount, min_spend, currency, route_ids, departure_from, departure_to, valid_from, valid_until, usage_limit, per_user_limit, used_count, is_active, created_by, created_at, updated_at
+`
+
+func (q *Queries) DeactivateVoucher(ctx context.Context, voucherID uuid.UUID) (Voucher, error) {
+	row := q.db.QueryRowContext(ctx, deactivateVoucher, voucherID)
+	var i Voucher
+	err := row.Scan(
+		&i.VoucherID,
+		&i.Code,
+		&i.Description,
+		&i.DiscountType,
+		&i.DiscountValue,
+		&i.MaxDiscountAmount,
+		&i.MinSpend,
+		&i.Currency,
+		pq.Array(&i.RouteIds),
+		&i.DepartureFrom,
+		&i.DepartureTo,
+		&i.ValidFrom,
+		&i.ValidUntil,
+		&i.UsageLimit,
+		&i.PerUserLimit,
+		&i.UsedCount,
+		&i.IsActive,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const decrementVoucherUsedCount = `-- name: DecrementVoucherUsedCount :exec
+UPDATE vouchers
+SET
+    used_count = GREATEST(used_count - 1, 0),
+    updated_at = NOW()
+WHERE voucher_id = $1
+`
+
+func (q *Queries) DecrementVoucherUsedCount(ctx context.Context, voucherID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, decrementVoucherUsedCount, voucherID)
+	return err
+}
+
+const failPendingPaymentTransactions = `-- name: FailPendingPaymentTransactions :exec
+UPDATE payment_transactions
+SET
+    status = 'FAILED',
+    failure_reason = $2,
+    updated_at = NOW()
+WHERE invoice_id = $1 AND status = 'PENDING'
+`
+
+type FailPendingPaymentTransactionsParams struct {
+	InvoiceID     uuid.UUID `json:"invoice_id"`
+	FailureReason string    `json:"failure_reason"`
+}
+
+// Close the open gateway transactions of an invoice that failed or expired
+func (q *Queries) FailPendingPaymentTransactions(ctx context.Context, arg FailPendingPaymentTransactionsParams) error {
+	_, err := q.db.ExecContext(ctx, failPendingPaymentTransactions, arg.InvoiceID, arg.FailureReason)
+	return err
+}
+
+const failRefund = `-- name: FailRefund :one
+UPDATE refunds
+SET
+    status = 'FAILED',
+    failure_reason = $2,
+    request_key = NULL,
+    updated_at = NOW()
+WHERE refund_id = $1 AND status = 'PENDING'
+RETURNING refund_id, invoice_id, amount, reason, initiated_by, provider, provider_reference, status, failure_reason, request_key, created_at, updated_at
+`
+
+type FailRefundParams struct {
+	RefundID      uuid.UUID `json:"refund_id"`
+	FailureReason string    `json:"failure_reason"`
+}
+
+// A failed refund releases its request key so that the same request can be retried
+func (q *Queries) FailRefund(ctx context.Context, arg FailRefundParams) (Refund, error) {
+	row := q.db.QueryRowContext(ctx, failRefund, arg.RefundID, arg.FailureReason)
+	var i Refund
+	err := row.Scan(
+		&i.RefundID,
+		&i.InvoiceID,
+		&i.Amount,
+		&i.Reason,
+		&i.InitiatedBy,
+		&i.Provider,
+		&i.ProviderReference,
+		&i.Status,
+		&i.FailureReason,
+		&i.RequestKey,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const finishReconciliationRun = `-- name: FinishReconciliationRun :one
+UPDATE reconciliation_runs
+SET
+    status = $2,
+    invoice_count = $3,
+    provider_count = $4,
+    matched_count = $5,
+    mismatch_count = $6,
+    error_message = $7,
+    finished_at = NOW()
+WHERE run_id = $1
+RETURNING run_id, provider, business_date, source, status, invoice_count, provider_count, matched_count, mismatch_count, error_message, started_by, started_at, finished_at
+`
+
+type FinishReconciliationRunParams struct {
+	RunID         uuid.UUID `json:"run_id"`
+	Status        string    `json:"status"`
+	InvoiceCount  int32     `json:"invoice_count"`
+	ProviderCount int32     `json:"provider_count"`
+	MatchedCount  int32     `json:"matched_count"`
+	MismatchCount int32     `json:"mismatch_count"`
+	ErrorMessage  string    `json:"error_message"`
+}
+
+func (q *Queries) FinishReconciliationRun(ctx context.Context, arg FinishReconciliationRunParams) (ReconciliationRun, error) {
+	row := q.db.QueryRowContext(ctx, finishReconciliationRun,
+		arg.RunID,
+		arg.Status,
+		arg.InvoiceCount,
+		arg.ProviderCount,
+		arg.MatchedCount,
+		arg.MismatchCount,
+		arg.ErrorMessage,
+	)
+	var i ReconciliationRun
+	err := row.Scan(
+		&i.RunID,
+		&i.Provider,
+		&i.BusinessDate,
+		&i.Source,
+		&i.Status,
+		&i.InvoiceCount,
+		&i.ProviderCount,
+		&i.MatchedCount,
+		&i.MismatchCount,
+		&i.ErrorMessage,
+		&i.StartedBy,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const getActiveVoucherRedemptionByInvoiceID = `-- name: GetActiveVoucherRedemptionByInvoiceID :one
+SELECT redemption_id, voucher_id, invoice_id, customer_id, discount_amount, currency, status, release_reason, redeemed_at, released_at, created_at, updated_at FROM voucher_redemptions
+WHERE invoice_id = $1 AND status <> 'RELEASED' LIMIT 1
+`
+
+func (q *Queries) GetActiveVoucherRedemptionByInvoiceID(ctx context.Context, invoiceID uuid.UUID) (VoucherRedemption, error) {
+	row := q.db.QueryRowContext(ctx, getActiveVoucherRedemptionByInvoiceID, invoiceID)
+	var i VoucherRedemption
+	err := row.Scan(
+		&i.RedemptionID,
+		&i.VoucherID,
+		&i.InvoiceID,
+		&i.CustomerID,
+		&i.DiscountAmount,
+		&i.Currency,
+		&i.Status,
+		&i.ReleaseReason,
+		&i.RedeemedAt,
+		&i.ReleasedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
 
 const getInvoiceByBankTransferCode = `-- name: GetInvoiceByBankTransferCode :one
-SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details FROM invoices
+SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at FROM invoices
 WHERE bank_transfer_code = $1 LIMIT 1
 `
 
@@ -175,12 +830,17 @@ func (q *Queries) GetInvoiceByBankTransferCode(ctx context.Context, bankTransfer
 		&i.BankName,
 		&i.BankTransactionID,
 		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
 	)
 	return i, err
 }
 
 const getInvoiceByID = `-- name: GetInvoiceByID :one
-SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details FROM invoices
+SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at FROM invoices
 WHERE invoice_id = $1 LIMIT 1
 `
 
@@ -218,12 +878,66 @@ func (q *Queries) GetInvoiceByID(ctx context.Context, invoiceID uuid.UUID) (Invo
 		&i.BankName,
 		&i.BankTransactionID,
 		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
+	)
+	return i, err
+}
+
+const getInvoiceByIDForUpdate = `-- name: GetInvoiceByIDForUpdate :one
+SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at FROM invoices
+WHERE invoice_id = $1 LIMIT 1
+FOR UPDATE
+`
+
+func (q *Queries) GetInvoiceByIDForUpdate(ctx context.Context, invoiceID uuid.UUID) (Invoice, error) {
+	row := q.db.QueryRowContext(ctx, getInvoiceByIDForUpdate, invoiceID)
+	var i Invoice
+	err := row.Scan(
+		&i.InvoiceID,
+		&i.InvoiceNumber,
+		&i.InvoiceType,
+		&i.CustomerID,
+		&i.TicketID,
+		&i.TotalAmount,
+		&i.DiscountAmount,
+		&i.TaxAmount,
+		&i.FinalAmount,
+		&i.Currency,
+		&i.PaymentStatus,
+		&i.PaymentMethod,
+		&i.IssueDate,
+		&i.Notes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.VnpayTxnRef,
+		&i.VnpayBankCode,
+		&i.VnpayTxnNo,
+		&i.VnpayPayDate,
+		&i.StripePaymentIntentID,
+		&i.StripeChargeID,
+		&i.StripeCustomerID,
+		&i.StripePaymentMethodDetails,
+		&i.BankTransferCode,
+		&i.BankAccountName,
+		&i.BankAccountNumber,
+		&i.BankName,
+		&i.BankTransactionID,
+		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
 	)
 	return i, err
 }
 
 const getInvoiceByStripePaymentIntentID = `-- name: GetInvoiceByStripePaymentIntentID :one
-SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details FROM invoices
+SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at FROM invoices
 WHERE stripe_payment_intent_id = $1 LIMIT 1
 `
 
@@ -261,12 +975,17 @@ func (q *Queries) GetInvoiceByStripePaymentIntentID(ctx context.Context, stripeP
 		&i.BankName,
 		&i.BankTransactionID,
 		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
 	)
 	return i, err
 }
 
 const getInvoiceByVNPayTxnRef = `-- name: GetInvoiceByVNPayTxnRef :one
-SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details FROM invoices
+SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at FROM invoices
 WHERE vnpay_txn_ref = $1 LIMIT 1
 `
 
@@ -304,17 +1023,23 @@ func (q *Queries) GetInvoiceByVNPayTxnRef(ctx context.Context, vnpayTxnRef sql.N
 		&i.BankName,
 		&i.BankTransactionID,
 		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
 	)
 	return i, err
 }
 
 const getLatestCompletedInvoiceByTicketID = `-- name: GetLatestCompletedInvoiceByTicketID :one
-SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details FROM invoices
-WHERE ticket_id = $1 AND payment_status = 'COMPLETED'
+SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at FROM invoices
+WHERE ticket_id = $1 AND payment_status IN ('COMPLETED', 'PARTIALLY_REFUNDED')
 ORDER BY created_at DESC
 LIMIT 1
 `
 
+// Paid invoices, including those that have already been partially refunded
 func (q *Queries) GetLatestCompletedInvoiceByTicketID(ctx context.Context, ticketID string) (Invoice, error) {
 	row := q.db.QueryRowContext(ctx, getLatestCompletedInvoiceByTicketID, ticketID)
 	var i Invoice
@@ -349,23 +1074,320 @@ func (q *Queries) GetLatestCompletedInvoiceByTicketID(ctx context.Context, ticke
 		&i.BankName,
 		&i.BankTransactionID,
 		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
 	)
 	return i, err
 }
 
-const listInvoicesByCustomerID = `-- name: ListInvoicesByCustomerID :many
-SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details FROM invoices
-WHERE customer_id = $1
-ORDER BY created_at DESC
+const getLatestExchangeRate = `-- name: GetLatestExchangeRate :one
+SELECT rate_id, base_currency, quote_currency, rate, source, effective_at, created_at FROM exchange_rates
+WHERE base_currency = $1 AND quote_currency = $2 AND effective_at <= NOW()
+ORDER BY effective_at DESC
+LIMIT 1
 `
 
-func (q *Queries) ListInvoicesByCustomerID(ctx context.Context, customerID string) ([]Invoice, error) {
-	rows, err := q.db.QueryContext(ctx, listInvoicesByCustomerID, customerID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	items := []Invoice{}
+type GetLatestExchangeRateParams struct {
+	BaseCurrency  string `json:"base_currency"`
+	QuoteCurrency string `json:"quote_currency"`
+}
+
+func (q *Queries) GetLatestExchangeRate(ctx context.Context, arg GetLatestExchangeRateParams) (ExchangeRate, error) {
+	row := q.db.QueryRowContext(ctx, getLatestExchangeRate, arg.BaseCurrency, arg.QuoteCurrency)
+	var i ExchangeRate
+	err := row.Scan(
+		&i.RateID,
+		&i.BaseCurrency,
+		&i.QuoteCurrency,
+		&i.Rate,
+		&i.Source,
+		&i.EffectiveAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getLatestPaymentTransactionByInvoiceID = `-- name: GetLatestPaymentTransactionByInvoiceID :one
+SELECT txn_ref, invoice_id, provider, amount, status, provider_txn_id, paid_at, failure_reason, created_at, updated_at FROM payment_transactions
+WHERE invoice_id = $1
+ORDER BY created_at DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestPaymentTransactionByInvoiceID(ctx context.Context, invoiceID uuid.UUID) (PaymentTransaction, error) {
+	row := q.db.QueryRowContext(ctx, getLatestPaymentTransactionByInvoiceID, invoiceID)
+	var i PaymentTransaction
+	err := row.Scan(
+		&i.TxnRef,
+		&i.InvoiceID,
+		&i.Provider,
+		&i.Amount,
+		&i.Status,
+		&i.ProviderTxnID,
+		&i.PaidAt,
+		&i.FailureReason,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getPaymentTransactionByTxnRef = `-- name: GetPaymentTransactionByTxnRef :one
+SELECT txn_ref, invoice_id, provider, amount, status, provider_txn_id, paid_at, failure_reason, created_at, updated_at FROM payment_transactions
+WHERE txn_ref = $1 LIMIT 1
+`
+
+func (q *Queries) GetPaymentTransactionByTxnRef(ctx context.Context, txnRef string) (PaymentTransaction, error) {
+	row := q.db.QueryRowContext(ctx, getPaymentTransactionByTxnRef, txnRef)
+	var i PaymentTransaction
+	err := row.Scan(
+		&i.TxnRef,
+		&i.InvoiceID,
+		&i.Provider,
+		&i.Amount,
+		&i.Status,
+		&i.ProviderTxnID,
+		&i.PaidAt,
+		&i.FailureReason,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getReconciliationItem = `-- name: GetReconciliationItem :one
+SELECT item_id, run_id, invoice_id, reference, mismatch_type, local_status, provider_status, local_amount, provider_amount, details, resolved_by, resolution_note, resolved_at, created_at FROM reconciliation_items
+WHERE item_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetReconciliationItem(ctx context.Context, itemID uuid.UUID) (ReconciliationItem, error) {
+	row := q.db.QueryRowContext(ctx, getReconciliationItem, itemID)
+	var i ReconciliationItem
+	err := row.Scan(
+		&i.ItemID,
+		&i.RunID,
+		&i.InvoiceID,
+		&i.Reference,
+		&i.MismatchType,
+		&i.LocalStatus,
+		&i.ProviderStatus,
+		&i.LocalAmount,
+		&i.ProviderAmount,
+		&i.Details,
+		&i.ResolvedBy,
+		&i.ResolutionNote,
+		&i.ResolvedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getReconciliationRun = `-- name: GetReconciliationRun :one
+SELECT run_id, provider, business_date, source, status, invoice_count, provider_count, matched_count, mismatch_count, error_message, started_by, started_at, finished_at FROM reconciliation_runs
+WHERE run_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetReconciliationRun(ctx context.Context, runID uuid.UUID) (ReconciliationRun, error) {
+	row := q.db.QueryRowContext(ctx, getReconciliationRun, runID)
+	var i ReconciliationRun
+	err := row.Scan(
+		&i.RunID,
+		&i.Provider,
+		&i.BusinessDate,
+		&i.Source,
+		&i.Status,
+		&i.InvoiceCount,
+		&i.ProviderCount,
+		&i.MatchedCount,
+		&i.MismatchCount,
+		&i.ErrorMessage,
+		&i.StartedBy,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const getRefundByID = `-- name: GetRefundByID :one
+SELECT refund_id, invoice_id, amount, reason, initiated_by, provider, provider_reference, status, failure_reason, request_key, created_at, updated_at FROM refunds
+WHERE refund_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetRefundByID(ctx context.Context, refundID uuid.UUID) (Refund, error) {
+	row := q.db.QueryRowContext(ctx, getRefundByID, refundID)
+	var i Refund
+	err := row.Scan(
+		&i.RefundID,
+		&i.InvoiceID,
+		&i.Amount,
+		&i.Reason,
+		&i.InitiatedBy,
+		&i.Provider,
+		&i.ProviderReference,
+		&i.Status,
+		&i.FailureReason,
+		&i.RequestKey,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getRefundByRequestKey = `-- name: GetRefundByRequestKey :one
+SELECT refund_id, invoice_id, amount, reason, initiated_by, provider, provider_reference, status, failure_reason, request_key, created_at, updated_at FROM refunds
+WHERE request_key = $1 LIMIT 1
+`
+
+func (q *Queries) GetRefundByRequestKey(ctx context.Context, requestKey sql.NullString) (Refund, error) {
+	row := q.db.QueryRowContext(ctx, getRefundByRequestKey, requestKey)
+	var i Refund
+	err := row.Scan(
+		&i.RefundID,
+		&i.InvoiceID,
+		&i.Amount,
+		&i.Reason,
+		&i.InitiatedBy,
+		&i.Provider,
+		&i.ProviderReference,
+		&i.Status,
+		&i.FailureReason,
+		&i.RequestKey,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getVoucherByCode = `-- name: GetVoucherByCode :one
+SELECT voucher_id, code, description, discount_type, discount_value, max_discount_amount, min_spend, currency, route_ids, departure_from, departure_to, valid_from, valid_until, usage_limit, per_user_limit, used_count, is_active, created_by, created_at, updated_at FROM vouchers
+WHERE code = $1 LIMIT 1
+`
+
+func (q *Queries) GetVoucherByCode(ctx context.Context, code string) (Voucher, error) {
+	row := q.db.QueryRowContext(ctx, getVoucherByCode, code)
+	var i Voucher
+	err := row.Scan(
+		&i.VoucherID,
+		&i.Code,
+		&i.Description,
+		&i.DiscountType,
+		&i.DiscountValue,
+		&i.MaxDiscountAmount,
+		&i.MinSpend,
+		&i.Currency,
+		pq.Array(&i.RouteIds),
+		&i.DepartureFrom,
+		&i.DepartureTo,
+		&i.ValidFrom,
+		&i.ValidUntil,
+		&i.UsageLimit,
+		&i.PerUserLimit,
+		&i.UsedCount,
+		&i.IsActive,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getVoucherByCodeForUpdate = `-- name: GetVoucherByCodeForUpdate :one
+SELECT voucher_id, code, description, discount_type, discount_value, max_discount_amount, min_spend, currency, route_ids, departure_from, departure_to, valid_from, valid_until, usage_limit, per_user_limit, used_count, is_active, created_by, created_at, updated_at FROM vouchers
+WHERE code = $1 LIMIT 1
+FOR UPDATE
+`
+
+// Locks the voucher while its usage caps are checked and a redemption is reserved
+func (q *Queries) GetVoucherByCodeForUpdate(ctx context.Context, code string) (Voucher, error) {
+	row := q.db.QueryRowContext(ctx, getVoucherByCodeForUpdate, code)
+	var i Voucher
+	err := row.Scan(
+		&i.VoucherID,
+		&i.Code,
+		&i.Description,
+		&i.DiscountType,
+		&i.DiscountValue,
+		&i.MaxDiscountAmount,
+		&i.MinSpend,
+		&i.Currency,
+		pq.Array(&i.RouteIds),
+		&i.DepartureFrom,
+		&i.DepartureTo,
+		&i.ValidFrom,
+		&i.ValidUntil,
+		&i.UsageLimit,
+		&i.PerUserLimit,
+		&i.UsedCount,
+		&i.IsActive,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getVoucherByID = `-- name: GetVoucherByID :one
+SELECT voucher_id, code, description, discount_type, discount_value, max_discount_amount, min_spend, currency, route_ids, departure_from, departure_to, valid_from, valid_until, usage_limit, per_user_limit, used_count, is_active, created_by, created_at, updated_at FROM vouchers
+WHERE voucher_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetVoucherByID(ctx context.Context, voucherID uuid.UUID) (Voucher, error) {
+	row := q.db.QueryRowContext(ctx, getVoucherByID, voucherID)
+	var i Voucher
+	err := row.Scan(
+		&i.VoucherID,
+		&i.Code,
+		&i.Description,
+		&i.DiscountType,
+		&i.DiscountValue,
+		&i.MaxDiscountAmount,
+		&i.MinSpend,
+		&i.Currency,
+		pq.Array(&i.RouteIds),
+		&i.DepartureFrom,
+		&i.DepartureTo,
+		&i.ValidFrom,
+		&i.ValidUntil,
+		&i.UsageLimit,
+		&i.PerUserLimit,
+		&i.UsedCount,
+		&i.IsActive,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const incrementVoucherUsedCount = `-- name: IncrementVoucherUsedCount :exec
+UPDATE vouchers
+SET
+    used_count = used_count + 1,
+    updated_at = NOW()
+WHERE voucher_id = $1
+`
+
+func (q *Queries) IncrementVoucherUsedCount(ctx context.Context, voucherID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, incrementVoucherUsedCount, voucherID)
+	return err
+}
+
+const listInvoicesByCustomerID = `-- name: ListInvoicesByCustomerID :many
+SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at FROM invoices
+WHERE customer_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListInvoicesByCustomerID(ctx context.Context, customerID string) ([]Invoice, error) {
+	rows, err := q.db.QueryContext(ctx, listInvoicesByCustomerID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Invoice{}
 	for rows.Next() {
 		var i Invoice
 		if err := rows.Scan(
@@ -399,6 +1421,11 @@ func (q *Queries) ListInvoicesByCustomerID(ctx context.Context, customerID strin
 			&i.BankName,
 			&i.BankTransactionID,
 			&i.BankPaymentDetails,
+			&i.ChargedCurrency,
+			&i.ChargedAmount,
+			&i.ExchangeRate,
+			&i.ExchangeRateSource,
+			&i.ExchangeRateAt,
 		); err != nil {
 			return nil, err
 		}
@@ -413,6 +1440,467 @@ func (q *Queries) ListInvoicesByCustomerID(ctx context.Context, customerID strin
 	return items, nil
 }
 
+const listInvoicesForReconciliation = `-- name: ListInvoicesForReconciliation :many
+SELECT invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at FROM invoices
+WHERE payment_method = $1
+    AND created_at >= $2::timestamptz
+    AND created_at < $3::timestamptz
+ORDER BY created_at
+`
+
+type ListInvoicesForReconciliationParams struct {
+	PaymentMethod sql.NullString `json:"payment_method"`
+	FromTime      time.Time      `json:"from_time"`
+	ToTime        time.Time      `json:"to_time"`
+}
+
+func (q *Queries) ListInvoicesForReconciliation(ctx context.Context, arg ListInvoicesForReconciliationParams) ([]Invoice, error) {
+	rows, err := q.db.QueryContext(ctx, listInvoicesForReconciliation,
+		arg.PaymentMethod,
+		arg.FromTime,
+		arg.ToTime,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Invoice{}
+	for rows.Next() {
+		var i Invoice
+		if err := rows.Scan(
+			&i.InvoiceID,
+			&i.InvoiceNumber,
+			&i.InvoiceType,
+			&i.CustomerID,
+			&i.TicketID,
+			&i.TotalAmount,
+			&i.DiscountAmount,
+			&i.TaxAmount,
+			&i.FinalAmount,
+			&i.Currency,
+			&i.PaymentStatus,
+			&i.PaymentMethod,
+			&i.IssueDate,
+			&i.Notes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.VnpayTxnRef,
+			&i.VnpayBankCode,
+			&i.VnpayTxnNo,
+			&i.VnpayPayDate,
+			&i.StripePaymentIntentID,
+			&i.StripeChargeID,
+			&i.StripeCustomerID,
+			&i.StripePaymentMethodDetails,
+			&i.BankTransferCode,
+			&i.BankAccountName,
+			&i.BankAccountNumber,
+			&i.BankName,
+			&i.BankTransactionID,
+			&i.BankPaymentDetails,
+			&i.ChargedCurrency,
+			&i.ChargedAmount,
+			&i.ExchangeRate,
+			&i.ExchangeRateSource,
+			&i.ExchangeRateAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReconciliationItemsByRunID = `-- name: ListReconciliationItemsByRunID :many
+SELECT item_id, run_id, invoice_id, reference, mismatch_type, local_status, provider_status, local_amount, provider_amount, details, resolved_by, resolution_note, resolved_at, created_at FROM reconciliation_items
+WHERE run_id = $1
+ORDER BY created_at, reference
+`
+
+func (q *Queries) ListReconciliationItemsByRunID(ctx context.Context, runID uuid.UUID) ([]ReconciliationItem, error) {
+	rows, err := q.db.QueryContext(ctx, listReconciliationItemsByRunID, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ReconciliationItem{}
+	for rows.Next() {
+		var i ReconciliationItem
+		if err := rows.Scan(
+			&i.ItemID,
+			&i.RunID,
+			&i.InvoiceID,
+			&i.Reference,
+			&i.MismatchType,
+			&i.LocalStatus,
+			&i.ProviderStatus,
+			&i.LocalAmount,
+			&i.ProviderAmount,
+			&i.Details,
+			&i.ResolvedBy,
+			&i.ResolutionNote,
+			&i.ResolvedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReconciliationRuns = `-- name: ListReconciliationRuns :many
+SELECT run_id, provider, business_date, source, status, invoice_count, provider_count, matched_count, mismatch_count, error_message, started_by, started_at, finished_at FROM reconciliation_runs
+WHERE ($1::text = '' OR provider = $1)
+    AND business_date BETWEEN $2::date AND $3::date
+ORDER BY business_date DESC, started_at DESC
+LIMIT $4 OFFSET $5
+`
+
+type ListReconciliationRunsParams struct {
+	Provider   string    `json:"provider"`
+	FromDate   time.Time `json:"from_date"`
+	ToDate     time.Time `json:"to_date"`
+	PageLimit  int32     `json:"page_limit"`
+	PageOffset int32     `json:"page_offset"`
+}
+
+func (q *Queries) ListReconciliationRuns(ctx context.Context, arg ListReconciliationRunsParams) ([]ReconciliationRun, error) {
+	rows, err := q.db.QueryContext(ctx, listReconciliationRuns,
+		arg.Provider,
+		arg.FromDate,
+		arg.ToDate,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ReconciliationRun{}
+	for rows.Next() {
+		var i ReconciliationRun
+		if err := rows.Scan(
+			&i.RunID,
+			&i.Provider,
+			&i.BusinessDate,
+			&i.Source,
+			&i.Status,
+			&i.InvoiceCount,
+			&i.ProviderCount,
+			&i.MatchedCount,
+			&i.MismatchCount,
+			&i.ErrorMessage,
+			&i.StartedBy,
+			&i.StartedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRefundsByInvoiceID = `-- name: ListRefundsByInvoiceID :many
+SELECT refund_id, invoice_id, amount, reason, initiated_by, provider, provider_reference, status, failure_reason, request_key, created_at, updated_at FROM refunds
+WHERE invoice_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) ListRefundsByInvoiceID(ctx context.Context, invoiceID uuid.UUID) ([]Refund, error) {
+	rows, err := q.db.QueryContext(ctx, listRefundsByInvoiceID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Refund{}
+	for rows.Next() {
+		var i Refund
+		if err := rows.Scan(
+			&i.RefundID,
+			&i.InvoiceID,
+			&i.Amount,
+			&i.Reason,
+			&i.InitiatedBy,
+			&i.Provider,
+			&i.ProviderReference,
+			&i.Status,
+			&i.FailureReason,
+			&i.RequestKey,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listVoucherRedemptionsByVoucherID = `-- name: ListVoucherRedemptionsByVoucherID :many
+SELECT redemption_id, voucher_id, invoice_id, customer_id, discount_amount, currency, status, release_reason, redeemed_at, released_at, created_at, updated_at FROM voucher_redemptions
+WHERE voucher_id = $1
+ORDER BY created_at DESC
+LIMIT $1
+`
+
+type ListVoucherRedemptionsByVoucherIDParams struct {
+	VoucherID uuid.UUID `json:"voucher_id"`
+	PageLimit int32     `json:"page_limit"`
+}
+
+func (q *Queries) ListVoucherRedemptionsByVoucherID(ctx context.Context, arg ListVoucherRedemptionsByVoucherIDParams) ([]VoucherRedemption, error) {
+	rows, err := q.db.QueryContext(ctx, listVoucherRedemptionsByVoucherID, arg.VoucherID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []VoucherRedemption{}
+	for rows.Next() {
+		var i VoucherRedemption
+		if err := rows.Scan(
+			&i.RedemptionID,
+			&i.VoucherID,
+			&i.InvoiceID,
+			&i.CustomerID,
+			&i.DiscountAmount,
+			&i.Currency,
+			&i.Status,
+			&i.ReleaseReason,
+			&i.RedeemedAt,
+			&i.ReleasedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listVouchers = `-- name: ListVouchers :many
+SELECT voucher_id, code, description, discount_type, discount_value, max_discount_amount, min_spend, currency, route_ids, departure_from, departure_to, valid_from, valid_until, usage_limit, per_user_limit, used_count, is_active, created_by, created_at, updated_at FROM vouchers
+WHERE (NOT $1::boolean OR is_active)
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3
+`
+
+type ListVouchersParams struct {
+	ActiveOnly bool  `json:"active_only"`
+	PageLimit  int32 `json:"page_limit"`
+	PageOffset int32 `json:"page_offset"`
+}
+
+func (q *Queries) ListVouchers(ctx context.Context, arg ListVouchersParams) ([]Voucher, error) {
+	rows, err := q.db.QueryContext(ctx, listVouchers,
+		arg.ActiveOnly,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Voucher{}
+	for rows.Next() {
+		var i Voucher
+		if err := rows.Scan(
+			&i.VoucherID,
+			&i.Code,
+			&i.Description,
+			&i.DiscountType,
+			&i.DiscountValue,
+			&i.MaxDiscountAmount,
+			&i.MinSpend,
+			&i.Currency,
+			pq.Array(&i.RouteIds),
+			&i.DepartureFrom,
+			&i.DepartureTo,
+			&i.ValidFrom,
+			&i.ValidUntil,
+			&i.UsageLimit,
+			&i.PerUserLimit,
+			&i.UsedCount,
+			&i.IsActive,
+			&i.CreatedBy,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const redeemVoucherRedemption = `-- name: RedeemVoucherRedemption :one
+UPDATE voucher_redemptions
+SET
+    status = 'REDEEMED',
+    redeemed_at = NOW(),
+    updated_at = NOW()
+WHERE invoice_id = $1 AND status = 'RESERVED'
+RETURNING redemption_id, voucher_id, invoice_id, customer_id, discount_amount, currency, status, release_reason, redeemed_at, released_at, created_at, updated_at
+`
+
+// Only a RESERVED redemption is redeemed; callbacks delivered twice are no-ops
+func (q *Queries) RedeemVoucherRedemption(ctx context.Context, invoiceID uuid.UUID) (VoucherRedemption, error) {
+	row := q.db.QueryRowContext(ctx, redeemVoucherRedemption, invoiceID)
+	var i VoucherRedemption
+	err := row.Scan(
+		&i.RedemptionID,
+		&i.VoucherID,
+		&i.InvoiceID,
+		&i.CustomerID,
+		&i.DiscountAmount,
+		&i.Currency,
+		&i.Status,
+		&i.ReleaseReason,
+		&i.RedeemedAt,
+		&i.ReleasedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const releaseVoucherRedemption = `-- name: ReleaseVoucherRedemption :one
+UPDATE voucher_redemptions
+SET
+    status = 'RELEASED',
+    release_reason = $2,
+    released_at = NOW(),
+    updated_at = NOW()
+WHERE invoice_id = $1 AND status = 'RESERVED'
+RETURNING redemption_id, voucher_id, invoice_id, customer_id, discount_amount, currency, status, release_reason, redeemed_at, released_at, created_at, updated_at
+`
+
+type ReleaseVoucherRedemptionParams struct {
+	InvoiceID     uuid.UUID `json:"invoice_id"`
+	ReleaseReason string    `json:"release_reason"`
+}
+
+func (q *Queries) ReleaseVoucherRedemption(ctx context.Context, arg ReleaseVoucherRedemptionParams) (VoucherRedemption, error) {
+	row := q.db.QueryRowContext(ctx, releaseVoucherRedemption, arg.InvoiceID, arg.ReleaseReason)
+	var i VoucherRedemption
+	err := row.Scan(
+		&i.RedemptionID,
+		&i.VoucherID,
+		&i.InvoiceID,
+		&i.CustomerID,
+		&i.DiscountAmount,
+		&i.Currency,
+		&i.Status,
+		&i.ReleaseReason,
+		&i.RedeemedAt,
+		&i.ReleasedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const resolveReconciliationItem = `-- name: ResolveReconciliationItem :one
+UPDATE reconciliation_items
+SET
+    resolved_by = $2,
+    resolution_note = $3,
+    resolved_at = NOW()
+WHERE item_id = $1 AND resolved_at IS NULL
+RETURNING item_id, run_id, invoice_id, reference, mismatch_type, local_status, provider_status, local_amount, provider_amount, details, resolved_by, resolution_note, resolved_at, created_at
+`
+
+type ResolveReconciliationItemParams struct {
+	ItemID         uuid.UUID      `json:"item_id"`
+	ResolvedBy     sql.NullString `json:"resolved_by"`
+	ResolutionNote string         `json:"resolution_note"`
+}
+
+// An item is resolved once; the first resolution note is kept
+func (q *Queries) ResolveReconciliationItem(ctx context.Context, arg ResolveReconciliationItemParams) (ReconciliationItem, error) {
+	row := q.db.QueryRowContext(ctx, resolveReconciliationItem,
+		arg.ItemID,
+		arg.ResolvedBy,
+		arg.ResolutionNote,
+	)
+	var i ReconciliationItem
+	err := row.Scan(
+		&i.ItemID,
+		&i.RunID,
+		&i.InvoiceID,
+		&i.Reference,
+		&i.MismatchType,
+		&i.LocalStatus,
+		&i.ProviderStatus,
+		&i.LocalAmount,
+		&i.ProviderAmount,
+		&i.Details,
+		&i.ResolvedBy,
+		&i.ResolutionNote,
+		&i.ResolvedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const sumRefundsByInvoiceID = `-- name: SumRefundsByInvoiceID :one
+SELECT
+    COALESCE(SUM(amount) FILTER (WHERE status = 'COMPLETED'), 0)::DECIMAL(15, 2) AS completed_amount,
+    COALESCE(SUM(amount) FILTER (WHERE status = 'PENDING'), 0)::DECIMAL(15, 2) AS pending_amount
+FROM refunds
+WHERE invoice_id = $1
+`
+
+type SumRefundsByInvoiceIDRow struct {
+	CompletedAmount float64 `json:"completed_amount"`
+	PendingAmount   float64 `json:"pending_amount"`
+}
+
+func (q *Queries) SumRefundsByInvoiceID(ctx context.Context, invoiceID uuid.UUID) (SumRefundsByInvoiceIDRow, error) {
+	row := q.db.QueryRowContext(ctx, sumRefundsByInvoiceID, invoiceID)
+	var i SumRefundsByInvoiceIDRow
+	err := row.Scan(&i.CompletedAmount, &i.PendingAmount)
+	return i, err
+}
+
 const updateInvoiceBankPaymentConfirmation = `-- name: UpdateInvoiceBankPaymentConfirmation :one
 UPDATE invoices
 SET
@@ -425,7 +1913,7 @@ SET
     notes = $8, -- Append confirmation notes
     updated_at = NOW()
 WHERE invoice_id = $1 -- Could also be bank_transfer_code
-RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details
+RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at
 `
 
 type UpdateInvoiceBankPaymentConfirmationParams struct {
@@ -483,6 +1971,11 @@ func (q *Queries) UpdateInvoiceBankPaymentConfirmation(ctx context.Context, arg
 		&i.BankName,
 		&i.BankTransactionID,
 		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
 	)
 	return i, err
 }
@@ -496,7 +1989,7 @@ SET
     notes = $5, -- Instructions for bank payment
     updated_at = NOW()
 WHERE invoice_id = $1
-RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details
+RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at
 `
 
 type UpdateInvoiceBankPaymentRequestParams struct {
@@ -548,6 +2041,82 @@ func (q *Queries) UpdateInvoiceBankPaymentRequest(ctx context.Context, arg Updat
 		&i.BankName,
 		&i.BankTransactionID,
 		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
+	)
+	return i, err
+}
+
+const updateInvoiceDiscount = `-- name: UpdateInvoiceDiscount :one
+UPDATE invoices
+SET
+    discount_amount = $2,
+    final_amount = $3,
+    notes = $4,
+    charged_amount = $5,
+    updated_at = NOW()
+WHERE invoice_id = $1
+RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at;
+
+`
+
+type UpdateInvoiceDiscountParams struct {
+	InvoiceID      uuid.UUID      `json:"invoice_id"`
+	DiscountAmount sql.NullString `json:"discount_amount"`
+	FinalAmount    float64        `json:"final_amount"`
+	Notes          string         `json:"notes"`
+	ChargedAmount  sql.NullString `json:"charged_amount"`
+}
+
+// Used when a voucher is applied to an invoice that is still awaiting payment
+func (q *Queries) UpdateInvoiceDiscount(ctx context.Context, arg UpdateInvoiceDiscountParams) (Invoice, error) {
+	row := q.db.QueryRowContext(ctx, updateInvoiceDiscount,
+		arg.InvoiceID,
+		arg.DiscountAmount,
+		arg.FinalAmount,
+		arg.Notes,
+		arg.ChargedAmount,
+	)
+	var i Invoice
+	err := row.Scan(
+		&i.InvoiceID,
+		&i.InvoiceNumber,
+		&i.InvoiceType,
+		&i.CustomerID,
+		&i.TicketID,
+		&i.TotalAmount,
+		&i.DiscountAmount,
+		&i.TaxAmount,
+		&i.FinalAmount,
+		&i.Currency,
+		&i.PaymentStatus,
+		&i.PaymentMethod,
+		&i.IssueDate,
+		&i.Notes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.VnpayTxnRef,
+		&i.VnpayBankCode,
+		&i.VnpayTxnNo,
+		&i.VnpayPayDate,
+		&i.StripePaymentIntentID,
+		&i.StripeChargeID,
+		&i.StripeCustomerID,
+		&i.StripePaymentMethodDetails,
+		&i.BankTransferCode,
+		&i.BankAccountName,
+		&i.BankAccountNumber,
+		&i.BankName,
+		&i.BankTransactionID,
+		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
 	)
 	return i, err
 }
@@ -559,7 +2128,7 @@ SET
     notes = $3, -- Reason for failure
     updated_at = NOW()
 WHERE invoice_id = $1
-RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details
+RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at
 `
 
 type UpdateInvoicePaymentFailedParams struct {
@@ -603,6 +2172,11 @@ func (q *Queries) UpdateInvoicePaymentFailed(ctx context.Context, arg UpdateInvo
 		&i.BankName,
 		&i.BankTransactionID,
 		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
 	)
 	return i, err
 }
@@ -614,7 +2188,7 @@ SET
     notes = $3, -- Notes for refund, cancellation, etc.
     updated_at = NOW()
 WHERE invoice_id = $1
-RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details
+RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at
 `
 
 type UpdateInvoiceStatusGeneralParams struct {
@@ -658,6 +2232,11 @@ func (q *Queries) UpdateInvoiceStatusGeneral(ctx context.Context, arg UpdateInvo
 		&i.BankName,
 		&i.BankTransactionID,
 		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
 	)
 	return i, err
 }
@@ -670,7 +2249,7 @@ SET
     payment_status = $4, -- 'PENDING' or 'REQUIRES_PAYMENT_METHOD'
     updated_at = NOW()
 WHERE invoice_id = $1
-RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details
+RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at
 `
 
 type UpdateInvoiceStripePaymentIntentParams struct {
@@ -720,6 +2299,11 @@ func (q *Queries) UpdateInvoiceStripePaymentIntent(ctx context.Context, arg Upda
 		&i.BankName,
 		&i.BankTransactionID,
 		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
 	)
 	return i, err
 }
@@ -732,7 +2316,7 @@ SET
     stripe_payment_method_details = $4,
     updated_at = NOW()
 WHERE stripe_payment_intent_id = $1
-RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details
+RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at
 `
 
 type UpdateInvoiceStripePaymentSuccessParams struct {
@@ -781,6 +2365,11 @@ func (q *Queries) UpdateInvoiceStripePaymentSuccess(ctx context.Context, arg Upd
 		&i.BankName,
 		&i.BankTransactionID,
 		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
 	)
 	return i, err
 }
@@ -794,7 +2383,7 @@ SET
     vnpay_pay_date = $5,
     updated_at = NOW()
 WHERE vnpay_txn_ref = $1
-RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details
+RETURNING invoice_id, invoice_number, invoice_type, customer_id, ticket_id, total_amount, discount_amount, tax_amount, final_amount, currency, payment_status, payment_method, issue_date, notes, created_at, updated_at, vnpay_txn_ref, vnpay_bank_code, vnpay_txn_no, vnpay_pay_date, stripe_payment_intent_id, stripe_charge_id, stripe_customer_id, stripe_payment_method_details, bank_transfer_code, bank_account_name, bank_account_number, bank_name, bank_transaction_id, bank_payment_details, charged_currency, charged_amount, exchange_rate, exchange_rate_source, exchange_rate_at
 `
 
 type UpdateInvoiceVNPayStatusParams struct {
@@ -845,6 +2434,11 @@ func (q *Queries) UpdateInvoiceVNPayStatus(ctx context.Context, arg UpdateInvoic
 		&i.BankName,
 		&i.BankTransactionID,
 		&i.BankPaymentDetails,
+		&i.ChargedCurrency,
+		&i.ChargedAmount,
+		&i.ExchangeRate,
+		&i.ExchangeRateSource,
+		&i.ExchangeRateAt,
 	)
 	return i, err
 }
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"payment_service/internal/db"
+	"payment_service/pkg/currency"
+)
+
+// ExchangeRateRepositoryInterface là nguồn tỷ giá đọc từ bảng exchange_rates
+type ExchangeRateRepositoryInterface interface {
+	currency.RateProvider
+}
+
+// ExchangeRateRepository đọc tỷ giá mới nhất (effective_at <= NOW()) của một cặp tiền tệ
+type ExchangeRateRepository struct {
+	dbConn *sql.DB
+	*db.Queries
+}
+
+// NewExchangeRateRepository creates a new ExchangeRateRepository
+func NewExchangeRateRepository(dbConn *sql.DB) ExchangeRateRepositoryInterface {
+	return &ExchangeRateRepository{
+		dbConn:  dbConn,
+		Queries: db.New(dbConn),
+	}
+}
+
+// LatestRate trả về tỷ giá mới nhất 1 base = rate quote, currency.ErrRateNotFound nếu chưa có
+func (r *ExchangeRateRepository) LatestRate(ctx context.Context, base, quote string) (currency.Rate, error) {
+	base, quote = currency.Normalize(base), currency.Normalize(quote)
+	row, err := r.Queries.GetLatestExchangeRate(ctx, db.GetLatestExchangeRateParams{
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return currency.Rate{}, fmt.Errorf("repository: no exchange rate for %s/%s: %w", base, quote, currency.ErrRateNotFound)
+		}
+		return currency.Rate{}, fmt.Errorf("repository: GetLatestExchangeRate failed for %s/%s: %w", base, quote, err)
+	}
+	return currency.Rate{
+		Base:   row.BaseCurrency,
+		Quote:  row.QuoteCurrency,
+		Rate:   row.Rate,
+		Source: row.Source,
+		AsOf:   row.EffectiveAt,
+	}, nil
+}
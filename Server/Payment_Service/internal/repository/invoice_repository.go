@@ -26,6 +26,19 @@ type InvoiceRepositoryInterface interface {
 	UpdateInvoiceBankPaymentConfirmation(ctx context.Context, arg db.UpdateInvoiceBankPaymentConfirmationParams) (db.Invoice, error) // New method
 	UpdateInvoicePaymentFailed(ctx context.Context, arg db.UpdateInvoicePaymentFailedParams) (db.Invoice, error)
 	UpdateInvoiceStatusGeneral(ctx context.Context, arg db.UpdateInvoiceStatusGeneralParams) (db.Invoice, error)
+
+	// Giao dịch trên các cổng thanh toán đi qua PaymentProvider chung (MoMo, ZaloPay)
+	CreateInvoiceWithPaymentTransaction(ctx context.Context, invoiceArg db.CreateInvoiceParams, txnArg db.CreatePaymentTransactionParams) (db.Invoice, error)
+	GetPaymentTransactionByTxnRef(ctx context.Context, txnRef string) (db.PaymentTransaction, error)
+	GetLatestPaymentTransactionByInvoiceID(ctx context.Context, invoiceID uuid.UUID) (db.PaymentTransaction, error)
+	CompleteProviderPayment(ctx context.Context, arg db.CompletePaymentTransactionParams, invoiceStatus sql.NullString, notes string) (db.Invoice, bool, error)
+	FailPendingPaymentTransactions(ctx context.Context, invoiceID uuid.UUID, reason string) error
+
+	// Sổ hoàn tiền: nhiều lần hoàn (một phần) trên cùng hoá đơn
+	CreateRefund(ctx context.Context, arg db.CreateRefundParams, check RefundCheck) (db.Refund, db.Invoice, bool, error)
+	CompleteRefund(ctx context.Context, arg db.CompleteRefundParams, settle RefundSettlement) (db.Refund, db.Invoice, bool, error)
+	FailRefund(ctx context.Context, refundID uuid.UUID, reason string) (db.Refund, error)
+	ListRefundsByInvoiceID(ctx context.Context, invoiceID uuid.UUID) ([]db.Refund, error)
 	GetDB() *sql.DB
 }
 
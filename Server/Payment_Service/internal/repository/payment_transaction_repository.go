@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"payment_service/internal/db"
+)
+
+// CreateInvoiceWithPaymentTransaction tạo hoá đơn và giao dịch cổng thanh toán đi kèm trong cùng một transaction.
+func (r *InvoiceRepository) CreateInvoiceWithPaymentTransaction(ctx context.Context, invoiceArg db.CreateInvoiceParams, txnArg db.CreatePaymentTransactionParams) (db.Invoice, error) {
+	if invoiceArg.InvoiceID == uuid.Nil {
+		invoiceArg.InvoiceID = uuid.New()
+	}
+	txnArg.InvoiceID = invoiceArg.InvoiceID
+
+	tx, err := r.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return db.Invoice{}, fmt.Errorf("repository: CreateInvoiceWithPaymentTransaction failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := r.Queries.WithTx(tx)
+
+	invoice, err := qtx.CreateInvoice(ctx, invoiceArg)
+	if err != nil {
+		return db.Invoice{}, fmt.Errorf("repository: CreateInvoiceWithPaymentTransaction failed to create invoice: %w", err)
+	}
+	if _, err := qtx.CreatePaymentTransaction(ctx, txnArg); err != nil {
+		return db.Invoice{}, fmt.Errorf("repository: CreateInvoiceWithPaymentTransaction failed to create transaction %s: %w", txnArg.TxnRef, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return db.Invoice{}, fmt.Errorf("repository: CreateInvoiceWithPaymentTransaction failed to commit: %w", err)
+	}
+	return invoice, nil
+}
+
+// GetPaymentTransactionByTxnRef lấy giao dịch cổng thanh toán theo mã đơn đã gửi sang cổng
+func (r *InvoiceRepository) GetPaymentTransactionByTxnRef(ctx context.Context, txnRef string) (db.PaymentTransaction, error) {
+	txn, err := r.Queries.GetPaymentTransactionByTxnRef(ctx, txnRef)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.PaymentTransaction{}, fmt.Errorf("repository: GetPaymentTransactionByTxnRef - transaction %s not found: %w", txnRef, err)
+		}
+		return db.PaymentTransaction{}, fmt.Errorf("repository: GetPaymentTransactionByTxnRef failed for %s: %w", txnRef, err)
+	}
+	return txn, nil
+}
+
+// GetLatestPaymentTransactionByInvoiceID lấy giao dịch cổng thanh toán mới nhất của hoá đơn
+func (r *InvoiceRepository) GetLatestPaymentTransactionByInvoiceID(ctx context.Context, invoiceID uuid.UUID) (db.PaymentTransaction, error) {
+	txn, err := r.Queries.GetLatestPaymentTransactionByInvoiceID(ctx, invoiceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.PaymentTransaction{}, fmt.Errorf("repository: GetLatestPaymentTransactionByInvoiceID - no transaction for invoice %s: %w", invoiceID, err)
+		}
+		return db.PaymentTransaction{}, fmt.Errorf("repository: GetLatestPaymentTransactionByInvoiceID failed for invoice %s: %w", invoiceID, err)
+	}
+	return txn, nil
+}
+
+// CompleteProviderPayment đánh dấu giao dịch cổng thanh toán và hoá đơn đã thanh toán trong một transaction.
+// applied = false khi giao dịch đã được xử lý trước đó (IPN/callback gửi lặp); khi đó hoá đơn hiện tại được trả về nguyên trạng.
+func (r *InvoiceRepository) CompleteProviderPayment(ctx context.Context, arg db.CompletePaymentTransactionParams, invoiceStatus sql.NullString, notes string) (db.Invoice, bool, error) {
+	tx, err := r.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return db.Invoice{}, false, fmt.Errorf("repository: CompleteProviderPayment failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := r.Queries.WithTx(tx)
+
+	txn, err := qtx.CompletePaymentTransaction(ctx, arg)
+	if errors.Is(err, sql.ErrNoRows) {
+		existing, getErr := qtx.GetPaymentTransactionByTxnRef(ctx, arg.TxnRef)
+		if getErr != nil {
+			return db.Invoice{}, false, fmt.Errorf("repository: CompleteProviderPayment - transaction %s not found: %w", arg.TxnRef, getErr)
+		}
+		invoice, getErr := qtx.GetInvoiceByID(ctx, existing.InvoiceID)
+		if getErr != nil {
+			return db.Invoice{}, false, fmt.Errorf("repository: CompleteProviderPayment failed to get invoice %s: %w", existing.InvoiceID, getErr)
+		}
+		return invoice, false, nil
+	}
+	if err != nil {
+		return db.Invoice{}, false, fmt.Errorf("repository: CompleteProviderPayment failed to complete transaction %s: %w", arg.TxnRef, err)
+	}
+
+	invoice, err := qtx.UpdateInvoiceStatusGeneral(ctx, db.UpdateInvoiceStatusGeneralParams{
+		InvoiceID:     txn.InvoiceID,
+		PaymentStatus: invoiceStatus,
+		Notes:         notes,
+	})
+	if err != nil {
+		return db.Invoice{}, false, fmt.Errorf("repository: CompleteProviderPayment failed to update invoice %s: %w", txn.InvoiceID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return db.Invoice{}, false, fmt.Errorf("repository: CompleteProviderPayment failed to commit: %w", err)
+	}
+	return invoice, true, nil
+}
+
+// FailPendingPaymentTransactions đóng các giao dịch cổng thanh toán còn PENDING của hoá đơn thất bại / hết hạn
+func (r *InvoiceRepository) FailPendingPaymentTransactions(ctx context.Context, invoiceID uuid.UUID, reason string) error {
+	err := r.Queries.FailPendingPaymentTransactions(ctx, db.FailPendingPaymentTransactionsParams{
+		InvoiceID:     invoiceID,
+		FailureReason: reason,
+	})
+	if err != nil {
+		return fmt.Errorf("repository: FailPendingPaymentTransactions failed for invoice %s: %w", invoiceID, err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"payment_service/internal/db"
+)
+
+// ReconciliationRepositoryInterface defines the methods for reconciliation repository
+type ReconciliationRepositoryInterface interface {
+	ListInvoicesForReconciliation(ctx context.Context, paymentMethod string, from, to time.Time) ([]db.Invoice, error)
+	GetInvoiceByVNPayTxnRef(ctx context.Context, vnpayTxnRef sql.NullString) (db.Invoice, error)
+	GetInvoiceByStripePaymentIntentID(ctx context.Context, stripePaymentIntentID sql.NullString) (db.Invoice, error)
+
+	CreateRun(ctx context.Context, arg db.CreateReconciliationRunParams) (db.ReconciliationRun, error)
+	FinishRun(ctx context.Context, arg db.FinishReconciliationRunParams, items []db.CreateReconciliationItemParams) (db.ReconciliationRun, error)
+	GetRun(ctx context.Context, runID uuid.UUID) (db.ReconciliationRun, error)
+	ListRuns(ctx context.Context, arg db.ListReconciliationRunsParams) ([]db.ReconciliationRun, int64, error)
+	ListItemsByRunID(ctx context.Context, runID uuid.UUID) ([]db.ReconciliationItem, error)
+	GetItem(ctx context.Context, itemID uuid.UUID) (db.ReconciliationItem, error)
+	ResolveItem(ctx context.Context, arg db.ResolveReconciliationItemParams) (db.ReconciliationItem, error)
+}
+
+// ReconciliationRepository lưu các lần đối soát hoá đơn với cổng thanh toán và các chênh lệch phát hiện được
+type ReconciliationRepository struct {
+	dbConn *sql.DB
+	*db.Queries
+}
+
+// NewReconciliationRepository creates a new ReconciliationRepository
+func NewReconciliationRepository(dbConn *sql.DB) ReconciliationRepositoryInterface {
+	return &ReconciliationRepository{
+		dbConn:  dbConn,
+		Queries: db.New(dbConn),
+	}
+}
+
+// ListInvoicesForReconciliation lấy các hoá đơn của phương thức thanh toán được tạo trong [from, to)
+func (r *ReconciliationRepository) ListInvoicesForReconciliation(ctx context.Context, paymentMethod string, from, to time.Time) ([]db.Invoice, error) {
+	invoices, err := r.Queries.ListInvoicesForReconciliation(ctx, db.ListInvoicesForReconciliationParams{
+		PaymentMethod: sql.NullString{String: paymentMethod, Valid: true},
+		FromTime:      from,
+		ToTime:        to,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repository: ListInvoicesForReconciliation failed for %s: %w", paymentMethod, err)
+	}
+	return invoices, nil
+}
+
+// CreateRun ghi một lần đối soát RUNNING
+func (r *ReconciliationRepository) CreateRun(ctx context.Context, arg db.CreateReconciliationRunParams) (db.ReconciliationRun, error) {
+	run, err := r.Queries.CreateReconciliationRun(ctx, arg)
+	if err != nil {
+		return db.ReconciliationRun{}, fmt.Errorf("repository: CreateRun failed for %s on %s: %w", arg.Provider, arg.BusinessDate.Format("2006-01-02"), err)
+	}
+	return run, nil
+}
+
+// FinishRun ghi kết quả và các chênh lệch của lần đối soát trong một transaction
+func (r *ReconciliationRepository) FinishRun(ctx context.Context, arg db.FinishReconciliationRunParams, items []db.CreateReconciliationItemParams) (db.ReconciliationRun, error) {
+	tx, err := r.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return db.ReconciliationRun{}, fmt.Errorf("repository: FinishRun failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := r.Queries.WithTx(tx)
+
+	for _, item := range items {
+		item.RunID = arg.RunID
+		if _, err := qtx.CreateReconciliationItem(ctx, item); err != nil {
+			return db.ReconciliationRun{}, fmt.Errorf("repository: FinishRun failed to insert item %s (%s) of run %s: %w", item.Reference, item.MismatchType, arg.RunID, err)
+		}
+	}
+	run, err := qtx.FinishReconciliationRun(ctx, arg)
+	if err != nil {
+		return db.ReconciliationRun{}, fmt.Errorf("repository: FinishRun failed to update run %s: %w", arg.RunID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return db.ReconciliationRun{}, fmt.Errorf("repository: FinishRun failed to commit: %w", err)
+	}
+	return run, nil
+}
+
+// GetRun lấy một lần đối soát theo ID
+func (r *ReconciliationRepository) GetRun(ctx context.Context, runID uuid.UUID) (db.ReconciliationRun, error) {
+	run, err := r.Queries.GetReconciliationRun(ctx, runID)
+	if err != nil {
+		return db.ReconciliationRun{}, fmt.Errorf("repository: GetRun failed for run %s: %w", runID, err)
+	}
+	return run, nil
+}
+
+// ListRuns lấy một trang các lần đối soát cùng tổng số bản ghi thoả điều kiện lọc
+func (r *ReconciliationRepository) ListRuns(ctx context.Context, arg db.ListReconciliationRunsParams) ([]db.ReconciliationRun, int64, error) {
+	runs, err := r.Queries.ListReconciliationRuns(ctx, arg)
+	if err != nil {
+		return nil, 0, fmt.Errorf("repository: ListRuns failed: %w", err)
+	}
+	total, err := r.Queries.CountReconciliationRuns(ctx, db.CountReconciliationRunsParams{
+		Provider: arg.Provider,
+		FromDate: arg.FromDate,
+		ToDate:   arg.ToDate,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("repository: ListRuns failed to count runs: %w", err)
+	}
+	return runs, total, nil
+}
+
+// ListItemsByRunID lấy các chênh lệch của một lần đối soát
+func (r *ReconciliationRepository) ListItemsByRunID(ctx context.Context, runID uuid.UUID) ([]db.ReconciliationItem, error) {
+	items, err := r.Queries.ListReconciliationItemsByRunID(ctx, runID)
+	if err != nil {
+		return nil, fmt.Errorf("repository: ListItemsByRunID failed for run %s: %w", runID, err)
+	}
+	return items, nil
+}
+
+// GetItem lấy một chênh lệch theo ID
+func (r *ReconciliationRepository) GetItem(ctx context.Context, itemID uuid.UUID) (db.ReconciliationItem, error) {
+	item, err := r.Queries.GetReconciliationItem(ctx, itemID)
+	if err != nil {
+		return db.ReconciliationItem{}, fmt.Errorf("repository: GetItem failed for item %s: %w", itemID, err)
+	}
+	return item, nil
+}
+
+// ResolveItem đánh dấu chênh lệch đã xử lý; sql.ErrNoRows khi chênh lệch không tồn tại hoặc đã được xử lý
+func (r *ReconciliationRepository) ResolveItem(ctx context.Context, arg db.ResolveReconciliationItemParams) (db.ReconciliationItem, error) {
+	item, err := r.Queries.ResolveReconciliationItem(ctx, arg)
+	if err != nil {
+		return db.ReconciliationItem{}, fmt.Errorf("repository: ResolveItem failed for item %s: %w", arg.ItemID, err)
+	}
+	return item, nil
+}
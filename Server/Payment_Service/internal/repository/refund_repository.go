@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"payment_service/internal/db"
+)
+
+// RefundCheck kiểm tra hoá đơn (đã khoá FOR UPDATE) và tổng tiền đã / đang hoàn trước khi ghi thêm một lần hoàn,
+// trả về số tiền sẽ ghi cho lần hoàn này.
+type RefundCheck func(invoice db.Invoice, refunded db.SumRefundsByInvoiceIDRow) (float64, error)
+
+// RefundSettlement trả về trạng thái và ghi chú mới của hoá đơn sau khi một lần hoàn đã hoàn tất.
+type RefundSettlement func(refund db.Refund, invoice db.Invoice, refunded db.SumRefundsByInvoiceIDRow) db.UpdateInvoiceStatusGeneralParams
+
+// CreateRefund ghi một lần hoàn PENDING cho hoá đơn. Hoá đơn được khoá trong transaction để các lần hoàn
+// đồng thời không vượt quá final_amount; check quyết định lần hoàn có hợp lệ không và số tiền được hoàn.
+// Provider của lần hoàn là phương thức thanh toán của hoá đơn.
+// Nếu arg.RequestKey đã tồn tại, lần hoàn cũ được trả về cùng existing = true.
+func (r *InvoiceRepository) CreateRefund(ctx context.Context, arg db.CreateRefundParams, check RefundCheck) (db.Refund, db.Invoice, bool, error) {
+	if arg.RefundID == uuid.Nil {
+		arg.RefundID = uuid.New()
+	}
+
+	tx, err := r.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CreateRefund failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := r.Queries.WithTx(tx)
+
+	invoice, err := qtx.GetInvoiceByIDForUpdate(ctx, arg.InvoiceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CreateRefund - invoice %s not found: %w", arg.InvoiceID, err)
+		}
+		return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CreateRefund failed to lock invoice %s: %w", arg.InvoiceID, err)
+	}
+
+	if arg.RequestKey.Valid {
+		existing, err := qtx.GetRefundByRequestKey(ctx, arg.RequestKey)
+		if err == nil {
+			return existing, invoice, true, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CreateRefund failed to look up request key %s: %w", arg.RequestKey.String, err)
+		}
+	}
+
+	refunded, err := qtx.SumRefundsByInvoiceID(ctx, arg.InvoiceID)
+	if err != nil {
+		return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CreateRefund failed to sum refunds of invoice %s: %w", arg.InvoiceID, err)
+	}
+	amount, err := check(invoice, refunded)
+	if err != nil {
+		return db.Refund{}, invoice, false, err
+	}
+	arg.Amount = amount
+	arg.Provider = invoice.PaymentMethod.String
+
+	refund, err := qtx.CreateRefund(ctx, arg)
+	if err != nil {
+		return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CreateRefund failed to insert refund for invoice %s: %w", arg.InvoiceID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CreateRefund failed to commit: %w", err)
+	}
+	return refund, invoice, false, nil
+}
+
+// CompleteRefund đánh dấu lần hoàn COMPLETED và cập nhật hoá đơn theo settle trong một transaction.
+// applied = false khi lần hoàn không còn PENDING; khi đó lần hoàn và hoá đơn hiện tại được trả về nguyên trạng.
+func (r *InvoiceRepository) CompleteRefund(ctx context.Context, arg db.CompleteRefundParams, settle RefundSettlement) (db.Refund, db.Invoice, bool, error) {
+	tx, err := r.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CompleteRefund failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := r.Queries.WithTx(tx)
+
+	refund, err := qtx.CompleteRefund(ctx, arg)
+	if errors.Is(err, sql.ErrNoRows) {
+		existing, getErr := qtx.GetRefundByID(ctx, arg.RefundID)
+		if getErr != nil {
+			return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CompleteRefund - refund %s not found: %w", arg.RefundID, getErr)
+		}
+		invoice, getErr := qtx.GetInvoiceByID(ctx, existing.InvoiceID)
+		if getErr != nil {
+			return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CompleteRefund failed to get invoice %s: %w", existing.InvoiceID, getErr)
+		}
+		return existing, invoice, false, nil
+	}
+	if err != nil {
+		return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CompleteRefund failed to complete refund %s: %w", arg.RefundID, err)
+	}
+
+	invoice, err := qtx.GetInvoiceByIDForUpdate(ctx, refund.InvoiceID)
+	if err != nil {
+		return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CompleteRefund failed to lock invoice %s: %w", refund.InvoiceID, err)
+	}
+	refunded, err := qtx.SumRefundsByInvoiceID(ctx, refund.InvoiceID)
+	if err != nil {
+		return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CompleteRefund failed to sum refunds of invoice %s: %w", refund.InvoiceID, err)
+	}
+
+	params := settle(refund, invoice, refunded)
+	params.InvoiceID = invoice.InvoiceID
+	updatedInvoice, err := qtx.UpdateInvoiceStatusGeneral(ctx, params)
+	if err != nil {
+		return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CompleteRefund failed to update invoice %s: %w", invoice.InvoiceID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return db.Refund{}, db.Invoice{}, false, fmt.Errorf("repository: CompleteRefund failed to commit: %w", err)
+	}
+	return refund, updatedInvoice, true, nil
+}
+
+// FailRefund đánh dấu lần hoàn PENDING là FAILED (cổng từ chối hoặc lỗi khi gọi cổng) và giải phóng request key để có thể thử lại
+func (r *InvoiceRepository) FailRefund(ctx context.Context, refundID uuid.UUID, reason string) (db.Refund, error) {
+	refund, err := r.Queries.FailRefund(ctx, db.FailRefundParams{
+		RefundID:      refundID,
+		FailureReason: reason,
+	})
+	if err != nil {
+		return db.Refund{}, fmt.Errorf("repository: FailRefund failed for refund %s: %w", refundID, err)
+	}
+	return refund, nil
+}
+
+// ListRefundsByInvoiceID lấy các lần hoàn của hoá đơn theo thứ tự thời gian
+func (r *InvoiceRepository) ListRefundsByInvoiceID(ctx context.Context, invoiceID uuid.UUID) ([]db.Refund, error) {
+	refunds, err := r.Queries.ListRefundsByInvoiceID(ctx, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("repository: ListRefundsByInvoiceID failed for invoice %s: %w", invoiceID, err)
+	}
+	return refunds, nil
+}
@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"payment_service/internal/db"
+)
+
+// VoucherCheck kiểm tra voucher (đã khoá FOR UPDATE) cùng số lượt khách đang giữ / đã dùng,
+// trả về số tiền được giảm của lượt dùng sắp ghi.
+type VoucherCheck func(voucher db.Voucher, customerUses int64) (float64, error)
+
+// VoucherInvoiceCheck giống VoucherCheck nhưng cho hoá đơn đã có (đã khoá FOR UPDATE),
+// trả về số tiền được giảm cùng giá trị mới của hoá đơn.
+type VoucherInvoiceCheck func(voucher db.Voucher, invoice db.Invoice, customerUses int64) (float64, db.UpdateInvoiceDiscountParams, error)
+
+// VoucherRepositoryInterface defines the methods for voucher repository
+type VoucherRepositoryInterface interface {
+	CreateVoucher(ctx context.Context, arg db.CreateVoucherParams) (db.Voucher, error)
+	GetVoucherByID(ctx context.Context, voucherID uuid.UUID) (db.Voucher, error)
+	GetVoucherByCode(ctx context.Context, code string) (db.Voucher, error)
+	ListVouchers(ctx context.Context, arg db.ListVouchersParams) ([]db.Voucher, int64, error)
+	DeactivateVoucher(ctx context.Context, voucherID uuid.UUID) (db.Voucher, error)
+	CountCustomerRedemptions(ctx context.Context, voucherID uuid.UUID, customerID string) (int64, error)
+	ListRedemptionsByVoucherID(ctx context.Context, voucherID uuid.UUID, limit int32) ([]db.VoucherRedemption, error)
+	GetActiveRedemptionByInvoiceID(ctx context.Context, invoiceID uuid.UUID) (db.VoucherRedemption, error)
+
+	// Vòng đời một lượt dùng: RESERVED khi tạo hoá đơn -> REDEEMED khi thanh toán / RELEASED khi hoá đơn thất bại
+	ReserveRedemption(ctx context.Context, code string, arg db.CreateVoucherRedemptionParams, check VoucherCheck) (db.VoucherRedemption, db.Voucher, bool, error)
+	ApplyToInvoice(ctx context.Context, code string, invoiceID uuid.UUID, check VoucherInvoiceCheck) (db.VoucherRedemption, db.Invoice, error)
+	RedeemRedemption(ctx context.Context, invoiceID uuid.UUID) (db.VoucherRedemption, bool, error)
+	ReleaseRedemption(ctx context.Context, invoiceID uuid.UUID, reason string) (db.VoucherRedemption, bool, error)
+}
+
+// VoucherRepository lưu các voucher và lượt dùng voucher của hoá đơn
+type VoucherRepository struct {
+	dbConn *sql.DB
+	*db.Queries
+}
+
+// NewVoucherRepository creates a new VoucherRepository
+func NewVoucherRepository(dbConn *sql.DB) VoucherRepositoryInterface {
+	return &VoucherRepository{
+		dbConn:  dbConn,
+		Queries: db.New(dbConn),
+	}
+}
+
+// CreateVoucher ghi một voucher mới
+func (r *VoucherRepository) CreateVoucher(ctx context.Context, arg db.CreateVoucherParams) (db.Voucher, error) {
+	voucher, err := r.Queries.CreateVoucher(ctx, arg)
+	if err != nil {
+		return db.Voucher{}, fmt.Errorf("repository: CreateVoucher failed for %s: %w", arg.Code, err)
+	}
+	return voucher, nil
+}
+
+// GetVoucherByID lấy voucher theo ID
+func (r *VoucherRepository) GetVoucherByID(ctx context.Context, voucherID uuid.UUID) (db.Voucher, error) {
+	voucher, err := r.Queries.GetVoucherByID(ctx, voucherID)
+	if err != nil {
+		return db.Voucher{}, fmt.Errorf("repository: GetVoucherByID failed for %s: %w", voucherID, err)
+	}
+	return voucher, nil
+}
+
+// GetVoucherByCode lấy voucher theo mã (chữ hoa)
+func (r *VoucherRepository) GetVoucherByCode(ctx context.Context, code string) (db.Voucher, error) {
+	voucher, err := r.Queries.GetVoucherByCode(ctx, code)
+	if err != nil {
+		return db.Voucher{}, fmt.Errorf("repository: GetVoucherByCode failed for %s: %w", code, err)
+	}
+	return voucher, nil
+}
+
+// ListVouchers lấy một trang voucher cùng tổng số voucher thoả điều kiện lọc
+func (r *VoucherRepository) ListVouchers(ctx context.Context, arg db.ListVouchersParams) ([]db.Voucher, int64, error) {
+	vouchers, err := r.Queries.ListVouchers(ctx, arg)
+	if err != nil {
+		return nil, 0, fmt.Errorf("repository: ListVouchers failed: %w", err)
+	}
+	total, err := r.Queries.CountVouchers(ctx, arg.ActiveOnly)
+	if err != nil {
+		return nil, 0, fmt.Errorf("repository: ListVouchers failed to count vouchers: %w", err)
+	}
+	return vouchers, total, nil
+}
+
+// DeactivateVoucher ngừng voucher; các lượt đang giữ vẫn được dùng tiếp cho hoá đơn đã tạo
+func (r *VoucherRepository) DeactivateVoucher(ctx context.Context, voucherID uuid.UUID) (db.Voucher, error) {
+	voucher, err := r.Queries.DeactivateVoucher(ctx, voucherID)
+	if err != nil {
+		return db.Voucher{}, fmt.Errorf("repository: DeactivateVoucher failed for %s: %w", voucherID, err)
+	}
+	return voucher, nil
+}
+
+// CountCustomerRedemptions đếm số lượt khách đang giữ hoặc đã dùng voucher
+func (r *VoucherRepository) CountCustomerRedemptions(ctx context.Context, voucherID uuid.UUID, customerID string) (int64, error) {
+	count, err := r.Queries.CountActiveVoucherRedemptionsByCustomer(ctx, db.CountActiveVoucherRedemptionsByCustomerParams{
+		VoucherID:  voucherID,
+		CustomerID: customerID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("repository: CountCustomerRedemptions failed for voucher %s: %w", voucherID, err)
+	}
+	return count, nil
+}
+
+// ListRedemptionsByVoucherID lấy các lượt dùng gần nhất của voucher
+func (r *VoucherRepository) ListRedemptionsByVoucherID(ctx context.Context, voucherID uuid.UUID, limit int32) ([]db.VoucherRedemption, error) {
+	redemptions, err := r.Queries.ListVoucherRedemptionsByVoucherID(ctx, db.ListVoucherRedemptionsByVoucherIDParams{
+		VoucherID: voucherID,
+		PageLimit: limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repository: ListRedemptionsByVoucherID failed for voucher %s: %w", voucherID, err)
+	}
+	return redemptions, nil
+}
+
+// GetActiveRedemptionByInvoiceID lấy lượt dùng voucher chưa bị trả lại của hoá đơn
+func (r *VoucherRepository) GetActiveRedemptionByInvoiceID(ctx context.Context, invoiceID uuid.UUID) (db.VoucherRedemption, error) {
+	redemption, err := r.Queries.GetActiveVoucherRedemptionByInvoiceID(ctx, invoiceID)
+	if err != nil {
+		return db.VoucherRedemption{}, fmt.Errorf("repository: GetActiveRedemptionByInvoiceID failed for invoice %s: %w", invoiceID, err)
+	}
+	return redemption, nil
+}
+
+// ReserveRedemption giữ một lượt dùng voucher cho hoá đơn arg.InvoiceID. Voucher được khoá trong transaction để
+// các hoá đơn đồng thời không vượt usage_limit / per_user_limit; check quyết định voucher có áp dụng được không
+// và số tiền được giảm. Nếu hoá đơn đã giữ một lượt, lượt cũ được trả về cùng existing = true.
+func (r *VoucherRepository) ReserveRedemption(ctx context.Context, code string, arg db.CreateVoucherRedemptionParams, check VoucherCheck) (db.VoucherRedemption, db.Voucher, bool, error) {
+	tx, err := r.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return db.VoucherRedemption{}, db.Voucher{}, false, fmt.Errorf("repository: ReserveRedemption failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := r.Queries.WithTx(tx)
+
+	existing, err := qtx.GetActiveVoucherRedemptionByInvoiceID(ctx, arg.InvoiceID)
+	if err == nil {
+		voucher, err := qtx.GetVoucherByID(ctx, existing.VoucherID)
+		if err != nil {
+			return db.VoucherRedemption{}, db.Voucher{}, false, fmt.Errorf("repository: ReserveRedemption failed to load voucher %s: %w", existing.VoucherID, err)
+		}
+		return existing, voucher, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return db.VoucherRedemption{}, db.Voucher{}, false, fmt.Errorf("repository: ReserveRedemption failed to look up redemption of invoice %s: %w", arg.InvoiceID, err)
+	}
+
+	voucher, redemption, err := r.reserve(ctx, qtx, code, arg, check)
+	if err != nil {
+		return db.VoucherRedemption{}, voucher, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return db.VoucherRedemption{}, db.Voucher{}, false, fmt.Errorf("repository: ReserveRedemption failed to commit: %w", err)
+	}
+	return redemption, voucher, false, nil
+}
+
+// ApplyToInvoice áp voucher vào hoá đơn đã có: giữ một lượt dùng và cập nhật discount_amount / final_amount
+// của hoá đơn trong cùng transaction.
+func (r *VoucherRepository) ApplyToInvoice(ctx context.Context, code string, invoiceID uuid.UUID, check VoucherInvoiceCheck) (db.VoucherRedemption, db.Invoice, error) {
+	tx, err := r.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return db.VoucherRedemption{}, db.Invoice{}, fmt.Errorf("repository: ApplyToInvoice failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := r.Queries.WithTx(tx)
+
+	invoice, err := qtx.GetInvoiceByIDForUpdate(ctx, invoiceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.VoucherRedemption{}, db.Invoice{}, fmt.Errorf("repository: ApplyToInvoice - invoice %s not found: %w", invoiceID, err)
+		}
+		return db.VoucherRedemption{}, db.Invoice{}, fmt.Errorf("repository: ApplyToInvoice failed to lock invoice %s: %w", invoiceID, err)
+	}
+
+	var invoiceArg db.UpdateInvoiceDiscountParams
+	arg := db.CreateVoucherRedemptionParams{
+		InvoiceID:  invoice.InvoiceID,
+		CustomerID: invoice.CustomerID,
+		Currency:   invoice.Currency.String,
+	}
+	_, redemption, err := r.reserve(ctx, qtx, code, arg, func(voucher db.Voucher, customerUses int64) (float64, error) {
+		amount, update, err := check(voucher, invoice, customerUses)
+		invoiceArg = update
+		return amount, err
+	})
+	if err != nil {
+		return db.VoucherRedemption{}, invoice, err
+	}
+
+	invoiceArg.InvoiceID = invoice.InvoiceID
+	updatedInvoice, err := qtx.UpdateInvoiceDiscount(ctx, invoiceArg)
+	if err != nil {
+		return db.VoucherRedemption{}, db.Invoice{}, fmt.Errorf("repository: ApplyToInvoice failed to update invoice %s: %w", invoiceID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return db.VoucherRedemption{}, db.Invoice{}, fmt.Errorf("repository: ApplyToInvoice failed to commit: %w", err)
+	}
+	return redemption, updatedInvoice, nil
+}
+
+// reserve khoá voucher, gọi check và ghi lượt dùng RESERVED trong transaction qtx
+func (r *VoucherRepository) reserve(ctx context.Context, qtx *db.Queries, code string, arg db.CreateVoucherRedemptionParams, check VoucherCheck) (db.Voucher, db.VoucherRedemption, error) {
+	voucher, err := qtx.GetVoucherByCodeForUpdate(ctx, code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.Voucher{}, db.VoucherRedemption{}, fmt.Errorf("repository: voucher %s not found: %w", code, err)
+		}
+		return db.Voucher{}, db.VoucherRedemption{}, fmt.Errorf("repository: failed to lock voucher %s: %w", code, err)
+	}
+
+	customerUses, err := qtx.CountActiveVoucherRedemptionsByCustomer(ctx, db.CountActiveVoucherRedemptionsByCustomerParams{
+		VoucherID:  voucher.VoucherID,
+		CustomerID: arg.CustomerID,
+	})
+	if err != nil {
+		return voucher, db.VoucherRedemption{}, fmt.Errorf("repository: failed to count redemptions of voucher %s: %w", code, err)
+	}
+	amount, err := check(voucher, customerUses)
+	if err != nil {
+		return voucher, db.VoucherRedemption{}, err
+	}
+	arg.VoucherID = voucher.VoucherID
+	arg.DiscountAmount = amount
+
+	redemption, err := qtx.CreateVoucherRedemption(ctx, arg)
+	if err != nil {
+		return voucher, db.VoucherRedemption{}, fmt.Errorf("repository: failed to reserve voucher %s for invoice %s: %w", code, arg.InvoiceID, err)
+	}
+	if err := qtx.IncrementVoucherUsedCount(ctx, voucher.VoucherID); err != nil {
+		return voucher, db.VoucherRedemption{}, fmt.Errorf("repository: failed to increment usage of voucher %s: %w", code, err)
+	}
+	return voucher, redemption, nil
+}
+
+// RedeemRedemption chuyển lượt dùng RESERVED của hoá đơn sang REDEEMED.
+// applied = false khi hoá đơn không có lượt đang giữ (không dùng voucher hoặc đã redeem trước đó).
+func (r *VoucherRepository) RedeemRedemption(ctx context.Context, invoiceID uuid.UUID) (db.VoucherRedemption, bool, error) {
+	redemption, err := r.Queries.RedeemVoucherRedemption(ctx, invoiceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.VoucherRedemption{}, false, nil
+	}
+	if err != nil {
+		return db.VoucherRedemption{}, false, fmt.Errorf("repository: RedeemRedemption failed for invoice %s: %w", invoiceID, err)
+	}
+	return redemption, true, nil
+}
+
+// ReleaseRedemption trả lại lượt dùng RESERVED của hoá đơn và giảm used_count của voucher trong một transaction.
+// applied = false khi hoá đơn không có lượt đang giữ.
+func (r *VoucherRepository) ReleaseRedemption(ctx context.Context, invoiceID uuid.UUID, reason string) (db.VoucherRedemption, bool, error) {
+	tx, err := r.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return db.VoucherRedemption{}, false, fmt.Errorf("repository: ReleaseRedemption failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := r.Queries.WithTx(tx)
+
+	redemption, err := qtx.ReleaseVoucherRedemption(ctx, db.ReleaseVoucherRedemptionParams{
+		InvoiceID:     invoiceID,
+		ReleaseReason: reason,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.VoucherRedemption{}, false, nil
+	}
+	if err != nil {
+		return db.VoucherRedemption{}, false, fmt.Errorf("repository: ReleaseRedemption failed for invoice %s: %w", invoiceID, err)
+	}
+	if err := qtx.DecrementVoucherUsedCount(ctx, redemption.VoucherID); err != nil {
+		return db.VoucherRedemption{}, false, fmt.Errorf("repository: ReleaseRedemption failed to decrement usage of voucher %s: %w", redemption.VoucherID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return db.VoucherRedemption{}, false, fmt.Errorf("repository: ReleaseRedemption failed to commit: %w", err)
+	}
+	return redemption, true, nil
+}
@@ -10,6 +10,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,6 +18,7 @@ import (
 	"payment_service/domain/model"        // Your domain models
 	"payment_service/internal/db"         // SQLC generated
 	"payment_service/internal/repository" // Your repository interface
+	"payment_service/pkg/currency"
 	// "payment_service/pkg/utils" // Your utility functions
 )
 
@@ -25,13 +27,14 @@ type BankServiceInterface interface {
 	CreateBankPaymentRequest(ctx context.Context, req model.InitialBankPaymentRequest) (*model.BankPaymentDetailsResponse, error)
 	ConfirmBankPayment(ctx context.Context, req model.BankPaymentConfirmationRequest) (db.Invoice, error)
 	HandleBankPaymentFailed(ctx context.Context, invoiceID uuid.UUID, reason string) (db.Invoice, error)
-	// Add RefundBankPayment if needed
+	CreditRefund(ctx context.Context, invoice db.Invoice, req model.ProviderRefundRequest) (string, error)
 }
 
 // BankService handles bank payment logic.
 type BankService struct {
 	invoiceRepo           repository.InvoiceRepositoryInterface
 	invoiceService        InvoiceServiceInterface // To use common invoice logic if any
+	voucherService        VoucherServiceInterface // Voucher khách chọn khi tạo thanh toán
 	accountServiceBaseURL string                  // Base URL for the external Account Service
 	httpClient            *http.Client            // HTTP client for external calls
 	// Add any other dependencies, like config for bank details to display
@@ -41,12 +44,14 @@ type BankService struct {
 func NewBankService(
 	invoiceRepo repository.InvoiceRepositoryInterface,
 	invoiceService InvoiceServiceInterface,
+	voucherService VoucherServiceInterface,
 	accountServiceBaseURL string,
 	httpClient *http.Client,
 ) BankServiceInterface {
 	return &BankService{
 		invoiceRepo:           invoiceRepo,
 		invoiceService:        invoiceService,
+		voucherService:        voucherService,
 		accountServiceBaseURL: accountServiceBaseURL,
 		httpClient:            httpClient,
 	}
@@ -64,33 +69,9 @@ type AccountServiceAccountResponse struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// convertToSmallestUnit converts a float amount in a major currency unit
-// to an int64 amount in the smallest currency unit (e.g., cents).
-func convertToSmallestUnit(amount float64, currency string) (int64, error) {
-	var multiplier float64
-	// This should be expanded based on all supported currencies and their decimal places.
-	// The currency codes should ideally come from a shared constant or configuration.
-	switch currency {
-	case "USD", "EUR": // Currencies with 2 decimal places
-		multiplier = 100.0
-	case "VND", "JPY": // Currencies with 0 decimal places
-		multiplier = 1.0
-	default:
-		return 0, fmt.Errorf("currency %s is not configured for smallest unit conversion or is unsupported", currency)
-	}
-
-	if amount < 0 {
-		return 0, fmt.Errorf("amount cannot be negative")
-	}
-
-	// Basic check for potential overflow, though a more robust solution might be needed for very large amounts.
-	// Max float64 can be much larger than max int64 after multiplication.
-	// This is a simplified check.
-	if (amount * multiplier) > float64(^uint64(0)>>1) { // Approx check against MaxInt64
-		return 0, fmt.Errorf("amount %f %s is too large to convert to smallest unit", amount, currency)
-	}
-
-	return int64(amount * multiplier), nil
+// accountCurrencyCode trả mã tiền tệ theo định dạng của AccountService (chữ hoa, VD "VND")
+func accountCurrencyCode(code string) string {
+	return strings.ToUpper(currency.Normalize(code))
 }
 
 // getAccountDetails fetches account details from the external Account Service.
@@ -173,29 +154,52 @@ func (s *BankService) CreateBankPaymentRequest(ctx context.Context, req model.In
 		return nil, fmt.Errorf("payer account is not active (status: %s)", accountDetails.Status)
 	}
 
-	// Currency check
-	if accountDetails.Currency != req.Currency {
-		log.Printf("Payer account %d currency mismatch. Account: %s, Request: %s", accountID, accountDetails.Currency, req.Currency)
-		return nil, fmt.Errorf("payer account currency (%s) does not match payment currency (%s)", accountDetails.Currency, req.Currency)
+	// Voucher: số dư chỉ cần đủ cho số tiền sau giảm giá. Lượt dùng được giữ khi tạo hoá đơn bên dưới.
+	payableAmount := req.Amount
+	if req.VoucherCode != "" {
+		quote, err := s.voucherService.Validate(ctx, model.ValidateVoucherRequest{
+			Code:          req.VoucherCode,
+			CustomerID:    req.CustomerID,
+			Amount:        req.Amount,
+			Currency:      req.Currency,
+			RouteID:       req.RouteID,
+			DepartureDate: req.DepartureDate,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply voucher %s: %w", req.VoucherCode, err)
+		}
+		payableAmount = quote.FinalAmount
+	}
+
+	// Currency: tài khoản khác tiền tệ giá vé thì số tiền bị trừ được quy đổi theo tỷ giá hiện tại,
+	// tỷ giá được snapshot lên hoá đơn khi tạo bên dưới.
+	requiredAmount := payableAmount
+	if !currency.Equal(accountDetails.Currency, req.Currency) {
+		conversion, err := s.invoiceService.ConvertAmount(ctx, payableAmount, req.Currency, accountDetails.Currency)
+		if err != nil {
+			log.Printf("Currency conversion error for account %d, amount %.2f %s to %s: %v", accountID, payableAmount, req.Currency, accountDetails.Currency, err)
+			return nil, fmt.Errorf("payment currency processing error: %w", err)
+		}
+		requiredAmount = conversion.ConvertedAmount
 	}
 
 	// Balance check
-	// Convert requested amount (float64 in major unit) to smallest unit (int64) for comparison.
-	requiredAmountSmallestUnit, err := convertToSmallestUnit(req.Amount, req.Currency)
+	// Convert required amount (float64 in major unit) to smallest unit (int64) for comparison.
+	requiredAmountSmallestUnit, err := currency.ToMinorUnits(requiredAmount, accountDetails.Currency)
 	if err != nil {
-		log.Printf("Currency conversion error for account %d, amount %.2f %s: %v", accountID, req.Amount, req.Currency, err)
+		log.Printf("Currency conversion error for account %d, amount %.2f %s: %v", accountID, requiredAmount, accountDetails.Currency, err)
 		return nil, fmt.Errorf("payment currency processing error: %w", err)
 	}
 
 	if accountDetails.Balance < requiredAmountSmallestUnit {
 		log.Printf("Insufficient balance for payer account %d. Available: %d %s, Required: %d %s",
-			accountID, accountDetails.Balance, accountDetails.Currency, requiredAmountSmallestUnit, req.Currency)
+			accountID, accountDetails.Balance, accountDetails.Currency, requiredAmountSmallestUnit, accountDetails.Currency)
 		return nil, fmt.Errorf("insufficient funds in payer account (available: %d %s, required: %d %s in smallest unit)",
-			accountDetails.Balance, accountDetails.Currency, requiredAmountSmallestUnit, req.Currency)
+			accountDetails.Balance, accountDetails.Currency, requiredAmountSmallestUnit, accountDetails.Currency)
 	}
 
-	log.Printf("Account %d validated successfully for payment. Balance: %d %s, Requested: %.2f %s",
-		accountID, accountDetails.Balance, accountDetails.Currency, req.Amount, req.Currency)
+	log.Printf("Account %d validated successfully for payment. Balance: %d %s, Requested: %.2f %s (%.2f %s)",
+		accountID, accountDetails.Balance, accountDetails.Currency, payableAmount, req.Currency, requiredAmount, accountDetails.Currency)
 
 	// 3. If all checks passed, proceed to create the invoice.
 	invoiceID := uuid.New()
@@ -210,7 +214,7 @@ func (s *BankService) CreateBankPaymentRequest(ctx context.Context, req model.In
 		TicketID:         req.TicketID,
 		TotalAmount:      req.Amount,
 		FinalAmount:      req.Amount,
-		Currency:         sql.NullString{String: req.Currency, Valid: req.Currency != ""},
+		Currency:         sql.NullString{String: currency.Normalize(req.Currency), Valid: req.Currency != ""},
 		PaymentMethod:    sql.NullString{String: string(model.PaymentMethodBank), Valid: true},
 		PaymentStatus:    sql.NullString{String: string(model.PaymentStatusAwaitingConfirmation), Valid: true},
 		IssueDate:        sql.NullTime{Time: time.Now(), Valid: true},
@@ -221,17 +225,21 @@ func (s *BankService) CreateBankPaymentRequest(ctx context.Context, req model.In
 		// PayerAccountID: sql.NullInt64{Int64: accountID, Valid: true},
 	}
 
-	dbInvoice, err := s.invoiceRepo.CreateInvoice(ctx, createParams)
+	dbInvoice, err := s.invoiceService.CreateInvoiceWithVoucher(ctx, createParams, req.VoucherSelection, accountDetails.Currency)
 	if err != nil {
 		log.Printf("Error creating invoice for bank payment after account check: %v", err)
 		return nil, fmt.Errorf("failed to create invoice for bank payment: %w", err)
 	}
 
+	chargedAmount, chargedCurrency := invoiceChargedAmount(dbInvoice)
 	paymentInstructions := model.BankPaymentDetailsResponse{
 		InvoiceID:                    dbInvoice.InvoiceID,
 		BankTransferCode:             bankTransferCode,
 		PayableAmount:                dbInvoice.FinalAmount,
 		Currency:                     dbInvoice.Currency.String,
+		ChargedAmount:                chargedAmount,
+		ChargedCurrency:              chargedCurrency,
+		ExchangeRate:                 decimalStringToFloat(dbInvoice.ExchangeRate),
 		OurBankAccountName:           "YOUR COMPANY NAME",        // From config
 		OurBankAccountNumber:         "YOUR BANK ACCOUNT NUMBER", // From config
 		OurBankName:                  "YOUR BANK NAME",           // From config
@@ -270,19 +278,20 @@ func (s *BankService) ConfirmBankPayment(ctx context.Context, req model.BankPaym
 		log.Printf("Invoice %s cannot be confirmed. Status: %s", existingInvoice.InvoiceID, existingInvoice.PaymentStatus.String)
 		return db.Invoice{}, fmt.Errorf("invoice %s is not awaiting confirmation (status: %s)", existingInvoice.InvoiceID, existingInvoice.PaymentStatus.String)
 	}
-	// 3b. Convert invoice amount to the smallest unit for the payment service.
-	amountToDebit, err := convertToSmallestUnit(existingInvoice.FinalAmount, existingInvoice.Currency.String)
+	// 3b. Convert the charged amount (account currency, theo tỷ giá đã snapshot) to the smallest unit for the payment service.
+	chargedAmount, chargedCurrency := invoiceChargedAmount(existingInvoice)
+	amountToDebit, err := currency.ToMinorUnits(chargedAmount, chargedCurrency)
 	if err != nil {
 		log.Printf("Currency conversion error for invoice %s. Amount: %.2f %s. Error: %v. Marking as failed.",
-			existingInvoice.InvoiceID, existingInvoice.FinalAmount, existingInvoice.Currency.String, err)
+			existingInvoice.InvoiceID, chargedAmount, chargedCurrency, err)
 		return s.HandleBankPaymentFailed(ctx, existingInvoice.InvoiceID, "Internal error during currency conversion.")
 	}
 
 	// 3c. Call the Account Service to make the payment.
-	log.Printf("Attempting to process payment for invoice %s via AccountService for account %d. Amount: %d %s",
-		existingInvoice.InvoiceID, existingInvoice.CustomerID, amountToDebit, existingInvoice.Currency.String)
+	log.Printf("Attempting to process payment for invoice %s via AccountService for account %s. Amount: %d %s",
+		existingInvoice.InvoiceID, existingInvoice.CustomerID, amountToDebit, chargedCurrency)
 
-	err = s.makePaymentOnAccount(ctx, existingInvoice.CustomerID, amountToDebit, existingInvoice.Currency.String)
+	err = s.makePaymentOnAccount(ctx, existingInvoice.CustomerID, amountToDebit, accountCurrencyCode(chargedCurrency))
 	if err != nil {
 		// The payment failed (e.g., insufficient funds). Mark the invoice as FAILED.
 		log.Printf("Payment via AccountService failed for invoice %s. Reason: %v. Marking invoice as failed.", existingInvoice.InvoiceID, err)
@@ -313,6 +322,9 @@ func (s *BankService) ConfirmBankPayment(ctx context.Context, req model.BankPaym
 	}
 
 	log.Printf("Bank payment confirmed for invoice %s. Status: COMPLETED", updatedInvoice.InvoiceID)
+	if _, _, err := s.voucherService.Redeem(ctx, updatedInvoice.InvoiceID); err != nil {
+		log.Printf("Warning: failed to redeem voucher for invoice %s: %v", updatedInvoice.InvoiceID, err)
+	}
 
 	// 5. Trigger post-payment actions - This logic remains the same.
 	if s.invoiceService != nil {
@@ -335,10 +347,74 @@ func (s *BankService) HandleBankPaymentFailed(ctx context.Context, invoiceID uui
 		return db.Invoice{}, fmt.Errorf("failed to mark bank payment as failed for invoice %s: %w", invoiceID, err)
 	}
 	log.Printf("Bank payment marked as FAILED for invoice %s. Reason: %s", invoiceID, reason)
+	if _, _, err := s.voucherService.Release(ctx, invoiceID, reason); err != nil {
+		log.Printf("Warning: failed to release voucher for invoice %s: %v", invoiceID, err)
+	}
 	return failedInvoice, nil
 }
 
-// Implement RefundBankPayment if necessary
+// CreditRefund nạp lại số tiền hoàn vào tài khoản khách (tài khoản đã bị trừ khi thanh toán) qua AccountService,
+// không cập nhật hoá đơn. Trả về mã tham chiếu của lần nạp.
+func (s *BankService) CreditRefund(ctx context.Context, invoice db.Invoice, req model.ProviderRefundRequest) (string, error) {
+	// Số tiền hoàn tính theo tiền tệ giá vé, quy đổi sang tiền tệ tài khoản theo tỷ giá lúc thanh toán
+	creditAmount, creditCurrency := invoiceChargedAmountFor(invoice, req.Amount, invoiceRefundTotals(invoice, req.PriorRefunds))
+	amountToCredit, err := currency.ToMinorUnits(creditAmount, creditCurrency)
+	if err != nil {
+		return "", fmt.Errorf("bank refund: could not convert amount for invoice %s: %w", invoice.InvoiceID, err)
+	}
+
+	description := fmt.Sprintf("Hoàn tiền hoá đơn %s (refund %s)", invoice.InvoiceNumber, req.RefundID)
+	if err := s.depositToAccount(ctx, invoice.CustomerID, amountToCredit, accountCurrencyCode(creditCurrency), description); err != nil {
+		log.Printf("Bank refund via AccountService failed for invoice %s: %v", invoice.InvoiceID, err)
+		return "", fmt.Errorf("bank refund failed for invoice %s: %w", invoice.InvoiceID, err)
+	}
+	return fmt.Sprintf("BANK-DEPOSIT-%s", req.RefundID), nil
+}
+
+// depositToAccount calls the external Account Service to credit an account (used for refunds).
+func (s *BankService) depositToAccount(ctx context.Context, accountID string, amount int64, currency, description string) error {
+	if s.httpClient == nil {
+		return fmt.Errorf("HTTP client not configured for AccountService communication")
+	}
+	if s.accountServiceBaseURL == "" {
+		return fmt.Errorf("AccountService base URL not configured")
+	}
+
+	url := fmt.Sprintf("%s/api/v1/accounts/deposit", s.accountServiceBaseURL)
+
+	bodyBytes, err := json.Marshal(map[string]interface{}{
+		"amount":      amount,
+		"currency":    currency,
+		"description": description,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal deposit request body: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create deposit request to AccountService: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-User-ID", accountID)
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(httpReq)
+	if err != nil {
+		log.Printf("Error making deposit request to AccountService at %s: %v", url, err)
+		return fmt.Errorf("deposit request to AccountService failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBodyBytes, _ := io.ReadAll(resp.Body)
+		log.Printf("AccountService deposit endpoint at %s returned error: %s (status %d) for AccountID %s, Body: %s", url, resp.Status, resp.StatusCode, accountID, string(respBodyBytes))
+		return fmt.Errorf("accountService deposit failed with status %d: %s", resp.StatusCode, string(respBodyBytes))
+	}
+
+	log.Printf("Successfully credited %d %s to account %s via AccountService", amount, currency, accountID)
+	return nil
+}
 
 // makePaymentOnAccount calls the external Account Service to perform a payment.
 func (s *BankService) makePaymentOnAccount(ctx context.Context, accountID string, amount int64, currency string) error {
@@ -382,12 +458,12 @@ func (s *BankService) makePaymentOnAccount(ctx context.Context, accountID string
 	// Handle non-OK responses, which indicate a failure (e.g., insufficient funds).
 	if resp.StatusCode != http.StatusOK {
 		respBodyBytes, _ := io.ReadAll(resp.Body)
-		log.Printf("AccountService payment endpoint at %s returned error: %s (status %d) for AccountID %d, Body: %s", url, resp.Status, resp.StatusCode, accountID, string(respBodyBytes))
+		log.Printf("AccountService payment endpoint at %s returned error: %s (status %d) for AccountID %s, Body: %s", url, resp.Status, resp.StatusCode, accountID, string(respBodyBytes))
 
 		// Return a specific error message that can be used upstream.
 		return fmt.Errorf("accountService payment failed with status %d: %s", resp.StatusCode, string(respBodyBytes))
 	}
 
-	log.Printf("Successfully processed payment of %d %s for account %d via AccountService", amount, currency, accountID)
+	log.Printf("Successfully processed payment of %d %s for account %s via AccountService", amount, currency, accountID)
 	return nil
 }
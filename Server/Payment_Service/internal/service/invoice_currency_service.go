@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+
+	"payment_service/internal/db"
+	"payment_service/pkg/currency"
+)
+
+// ConvertAmount quy đổi amount từ tiền tệ from sang to theo tỷ giá mới nhất của nguồn tỷ giá
+func (s *InvoiceService) ConvertAmount(ctx context.Context, amount float64, from, to string) (currency.Conversion, error) {
+	conversion, err := s.converter.Convert(ctx, amount, from, to)
+	if err != nil {
+		return currency.Conversion{}, fmt.Errorf("service: failed to convert %.2f %s to %s: %w", amount, from, to, err)
+	}
+	return conversion, nil
+}
+
+// snapshotExchangeRate ghi tiền tệ thu, số tiền thu và tỷ giá dùng để quy đổi final_amount (tiền tệ giá vé)
+// sang chargeCurrency. chargeCurrency rỗng nghĩa là thu bằng tiền tệ giá vé (tỷ giá 1, nguồn "identity").
+func (s *InvoiceService) snapshotExchangeRate(ctx context.Context, params db.CreateInvoiceParams, chargeCurrency string) (db.CreateInvoiceParams, error) {
+	fareCurrency := params.Currency.String
+	if fareCurrency == "" {
+		return params, nil
+	}
+	if chargeCurrency == "" {
+		chargeCurrency = fareCurrency
+	}
+
+	conversion, err := s.ConvertAmount(ctx, params.FinalAmount, fareCurrency, chargeCurrency)
+	if err != nil {
+		return db.CreateInvoiceParams{}, err
+	}
+	params.ChargedCurrency = sql.NullString{String: conversion.To, Valid: true}
+	params.ChargedAmount = sql.NullString{String: formatCurrencyAmount(conversion.ConvertedAmount, conversion.To), Valid: true}
+	params.ExchangeRate = sql.NullString{String: strconv.FormatFloat(conversion.Rate.Rate, 'f', -1, 64), Valid: true}
+	params.ExchangeRateSource = sql.NullString{String: conversion.Rate.Source, Valid: conversion.Rate.Source != ""}
+	params.ExchangeRateAt = sql.NullTime{Time: conversion.Rate.AsOf, Valid: !conversion.Rate.AsOf.IsZero()}
+	return params, nil
+}
+
+// invoiceChargedAmount trả về số tiền và tiền tệ thực thu của hoá đơn. Hoá đơn tạo trước khi có
+// snapshot tỷ giá được thu bằng tiền tệ giá vé.
+func invoiceChargedAmount(invoice db.Invoice) (float64, string) {
+	if invoice.ChargedCurrency.Valid && invoice.ChargedAmount.Valid {
+		return decimalStringToFloat(invoice.ChargedAmount), invoice.ChargedCurrency.String
+	}
+	return invoice.FinalAmount, invoice.Currency.String
+}
+
+// refundTotals là tổng các lần hoàn trước (COMPLETED hoặc PENDING) của hoá đơn, theo tiền tệ giá vé (Fare)
+// và theo tiền tệ thực thu (Charged).
+type refundTotals struct {
+	Fare    float64
+	Charged float64
+}
+
+// invoiceRefundTotals cộng dồn các lần hoàn trước theo đúng thứ tự đã hoàn, mỗi lần quy đổi như invoiceChargedAmountFor
+// để Charged khớp với số tiền đã thực sự gửi cho cổng thanh toán.
+func invoiceRefundTotals(invoice db.Invoice, fareAmounts []float64) refundTotals {
+	var totals refundTotals
+	for _, fare := range fareAmounts {
+		charged, _ := invoiceChargedAmountFor(invoice, fare, totals)
+		totals.Fare += fare
+		totals.Charged += charged
+	}
+	return totals
+}
+
+// invoiceChargedAmountFor quy đổi fareAmount (tiền tệ giá vé, VD số tiền hoàn) sang tiền tệ thực thu theo
+// tỷ giá đã snapshot khi tạo hoá đơn (không theo tỷ giá hiện tại), không vượt quá phần đã thu chưa hoàn
+// (số đã thu trừ refunded.Charged). Lần hoàn đưa tổng đã hoàn lên tới final_amount trả về đúng phần còn lại
+// để tổng các lần hoàn bằng số tiền đã thu.
+func invoiceChargedAmountFor(invoice db.Invoice, fareAmount float64, refunded refundTotals) (float64, string) {
+	chargedAmount, chargedCurrency := invoiceChargedAmount(invoice)
+	if currency.Equal(chargedCurrency, invoice.Currency.String) {
+		return fareAmount, chargedCurrency
+	}
+	remaining := math.Max(0, currency.Round(chargedAmount-refunded.Charged, chargedCurrency))
+	if refunded.Fare+fareAmount >= invoice.FinalAmount-refundEpsilon {
+		return remaining, chargedCurrency
+	}
+	rate := decimalStringToFloat(invoice.ExchangeRate)
+	if rate <= 0 && invoice.FinalAmount > 0 {
+		rate = chargedAmount / invoice.FinalAmount
+	}
+	return math.Min(currency.Round(fareAmount*rate, chargedCurrency), remaining), chargedCurrency
+}
+
+// formatCurrencyAmount ghi số tiền dạng DECIMAL với đúng số chữ số phần lẻ của tiền tệ
+func formatCurrencyAmount(amount float64, code string) string {
+	c, err := currency.Lookup(code)
+	if err != nil {
+		return floatToDecimalString(amount)
+	}
+	return c.FormatDecimal(amount)
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"payment_service/internal/db"
+	"payment_service/pkg/currency"
+)
+
+func nullString(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+
+// invoiceVNDChargedUSD là hoá đơn giá vé 1.000.000 VND thu bằng USD theo tỷ giá snapshot 1 VND = 0.00004 USD
+func invoiceVNDChargedUSD() db.Invoice {
+	return db.Invoice{
+		FinalAmount:     1000000,
+		Currency:        nullString("vnd"),
+		ChargedCurrency: nullString("usd"),
+		ChargedAmount:   nullString("40.00"),
+		ExchangeRate:    nullString("0.00004"),
+	}
+}
+
+func TestInvoiceChargedAmountFor(t *testing.T) {
+	tests := []struct {
+		name         string
+		invoice      func() db.Invoice
+		fareAmount   float64
+		refunded     refundTotals
+		wantAmount   float64
+		wantCurrency string
+	}{
+		{
+			name: "same currency",
+			invoice: func() db.Invoice {
+				inv := invoiceVNDChargedUSD()
+				inv.ChargedCurrency, inv.ChargedAmount, inv.ExchangeRate = nullString("vnd"), nullString("1000000"), nullString("1")
+				return inv
+			},
+			fareAmount:   300000,
+			wantAmount:   300000,
+			wantCurrency: "vnd",
+		},
+		{
+			name: "invoice before rate snapshot uses fare currency",
+			invoice: func() db.Invoice {
+				return db.Invoice{FinalAmount: 1000000, Currency: nullString("vnd")}
+			},
+			fareAmount:   300000,
+			wantAmount:   300000,
+			wantCurrency: "vnd",
+		},
+		{
+			name:         "full refund returns exactly the charged amount",
+			invoice:      invoiceVNDChargedUSD,
+			fareAmount:   1000000,
+			wantAmount:   40,
+			wantCurrency: "usd",
+		},
+		{
+			name:         "partial refund uses snapshot rate, rounded to cents",
+			invoice:      invoiceVNDChargedUSD,
+			fareAmount:   333333,
+			wantAmount:   13.33,
+			wantCurrency: "usd",
+		},
+		{
+			name: "snapshot rate is used, not the charged/final ratio",
+			invoice: func() db.Invoice {
+				inv := invoiceVNDChargedUSD()
+				inv.ChargedAmount = nullString("40.10") // Phí làm lệch tỷ lệ, tỷ giá snapshot vẫn là 0.00004
+				return inv
+			},
+			fareAmount:   250000,
+			wantAmount:   10,
+			wantCurrency: "usd",
+		},
+		{
+			name: "missing rate falls back to charged/final ratio",
+			invoice: func() db.Invoice {
+				inv := invoiceVNDChargedUSD()
+				inv.ExchangeRate = sql.NullString{}
+				return inv
+			},
+			fareAmount:   250000,
+			wantAmount:   10,
+			wantCurrency: "usd",
+		},
+		{
+			name: "capped at charged amount",
+			invoice: func() db.Invoice {
+				inv := invoiceVNDChargedUSD()
+				inv.ExchangeRate = nullString("0.00005")
+				return inv
+			},
+			fareAmount:   900000,
+			wantAmount:   40,
+			wantCurrency: "usd",
+		},
+		{
+			name: "USD fare charged in VND rounds to whole dong",
+			invoice: func() db.Invoice {
+				return db.Invoice{
+					FinalAmount:     40,
+					Currency:        nullString("usd"),
+					ChargedCurrency: nullString("vnd"),
+					ChargedAmount:   nullString("1000000"),
+					ExchangeRate:    nullString("25000"),
+				}
+			},
+			fareAmount:   13.33337,
+			wantAmount:   333334,
+			wantCurrency: "vnd",
+		},
+		{
+			name:         "refund reaching final amount returns the charged remainder",
+			invoice:      invoiceVNDChargedUSD,
+			fareAmount:   333334,
+			refunded:     refundTotals{Fare: 666666, Charged: 26.66},
+			wantAmount:   13.34,
+			wantCurrency: "usd",
+		},
+		{
+			name: "capped at charged amount not yet refunded",
+			invoice: func() db.Invoice {
+				inv := invoiceVNDChargedUSD()
+				inv.ExchangeRate = nullString("0.00005")
+				return inv
+			},
+			fareAmount:   300000,
+			refunded:     refundTotals{Fare: 600000, Charged: 30},
+			wantAmount:   10,
+			wantCurrency: "usd",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotAmount, gotCurrency := invoiceChargedAmountFor(tt.invoice(), tt.fareAmount, tt.refunded)
+			if gotAmount != tt.wantAmount || gotCurrency != tt.wantCurrency {
+				t.Fatalf("invoiceChargedAmountFor(%v) = %v %s, want %v %s",
+					tt.fareAmount, gotAmount, gotCurrency, tt.wantAmount, tt.wantCurrency)
+			}
+		})
+	}
+}
+
+// Hoàn nhiều lần một phần: mỗi lần quy đổi ra đúng đơn vị nhỏ nhất của tiền tệ thu, các lần trước lần cuối quy đổi
+// ngược lại lệch giá vé không quá nửa đơn vị nhỏ nhất, lần cuối trả phần còn lại nên tổng bằng đúng số tiền đã thu.
+func TestInvoiceChargedAmountForPartialRefundsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		invoice  db.Invoice
+		refunds  []float64 // Số tiền hoàn theo tiền tệ giá vé, tổng = FinalAmount
+		wantSums float64
+	}{
+		{
+			name:     "VND fare charged in USD",
+			invoice:  invoiceVNDChargedUSD(),
+			refunds:  []float64{333333, 333333, 333334},
+			wantSums: 40,
+		},
+		{
+			name: "USD fare charged in VND",
+			invoice: db.Invoice{
+				FinalAmount:     40,
+				Currency:        nullString("usd"),
+				ChargedCurrency: nullString("vnd"),
+				ChargedAmount:   nullString("1000000"),
+				ExchangeRate:    nullString("25000"),
+			},
+			refunds:  []float64{13.33, 13.33, 13.34},
+			wantSums: 1000000,
+		},
+		{
+			name: "EUR fare charged in USD",
+			invoice: db.Invoice{
+				FinalAmount:     99.99,
+				Currency:        nullString("eur"),
+				ChargedCurrency: nullString("usd"),
+				ChargedAmount:   nullString("108.99"),
+				ExchangeRate:    nullString("1.09"),
+			},
+			refunds:  []float64{33.33, 33.33, 33.33},
+			wantSums: 108.99,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			charged, chargedCurrency := invoiceChargedAmount(tt.invoice)
+			c, err := currency.Lookup(chargedCurrency)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rate := decimalStringToFloat(tt.invoice.ExchangeRate)
+
+			var totalMinor int64
+			for i, fare := range tt.refunds {
+				amount, code := invoiceChargedAmountFor(tt.invoice, fare, invoiceRefundTotals(tt.invoice, tt.refunds[:i]))
+				if code != chargedCurrency {
+					t.Fatalf("refund %v converted to %s, want %s", fare, code, chargedCurrency)
+				}
+				minor, err := c.ToMinorUnits(amount)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if back := c.FromMinorUnits(minor); back != amount {
+					t.Fatalf("refund %v -> %v %s is not a whole number of minor units", fare, amount, code)
+				}
+				if diff := math.Abs(amount/rate - fare); i < len(tt.refunds)-1 && diff > c.MinorUnit()/2/rate+1e-9 {
+					t.Fatalf("refund %v -> %v %s -> %v, off by %v", fare, amount, code, amount/rate, diff)
+				}
+				totalMinor += minor
+			}
+
+			chargedMinor, _ := c.ToMinorUnits(charged)
+			wantMinor, _ := c.ToMinorUnits(tt.wantSums)
+			if totalMinor != wantMinor || totalMinor != chargedMinor {
+				t.Fatalf("total refunded = %d minor units, want %d (charged %d)", totalMinor, wantMinor, chargedMinor)
+			}
+		})
+	}
+}
+
+// fakeRateProvider là nguồn tỷ giá trong bộ nhớ, khoá theo "base/quote"
+type fakeRateProvider map[string]currency.Rate
+
+func (f fakeRateProvider) LatestRate(_ context.Context, base, quote string) (currency.Rate, error) {
+	rate, ok := f[base+"/"+quote]
+	if !ok {
+		return currency.Rate{}, fmt.Errorf("fake: %s/%s: %w", base, quote, currency.ErrRateNotFound)
+	}
+	return rate, nil
+}
+
+func TestSnapshotExchangeRate(t *testing.T) {
+	asOf := time.Date(2025, 7, 30, 8, 0, 0, 0, time.FixedZone("ICT", 7*60*60))
+	svc := &InvoiceService{converter: currency.NewConverter(fakeRateProvider{
+		"usd/vnd": {Base: "usd", Quote: "vnd", Rate: 25000, Source: "vietcombank", AsOf: asOf},
+		"eur/usd": {Base: "eur", Quote: "usd", Rate: 1.09, Source: "ecb", AsOf: asOf},
+	})}
+
+	tests := []struct {
+		name           string
+		params         db.CreateInvoiceParams
+		chargeCurrency string
+		wantCurrency   string
+		wantAmount     string
+		wantRate       string
+		wantSource     string
+		wantAsOf       bool // ExchangeRateAt = asOf của tỷ giá nguồn
+		wantErr        error
+	}{
+		{
+			name:           "VND fare charged in USD uses inverse rate",
+			params:         db.CreateInvoiceParams{FinalAmount: 1000000, Currency: nullString("vnd")},
+			chargeCurrency: "usd",
+			wantCurrency:   "usd",
+			wantAmount:     "40.00",
+			wantRate:       "0.00004",
+			wantSource:     "vietcombank",
+			wantAsOf:       true,
+		},
+		{
+			name:           "USD fare charged in VND has no decimals",
+			params:         db.CreateInvoiceParams{FinalAmount: 13.33, Currency: nullString("usd")},
+			chargeCurrency: "VND",
+			wantCurrency:   "vnd",
+			wantAmount:     "333250",
+			wantRate:       "25000",
+			wantSource:     "vietcombank",
+			wantAsOf:       true,
+		},
+		{
+			name:           "EUR fare charged in USD rounds to cents",
+			params:         db.CreateInvoiceParams{FinalAmount: 99.99, Currency: nullString("eur")},
+			chargeCurrency: "usd",
+			wantCurrency:   "usd",
+			wantAmount:     "108.99",
+			wantRate:       "1.09",
+			wantSource:     "ecb",
+			wantAsOf:       true,
+		},
+		{
+			name:         "no charge currency means identity",
+			params:       db.CreateInvoiceParams{FinalAmount: 250000, Currency: nullString("vnd")},
+			wantCurrency: "vnd",
+			wantAmount:   "250000",
+			wantRate:     "1",
+			wantSource:   currency.IdentitySource,
+		},
+		{
+			name:           "missing rate",
+			params:         db.CreateInvoiceParams{FinalAmount: 250000, Currency: nullString("vnd")},
+			chargeCurrency: "eur",
+			wantErr:        currency.ErrRateNotFound,
+		},
+		{
+			name:           "unsupported charge currency",
+			params:         db.CreateInvoiceParams{FinalAmount: 250000, Currency: nullString("vnd")},
+			chargeCurrency: "xyz",
+			wantErr:        currency.ErrUnsupportedCurrency,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.snapshotExchangeRate(context.Background(), tt.params, tt.chargeCurrency)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("snapshotExchangeRate() err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("snapshotExchangeRate() err = %v", err)
+			}
+			if got.ChargedCurrency != nullString(tt.wantCurrency) || got.ChargedAmount != nullString(tt.wantAmount) ||
+				got.ExchangeRate != nullString(tt.wantRate) || got.ExchangeRateSource != nullString(tt.wantSource) {
+				t.Fatalf("snapshotExchangeRate() = %v %v at %v (%v), want %s %s at %s (%s)",
+					got.ChargedAmount, got.ChargedCurrency, got.ExchangeRate, got.ExchangeRateSource,
+					tt.wantAmount, tt.wantCurrency, tt.wantRate, tt.wantSource)
+			}
+			if !got.ExchangeRateAt.Valid || (tt.wantAsOf && !got.ExchangeRateAt.Time.Equal(asOf)) {
+				t.Fatalf("snapshotExchangeRate() ExchangeRateAt = %v, want %v", got.ExchangeRateAt, asOf)
+			}
+			if got.FinalAmount != tt.params.FinalAmount || got.Currency != tt.params.Currency {
+				t.Fatalf("snapshotExchangeRate() changed the fare: %v %v", got.FinalAmount, got.Currency)
+			}
+
+			// Hoá đơn tạo từ snapshot: hoàn toàn bộ trả đúng số đã thu
+			invoice := db.Invoice{
+				FinalAmount:     got.FinalAmount,
+				Currency:        got.Currency,
+				ChargedCurrency: got.ChargedCurrency,
+				ChargedAmount:   got.ChargedAmount,
+				ExchangeRate:    got.ExchangeRate,
+			}
+			amount, code := invoiceChargedAmountFor(invoice, got.FinalAmount, refundTotals{})
+			if code != tt.wantCurrency || formatCurrencyAmount(amount, code) != tt.wantAmount {
+				t.Fatalf("full refund = %v %s, want %s %s", amount, code, tt.wantAmount, tt.wantCurrency)
+			}
+		})
+	}
+
+	t.Run("no fare currency leaves params unchanged", func(t *testing.T) {
+		params := db.CreateInvoiceParams{FinalAmount: 250000}
+		got, err := svc.snapshotExchangeRate(context.Background(), params, "usd")
+		if err != nil || got.ChargedCurrency.Valid || got.ExchangeRate.Valid {
+			t.Fatalf("snapshotExchangeRate() = %+v, %v; want params unchanged", got, err)
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/google/uuid"
+
+	"payment_service/domain/model"
+	"payment_service/internal/db"
+)
+
+var (
+	// ErrInvoiceNotRefundable: hoá đơn chưa thanh toán hoặc đã hoàn toàn bộ
+	ErrInvoiceNotRefundable = errors.New("invoice is not refundable")
+	// ErrRefundExceedsInvoice: số tiền hoàn vượt quá phần còn lại của final_amount
+	ErrRefundExceedsInvoice = errors.New("refund amount exceeds refundable amount")
+	// ErrDuplicateRefundRequest: cùng một yêu cầu hoàn (request key) đã được ghi sổ trước đó
+	ErrDuplicateRefundRequest = errors.New("refund request already recorded")
+)
+
+// refundEpsilon là sai số khi so sánh các số tiền DECIMAL(15,2) đã đổi sang float64
+const refundEpsilon = 0.005
+
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// StartRefund ghi một lần hoàn PENDING cho hoá đơn COMPLETED / PARTIALLY_REFUNDED, trước khi gọi cổng thanh toán.
+// Tổng các lần hoàn COMPLETED và PENDING không vượt quá final_amount của hoá đơn.
+func (s *InvoiceService) StartRefund(ctx context.Context, req model.InvoiceRefundRequest) (db.Refund, db.Invoice, error) {
+	arg := db.CreateRefundParams{
+		InvoiceID:   req.InvoiceID,
+		Reason:      req.Reason,
+		InitiatedBy: req.InitiatedBy,
+		RequestKey:  sql.NullString{String: req.RequestKey, Valid: req.RequestKey != ""},
+	}
+
+	refund, invoice, existing, err := s.repo.CreateRefund(ctx, arg, func(invoice db.Invoice, refunded db.SumRefundsByInvoiceIDRow) (float64, error) {
+		status := model.PaymentStatus(invoice.PaymentStatus.String)
+		if status != model.PaymentStatusCompleted && status != model.PaymentStatusPartiallyRefunded {
+			return 0, fmt.Errorf("invoice %s has status %s: %w", invoice.InvoiceID, status, ErrInvoiceNotRefundable)
+		}
+
+		refundable := roundAmount(invoice.FinalAmount - refunded.CompletedAmount - refunded.PendingAmount)
+		if refundable < refundEpsilon {
+			return 0, fmt.Errorf("invoice %s has nothing left to refund (refunded %.2f, pending %.2f): %w",
+				invoice.InvoiceID, refunded.CompletedAmount, refunded.PendingAmount, ErrRefundExceedsInvoice)
+		}
+
+		amount := roundAmount(req.Amount)
+		if amount <= 0 {
+			amount = refundable
+		}
+		if amount > refundable+refundEpsilon {
+			if !req.CapToRefundable {
+				return 0, fmt.Errorf("refund %.2f for invoice %s exceeds refundable %.2f: %w", amount, invoice.InvoiceID, refundable, ErrRefundExceedsInvoice)
+			}
+			amount = refundable
+		}
+		return amount, nil
+	})
+	if err != nil {
+		return db.Refund{}, invoice, fmt.Errorf("service: failed to start refund for invoice %s: %w", req.InvoiceID, err)
+	}
+	if existing {
+		return refund, invoice, fmt.Errorf("service: refund request %s already recorded as refund %s (%s): %w", req.RequestKey, refund.RefundID, refund.Status, ErrDuplicateRefundRequest)
+	}
+
+	log.Printf("Info: service: refund %s of %.2f started for invoice %s (%s) by %s", refund.RefundID, refund.Amount, invoice.InvoiceID, refund.Provider, refund.InitiatedBy)
+	return refund, invoice, nil
+}
+
+// CompleteRefund ghi nhận cổng thanh toán đã hoàn tiền và cập nhật hoá đơn:
+// REFUNDED khi tổng đã hoàn bằng final_amount, ngược lại PARTIALLY_REFUNDED.
+func (s *InvoiceService) CompleteRefund(ctx context.Context, refundID uuid.UUID, providerReference string) (db.Refund, db.Invoice, error) {
+	arg := db.CompleteRefundParams{
+		RefundID:          refundID,
+		ProviderReference: sql.NullString{String: providerReference, Valid: providerReference != ""},
+	}
+
+	refund, invoice, applied, err := s.repo.CompleteRefund(ctx, arg, func(refund db.Refund, invoice db.Invoice, refunded db.SumRefundsByInvoiceIDRow) db.UpdateInvoiceStatusGeneralParams {
+		status := model.PaymentStatusPartiallyRefunded
+		if refunded.CompletedAmount >= invoice.FinalAmount-refundEpsilon {
+			status = model.PaymentStatusRefunded
+		}
+
+		reference := providerReference
+		if reference == "" {
+			reference = refund.RefundID.String()
+		}
+		note := fmt.Sprintf("Refund ID: %s. Hoàn %.2f bởi %s. Reason: %s", reference, refund.Amount, refund.InitiatedBy, refund.Reason)
+		if invoice.Notes != "" {
+			note = invoice.Notes + "\n" + note
+		}
+		return db.UpdateInvoiceStatusGeneralParams{
+			PaymentStatus: sql.NullString{String: string(status), Valid: true},
+			Notes:         note,
+		}
+	})
+	if err != nil {
+		return db.Refund{}, db.Invoice{}, fmt.Errorf("service: failed to complete refund %s: %w", refundID, err)
+	}
+	if !applied {
+		log.Printf("Info: service: refund %s is already %s. Skipping completion.", refund.RefundID, refund.Status)
+		return refund, invoice, nil
+	}
+
+	if invoice.PaymentStatus.String == string(model.PaymentStatusRefunded) {
+		if err := s.UpdateTicketStatus(ctx, invoice.TicketID, model.TicketStatusRefunded, invoice.InvoiceID); err != nil {
+			log.Printf("Warning: service: failed to update ticket status for invoice %s (ticket %s) after refund: %v", invoice.InvoiceID, invoice.TicketID, err)
+		}
+	}
+	return refund, invoice, nil
+}
+
+// FailRefund đánh dấu lần hoàn thất bại, số tiền của nó lại có thể được hoàn
+func (s *InvoiceService) FailRefund(ctx context.Context, refundID uuid.UUID, reason string) (db.Refund, error) {
+	refund, err := s.repo.FailRefund(ctx, refundID, reason)
+	if err != nil {
+		return db.Refund{}, fmt.Errorf("service: failed to mark refund %s as failed: %w", refundID, err)
+	}
+	return refund, nil
+}
+
+// PriorRefundAmounts trả về số tiền (tiền tệ giá vé) của các lần hoàn COMPLETED / PENDING ghi sổ trước refundID,
+// theo thứ tự ghi sổ, để quy đổi lần hoàn refundID theo phần tiền thực thu còn lại.
+func (s *InvoiceService) PriorRefundAmounts(ctx context.Context, invoiceID, refundID uuid.UUID) ([]float64, error) {
+	refunds, err := s.repo.ListRefundsByInvoiceID(ctx, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list refunds of invoice %s: %w", invoiceID, err)
+	}
+	var amounts []float64
+	for _, refund := range refunds {
+		if refund.RefundID == refundID {
+			break
+		}
+		switch model.RefundStatus(refund.Status) {
+		case model.RefundStatusCompleted, model.RefundStatusPending:
+			amounts = append(amounts, refund.Amount)
+		}
+	}
+	return amounts, nil
+}
+
+// ListRefunds trả về sổ hoàn tiền của hoá đơn cùng số tiền đã hoàn, đang chờ và còn có thể hoàn
+func (s *InvoiceService) ListRefunds(ctx context.Context, invoiceID uuid.UUID) (model.InvoiceRefundsResponse, error) {
+	invoice, err := s.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		return model.InvoiceRefundsResponse{}, err
+	}
+	refunds, err := s.repo.ListRefundsByInvoiceID(ctx, invoiceID)
+	if err != nil {
+		return model.InvoiceRefundsResponse{}, fmt.Errorf("service: failed to list refunds of invoice %s: %w", invoiceID, err)
+	}
+
+	resp := model.InvoiceRefundsResponse{
+		InvoiceID:     invoice.InvoiceID,
+		PaymentStatus: invoice.PaymentStatus.String,
+		FinalAmount:   invoice.FinalAmount,
+		Refunds:       make([]model.RefundResponse, len(refunds)),
+	}
+	for i, refund := range refunds {
+		switch model.RefundStatus(refund.Status) {
+		case model.RefundStatusCompleted:
+			resp.RefundedAmount += refund.Amount
+		case model.RefundStatusPending:
+			resp.PendingAmount += refund.Amount
+		}
+		resp.Refunds[i] = s.MapDbRefundToAPIResponse(refund)
+	}
+	resp.RefundedAmount = roundAmount(resp.RefundedAmount)
+	resp.PendingAmount = roundAmount(resp.PendingAmount)
+
+	switch model.PaymentStatus(invoice.PaymentStatus.String) {
+	case model.PaymentStatusCompleted, model.PaymentStatusPartiallyRefunded:
+		resp.RefundableAmount = math.Max(0, roundAmount(invoice.FinalAmount-resp.RefundedAmount-resp.PendingAmount))
+	}
+	return resp, nil
+}
+
+// MapDbRefundToAPIResponse chuyển đổi db.Refund sang model.RefundResponse
+func (s *InvoiceService) MapDbRefundToAPIResponse(refund db.Refund) model.RefundResponse {
+	return model.RefundResponse{
+		RefundID:          refund.RefundID,
+		InvoiceID:         refund.InvoiceID,
+		Amount:            refund.Amount,
+		Reason:            refund.Reason,
+		InitiatedBy:       refund.InitiatedBy,
+		Provider:          refund.Provider,
+		ProviderReference: refund.ProviderReference.String,
+		Status:            refund.Status,
+		FailureReason:     refund.FailureReason,
+		CreatedAt:         refund.CreatedAt.Format("2006-01-02 15:04:05"),
+		UpdatedAt:         refund.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
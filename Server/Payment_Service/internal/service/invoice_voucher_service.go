@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+
+	"payment_service/domain/model"
+	"payment_service/internal/db"
+	"payment_service/pkg/currency"
+)
+
+// CreateInvoiceWithVoucher ghi hoá đơn params, áp voucher khách chọn (nếu có) trước khi ghi.
+// chargeCurrency là tiền tệ thực thu của khách (rỗng = tiền tệ giá vé).
+// Dùng cho các luồng tự tạo CreateInvoiceParams (chuyển khoản qua AccountService).
+func (s *InvoiceService) CreateInvoiceWithVoucher(ctx context.Context, params db.CreateInvoiceParams, selection model.VoucherSelection, chargeCurrency string) (db.Invoice, error) {
+	return s.createInvoiceWithVoucher(ctx, params, selection, chargeCurrency, func(params db.CreateInvoiceParams) (db.Invoice, error) {
+		return s.repo.CreateInvoice(ctx, params)
+	})
+}
+
+// createInvoiceWithVoucher giữ một lượt dùng voucher cho params.InvoiceID, cộng số tiền giảm vào discount_amount,
+// trừ vào final_amount, quy đổi final_amount sang chargeCurrency rồi ghi hoá đơn bằng create.
+// Voucher được tính trên tổng tiền sau các giảm giá đã có. Ghi hoá đơn lỗi thì lượt dùng được trả lại ngay.
+func (s *InvoiceService) createInvoiceWithVoucher(ctx context.Context, params db.CreateInvoiceParams, selection model.VoucherSelection, chargeCurrency string, create func(params db.CreateInvoiceParams) (db.Invoice, error)) (db.Invoice, error) {
+	if selection.VoucherCode == "" {
+		params, err := s.snapshotExchangeRate(ctx, params, chargeCurrency)
+		if err != nil {
+			return db.Invoice{}, err
+		}
+		return create(params)
+	}
+
+	fareCurrency := params.Currency.String
+	currentDiscount := decimalStringToFloat(params.DiscountAmount)
+	quote, err := s.vouchers.Reserve(ctx, params.InvoiceID, model.ValidateVoucherRequest{
+		Code:          selection.VoucherCode,
+		CustomerID:    params.CustomerID,
+		Amount:        params.TotalAmount - currentDiscount,
+		Currency:      fareCurrency,
+		RouteID:       selection.RouteID,
+		DepartureDate: selection.DepartureDate,
+	})
+	if err != nil {
+		return db.Invoice{}, fmt.Errorf("service: failed to apply voucher %s: %w", selection.VoucherCode, err)
+	}
+
+	params.DiscountAmount = sql.NullString{String: floatToDecimalString(currentDiscount + quote.DiscountAmount), Valid: true}
+	params.FinalAmount = currency.Round(params.FinalAmount-quote.DiscountAmount, fareCurrency)
+	voucherNote := fmt.Sprintf("Voucher %s applied: -%.2f %s.", quote.Code, quote.DiscountAmount, fareCurrency)
+	if params.Notes != "" {
+		params.Notes = params.Notes + " | " + voucherNote
+	} else {
+		params.Notes = voucherNote
+	}
+
+	snapshotParams, err := s.snapshotExchangeRate(ctx, params, chargeCurrency)
+	if err != nil {
+		s.releaseVoucher(ctx, params.InvoiceID, "exchange rate unavailable")
+		return db.Invoice{}, err
+	}
+	params = snapshotParams
+
+	invoice, err := create(params)
+	if err != nil {
+		s.releaseVoucher(ctx, params.InvoiceID, "invoice creation failed")
+		return db.Invoice{}, err
+	}
+	return invoice, nil
+}
+
+// redeemVoucher ghi nhận lượt dùng voucher của hoá đơn vừa thanh toán. Lỗi chỉ được log:
+// thanh toán đã thành công, lượt dùng có thể ghi nhận lại qua POST /vouchers/redeem.
+func (s *InvoiceService) redeemVoucher(ctx context.Context, invoiceID uuid.UUID) {
+	redemption, applied, err := s.vouchers.Redeem(ctx, invoiceID)
+	if err != nil {
+		log.Printf("Warning: service: failed to redeem voucher for invoice %s: %v", invoiceID, err)
+		return
+	}
+	if applied {
+		log.Printf("Info: service: voucher redemption %s redeemed for invoice %s.", redemption.RedemptionID, invoiceID)
+	}
+}
+
+// releaseVoucher trả lại lượt dùng voucher của hoá đơn hết hạn / thất bại
+func (s *InvoiceService) releaseVoucher(ctx context.Context, invoiceID uuid.UUID, reason string) {
+	redemption, applied, err := s.vouchers.Release(ctx, invoiceID, reason)
+	if err != nil {
+		log.Printf("Warning: service: failed to release voucher for invoice %s: %v", invoiceID, err)
+		return
+	}
+	if applied {
+		log.Printf("Info: service: voucher redemption %s released for invoice %s.", redemption.RedemptionID, invoiceID)
+	}
+}
+
+// AttachStripePaymentIntent gắn PaymentIntent vào hoá đơn Stripe được tạo trước PaymentIntent
+// (số tiền gửi sang Stripe là final_amount sau voucher).
+func (s *InvoiceService) AttachStripePaymentIntent(ctx context.Context, invoice db.Invoice, paymentIntentID string) (db.Invoice, error) {
+	updatedInvoice, err := s.repo.UpdateInvoiceStripePaymentIntent(ctx, db.UpdateInvoiceStripePaymentIntentParams{
+		InvoiceID:             invoice.InvoiceID,
+		StripePaymentIntentID: sql.NullString{String: paymentIntentID, Valid: paymentIntentID != ""},
+		PaymentMethod:         invoice.PaymentMethod,
+		PaymentStatus:         invoice.PaymentStatus,
+	})
+	if err != nil {
+		return db.Invoice{}, fmt.Errorf("service: failed to attach PaymentIntent %s to invoice %s: %w", paymentIntentID, invoice.InvoiceID, err)
+	}
+	return updatedInvoice, nil
+}
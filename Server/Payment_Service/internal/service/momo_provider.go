@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"payment_service/domain/model"
+	"payment_service/internal/db"
+	"payment_service/pkg/momo"
+)
+
+// MoMoProvider thanh toán qua ví MoMo (captureWallet): khách được chuyển tới payUrl, kết quả về qua IPN và redirect.
+type MoMoProvider struct {
+	client     *momo.Client
+	invoiceSvc InvoiceServiceInterface
+}
+
+func NewMoMoProvider(client *momo.Client, invoiceSvc InvoiceServiceInterface) PaymentProvider {
+	return &MoMoProvider{client: client, invoiceSvc: invoiceSvc}
+}
+
+func (p *MoMoProvider) Method() model.PaymentMethod {
+	return model.PaymentMethodMoMo
+}
+
+func (p *MoMoProvider) CreatePayment(ctx context.Context, req model.ProviderPaymentRequest) (*model.ProviderPaymentResponse, error) {
+	if req.Currency != "" && !strings.EqualFold(req.Currency, "vnd") {
+		return nil, fmt.Errorf("momo: currency %s is not supported, only VND", req.Currency)
+	}
+	req.Currency = "vnd"
+
+	txnRef := fmt.Sprintf("MOMO%d%s", time.Now().Unix(), uuid.New().String()[:8])
+	invoice, err := p.invoiceSvc.CreateInvoiceForProvider(ctx, req, model.PaymentMethodMoMo, txnRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	momoResp, err := p.client.Create(ctx, momo.CreateRequest{
+		RequestID: txnRef,
+		Amount:    int64(invoice.FinalAmount),
+		OrderID:   txnRef,
+		OrderInfo: fmt.Sprintf("Thanh toan cho ve %s, hoa don %s", req.TicketID, invoice.InvoiceNumber),
+		Lang:      momoLang(req.Language),
+	})
+	if err == nil && momoResp.ResultCode != momo.ResultCodeSuccess {
+		err = fmt.Errorf("momo: create rejected (code %d): %s", momoResp.ResultCode, momoResp.Message)
+	}
+	if err != nil {
+		if _, failErr := p.invoiceSvc.UpdateInvoiceStatusForPaymentFailure(ctx, txnRef, model.PaymentMethodMoMo, err.Error()); failErr != nil {
+			log.Printf("Warning: momo: failed to mark invoice %s as failed: %v", invoice.InvoiceID, failErr)
+		}
+		return nil, err
+	}
+
+	return &model.ProviderPaymentResponse{
+		Provider:   model.PaymentMethodMoMo,
+		InvoiceID:  invoice.InvoiceID,
+		TxnRef:     txnRef,
+		PaymentURL: momoResp.PayURL,
+		Deeplink:   momoResp.Deeplink,
+		QRCode:     momoResp.QrCodeURL,
+	}, nil
+}
+
+// HandleCallback xử lý IPN (JSON body) và redirect (query string) của MoMo, cả hai cùng định dạng và chữ ký.
+// IPN hợp lệ luôn trả 204 để MoMo không gửi lại; chữ ký sai trả 400.
+func (p *MoMoProvider) HandleCallback(ctx context.Context, callback model.ProviderCallback) (*model.ProviderCallbackResult, error) {
+	var notification momo.Notification
+	var err error
+	if callback.Kind == model.ProviderCallbackIPN {
+		err = json.Unmarshal(callback.Body, &notification)
+	} else {
+		notification, err = momo.NotificationFromValues(callback.Query)
+	}
+	if err != nil {
+		return &model.ProviderCallbackResult{
+			Provider:  model.PaymentMethodMoMo,
+			Message:   "Invalid request",
+			AckStatus: http.StatusBadRequest,
+		}, fmt.Errorf("momo: invalid %s payload: %w", callback.Kind, err)
+	}
+
+	result := &model.ProviderCallbackResult{
+		Provider:      model.PaymentMethodMoMo,
+		TxnRef:        notification.OrderID,
+		ProviderTxnID: strconv.FormatInt(notification.TransID, 10),
+		Message:       notification.Message,
+	}
+	if callback.Kind == model.ProviderCallbackIPN {
+		result.AckStatus = http.StatusNoContent
+	}
+	if !p.client.VerifyNotification(notification) {
+		log.Printf("MoMo %s: invalid signature for orderId %s", callback.Kind, notification.OrderID)
+		result.Message = "Invalid signature"
+		result.AckStatus = http.StatusBadRequest
+		return result, ErrInvalidSignature
+	}
+	if notification.ResultCode == momo.ResultCodePending || notification.ResultCode == momo.ResultCodeProcessing {
+		result.Status = model.PaymentStatusPending
+		return result, nil
+	}
+
+	invoice, err := settleProviderPayment(ctx, p.invoiceSvc, model.PaymentMethodMoMo, providerOutcome{
+		TxnRef:        notification.OrderID,
+		ProviderTxnID: result.ProviderTxnID,
+		Amount:        float64(notification.Amount),
+		Success:       notification.ResultCode == momo.ResultCodeSuccess,
+		PaidAt:        time.UnixMilli(notification.ResponseTime),
+		Reason:        fmt.Sprintf("MoMo resultCode %d: %s", notification.ResultCode, notification.Message),
+	})
+	if err != nil && !errors.Is(err, ErrInvoiceNotPending) && !errors.Is(err, ErrAmountMismatch) {
+		// Lỗi hệ thống: trả nil để controller trả 500, MoMo sẽ gửi lại IPN
+		return nil, err
+	}
+	result.InvoiceID = invoice.InvoiceID
+	result.Status = model.PaymentStatus(invoice.PaymentStatus.String)
+	return result, err
+}
+
+func (p *MoMoProvider) QueryPayment(ctx context.Context, invoice db.Invoice) (*model.ProviderPaymentStatus, error) {
+	txn, err := p.invoiceSvc.GetLatestPaymentTransactionByInvoiceID(ctx, invoice.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Query(ctx, momo.QueryRequest{
+		RequestID: fmt.Sprintf("%s-Q%d", txn.TxnRef, time.Now().UnixMilli()),
+		OrderID:   txn.TxnRef,
+		Lang:      "vi",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status := &model.ProviderPaymentStatus{
+		Provider:  model.PaymentMethodMoMo,
+		InvoiceID: invoice.InvoiceID,
+		TxnRef:    txn.TxnRef,
+		Amount:    float64(resp.Amount),
+		Message:   resp.Message,
+	}
+	if resp.TransID != 0 {
+		status.ProviderTxnID = strconv.FormatInt(resp.TransID, 10)
+	}
+	switch resp.ResultCode {
+	case momo.ResultCodeSuccess:
+		status.Status = model.PaymentStatusCompleted
+		paidAt := time.UnixMilli(resp.ResponseTime)
+		status.PaidAt = &paidAt
+	case momo.ResultCodePending, momo.ResultCodeProcessing:
+		status.Status = model.PaymentStatusPending
+	default:
+		status.Status = model.PaymentStatusFailed
+	}
+	return status, nil
+}
+
+func (p *MoMoProvider) Refund(ctx context.Context, invoice db.Invoice, req model.ProviderRefundRequest) (string, error) {
+	txn, err := p.invoiceSvc.GetLatestPaymentTransactionByInvoiceID(ctx, invoice.InvoiceID)
+	if err != nil {
+		return "", err
+	}
+	if txn.Status != string(model.PaymentStatusCompleted) || !txn.ProviderTxnID.Valid {
+		return "", fmt.Errorf("momo refund: transaction %s of invoice %s is %s without transId", txn.TxnRef, invoice.InvoiceID, txn.Status)
+	}
+	transID, err := strconv.ParseInt(txn.ProviderTxnID.String, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("momo refund: invalid transId %q: %w", txn.ProviderTxnID.String, err)
+	}
+
+	refundOrderID := fmt.Sprintf("%s-R%d", txn.TxnRef, time.Now().UnixMilli())
+	resp, err := p.client.Refund(ctx, momo.RefundRequest{
+		OrderID:     refundOrderID,
+		RequestID:   refundOrderID,
+		Amount:      int64(req.Amount),
+		TransID:     transID,
+		Lang:        "vi",
+		Description: req.Reason,
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.ResultCode != momo.ResultCodeSuccess {
+		log.Printf("MoMo refund rejected for orderId %s: %d - %s", txn.TxnRef, resp.ResultCode, resp.Message)
+		return "", fmt.Errorf("momo refund rejected (code %d): %s", resp.ResultCode, resp.Message)
+	}
+
+	log.Printf("Info: MoMo refund of %.2f succeeded for invoice %s (orderId %s, refund transId %d)", req.Amount, invoice.InvoiceID, txn.TxnRef, resp.TransID)
+	return strconv.FormatInt(resp.TransID, 10), nil
+}
+
+func momoLang(language string) string {
+	if language == "en" {
+		return "en"
+	}
+	return "vi"
+}
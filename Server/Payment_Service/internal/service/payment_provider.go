@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"payment_service/domain/model"
+	"payment_service/internal/db"
+)
+
+var (
+	ErrUnknownPaymentProvider = errors.New("unknown payment provider")
+	ErrCallbackNotSupported   = errors.New("payment provider does not support callbacks")
+	ErrInvalidSignature       = errors.New("invalid payment provider signature")
+	// ErrAmountMismatch: số tiền cổng báo khác số tiền của giao dịch, không cập nhật hoá đơn và cần đối soát thủ công.
+	ErrAmountMismatch = errors.New("paid amount does not match transaction amount")
+)
+
+// PaymentProvider là cổng thanh toán dùng chung cho API /payments/:provider và luồng hoàn tiền.
+// Thêm cổng mới chỉ cần cài đặt interface này và đăng ký vào PaymentProviderRegistry trong main.
+type PaymentProvider interface {
+	Method() model.PaymentMethod
+	// CreatePayment tạo hoá đơn PENDING và yêu cầu thanh toán trên cổng.
+	CreatePayment(ctx context.Context, req model.ProviderPaymentRequest) (*model.ProviderPaymentResponse, error)
+	// HandleCallback xác thực và áp dụng redirect / IPN của cổng.
+	// Result khác nil thì controller trả result.Ack cho cổng kể cả khi có lỗi; result nil nghĩa là lỗi hệ thống.
+	HandleCallback(ctx context.Context, callback model.ProviderCallback) (*model.ProviderCallbackResult, error)
+	// QueryPayment hỏi trạng thái giao dịch của hoá đơn trực tiếp từ cổng.
+	QueryPayment(ctx context.Context, invoice db.Invoice) (*model.ProviderPaymentStatus, error)
+	// Refund hoàn amount trên cổng và trả về mã tham chiếu của lần hoàn; không cập nhật hoá đơn.
+	Refund(ctx context.Context, invoice db.Invoice, req model.ProviderRefundRequest) (string, error)
+}
+
+// PaymentProviderRegistry tra cứu PaymentProvider theo phương thức thanh toán
+type PaymentProviderRegistry struct {
+	mu        sync.RWMutex
+	providers map[model.PaymentMethod]PaymentProvider
+}
+
+func NewPaymentProviderRegistry(providers ...PaymentProvider) *PaymentProviderRegistry {
+	r := &PaymentProviderRegistry{providers: make(map[model.PaymentMethod]PaymentProvider, len(providers))}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+// Register thêm (hoặc thay thế) provider cho phương thức thanh toán của nó
+func (r *PaymentProviderRegistry) Register(p PaymentProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[p.Method()] = p
+}
+
+func (r *PaymentProviderRegistry) Get(method model.PaymentMethod) (PaymentProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.providers[method]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPaymentProvider, method)
+	}
+	return p, nil
+}
+
+// Methods trả về danh sách phương thức đã đăng ký, sắp xếp theo tên
+func (r *PaymentProviderRegistry) Methods() []model.PaymentMethod {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	methods := make([]model.PaymentMethod, 0, len(r.providers))
+	for m := range r.providers {
+		methods = append(methods, m)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i] < methods[j] })
+	return methods
+}
+
+// providerOutcome là kết quả thanh toán cổng báo về cho một giao dịch
+type providerOutcome struct {
+	TxnRef        string
+	ProviderTxnID string
+	Amount        float64
+	Success       bool
+	PaidAt        time.Time
+	Reason        string
+}
+
+// settleProviderPayment áp dụng kết quả cổng báo về lên hoá đơn của giao dịch (dùng cho MoMo, ZaloPay).
+func settleProviderPayment(ctx context.Context, invoiceSvc InvoiceServiceInterface, method model.PaymentMethod, outcome providerOutcome) (db.Invoice, error) {
+	if !outcome.Success {
+		return invoiceSvc.UpdateInvoiceStatusForPaymentFailure(ctx, outcome.TxnRef, method, outcome.Reason)
+	}
+
+	txn, err := invoiceSvc.GetPaymentTransaction(ctx, outcome.TxnRef)
+	if err != nil {
+		return db.Invoice{}, err
+	}
+	if math.Abs(txn.Amount-outcome.Amount) >= 0.01 {
+		log.Printf("CRITICAL: %s transaction %s paid %.2f, expected %.2f (invoice %s). Manual reconciliation required.",
+			method, outcome.TxnRef, outcome.Amount, txn.Amount, txn.InvoiceID)
+		return db.Invoice{}, fmt.Errorf("service: %s transaction %s: %w", method, outcome.TxnRef, ErrAmountMismatch)
+	}
+	return invoiceSvc.UpdateInvoiceStatusForProviderSuccess(ctx, outcome.TxnRef, outcome.ProviderTxnID, outcome.PaidAt)
+}
+
+// paymentStatusFromInvoice chuyển trạng thái hoá đơn nội bộ thành ProviderPaymentStatus (dùng khi cổng không có API truy vấn)
+func paymentStatusFromInvoice(method model.PaymentMethod, invoice db.Invoice, txnRef string) *model.ProviderPaymentStatus {
+	status := &model.ProviderPaymentStatus{
+		Provider:  method,
+		InvoiceID: invoice.InvoiceID,
+		TxnRef:    txnRef,
+		Status:    model.PaymentStatus(invoice.PaymentStatus.String),
+		Amount:    invoice.FinalAmount,
+	}
+	if status.Status == model.PaymentStatusCompleted && invoice.UpdatedAt.Valid {
+		paidAt := invoice.UpdatedAt.Time
+		status.PaidAt = &paidAt
+	}
+	return status
+}
@@ -0,0 +1,383 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"payment_service/config"
+	"payment_service/domain/model"
+	"payment_service/internal/db"
+	"payment_service/pkg/momo"
+	"payment_service/pkg/zalopay"
+)
+
+// Dữ liệu mẫu dùng chung với pkg/momo và pkg/zalopay (khoá sandbox công khai của cổng);
+// chữ ký / MAC được tính độc lập bằng openssl dgst -sha256 -hmac.
+var (
+	testMoMoConfig = &config.MoMoConfig{
+		PartnerCode: "MOMO",
+		AccessKey:   "F8BBA842ECF85",
+		SecretKey:   "K951B6PE1waDMi640xX08PD3vg6EkVlz",
+	}
+	testZaloPayConfig = &config.ZaloPayConfig{
+		AppID: 2553,
+		Key1:  "PcY4iZIKFCIdgZvA6ueMcMHHUbRLYjPL",
+		Key2:  "kLtgPl8HHhfvMuDHPwKfgfsY4Ydm9eIz",
+	}
+)
+
+const (
+	momoSampleIPN = `{"partnerCode":"MOMO","orderId":"MM1540456472575","requestId":"MM1540456472575","amount":50000,` +
+		`"orderInfo":"SDK team.","orderType":"momo_wallet","transId":2588659987,"resultCode":0,"message":"Successful.",` +
+		`"payType":"qr","responseTime":1540456472575,"extraData":"",` +
+		`"signature":"340d42991952e7bdbd565ce19f8087f46b14b4d586a9b8af0251b3b9f5b121ef"}`
+
+	zaloPaySampleData = `{"app_id":2553,"app_trans_id":"190613_123456","app_time":1560397946000,"app_user":"user123",` +
+		`"amount":50000,"embed_data":"{}","item":"[]","zp_trans_id":190613000000163,"server_time":1560397990000,` +
+		`"channel":38,"merchant_user_id":"rSVW3Ab0Yt2NFnqu6v0pdiyDs2eT6Z8_Lt3Kj-lhtPo","user_fee_amount":0,"discount_amount":0}`
+	zaloPaySampleMac = "cf220d37f62f9b42ce0971112312eeb91f1819bff6ad83c35a323156528a4a33"
+)
+
+// fakeInvoiceService chỉ cài các hàm settleProviderPayment dùng; gọi hàm khác sẽ panic.
+type fakeInvoiceService struct {
+	InvoiceServiceInterface
+	txn     db.PaymentTransaction
+	settled []string // providerTxnID của các lần UpdateInvoiceStatusForProviderSuccess
+	failed  []string // reason của các lần UpdateInvoiceStatusForPaymentFailure
+}
+
+func newFakeInvoiceService(txnRef string, amount float64) *fakeInvoiceService {
+	return &fakeInvoiceService{txn: db.PaymentTransaction{TxnRef: txnRef, InvoiceID: uuid.New(), Amount: amount}}
+}
+
+func (f *fakeInvoiceService) GetPaymentTransaction(_ context.Context, txnRef string) (db.PaymentTransaction, error) {
+	if txnRef != f.txn.TxnRef {
+		return db.PaymentTransaction{}, fmt.Errorf("transaction %s: %w", txnRef, sql.ErrNoRows)
+	}
+	return f.txn, nil
+}
+
+func (f *fakeInvoiceService) UpdateInvoiceStatusForProviderSuccess(_ context.Context, _, providerTxnID string, _ time.Time) (db.Invoice, error) {
+	f.settled = append(f.settled, providerTxnID)
+	return f.invoice(model.PaymentStatusCompleted), nil
+}
+
+func (f *fakeInvoiceService) UpdateInvoiceStatusForPaymentFailure(_ context.Context, _ string, _ model.PaymentMethod, reason string) (db.Invoice, error) {
+	f.failed = append(f.failed, reason)
+	return f.invoice(model.PaymentStatusFailed), nil
+}
+
+func (f *fakeInvoiceService) invoice(status model.PaymentStatus) db.Invoice {
+	return db.Invoice{InvoiceID: f.txn.InvoiceID, PaymentStatus: sql.NullString{String: string(status), Valid: true}}
+}
+
+// momoSampleNotification đọc IPN mẫu và áp dụng mutate; resign ký lại sau khi sửa (giả lập MoMo gửi kết quả khác).
+func momoSampleNotification(t *testing.T, mutate func(n *momo.Notification), resign bool) momo.Notification {
+	t.Helper()
+	var n momo.Notification
+	if err := json.Unmarshal([]byte(momoSampleIPN), &n); err != nil {
+		t.Fatal(err)
+	}
+	if mutate != nil {
+		mutate(&n)
+	}
+	if resign {
+		n.Signature = momo.Sign(testMoMoConfig.SecretKey, n.RawSignature(testMoMoConfig.AccessKey))
+	}
+	return n
+}
+
+func momoIPN(t *testing.T, n momo.Notification) model.ProviderCallback {
+	t.Helper()
+	body, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model.ProviderCallback{Kind: model.ProviderCallbackIPN, Body: body}
+}
+
+func TestMoMoProviderHandleCallback(t *testing.T) {
+	tests := []struct {
+		name          string
+		callback      func(t *testing.T) model.ProviderCallback
+		txnAmount     float64
+		wantErr       error
+		wantAnyErr    bool
+		wantStatus    model.PaymentStatus
+		wantAckStatus int
+		wantSettled   int
+		wantFailed    int
+	}{
+		{
+			name: "IPN sample vector settles invoice",
+			callback: func(t *testing.T) model.ProviderCallback {
+				return model.ProviderCallback{Kind: model.ProviderCallbackIPN, Body: []byte(momoSampleIPN)}
+			},
+			txnAmount:     50000,
+			wantStatus:    model.PaymentStatusCompleted,
+			wantAckStatus: http.StatusNoContent,
+			wantSettled:   1,
+		},
+		{
+			name: "redirect sample vector settles invoice",
+			callback: func(t *testing.T) model.ProviderCallback {
+				return model.ProviderCallback{Kind: model.ProviderCallbackReturn, Query: momoSampleNotification(t, nil, false).Values()}
+			},
+			txnAmount:   50000,
+			wantStatus:  model.PaymentStatusCompleted,
+			wantSettled: 1,
+		},
+		{
+			name: "IPN with tampered amount",
+			callback: func(t *testing.T) model.ProviderCallback {
+				return momoIPN(t, momoSampleNotification(t, func(n *momo.Notification) { n.Amount = 5000 }, false))
+			},
+			txnAmount:     50000,
+			wantErr:       ErrInvalidSignature,
+			wantAckStatus: http.StatusBadRequest,
+		},
+		{
+			name: "redirect with tampered resultCode",
+			callback: func(t *testing.T) model.ProviderCallback {
+				query := momoSampleNotification(t, nil, false).Values()
+				query.Set("resultCode", "1006")
+				return model.ProviderCallback{Kind: model.ProviderCallbackReturn, Query: query}
+			},
+			txnAmount:     50000,
+			wantErr:       ErrInvalidSignature,
+			wantAckStatus: http.StatusBadRequest,
+		},
+		{
+			name: "IPN signed with another secret",
+			callback: func(t *testing.T) model.ProviderCallback {
+				n := momoSampleNotification(t, nil, false)
+				n.Signature = momo.Sign("another-secret", n.RawSignature(testMoMoConfig.AccessKey))
+				return momoIPN(t, n)
+			},
+			txnAmount:     50000,
+			wantErr:       ErrInvalidSignature,
+			wantAckStatus: http.StatusBadRequest,
+		},
+		{
+			name: "IPN pending result does not touch invoice",
+			callback: func(t *testing.T) model.ProviderCallback {
+				return momoIPN(t, momoSampleNotification(t, func(n *momo.Notification) { n.ResultCode = momo.ResultCodePending }, true))
+			},
+			txnAmount:     50000,
+			wantStatus:    model.PaymentStatusPending,
+			wantAckStatus: http.StatusNoContent,
+		},
+		{
+			name: "IPN failed result marks invoice failed",
+			callback: func(t *testing.T) model.ProviderCallback {
+				return momoIPN(t, momoSampleNotification(t, func(n *momo.Notification) {
+					n.ResultCode = 1006
+					n.Message = "Transaction denied by user."
+				}, true))
+			},
+			txnAmount:     50000,
+			wantStatus:    model.PaymentStatusFailed,
+			wantAckStatus: http.StatusNoContent,
+			wantFailed:    1,
+		},
+		{
+			name: "IPN valid signature but amount differs from transaction",
+			callback: func(t *testing.T) model.ProviderCallback {
+				return model.ProviderCallback{Kind: model.ProviderCallbackIPN, Body: []byte(momoSampleIPN)}
+			},
+			txnAmount:     60000,
+			wantErr:       ErrAmountMismatch,
+			wantAckStatus: http.StatusNoContent,
+		},
+		{
+			name: "IPN malformed body",
+			callback: func(t *testing.T) model.ProviderCallback {
+				return model.ProviderCallback{Kind: model.ProviderCallbackIPN, Body: []byte("not-json")}
+			},
+			txnAmount:     50000,
+			wantAnyErr:    true,
+			wantAckStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoiceSvc := newFakeInvoiceService("MM1540456472575", tt.txnAmount)
+			provider := NewMoMoProvider(momo.NewClient(testMoMoConfig, http.DefaultClient), invoiceSvc)
+
+			result, err := provider.HandleCallback(context.Background(), tt.callback(t))
+			checkCallbackErr(t, err, tt.wantErr, tt.wantAnyErr)
+			if result == nil {
+				t.Fatal("HandleCallback() result = nil, want ack for MoMo")
+			}
+			if result.Status != tt.wantStatus || result.AckStatus != tt.wantAckStatus {
+				t.Fatalf("HandleCallback() status = %q, ack status = %d; want %q, %d",
+					result.Status, result.AckStatus, tt.wantStatus, tt.wantAckStatus)
+			}
+			if len(invoiceSvc.settled) != tt.wantSettled || len(invoiceSvc.failed) != tt.wantFailed {
+				t.Fatalf("invoice settled %v, failed %v; want %d settled, %d failed",
+					invoiceSvc.settled, invoiceSvc.failed, tt.wantSettled, tt.wantFailed)
+			}
+			if tt.wantSettled > 0 && invoiceSvc.settled[0] != "2588659987" {
+				t.Fatalf("settled providerTxnID = %s, want MoMo transId 2588659987", invoiceSvc.settled[0])
+			}
+		})
+	}
+}
+
+func zaloPayIPN(t *testing.T, data, mac string) model.ProviderCallback {
+	t.Helper()
+	body, err := json.Marshal(zalopay.CallbackRequest{Data: data, Mac: mac, Type: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model.ProviderCallback{Kind: model.ProviderCallbackIPN, Body: body}
+}
+
+func TestZaloPayProviderHandleCallback(t *testing.T) {
+	sampleRedirect := url.Values{
+		"appid":          {"2553"},
+		"apptransid":     {"190613_123456"},
+		"pmcid":          {"38"},
+		"bankcode":       {"zalopayapp"},
+		"amount":         {"50000"},
+		"discountamount": {"0"},
+		"status":         {"1"},
+		"checksum":       {"475563832bcb15c0ce26fca04c470460ea1983619485ea173a2d4dc5a1bb2697"},
+	}
+
+	tests := []struct {
+		name        string
+		callback    func(t *testing.T) model.ProviderCallback
+		txnAmount   float64
+		wantErr     error
+		wantAnyErr  bool
+		wantStatus  model.PaymentStatus
+		wantAckCode *int // nil: không có ack (redirect)
+		wantSettled int
+	}{
+		{
+			name:        "callback sample vector settles invoice",
+			callback:    func(t *testing.T) model.ProviderCallback { return zaloPayIPN(t, zaloPaySampleData, zaloPaySampleMac) },
+			txnAmount:   50000,
+			wantStatus:  model.PaymentStatusCompleted,
+			wantAckCode: ackCode(zalopay.CallbackCodeSuccess),
+			wantSettled: 1,
+		},
+		{
+			name: "callback mac in uppercase",
+			callback: func(t *testing.T) model.ProviderCallback {
+				return zaloPayIPN(t, zaloPaySampleData, strings.ToUpper(zaloPaySampleMac))
+			},
+			txnAmount:   50000,
+			wantStatus:  model.PaymentStatusCompleted,
+			wantAckCode: ackCode(zalopay.CallbackCodeSuccess),
+			wantSettled: 1,
+		},
+		{
+			name: "callback with tampered amount",
+			callback: func(t *testing.T) model.ProviderCallback {
+				return zaloPayIPN(t, strings.Replace(zaloPaySampleData, `"amount":50000`, `"amount":5000`, 1), zaloPaySampleMac)
+			},
+			txnAmount:   50000,
+			wantErr:     ErrInvalidSignature,
+			wantAckCode: ackCode(zalopay.CallbackCodeInvalidMac),
+		},
+		{
+			name: "callback signed with key1",
+			callback: func(t *testing.T) model.ProviderCallback {
+				return zaloPayIPN(t, zaloPaySampleData, zalopay.Mac(testZaloPayConfig.Key1, zaloPaySampleData))
+			},
+			txnAmount:   50000,
+			wantErr:     ErrInvalidSignature,
+			wantAckCode: ackCode(zalopay.CallbackCodeInvalidMac),
+		},
+		{
+			name: "callback valid mac but amount differs from transaction",
+			callback: func(t *testing.T) model.ProviderCallback {
+				return zaloPayIPN(t, zaloPaySampleData, zaloPaySampleMac)
+			},
+			txnAmount:   60000,
+			wantErr:     ErrAmountMismatch,
+			wantAckCode: ackCode(zalopay.CallbackCodeSuccess),
+		},
+		{
+			name: "callback malformed body",
+			callback: func(t *testing.T) model.ProviderCallback {
+				return model.ProviderCallback{Kind: model.ProviderCallbackIPN, Body: []byte("not-json")}
+			},
+			txnAmount:   50000,
+			wantAnyErr:  true,
+			wantAckCode: ackCode(zalopay.CallbackCodeInvalidMac),
+		},
+		{
+			name: "redirect with tampered status",
+			callback: func(t *testing.T) model.ProviderCallback {
+				query := url.Values{}
+				for k, v := range sampleRedirect {
+					query[k] = v
+				}
+				query.Set("status", "-49")
+				return model.ProviderCallback{Kind: model.ProviderCallbackReturn, Query: query}
+			},
+			txnAmount: 50000,
+			wantErr:   ErrInvalidSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoiceSvc := newFakeInvoiceService("190613_123456", tt.txnAmount)
+			provider := NewZaloPayProvider(zalopay.NewClient(testZaloPayConfig, http.DefaultClient), invoiceSvc)
+
+			result, err := provider.HandleCallback(context.Background(), tt.callback(t))
+			checkCallbackErr(t, err, tt.wantErr, tt.wantAnyErr)
+			if result == nil {
+				t.Fatal("HandleCallback() result = nil, want ack for ZaloPay")
+			}
+			if result.Status != tt.wantStatus {
+				t.Fatalf("HandleCallback() status = %q, want %q", result.Status, tt.wantStatus)
+			}
+			ack, _ := result.Ack.(zalopay.CallbackResponse)
+			if tt.wantAckCode == nil {
+				if result.Ack != nil {
+					t.Fatalf("HandleCallback() ack = %+v, want none", result.Ack)
+				}
+			} else if result.Ack == nil || ack.ReturnCode != *tt.wantAckCode {
+				t.Fatalf("HandleCallback() ack = %+v, want return_code %d", result.Ack, *tt.wantAckCode)
+			}
+			if len(invoiceSvc.settled) != tt.wantSettled {
+				t.Fatalf("invoice settled %v, want %d", invoiceSvc.settled, tt.wantSettled)
+			}
+			if tt.wantSettled > 0 && invoiceSvc.settled[0] != "190613000000163" {
+				t.Fatalf("settled providerTxnID = %s, want zp_trans_id 190613000000163", invoiceSvc.settled[0])
+			}
+		})
+	}
+}
+
+func ackCode(code int) *int { return &code }
+
+func checkCallbackErr(t *testing.T, err, wantErr error, wantAnyErr bool) {
+	t.Helper()
+	switch {
+	case wantErr != nil:
+		if !errors.Is(err, wantErr) {
+			t.Fatalf("HandleCallback() err = %v, want %v", err, wantErr)
+		}
+	case wantAnyErr:
+		if err == nil {
+			t.Fatal("HandleCallback() err = nil, want error")
+		}
+	case err != nil:
+		t.Fatalf("HandleCallback() err = %v, want nil", err)
+	}
+}
@@ -5,8 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"math"
 	"payment_service/internal/repository"
+	"payment_service/pkg/currency"
 	"payment_service/pkg/kafkaclient"
 	"strconv"
 	"strings"
@@ -32,7 +32,7 @@ type InvoiceServiceInterface interface {
 	UpdateInvoiceStatusForVNPaySuccess(ctx context.Context, txnRef, vnpBankCode, vnpTxnNo, vnpPayDate string) (db.Invoice, error)
 	UpdateInvoiceStatusForStripeSuccess(ctx context.Context, paymentIntentID, chargeID, paymentMethodDetailsJSON string) (db.Invoice, error)
 	UpdateInvoiceStatusForPaymentFailure(ctx context.Context, identifier string, method model.PaymentMethod, reason string) (db.Invoice, error)
-	UpdateInvoiceStatusForRefund(ctx context.Context, invoiceID uuid.UUID, reason string, refundSpecificIdentifier string) (db.Invoice, error) // Added refundSpecificIdentifier
+	AddInvoiceNote(ctx context.Context, invoiceID uuid.UUID, note string) (db.Invoice, error)
 	UpdateInvoiceStatusForPaymentFailureForUUID(ctx context.Context, identifier uuid.UUID, method model.PaymentMethod, reason string) (db.Invoice, error)
 	GetInvoicesByCustomerID(ctx context.Context, customerID string) ([]db.Invoice, error)
 	MapDbInvoiceToAPIResponse(invoice db.Invoice) model.GetInvoiceResponse
@@ -45,6 +45,27 @@ type InvoiceServiceInterface interface {
 	ConfirmInvoiceForBankPayment(ctx context.Context, req model.BankPaymentConfirmationRequest) (db.Invoice, error)                                       // Assuming this exists
 	MarkInvoiceAsFailedForBankPayment(ctx context.Context, invoiceID uuid.UUID, reason string) (db.Invoice, error)                                        // Assuming this exists
 	ProcessStaffDirectPayment(ctx context.Context, req model.StaffDirectPaymentRequest) (db.Invoice, error)                                               // New Method
+
+	// Thanh toán qua PaymentProvider chung (MoMo, ZaloPay)
+	CreateInvoiceForProvider(ctx context.Context, req model.ProviderPaymentRequest, method model.PaymentMethod, txnRef string) (db.Invoice, error)
+	GetPaymentTransaction(ctx context.Context, txnRef string) (db.PaymentTransaction, error)
+	GetLatestPaymentTransactionByInvoiceID(ctx context.Context, invoiceID uuid.UUID) (db.PaymentTransaction, error)
+	UpdateInvoiceStatusForProviderSuccess(ctx context.Context, txnRef, providerTxnID string, paidAt time.Time) (db.Invoice, error)
+
+	// Sổ hoàn tiền: mỗi lần hoàn (toàn phần hoặc một phần) là một bản ghi refunds của hoá đơn
+	StartRefund(ctx context.Context, req model.InvoiceRefundRequest) (db.Refund, db.Invoice, error)
+	CompleteRefund(ctx context.Context, refundID uuid.UUID, providerReference string) (db.Refund, db.Invoice, error)
+	FailRefund(ctx context.Context, refundID uuid.UUID, reason string) (db.Refund, error)
+	ListRefunds(ctx context.Context, invoiceID uuid.UUID) (model.InvoiceRefundsResponse, error)
+	PriorRefundAmounts(ctx context.Context, invoiceID, refundID uuid.UUID) ([]float64, error)
+	MapDbRefundToAPIResponse(refund db.Refund) model.RefundResponse
+
+	// Voucher: giữ lượt dùng khi tạo hoá đơn, ghi nhận khi thanh toán, trả lại khi hết hạn / thất bại
+	CreateInvoiceWithVoucher(ctx context.Context, params db.CreateInvoiceParams, selection model.VoucherSelection, chargeCurrency string) (db.Invoice, error)
+	AttachStripePaymentIntent(ctx context.Context, invoice db.Invoice, paymentIntentID string) (db.Invoice, error)
+
+	// Đa tiền tệ: hoá đơn lưu tiền tệ giá vé và tiền tệ thực thu cùng tỷ giá đã dùng
+	ConvertAmount(ctx context.Context, amount float64, from, to string) (currency.Conversion, error)
 }
 
 // InvoiceService xử lý logic nghiệp vụ liên quan đến hóa đơn
@@ -52,14 +73,18 @@ type InvoiceService struct {
 	repo        repository.InvoiceRepositoryInterface // Sử dụng interface
 	publisher   *kafkaclient.Publisher                // << THAY ĐỔI: Thay thế URL và http client bằng producer
 	redisClient *redis.Client
+	vouchers    VoucherServiceInterface
+	converter   *currency.Converter // Quy đổi giá vé sang tiền tệ khách thanh toán
 }
 
 // NewInvoiceService tạo một invoice service mới
-func NewInvoiceService(repo repository.InvoiceRepositoryInterface, publisher *kafkaclient.Publisher, redisClient *redis.Client) InvoiceServiceInterface {
+func NewInvoiceService(repo repository.InvoiceRepositoryInterface, publisher *kafkaclient.Publisher, redisClient *redis.Client, vouchers VoucherServiceInterface, converter *currency.Converter) InvoiceServiceInterface {
 	return &InvoiceService{
 		repo:        repo,
 		publisher:   publisher,
 		redisClient: redisClient,
+		vouchers:    vouchers,
+		converter:   converter,
 	}
 }
 
@@ -82,43 +107,14 @@ func floatToDecimalString(f float64) string {
 	return fmt.Sprintf("%.2f", f)
 }
 
-func int64ToDecimalString(amountSmallestUnit int64, currency string) string {
-	isVND := strings.ToLower(currency) == "vnd"
-	if isVND {
-		return fmt.Sprintf("%d.00", amountSmallestUnit)
-	}
-	dollars := amountSmallestUnit / 100
-	cents := amountSmallestUnit % 100
-	return fmt.Sprintf("%d.%02d", dollars, cents)
-}
-
-func int64ToDecimalFloat(amountSmallestUnit int64, currency string) float64 {
-	isVND := strings.ToLower(currency) == "vnd"
-	if isVND {
-		return float64(amountSmallestUnit)
-	}
-	return float64(amountSmallestUnit) / 100.0
-}
-
-// GetAmountInSmallestUnit converts a float amount to its smallest currency unit (e.g., cents).
+// GetAmountInSmallestUnit converts a float amount to its smallest currency unit (e.g., cents) theo ISO 4217.
 func (s *InvoiceService) GetAmountInSmallestUnit(amount float64, currencyCode string) (int64, error) {
-	currency := strings.ToLower(currencyCode)
-	// For currencies like JPY, VND that don't have subunits, or where Stripe expects the amount as is.
-	// Stripe treats VND as a zero-decimal currency.
-	if currency == "vnd" || currency == "jpy" { // Add other zero-decimal currencies if needed
-		return int64(math.Round(amount)), nil
-	}
-	// For currencies with 2 decimal places like USD, EUR
-	return int64(math.Round(amount * 100)), nil
+	return currency.ToMinorUnits(amount, currencyCode)
 }
 
 // ConvertSmallestUnitToFloat converts an amount from smallest unit to float.
 func (s *InvoiceService) ConvertSmallestUnitToFloat(amount int64, currencyCode string) (float64, error) {
-	currency := strings.ToLower(currencyCode)
-	if currency == "vnd" || currency == "jpy" {
-		return float64(amount), nil
-	}
-	return float64(amount) / 100.0, nil
+	return currency.FromMinorUnits(amount, currencyCode)
 }
 
 // CreateInvoiceForVNPay tạo hóa đơn mới cho giao dịch VNPay
@@ -144,7 +140,7 @@ func (s *InvoiceService) CreateInvoiceForVNPay(ctx context.Context, req model.VN
 		VnpayTxnRef:    sql.NullString{String: txnRef, Valid: txnRef != ""},
 	}
 
-	createdInvoice, err := s.repo.CreateInvoice(ctx, params)
+	createdInvoice, err := s.CreateInvoiceWithVoucher(ctx, params, req.VoucherSelection, "")
 	if err != nil {
 		return db.Invoice{}, fmt.Errorf("service: failed to create VNPay invoice: %w", err)
 	}
@@ -186,7 +182,7 @@ func (s *InvoiceService) CreateInvoiceForStripe(ctx context.Context, req model.I
 		StripePaymentIntentID: sql.NullString{String: paymentIntentID, Valid: paymentIntentID != ""},
 	}
 
-	createdInvoice, err := s.repo.CreateInvoice(ctx, params)
+	createdInvoice, err := s.CreateInvoiceWithVoucher(ctx, params, req.VoucherSelection, req.ChargeCurrency)
 	if err != nil {
 		return db.Invoice{}, fmt.Errorf("service: failed to create Stripe invoice: %w", err)
 	}
@@ -261,6 +257,7 @@ func (s *InvoiceService) UpdateInvoiceStatusForVNPaySuccess(ctx context.Context,
 	if err != nil {
 		return db.Invoice{}, fmt.Errorf("service: failed to update invoice for VNPay success (TxnRef: %s): %w", txnRef, err)
 	}
+	s.redeemVoucher(ctx, invoice.InvoiceID)
 
 	if err := s.UpdateTicketStatus(ctx, invoice.TicketID, model.TicketStatusPaid, invoice.InvoiceID); err != nil {
 		log.Printf("Warning: service: failed to update ticket status for invoice %s (ticket %s) after VNPay success: %v", invoice.InvoiceID, invoice.TicketID, err)
@@ -282,6 +279,7 @@ func (s *InvoiceService) UpdateInvoiceStatusForStripeSuccess(ctx context.Context
 	if err != nil {
 		return db.Invoice{}, fmt.Errorf("service: failed to update invoice for Stripe success (PI_ID: %s): %w", paymentIntentID, err)
 	}
+	s.redeemVoucher(ctx, invoice.InvoiceID)
 
 	if err := s.UpdateTicketStatus(ctx, invoice.TicketID, model.TicketStatusPaid, invoice.InvoiceID); err != nil {
 		log.Printf("Warning: service: failed to update ticket status for invoice %s (ticket %s) after Stripe success: %v", invoice.InvoiceID, invoice.TicketID, err)
@@ -345,10 +343,11 @@ func (s *InvoiceService) UpdateInvoiceStatusForPaymentFailureForUUID(ctx context
 
 	err = s.clearInvoiceExpiration(ctx, invoice.InvoiceID.String())
 	if err != nil {
-		log.Printf("Info: %w", err)
+		log.Printf("Info: %v", err)
 	}
 
 	if invoice.PaymentStatus.String == string(model.PaymentStatusCompleted) ||
+		invoice.PaymentStatus.String == string(model.PaymentStatusPartiallyRefunded) ||
 		invoice.PaymentStatus.String == string(model.PaymentStatusRefunded) ||
 		invoice.PaymentStatus.String == string(model.PaymentStatusFailed) {
 		log.Printf("Info: service: invoice %s already in final state %s. Skipping failure update.", invoice.InvoiceID, invoice.PaymentStatus.String)
@@ -365,6 +364,8 @@ func (s *InvoiceService) UpdateInvoiceStatusForPaymentFailureForUUID(ctx context
 	if err != nil {
 		return db.Invoice{}, fmt.Errorf("service: failed to update invoice %s for payment failure (%s): %w", invoice.InvoiceID, method, err)
 	}
+	s.failPaymentTransactions(ctx, updatedInvoice.InvoiceID, reason)
+	s.releaseVoucher(ctx, updatedInvoice.InvoiceID, reason)
 
 	if err := s.UpdateTicketStatus(ctx, updatedInvoice.TicketID, model.TicketStatusFailed, updatedInvoice.InvoiceID); err != nil {
 		log.Printf("Warning: service: failed to update ticket status for invoice %s (ticket %s) after payment failure: %v", updatedInvoice.InvoiceID, updatedInvoice.TicketID, err)
@@ -385,6 +386,11 @@ func (s *InvoiceService) UpdateInvoiceStatusForPaymentFailure(ctx context.Contex
 		invoice, err = s.GetInvoiceByVNPayTxnRef(ctx, identifier)
 	case model.PaymentMethodBank:
 		invoice, err = s.GetInvoiceByBankTransferCode(ctx, identifier)
+	case model.PaymentMethodMoMo, model.PaymentMethodZaloPay:
+		var txn db.PaymentTransaction
+		if txn, err = s.repo.GetPaymentTransactionByTxnRef(ctx, identifier); err == nil {
+			invoice, err = s.GetInvoiceByID(ctx, txn.InvoiceID)
+		}
 	default:
 		return db.Invoice{}, fmt.Errorf("service: unsupported payment method for failure update: %s", method)
 	}
@@ -394,6 +400,7 @@ func (s *InvoiceService) UpdateInvoiceStatusForPaymentFailure(ctx context.Contex
 	}
 
 	if invoice.PaymentStatus.String == string(model.PaymentStatusCompleted) ||
+		invoice.PaymentStatus.String == string(model.PaymentStatusPartiallyRefunded) ||
 		invoice.PaymentStatus.String == string(model.PaymentStatusRefunded) ||
 		invoice.PaymentStatus.String == string(model.PaymentStatusFailed) {
 		log.Printf("Info: service: invoice %s already in final state %s. Skipping failure update.", invoice.InvoiceID, invoice.PaymentStatus.String)
@@ -410,6 +417,8 @@ func (s *InvoiceService) UpdateInvoiceStatusForPaymentFailure(ctx context.Contex
 	if err != nil {
 		return db.Invoice{}, fmt.Errorf("service: failed to update invoice %s for payment failure (%s): %w", invoice.InvoiceID, method, err)
 	}
+	s.failPaymentTransactions(ctx, updatedInvoice.InvoiceID, reason)
+	s.releaseVoucher(ctx, updatedInvoice.InvoiceID, reason)
 
 	if err := s.UpdateTicketStatus(ctx, updatedInvoice.TicketID, model.TicketStatusFailed, updatedInvoice.InvoiceID); err != nil {
 		log.Printf("Warning: service: failed to update ticket status for invoice %s (ticket %s) after payment failure: %v", updatedInvoice.InvoiceID, updatedInvoice.TicketID, err)
@@ -418,35 +427,24 @@ func (s *InvoiceService) UpdateInvoiceStatusForPaymentFailure(ctx context.Contex
 	return updatedInvoice, nil
 }
 
-// UpdateInvoiceStatusForRefund cập nhật trạng thái hóa đơn khi hoàn tiền
-func (s *InvoiceService) UpdateInvoiceStatusForRefund(ctx context.Context, invoiceID uuid.UUID, reason string, refundSpecificIdentifier string) (db.Invoice, error) {
-	invoice, err := s.GetInvoiceByID(ctx, invoiceID)
+// AddInvoiceNote ghi thêm ghi chú vào hoá đơn (giữ nguyên trạng thái), dùng cho điều chỉnh giá vé.
+func (s *InvoiceService) AddInvoiceNote(ctx context.Context, invoiceID uuid.UUID, note string) (db.Invoice, error) {
+	invoice, err := s.repo.GetInvoiceByID(ctx, invoiceID)
 	if err != nil {
-		return db.Invoice{}, fmt.Errorf("service: could not find invoice %s to mark as refunded: %w", invoiceID, err)
-	}
-
-	if invoice.PaymentStatus.String != string(model.PaymentStatusCompleted) {
-		return db.Invoice{}, fmt.Errorf("service: invoice %s is not completed, cannot refund. Current status: %s", invoiceID, invoice.PaymentStatus.String)
+		return db.Invoice{}, fmt.Errorf("service: could not find invoice %s to add note: %w", invoiceID, err)
 	}
 
-	fullReason := reason
-	if refundSpecificIdentifier != "" {
-		fullReason = fmt.Sprintf("Refund ID: %s. Reason: %s", refundSpecificIdentifier, reason)
+	notes := note
+	if invoice.Notes != "" {
+		notes = invoice.Notes + "\n" + note
 	}
-
-	params := db.UpdateInvoiceStatusGeneralParams{
+	updatedInvoice, err := s.repo.UpdateInvoiceStatusGeneral(ctx, db.UpdateInvoiceStatusGeneralParams{
 		InvoiceID:     invoiceID,
-		PaymentStatus: sql.NullString{String: string(model.PaymentStatusRefunded), Valid: true},
-		Notes:         fullReason,
-	}
-
-	updatedInvoice, err := s.repo.UpdateInvoiceStatusGeneral(ctx, params)
+		PaymentStatus: invoice.PaymentStatus,
+		Notes:         notes,
+	})
 	if err != nil {
-		return db.Invoice{}, fmt.Errorf("service: failed to update invoice %s to refunded: %w", invoiceID, err)
-	}
-
-	if err := s.UpdateTicketStatus(ctx, updatedInvoice.TicketID, model.TicketStatusRefunded, updatedInvoice.InvoiceID); err != nil {
-		log.Printf("Warning: service: failed to update ticket status for invoice %s (ticket %s) after refund: %v", updatedInvoice.InvoiceID, updatedInvoice.TicketID, err)
+		return db.Invoice{}, fmt.Errorf("service: failed to add note to invoice %s: %w", invoiceID, err)
 	}
 	return updatedInvoice, nil
 }
@@ -472,7 +470,7 @@ func (s *InvoiceService) UpdateTicketStatus(ctx context.Context, ticketID string
 
 	err := s.clearInvoiceExpiration(ctx, invoiceID.String())
 	if err != nil {
-		log.Printf("Info: %w", err)
+		log.Printf("Info: %v", err)
 	}
 	go func() {
 		bgCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
@@ -521,6 +519,11 @@ func (s *InvoiceService) MapDbInvoiceToAPIResponse(invoice db.Invoice) model.Get
 		issueDateStr = invoice.IssueDate.Time.Format("2006-01-02 15:04:05")
 	}
 
+	var exchangeRateAtStr string
+	if invoice.ExchangeRateAt.Valid {
+		exchangeRateAtStr = invoice.ExchangeRateAt.Time.Format("2006-01-02 15:04:05")
+	}
+
 	return model.GetInvoiceResponse{
 		InvoiceID:                  invoice.InvoiceID,
 		InvoiceNumber:              invoice.InvoiceNumber,
@@ -546,6 +549,11 @@ func (s *InvoiceService) MapDbInvoiceToAPIResponse(invoice db.Invoice) model.Get
 		StripeChargeID:             invoice.StripeChargeID.String,
 		StripeCustomerID:           invoice.StripeCustomerID.String,
 		StripePaymentMethodDetails: invoice.StripePaymentMethodDetails, // db.Invoice.StripePaymentMethodDetails is sql.NullString
+		ChargedCurrency:            invoice.ChargedCurrency.String,
+		ChargedAmount:              decimalStringToFloat(invoice.ChargedAmount),
+		ExchangeRate:               decimalStringToFloat(invoice.ExchangeRate),
+		ExchangeRateSource:         invoice.ExchangeRateSource.String,
+		ExchangeRateAt:             exchangeRateAtStr,
 	}
 }
 
@@ -587,7 +595,7 @@ func (s *InvoiceService) CreateInvoiceForBankPayment(ctx context.Context, req mo
 		BankPaymentDetails: fmt.Sprintf("Due Date: %s", dueDate.Format("2006-01-02")),
 	}
 
-	createdInvoice, err := s.repo.CreateInvoice(ctx, params) //
+	createdInvoice, err := s.CreateInvoiceWithVoucher(ctx, params, req.VoucherSelection, "") //
 	if err != nil {
 		return db.Invoice{}, fmt.Errorf("service: failed to create bank payment invoice: %w", err)
 	}
@@ -667,6 +675,7 @@ func (s *InvoiceService) ConfirmInvoiceForBankPayment(ctx context.Context, req m
 	if err != nil {
 		return db.Invoice{}, fmt.Errorf("service: failed to update invoice for bank payment confirmation (InvoiceID: %s): %w", invoice.InvoiceID, err)
 	}
+	s.redeemVoucher(ctx, updatedInvoice.InvoiceID)
 
 	if err := s.UpdateTicketStatus(ctx, updatedInvoice.TicketID, model.TicketStatusPaid, updatedInvoice.InvoiceID); err != nil { //
 		log.Printf("Warning: service: failed to update ticket status for invoice %s (ticket %s) after bank payment confirmation: %v", updatedInvoice.InvoiceID, updatedInvoice.TicketID, err)
@@ -683,7 +692,7 @@ func (s *InvoiceService) MarkInvoiceAsFailedForBankPayment(ctx context.Context,
 
 	// Check current status to avoid marking already finalized (completed/refunded) or already failed invoices again
 	currentStatus := model.PaymentStatus(invoice.PaymentStatus.String)
-	if currentStatus == model.PaymentStatusCompleted || currentStatus == model.PaymentStatusRefunded || currentStatus == model.PaymentStatusFailed {
+	if currentStatus == model.PaymentStatusCompleted || currentStatus == model.PaymentStatusPartiallyRefunded || currentStatus == model.PaymentStatusRefunded || currentStatus == model.PaymentStatusFailed {
 		log.Printf("Info: service: invoice %s already in a final state (%s). Cannot mark as failed for bank payment.", invoiceID, currentStatus)
 		return invoice, fmt.Errorf("service: invoice %s already in a final state (%s)", invoiceID, currentStatus)
 	}
@@ -703,6 +712,7 @@ func (s *InvoiceService) MarkInvoiceAsFailedForBankPayment(ctx context.Context,
 	if err != nil {
 		return db.Invoice{}, fmt.Errorf("service: failed to update invoice %s to failed for bank payment: %w", invoiceID, err)
 	}
+	s.releaseVoucher(ctx, updatedInvoice.InvoiceID, reason)
 
 	if err := s.UpdateTicketStatus(ctx, updatedInvoice.TicketID, model.TicketStatusFailed, updatedInvoice.InvoiceID); err != nil { //
 		log.Printf("Warning: service: failed to update ticket status for invoice %s (ticket %s) after bank payment failure: %v", updatedInvoice.InvoiceID, updatedInvoice.TicketID, err)
@@ -749,10 +759,11 @@ func (s *InvoiceService) ProcessStaffDirectPayment(ctx context.Context, req mode
 		// VNPay, Stripe, and standard Bank Transfer specific fields will be null/empty by default
 	}
 
-	createdInvoice, err := s.repo.CreateInvoice(ctx, params)
+	createdInvoice, err := s.CreateInvoiceWithVoucher(ctx, params, req.VoucherSelection, "")
 	if err != nil {
 		return db.Invoice{}, fmt.Errorf("service: failed to create staff direct payment invoice: %w", err)
 	}
+	s.redeemVoucher(ctx, createdInvoice.InvoiceID)
 
 	// Update ticket status
 	if err := s.UpdateTicketStatus(ctx, createdInvoice.TicketID, model.TicketStatusPaid, createdInvoice.InvoiceID); err != nil {
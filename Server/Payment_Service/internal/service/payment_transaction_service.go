@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"payment_service/domain/model"
+	"payment_service/internal/db"
+)
+
+// ErrInvoiceNotPending: cổng báo thanh toán thành công nhưng hoá đơn đã đóng (hết hạn / thất bại), cần đối soát và hoàn tiền thủ công.
+var ErrInvoiceNotPending = errors.New("invoice is no longer pending")
+
+// CreateInvoiceForProvider tạo hoá đơn PENDING cho thanh toán qua PaymentProvider cùng giao dịch txnRef gửi sang cổng.
+func (s *InvoiceService) CreateInvoiceForProvider(ctx context.Context, req model.ProviderPaymentRequest, method model.PaymentMethod, txnRef string) (db.Invoice, error) {
+	currency := strings.ToLower(req.Currency)
+	if currency == "" {
+		currency = "vnd"
+	}
+	finalAmount := req.Amount - req.DiscountAmount + req.TaxAmount
+	if finalAmount <= 0 {
+		return db.Invoice{}, fmt.Errorf("service: final amount %.2f must be positive", finalAmount)
+	}
+
+	invoiceParams := db.CreateInvoiceParams{
+		InvoiceID:      uuid.New(),
+		InvoiceNumber:  generateInvoiceNumber(string(method)),
+		InvoiceType:    sql.NullString{String: req.InvoiceType, Valid: req.InvoiceType != ""},
+		CustomerID:     req.CustomerID,
+		TicketID:       req.TicketID,
+		TotalAmount:    req.Amount,
+		DiscountAmount: sql.NullString{String: floatToDecimalString(req.DiscountAmount), Valid: req.DiscountAmount != 0},
+		TaxAmount:      sql.NullString{String: floatToDecimalString(req.TaxAmount), Valid: req.TaxAmount != 0},
+		FinalAmount:    finalAmount,
+		Currency:       sql.NullString{String: currency, Valid: true},
+		PaymentStatus:  sql.NullString{String: string(model.PaymentStatusPending), Valid: true},
+		PaymentMethod:  sql.NullString{String: string(method), Valid: true},
+		IssueDate:      sql.NullTime{Time: time.Now(), Valid: true},
+		Notes:          req.Notes,
+	}
+	txnParams := db.CreatePaymentTransactionParams{
+		TxnRef:   txnRef,
+		Provider: string(method),
+		Amount:   finalAmount,
+	}
+
+	createdInvoice, err := s.createInvoiceWithVoucher(ctx, invoiceParams, req.VoucherSelection, "", func(params db.CreateInvoiceParams) (db.Invoice, error) {
+		txnParams.Amount = params.FinalAmount
+		return s.repo.CreateInvoiceWithPaymentTransaction(ctx, params, txnParams)
+	})
+	if err != nil {
+		return db.Invoice{}, fmt.Errorf("service: failed to create %s invoice: %w", method, err)
+	}
+
+	expiration := 15 * time.Minute
+	if err := s.setInvoiceExpiration(ctx, createdInvoice.InvoiceID.String(), expiration); err != nil {
+		log.Printf("CẢNH BÁO: Không thể set key hết hạn cho hóa đơn %s: %v", createdInvoice.InvoiceID, err)
+	}
+
+	return createdInvoice, nil
+}
+
+// GetPaymentTransaction lấy giao dịch cổng thanh toán theo txnRef
+func (s *InvoiceService) GetPaymentTransaction(ctx context.Context, txnRef string) (db.PaymentTransaction, error) {
+	txn, err := s.repo.GetPaymentTransactionByTxnRef(ctx, txnRef)
+	if err != nil {
+		return db.PaymentTransaction{}, fmt.Errorf("service: %w", err)
+	}
+	return txn, nil
+}
+
+// GetLatestPaymentTransactionByInvoiceID lấy giao dịch cổng thanh toán mới nhất của hoá đơn
+func (s *InvoiceService) GetLatestPaymentTransactionByInvoiceID(ctx context.Context, invoiceID uuid.UUID) (db.PaymentTransaction, error) {
+	txn, err := s.repo.GetLatestPaymentTransactionByInvoiceID(ctx, invoiceID)
+	if err != nil {
+		return db.PaymentTransaction{}, fmt.Errorf("service: %w", err)
+	}
+	return txn, nil
+}
+
+// UpdateInvoiceStatusForProviderSuccess cập nhật hoá đơn khi cổng thanh toán (MoMo, ZaloPay...) báo thành công.
+// Gọi lặp với cùng txnRef (IPN gửi lại, redirect và IPN cùng về) chỉ được áp dụng một lần.
+func (s *InvoiceService) UpdateInvoiceStatusForProviderSuccess(ctx context.Context, txnRef, providerTxnID string, paidAt time.Time) (db.Invoice, error) {
+	txn, err := s.repo.GetPaymentTransactionByTxnRef(ctx, txnRef)
+	if err != nil {
+		return db.Invoice{}, fmt.Errorf("service: %w", err)
+	}
+	invoice, err := s.repo.GetInvoiceByID(ctx, txn.InvoiceID)
+	if err != nil {
+		return db.Invoice{}, fmt.Errorf("service: %w", err)
+	}
+
+	if txn.Status == string(model.PaymentStatusCompleted) {
+		log.Printf("Info: service: %s transaction %s already completed for invoice %s.", txn.Provider, txnRef, invoice.InvoiceID)
+		return invoice, nil
+	}
+	if invoice.PaymentStatus.String != string(model.PaymentStatusPending) {
+		log.Printf("CRITICAL: %s transaction %s (provider txn %s) succeeded, but invoice %s is %s. Manual reconciliation required.",
+			txn.Provider, txnRef, providerTxnID, invoice.InvoiceID, invoice.PaymentStatus.String)
+		return invoice, fmt.Errorf("service: invoice %s is %s: %w", invoice.InvoiceID, invoice.PaymentStatus.String, ErrInvoiceNotPending)
+	}
+
+	notes := fmt.Sprintf("Paid via %s. Transaction: %s, provider transaction: %s.", txn.Provider, txnRef, providerTxnID)
+	if invoice.Notes != "" {
+		notes = invoice.Notes + " | " + notes
+	}
+	updatedInvoice, applied, err := s.repo.CompleteProviderPayment(ctx, db.CompletePaymentTransactionParams{
+		TxnRef:        txnRef,
+		ProviderTxnID: sql.NullString{String: providerTxnID, Valid: providerTxnID != ""},
+		PaidAt:        sql.NullTime{Time: paidAt, Valid: !paidAt.IsZero()},
+	}, sql.NullString{String: string(model.PaymentStatusCompleted), Valid: true}, notes)
+	if err != nil {
+		return db.Invoice{}, fmt.Errorf("service: failed to update invoice for %s success (TxnRef: %s): %w", txn.Provider, txnRef, err)
+	}
+	if !applied {
+		return updatedInvoice, nil
+	}
+	s.redeemVoucher(ctx, updatedInvoice.InvoiceID)
+
+	if err := s.UpdateTicketStatus(ctx, updatedInvoice.TicketID, model.TicketStatusPaid, updatedInvoice.InvoiceID); err != nil {
+		log.Printf("Warning: service: failed to update ticket status for invoice %s (ticket %s) after %s success: %v", updatedInvoice.InvoiceID, updatedInvoice.TicketID, txn.Provider, err)
+	}
+	s.publishSuccessNotification(updatedInvoice)
+	return updatedInvoice, nil
+}
+
+// failPaymentTransactions đóng các giao dịch cổng còn mở của hoá đơn vừa chuyển FAILED để callback đến muộn không được áp dụng.
+func (s *InvoiceService) failPaymentTransactions(ctx context.Context, invoiceID uuid.UUID, reason string) {
+	if err := s.repo.FailPendingPaymentTransactions(ctx, invoiceID, reason); err != nil {
+		log.Printf("Warning: service: %v", err)
+	}
+}
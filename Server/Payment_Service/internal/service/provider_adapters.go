@@ -0,0 +1,328 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stripe/stripe-go/v76"
+
+	"payment_service/domain/model"
+	"payment_service/internal/db"
+	"payment_service/pkg/currency"
+)
+
+// Các adapter dưới đây đưa VNPay, Stripe và chuyển khoản ngân hàng vào PaymentProviderRegistry,
+// logic thanh toán vẫn nằm trong VNPayService / StripeService / BankService.
+
+// VNPayProvider bọc VNPayService
+type VNPayProvider struct {
+	vnpaySvc *VNPayService
+}
+
+func NewVNPayProvider(vnpaySvc *VNPayService) PaymentProvider {
+	return &VNPayProvider{vnpaySvc: vnpaySvc}
+}
+
+func (p *VNPayProvider) Method() model.PaymentMethod {
+	return model.PaymentMethodVNPay
+}
+
+func (p *VNPayProvider) CreatePayment(ctx context.Context, req model.ProviderPaymentRequest) (*model.ProviderPaymentResponse, error) {
+	if req.Currency != "" && !strings.EqualFold(req.Currency, "vnd") {
+		return nil, fmt.Errorf("vnpay: currency %s is not supported, only VND", req.Currency)
+	}
+	language := req.Language
+	if language == "" {
+		language = "vn"
+	}
+	resp, err := p.vnpaySvc.CreatePayment(ctx, model.VNPayPaymentRequest{
+		Amount:           req.Amount,
+		BankCode:         req.BankCode,
+		Language:         language,
+		InvoiceType:      req.InvoiceType,
+		CustomerID:       req.CustomerID,
+		TicketID:         req.TicketID,
+		DiscountAmount:   req.DiscountAmount,
+		TaxAmount:        req.TaxAmount,
+		Notes:            req.Notes,
+		VoucherSelection: req.VoucherSelection,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.ProviderPaymentResponse{
+		Provider:   model.PaymentMethodVNPay,
+		InvoiceID:  resp.InvoiceID,
+		TxnRef:     resp.TxnRef,
+		PaymentURL: resp.PaymentURL,
+	}, nil
+}
+
+func (p *VNPayProvider) HandleCallback(ctx context.Context, callback model.ProviderCallback) (*model.ProviderCallbackResult, error) {
+	if callback.Kind == model.ProviderCallbackIPN {
+		ipnResp, err := p.vnpaySvc.ProcessIPN(ctx, callback.Query)
+		if err != nil {
+			return &model.ProviderCallbackResult{
+				Provider: model.PaymentMethodVNPay,
+				TxnRef:   callback.Query.Get("vnp_TxnRef"),
+				Message:  err.Error(),
+				Ack:      model.VNPayIPNResponse{RspCode: "99", Message: "Internal Server Error"},
+			}, err
+		}
+		return &model.ProviderCallbackResult{
+			Provider: model.PaymentMethodVNPay,
+			TxnRef:   callback.Query.Get("vnp_TxnRef"),
+			Message:  ipnResp.Message,
+			Ack:      ipnResp,
+		}, nil
+	}
+
+	returnResp, err := p.vnpaySvc.ProcessReturn(ctx, callback.Query)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.ProviderCallbackResult{
+		Provider:      model.PaymentMethodVNPay,
+		InvoiceID:     returnResp.InvoiceID,
+		TxnRef:        returnResp.TransactionRef,
+		ProviderTxnID: returnResp.TransactionNo,
+		Message:       returnResp.Message,
+	}
+	if !returnResp.IsValid {
+		return result, ErrInvalidSignature
+	}
+	if returnResp.ResponseCode == "00" {
+		result.Status = model.PaymentStatusCompleted
+	} else {
+		result.Status = model.PaymentStatusFailed
+	}
+	return result, nil
+}
+
+func (p *VNPayProvider) QueryPayment(ctx context.Context, invoice db.Invoice) (*model.ProviderPaymentStatus, error) {
+	if !invoice.VnpayTxnRef.Valid || invoice.VnpayTxnRef.String == "" {
+		return nil, fmt.Errorf("vnpay query: invoice %s has no vnp_TxnRef", invoice.InvoiceID)
+	}
+	transactionDate := invoice.IssueDate.Time.Format("20060102150405")
+	if invoice.VnpayPayDate.Valid && invoice.VnpayPayDate.String != "" {
+		transactionDate = invoice.VnpayPayDate.String
+	}
+
+	resp, err := p.vnpaySvc.SubmitQuery(ctx, model.VNPayQueryRequest{
+		TxnRef:          invoice.VnpayTxnRef.String,
+		TransactionDate: transactionDate,
+	}, "127.0.0.1")
+	if err != nil {
+		return nil, err
+	}
+
+	amountInt, _ := strconv.ParseInt(resp["vnp_Amount"], 10, 64)
+	status := &model.ProviderPaymentStatus{
+		Provider:      model.PaymentMethodVNPay,
+		InvoiceID:     invoice.InvoiceID,
+		TxnRef:        invoice.VnpayTxnRef.String,
+		ProviderTxnID: resp["vnp_TransactionNo"],
+		Amount:        float64(amountInt) / 100.0,
+		Message:       resp["vnp_Message"],
+	}
+	switch resp["vnp_TransactionStatus"] {
+	case "00":
+		status.Status = model.PaymentStatusCompleted
+		if paidAt, err := time.ParseInLocation("20060102150405", resp["vnp_PayDate"], time.Local); err == nil {
+			status.PaidAt = &paidAt
+		}
+	case "01":
+		status.Status = model.PaymentStatusPending
+	default:
+		status.Status = model.PaymentStatusFailed
+	}
+	return status, nil
+}
+
+func (p *VNPayProvider) Refund(ctx context.Context, invoice db.Invoice, req model.ProviderRefundRequest) (string, error) {
+	return p.vnpaySvc.RequestRefund(ctx, invoice, req.Amount, req.RequestedBy, "127.0.0.1", req.Reason)
+}
+
+// StripeProvider bọc StripeServiceInterface
+type StripeProvider struct {
+	stripeSvc  StripeServiceInterface
+	invoiceSvc InvoiceServiceInterface
+}
+
+func NewStripeProvider(stripeSvc StripeServiceInterface, invoiceSvc InvoiceServiceInterface) PaymentProvider {
+	return &StripeProvider{stripeSvc: stripeSvc, invoiceSvc: invoiceSvc}
+}
+
+func (p *StripeProvider) Method() model.PaymentMethod {
+	return model.PaymentMethodStripe
+}
+
+func (p *StripeProvider) CreatePayment(ctx context.Context, req model.ProviderPaymentRequest) (*model.ProviderPaymentResponse, error) {
+	fareCurrency := currency.Normalize(req.Currency)
+	if fareCurrency == "" {
+		fareCurrency = "vnd"
+	}
+	if _, err := currency.Lookup(fareCurrency); err != nil {
+		return nil, fmt.Errorf("stripe: %w", err)
+	}
+	if req.ChargeCurrency != "" {
+		if _, err := currency.Lookup(req.ChargeCurrency); err != nil {
+			return nil, fmt.Errorf("stripe: charge %w", err)
+		}
+	}
+	amount, _ := p.invoiceSvc.GetAmountInSmallestUnit(req.Amount, fareCurrency)
+	discount, _ := p.invoiceSvc.GetAmountInSmallestUnit(req.DiscountAmount, fareCurrency)
+	tax, _ := p.invoiceSvc.GetAmountInSmallestUnit(req.TaxAmount, fareCurrency)
+
+	// Stripe thu final_amount của hoá đơn, được tính từ số gốc / giảm giá / voucher / thuế khi tạo hoá đơn,
+	// quy đổi sang ChargeCurrency (nếu có) theo tỷ giá được snapshot trên hoá đơn
+	resp, err := p.stripeSvc.CreatePaymentIntent(ctx, model.InitialStripePaymentRequest{
+		Amount:           amount,
+		Currency:         fareCurrency,
+		ChargeCurrency:   req.ChargeCurrency,
+		InvoiceType:      req.InvoiceType,
+		CustomerID:       req.CustomerID,
+		TicketID:         req.TicketID,
+		Notes:            req.Notes,
+		DiscountAmount:   discount,
+		TaxAmount:        tax,
+		VoucherSelection: req.VoucherSelection,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.ProviderPaymentResponse{
+		Provider:     model.PaymentMethodStripe,
+		InvoiceID:    resp.InvoiceID,
+		TxnRef:       resp.PaymentIntentID,
+		ClientSecret: resp.ClientSecret,
+		Details:      map[string]string{"publishable_key": resp.PublishableKey},
+	}, nil
+}
+
+// HandleCallback: RETURN là redirect sau 3-D Secure (?payment_intent=pi_...), IPN là webhook Stripe.
+func (p *StripeProvider) HandleCallback(ctx context.Context, callback model.ProviderCallback) (*model.ProviderCallbackResult, error) {
+	if callback.Kind == model.ProviderCallbackIPN {
+		if err := p.stripeSvc.HandleWebhook(ctx, callback.Body, callback.Header.Get("Stripe-Signature")); err != nil {
+			return &model.ProviderCallbackResult{
+				Provider:  model.PaymentMethodStripe,
+				Message:   err.Error(),
+				Ack:       map[string]string{"error": "webhook processing failed"},
+				AckStatus: http.StatusBadRequest,
+			}, err
+		}
+		return &model.ProviderCallbackResult{
+			Provider: model.PaymentMethodStripe,
+			Message:  "received",
+			Ack:      map[string]bool{"received": true},
+		}, nil
+	}
+
+	paymentIntentID := callback.Query.Get("payment_intent")
+	if paymentIntentID == "" {
+		return &model.ProviderCallbackResult{Provider: model.PaymentMethodStripe, Message: "payment_intent is required"},
+			fmt.Errorf("stripe: missing payment_intent in return query")
+	}
+	invoice, err := p.stripeSvc.ConfirmPayment(ctx, paymentIntentID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ProviderCallbackResult{
+		Provider:      model.PaymentMethodStripe,
+		InvoiceID:     invoice.InvoiceID,
+		TxnRef:        paymentIntentID,
+		ProviderTxnID: invoice.StripeChargeID.String,
+		Status:        model.PaymentStatus(invoice.PaymentStatus.String),
+		Message:       "Payment successful",
+	}, nil
+}
+
+func (p *StripeProvider) QueryPayment(ctx context.Context, invoice db.Invoice) (*model.ProviderPaymentStatus, error) {
+	if !invoice.StripePaymentIntentID.Valid || invoice.StripePaymentIntentID.String == "" {
+		return nil, fmt.Errorf("stripe query: invoice %s does not have a Stripe PaymentIntentID", invoice.InvoiceID)
+	}
+	pi, err := p.stripeSvc.GetPaymentIntent(ctx, invoice.StripePaymentIntentID.String)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, _ := p.invoiceSvc.ConvertSmallestUnitToFloat(pi.AmountReceived, string(pi.Currency))
+	status := &model.ProviderPaymentStatus{
+		Provider:  model.PaymentMethodStripe,
+		InvoiceID: invoice.InvoiceID,
+		TxnRef:    pi.ID,
+		Amount:    amount,
+		Message:   string(pi.Status),
+	}
+	if pi.LatestCharge != nil {
+		status.ProviderTxnID = pi.LatestCharge.ID
+	}
+	switch pi.Status {
+	case stripe.PaymentIntentStatusSucceeded:
+		status.Status = model.PaymentStatusCompleted
+	case stripe.PaymentIntentStatusRequiresAction:
+		status.Status = model.PaymentStatusRequiresAction
+	case stripe.PaymentIntentStatusCanceled:
+		status.Status = model.PaymentStatusFailed
+	default:
+		status.Status = model.PaymentStatusPending
+	}
+	return status, nil
+}
+
+func (p *StripeProvider) Refund(ctx context.Context, invoice db.Invoice, req model.ProviderRefundRequest) (string, error) {
+	return p.stripeSvc.RefundAmount(ctx, invoice, req)
+}
+
+// BankProvider bọc BankServiceInterface; xác nhận chuyển khoản đi qua API /bank riêng nên không có callback.
+type BankProvider struct {
+	bankSvc BankServiceInterface
+}
+
+func NewBankProvider(bankSvc BankServiceInterface) PaymentProvider {
+	return &BankProvider{bankSvc: bankSvc}
+}
+
+func (p *BankProvider) Method() model.PaymentMethod {
+	return model.PaymentMethodBank
+}
+
+func (p *BankProvider) CreatePayment(ctx context.Context, req model.ProviderPaymentRequest) (*model.ProviderPaymentResponse, error) {
+	currency := req.Currency
+	if currency == "" {
+		currency = "VND"
+	}
+	resp, err := p.bankSvc.CreateBankPaymentRequest(ctx, model.InitialBankPaymentRequest{
+		CustomerID:       req.CustomerID,
+		TicketID:         req.TicketID,
+		Amount:           req.Amount - req.DiscountAmount + req.TaxAmount,
+		Currency:         currency,
+		InvoiceType:      req.InvoiceType,
+		VoucherSelection: req.VoucherSelection,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.ProviderPaymentResponse{
+		Provider:  model.PaymentMethodBank,
+		InvoiceID: resp.InvoiceID,
+		TxnRef:    resp.BankTransferCode,
+		Details:   resp,
+	}, nil
+}
+
+func (p *BankProvider) HandleCallback(ctx context.Context, callback model.ProviderCallback) (*model.ProviderCallbackResult, error) {
+	return nil, fmt.Errorf("bank: %w", ErrCallbackNotSupported)
+}
+
+func (p *BankProvider) QueryPayment(ctx context.Context, invoice db.Invoice) (*model.ProviderPaymentStatus, error) {
+	return paymentStatusFromInvoice(model.PaymentMethodBank, invoice, invoice.BankTransferCode.String), nil
+}
+
+func (p *BankProvider) Refund(ctx context.Context, invoice db.Invoice, req model.ProviderRefundRequest) (string, error) {
+	return p.bankSvc.CreditRefund(ctx, invoice, req)
+}
@@ -0,0 +1,591 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stripe/stripe-go/v76"
+
+	"payment_service/domain/model"
+	"payment_service/internal/db"
+	"payment_service/internal/repository"
+)
+
+var (
+	// ErrReconciliationNotSupported: cổng thanh toán chưa hỗ trợ đối soát
+	ErrReconciliationNotSupported = errors.New("reconciliation is not supported for this provider")
+	// ErrInvalidSettlementFile: file quyết toán thiếu cột bắt buộc hoặc có dòng không đọc được
+	ErrInvalidSettlementFile = errors.New("invalid settlement file")
+	// ErrReconciliationItemResolved: chênh lệch đã được xử lý trước đó
+	ErrReconciliationItemResolved = errors.New("reconciliation item already resolved")
+)
+
+// ReconciliationServiceInterface đối soát hoá đơn với giao dịch thực tế trên VNPay / Stripe và cung cấp báo cáo cho kế toán.
+type ReconciliationServiceInterface interface {
+	// Reconcile hỏi trực tiếp cổng thanh toán các giao dịch của ngày businessDate
+	Reconcile(ctx context.Context, provider model.PaymentMethod, businessDate time.Time, startedBy string) (model.ReconciliationReportResponse, error)
+	// ReconcileSettlementFile đối soát theo file quyết toán CSV của cổng
+	ReconcileSettlementFile(ctx context.Context, provider model.PaymentMethod, businessDate time.Time, file io.Reader, startedBy string) (model.ReconciliationReportResponse, error)
+	ListRuns(ctx context.Context, provider string, from, to time.Time, page, limit int) (model.ReconciliationRunsResponse, error)
+	GetReport(ctx context.Context, runID uuid.UUID, onlyUnresolved bool) (model.ReconciliationReportResponse, error)
+	ResolveItem(ctx context.Context, itemID uuid.UUID, resolvedBy, note string) (model.ReconciliationItemResponse, error)
+	// Location là múi giờ dùng để tính ngày giao dịch
+	Location() *time.Location
+}
+
+type ReconciliationService struct {
+	repo       repository.ReconciliationRepositoryInterface
+	providers  *PaymentProviderRegistry
+	stripeSvc  StripeServiceInterface
+	invoiceSvc InvoiceServiceInterface
+	location   *time.Location
+}
+
+func NewReconciliationService(repo repository.ReconciliationRepositoryInterface, providers *PaymentProviderRegistry, stripeSvc StripeServiceInterface, invoiceSvc InvoiceServiceInterface, location *time.Location) ReconciliationServiceInterface {
+	if location == nil {
+		location = time.Local
+	}
+	return &ReconciliationService{
+		repo:       repo,
+		providers:  providers,
+		stripeSvc:  stripeSvc,
+		invoiceSvc: invoiceSvc,
+		location:   location,
+	}
+}
+
+// settlementSource là dữ liệu giao dịch của cổng dùng cho một lần đối soát
+type settlementSource struct {
+	source model.ReconciliationSource
+	// settlements là các giao dịch cổng báo về cho ngày đối soát (danh sách Stripe, file quyết toán)
+	settlements []model.ProviderSettlement
+	// lookup hỏi cổng từng giao dịch không có trong settlements; trả về nil khi cổng không có giao dịch.
+	// nil khi nguồn không hỗ trợ hỏi từng giao dịch (file quyết toán).
+	lookup func(ctx context.Context, invoice db.Invoice) (*model.ProviderSettlement, error)
+	// issuesReferences: mã tham chiếu do cổng cấp (Stripe PaymentIntent), hoá đơn có mã mà cổng không biết là bất thường.
+	// Với VNPay mã do hệ thống tạo, khách bỏ dở thanh toán thì cổng không có giao dịch.
+	issuesReferences bool
+}
+
+// reconciliation là kết quả so khớp đang được tích luỹ của một lần đối soát
+type reconciliation struct {
+	provider      model.PaymentMethod
+	invoiceCount  int32
+	providerCount int32
+	matchedCount  int32
+	items         []db.CreateReconciliationItemParams
+}
+
+func (s *ReconciliationService) Location() *time.Location {
+	return s.location
+}
+
+// Reconcile đối soát qua API của cổng: VNPay hỏi querydr từng hoá đơn, Stripe liệt kê PaymentIntent tạo trong ngày.
+func (s *ReconciliationService) Reconcile(ctx context.Context, provider model.PaymentMethod, businessDate time.Time, startedBy string) (model.ReconciliationReportResponse, error) {
+	from, to := s.businessDay(businessDate)
+	switch provider {
+	case model.PaymentMethodVNPay:
+		return s.run(ctx, provider, from, model.ReconciliationSourceAPI, startedBy, func(ctx context.Context) (settlementSource, error) {
+			return settlementSource{source: model.ReconciliationSourceAPI, lookup: s.queryProvider(provider)}, nil
+		})
+	case model.PaymentMethodStripe:
+		return s.run(ctx, provider, from, model.ReconciliationSourceAPI, startedBy, func(ctx context.Context) (settlementSource, error) {
+			intents, err := s.stripeSvc.ListPaymentIntents(ctx, from, to)
+			if err != nil {
+				return settlementSource{}, err
+			}
+			settlements := make([]model.ProviderSettlement, 0, len(intents))
+			for _, pi := range intents {
+				settlements = append(settlements, s.stripeSettlement(pi))
+			}
+			return settlementSource{
+				source:           model.ReconciliationSourceAPI,
+				settlements:      settlements,
+				lookup:           s.queryProvider(provider),
+				issuesReferences: true,
+			}, nil
+		})
+	default:
+		return model.ReconciliationReportResponse{}, fmt.Errorf("service: reconcile %s: %w", provider, ErrReconciliationNotSupported)
+	}
+}
+
+// ReconcileSettlementFile đối soát theo file quyết toán CSV. File cần dòng tiêu đề với các cột
+// reference, amount (đơn vị chính: VND, USD), status; tuỳ chọn provider_txn_id, paid_at.
+func (s *ReconciliationService) ReconcileSettlementFile(ctx context.Context, provider model.PaymentMethod, businessDate time.Time, file io.Reader, startedBy string) (model.ReconciliationReportResponse, error) {
+	if provider != model.PaymentMethodVNPay && provider != model.PaymentMethodStripe {
+		return model.ReconciliationReportResponse{}, fmt.Errorf("service: reconcile %s: %w", provider, ErrReconciliationNotSupported)
+	}
+	// Đọc hết file trước khi ghi lần đối soát để file lỗi không để lại lần đối soát FAILED
+	settlements, err := s.parseSettlementFile(file)
+	if err != nil {
+		return model.ReconciliationReportResponse{}, fmt.Errorf("service: %w", err)
+	}
+
+	from, _ := s.businessDay(businessDate)
+	return s.run(ctx, provider, from, model.ReconciliationSourceCSV, startedBy, func(ctx context.Context) (settlementSource, error) {
+		// File quyết toán chỉ liệt kê giao dịch đã thu tiền, hoá đơn chưa thanh toán không có trong file là bình thường
+		return settlementSource{source: model.ReconciliationSourceCSV, settlements: settlements}, nil
+	})
+}
+
+// run ghi một lần đối soát RUNNING, so khớp hoá đơn của ngày với dữ liệu của cổng rồi lưu kết quả.
+// Không lấy được dữ liệu của cổng thì lần đối soát được ghi FAILED.
+func (s *ReconciliationService) run(ctx context.Context, provider model.PaymentMethod, day time.Time, source model.ReconciliationSource, startedBy string, load func(ctx context.Context) (settlementSource, error)) (model.ReconciliationReportResponse, error) {
+	if startedBy == "" {
+		startedBy = "system"
+	}
+	run, err := s.repo.CreateRun(ctx, db.CreateReconciliationRunParams{
+		Provider:     string(provider),
+		BusinessDate: time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC),
+		Source:       string(source),
+		StartedBy:    startedBy,
+	})
+	if err != nil {
+		return model.ReconciliationReportResponse{}, fmt.Errorf("service: failed to start reconciliation: %w", err)
+	}
+	log.Printf("Info: service: reconciliation %s started for %s on %s (%s) by %s", run.RunID, provider, day.Format("2006-01-02"), source, startedBy)
+
+	result, err := s.reconcile(ctx, provider, day, load)
+	if err != nil {
+		log.Printf("Warning: service: reconciliation %s for %s on %s failed: %v", run.RunID, provider, day.Format("2006-01-02"), err)
+		if _, finishErr := s.repo.FinishRun(ctx, db.FinishReconciliationRunParams{
+			RunID:        run.RunID,
+			Status:       string(model.ReconciliationRunStatusFailed),
+			ErrorMessage: err.Error(),
+		}, nil); finishErr != nil {
+			log.Printf("Warning: service: failed to mark reconciliation %s as failed: %v", run.RunID, finishErr)
+		}
+		return model.ReconciliationReportResponse{}, fmt.Errorf("service: reconciliation %s failed: %w", run.RunID, err)
+	}
+
+	run, err = s.repo.FinishRun(ctx, db.FinishReconciliationRunParams{
+		RunID:         run.RunID,
+		Status:        string(model.ReconciliationRunStatusCompleted),
+		InvoiceCount:  result.invoiceCount,
+		ProviderCount: result.providerCount,
+		MatchedCount:  result.matchedCount,
+		MismatchCount: int32(len(result.items)),
+	}, result.items)
+	if err != nil {
+		return model.ReconciliationReportResponse{}, fmt.Errorf("service: failed to save reconciliation %s: %w", run.RunID, err)
+	}
+	if run.MismatchCount > 0 {
+		log.Printf("Warning: service: reconciliation %s for %s on %s found %d mismatches", run.RunID, provider, day.Format("2006-01-02"), run.MismatchCount)
+	}
+	return s.GetReport(ctx, run.RunID, false)
+}
+
+// reconcile so khớp các hoá đơn tạo trong ngày với giao dịch của cổng theo vnpay_txn_ref / stripe_payment_intent_id
+func (s *ReconciliationService) reconcile(ctx context.Context, provider model.PaymentMethod, day time.Time, load func(ctx context.Context) (settlementSource, error)) (*reconciliation, error) {
+	from, to := s.businessDay(day)
+	invoices, err := s.repo.ListInvoicesForReconciliation(ctx, string(provider), from, to)
+	if err != nil {
+		return nil, err
+	}
+	src, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &reconciliation{provider: provider, providerCount: int32(len(src.settlements))}
+	byReference := make(map[string]model.ProviderSettlement, len(src.settlements))
+	for _, st := range src.settlements {
+		byReference[st.Reference] = st
+	}
+
+	for _, invoice := range invoices {
+		reference := invoiceReference(provider, invoice)
+		if reference == "" {
+			// Hoá đơn chưa từng được gửi sang cổng, chỉ bất thường khi đã được ghi nhận thanh toán
+			if isPaidLocally(invoice) {
+				result.invoiceCount++
+				result.flag(invoice, nil, model.MismatchPaidLocalNotAtProvider, "invoice is paid but has no provider reference")
+			}
+			continue
+		}
+		result.invoiceCount++
+
+		st, found := byReference[reference]
+		if found {
+			delete(byReference, reference)
+		} else if src.lookup != nil {
+			queried, err := src.lookup(ctx, invoice)
+			if err != nil {
+				result.flag(invoice, nil, model.MismatchQueryFailed, err.Error())
+				continue
+			}
+			if queried != nil {
+				st, found = *queried, true
+				result.providerCount++
+			}
+		}
+
+		if !found {
+			switch {
+			case isPaidLocally(invoice):
+				result.flag(invoice, nil, model.MismatchPaidLocalNotAtProvider, fmt.Sprintf("%s has no transaction %s", provider, reference))
+			case src.issuesReferences:
+				result.flag(invoice, nil, model.MismatchMissingAtProvider, fmt.Sprintf("%s does not know reference %s", provider, reference))
+			default:
+				// Khách bỏ dở thanh toán: cả hai phía đều chưa thu tiền
+				result.matchedCount++
+			}
+			continue
+		}
+		result.compare(invoice, st)
+	}
+
+	// Giao dịch đã thu tiền trên cổng nhưng không khớp hoá đơn nào trong ngày
+	for reference, st := range byReference {
+		if st.Status != model.PaymentStatusCompleted {
+			continue
+		}
+		invoice, err := s.invoiceByReference(ctx, provider, reference)
+		if errors.Is(err, sql.ErrNoRows) {
+			result.flag(db.Invoice{}, &st, model.MismatchOrphanAtProvider, fmt.Sprintf("no invoice with reference %s", reference))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Hoá đơn của ngày khác (vd: tạo trước nửa đêm, thanh toán sau nửa đêm)
+		result.compare(invoice, st)
+	}
+	return result, nil
+}
+
+// compare so trạng thái và số tiền của hoá đơn với giao dịch trên cổng
+func (r *reconciliation) compare(invoice db.Invoice, st model.ProviderSettlement) {
+	localPaid := isPaidLocally(invoice)
+	providerPaid := st.Status == model.PaymentStatusCompleted
+	// Cổng thu theo tiền tệ thực thu của hoá đơn (VD: Stripe thu USD cho vé VND)
+	chargedAmount, chargedCurrency := invoiceChargedAmount(invoice)
+	switch {
+	case providerPaid && !localPaid:
+		details := fmt.Sprintf("%s collected %.2f but invoice is %s", r.provider, st.Amount, invoice.PaymentStatus.String)
+		if st.PaidAt != nil {
+			details += fmt.Sprintf(" (paid at %s)", st.PaidAt.Format(time.RFC3339))
+		}
+		r.flag(invoice, &st, model.MismatchPaidAtProviderPendingLocal, details)
+	case !providerPaid && localPaid:
+		r.flag(invoice, &st, model.MismatchPaidLocalNotAtProvider, fmt.Sprintf("%s transaction is %s", r.provider, st.RawStatus))
+	case providerPaid && math.Abs(st.Amount-chargedAmount) > refundEpsilon:
+		r.flag(invoice, &st, model.MismatchAmount, fmt.Sprintf("%s collected %.2f %s, charged amount is %.2f %s (final_amount %.2f %s)",
+			r.provider, st.Amount, chargedCurrency, chargedAmount, chargedCurrency, invoice.FinalAmount, invoice.Currency.String))
+	default:
+		r.matchedCount++
+	}
+}
+
+// flag ghi một chênh lệch; invoice rỗng khi giao dịch trên cổng không có hoá đơn, st nil khi cổng không có giao dịch
+func (r *reconciliation) flag(invoice db.Invoice, st *model.ProviderSettlement, mismatch model.ReconciliationMismatchType, details string) {
+	item := db.CreateReconciliationItemParams{
+		InvoiceID:    uuid.NullUUID{UUID: invoice.InvoiceID, Valid: invoice.InvoiceID != uuid.Nil},
+		Reference:    invoiceReference(r.provider, invoice),
+		MismatchType: string(mismatch),
+		LocalStatus:  invoice.PaymentStatus.String,
+		Details:      details,
+	}
+	item.LocalAmount, _ = invoiceChargedAmount(invoice)
+	if st != nil {
+		item.Reference = st.Reference
+		item.ProviderStatus = st.RawStatus
+		item.ProviderAmount = st.Amount
+	}
+	if item.Reference == "" {
+		item.Reference = invoice.InvoiceNumber
+	}
+	r.items = append(r.items, item)
+}
+
+// queryProvider hỏi cổng trạng thái giao dịch của hoá đơn qua PaymentProviderRegistry
+func (s *ReconciliationService) queryProvider(provider model.PaymentMethod) func(ctx context.Context, invoice db.Invoice) (*model.ProviderSettlement, error) {
+	return func(ctx context.Context, invoice db.Invoice) (*model.ProviderSettlement, error) {
+		p, err := s.providers.Get(provider)
+		if err != nil {
+			return nil, err
+		}
+		status, err := p.QueryPayment(ctx, invoice)
+		if err != nil {
+			var stripeErr *stripe.Error
+			if errors.Is(err, ErrVNPayTransactionNotFound) ||
+				(errors.As(err, &stripeErr) && stripeErr.Code == stripe.ErrorCodeResourceMissing) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return &model.ProviderSettlement{
+			Reference:     invoiceReference(provider, invoice),
+			ProviderTxnID: status.ProviderTxnID,
+			Status:        status.Status,
+			RawStatus:     string(status.Status),
+			Amount:        status.Amount,
+			PaidAt:        status.PaidAt,
+		}, nil
+	}
+}
+
+func (s *ReconciliationService) stripeSettlement(pi *stripe.PaymentIntent) model.ProviderSettlement {
+	amount, _ := s.invoiceSvc.ConvertSmallestUnitToFloat(pi.AmountReceived, string(pi.Currency))
+	st := model.ProviderSettlement{
+		Reference: pi.ID,
+		RawStatus: string(pi.Status),
+		Amount:    amount,
+		Status:    model.PaymentStatusPending,
+	}
+	if pi.LatestCharge != nil {
+		st.ProviderTxnID = pi.LatestCharge.ID
+	}
+	switch pi.Status {
+	case stripe.PaymentIntentStatusSucceeded:
+		st.Status = model.PaymentStatusCompleted
+	case stripe.PaymentIntentStatusCanceled:
+		st.Status = model.PaymentStatusFailed
+	}
+	return st
+}
+
+// parseSettlementFile đọc file quyết toán CSV, cột được xác định theo dòng tiêu đề (không phân biệt hoa thường)
+func (s *ReconciliationService) parseSettlementFile(file io.Reader) ([]model.ProviderSettlement, error) {
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read header: %v", ErrInvalidSettlementFile, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"reference", "amount", "status"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidSettlementFile, required)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var settlements []model.ProviderSettlement
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidSettlementFile, line, err)
+		}
+
+		reference := field(record, "reference")
+		if reference == "" {
+			return nil, fmt.Errorf("%w: line %d: reference is empty", ErrInvalidSettlementFile, line)
+		}
+		amount, err := strconv.ParseFloat(strings.ReplaceAll(field(record, "amount"), ",", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid amount %q", ErrInvalidSettlementFile, line, field(record, "amount"))
+		}
+		rawStatus := field(record, "status")
+		st := model.ProviderSettlement{
+			Reference:     reference,
+			ProviderTxnID: field(record, "provider_txn_id"),
+			Status:        settlementStatus(rawStatus),
+			RawStatus:     rawStatus,
+			Amount:        amount,
+		}
+		if paidAt := field(record, "paid_at"); paidAt != "" {
+			t, err := s.parseSettlementTime(paidAt)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: invalid paid_at %q", ErrInvalidSettlementFile, line, paidAt)
+			}
+			st.PaidAt = &t
+		}
+		settlements = append(settlements, st)
+	}
+	return settlements, nil
+}
+
+// settlementStatus chuyển trạng thái trong file quyết toán: VNPay dùng mã 00, Stripe dùng succeeded
+func settlementStatus(raw string) model.PaymentStatus {
+	switch strings.ToUpper(raw) {
+	case "00", "SUCCESS", "SUCCEEDED", "COMPLETED", "PAID":
+		return model.PaymentStatusCompleted
+	case "01", "PENDING", "PROCESSING":
+		return model.PaymentStatusPending
+	default:
+		return model.PaymentStatusFailed
+	}
+}
+
+func (s *ReconciliationService) parseSettlementTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "20060102150405"} {
+		if t, err := time.ParseInLocation(layout, value, s.location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported time format")
+}
+
+// businessDay trả về [00:00, 00:00 ngày hôm sau) của ngày theo múi giờ đối soát
+func (s *ReconciliationService) businessDay(date time.Time) (time.Time, time.Time) {
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, s.location)
+	return from, from.AddDate(0, 0, 1)
+}
+
+func (s *ReconciliationService) invoiceByReference(ctx context.Context, provider model.PaymentMethod, reference string) (db.Invoice, error) {
+	ref := sql.NullString{String: reference, Valid: true}
+	if provider == model.PaymentMethodStripe {
+		return s.repo.GetInvoiceByStripePaymentIntentID(ctx, ref)
+	}
+	return s.repo.GetInvoiceByVNPayTxnRef(ctx, ref)
+}
+
+func invoiceReference(provider model.PaymentMethod, invoice db.Invoice) string {
+	if provider == model.PaymentMethodStripe {
+		return invoice.StripePaymentIntentID.String
+	}
+	return invoice.VnpayTxnRef.String
+}
+
+// isPaidLocally: hoá đơn đã được ghi nhận thu tiền, kể cả khi sau đó đã hoàn một phần hoặc toàn bộ
+func isPaidLocally(invoice db.Invoice) bool {
+	switch model.PaymentStatus(invoice.PaymentStatus.String) {
+	case model.PaymentStatusCompleted, model.PaymentStatusPartiallyRefunded, model.PaymentStatusRefunded:
+		return true
+	}
+	return false
+}
+
+func (s *ReconciliationService) ListRuns(ctx context.Context, provider string, from, to time.Time, page, limit int) (model.ReconciliationRunsResponse, error) {
+	runs, total, err := s.repo.ListRuns(ctx, db.ListReconciliationRunsParams{
+		Provider:   provider,
+		FromDate:   from,
+		ToDate:     to,
+		PageLimit:  int32(limit),
+		PageOffset: int32((page - 1) * limit),
+	})
+	if err != nil {
+		return model.ReconciliationRunsResponse{}, fmt.Errorf("service: failed to list reconciliation runs: %w", err)
+	}
+
+	resp := model.ReconciliationRunsResponse{
+		Items: make([]model.ReconciliationRunResponse, len(runs)),
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	}
+	for i, run := range runs {
+		resp.Items[i] = mapDbReconciliationRun(run)
+	}
+	return resp, nil
+}
+
+// GetReport trả về lần đối soát cùng các chênh lệch của nó
+func (s *ReconciliationService) GetReport(ctx context.Context, runID uuid.UUID, onlyUnresolved bool) (model.ReconciliationReportResponse, error) {
+	run, err := s.repo.GetRun(ctx, runID)
+	if err != nil {
+		return model.ReconciliationReportResponse{}, err
+	}
+	items, err := s.repo.ListItemsByRunID(ctx, runID)
+	if err != nil {
+		return model.ReconciliationReportResponse{}, err
+	}
+
+	report := model.ReconciliationReportResponse{
+		Run:   mapDbReconciliationRun(run),
+		Items: make([]model.ReconciliationItemResponse, 0, len(items)),
+	}
+	for _, item := range items {
+		if onlyUnresolved && item.ResolvedAt.Valid {
+			continue
+		}
+		report.Items = append(report.Items, mapDbReconciliationItem(item))
+	}
+	return report, nil
+}
+
+// ResolveItem đánh dấu chênh lệch đã được kế toán xử lý
+func (s *ReconciliationService) ResolveItem(ctx context.Context, itemID uuid.UUID, resolvedBy, note string) (model.ReconciliationItemResponse, error) {
+	item, err := s.repo.ResolveItem(ctx, db.ResolveReconciliationItemParams{
+		ItemID:         itemID,
+		ResolvedBy:     sql.NullString{String: resolvedBy, Valid: resolvedBy != ""},
+		ResolutionNote: note,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		existing, getErr := s.repo.GetItem(ctx, itemID)
+		if getErr != nil {
+			return model.ReconciliationItemResponse{}, getErr
+		}
+		return mapDbReconciliationItem(existing), fmt.Errorf("service: item %s resolved by %s: %w", itemID, existing.ResolvedBy.String, ErrReconciliationItemResolved)
+	}
+	if err != nil {
+		return model.ReconciliationItemResponse{}, err
+	}
+	log.Printf("Info: service: reconciliation item %s (%s %s) resolved by %s", item.ItemID, item.MismatchType, item.Reference, resolvedBy)
+	return mapDbReconciliationItem(item), nil
+}
+
+func mapDbReconciliationRun(run db.ReconciliationRun) model.ReconciliationRunResponse {
+	resp := model.ReconciliationRunResponse{
+		RunID:         run.RunID,
+		Provider:      run.Provider,
+		BusinessDate:  run.BusinessDate.Format("2006-01-02"),
+		Source:        run.Source,
+		Status:        run.Status,
+		InvoiceCount:  run.InvoiceCount,
+		ProviderCount: run.ProviderCount,
+		MatchedCount:  run.MatchedCount,
+		MismatchCount: run.MismatchCount,
+		ErrorMessage:  run.ErrorMessage,
+		StartedBy:     run.StartedBy,
+		StartedAt:     run.StartedAt.Format("2006-01-02 15:04:05"),
+	}
+	if run.FinishedAt.Valid {
+		resp.FinishedAt = run.FinishedAt.Time.Format("2006-01-02 15:04:05")
+	}
+	return resp
+}
+
+func mapDbReconciliationItem(item db.ReconciliationItem) model.ReconciliationItemResponse {
+	resp := model.ReconciliationItemResponse{
+		ItemID:         item.ItemID,
+		RunID:          item.RunID,
+		Reference:      item.Reference,
+		MismatchType:   item.MismatchType,
+		LocalStatus:    item.LocalStatus,
+		ProviderStatus: item.ProviderStatus,
+		LocalAmount:    item.LocalAmount,
+		ProviderAmount: item.ProviderAmount,
+		Details:        item.Details,
+		Resolved:       item.ResolvedAt.Valid,
+		ResolvedBy:     item.ResolvedBy.String,
+		ResolutionNote: item.ResolutionNote,
+		CreatedAt:      item.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if item.InvoiceID.Valid {
+		invoiceID := item.InvoiceID.UUID
+		resp.InvoiceID = &invoiceID
+	}
+	if item.ResolvedAt.Valid {
+		resp.ResolvedAt = item.ResolvedAt.Time.Format("2006-01-02 15:04:05")
+	}
+	return resp
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"payment_service/domain/model"
+	"payment_service/internal/db"
+)
+
+// ErrRefundNotApplicable là lỗi không thể xử lý lại (không có hoá đơn đã thanh toán, đã hoàn tiền...).
+// Consumer sẽ commit message thay vì retry.
+var ErrRefundNotApplicable = errors.New("refund is not applicable")
+
+// errRefundNotSettled: tiền đã hoàn trên cổng nhưng lần hoàn chưa được ghi COMPLETED, không được hoàn lại lần nữa
+var errRefundNotSettled = errors.New("refund processed but not settled")
+
+// RefundServiceInterface hoàn tiền hoá đơn theo phương thức thanh toán gốc, mỗi lần hoàn được ghi vào sổ hoàn tiền.
+type RefundServiceInterface interface {
+	RefundInvoice(ctx context.Context, req model.InvoiceRefundRequest) (db.Refund, db.Invoice, error)
+	RefundTicket(ctx context.Context, req model.TicketRefundRequest) (db.Invoice, error)
+	AdjustFare(ctx context.Context, req model.FareAdjustmentRequest) (db.Invoice, error)
+}
+
+type RefundService struct {
+	invoiceService InvoiceServiceInterface
+	providers      *PaymentProviderRegistry
+}
+
+func NewRefundService(invoiceService InvoiceServiceInterface, providers *PaymentProviderRegistry) RefundServiceInterface {
+	return &RefundService{
+		invoiceService: invoiceService,
+		providers:      providers,
+	}
+}
+
+// RefundInvoice ghi sổ một lần hoàn PENDING, hoàn tiền qua cổng gốc của hoá đơn (hoặc ghi nhận hoàn tại quầy)
+// rồi đánh dấu lần hoàn COMPLETED. Cổng từ chối thì lần hoàn được đánh dấu FAILED.
+func (s *RefundService) RefundInvoice(ctx context.Context, req model.InvoiceRefundRequest) (db.Refund, db.Invoice, error) {
+	refund, invoice, err := s.invoiceService.StartRefund(ctx, req)
+	if err != nil {
+		return db.Refund{}, invoice, err
+	}
+
+	method := model.PaymentMethod(invoice.PaymentMethod.String)
+	log.Printf("Refunding invoice %s: refund %s, method %s, amount %.2f", invoice.InvoiceID, refund.RefundID, method, refund.Amount)
+
+	// Thanh toán tại quầy: nhân viên hoàn tiền trực tiếp, hệ thống chỉ ghi nhận.
+	reference := "COUNTER"
+	if !isCounterPaymentMethod(method) {
+		reference, err = s.refundViaProvider(ctx, invoice, refund)
+		if err != nil {
+			if _, failErr := s.invoiceService.FailRefund(ctx, refund.RefundID, err.Error()); failErr != nil {
+				log.Printf("Warning: failed to mark refund %s of invoice %s as failed: %v", refund.RefundID, invoice.InvoiceID, failErr)
+			}
+			return db.Refund{}, invoice, err
+		}
+	}
+
+	completedRefund, updatedInvoice, err := s.invoiceService.CompleteRefund(ctx, refund.RefundID, reference)
+	if err != nil {
+		// Tiền đã hoàn nhưng không cập nhật được hoá đơn; lần hoàn vẫn PENDING để đối soát thủ công.
+		log.Printf("CRITICAL: %s refund %s (%s) processed for invoice %s, but failed to complete it: %v", method, refund.RefundID, reference, invoice.InvoiceID, err)
+		return refund, invoice, fmt.Errorf("refund %s: %v: %w", refund.RefundID, err, errRefundNotSettled)
+	}
+	return completedRefund, updatedInvoice, nil
+}
+
+func (s *RefundService) RefundTicket(ctx context.Context, req model.TicketRefundRequest) (db.Invoice, error) {
+	if req.RefundPercent <= 0 || req.RefundPercent > 100 {
+		return db.Invoice{}, fmt.Errorf("refund percent %.2f for ticket %s: %w", req.RefundPercent, req.TicketID, ErrRefundNotApplicable)
+	}
+
+	invoice, err := s.invoiceService.GetLatestCompletedInvoiceByTicketID(ctx, req.TicketID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.Invoice{}, fmt.Errorf("no completed invoice for ticket %s: %w", req.TicketID, ErrRefundNotApplicable)
+	}
+	if err != nil {
+		return db.Invoice{}, err
+	}
+
+	amount := math.Round(invoice.FinalAmount*req.RefundPercent) / 100
+	if req.RefundAmount > 0 {
+		// Giá vé có thể đã được điều chỉnh (đổi ghế) sau khi xuất hoá đơn, ticket-service tính trên giá hiện tại.
+		amount = math.Min(req.RefundAmount, invoice.FinalAmount)
+	}
+	reason := fmt.Sprintf("Khách huỷ vé, hoàn %.2f%% (%.2f). %s", req.RefundPercent, amount, req.Reason)
+
+	// Phần đã hoàn trước đó (chênh lệch giá) được trừ bớt nhờ CapToRefundable.
+	_, updatedInvoice, err := s.RefundInvoice(ctx, model.InvoiceRefundRequest{
+		InvoiceID:       invoice.InvoiceID,
+		Amount:          amount,
+		Reason:          reason,
+		InitiatedBy:     refundInitiator(req.RequestedBy),
+		RequestKey:      "ticket-refund:" + req.TicketID,
+		CapToRefundable: true,
+	})
+	if errors.Is(err, errRefundNotSettled) {
+		// Tiền đã hoàn, lần hoàn còn PENDING chờ đối soát: coi như đã xử lý để không hoàn lặp lại khi retry.
+		log.Printf("Warning: refund for ticket %s was processed but not settled, pending manual reconciliation: %v", req.TicketID, err)
+		updatedInvoice, err = invoice, nil
+	}
+	if err != nil {
+		return db.Invoice{}, refundLedgerError(req.TicketID, err)
+	}
+
+	// Vé đã huỷ nên được đánh dấu đã hoàn tiền kể cả khi hoá đơn chỉ được hoàn một phần.
+	if updatedInvoice.PaymentStatus.String != string(model.PaymentStatusRefunded) {
+		if err := s.invoiceService.UpdateTicketStatus(ctx, updatedInvoice.TicketID, model.TicketStatusRefunded, updatedInvoice.InvoiceID); err != nil {
+			log.Printf("Warning: failed to update ticket status for invoice %s (ticket %s) after partial refund: %v", updatedInvoice.InvoiceID, updatedInvoice.TicketID, err)
+		}
+	}
+	return updatedInvoice, nil
+}
+
+// AdjustFare xử lý chênh lệch giá khi khách đổi ghế/đổi chuyến trên vé đã thanh toán,
+// hoặc hoàn một phần hoá đơn chung khi đơn đoàn bớt hành khách (ticket_id là mã đơn đoàn).
+// Phần chênh lệch được hoàn qua sổ hoàn tiền, hoá đơn chuyển sang PARTIALLY_REFUNDED.
+func (s *RefundService) AdjustFare(ctx context.Context, req model.FareAdjustmentRequest) (db.Invoice, error) {
+	if req.Amount == 0 {
+		return db.Invoice{}, fmt.Errorf("zero fare adjustment for ticket %s: %w", req.TicketID, ErrRefundNotApplicable)
+	}
+
+	invoice, err := s.invoiceService.GetLatestCompletedInvoiceByTicketID(ctx, req.TicketID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.Invoice{}, fmt.Errorf("no completed invoice for ticket %s: %w", req.TicketID, ErrRefundNotApplicable)
+	}
+	if err != nil {
+		return db.Invoice{}, err
+	}
+
+	if req.Amount > 0 {
+		if req.CollectedBy == "" {
+			return db.Invoice{}, fmt.Errorf("additional fare %.2f for ticket %s was not collected: %w", req.Amount, req.TicketID, ErrRefundNotApplicable)
+		}
+		note := fmt.Sprintf("Đổi ghế: thu thêm %.2f tại quầy bởi %s. %s", req.Amount, req.CollectedBy, req.Reason)
+		return s.invoiceService.AddInvoiceNote(ctx, invoice.InvoiceID, note)
+	}
+
+	amount := -req.Amount
+	refundReq := model.InvoiceRefundRequest{
+		InvoiceID:       invoice.InvoiceID,
+		Amount:          amount,
+		Reason:          fmt.Sprintf("Hoàn chênh lệch giá vé %.2f. %s", amount, req.Reason),
+		InitiatedBy:     refundInitiator(req.RequestedBy),
+		CapToRefundable: true,
+	}
+	if !req.RequestedAt.IsZero() {
+		refundReq.RequestKey = fmt.Sprintf("fare-adjustment:%s:%d", req.TicketID, req.RequestedAt.UnixNano())
+	}
+
+	_, updatedInvoice, err := s.RefundInvoice(ctx, refundReq)
+	if errors.Is(err, errRefundNotSettled) {
+		// Tiền đã hoàn, lần hoàn còn PENDING chờ đối soát: coi như đã xử lý để không hoàn lặp lại khi retry.
+		log.Printf("Warning: fare adjustment refund for ticket %s was processed but not settled, pending manual reconciliation: %v", req.TicketID, err)
+		return invoice, nil
+	}
+	if err != nil {
+		return db.Invoice{}, refundLedgerError(req.TicketID, err)
+	}
+	return updatedInvoice, nil
+}
+
+// refundViaProvider hoàn tiền qua cổng thanh toán gốc của hoá đơn, trả về mã tham chiếu của lần hoàn.
+func (s *RefundService) refundViaProvider(ctx context.Context, invoice db.Invoice, refund db.Refund) (string, error) {
+	provider, err := s.providers.Get(model.PaymentMethod(invoice.PaymentMethod.String))
+	if err != nil {
+		return "", fmt.Errorf("invoice %s: %v: %w", invoice.InvoiceID, err, ErrRefundNotApplicable)
+	}
+	priorRefunds, err := s.invoiceService.PriorRefundAmounts(ctx, invoice.InvoiceID, refund.RefundID)
+	if err != nil {
+		return "", err
+	}
+	return provider.Refund(ctx, invoice, model.ProviderRefundRequest{
+		RefundID:     refund.RefundID,
+		Amount:       refund.Amount,
+		Reason:       refund.Reason,
+		RequestedBy:  refund.InitiatedBy,
+		PriorRefunds: priorRefunds,
+	})
+}
+
+// refundLedgerError chuyển các lỗi sổ hoàn tiền không thể xử lý lại (hoá đơn đã hoàn hết, yêu cầu trùng) thành ErrRefundNotApplicable
+func refundLedgerError(ticketID string, err error) error {
+	if errors.Is(err, ErrInvoiceNotRefundable) || errors.Is(err, ErrRefundExceedsInvoice) || errors.Is(err, ErrDuplicateRefundRequest) {
+		return fmt.Errorf("ticket %s: %v: %w", ticketID, err, ErrRefundNotApplicable)
+	}
+	return err
+}
+
+// refundInitiator: sự kiện từ ticket-service có thể không có requested_by
+func refundInitiator(requestedBy string) string {
+	if requestedBy == "" {
+		return "system"
+	}
+	return requestedBy
+}
+
+func isCounterPaymentMethod(method model.PaymentMethod) bool {
+	switch method {
+	case model.PaymentMethodStaffCash, model.PaymentMethodStaffCard, model.PaymentMethodStaffTransfer, model.PaymentMethodStaffOther:
+		return true
+	}
+	return false
+}
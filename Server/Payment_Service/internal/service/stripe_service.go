@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stripe/stripe-go/v76" // Use appropriate version
 	"github.com/stripe/stripe-go/v76/paymentintent"
 	"github.com/stripe/stripe-go/v76/refund" // Import refund package
@@ -16,6 +19,7 @@ import (
 	"payment_service/config"
 	"payment_service/domain/model"
 	"payment_service/internal/db"
+	"payment_service/pkg/currency"
 )
 
 // StripeServiceInterface định nghĩa các phương thức cho stripe service
@@ -23,7 +27,9 @@ type StripeServiceInterface interface {
 	CreatePaymentIntent(ctx context.Context, req model.InitialStripePaymentRequest) (*model.StripePaymentIntentResponse, error)
 	ConfirmPayment(ctx context.Context, paymentIntentID string) (db.Invoice, error)
 	HandleWebhook(ctx context.Context, payload []byte, signature string) error
-	RefundPayment(ctx context.Context, req model.StripeInitiateRefundRequest) (db.Invoice, error) // New method
+	RefundAmount(ctx context.Context, invoice db.Invoice, req model.ProviderRefundRequest) (string, error)
+	GetPaymentIntent(ctx context.Context, paymentIntentID string) (*stripe.PaymentIntent, error)
+	ListPaymentIntents(ctx context.Context, from, to time.Time) ([]*stripe.PaymentIntent, error)
 }
 
 // StripeService xử lý các tương tác với Stripe API
@@ -43,34 +49,58 @@ func NewStripeService(cfg *config.StripeConfig, invoiceService InvoiceServiceInt
 
 // CreatePaymentIntent tạo một Stripe PaymentIntent và một hóa đơn liên quan
 func (s *StripeService) CreatePaymentIntent(ctx context.Context, req model.InitialStripePaymentRequest) (*model.StripePaymentIntentResponse, error) {
+	// Hoá đơn được tạo trước để PaymentIntent thu đúng final_amount (sau giảm giá, voucher và thuế),
+	// quy đổi sang charge_currency theo tỷ giá được snapshot trên hoá đơn
+	dbInvoice, err := s.invoiceService.CreateInvoiceForStripe(ctx, req, "")
+	if err != nil {
+		log.Printf("Error creating invoice in DB before Stripe PI creation: %v", err)
+		return nil, fmt.Errorf("failed to create internal invoice for Stripe payment: %w", err)
+	}
+	chargedAmount, chargedCurrency := invoiceChargedAmount(dbInvoice)
+	amount, err := s.invoiceService.GetAmountInSmallestUnit(chargedAmount, chargedCurrency)
+	if err != nil {
+		if _, failErr := s.invoiceService.UpdateInvoiceStatusForPaymentFailureForUUID(ctx, dbInvoice.InvoiceID, model.PaymentMethodStripe, "Unsupported charge currency"); failErr != nil {
+			log.Printf("Warning: failed to mark invoice %s as failed after currency error: %v", dbInvoice.InvoiceID, failErr)
+		}
+		return nil, fmt.Errorf("stripe: invalid charge amount for invoice %s: %w", dbInvoice.InvoiceID, err)
+	}
+
 	params := &stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(req.Amount), // Amount is already in smallest unit from request
-		Currency: stripe.String(strings.ToLower(req.Currency)),
+		Amount:   stripe.Int64(amount),
+		Currency: stripe.String(currency.Normalize(chargedCurrency)),
 		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{
 			Enabled: stripe.Bool(true),
 		},
 		Metadata: map[string]string{
 			"customer_id": req.CustomerID,
 			"ticket_id":   req.TicketID,
+			"invoice_id":  dbInvoice.InvoiceID.String(),
 		},
 	}
+	if dbInvoice.ExchangeRate.Valid && !currency.Equal(chargedCurrency, dbInvoice.Currency.String) {
+		params.Metadata["fare_amount"] = strconv.FormatFloat(dbInvoice.FinalAmount, 'f', -1, 64)
+		params.Metadata["fare_currency"] = dbInvoice.Currency.String
+		params.Metadata["exchange_rate"] = dbInvoice.ExchangeRate.String
+	}
 
 	pi, err := paymentintent.New(params)
 	if err != nil {
 		log.Printf("Error creating Stripe PaymentIntent: %v. Request: %+v", err, req)
+		if _, failErr := s.invoiceService.UpdateInvoiceStatusForPaymentFailureForUUID(ctx, dbInvoice.InvoiceID, model.PaymentMethodStripe, "PaymentIntent creation failed"); failErr != nil {
+			log.Printf("Warning: failed to mark invoice %s as failed after Stripe PI error: %v", dbInvoice.InvoiceID, failErr)
+		}
 		if stripeErr, ok := err.(*stripe.Error); ok {
 			return nil, fmt.Errorf("stripe error (%s): %s - %s", stripeErr.Code, stripeErr.Msg, stripeErr.Type)
 		}
 		return nil, fmt.Errorf("stripe: failed to create payment intent: %w", err)
 	}
 
-	dbInvoice, err := s.invoiceService.CreateInvoiceForStripe(ctx, req, pi.ID)
-	if err != nil {
-		log.Printf("Error creating invoice in DB after Stripe PI creation (%s): %v", pi.ID, err)
-		// Consider cancelling the PaymentIntent on Stripe if invoice creation fails.
+	if _, err := s.invoiceService.AttachStripePaymentIntent(ctx, dbInvoice, pi.ID); err != nil {
+		log.Printf("Error attaching Stripe PI %s to invoice %s: %v", pi.ID, dbInvoice.InvoiceID, err)
+		// Consider cancelling the PaymentIntent on Stripe if invoice update fails.
 		// _, cancelErr := paymentintent.Cancel(pi.ID, nil)
 		// if cancelErr != nil { log.Printf("Failed to cancel Stripe PI %s after DB error: %v", pi.ID, cancelErr) }
-		return nil, fmt.Errorf("failed to create internal invoice after Stripe PI creation: %w", err)
+		return nil, fmt.Errorf("failed to update internal invoice after Stripe PI creation: %w", err)
 	}
 
 	return &model.StripePaymentIntentResponse{
@@ -146,6 +176,37 @@ func (s *StripeService) ConfirmPayment(ctx context.Context, paymentIntentID stri
 	}
 }
 
+// GetPaymentIntent lấy PaymentIntent từ Stripe, không cập nhật hoá đơn
+func (s *StripeService) GetPaymentIntent(ctx context.Context, paymentIntentID string) (*stripe.PaymentIntent, error) {
+	pi, err := paymentintent.Get(paymentIntentID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("stripe: failed to retrieve payment intent %s: %w", paymentIntentID, err)
+	}
+	return pi, nil
+}
+
+// ListPaymentIntents lấy các PaymentIntent được tạo trong [from, to) từ Stripe, dùng cho đối soát
+func (s *StripeService) ListPaymentIntents(ctx context.Context, from, to time.Time) ([]*stripe.PaymentIntent, error) {
+	params := &stripe.PaymentIntentListParams{
+		CreatedRange: &stripe.RangeQueryParams{
+			GreaterThanOrEqual: from.Unix(),
+			LesserThan:         to.Unix(),
+		},
+	}
+	params.Context = ctx
+	params.Limit = stripe.Int64(100)
+
+	var intents []*stripe.PaymentIntent
+	iter := paymentintent.List(params)
+	for iter.Next() {
+		intents = append(intents, iter.PaymentIntent())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("stripe: failed to list payment intents created from %s to %s: %w", from.Format(time.RFC3339), to.Format(time.RFC3339), err)
+	}
+	return intents, nil
+}
+
 // HandleWebhook xử lý các sự kiện webhook từ Stripe
 func (s *StripeService) HandleWebhook(ctx context.Context, payload []byte, signature string) error {
 	if s.cfg.WebhookSecret == "" {
@@ -208,45 +269,51 @@ func (s *StripeService) HandleWebhook(ctx context.Context, payload []byte, signa
 		}
 		log.Printf("Webhook: Invoice updated to FAILED for PI %s.", pi.ID)
 
-	// Handle refund update from webhook if needed, e.g., charge.refunded
+	// Hoàn tiền được ghi vào sổ hoàn tiền khi gọi Stripe; webhook chỉ đối soát với số Stripe đã hoàn.
 	case stripe.EventTypeChargeRefunded:
 		var ch stripe.Charge
 		if err := json.Unmarshal(event.Data.Raw, &ch); err != nil {
 			log.Printf("Error unmarshaling charge.refunded data: %v", err)
 			return fmt.Errorf("failed to unmarshal charge.refunded data: %w", err)
 		}
-		log.Printf("Webhook: Charge %s for PaymentIntent %s was refunded.", ch.ID, ch.PaymentIntent.ID)
-		// If multiple refunds on a PI, this needs careful handling.
-		// The refund object itself might be more useful.
-		// We already update status when initiating refund. This could be for external refunds.
-		// For now, this is a good place to reconcile.
-		if ch.PaymentIntent != nil && ch.PaymentIntent.ID != "" {
-			invoice, findErr := s.invoiceService.GetInvoiceByStripePaymentIntentID(ctx, ch.PaymentIntent.ID)
-			if findErr == nil {
-				// Assuming the first refund means the primary one we care about changing status for.
-				// If invoice is not already refunded, update it.
-				if invoice.PaymentStatus.String != string(model.PaymentStatusRefunded) {
-					// Get latest refund from the charge
-					var refundReason string = "Refund processed via Stripe."
-					if len(ch.Refunds.Data) > 0 {
-						// Get reason from the latest refund object on the charge
-						// Stripe API might not directly provide the custom reason here this way.
-						// The refund object itself (if event was for refund.updated) would be better.
-						// For now, using a generic message.
-						refundReason = fmt.Sprintf("Stripe Charge %s refunded. Refund ID: %s", ch.ID, ch.Refunds.Data[0].ID)
-
-					}
-					_, errRefundUpdate := s.invoiceService.UpdateInvoiceStatusForRefund(ctx, invoice.InvoiceID, refundReason, ch.Refunds.Data[0].ID)
-					if errRefundUpdate != nil {
-						log.Printf("Webhook: Error updating invoice %s to refunded via charge.refunded event: %v", invoice.InvoiceID, errRefundUpdate)
-						return errRefundUpdate
-					}
-					log.Printf("Webhook: Invoice %s updated to REFUNDED via charge.refunded event for PI %s.", invoice.InvoiceID, ch.PaymentIntent.ID)
-				}
-			} else {
-				log.Printf("Webhook: charge.refunded: Could not find invoice for PI %s", ch.PaymentIntent.ID)
+		if ch.PaymentIntent == nil || ch.PaymentIntent.ID == "" {
+			log.Printf("Webhook: charge.refunded: charge %s has no PaymentIntent", ch.ID)
+			break
+		}
+		log.Printf("Webhook: Charge %s for PaymentIntent %s was refunded (%d %s in total).", ch.ID, ch.PaymentIntent.ID, ch.AmountRefunded, ch.Currency)
+
+		invoice, findErr := s.invoiceService.GetInvoiceByStripePaymentIntentID(ctx, ch.PaymentIntent.ID)
+		if findErr != nil {
+			log.Printf("Webhook: charge.refunded: Could not find invoice for PI %s", ch.PaymentIntent.ID)
+			break
+		}
+		ledger, err := s.invoiceService.ListRefunds(ctx, invoice.InvoiceID)
+		if err != nil {
+			return fmt.Errorf("webhook: failed to load refunds of invoice %s: %w", invoice.InvoiceID, err)
+		}
+		stripeRefunded, err := s.invoiceService.ConvertSmallestUnitToFloat(ch.AmountRefunded, string(ch.Currency))
+		if err != nil {
+			return fmt.Errorf("webhook: failed to convert refunded amount of charge %s: %w", ch.ID, err)
+		}
+		// Sổ hoàn tiền ghi theo tiền tệ giá vé, Stripe hoàn theo tiền tệ thực thu: cộng dồn các lần hoàn
+		// COMPLETED / PENDING theo đúng số đã gửi cho Stripe.
+		var fareAmounts []float64
+		for _, r := range ledger.Refunds {
+			if r.Status == string(model.RefundStatusCompleted) || r.Status == string(model.RefundStatusPending) {
+				fareAmounts = append(fareAmounts, r.Amount)
 			}
 		}
+		recorded := invoiceRefundTotals(invoice, fareAmounts).Charged
+		_, chargedCurrency := invoiceChargedAmount(invoice)
+		tolerance := refundEpsilon
+		if c, err := currency.Lookup(chargedCurrency); err == nil {
+			tolerance = c.MinorUnit() / 2
+		}
+		// Lần hoàn đang PENDING có thể đã được Stripe xử lý trước khi hoá đơn được cập nhật
+		if stripeRefunded > recorded+tolerance {
+			log.Printf("Warning: Webhook: charge %s refunded %.2f %s on Stripe, but invoice %s only records %.2f %s (%.2f refunded, %.2f pending %s). Refund was made outside payment service, manual reconciliation required.",
+				ch.ID, stripeRefunded, ch.Currency, invoice.InvoiceID, recorded, chargedCurrency, ledger.RefundedAmount, ledger.PendingAmount, invoice.Currency.String)
+		}
 
 	default:
 		log.Printf("Webhook: Unhandled event type: %s", event.Type)
@@ -255,113 +322,45 @@ func (s *StripeService) HandleWebhook(ctx context.Context, payload []byte, signa
 	return nil
 }
 
-// RefundPayment handles refunding a Stripe payment.
-func (s *StripeService) RefundPayment(ctx context.Context, req model.StripeInitiateRefundRequest) (db.Invoice, error) {
-	invoice, err := s.invoiceService.GetLatestCompletedInvoiceByTicketID(ctx, req.TicketID)
-	if err != nil {
-		return db.Invoice{}, fmt.Errorf("stripe refund: failed to get invoice for ticket ID %s: %w", req.TicketID, err)
-	}
-
+// RefundAmount hoàn req.Amount (toàn phần hoặc một phần) của PaymentIntent mà không đổi trạng thái hoá đơn.
+// RefundID của sổ hoàn tiền được dùng làm idempotency key để Stripe không hoàn lặp khi gọi lại.
+// Trả về Stripe refund ID.
+func (s *StripeService) RefundAmount(ctx context.Context, invoice db.Invoice, req model.ProviderRefundRequest) (string, error) {
 	if !invoice.StripePaymentIntentID.Valid || invoice.StripePaymentIntentID.String == "" {
-		return db.Invoice{}, fmt.Errorf("stripe refund: invoice %s does not have a Stripe PaymentIntentID", invoice.InvoiceID)
-	}
-
-	if invoice.PaymentStatus.String != string(model.PaymentStatusCompleted) {
-		return db.Invoice{}, fmt.Errorf("stripe refund: invoice %s is not completed (status: %s), cannot refund", invoice.InvoiceID, invoice.PaymentStatus.String)
-	}
-	if invoice.PaymentStatus.String == string(model.PaymentStatusRefunded) {
-		return db.Invoice{}, fmt.Errorf("stripe refund: invoice %s is already refunded", invoice.InvoiceID)
+		return "", fmt.Errorf("stripe refund: invoice %s does not have a Stripe PaymentIntentID", invoice.InvoiceID)
 	}
 
-	// Calculate refund amount in smallest currency unit
-	currency := "usd" // Default if not set
-	if invoice.Currency.Valid && invoice.Currency.String != "" {
-		currency = invoice.Currency.String
+	// req.Amount theo tiền tệ giá vé, Stripe hoàn theo tiền tệ đã thu với tỷ giá lúc thanh toán
+	refundAmount, refundCurrency := invoiceChargedAmountFor(invoice, req.Amount, invoiceRefundTotals(invoice, req.PriorRefunds))
+	if refundCurrency == "" {
+		refundCurrency = "usd"
 	}
-
-	originalAmountSmallestUnit, err := s.invoiceService.GetAmountInSmallestUnit(invoice.FinalAmount, currency)
+	amountSmallestUnit, err := s.invoiceService.GetAmountInSmallestUnit(refundAmount, refundCurrency)
 	if err != nil {
-		return db.Invoice{}, fmt.Errorf("stripe refund: could not convert final amount for invoice %s: %w", invoice.InvoiceID, err)
+		return "", fmt.Errorf("stripe refund: could not convert amount for invoice %s: %w", invoice.InvoiceID, err)
 	}
-
-	refundAmountSmallestUnit := originalAmountSmallestUnit
-	if req.PercentageDeduction > 0 {
-		deduction := float64(originalAmountSmallestUnit) * req.PercentageDeduction
-		refundAmountSmallestUnit = originalAmountSmallestUnit - int64(deduction)
-	}
-
-	if refundAmountSmallestUnit <= 0 && originalAmountSmallestUnit > 0 { // Cannot refund zero or negative unless it's a full refund of zero amount tx
-		log.Printf("Stripe refund: Calculated refund amount for invoice %s is zero or negative (%d from %.2f with %.2f%% deduction). Assuming full refund of original amount if original was > 0, otherwise error.",
-			invoice.InvoiceID, refundAmountSmallestUnit, invoice.FinalAmount, req.PercentageDeduction*100)
-		// If original amount was 0, then refunding 0 is fine. Otherwise, this logic needs review.
-		// For now, let it pass if original was 0. If original > 0 and refund is <=0, it's an issue.
-		if originalAmountSmallestUnit > 0 {
-			// This might happen if PercentageDeduction is 1.0 or more.
-			// A 100% deduction means refundAmount is 0. Stripe might allow refunding 0.
-			// If we want to prevent 0 refund unless it's a full refund of a 0 value transaction:
-			if refundAmountSmallestUnit < 0 { // strictly negative, which shouldn't happen with percent 0-1
-				return db.Invoice{}, fmt.Errorf("stripe refund: calculated refund amount is negative for invoice %s", invoice.InvoiceID)
-			}
-			// If refundAmountSmallestUnit is 0, Stripe might accept it.
-		}
+	if amountSmallestUnit <= 0 {
+		return "", fmt.Errorf("stripe refund: amount must be positive for invoice %s", invoice.InvoiceID)
 	}
 
 	params := &stripe.RefundParams{
 		PaymentIntent: stripe.String(invoice.StripePaymentIntentID.String),
-		// Amount is optional; if omitted, a full refund is issued.
-		// If req.PercentageDeduction results in a partial refund, set Amount.
-		// If req.PercentageDeduction is 0 (full refund of final_amount), Amount can be set or omitted.
-	}
-	if req.PercentageDeduction > 0.0 || refundAmountSmallestUnit < originalAmountSmallestUnit { // If it's a partial refund
-		params.Amount = stripe.Int64(refundAmountSmallestUnit)
-	}
-	// If req.PercentageDeduction == 0, it's a full refund of invoice.FinalAmount.
-	// Stripe will refund the full amount if params.Amount is not set.
-	// If refundAmountSmallestUnit is calculated (even for full refund), we can set it.
-	// Let's be explicit if we calculated an amount:
-	if refundAmountSmallestUnit > 0 { // Only set amount if it's positive
-		params.Amount = stripe.Int64(refundAmountSmallestUnit)
-	} else if refundAmountSmallestUnit == 0 && originalAmountSmallestUnit > 0 {
-		// Refunding $0.00 for a paid transaction.
-		params.Amount = stripe.Int64(0)
+		Amount:        stripe.Int64(amountSmallestUnit),
+		Reason:        stripe.String(string(stripe.RefundReasonRequestedByCustomer)),
+		Metadata: map[string]string{
+			"internal_refund_reason": req.Reason,
+			"ticket_id":              invoice.TicketID,
+			"refund_id":              req.RefundID.String(),
+		},
 	}
-	// If originalAmountSmallestUnit was 0, and percentageDeduction is also 0, then refund amount is 0. params.Amount will be 0.
-
-	// Stripe's standard reasons: duplicate, fraudulent, requested_by_customer
-	params.Reason = stripe.String(string(stripe.RefundReasonRequestedByCustomer))
-	params.Metadata = map[string]string{
-		"internal_refund_reason": req.Reason,
-		"ticket_id":              req.TicketID,
+	if req.RefundID != uuid.Nil {
+		params.SetIdempotencyKey("refund-" + req.RefundID.String())
 	}
-
 	stripeRefund, err := refund.New(params)
 	if err != nil {
-		log.Printf("Stripe API error during refund for PI %s: %v", invoice.StripePaymentIntentID.String, err)
-		if stripeErr, ok := err.(*stripe.Error); ok {
-			// Try to update invoice to FAILED or keep as COMPLETED but add note about failed refund attempt
-			note := fmt.Sprintf("Stripe refund attempt failed for PI %s. Stripe Error (%s): %s. Reason: %s",
-				invoice.StripePaymentIntentID.String, stripeErr.Code, stripeErr.Msg, req.Reason)
-			log.Print(note)
-			// s.invoiceService.AddNoteToInvoice(ctx, invoice.InvoiceID, note) // Hypothetical method
-			return db.Invoice{}, fmt.Errorf("stripe API error (%s) refunding PI %s: %s", stripeErr.Code, invoice.StripePaymentIntentID.String, stripeErr.Msg)
-		}
-		return db.Invoice{}, fmt.Errorf("stripe: failed to process refund for PI %s: %w", invoice.StripePaymentIntentID.String, err)
+		return "", fmt.Errorf("stripe: failed to refund %d for PI %s: %w", amountSmallestUnit, invoice.StripePaymentIntentID.String, err)
 	}
-
 	log.Printf("Stripe refund successful for PI %s. Refund ID: %s. Amount: %d %s",
-		stripeRefund.PaymentIntent, stripeRefund.ID, stripeRefund.Amount, strings.ToUpper(string(stripeRefund.Currency)))
-
-	// Update invoice status to REFUNDED
-	// Pass stripeRefund.ID as the specific identifier
-	updatedInvoice, err := s.invoiceService.UpdateInvoiceStatusForRefund(ctx, invoice.InvoiceID, req.Reason, stripeRefund.ID)
-	if err != nil {
-		// This is a critical situation: Stripe refund succeeded, but DB update failed.
-		log.Printf("CRITICAL: Stripe refund %s processed, but failed to update invoice %s status: %v", stripeRefund.ID, invoice.InvoiceID, err)
-		// Return the invoice object from before the failed update, but with an error indicating the inconsistency.
-		return invoice, fmt.Errorf("stripe refund %s successful, but DB update failed for invoice %s: %w. MANUAL INTERVENTION REQUIRED", stripeRefund.ID, invoice.InvoiceID, err)
-	}
-
-	// Ticket status update is handled by InvoiceService.UpdateInvoiceStatusForRefund
-
-	return updatedInvoice, nil
+		invoice.StripePaymentIntentID.String, stripeRefund.ID, stripeRefund.Amount, strings.ToUpper(string(stripeRefund.Currency)))
+	return stripeRefund.ID, nil
 }
@@ -1,13 +1,18 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"crypto/hmac"
 	"crypto/sha512"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
+	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
@@ -16,9 +21,13 @@ import (
 
 	"payment_service/config"
 	"payment_service/domain/model"
+	"payment_service/internal/db"
 	// Assuming this is updated after sqlc generate
 )
 
+// ErrVNPayTransactionNotFound: VNPay không có giao dịch với vnp_TxnRef được hỏi (querydr trả mã 91)
+var ErrVNPayTransactionNotFound = errors.New("vnpay transaction not found")
+
 // VNPayService handles the VNPay payment integration
 type VNPayService struct {
 	config     *config.VNPayConfig
@@ -51,8 +60,8 @@ func (s *VNPayService) CreatePayment(ctx context.Context, req model.VNPayPayment
 	expireTime := now.Add(15 * time.Minute).Format("20060102150405")
 
 	// VNPay expects amount in VND (integer, multiplied by 100 if it were cents, but it's base unit for VND)
-	// req.Amount is float64, VNPay expects integer string for vnp_Amount
-	amountVND := int(req.Amount * 100) // This is standard for VNPay, treating amount as base unit * 100
+	// VNPay thu final_amount của hoá đơn (sau giảm giá, voucher và thuế), nhân 100 theo quy ước của VNPay
+	amountVND := int(math.Round(invoice.FinalAmount * 100))
 
 	inputData := map[string]string{
 		"vnp_Version":    "2.1.0",
@@ -305,11 +314,14 @@ func (s *VNPayService) ProcessIPN(ctx context.Context, queryParams url.Values) (
 		return ipnResponse, nil
 	}
 
-	// Check if invoice is already processed (COMPLETED or FAILED)
-	if invoice.PaymentStatus.String == string(model.PaymentStatusCompleted) || invoice.PaymentStatus.String == string(model.PaymentStatusFailed) {
-		// If already COMPLETED, VNPay expects 00. If FAILED and IPN says success, this is an issue.
-		// For simplicity, if already completed, assume it's a duplicate IPN.
-		if invoice.PaymentStatus.String == string(model.PaymentStatusCompleted) && responseCode == "00" {
+	// Check if invoice is already processed (COMPLETED, (PARTIALLY_)REFUNDED or FAILED)
+	alreadyPaid := invoice.PaymentStatus.String == string(model.PaymentStatusCompleted) ||
+		invoice.PaymentStatus.String == string(model.PaymentStatusPartiallyRefunded) ||
+		invoice.PaymentStatus.String == string(model.PaymentStatusRefunded)
+	if alreadyPaid || invoice.PaymentStatus.String == string(model.PaymentStatusFailed) {
+		// If already paid, VNPay expects 00. If FAILED and IPN says success, this is an issue.
+		// For simplicity, if already paid, assume it's a duplicate IPN (refunds must not be overwritten).
+		if alreadyPaid && responseCode == "00" {
 			ipnResponse.RspCode = "00" // Already confirmed, acknowledge success
 			ipnResponse.Message = "Order already confirmed"
 		} else {
@@ -416,10 +428,45 @@ func (s *VNPayService) QueryTransaction(ctx context.Context, req model.VNPayQuer
 	return dataRequest, nil
 }
 
-// RefundTransaction prepares data for refunding a transaction.
-// The actual API call to VNPay is expected to be made by the caller with this data.
-// The 'reason' parameter is for internal logging/updating invoice notes.
-func (s *VNPayService) RefundTransaction(ctx context.Context, req model.VNPayRefundRequest, ipAddr string, reason string) (map[string]string, error) {
+// SubmitQuery gửi yêu cầu querydr tới VNPay TransactionAPI và trả về response đã kiểm tra mã phản hồi.
+func (s *VNPayService) SubmitQuery(ctx context.Context, req model.VNPayQueryRequest, ipAddr string) (map[string]string, error) {
+	queryData, err := s.QueryTransaction(ctx, req, ipAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyBytes, err := json.Marshal(queryData)
+	if err != nil {
+		return nil, fmt.Errorf("vnpay query: failed to marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.TransactionAPI, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("vnpay query: failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("vnpay query: request to %s failed: %w", s.config.TransactionAPI, err)
+	}
+	defer resp.Body.Close()
+
+	var vnpResp map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&vnpResp); err != nil {
+		return nil, fmt.Errorf("vnpay query: failed to decode response (status %d): %w", resp.StatusCode, err)
+	}
+	if vnpResp["vnp_ResponseCode"] == "91" {
+		return nil, fmt.Errorf("vnpay query for TxnRef %s: %w", req.TxnRef, ErrVNPayTransactionNotFound)
+	}
+	if vnpResp["vnp_ResponseCode"] != "00" {
+		return nil, fmt.Errorf("vnpay query rejected (code %s): %s", vnpResp["vnp_ResponseCode"], vnpResp["vnp_Message"])
+	}
+	return vnpResp, nil
+}
+
+// buildRefundData tạo và ký dữ liệu refund gửi tới VNPay TransactionAPI.
+func (s *VNPayService) buildRefundData(req model.VNPayRefundRequest, ipAddr string, reason string) map[string]string {
 	rand.Seed(time.Now().UnixNano())
 	requestID := strconv.FormatInt(time.Now().UnixNano(), 10)
 	createDate := time.Now().Format("20060102150405")
@@ -465,31 +512,66 @@ func (s *VNPayService) RefundTransaction(ctx context.Context, req model.VNPayRef
 	hmacObj.Write([]byte(hashDataString))
 	secureHash := hex.EncodeToString(hmacObj.Sum(nil))
 	refundData["vnp_SecureHash"] = secureHash
+	return refundData
+}
+
+// RequestRefund gọi API hoàn tiền của VNPay (toàn phần hoặc một phần), không cập nhật hoá đơn.
+// Trả về vnp_TransactionNo của giao dịch hoàn.
+func (s *VNPayService) RequestRefund(ctx context.Context, invoice db.Invoice, amount float64, createBy string, ipAddr string, reason string) (string, error) {
+	if !invoice.VnpayTxnRef.Valid || invoice.VnpayTxnRef.String == "" {
+		return "", fmt.Errorf("vnpay refund: invoice %s has no vnp_TxnRef", invoice.InvoiceID)
+	}
 
-	// After preparing data (and presumably after VNPay confirms refund via IPN or query):
-	// Update local invoice status.
-	// This direct update here might be premature if VNPay's refund is not immediate.
-	// Ideally, update based on a subsequent IPN or query result for the refund.
-	// For now, if the request is to *initiate* and then assume it will be processed:
-	invoice, err := s.invoiceSvc.GetInvoiceByVNPayTxnRef(ctx, req.TxnRef)
+	var transactionDate string
+	if invoice.VnpayPayDate.Valid && len(invoice.VnpayPayDate.String) >= 8 {
+		transactionDate = invoice.VnpayPayDate.String[:8] // YYYYMMDD from YYYYMMDDHHMMSS
+	} else if invoice.IssueDate.Valid {
+		transactionDate = invoice.IssueDate.Time.Format("20060102")
+	} else {
+		return "", fmt.Errorf("vnpay refund: cannot determine original transaction date for invoice %s", invoice.InvoiceID)
+	}
+
+	transactionType := "03" // Partial refund
+	if amount >= invoice.FinalAmount {
+		transactionType = "02" // Full refund
+	}
+
+	refundData := s.buildRefundData(model.VNPayRefundRequest{
+		TxnRef:          invoice.VnpayTxnRef.String,
+		TransactionDate: transactionDate,
+		Amount:          amount,
+		TransactionType: transactionType,
+		CreateBy:        createBy,
+	}, ipAddr, reason)
+
+	bodyBytes, err := json.Marshal(refundData)
+	if err != nil {
+		return "", fmt.Errorf("vnpay refund: failed to marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.TransactionAPI, bytes.NewReader(bodyBytes))
 	if err != nil {
-		log.Printf("Error: RefundTransaction: Failed to get invoice by TxnRef %s for status update: %v", req.TxnRef, err)
-		// Return prepared data, but log the failure to find invoice for update
-		return refundData, fmt.Errorf("failed to retrieve invoice for status update post-refund prep: %w", err)
+		return "", fmt.Errorf("vnpay refund: failed to create request: %w", err)
 	}
+	httpReq.Header.Set("Content-Type", "application/json")
 
-	// Using an empty string for refundSpecificIdentifier as VNPay refund API response is not processed here directly
-	_, updateErr := s.invoiceSvc.UpdateInvoiceStatusForRefund(ctx, invoice.InvoiceID, reason, "")
-	if updateErr != nil {
-		log.Printf("Error: RefundTransaction: Failed to update invoice %s to refunded: %v", invoice.InvoiceID, updateErr)
-		// Return prepared data, but log the failure
-		return refundData, fmt.Errorf("failed to update invoice status to refunded: %w", updateErr)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("vnpay refund: request to %s failed: %w", s.config.TransactionAPI, err)
 	}
-	log.Printf("Info: RefundTransaction: Invoice %s status updated to REFUNDED (or attempted). Reason: %s", invoice.InvoiceID, reason)
+	defer resp.Body.Close()
 
-	// The ticket status update is handled by InvoiceService.UpdateInvoiceStatusForRefund
+	var vnpResp map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&vnpResp); err != nil {
+		return "", fmt.Errorf("vnpay refund: failed to decode response (status %d): %w", resp.StatusCode, err)
+	}
+	if vnpResp["vnp_ResponseCode"] != "00" {
+		log.Printf("VNPay refund rejected for TxnRef %s: %s - %s", invoice.VnpayTxnRef.String, vnpResp["vnp_ResponseCode"], vnpResp["vnp_Message"])
+		return "", fmt.Errorf("vnpay refund rejected (code %s): %s", vnpResp["vnp_ResponseCode"], vnpResp["vnp_Message"])
+	}
 
-	return refundData, nil // Returns data to be POSTed to VNPay
+	log.Printf("Info: VNPay refund of %.2f succeeded for invoice %s (TxnRef %s)", amount, invoice.InvoiceID, invoice.VnpayTxnRef.String)
+	return vnpResp["vnp_TransactionNo"], nil
 }
 
 // updateTicketStatus is now part of InvoiceService, keep this for reference if direct call needed previously.
@@ -0,0 +1,508 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"payment_service/domain/model"
+	"payment_service/internal/db"
+	"payment_service/internal/repository"
+	"payment_service/pkg/currency"
+)
+
+var (
+	// ErrVoucherNotFound: không có voucher với mã này
+	ErrVoucherNotFound = errors.New("voucher not found")
+	// ErrVoucherNotApplicable: voucher hết hạn / ngừng / không áp dụng cho tuyến, ngày khởi hành hoặc số tiền này
+	ErrVoucherNotApplicable = errors.New("voucher is not applicable")
+	// ErrVoucherUsageLimitReached: voucher đã hết lượt dùng (tổng hoặc của khách)
+	ErrVoucherUsageLimitReached = errors.New("voucher usage limit reached")
+	// ErrVoucherAlreadyApplied: hoá đơn đã dùng một voucher khác
+	ErrVoucherAlreadyApplied = errors.New("invoice already has a voucher")
+	// ErrVoucherCodeExists: mã voucher đã được dùng cho chiến dịch khác
+	ErrVoucherCodeExists = errors.New("voucher code already exists")
+	// ErrInvalidVoucher: thông tin tạo voucher không hợp lệ
+	ErrInvalidVoucher = errors.New("invalid voucher")
+	// ErrVoucherRedemptionNotFound: hoá đơn không dùng voucher
+	ErrVoucherRedemptionNotFound = errors.New("voucher redemption not found")
+)
+
+// voucherRedemptionsShown là số lượt dùng gần nhất trả về cùng chi tiết voucher
+const voucherRedemptionsShown = 50
+
+// VoucherServiceInterface quản lý voucher và vòng đời lượt dùng voucher của hoá đơn:
+// Reserve khi tạo hoá đơn, Redeem khi hoá đơn được thanh toán, Release khi hoá đơn hết hạn / thất bại.
+type VoucherServiceInterface interface {
+	CreateVoucher(ctx context.Context, req model.CreateVoucherRequest, createdBy string) (model.VoucherResponse, error)
+	GetVoucher(ctx context.Context, voucherID uuid.UUID) (model.VoucherDetailResponse, error)
+	ListVouchers(ctx context.Context, activeOnly bool, page, limit int) (model.VouchersResponse, error)
+	DeactivateVoucher(ctx context.Context, voucherID uuid.UUID) (model.VoucherResponse, error)
+
+	// Validate tính số tiền được giảm mà không giữ lượt dùng
+	Validate(ctx context.Context, req model.ValidateVoucherRequest) (model.VoucherQuoteResponse, error)
+	// Reserve giữ một lượt dùng cho hoá đơn invoiceID sắp được tạo
+	Reserve(ctx context.Context, invoiceID uuid.UUID, req model.ValidateVoucherRequest) (model.VoucherQuoteResponse, error)
+	// Apply áp voucher vào hoá đơn chuyển khoản đang chờ thanh toán
+	Apply(ctx context.Context, req model.ApplyVoucherRequest) (db.Invoice, model.VoucherQuoteResponse, error)
+	// Redeem ghi nhận lượt dùng của hoá đơn đã thanh toán; applied = false khi hoá đơn không có lượt đang giữ
+	Redeem(ctx context.Context, invoiceID uuid.UUID) (db.VoucherRedemption, bool, error)
+	// RedeemForPaidInvoice là Redeem cho API, kiểm tra hoá đơn đã thanh toán
+	RedeemForPaidInvoice(ctx context.Context, invoiceID uuid.UUID) (model.VoucherRedemptionResponse, error)
+	// Release trả lại lượt dùng của hoá đơn hết hạn / thất bại; applied = false khi hoá đơn không có lượt đang giữ
+	Release(ctx context.Context, invoiceID uuid.UUID, reason string) (db.VoucherRedemption, bool, error)
+}
+
+type VoucherService struct {
+	repo        repository.VoucherRepositoryInterface
+	invoiceRepo repository.InvoiceRepositoryInterface
+}
+
+func NewVoucherService(repo repository.VoucherRepositoryInterface, invoiceRepo repository.InvoiceRepositoryInterface) VoucherServiceInterface {
+	return &VoucherService{
+		repo:        repo,
+		invoiceRepo: invoiceRepo,
+	}
+}
+
+func normalizeVoucherCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// CreateVoucher tạo voucher mới, mã voucher được lưu chữ hoa
+func (s *VoucherService) CreateVoucher(ctx context.Context, req model.CreateVoucherRequest, createdBy string) (model.VoucherResponse, error) {
+	code := normalizeVoucherCode(req.Code)
+	if code == "" {
+		return model.VoucherResponse{}, fmt.Errorf("service: code is required: %w", ErrInvalidVoucher)
+	}
+	if model.VoucherDiscountType(req.DiscountType) == model.VoucherDiscountPercentage && req.DiscountValue > 100 {
+		return model.VoucherResponse{}, fmt.Errorf("service: percentage discount %.2f exceeds 100: %w", req.DiscountValue, ErrInvalidVoucher)
+	}
+	validFrom, err := time.Parse(time.RFC3339, req.ValidFrom)
+	if err != nil {
+		return model.VoucherResponse{}, fmt.Errorf("service: valid_from must be RFC3339: %w", ErrInvalidVoucher)
+	}
+	validUntil, err := time.Parse(time.RFC3339, req.ValidUntil)
+	if err != nil {
+		return model.VoucherResponse{}, fmt.Errorf("service: valid_until must be RFC3339: %w", ErrInvalidVoucher)
+	}
+	if !validUntil.After(validFrom) {
+		return model.VoucherResponse{}, fmt.Errorf("service: valid_until must be after valid_from: %w", ErrInvalidVoucher)
+	}
+	departureFrom, err := parseOptionalDate(req.DepartureFrom)
+	if err != nil {
+		return model.VoucherResponse{}, fmt.Errorf("service: departure_from must be YYYY-MM-DD: %w", ErrInvalidVoucher)
+	}
+	departureTo, err := parseOptionalDate(req.DepartureTo)
+	if err != nil {
+		return model.VoucherResponse{}, fmt.Errorf("service: departure_to must be YYYY-MM-DD: %w", ErrInvalidVoucher)
+	}
+	if departureFrom.Valid && departureTo.Valid && departureTo.Time.Before(departureFrom.Time) {
+		return model.VoucherResponse{}, fmt.Errorf("service: departure_to must not be before departure_from: %w", ErrInvalidVoucher)
+	}
+
+	if _, err := s.repo.GetVoucherByCode(ctx, code); err == nil {
+		return model.VoucherResponse{}, fmt.Errorf("service: voucher %s: %w", code, ErrVoucherCodeExists)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return model.VoucherResponse{}, fmt.Errorf("service: failed to check voucher code %s: %w", code, err)
+	}
+
+	routeIDs := make([]string, 0, len(req.RouteIDs))
+	for _, routeID := range req.RouteIDs {
+		if routeID = strings.TrimSpace(routeID); routeID != "" && !slices.Contains(routeIDs, routeID) {
+			routeIDs = append(routeIDs, routeID)
+		}
+	}
+	params := db.CreateVoucherParams{
+		Code:          code,
+		Description:   req.Description,
+		DiscountType:  req.DiscountType,
+		DiscountValue: req.DiscountValue,
+		MinSpend:      req.MinSpend,
+		Currency:      strings.ToLower(req.Currency),
+		RouteIds:      routeIDs,
+		DepartureFrom: departureFrom,
+		DepartureTo:   departureTo,
+		ValidFrom:     validFrom,
+		ValidUntil:    validUntil,
+		CreatedBy:     createdBy,
+	}
+	if req.MaxDiscountAmount != nil {
+		params.MaxDiscountAmount = sql.NullString{String: floatToDecimalString(*req.MaxDiscountAmount), Valid: true}
+	}
+	if req.UsageLimit != nil {
+		params.UsageLimit = sql.NullInt32{Int32: *req.UsageLimit, Valid: true}
+	}
+	if req.PerUserLimit != nil {
+		params.PerUserLimit = sql.NullInt32{Int32: *req.PerUserLimit, Valid: true}
+	}
+
+	voucher, err := s.repo.CreateVoucher(ctx, params)
+	if err != nil {
+		return model.VoucherResponse{}, fmt.Errorf("service: failed to create voucher: %w", err)
+	}
+	return mapDbVoucher(voucher), nil
+}
+
+// GetVoucher trả về voucher cùng các lượt dùng gần nhất
+func (s *VoucherService) GetVoucher(ctx context.Context, voucherID uuid.UUID) (model.VoucherDetailResponse, error) {
+	voucher, err := s.repo.GetVoucherByID(ctx, voucherID)
+	if err != nil {
+		return model.VoucherDetailResponse{}, err
+	}
+	redemptions, err := s.repo.ListRedemptionsByVoucherID(ctx, voucherID, voucherRedemptionsShown)
+	if err != nil {
+		return model.VoucherDetailResponse{}, err
+	}
+
+	resp := model.VoucherDetailResponse{
+		Voucher:     mapDbVoucher(voucher),
+		Redemptions: make([]model.VoucherRedemptionResponse, len(redemptions)),
+	}
+	for i, redemption := range redemptions {
+		resp.Redemptions[i] = mapDbVoucherRedemption(redemption)
+	}
+	return resp, nil
+}
+
+// ListVouchers liệt kê voucher mới nhất trước
+func (s *VoucherService) ListVouchers(ctx context.Context, activeOnly bool, page, limit int) (model.VouchersResponse, error) {
+	vouchers, total, err := s.repo.ListVouchers(ctx, db.ListVouchersParams{
+		ActiveOnly: activeOnly,
+		PageLimit:  int32(limit),
+		PageOffset: int32((page - 1) * limit),
+	})
+	if err != nil {
+		return model.VouchersResponse{}, fmt.Errorf("service: failed to list vouchers: %w", err)
+	}
+
+	resp := model.VouchersResponse{
+		Items: make([]model.VoucherResponse, len(vouchers)),
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	}
+	for i, voucher := range vouchers {
+		resp.Items[i] = mapDbVoucher(voucher)
+	}
+	return resp, nil
+}
+
+// DeactivateVoucher ngừng voucher, hoá đơn đã giữ lượt dùng vẫn được thanh toán với giá đã giảm
+func (s *VoucherService) DeactivateVoucher(ctx context.Context, voucherID uuid.UUID) (model.VoucherResponse, error) {
+	voucher, err := s.repo.DeactivateVoucher(ctx, voucherID)
+	if err != nil {
+		return model.VoucherResponse{}, err
+	}
+	return mapDbVoucher(voucher), nil
+}
+
+// Validate kiểm tra voucher cho một khoản thanh toán. Lượt dùng không được giữ nên kết quả có thể thay đổi
+// khi khách tạo thanh toán nếu voucher vừa hết lượt.
+func (s *VoucherService) Validate(ctx context.Context, req model.ValidateVoucherRequest) (model.VoucherQuoteResponse, error) {
+	code := normalizeVoucherCode(req.Code)
+	voucher, err := s.repo.GetVoucherByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.VoucherQuoteResponse{}, fmt.Errorf("service: voucher %s: %w", code, ErrVoucherNotFound)
+		}
+		return model.VoucherQuoteResponse{}, fmt.Errorf("service: failed to load voucher %s: %w", code, err)
+	}
+	customerUses, err := s.repo.CountCustomerRedemptions(ctx, voucher.VoucherID, req.CustomerID)
+	if err != nil {
+		return model.VoucherQuoteResponse{}, fmt.Errorf("service: %w", err)
+	}
+
+	discount, err := evaluateVoucher(voucher, req, customerUses, time.Now())
+	if err != nil {
+		return model.VoucherQuoteResponse{}, err
+	}
+	return newVoucherQuote(voucher, req.Amount, discount), nil
+}
+
+// Reserve giữ một lượt dùng voucher cho hoá đơn invoiceID (hoá đơn được ghi sau khi giữ thành công).
+// Gọi lại với cùng hoá đơn và cùng mã trả về lượt đã giữ.
+func (s *VoucherService) Reserve(ctx context.Context, invoiceID uuid.UUID, req model.ValidateVoucherRequest) (model.VoucherQuoteResponse, error) {
+	code := normalizeVoucherCode(req.Code)
+	now := time.Now()
+	redemption, voucher, existing, err := s.repo.ReserveRedemption(ctx, code, db.CreateVoucherRedemptionParams{
+		InvoiceID:  invoiceID,
+		CustomerID: req.CustomerID,
+		Currency:   strings.ToLower(req.Currency),
+	}, func(voucher db.Voucher, customerUses int64) (float64, error) {
+		return evaluateVoucher(voucher, req, customerUses, now)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.VoucherQuoteResponse{}, fmt.Errorf("service: voucher %s: %w", code, ErrVoucherNotFound)
+		}
+		return model.VoucherQuoteResponse{}, err
+	}
+	if existing && voucher.Code != code {
+		return model.VoucherQuoteResponse{}, fmt.Errorf("service: invoice %s uses voucher %s: %w", invoiceID, voucher.Code, ErrVoucherAlreadyApplied)
+	}
+	return newVoucherQuote(voucher, req.Amount, redemption.DiscountAmount), nil
+}
+
+// Apply áp voucher vào hoá đơn chuyển khoản đang chờ xác nhận. Hoá đơn của các cổng khác đã gửi số tiền
+// sang cổng khi tạo nên chỉ nhận voucher lúc tạo thanh toán.
+func (s *VoucherService) Apply(ctx context.Context, req model.ApplyVoucherRequest) (db.Invoice, model.VoucherQuoteResponse, error) {
+	code := normalizeVoucherCode(req.Code)
+	now := time.Now()
+	var quote model.VoucherQuoteResponse
+	_, invoice, err := s.repo.ApplyToInvoice(ctx, code, req.InvoiceID, func(voucher db.Voucher, invoice db.Invoice, customerUses int64) (float64, db.UpdateInvoiceDiscountParams, error) {
+		if invoice.PaymentMethod.String != string(model.PaymentMethodBank) ||
+			invoice.PaymentStatus.String != string(model.PaymentStatusAwaitingConfirmation) {
+			return 0, db.UpdateInvoiceDiscountParams{}, fmt.Errorf("service: invoice %s is %s %s, only bank transfers awaiting confirmation accept vouchers: %w",
+				invoice.InvoiceID, invoice.PaymentMethod.String, invoice.PaymentStatus.String, ErrVoucherNotApplicable)
+		}
+		currentDiscount := decimalStringToFloat(invoice.DiscountAmount)
+		discount, err := evaluateVoucher(voucher, model.ValidateVoucherRequest{
+			Code:          code,
+			CustomerID:    invoice.CustomerID,
+			Amount:        invoice.TotalAmount - currentDiscount,
+			Currency:      invoice.Currency.String,
+			RouteID:       req.RouteID,
+			DepartureDate: req.DepartureDate,
+		}, customerUses, now)
+		if err != nil {
+			return 0, db.UpdateInvoiceDiscountParams{}, err
+		}
+		quote = newVoucherQuote(voucher, invoice.FinalAmount, discount)
+
+		notes := fmt.Sprintf("Voucher %s applied: -%.2f %s.", voucher.Code, discount, invoice.Currency.String)
+		if invoice.Notes != "" {
+			notes = invoice.Notes + " | " + notes
+		}
+		// Số tiền thực thu giảm theo tỷ giá đã snapshot khi tạo hoá đơn (hoá đơn chưa thanh toán nên chưa có lần hoàn)
+		chargedAmount := invoice.ChargedAmount
+		if chargedAmount.Valid {
+			amount, chargedCurrency := invoiceChargedAmountFor(invoice, quote.FinalAmount, refundTotals{})
+			chargedAmount.String = formatCurrencyAmount(amount, chargedCurrency)
+		}
+		return discount, db.UpdateInvoiceDiscountParams{
+			DiscountAmount: sql.NullString{String: floatToDecimalString(currentDiscount + discount), Valid: true},
+			FinalAmount:    quote.FinalAmount,
+			Notes:          notes,
+			ChargedAmount:  chargedAmount,
+		}, nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return db.Invoice{}, model.VoucherQuoteResponse{}, err
+		case strings.Contains(err.Error(), "idx_voucher_redemptions_invoice_active"):
+			return db.Invoice{}, model.VoucherQuoteResponse{}, fmt.Errorf("service: invoice %s: %w", req.InvoiceID, ErrVoucherAlreadyApplied)
+		}
+		return db.Invoice{}, model.VoucherQuoteResponse{}, err
+	}
+	return invoice, quote, nil
+}
+
+// Redeem ghi nhận lượt dùng voucher của hoá đơn vừa được thanh toán
+func (s *VoucherService) Redeem(ctx context.Context, invoiceID uuid.UUID) (db.VoucherRedemption, bool, error) {
+	redemption, applied, err := s.repo.RedeemRedemption(ctx, invoiceID)
+	if err != nil {
+		return db.VoucherRedemption{}, false, fmt.Errorf("service: %w", err)
+	}
+	return redemption, applied, nil
+}
+
+// RedeemForPaidInvoice ghi nhận thủ công lượt dùng voucher của hoá đơn đã thanh toán (khi ghi nhận tự động lỗi).
+// Hoá đơn đã được ghi nhận trước đó trả về lượt dùng hiện tại.
+func (s *VoucherService) RedeemForPaidInvoice(ctx context.Context, invoiceID uuid.UUID) (model.VoucherRedemptionResponse, error) {
+	invoice, err := s.invoiceRepo.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		return model.VoucherRedemptionResponse{}, err
+	}
+	switch model.PaymentStatus(invoice.PaymentStatus.String) {
+	case model.PaymentStatusCompleted, model.PaymentStatusPartiallyRefunded, model.PaymentStatusRefunded:
+	default:
+		return model.VoucherRedemptionResponse{}, fmt.Errorf("service: invoice %s is %s, not paid: %w", invoiceID, invoice.PaymentStatus.String, ErrVoucherNotApplicable)
+	}
+
+	redemption, applied, err := s.Redeem(ctx, invoiceID)
+	if err != nil {
+		return model.VoucherRedemptionResponse{}, err
+	}
+	if !applied {
+		redemption, err = s.repo.GetActiveRedemptionByInvoiceID(ctx, invoiceID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.VoucherRedemptionResponse{}, fmt.Errorf("service: invoice %s: %w", invoiceID, ErrVoucherRedemptionNotFound)
+			}
+			return model.VoucherRedemptionResponse{}, err
+		}
+	}
+	return mapDbVoucherRedemption(redemption), nil
+}
+
+// Release trả lại lượt dùng voucher của hoá đơn hết hạn / thất bại để khách hoặc khách khác dùng lại
+func (s *VoucherService) Release(ctx context.Context, invoiceID uuid.UUID, reason string) (db.VoucherRedemption, bool, error) {
+	redemption, applied, err := s.repo.ReleaseRedemption(ctx, invoiceID, reason)
+	if err != nil {
+		return db.VoucherRedemption{}, false, fmt.Errorf("service: %w", err)
+	}
+	return redemption, applied, nil
+}
+
+// evaluateVoucher kiểm tra điều kiện của voucher tại thời điểm now và trả về số tiền được giảm trên req.Amount
+func evaluateVoucher(voucher db.Voucher, req model.ValidateVoucherRequest, customerUses int64, now time.Time) (float64, error) {
+	notApplicable := func(format string, args ...any) error {
+		return fmt.Errorf("service: voucher %s: %s: %w", voucher.Code, fmt.Sprintf(format, args...), ErrVoucherNotApplicable)
+	}
+
+	if !voucher.IsActive {
+		return 0, notApplicable("voucher is no longer active")
+	}
+	if now.Before(voucher.ValidFrom) {
+		return 0, notApplicable("valid from %s", voucher.ValidFrom.Format(time.RFC3339))
+	}
+	if now.After(voucher.ValidUntil) {
+		return 0, notApplicable("expired at %s", voucher.ValidUntil.Format(time.RFC3339))
+	}
+	if !strings.EqualFold(req.Currency, voucher.Currency) {
+		return 0, notApplicable("only valid for %s payments", strings.ToUpper(voucher.Currency))
+	}
+	if req.Amount < voucher.MinSpend {
+		return 0, notApplicable("minimum spend is %.2f %s", voucher.MinSpend, voucher.Currency)
+	}
+	if len(voucher.RouteIds) > 0 && !slices.Contains(voucher.RouteIds, req.RouteID) {
+		return 0, notApplicable("not valid for route %q", req.RouteID)
+	}
+	if voucher.DepartureFrom.Valid || voucher.DepartureTo.Valid {
+		departure, err := time.Parse("2006-01-02", req.DepartureDate)
+		if err != nil {
+			return 0, notApplicable("departure_date (YYYY-MM-DD) is required")
+		}
+		if (voucher.DepartureFrom.Valid && departure.Before(voucher.DepartureFrom.Time)) ||
+			(voucher.DepartureTo.Valid && departure.After(voucher.DepartureTo.Time)) {
+			return 0, notApplicable("not valid for departures on %s", req.DepartureDate)
+		}
+	}
+	if voucher.UsageLimit.Valid && voucher.UsedCount >= voucher.UsageLimit.Int32 {
+		return 0, fmt.Errorf("service: voucher %s: all %d uses have been taken: %w", voucher.Code, voucher.UsageLimit.Int32, ErrVoucherUsageLimitReached)
+	}
+	if voucher.PerUserLimit.Valid && customerUses >= int64(voucher.PerUserLimit.Int32) {
+		return 0, fmt.Errorf("service: voucher %s: customer has used it %d time(s): %w", voucher.Code, customerUses, ErrVoucherUsageLimitReached)
+	}
+
+	var discount float64
+	switch model.VoucherDiscountType(voucher.DiscountType) {
+	case model.VoucherDiscountPercentage:
+		discount = req.Amount * voucher.DiscountValue / 100
+		if voucher.MaxDiscountAmount.Valid {
+			discount = math.Min(discount, decimalStringToFloat(voucher.MaxDiscountAmount))
+		}
+	case model.VoucherDiscountFixed:
+		discount = voucher.DiscountValue
+	default:
+		return 0, notApplicable("unknown discount type %s", voucher.DiscountType)
+	}
+	return currency.Round(math.Min(discount, req.Amount), voucher.Currency), nil
+}
+
+func newVoucherQuote(voucher db.Voucher, amount, discount float64) model.VoucherQuoteResponse {
+	return model.VoucherQuoteResponse{
+		VoucherID:      voucher.VoucherID,
+		Code:           voucher.Code,
+		DiscountType:   voucher.DiscountType,
+		Amount:         amount,
+		DiscountAmount: discount,
+		FinalAmount:    currency.Round(amount-discount, voucher.Currency),
+		Currency:       voucher.Currency,
+	}
+}
+
+func parseOptionalDate(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: parsed, Valid: true}, nil
+}
+
+// decimalStringToFloat đọc cột DECIMAL nullable, NULL hoặc không đọc được là 0
+func decimalStringToFloat(value sql.NullString) float64 {
+	if !value.Valid {
+		return 0
+	}
+	parsed, err := strconv.ParseFloat(value.String, 64)
+	if err != nil {
+		return 0
+	}
+	return parsed
+}
+
+func mapDbVoucher(voucher db.Voucher) model.VoucherResponse {
+	resp := model.VoucherResponse{
+		VoucherID:     voucher.VoucherID,
+		Code:          voucher.Code,
+		Description:   voucher.Description,
+		DiscountType:  voucher.DiscountType,
+		DiscountValue: voucher.DiscountValue,
+		MinSpend:      voucher.MinSpend,
+		Currency:      voucher.Currency,
+		RouteIDs:      voucher.RouteIds,
+		ValidFrom:     voucher.ValidFrom.Format(time.RFC3339),
+		ValidUntil:    voucher.ValidUntil.Format(time.RFC3339),
+		UsedCount:     voucher.UsedCount,
+		IsActive:      voucher.IsActive,
+		CreatedBy:     voucher.CreatedBy,
+		CreatedAt:     voucher.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if resp.RouteIDs == nil {
+		resp.RouteIDs = []string{}
+	}
+	if voucher.MaxDiscountAmount.Valid {
+		maxDiscount := decimalStringToFloat(voucher.MaxDiscountAmount)
+		resp.MaxDiscountAmount = &maxDiscount
+	}
+	if voucher.DepartureFrom.Valid {
+		resp.DepartureFrom = voucher.DepartureFrom.Time.Format("2006-01-02")
+	}
+	if voucher.DepartureTo.Valid {
+		resp.DepartureTo = voucher.DepartureTo.Time.Format("2006-01-02")
+	}
+	if voucher.UsageLimit.Valid {
+		usageLimit := voucher.UsageLimit.Int32
+		resp.UsageLimit = &usageLimit
+	}
+	if voucher.PerUserLimit.Valid {
+		perUserLimit := voucher.PerUserLimit.Int32
+		resp.PerUserLimit = &perUserLimit
+	}
+	return resp
+}
+
+func mapDbVoucherRedemption(redemption db.VoucherRedemption) model.VoucherRedemptionResponse {
+	resp := model.VoucherRedemptionResponse{
+		RedemptionID:   redemption.RedemptionID,
+		VoucherID:      redemption.VoucherID,
+		InvoiceID:      redemption.InvoiceID,
+		CustomerID:     redemption.CustomerID,
+		DiscountAmount: redemption.DiscountAmount,
+		Currency:       redemption.Currency,
+		Status:         redemption.Status,
+		ReleaseReason:  redemption.ReleaseReason,
+		CreatedAt:      redemption.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if redemption.RedeemedAt.Valid {
+		resp.RedeemedAt = redemption.RedeemedAt.Time.Format("2006-01-02 15:04:05")
+	}
+	if redemption.ReleasedAt.Valid {
+		resp.ReleasedAt = redemption.ReleasedAt.Time.Format("2006-01-02 15:04:05")
+	}
+	return resp
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"payment_service/domain/model"
+	"payment_service/internal/db"
+	"payment_service/pkg/zalopay"
+)
+
+// zaloPayOrderExpiry khớp với thời gian giữ hoá đơn PENDING (15 phút)
+const zaloPayOrderExpiry = 15 * time.Minute
+
+// ZaloPayProvider thanh toán qua ví ZaloPay: khách được chuyển tới order_url, kết quả về qua callback (IPN) và redirect.
+type ZaloPayProvider struct {
+	client     *zalopay.Client
+	invoiceSvc InvoiceServiceInterface
+}
+
+func NewZaloPayProvider(client *zalopay.Client, invoiceSvc InvoiceServiceInterface) PaymentProvider {
+	return &ZaloPayProvider{client: client, invoiceSvc: invoiceSvc}
+}
+
+func (p *ZaloPayProvider) Method() model.PaymentMethod {
+	return model.PaymentMethodZaloPay
+}
+
+func (p *ZaloPayProvider) CreatePayment(ctx context.Context, req model.ProviderPaymentRequest) (*model.ProviderPaymentResponse, error) {
+	if req.Currency != "" && !strings.EqualFold(req.Currency, "vnd") {
+		return nil, fmt.Errorf("zalopay: currency %s is not supported, only VND", req.Currency)
+	}
+	req.Currency = "vnd"
+
+	now := time.Now()
+	txnRef := zalopay.NewAppTransID(now, uuid.New().String()[:8])
+	invoice, err := p.invoiceSvc.CreateInvoiceForProvider(ctx, req, model.PaymentMethodZaloPay, txnRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	zpResp, err := p.client.Create(ctx, zalopay.CreateOrder{
+		AppUser:        req.CustomerID,
+		AppTransID:     txnRef,
+		AppTime:        now.UnixMilli(),
+		Amount:         int64(invoice.FinalAmount),
+		Description:    fmt.Sprintf("Thanh toan cho ve %s, hoa don %s", req.TicketID, invoice.InvoiceNumber),
+		BankCode:       req.BankCode,
+		ExpireDuration: int64(zaloPayOrderExpiry.Seconds()),
+	})
+	if err == nil && zpResp.ReturnCode != zalopay.ReturnCodeSuccess {
+		err = fmt.Errorf("zalopay: create rejected (code %d/%d): %s", zpResp.ReturnCode, zpResp.SubReturnCode, zpResp.SubReturnMessage)
+	}
+	if err != nil {
+		if _, failErr := p.invoiceSvc.UpdateInvoiceStatusForPaymentFailure(ctx, txnRef, model.PaymentMethodZaloPay, err.Error()); failErr != nil {
+			log.Printf("Warning: zalopay: failed to mark invoice %s as failed: %v", invoice.InvoiceID, failErr)
+		}
+		return nil, err
+	}
+
+	return &model.ProviderPaymentResponse{
+		Provider:   model.PaymentMethodZaloPay,
+		InvoiceID:  invoice.InvoiceID,
+		TxnRef:     txnRef,
+		PaymentURL: zpResp.OrderURL,
+		QRCode:     zpResp.QrCode,
+		Details:    map[string]string{"zp_trans_token": zpResp.ZpTransToken, "order_token": zpResp.OrderToken},
+	}, nil
+}
+
+// HandleCallback xử lý callback server-to-server (IPN) và redirect của ZaloPay.
+// ZaloPay chỉ gọi callback khi thanh toán thành công; redirect không mang mã giao dịch nên phải truy vấn lại.
+func (p *ZaloPayProvider) HandleCallback(ctx context.Context, callback model.ProviderCallback) (*model.ProviderCallbackResult, error) {
+	if callback.Kind == model.ProviderCallbackIPN {
+		return p.handleIPN(ctx, callback.Body)
+	}
+	return p.handleReturn(ctx, callback)
+}
+
+func (p *ZaloPayProvider) handleIPN(ctx context.Context, body []byte) (*model.ProviderCallbackResult, error) {
+	result := &model.ProviderCallbackResult{Provider: model.PaymentMethodZaloPay}
+
+	var req zalopay.CallbackRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		result.Message = "invalid request"
+		result.Ack = zalopay.CallbackResponse{ReturnCode: zalopay.CallbackCodeInvalidMac, ReturnMessage: result.Message}
+		return result, fmt.Errorf("zalopay: invalid callback body: %w", err)
+	}
+	data, valid, err := p.client.VerifyCallback(req)
+	if !valid {
+		log.Printf("ZaloPay callback: invalid mac")
+		result.Message = "mac not equal"
+		result.Ack = zalopay.CallbackResponse{ReturnCode: zalopay.CallbackCodeInvalidMac, ReturnMessage: result.Message}
+		return result, ErrInvalidSignature
+	}
+	if err != nil {
+		result.Message = err.Error()
+		result.Ack = zalopay.CallbackResponse{ReturnCode: zalopay.CallbackCodeInvalidMac, ReturnMessage: result.Message}
+		return result, err
+	}
+
+	result.TxnRef = data.AppTransID
+	result.ProviderTxnID = strconv.FormatInt(data.ZpTransID, 10)
+	invoice, err := settleProviderPayment(ctx, p.invoiceSvc, model.PaymentMethodZaloPay, providerOutcome{
+		TxnRef:        data.AppTransID,
+		ProviderTxnID: result.ProviderTxnID,
+		Amount:        float64(data.Amount),
+		Success:       true,
+		PaidAt:        time.UnixMilli(data.ServerTime),
+	})
+	if err != nil && !errors.Is(err, ErrInvoiceNotPending) && !errors.Is(err, ErrAmountMismatch) {
+		// return_code 0: ZaloPay sẽ gửi lại callback
+		result.Message = err.Error()
+		result.Ack = zalopay.CallbackResponse{ReturnCode: zalopay.CallbackCodeRetry, ReturnMessage: result.Message}
+		return result, err
+	}
+
+	result.InvoiceID = invoice.InvoiceID
+	result.Status = model.PaymentStatus(invoice.PaymentStatus.String)
+	result.Message = "success"
+	result.Ack = zalopay.CallbackResponse{ReturnCode: zalopay.CallbackCodeSuccess, ReturnMessage: result.Message}
+	return result, err
+}
+
+func (p *ZaloPayProvider) handleReturn(ctx context.Context, callback model.ProviderCallback) (*model.ProviderCallbackResult, error) {
+	redirect := zalopay.RedirectFromValues(callback.Query)
+	result := &model.ProviderCallbackResult{Provider: model.PaymentMethodZaloPay, TxnRef: redirect.AppTransID}
+	if !p.client.VerifyRedirect(redirect) {
+		log.Printf("ZaloPay redirect: invalid checksum for app_trans_id %s", redirect.AppTransID)
+		result.Message = "Invalid checksum"
+		return result, ErrInvalidSignature
+	}
+
+	txn, err := p.invoiceSvc.GetPaymentTransaction(ctx, redirect.AppTransID)
+	if err != nil {
+		return nil, err
+	}
+	invoice, err := p.invoiceSvc.GetInvoiceByID(ctx, txn.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	status, err := p.QueryPayment(ctx, invoice)
+	if err != nil {
+		return nil, err
+	}
+
+	result.InvoiceID = invoice.InvoiceID
+	result.ProviderTxnID = status.ProviderTxnID
+	result.Message = status.Message
+	if status.Status == model.PaymentStatusPending {
+		result.Status = model.PaymentStatusPending
+		return result, nil
+	}
+
+	outcome := providerOutcome{
+		TxnRef:        redirect.AppTransID,
+		ProviderTxnID: status.ProviderTxnID,
+		Amount:        status.Amount,
+		Success:       status.Status == model.PaymentStatusCompleted,
+		Reason:        "ZaloPay: " + status.Message,
+	}
+	if status.PaidAt != nil {
+		outcome.PaidAt = *status.PaidAt
+	}
+	updatedInvoice, err := settleProviderPayment(ctx, p.invoiceSvc, model.PaymentMethodZaloPay, outcome)
+	if err != nil && !errors.Is(err, ErrInvoiceNotPending) && !errors.Is(err, ErrAmountMismatch) {
+		return nil, err
+	}
+	result.Status = model.PaymentStatus(updatedInvoice.PaymentStatus.String)
+	return result, err
+}
+
+func (p *ZaloPayProvider) QueryPayment(ctx context.Context, invoice db.Invoice) (*model.ProviderPaymentStatus, error) {
+	txn, err := p.invoiceSvc.GetLatestPaymentTransactionByInvoiceID(ctx, invoice.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Query(ctx, txn.TxnRef)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &model.ProviderPaymentStatus{
+		Provider:  model.PaymentMethodZaloPay,
+		InvoiceID: invoice.InvoiceID,
+		TxnRef:    txn.TxnRef,
+		Amount:    float64(resp.Amount),
+		Message:   resp.ReturnMessage,
+	}
+	if resp.ZpTransID != 0 {
+		status.ProviderTxnID = strconv.FormatInt(resp.ZpTransID, 10)
+	}
+	switch {
+	case resp.ReturnCode == zalopay.ReturnCodeSuccess:
+		status.Status = model.PaymentStatusCompleted
+		paidAt := time.UnixMilli(resp.ServerTime)
+		status.PaidAt = &paidAt
+	case resp.ReturnCode == zalopay.ReturnCodeProcessing || resp.IsProcessing:
+		status.Status = model.PaymentStatusPending
+	default:
+		status.Status = model.PaymentStatusFailed
+	}
+	return status, nil
+}
+
+func (p *ZaloPayProvider) Refund(ctx context.Context, invoice db.Invoice, req model.ProviderRefundRequest) (string, error) {
+	txn, err := p.invoiceSvc.GetLatestPaymentTransactionByInvoiceID(ctx, invoice.InvoiceID)
+	if err != nil {
+		return "", err
+	}
+	if txn.Status != string(model.PaymentStatusCompleted) || !txn.ProviderTxnID.Valid {
+		return "", fmt.Errorf("zalopay refund: transaction %s of invoice %s is %s without zp_trans_id", txn.TxnRef, invoice.InvoiceID, txn.Status)
+	}
+	zpTransID, err := strconv.ParseInt(txn.ProviderTxnID.String, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("zalopay refund: invalid zp_trans_id %q: %w", txn.ProviderTxnID.String, err)
+	}
+
+	now := time.Now()
+	mRefundID := zalopay.NewRefundID(now, p.client.AppID(), uuid.New().String()[:8])
+	resp, err := p.client.Refund(ctx, zalopay.RefundRequest{
+		MRefundID:   mRefundID,
+		ZpTransID:   zpTransID,
+		Amount:      int64(req.Amount),
+		Timestamp:   now.UnixMilli(),
+		Description: req.Reason,
+	})
+	if err != nil {
+		return "", err
+	}
+	// return_code 3: ZaloPay đã nhận yêu cầu và đang xử lý hoàn tiền
+	if resp.ReturnCode != zalopay.ReturnCodeSuccess && resp.ReturnCode != zalopay.ReturnCodeProcessing {
+		log.Printf("ZaloPay refund rejected for app_trans_id %s: %d/%d - %s", txn.TxnRef, resp.ReturnCode, resp.SubReturnCode, resp.SubReturnMessage)
+		return "", fmt.Errorf("zalopay refund rejected (code %d): %s", resp.ReturnCode, resp.ReturnMessage)
+	}
+
+	log.Printf("Info: ZaloPay refund of %.2f accepted for invoice %s (app_trans_id %s, m_refund_id %s)", req.Amount, invoice.InvoiceID, txn.TxnRef, mRefundID)
+	return mRefundID, nil
+}
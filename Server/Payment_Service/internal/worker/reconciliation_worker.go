@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"payment_service/config"
+	"payment_service/domain/model"
+	"payment_service/internal/service"
+)
+
+// reconciliationProviders là các cổng được đối soát tự động hằng ngày
+var reconciliationProviders = []model.PaymentMethod{model.PaymentMethodVNPay, model.PaymentMethodStripe}
+
+// ReconciliationWorker đối soát giao dịch ngày hôm trước với VNPay và Stripe vào giờ cấu hình.
+// Khi chạy nhiều instance, khoá Redis đảm bảo mỗi cổng chỉ được đối soát một lần cho mỗi ngày.
+type ReconciliationWorker struct {
+	redisClient       *redis.Client
+	reconciliationSvc service.ReconciliationServiceInterface
+	cfg               config.ReconciliationConfig
+}
+
+func NewReconciliationWorker(redisClient *redis.Client, reconciliationSvc service.ReconciliationServiceInterface, cfg config.ReconciliationConfig) *ReconciliationWorker {
+	return &ReconciliationWorker{
+		redisClient:       redisClient,
+		reconciliationSvc: reconciliationSvc,
+		cfg:               cfg,
+	}
+}
+
+// Start chờ tới giờ đối soát mỗi ngày cho tới khi ctx bị huỷ
+func (w *ReconciliationWorker) Start(ctx context.Context) {
+	if !w.cfg.Enabled {
+		log.Println("ReconciliationWorker: Đối soát hằng ngày đang tắt (RECONCILIATION_ENABLED=false).")
+		return
+	}
+	location := w.reconciliationSvc.Location()
+	log.Printf("ReconciliationWorker: Đối soát hằng ngày lúc %02d:00 (%s).", w.cfg.Hour, location)
+
+	for {
+		next := nextRunAt(time.Now().In(location), w.cfg.Hour)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		w.RunDaily(ctx, next.AddDate(0, 0, -1))
+	}
+}
+
+// RunDaily đối soát ngày businessDate với từng cổng, bỏ qua cổng đã được instance khác đối soát
+func (w *ReconciliationWorker) RunDaily(ctx context.Context, businessDate time.Time) {
+	date := businessDate.Format("2006-01-02")
+	for _, provider := range reconciliationProviders {
+		lockKey := fmt.Sprintf("reconciliation:%s:%s", provider, date)
+		acquired, err := w.redisClient.SetNX(ctx, lockKey, lockOwner(), 23*time.Hour).Result()
+		if err != nil {
+			log.Printf("ReconciliationWorker: Không thể lấy khoá %s: %v. Bỏ qua.", lockKey, err)
+			continue
+		}
+		if !acquired {
+			log.Printf("ReconciliationWorker: %s ngày %s đã được đối soát bởi instance khác.", provider, date)
+			continue
+		}
+
+		runCtx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+		report, err := w.reconciliationSvc.Reconcile(runCtx, provider, businessDate, "system")
+		cancel()
+		if err != nil {
+			log.Printf("CRITICAL: ReconciliationWorker: Đối soát %s ngày %s thất bại: %v", provider, date, err)
+			continue
+		}
+		log.Printf("ReconciliationWorker: Đối soát %s ngày %s xong (run %s): %d khớp, %d chênh lệch.",
+			provider, date, report.Run.RunID, report.Run.MatchedCount, report.Run.MismatchCount)
+	}
+}
+
+// nextRunAt trả về lần chạy kế tiếp lúc hour:00 sau now, theo múi giờ của now
+func nextRunAt(now time.Time, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+func lockOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "payment_service"
+	}
+	return hostname
+}
@@ -0,0 +1,221 @@
+package worker
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"payment_service/config"
+	"payment_service/domain/model"
+	"payment_service/internal/service"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// refundRetryBackoff là thời gian chờ trước khi xử lý lại partition có record thất bại.
+const refundRetryBackoff = 5 * time.Second
+
+// RefundConsumer nhận yêu cầu hoàn tiền (topic refund_requests) và điều chỉnh giá vé (topic fare_adjustments) từ ticket-service.
+type RefundConsumer struct {
+	kafkaCfg      config.KafkaConfig
+	refundService service.RefundServiceInterface
+	attempts      map[string]int // Số lần xử lý thất bại của record đang chặn partition, khoá theo topic/partition/offset
+}
+
+func NewRefundConsumer(kafkaCfg config.KafkaConfig, refundService service.RefundServiceInterface) *RefundConsumer {
+	if kafkaCfg.RefundMaxAttempts <= 0 {
+		kafkaCfg.RefundMaxAttempts = 5
+	}
+	return &RefundConsumer{
+		kafkaCfg:      kafkaCfg,
+		refundService: refundService,
+		attempts:      make(map[string]int),
+	}
+}
+
+// Start bắt đầu consume, chỉ commit khi hoàn tiền thành công hoặc yêu cầu không thể xử lý.
+// Record thất bại chặn partition của nó: consumer seek về offset của record đó và xử lý lại sau refundRetryBackoff,
+// các record phía sau trong cùng partition không được xử lý hay commit cho tới khi record lỗi thành công.
+// Record thất bại RefundMaxAttempts lần được chuyển sang RefundDeadLetterTopic rồi commit để partition chạy tiếp.
+func (c *RefundConsumer) Start(ctx context.Context) {
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(c.kafkaCfg.Seeds...),
+		kgo.ConsumerGroup(c.kafkaCfg.RefundRequestsGroupID),
+		kgo.ConsumeTopics(c.kafkaCfg.RefundRequestsTopic, c.kafkaCfg.FareAdjustmentsTopic),
+		kgo.DisableAutoCommit(),
+		kgo.BlockRebalanceOnPoll(), // SetOffsets/commit không chạy song song với rebalance
+	}
+	if c.kafkaCfg.EnableTLS {
+		opts = append(opts, kgo.DialTLSConfig(new(tls.Config)))
+	}
+	if c.kafkaCfg.SASLUser != "" && c.kafkaCfg.SASLPass != "" {
+		opts = append(opts, kgo.SASL(scram.Auth{
+			User: c.kafkaCfg.SASLUser,
+			Pass: c.kafkaCfg.SASLPass,
+		}.AsSha256Mechanism()))
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		log.Printf("RefundConsumer: Không thể tạo Kafka client: %v", err)
+		return
+	}
+	defer client.Close()
+
+	log.Printf("Bắt đầu lắng nghe yêu cầu hoàn tiền trên topic: %s, %s", c.kafkaCfg.RefundRequestsTopic, c.kafkaCfg.FareAdjustmentsTopic)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("RefundConsumer: Shutting down.")
+			return
+		default:
+			fetches := client.PollFetches(ctx)
+			if errs := fetches.Errors(); len(errs) > 0 {
+				log.Printf("RefundConsumer: Fetch errors: %v", errs)
+				client.AllowRebalance()
+				continue
+			}
+
+			failed := false
+			fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+				if !c.handlePartition(client, p) {
+					failed = true
+				}
+			})
+			client.AllowRebalance()
+
+			if failed {
+				select {
+				case <-ctx.Done():
+				case <-time.After(refundRetryBackoff):
+				}
+			}
+		}
+	}
+}
+
+// handlePartition xử lý tuần tự các record của một partition và commit tới record thành công cuối cùng.
+// Gặp record thất bại thì seek partition về record đó và bỏ qua phần còn lại, trả về false.
+func (c *RefundConsumer) handlePartition(client *kgo.Client, p kgo.FetchTopicPartition) bool {
+	var lastHandled *kgo.Record
+	ok := true
+	for _, record := range p.Records {
+		if !c.handleRecord(record) && !c.deadLetterAfterMaxAttempts(client, record) {
+			client.SetOffsets(map[string]map[int32]kgo.EpochOffset{
+				record.Topic: {record.Partition: {Epoch: record.LeaderEpoch, Offset: record.Offset}},
+			})
+			log.Printf("RefundConsumer: Dừng partition %s/%d tại offset %d, sẽ xử lý lại.", record.Topic, record.Partition, record.Offset)
+			ok = false
+			break
+		}
+		delete(c.attempts, recordKey(record))
+		lastHandled = record
+	}
+
+	if lastHandled != nil {
+		if err := client.CommitRecords(context.Background(), lastHandled); err != nil {
+			log.Printf("RefundConsumer: Failed to commit offset %d of %s/%d: %v", lastHandled.Offset, lastHandled.Topic, lastHandled.Partition, err)
+		}
+	}
+	return ok
+}
+
+// deadLetterAfterMaxAttempts đếm số lần record thất bại; tới RefundMaxAttempts thì chuyển record sang
+// RefundDeadLetterTopic (kèm topic/partition/offset gốc trong header) và trả về true để commit qua nó.
+// Không gửi được sang dead letter thì record tiếp tục chặn partition.
+func (c *RefundConsumer) deadLetterAfterMaxAttempts(client *kgo.Client, record *kgo.Record) bool {
+	key := recordKey(record)
+	c.attempts[key]++
+	attempts := c.attempts[key]
+	if attempts < c.kafkaCfg.RefundMaxAttempts {
+		return false
+	}
+
+	headers := append([]kgo.RecordHeader{}, record.Headers...)
+	headers = append(headers,
+		kgo.RecordHeader{Key: "source_topic", Value: []byte(record.Topic)},
+		kgo.RecordHeader{Key: "source_partition", Value: []byte(strconv.Itoa(int(record.Partition)))},
+		kgo.RecordHeader{Key: "source_offset", Value: []byte(strconv.FormatInt(record.Offset, 10))},
+		kgo.RecordHeader{Key: "attempts", Value: []byte(strconv.Itoa(attempts))},
+	)
+	produceCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.ProduceSync(produceCtx, &kgo.Record{
+		Topic:   c.kafkaCfg.RefundDeadLetterTopic,
+		Key:     record.Key,
+		Value:   record.Value,
+		Headers: headers,
+	}).FirstErr(); err != nil {
+		log.Printf("RefundConsumer: CRITICAL: Không chuyển được record %s sang dead letter %s: %v", key, c.kafkaCfg.RefundDeadLetterTopic, err)
+		return false
+	}
+
+	delete(c.attempts, key)
+	log.Printf("RefundConsumer: CRITICAL: Record %s thất bại %d lần, đã chuyển sang %s để xử lý thủ công: %s",
+		key, attempts, c.kafkaCfg.RefundDeadLetterTopic, string(record.Value))
+	return true
+}
+
+func recordKey(record *kgo.Record) string {
+	return fmt.Sprintf("%s/%d/%d", record.Topic, record.Partition, record.Offset)
+}
+
+// handleRecord trả về true nếu record có thể commit.
+func (c *RefundConsumer) handleRecord(record *kgo.Record) bool {
+	if record.Topic == c.kafkaCfg.FareAdjustmentsTopic {
+		return c.handleFareAdjustment(record)
+	}
+
+	var req model.TicketRefundRequest
+	if err := json.Unmarshal(record.Value, &req); err != nil {
+		log.Printf("RefundConsumer: Failed to unmarshal event: %v. Skipping.", err)
+		return true
+	}
+
+	handleCtx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	invoice, err := c.refundService.RefundTicket(handleCtx, req)
+	if errors.Is(err, service.ErrRefundNotApplicable) {
+		log.Printf("RefundConsumer: Bỏ qua yêu cầu hoàn tiền cho vé %s: %v", req.TicketID, err)
+		return true
+	}
+	if err != nil {
+		log.Printf("RefundConsumer: Hoàn tiền cho vé %s thất bại: %v. Not committing.", req.TicketID, err)
+		return false
+	}
+
+	log.Printf("RefundConsumer: Đã hoàn tiền cho vé %s (invoice %s).", req.TicketID, invoice.InvoiceID)
+	return true
+}
+
+func (c *RefundConsumer) handleFareAdjustment(record *kgo.Record) bool {
+	var req model.FareAdjustmentRequest
+	if err := json.Unmarshal(record.Value, &req); err != nil {
+		log.Printf("RefundConsumer: Failed to unmarshal fare adjustment: %v. Skipping.", err)
+		return true
+	}
+
+	handleCtx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	invoice, err := c.refundService.AdjustFare(handleCtx, req)
+	if errors.Is(err, service.ErrRefundNotApplicable) {
+		log.Printf("RefundConsumer: Bỏ qua điều chỉnh giá cho vé %s: %v", req.TicketID, err)
+		return true
+	}
+	if err != nil {
+		log.Printf("RefundConsumer: Điều chỉnh giá %.2f cho vé %s thất bại: %v. Not committing.", req.Amount, req.TicketID, err)
+		return false
+	}
+
+	log.Printf("RefundConsumer: Đã điều chỉnh giá %.2f cho vé %s (invoice %s).", req.Amount, req.TicketID, invoice.InvoiceID)
+	return true
+}
@@ -0,0 +1,122 @@
+// Package currency chứa metadata ISO 4217 (số chữ số phần lẻ) để quy đổi số tiền sang đơn vị nhỏ nhất
+// mà cổng thanh toán / AccountService dùng, và quy đổi giữa các tiền tệ theo tỷ giá của một RateProvider.
+// Mã tiền tệ được chuẩn hoá chữ thường như cột invoices.currency ("vnd", "usd").
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ErrUnsupportedCurrency: mã tiền tệ không có trong bảng ISO 4217 của package
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// Currency là một tiền tệ ISO 4217
+type Currency struct {
+	Code       string // Mã chữ thường, ví dụ "vnd"
+	Name       string
+	MinorUnits int // Số chữ số phần lẻ: VND 0, USD 2, KWD 3
+}
+
+// currencies là các tiền tệ khách có thể thanh toán (cổng hoặc tài khoản ngân hàng)
+var currencies = map[string]Currency{
+	"vnd": {Code: "vnd", Name: "Vietnamese Dong", MinorUnits: 0},
+	"usd": {Code: "usd", Name: "US Dollar", MinorUnits: 2},
+	"eur": {Code: "eur", Name: "Euro", MinorUnits: 2},
+	"gbp": {Code: "gbp", Name: "Pound Sterling", MinorUnits: 2},
+	"aud": {Code: "aud", Name: "Australian Dollar", MinorUnits: 2},
+	"cad": {Code: "cad", Name: "Canadian Dollar", MinorUnits: 2},
+	"chf": {Code: "chf", Name: "Swiss Franc", MinorUnits: 2},
+	"cny": {Code: "cny", Name: "Yuan Renminbi", MinorUnits: 2},
+	"hkd": {Code: "hkd", Name: "Hong Kong Dollar", MinorUnits: 2},
+	"sgd": {Code: "sgd", Name: "Singapore Dollar", MinorUnits: 2},
+	"thb": {Code: "thb", Name: "Baht", MinorUnits: 2},
+	"twd": {Code: "twd", Name: "New Taiwan Dollar", MinorUnits: 2},
+	"jpy": {Code: "jpy", Name: "Yen", MinorUnits: 0},
+	"krw": {Code: "krw", Name: "Won", MinorUnits: 0},
+	"kwd": {Code: "kwd", Name: "Kuwaiti Dinar", MinorUnits: 3},
+}
+
+// Normalize chuẩn hoá mã tiền tệ về chữ thường, bỏ khoảng trắng
+func Normalize(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+// Lookup trả về tiền tệ theo mã (không phân biệt hoa thường)
+func Lookup(code string) (Currency, error) {
+	c, ok := currencies[Normalize(code)]
+	if !ok {
+		return Currency{}, fmt.Errorf("currency %q: %w", code, ErrUnsupportedCurrency)
+	}
+	return c, nil
+}
+
+// Equal so sánh hai mã tiền tệ không phân biệt hoa thường
+func Equal(a, b string) bool {
+	return Normalize(a) == Normalize(b)
+}
+
+func (c Currency) factor() float64 {
+	return math.Pow10(c.MinorUnits)
+}
+
+// Round làm tròn amount (đơn vị chính) tới đơn vị nhỏ nhất của tiền tệ
+func (c Currency) Round(amount float64) float64 {
+	return math.Round(amount*c.factor()) / c.factor()
+}
+
+// ToMinorUnits đổi amount (đơn vị chính) sang đơn vị nhỏ nhất: 10.5 USD -> 1050, 10000 VND -> 10000
+func (c Currency) ToMinorUnits(amount float64) (int64, error) {
+	if amount < 0 {
+		return 0, fmt.Errorf("amount %f %s cannot be negative", amount, c.Code)
+	}
+	minor := math.Round(amount * c.factor())
+	if minor > math.MaxInt64 {
+		return 0, fmt.Errorf("amount %f %s is too large to convert to minor units", amount, c.Code)
+	}
+	return int64(minor), nil
+}
+
+// FromMinorUnits đổi số tiền ở đơn vị nhỏ nhất về đơn vị chính
+func (c Currency) FromMinorUnits(amount int64) float64 {
+	return float64(amount) / c.factor()
+}
+
+// MinorUnit là giá trị của một đơn vị nhỏ nhất ở đơn vị chính (0.01 USD, 1 VND)
+func (c Currency) MinorUnit() float64 {
+	return 1 / c.factor()
+}
+
+// FormatDecimal định dạng amount với đúng số chữ số phần lẻ của tiền tệ
+func (c Currency) FormatDecimal(amount float64) string {
+	return fmt.Sprintf("%.*f", c.MinorUnits, c.Round(amount))
+}
+
+// ToMinorUnits đổi amount của tiền tệ code sang đơn vị nhỏ nhất
+func ToMinorUnits(amount float64, code string) (int64, error) {
+	c, err := Lookup(code)
+	if err != nil {
+		return 0, err
+	}
+	return c.ToMinorUnits(amount)
+}
+
+// FromMinorUnits đổi số tiền ở đơn vị nhỏ nhất của tiền tệ code về đơn vị chính
+func FromMinorUnits(amount int64, code string) (float64, error) {
+	c, err := Lookup(code)
+	if err != nil {
+		return 0, err
+	}
+	return c.FromMinorUnits(amount), nil
+}
+
+// Round làm tròn amount theo tiền tệ code. Tiền tệ không hỗ trợ được làm tròn 2 chữ số.
+func Round(amount float64, code string) float64 {
+	c, err := Lookup(code)
+	if err != nil {
+		return math.Round(amount*100) / 100
+	}
+	return c.Round(amount)
+}
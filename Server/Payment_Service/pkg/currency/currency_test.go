@@ -0,0 +1,186 @@
+package currency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestToMinorUnits(t *testing.T) {
+	tests := []struct {
+		amount  float64
+		code    string
+		want    int64
+		wantErr error
+	}{
+		// VND không có phần lẻ
+		{amount: 10000, code: "vnd", want: 10000},
+		{amount: 250000.4, code: "vnd", want: 250000},
+		{amount: 250000.5, code: "vnd", want: 250001},
+		{amount: 0, code: "VND", want: 0},
+		// USD / EUR 2 chữ số phần lẻ
+		{amount: 10.5, code: "usd", want: 1050},
+		{amount: 19.99, code: "usd", want: 1999}, // 19.99 * 100 = 1998.9999999999998
+		{amount: 0.1 + 0.2, code: "USD", want: 30},
+		{amount: 1234.567, code: "eur", want: 123457},
+		{amount: 0.01, code: " Eur ", want: 1},
+		{amount: 1.234, code: "kwd", want: 1234},
+		{amount: 500, code: "jpy", want: 500},
+		{amount: -1, code: "usd", wantErr: errAny},
+		{amount: 10, code: "xyz", wantErr: ErrUnsupportedCurrency},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %s", tt.amount, tt.code), func(t *testing.T) {
+			got, err := ToMinorUnits(tt.amount, tt.code)
+			if !matchErr(err, tt.wantErr) {
+				t.Fatalf("ToMinorUnits() err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("ToMinorUnits() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromMinorUnits(t *testing.T) {
+	tests := []struct {
+		minor int64
+		code  string
+		want  float64
+	}{
+		{minor: 250000, code: "vnd", want: 250000},
+		{minor: 1050, code: "usd", want: 10.5},
+		{minor: 1, code: "eur", want: 0.01},
+		{minor: 1234, code: "kwd", want: 1.234},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d %s", tt.minor, tt.code), func(t *testing.T) {
+			got, err := FromMinorUnits(tt.minor, tt.code)
+			if err != nil {
+				t.Fatalf("FromMinorUnits() err = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("FromMinorUnits() = %v, want %v", got, tt.want)
+			}
+			back, err := ToMinorUnits(got, tt.code)
+			if err != nil || back != tt.minor {
+				t.Fatalf("ToMinorUnits(FromMinorUnits(%d)) = %d, %v", tt.minor, back, err)
+			}
+		})
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		amount float64
+		code   string
+		want   float64
+	}{
+		{amount: 12345.6, code: "vnd", want: 12346},
+		{amount: 12345.4, code: "vnd", want: 12345},
+		{amount: 13.33332, code: "usd", want: 13.33},
+		{amount: 10.126, code: "usd", want: 10.13},
+		{amount: 99.999, code: "eur", want: 100},
+		{amount: 1.2344, code: "kwd", want: 1.234},
+		{amount: 1.236, code: "xyz", want: 1.24}, // Tiền tệ không hỗ trợ: 2 chữ số
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %s", tt.amount, tt.code), func(t *testing.T) {
+			if got := Round(tt.amount, tt.code); got != tt.want {
+				t.Fatalf("Round() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	tests := []struct {
+		amount float64
+		code   string
+		want   string
+	}{
+		{amount: 250000.4, code: "vnd", want: "250000"},
+		{amount: 10.5, code: "usd", want: "10.50"},
+		{amount: 13.33332, code: "eur", want: "13.33"},
+		{amount: 1.2, code: "kwd", want: "1.200"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %s", tt.amount, tt.code), func(t *testing.T) {
+			c, err := Lookup(tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.FormatDecimal(tt.amount); got != tt.want {
+				t.Fatalf("FormatDecimal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// staticRates là RateProvider trong bộ nhớ, khoá theo "base/quote"
+type staticRates map[string]Rate
+
+func (s staticRates) LatestRate(_ context.Context, base, quote string) (Rate, error) {
+	rate, ok := s[Normalize(base)+"/"+Normalize(quote)]
+	if !ok {
+		return Rate{}, fmt.Errorf("static: %s/%s: %w", base, quote, ErrRateNotFound)
+	}
+	return rate, nil
+}
+
+func TestConverterConvert(t *testing.T) {
+	asOf := time.Date(2025, 7, 30, 8, 0, 0, 0, time.UTC)
+	converter := NewConverter(staticRates{
+		"usd/vnd": {Base: "usd", Quote: "vnd", Rate: 25000, Source: "vietcombank", AsOf: asOf},
+	})
+
+	tests := []struct {
+		name       string
+		amount     float64
+		from, to   string
+		want       float64
+		wantRate   float64
+		wantSource string
+		wantErr    error
+	}{
+		{name: "direct rate rounds to VND", amount: 13.33, from: "usd", to: "vnd", want: 333250, wantRate: 25000, wantSource: "vietcombank"},
+		{name: "inverse rate rounds to cents", amount: 333333, from: "VND", to: "USD", want: 13.33, wantRate: 0.00004, wantSource: "vietcombank"},
+		{name: "identity", amount: 19.99, from: "usd", to: "usd", want: 19.99, wantRate: 1, wantSource: IdentitySource},
+		{name: "missing pair", amount: 10, from: "eur", to: "vnd", wantErr: ErrRateNotFound},
+		{name: "unsupported currency", amount: 10, from: "xyz", to: "vnd", wantErr: ErrUnsupportedCurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := converter.Convert(context.Background(), tt.amount, tt.from, tt.to)
+			if !matchErr(err, tt.wantErr) {
+				t.Fatalf("Convert() err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.ConvertedAmount != tt.want || got.Rate.Rate != tt.wantRate || got.Rate.Source != tt.wantSource {
+				t.Fatalf("Convert() = %v at rate %v (%s), want %v at rate %v (%s)",
+					got.ConvertedAmount, got.Rate.Rate, got.Rate.Source, tt.want, tt.wantRate, tt.wantSource)
+			}
+			if got.From != Normalize(tt.from) || got.To != Normalize(tt.to) {
+				t.Fatalf("Convert() currencies = %s -> %s, want %s -> %s", got.From, got.To, Normalize(tt.from), Normalize(tt.to))
+			}
+		})
+	}
+}
+
+// errAny khớp với mọi lỗi khác nil
+var errAny = errors.New("any error")
+
+func matchErr(err, want error) bool {
+	switch want {
+	case nil:
+		return err == nil
+	case errAny:
+		return err != nil
+	default:
+		return errors.Is(err, want)
+	}
+}
@@ -0,0 +1,199 @@
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrRateNotFound: nguồn tỷ giá không có tỷ giá cho cặp tiền tệ
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// IdentitySource là nguồn của "tỷ giá" 1 khi tiền tệ thu bằng tiền tệ của giá vé
+const IdentitySource = "identity"
+
+// Rate là tỷ giá tại một thời điểm: 1 Base = Rate Quote
+type Rate struct {
+	Base   string
+	Quote  string
+	Rate   float64
+	Source string
+	AsOf   time.Time
+}
+
+// RateProvider là nguồn tỷ giá (file cấu hình, bảng exchange_rates, API ngân hàng...).
+// LatestRate trả về ErrRateNotFound (có thể được bọc) khi không có cặp base/quote.
+type RateProvider interface {
+	LatestRate(ctx context.Context, base, quote string) (Rate, error)
+}
+
+// Conversion là kết quả quy đổi cùng tỷ giá được dùng, được lưu lại trên hoá đơn
+type Conversion struct {
+	From            string
+	To              string
+	Amount          float64
+	ConvertedAmount float64
+	Rate            Rate // Luôn theo chiều From -> To
+}
+
+// Converter quy đổi số tiền giữa các tiền tệ. Khi nguồn chỉ có tỷ giá chiều ngược lại, tỷ giá được nghịch đảo.
+type Converter struct {
+	provider RateProvider
+}
+
+func NewConverter(provider RateProvider) *Converter {
+	return &Converter{provider: provider}
+}
+
+// Convert quy đổi amount từ from sang to, làm tròn theo đơn vị nhỏ nhất của to
+func (c *Converter) Convert(ctx context.Context, amount float64, from, to string) (Conversion, error) {
+	fromCurrency, err := Lookup(from)
+	if err != nil {
+		return Conversion{}, err
+	}
+	toCurrency, err := Lookup(to)
+	if err != nil {
+		return Conversion{}, err
+	}
+
+	rate, err := c.rate(ctx, fromCurrency.Code, toCurrency.Code)
+	if err != nil {
+		return Conversion{}, err
+	}
+	return Conversion{
+		From:            fromCurrency.Code,
+		To:              toCurrency.Code,
+		Amount:          amount,
+		ConvertedAmount: toCurrency.Round(amount * rate.Rate),
+		Rate:            rate,
+	}, nil
+}
+
+func (c *Converter) rate(ctx context.Context, from, to string) (Rate, error) {
+	if from == to {
+		return Rate{Base: from, Quote: to, Rate: 1, Source: IdentitySource, AsOf: time.Now()}, nil
+	}
+	if c.provider == nil {
+		return Rate{}, fmt.Errorf("currency: no rate provider configured for %s/%s: %w", from, to, ErrRateNotFound)
+	}
+
+	rate, err := c.provider.LatestRate(ctx, from, to)
+	if err == nil {
+		return rate, nil
+	}
+	if !errors.Is(err, ErrRateNotFound) {
+		return Rate{}, err
+	}
+	inverse, inverseErr := c.provider.LatestRate(ctx, to, from)
+	if inverseErr != nil {
+		if errors.Is(inverseErr, ErrRateNotFound) {
+			return Rate{}, fmt.Errorf("currency: %s/%s: %w", from, to, ErrRateNotFound)
+		}
+		return Rate{}, inverseErr
+	}
+	if inverse.Rate <= 0 {
+		return Rate{}, fmt.Errorf("currency: invalid rate %f for %s/%s", inverse.Rate, to, from)
+	}
+	return Rate{Base: from, Quote: to, Rate: 1 / inverse.Rate, Source: inverse.Source, AsOf: inverse.AsOf}, nil
+}
+
+// rateFile là định dạng file tỷ giá:
+//
+//	{"source": "vietcombank", "as_of": "2025-07-30T08:00:00+07:00",
+//	 "rates": [{"base": "usd", "quote": "vnd", "rate": 26100}]}
+type rateFile struct {
+	Source string    `json:"source"`
+	AsOf   time.Time `json:"as_of"`
+	Rates  []struct {
+		Base  string  `json:"base"`
+		Quote string  `json:"quote"`
+		Rate  float64 `json:"rate"`
+	} `json:"rates"`
+}
+
+// FileRateProvider đọc tỷ giá từ file JSON cục bộ, đọc lại khi file được sửa để cập nhật tỷ giá không cần khởi động lại.
+type FileRateProvider struct {
+	path string
+
+	mu      sync.RWMutex
+	modTime time.Time
+	rates   map[[2]string]Rate
+}
+
+// NewFileRateProvider đọc file tỷ giá path, trả lỗi nếu file không đọc được hoặc sai định dạng
+func NewFileRateProvider(path string) (*FileRateProvider, error) {
+	p := &FileRateProvider{path: path}
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *FileRateProvider) LatestRate(ctx context.Context, base, quote string) (Rate, error) {
+	if info, err := os.Stat(p.path); err == nil && info.ModTime().After(p.loadedAt()) {
+		if err := p.reload(); err != nil {
+			// Giữ tỷ giá đã đọc trước đó khi file đang được ghi dở / sai định dạng
+			return p.lookup(base, quote, fmt.Errorf("currency: failed to reload %s: %w", p.path, err))
+		}
+	}
+	return p.lookup(base, quote, nil)
+}
+
+func (p *FileRateProvider) loadedAt() time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.modTime
+}
+
+func (p *FileRateProvider) lookup(base, quote string, reloadErr error) (Rate, error) {
+	p.mu.RLock()
+	rate, ok := p.rates[[2]string{Normalize(base), Normalize(quote)}]
+	p.mu.RUnlock()
+	if !ok {
+		if reloadErr != nil {
+			return Rate{}, reloadErr
+		}
+		return Rate{}, fmt.Errorf("currency: %s/%s in %s: %w", base, quote, p.path, ErrRateNotFound)
+	}
+	return rate, nil
+}
+
+func (p *FileRateProvider) reload() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	raw, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	var file rateFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return fmt.Errorf("currency: invalid rate file %s: %w", p.path, err)
+	}
+
+	rates := make(map[[2]string]Rate, len(file.Rates))
+	for _, r := range file.Rates {
+		base, quote := Normalize(r.Base), Normalize(r.Quote)
+		if _, err := Lookup(base); err != nil {
+			return fmt.Errorf("currency: rate file %s: %w", p.path, err)
+		}
+		if _, err := Lookup(quote); err != nil {
+			return fmt.Errorf("currency: rate file %s: %w", p.path, err)
+		}
+		if r.Rate <= 0 {
+			return fmt.Errorf("currency: rate file %s: rate %s/%s must be positive", p.path, base, quote)
+		}
+		rates[[2]string{base, quote}] = Rate{Base: base, Quote: quote, Rate: r.Rate, Source: file.Source, AsOf: file.AsOf}
+	}
+
+	p.mu.Lock()
+	p.rates = rates
+	p.modTime = info.ModTime()
+	p.mu.Unlock()
+	return nil
+}
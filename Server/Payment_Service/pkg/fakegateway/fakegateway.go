@@ -0,0 +1,81 @@
+// Package fakegateway giả lập API của MoMo và ZaloPay để chạy thử luồng thanh toán cục bộ (không cần tài khoản sandbox).
+// Cổng giả lập kiểm tra chữ ký giống cổng thật, lưu đơn trong bộ nhớ và có trang /pay để chọn kết quả thanh toán:
+//
+//	GET <base>/pay?orderId=...&result=success|failed        (MoMo)
+//	GET <base>/pay?app_trans_id=...&result=success|failed   (ZaloPay)
+//
+// Trang /pay gửi IPN / callback đã ký về merchant rồi redirect trình duyệt về redirect URL như cổng thật.
+package fakegateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// notifyClient gửi IPN / callback từ cổng giả lập về merchant
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// basePath trả về tiền tố mà handler được mount (kể cả khi chạy sau http.StripPrefix)
+func basePath(r *http.Request) string {
+	requestURL, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(requestURL.Path, r.URL.Path)
+}
+
+// externalURL dựng URL tuyệt đối tới path của cổng giả lập
+func externalURL(r *http.Request, path string, query url.Values) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	u := url.URL{Scheme: scheme, Host: r.Host, Path: basePath(r) + path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("fakegateway: failed to write response: %v", err)
+	}
+}
+
+// postNotification gửi thông báo server-to-server về merchant, lỗi chỉ được log (cổng thật sẽ retry)
+func postNotification(target, contentType string, body []byte) {
+	if target == "" {
+		return
+	}
+	resp, err := notifyClient.Post(target, contentType, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("fakegateway: notify %s failed: %v", target, err)
+		return
+	}
+	defer resp.Body.Close()
+	log.Printf("fakegateway: notify %s -> %d", target, resp.StatusCode)
+}
+
+// redirectTo redirect trình duyệt về redirectURL kèm query; nếu merchant không cấu hình redirect thì trả JSON
+func redirectTo(w http.ResponseWriter, r *http.Request, redirectURL string, query url.Values) {
+	if redirectURL == "" {
+		writeJSON(w, http.StatusOK, query)
+		return
+	}
+	target, err := url.Parse(redirectURL)
+	if err != nil {
+		http.Error(w, "invalid redirect url", http.StatusBadRequest)
+		return
+	}
+	merged := target.Query()
+	for k, v := range query {
+		merged[k] = v
+	}
+	target.RawQuery = merged.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
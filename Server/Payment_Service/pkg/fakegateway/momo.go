@@ -0,0 +1,200 @@
+package fakegateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"payment_service/config"
+	"payment_service/pkg/momo"
+)
+
+// momoResultCodeDeclined: khách từ chối thanh toán trên app MoMo
+const momoResultCodeDeclined = 1006
+
+type momoOrder struct {
+	request    momo.CreateRequest
+	resultCode int
+	transID    int64
+	refunded   int64
+}
+
+// MoMo giả lập API v2 của MoMo (create, query, refund) với cùng cấu hình khoá của merchant
+type MoMo struct {
+	cfg config.MoMoConfig
+	mux *http.ServeMux
+
+	mu     sync.Mutex
+	orders map[string]*momoOrder
+	nextID int64
+}
+
+func NewMoMo(cfg config.MoMoConfig) *MoMo {
+	g := &MoMo{cfg: cfg, mux: http.NewServeMux(), orders: make(map[string]*momoOrder), nextID: time.Now().Unix()}
+	g.mux.HandleFunc(momo.CreatePath, g.handleCreate)
+	g.mux.HandleFunc(momo.QueryPath, g.handleQuery)
+	g.mux.HandleFunc(momo.RefundPath, g.handleRefund)
+	g.mux.HandleFunc("/pay", g.handlePay)
+	return g
+}
+
+func (g *MoMo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+func (g *MoMo) verify(raw, signature string) bool {
+	return momo.VerifySignature(g.cfg.SecretKey, raw, signature)
+}
+
+func (g *MoMo) newTransID() int64 {
+	g.nextID++
+	return g.nextID
+}
+
+func (g *MoMo) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req momo.CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, momo.CreateResponse{ResultCode: 20, Message: "Bad format request."})
+		return
+	}
+	resp := momo.CreateResponse{
+		PartnerCode:  req.PartnerCode,
+		RequestID:    req.RequestID,
+		OrderID:      req.OrderID,
+		Amount:       req.Amount,
+		ResponseTime: time.Now().UnixMilli(),
+	}
+	if !g.verify(req.RawSignature(g.cfg.AccessKey), req.Signature) {
+		resp.ResultCode, resp.Message = 11, "Access denied: invalid signature."
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+
+	g.mu.Lock()
+	if _, exists := g.orders[req.OrderID]; exists {
+		g.mu.Unlock()
+		resp.ResultCode, resp.Message = 41, "Duplicate orderId."
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	g.orders[req.OrderID] = &momoOrder{request: req, resultCode: momo.ResultCodePending}
+	g.mu.Unlock()
+
+	payURL := externalURL(r, "/pay", map[string][]string{"orderId": {req.OrderID}})
+	resp.Message = "Thành công."
+	resp.PayURL = payURL
+	resp.Deeplink = payURL
+	resp.QrCodeURL = payURL
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (g *MoMo) handleQuery(w http.ResponseWriter, r *http.Request) {
+	var req momo.QueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, momo.QueryResponse{ResultCode: 20, Message: "Bad format request."})
+		return
+	}
+	resp := momo.QueryResponse{PartnerCode: req.PartnerCode, RequestID: req.RequestID, OrderID: req.OrderID, ResponseTime: time.Now().UnixMilli()}
+	if !g.verify(req.RawSignature(g.cfg.AccessKey), req.Signature) {
+		resp.ResultCode, resp.Message = 11, "Access denied: invalid signature."
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+
+	g.mu.Lock()
+	order, ok := g.orders[req.OrderID]
+	if ok {
+		resp.Amount, resp.TransID, resp.ResultCode = order.request.Amount, order.transID, order.resultCode
+		resp.ExtraData = order.request.ExtraData
+	}
+	g.mu.Unlock()
+	if !ok {
+		resp.ResultCode, resp.Message = momo.ResultCodeNotFound, "Order not found."
+	} else {
+		resp.Message = "Thành công."
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (g *MoMo) handleRefund(w http.ResponseWriter, r *http.Request) {
+	var req momo.RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, momo.RefundResponse{ResultCode: 20, Message: "Bad format request."})
+		return
+	}
+	resp := momo.RefundResponse{PartnerCode: req.PartnerCode, OrderID: req.OrderID, RequestID: req.RequestID, Amount: req.Amount, ResponseTime: time.Now().UnixMilli()}
+	if !g.verify(req.RawSignature(g.cfg.AccessKey), req.Signature) {
+		resp.ResultCode, resp.Message = 11, "Access denied: invalid signature."
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var paid *momoOrder
+	for _, order := range g.orders {
+		if order.transID == req.TransID && order.resultCode == momo.ResultCodeSuccess {
+			paid = order
+			break
+		}
+	}
+	switch {
+	case paid == nil:
+		resp.ResultCode, resp.Message = momo.ResultCodeNotFound, "Transaction not found."
+	case req.Amount <= 0 || paid.refunded+req.Amount > paid.request.Amount:
+		resp.ResultCode, resp.Message = 22, "Refund amount exceeds the paid amount."
+	default:
+		paid.refunded += req.Amount
+		resp.TransID = g.newTransID()
+		resp.Message = "Thành công."
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handlePay mô phỏng khách thanh toán trên app MoMo
+func (g *MoMo) handlePay(w http.ResponseWriter, r *http.Request) {
+	orderID := r.URL.Query().Get("orderId")
+	success := r.URL.Query().Get("result") != "failed"
+
+	g.mu.Lock()
+	order, ok := g.orders[orderID]
+	if ok && order.resultCode == momo.ResultCodePending {
+		if success {
+			order.resultCode = momo.ResultCodeSuccess
+			order.transID = g.newTransID()
+		} else {
+			order.resultCode = momoResultCodeDeclined
+		}
+	}
+	var notification momo.Notification
+	if ok {
+		notification = momo.Notification{
+			PartnerCode:  order.request.PartnerCode,
+			OrderID:      orderID,
+			RequestID:    order.request.RequestID,
+			Amount:       order.request.Amount,
+			OrderInfo:    order.request.OrderInfo,
+			OrderType:    "momo_wallet",
+			TransID:      order.transID,
+			ResultCode:   order.resultCode,
+			Message:      "Thành công.",
+			PayType:      "qr",
+			ResponseTime: time.Now().UnixMilli(),
+			ExtraData:    order.request.ExtraData,
+		}
+		if order.resultCode != momo.ResultCodeSuccess {
+			notification.Message = "Giao dịch bị từ chối bởi người dùng."
+		}
+	}
+	g.mu.Unlock()
+	if !ok {
+		http.Error(w, "order not found", http.StatusNotFound)
+		return
+	}
+
+	notification.Signature = momo.Sign(g.cfg.SecretKey, notification.RawSignature(g.cfg.AccessKey))
+	body, _ := json.Marshal(notification)
+	postNotification(order.request.IpnURL, "application/json", body)
+	redirectTo(w, r, order.request.RedirectURL, notification.Values())
+}
@@ -0,0 +1,218 @@
+package fakegateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"payment_service/config"
+	"payment_service/pkg/zalopay"
+)
+
+// zaloPayRedirectStatusCancelled: khách huỷ thanh toán trên trang ZaloPay
+const zaloPayRedirectStatusCancelled = "-49"
+
+type zaloPayOrder struct {
+	order      zalopay.CreateOrder
+	returnCode int
+	zpTransID  int64
+	serverTime int64
+	refunded   int64
+}
+
+// ZaloPay giả lập API v2 của ZaloPay (create, query, refund) với cùng key1 / key2 của merchant
+type ZaloPay struct {
+	cfg config.ZaloPayConfig
+	mux *http.ServeMux
+
+	mu     sync.Mutex
+	orders map[string]*zaloPayOrder
+	nextID int64
+}
+
+func NewZaloPay(cfg config.ZaloPayConfig) *ZaloPay {
+	g := &ZaloPay{cfg: cfg, mux: http.NewServeMux(), orders: make(map[string]*zaloPayOrder), nextID: time.Now().Unix()}
+	g.mux.HandleFunc(zalopay.CreatePath, g.handleCreate)
+	g.mux.HandleFunc(zalopay.QueryPath, g.handleQuery)
+	g.mux.HandleFunc(zalopay.RefundPath, g.handleRefund)
+	g.mux.HandleFunc("/pay", g.handlePay)
+	return g
+}
+
+func (g *ZaloPay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+func (g *ZaloPay) newID() int64 {
+	g.nextID++
+	return g.nextID
+}
+
+func (g *ZaloPay) handleCreate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, zalopay.CreateResponse{ReturnCode: zalopay.ReturnCodeFailed, ReturnMessage: err.Error()})
+		return
+	}
+	order, err := zalopay.CreateOrderFromForm(r.PostForm)
+	if err != nil {
+		writeJSON(w, http.StatusOK, zalopay.CreateResponse{ReturnCode: zalopay.ReturnCodeFailed, ReturnMessage: err.Error()})
+		return
+	}
+	if order.AppID != g.cfg.AppID || !zalopay.VerifyMac(g.cfg.Key1, order.MacData(), order.Mac) {
+		writeJSON(w, http.StatusOK, zalopay.CreateResponse{ReturnCode: zalopay.ReturnCodeFailed, ReturnMessage: "Giao dịch thất bại", SubReturnCode: -401, SubReturnMessage: "Dữ liệu yêu cầu không hợp lệ"})
+		return
+	}
+
+	g.mu.Lock()
+	if _, exists := g.orders[order.AppTransID]; exists {
+		g.mu.Unlock()
+		writeJSON(w, http.StatusOK, zalopay.CreateResponse{ReturnCode: zalopay.ReturnCodeFailed, ReturnMessage: "Giao dịch thất bại", SubReturnCode: -68, SubReturnMessage: "Mã giao dịch bị trùng"})
+		return
+	}
+	g.orders[order.AppTransID] = &zaloPayOrder{order: order, returnCode: zalopay.ReturnCodeProcessing}
+	token := strconv.FormatInt(g.newID(), 10)
+	g.mu.Unlock()
+
+	orderURL := externalURL(r, "/pay", map[string][]string{"app_trans_id": {order.AppTransID}})
+	writeJSON(w, http.StatusOK, zalopay.CreateResponse{
+		ReturnCode:       zalopay.ReturnCodeSuccess,
+		ReturnMessage:    "Giao dịch thành công",
+		SubReturnCode:    1,
+		SubReturnMessage: "Giao dịch thành công",
+		OrderURL:         orderURL,
+		ZpTransToken:     token,
+		OrderToken:       token,
+		QrCode:           orderURL,
+	})
+}
+
+func (g *ZaloPay) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, zalopay.QueryResponse{ReturnCode: zalopay.ReturnCodeFailed, ReturnMessage: err.Error()})
+		return
+	}
+	appID, _ := strconv.Atoi(r.PostForm.Get("app_id"))
+	appTransID := r.PostForm.Get("app_trans_id")
+	if appID != g.cfg.AppID || !zalopay.VerifyMac(g.cfg.Key1, zalopay.QueryMacData(appID, appTransID, g.cfg.Key1), r.PostForm.Get("mac")) {
+		writeJSON(w, http.StatusOK, zalopay.QueryResponse{ReturnCode: zalopay.ReturnCodeFailed, ReturnMessage: "Giao dịch thất bại", SubReturnCode: -401, SubReturnMessage: "Dữ liệu yêu cầu không hợp lệ"})
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	order, ok := g.orders[appTransID]
+	if !ok {
+		writeJSON(w, http.StatusOK, zalopay.QueryResponse{ReturnCode: zalopay.ReturnCodeFailed, ReturnMessage: "Giao dịch thất bại", SubReturnCode: -92, SubReturnMessage: "Giao dịch không tồn tại"})
+		return
+	}
+	resp := zalopay.QueryResponse{
+		ReturnCode:   order.returnCode,
+		IsProcessing: order.returnCode == zalopay.ReturnCodeProcessing,
+		Amount:       order.order.Amount,
+		ZpTransID:    order.zpTransID,
+		ServerTime:   order.serverTime,
+	}
+	switch order.returnCode {
+	case zalopay.ReturnCodeSuccess:
+		resp.ReturnMessage = "Giao dịch thành công"
+	case zalopay.ReturnCodeProcessing:
+		resp.ReturnMessage = "Giao dịch chưa được thanh toán"
+	default:
+		resp.ReturnMessage = "Giao dịch thất bại"
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (g *ZaloPay) handleRefund(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, zalopay.RefundResponse{ReturnCode: zalopay.ReturnCodeFailed, ReturnMessage: err.Error()})
+		return
+	}
+	req, err := zalopay.RefundRequestFromForm(r.PostForm)
+	if err != nil {
+		writeJSON(w, http.StatusOK, zalopay.RefundResponse{ReturnCode: zalopay.ReturnCodeFailed, ReturnMessage: err.Error()})
+		return
+	}
+	if req.AppID != g.cfg.AppID || !zalopay.VerifyMac(g.cfg.Key1, req.MacData(), req.Mac) {
+		writeJSON(w, http.StatusOK, zalopay.RefundResponse{ReturnCode: zalopay.ReturnCodeFailed, ReturnMessage: "Giao dịch thất bại", SubReturnCode: -401, SubReturnMessage: "Dữ liệu yêu cầu không hợp lệ"})
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var paid *zaloPayOrder
+	for _, order := range g.orders {
+		if order.zpTransID == req.ZpTransID && order.returnCode == zalopay.ReturnCodeSuccess {
+			paid = order
+			break
+		}
+	}
+	switch {
+	case paid == nil:
+		writeJSON(w, http.StatusOK, zalopay.RefundResponse{ReturnCode: zalopay.ReturnCodeFailed, ReturnMessage: "Giao dịch thất bại", SubReturnCode: -92, SubReturnMessage: "Giao dịch không tồn tại"})
+	case req.Amount <= 0 || paid.refunded+req.Amount > paid.order.Amount:
+		writeJSON(w, http.StatusOK, zalopay.RefundResponse{ReturnCode: zalopay.ReturnCodeFailed, ReturnMessage: "Giao dịch thất bại", SubReturnCode: -13, SubReturnMessage: "Số tiền hoàn vượt quá số tiền giao dịch"})
+	default:
+		paid.refunded += req.Amount
+		writeJSON(w, http.StatusOK, zalopay.RefundResponse{ReturnCode: zalopay.ReturnCodeSuccess, ReturnMessage: "Giao dịch thành công", RefundID: g.newID()})
+	}
+}
+
+// handlePay mô phỏng khách thanh toán trên trang ZaloPay; ZaloPay chỉ gửi callback khi thanh toán thành công
+func (g *ZaloPay) handlePay(w http.ResponseWriter, r *http.Request) {
+	appTransID := r.URL.Query().Get("app_trans_id")
+	success := r.URL.Query().Get("result") != "failed"
+
+	g.mu.Lock()
+	order, ok := g.orders[appTransID]
+	if ok && order.returnCode == zalopay.ReturnCodeProcessing && success {
+		order.returnCode = zalopay.ReturnCodeSuccess
+		order.zpTransID = g.newID()
+		order.serverTime = time.Now().UnixMilli()
+	}
+	var snapshot zaloPayOrder
+	if ok {
+		snapshot = *order
+	}
+	g.mu.Unlock()
+	if !ok {
+		http.Error(w, "order not found", http.StatusNotFound)
+		return
+	}
+
+	redirect := zalopay.Redirect{
+		AppID:          strconv.Itoa(snapshot.order.AppID),
+		AppTransID:     appTransID,
+		Pmcid:          "38",
+		BankCode:       snapshot.order.BankCode,
+		Amount:         strconv.FormatInt(snapshot.order.Amount, 10),
+		DiscountAmount: "0",
+		Status:         zaloPayRedirectStatusCancelled,
+	}
+	if snapshot.returnCode == zalopay.ReturnCodeSuccess {
+		redirect.Status = zalopay.RedirectStatusSuccess
+		data, _ := json.Marshal(zalopay.CallbackData{
+			AppID:      snapshot.order.AppID,
+			AppTransID: appTransID,
+			AppTime:    snapshot.order.AppTime,
+			AppUser:    snapshot.order.AppUser,
+			Amount:     snapshot.order.Amount,
+			EmbedData:  snapshot.order.EmbedData,
+			Item:       snapshot.order.Item,
+			ZpTransID:  snapshot.zpTransID,
+			ServerTime: snapshot.serverTime,
+			Channel:    38,
+		})
+		body, _ := json.Marshal(zalopay.CallbackRequest{Data: string(data), Mac: zalopay.Mac(g.cfg.Key2, string(data)), Type: 1})
+		postNotification(snapshot.order.CallbackURL, "application/json", body)
+	}
+	redirect.Checksum = zalopay.Mac(g.cfg.Key2, redirect.ChecksumData())
+
+	var embed struct {
+		RedirectURL string `json:"redirecturl"`
+	}
+	_ = json.Unmarshal([]byte(snapshot.order.EmbedData), &embed)
+	redirectTo(w, r, embed.RedirectURL, redirect.Values())
+}
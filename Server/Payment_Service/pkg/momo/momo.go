@@ -0,0 +1,338 @@
+// Package momo gọi API v2 của ví MoMo (captureWallet) và ký / xác thực chữ ký HMAC-SHA256 theo tài liệu MoMo.
+// Các kiểu request / response được dùng chung với pkg/fakegateway để chạy thử với cổng giả lập cục bộ.
+package momo
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"payment_service/config"
+)
+
+const (
+	CreatePath = "/v2/gateway/api/create"
+	QueryPath  = "/v2/gateway/api/query"
+	RefundPath = "/v2/gateway/api/refund"
+
+	RequestTypeCaptureWallet = "captureWallet"
+
+	ResultCodeSuccess    = 0
+	ResultCodePending    = 1000 // Đơn đã khởi tạo, chờ khách xác nhận trên app MoMo
+	ResultCodeProcessing = 7000 // Giao dịch đang được xử lý
+	ResultCodeNotFound   = 42   // Không tìm thấy đơn / giao dịch
+)
+
+// Sign ký chuỗi rawSignature bằng HMAC-SHA256 với secretKey, trả về hex.
+func Sign(secretKey, rawSignature string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(rawSignature))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature so sánh chữ ký nhận được với chữ ký tính lại (constant-time).
+func VerifySignature(secretKey, rawSignature, signature string) bool {
+	return hmac.Equal([]byte(Sign(secretKey, rawSignature)), []byte(strings.ToLower(signature)))
+}
+
+// rawSignature ghép các cặp key=value theo đúng thứ tự MoMo quy định (a-z) bằng '&'.
+func rawSignature(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteString("&")
+		}
+		b.WriteString(pairs[i])
+		b.WriteString("=")
+		b.WriteString(pairs[i+1])
+	}
+	return b.String()
+}
+
+// CreateRequest là body tạo đơn thanh toán
+type CreateRequest struct {
+	PartnerCode string `json:"partnerCode"`
+	RequestID   string `json:"requestId"`
+	Amount      int64  `json:"amount"`
+	OrderID     string `json:"orderId"`
+	OrderInfo   string `json:"orderInfo"`
+	RedirectURL string `json:"redirectUrl"`
+	IpnURL      string `json:"ipnUrl"`
+	RequestType string `json:"requestType"`
+	ExtraData   string `json:"extraData"`
+	Lang        string `json:"lang"`
+	Signature   string `json:"signature"`
+}
+
+func (r CreateRequest) RawSignature(accessKey string) string {
+	return rawSignature(
+		"accessKey", accessKey,
+		"amount", strconv.FormatInt(r.Amount, 10),
+		"extraData", r.ExtraData,
+		"ipnUrl", r.IpnURL,
+		"orderId", r.OrderID,
+		"orderInfo", r.OrderInfo,
+		"partnerCode", r.PartnerCode,
+		"redirectUrl", r.RedirectURL,
+		"requestId", r.RequestID,
+		"requestType", r.RequestType,
+	)
+}
+
+// CreateResponse là kết quả tạo đơn; khách thanh toán qua PayURL / Deeplink / QrCodeURL
+type CreateResponse struct {
+	PartnerCode  string `json:"partnerCode"`
+	RequestID    string `json:"requestId"`
+	OrderID      string `json:"orderId"`
+	Amount       int64  `json:"amount"`
+	ResponseTime int64  `json:"responseTime"`
+	Message      string `json:"message"`
+	ResultCode   int    `json:"resultCode"`
+	PayURL       string `json:"payUrl"`
+	Deeplink     string `json:"deeplink,omitempty"`
+	QrCodeURL    string `json:"qrCodeUrl,omitempty"`
+}
+
+// Notification là kết quả thanh toán MoMo gửi về ipnUrl (JSON) và redirectUrl (query string)
+type Notification struct {
+	PartnerCode  string `json:"partnerCode"`
+	OrderID      string `json:"orderId"`
+	RequestID    string `json:"requestId"`
+	Amount       int64  `json:"amount"`
+	OrderInfo    string `json:"orderInfo"`
+	OrderType    string `json:"orderType"`
+	TransID      int64  `json:"transId"`
+	ResultCode   int    `json:"resultCode"`
+	Message      string `json:"message"`
+	PayType      string `json:"payType"`
+	ResponseTime int64  `json:"responseTime"`
+	ExtraData    string `json:"extraData"`
+	Signature    string `json:"signature"`
+}
+
+func (n Notification) RawSignature(accessKey string) string {
+	return rawSignature(
+		"accessKey", accessKey,
+		"amount", strconv.FormatInt(n.Amount, 10),
+		"extraData", n.ExtraData,
+		"message", n.Message,
+		"orderId", n.OrderID,
+		"orderInfo", n.OrderInfo,
+		"orderType", n.OrderType,
+		"partnerCode", n.PartnerCode,
+		"payType", n.PayType,
+		"requestId", n.RequestID,
+		"responseTime", strconv.FormatInt(n.ResponseTime, 10),
+		"resultCode", strconv.Itoa(n.ResultCode),
+		"transId", strconv.FormatInt(n.TransID, 10),
+	)
+}
+
+// Values chuyển Notification thành query string như MoMo redirect về redirectUrl.
+func (n Notification) Values() url.Values {
+	return url.Values{
+		"partnerCode":  {n.PartnerCode},
+		"orderId":      {n.OrderID},
+		"requestId":    {n.RequestID},
+		"amount":       {strconv.FormatInt(n.Amount, 10)},
+		"orderInfo":    {n.OrderInfo},
+		"orderType":    {n.OrderType},
+		"transId":      {strconv.FormatInt(n.TransID, 10)},
+		"resultCode":   {strconv.Itoa(n.ResultCode)},
+		"message":      {n.Message},
+		"payType":      {n.PayType},
+		"responseTime": {strconv.FormatInt(n.ResponseTime, 10)},
+		"extraData":    {n.ExtraData},
+		"signature":    {n.Signature},
+	}
+}
+
+// NotificationFromValues đọc Notification từ query string redirect.
+func NotificationFromValues(values url.Values) (Notification, error) {
+	n := Notification{
+		PartnerCode: values.Get("partnerCode"),
+		OrderID:     values.Get("orderId"),
+		RequestID:   values.Get("requestId"),
+		OrderInfo:   values.Get("orderInfo"),
+		OrderType:   values.Get("orderType"),
+		Message:     values.Get("message"),
+		PayType:     values.Get("payType"),
+		ExtraData:   values.Get("extraData"),
+		Signature:   values.Get("signature"),
+	}
+	var err error
+	if n.Amount, err = strconv.ParseInt(values.Get("amount"), 10, 64); err != nil {
+		return Notification{}, fmt.Errorf("momo: invalid amount %q: %w", values.Get("amount"), err)
+	}
+	if n.TransID, err = strconv.ParseInt(values.Get("transId"), 10, 64); err != nil {
+		return Notification{}, fmt.Errorf("momo: invalid transId %q: %w", values.Get("transId"), err)
+	}
+	if n.ResultCode, err = strconv.Atoi(values.Get("resultCode")); err != nil {
+		return Notification{}, fmt.Errorf("momo: invalid resultCode %q: %w", values.Get("resultCode"), err)
+	}
+	if n.ResponseTime, err = strconv.ParseInt(values.Get("responseTime"), 10, 64); err != nil {
+		return Notification{}, fmt.Errorf("momo: invalid responseTime %q: %w", values.Get("responseTime"), err)
+	}
+	return n, nil
+}
+
+// QueryRequest truy vấn trạng thái đơn theo orderId
+type QueryRequest struct {
+	PartnerCode string `json:"partnerCode"`
+	RequestID   string `json:"requestId"`
+	OrderID     string `json:"orderId"`
+	Lang        string `json:"lang"`
+	Signature   string `json:"signature"`
+}
+
+func (r QueryRequest) RawSignature(accessKey string) string {
+	return rawSignature(
+		"accessKey", accessKey,
+		"orderId", r.OrderID,
+		"partnerCode", r.PartnerCode,
+		"requestId", r.RequestID,
+	)
+}
+
+type QueryResponse struct {
+	PartnerCode  string `json:"partnerCode"`
+	RequestID    string `json:"requestId"`
+	OrderID      string `json:"orderId"`
+	ExtraData    string `json:"extraData"`
+	Amount       int64  `json:"amount"`
+	TransID      int64  `json:"transId"`
+	PayType      string `json:"payType"`
+	ResultCode   int    `json:"resultCode"`
+	Message      string `json:"message"`
+	ResponseTime int64  `json:"responseTime"`
+}
+
+// RefundRequest hoàn tiền (toàn phần hoặc một phần) cho giao dịch TransID; OrderID là mã riêng của lần hoàn
+type RefundRequest struct {
+	PartnerCode string `json:"partnerCode"`
+	OrderID     string `json:"orderId"`
+	RequestID   string `json:"requestId"`
+	Amount      int64  `json:"amount"`
+	TransID     int64  `json:"transId"`
+	Lang        string `json:"lang"`
+	Description string `json:"description"`
+	Signature   string `json:"signature"`
+}
+
+func (r RefundRequest) RawSignature(accessKey string) string {
+	return rawSignature(
+		"accessKey", accessKey,
+		"amount", strconv.FormatInt(r.Amount, 10),
+		"description", r.Description,
+		"orderId", r.OrderID,
+		"partnerCode", r.PartnerCode,
+		"requestId", r.RequestID,
+		"transId", strconv.FormatInt(r.TransID, 10),
+	)
+}
+
+type RefundResponse struct {
+	PartnerCode  string `json:"partnerCode"`
+	OrderID      string `json:"orderId"`
+	RequestID    string `json:"requestId"`
+	Amount       int64  `json:"amount"`
+	TransID      int64  `json:"transId"`
+	ResultCode   int    `json:"resultCode"`
+	Message      string `json:"message"`
+	ResponseTime int64  `json:"responseTime"`
+}
+
+// Client gọi API của MoMo, tự điền partnerCode và chữ ký cho từng request.
+type Client struct {
+	cfg        *config.MoMoConfig
+	httpClient *http.Client
+}
+
+func NewClient(cfg *config.MoMoConfig, httpClient *http.Client) *Client {
+	return &Client{cfg: cfg, httpClient: httpClient}
+}
+
+// VerifyNotification kiểm tra chữ ký IPN / redirect bằng accessKey và secretKey của merchant.
+func (c *Client) VerifyNotification(n Notification) bool {
+	return VerifySignature(c.cfg.SecretKey, n.RawSignature(c.cfg.AccessKey), n.Signature)
+}
+
+func (c *Client) Create(ctx context.Context, req CreateRequest) (*CreateResponse, error) {
+	req.PartnerCode = c.cfg.PartnerCode
+	if req.RequestType == "" {
+		req.RequestType = RequestTypeCaptureWallet
+	}
+	if req.RedirectURL == "" {
+		req.RedirectURL = c.cfg.RedirectURL
+	}
+	if req.IpnURL == "" {
+		req.IpnURL = c.cfg.IPNURL
+	}
+	req.Signature = Sign(c.cfg.SecretKey, req.RawSignature(c.cfg.AccessKey))
+
+	var resp CreateResponse
+	if err := c.post(ctx, CreatePath, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) Query(ctx context.Context, req QueryRequest) (*QueryResponse, error) {
+	req.PartnerCode = c.cfg.PartnerCode
+	req.Signature = Sign(c.cfg.SecretKey, req.RawSignature(c.cfg.AccessKey))
+
+	var resp QueryResponse
+	if err := c.post(ctx, QueryPath, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) Refund(ctx context.Context, req RefundRequest) (*RefundResponse, error) {
+	req.PartnerCode = c.cfg.PartnerCode
+	req.Signature = Sign(c.cfg.SecretKey, req.RawSignature(c.cfg.AccessKey))
+
+	var resp RefundResponse
+	if err := c.post(ctx, RefundPath, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) post(ctx context.Context, path string, body interface{}, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("momo: failed to marshal request: %w", err)
+	}
+	endpoint := strings.TrimSuffix(c.cfg.Endpoint, "/") + path
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("momo: failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("momo: request to %s failed: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("momo: failed to read response: %w", err)
+	}
+	// MoMo trả lỗi nghiệp vụ kèm HTTP 4xx nhưng body vẫn có resultCode, chỉ coi là lỗi khi không đọc được body
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("momo: failed to decode response (status %d): %w", resp.StatusCode, err)
+	}
+	return nil
+}
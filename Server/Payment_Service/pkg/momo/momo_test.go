@@ -0,0 +1,150 @@
+package momo
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"payment_service/config"
+)
+
+// Khoá sandbox công khai của MoMo (partnerCode MOMO) và dữ liệu mẫu theo tài liệu API v2.
+// Chữ ký mong đợi được tính độc lập với package này:
+//
+//	printf '%s' "<rawSignature>" | openssl dgst -sha256 -hmac "$SECRET_KEY"
+const (
+	testPartnerCode = "MOMO"
+	testAccessKey   = "F8BBA842ECF85"
+	testSecretKey   = "K951B6PE1waDMi640xX08PD3vg6EkVlz"
+)
+
+func sampleNotification() Notification {
+	return Notification{
+		PartnerCode:  testPartnerCode,
+		OrderID:      "MM1540456472575",
+		RequestID:    "MM1540456472575",
+		Amount:       50000,
+		OrderInfo:    "SDK team.",
+		OrderType:    "momo_wallet",
+		TransID:      2588659987,
+		ResultCode:   ResultCodeSuccess,
+		Message:      "Successful.",
+		PayType:      "qr",
+		ResponseTime: 1540456472575,
+		Signature:    "340d42991952e7bdbd565ce19f8087f46b14b4d586a9b8af0251b3b9f5b121ef",
+	}
+}
+
+func TestSignatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		wantRaw  string
+		wantSign string
+	}{
+		{
+			name: "create captureWallet",
+			raw: CreateRequest{
+				PartnerCode: testPartnerCode,
+				RequestID:   "MM1540456472575",
+				Amount:      50000,
+				OrderID:     "MM1540456472575",
+				OrderInfo:   "SDK team.",
+				RedirectURL: "https://momo.vn",
+				IpnURL:      "https://momo.vn",
+				RequestType: RequestTypeCaptureWallet,
+			}.RawSignature(testAccessKey),
+			wantRaw: "accessKey=F8BBA842ECF85&amount=50000&extraData=&ipnUrl=https://momo.vn&orderId=MM1540456472575" +
+				"&orderInfo=SDK team.&partnerCode=MOMO&redirectUrl=https://momo.vn&requestId=MM1540456472575&requestType=captureWallet",
+			wantSign: "1f122c1f1624b42ae02ea11cb39c4f14e6187bc9786497052092e2f783590384",
+		},
+		{
+			name: "IPN / redirect notification",
+			raw:  sampleNotification().RawSignature(testAccessKey),
+			wantRaw: "accessKey=F8BBA842ECF85&amount=50000&extraData=&message=Successful.&orderId=MM1540456472575" +
+				"&orderInfo=SDK team.&orderType=momo_wallet&partnerCode=MOMO&payType=qr&requestId=MM1540456472575" +
+				"&responseTime=1540456472575&resultCode=0&transId=2588659987",
+			wantSign: "340d42991952e7bdbd565ce19f8087f46b14b4d586a9b8af0251b3b9f5b121ef",
+		},
+		{
+			name: "query",
+			raw: QueryRequest{
+				PartnerCode: testPartnerCode,
+				RequestID:   "MM1540456472575",
+				OrderID:     "MM1540456472575",
+			}.RawSignature(testAccessKey),
+			wantRaw:  "accessKey=F8BBA842ECF85&orderId=MM1540456472575&partnerCode=MOMO&requestId=MM1540456472575",
+			wantSign: "65cd17fe85cddc39e62a1eeb1d1cece2e8a08f48474514f18a3ffaaeee835456",
+		},
+		{
+			name: "refund",
+			raw: RefundRequest{
+				PartnerCode: testPartnerCode,
+				OrderID:     "MM1540456472576",
+				RequestID:   "MM1540456472576",
+				Amount:      50000,
+				TransID:     2588659987,
+			}.RawSignature(testAccessKey),
+			wantRaw: "accessKey=F8BBA842ECF85&amount=50000&description=&orderId=MM1540456472576&partnerCode=MOMO" +
+				"&requestId=MM1540456472576&transId=2588659987",
+			wantSign: "ef39227d2841afa7a36ce7178da897acc582459ebb6d78455e8361b4a47a561d",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.raw != tt.wantRaw {
+				t.Fatalf("rawSignature =\n%s\nwant\n%s", tt.raw, tt.wantRaw)
+			}
+			if got := Sign(testSecretKey, tt.raw); got != tt.wantSign {
+				t.Fatalf("Sign() = %s, want %s", got, tt.wantSign)
+			}
+		})
+	}
+}
+
+func TestVerifyNotification(t *testing.T) {
+	client := NewClient(&config.MoMoConfig{
+		PartnerCode: testPartnerCode,
+		AccessKey:   testAccessKey,
+		SecretKey:   testSecretKey,
+	}, http.DefaultClient)
+
+	tests := []struct {
+		name   string
+		mutate func(n *Notification)
+		want   bool
+	}{
+		{name: "valid", mutate: func(n *Notification) {}, want: true},
+		{name: "uppercase signature", mutate: func(n *Notification) { n.Signature = strings.ToUpper(n.Signature) }, want: true},
+		{name: "tampered amount", mutate: func(n *Notification) { n.Amount = 5000 }, want: false},
+		{name: "tampered resultCode", mutate: func(n *Notification) { n.ResultCode = ResultCodePending }, want: false},
+		{name: "tampered orderId", mutate: func(n *Notification) { n.OrderID = "MM1540456472576" }, want: false},
+		{name: "empty signature", mutate: func(n *Notification) { n.Signature = "" }, want: false},
+		{name: "signed with another secret", mutate: func(n *Notification) {
+			n.Signature = Sign("another-secret", n.RawSignature(testAccessKey))
+		}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := sampleNotification()
+			tt.mutate(&n)
+			if got := client.VerifyNotification(n); got != tt.want {
+				t.Fatalf("VerifyNotification() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotificationValuesRoundTrip(t *testing.T) {
+	want := sampleNotification()
+	got, err := NotificationFromValues(want.Values())
+	if err != nil {
+		t.Fatalf("NotificationFromValues() err = %v", err)
+	}
+	if got != want {
+		t.Fatalf("NotificationFromValues(Values()) = %+v, want %+v", got, want)
+	}
+	if !VerifySignature(testSecretKey, got.RawSignature(testAccessKey), got.Signature) {
+		t.Fatal("redirect query string lost the signed fields")
+	}
+}
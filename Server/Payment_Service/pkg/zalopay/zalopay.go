@@ -0,0 +1,403 @@
+// Package zalopay gọi API v2 của ví ZaloPay (tạo đơn, truy vấn, hoàn tiền) và tính / xác thực MAC HMAC-SHA256.
+// Request ký bằng key1, callback và redirect từ ZaloPay xác thực bằng key2.
+// Các kiểu dữ liệu được dùng chung với pkg/fakegateway để chạy thử với cổng giả lập cục bộ.
+package zalopay
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"payment_service/config"
+)
+
+const (
+	CreatePath = "/v2/create"
+	QueryPath  = "/v2/query"
+	RefundPath = "/v2/refund"
+
+	ReturnCodeSuccess    = 1
+	ReturnCodeFailed     = 2
+	ReturnCodeProcessing = 3
+
+	// Mã phản hồi merchant trả cho callback của ZaloPay
+	CallbackCodeSuccess    = 1
+	CallbackCodeDuplicated = 2
+	CallbackCodeRetry      = 0 // ZaloPay gửi lại callback
+	CallbackCodeInvalidMac = -1
+
+	RedirectStatusSuccess = "1"
+)
+
+// vietnamTime dùng cho tiền tố yymmdd của app_trans_id / m_refund_id (ZaloPay yêu cầu theo giờ GMT+7).
+var vietnamTime = time.FixedZone("ICT", 7*60*60)
+
+// Mac tính HMAC-SHA256 của data với key, trả về hex.
+func Mac(key, data string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyMac so sánh MAC nhận được với MAC tính lại (constant-time).
+func VerifyMac(key, data, mac string) bool {
+	return hmac.Equal([]byte(Mac(key, data)), []byte(strings.ToLower(mac)))
+}
+
+// NewAppTransID tạo app_trans_id dạng yymmdd_<suffix>.
+func NewAppTransID(now time.Time, suffix string) string {
+	return now.In(vietnamTime).Format("060102") + "_" + suffix
+}
+
+// NewRefundID tạo m_refund_id dạng yymmdd_appid_<suffix>.
+func NewRefundID(now time.Time, appID int, suffix string) string {
+	return now.In(vietnamTime).Format("060102") + "_" + strconv.Itoa(appID) + "_" + suffix
+}
+
+// CreateOrder là request tạo đơn (form-urlencoded)
+type CreateOrder struct {
+	AppID          int
+	AppUser        string
+	AppTransID     string
+	AppTime        int64 // Unix milliseconds
+	Amount         int64
+	Item           string // JSON array
+	EmbedData      string // JSON object, chứa redirecturl
+	Description    string
+	BankCode       string
+	CallbackURL    string
+	ExpireDuration int64 // Giây
+	Mac            string
+}
+
+func (o CreateOrder) MacData() string {
+	return strings.Join([]string{
+		strconv.Itoa(o.AppID), o.AppTransID, o.AppUser, strconv.FormatInt(o.Amount, 10),
+		strconv.FormatInt(o.AppTime, 10), o.EmbedData, o.Item,
+	}, "|")
+}
+
+func (o CreateOrder) Form() url.Values {
+	form := url.Values{
+		"app_id":       {strconv.Itoa(o.AppID)},
+		"app_user":     {o.AppUser},
+		"app_trans_id": {o.AppTransID},
+		"app_time":     {strconv.FormatInt(o.AppTime, 10)},
+		"amount":       {strconv.FormatInt(o.Amount, 10)},
+		"item":         {o.Item},
+		"embed_data":   {o.EmbedData},
+		"description":  {o.Description},
+		"bank_code":    {o.BankCode},
+		"callback_url": {o.CallbackURL},
+		"mac":          {o.Mac},
+	}
+	if o.ExpireDuration > 0 {
+		form.Set("expire_duration_seconds", strconv.FormatInt(o.ExpireDuration, 10))
+	}
+	return form
+}
+
+// CreateOrderFromForm đọc CreateOrder từ form (dùng bởi fake gateway).
+func CreateOrderFromForm(form url.Values) (CreateOrder, error) {
+	o := CreateOrder{
+		AppUser:     form.Get("app_user"),
+		AppTransID:  form.Get("app_trans_id"),
+		Item:        form.Get("item"),
+		EmbedData:   form.Get("embed_data"),
+		Description: form.Get("description"),
+		BankCode:    form.Get("bank_code"),
+		CallbackURL: form.Get("callback_url"),
+		Mac:         form.Get("mac"),
+	}
+	var err error
+	if o.AppID, err = strconv.Atoi(form.Get("app_id")); err != nil {
+		return CreateOrder{}, fmt.Errorf("zalopay: invalid app_id: %w", err)
+	}
+	if o.AppTime, err = strconv.ParseInt(form.Get("app_time"), 10, 64); err != nil {
+		return CreateOrder{}, fmt.Errorf("zalopay: invalid app_time: %w", err)
+	}
+	if o.Amount, err = strconv.ParseInt(form.Get("amount"), 10, 64); err != nil {
+		return CreateOrder{}, fmt.Errorf("zalopay: invalid amount: %w", err)
+	}
+	if v := form.Get("expire_duration_seconds"); v != "" {
+		if o.ExpireDuration, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return CreateOrder{}, fmt.Errorf("zalopay: invalid expire_duration_seconds: %w", err)
+		}
+	}
+	return o, nil
+}
+
+type CreateResponse struct {
+	ReturnCode       int    `json:"return_code"`
+	ReturnMessage    string `json:"return_message"`
+	SubReturnCode    int    `json:"sub_return_code"`
+	SubReturnMessage string `json:"sub_return_message"`
+	OrderURL         string `json:"order_url"`
+	ZpTransToken     string `json:"zp_trans_token"`
+	OrderToken       string `json:"order_token"`
+	QrCode           string `json:"qr_code"`
+}
+
+// CallbackRequest là body ZaloPay POST về callback_url; Data là JSON của CallbackData, Mac = HMAC(key2, Data)
+type CallbackRequest struct {
+	Data string `json:"data"`
+	Mac  string `json:"mac"`
+	Type int    `json:"type"`
+}
+
+type CallbackData struct {
+	AppID          int    `json:"app_id"`
+	AppTransID     string `json:"app_trans_id"`
+	AppTime        int64  `json:"app_time"`
+	AppUser        string `json:"app_user"`
+	Amount         int64  `json:"amount"`
+	EmbedData      string `json:"embed_data"`
+	Item           string `json:"item"`
+	ZpTransID      int64  `json:"zp_trans_id"`
+	ServerTime     int64  `json:"server_time"`
+	Channel        int    `json:"channel"`
+	MerchantUserID string `json:"merchant_user_id"`
+	UserFeeAmount  int64  `json:"user_fee_amount"`
+	DiscountAmount int64  `json:"discount_amount"`
+}
+
+type CallbackResponse struct {
+	ReturnCode    int    `json:"return_code"`
+	ReturnMessage string `json:"return_message"`
+}
+
+// Redirect là query string ZaloPay gắn vào redirecturl sau khi khách thanh toán
+type Redirect struct {
+	AppID          string
+	AppTransID     string
+	Pmcid          string
+	BankCode       string
+	Amount         string
+	DiscountAmount string
+	Status         string
+	Checksum       string
+}
+
+func RedirectFromValues(values url.Values) Redirect {
+	return Redirect{
+		AppID:          values.Get("appid"),
+		AppTransID:     values.Get("apptransid"),
+		Pmcid:          values.Get("pmcid"),
+		BankCode:       values.Get("bankcode"),
+		Amount:         values.Get("amount"),
+		DiscountAmount: values.Get("discountamount"),
+		Status:         values.Get("status"),
+		Checksum:       values.Get("checksum"),
+	}
+}
+
+func (r Redirect) ChecksumData() string {
+	return strings.Join([]string{r.AppID, r.AppTransID, r.Pmcid, r.BankCode, r.Amount, r.DiscountAmount, r.Status}, "|")
+}
+
+func (r Redirect) Values() url.Values {
+	return url.Values{
+		"appid":          {r.AppID},
+		"apptransid":     {r.AppTransID},
+		"pmcid":          {r.Pmcid},
+		"bankcode":       {r.BankCode},
+		"amount":         {r.Amount},
+		"discountamount": {r.DiscountAmount},
+		"status":         {r.Status},
+		"checksum":       {r.Checksum},
+	}
+}
+
+// QueryMacData là dữ liệu ký khi truy vấn đơn: app_id|app_trans_id|key1
+func QueryMacData(appID int, appTransID, key1 string) string {
+	return strings.Join([]string{strconv.Itoa(appID), appTransID, key1}, "|")
+}
+
+type QueryResponse struct {
+	ReturnCode       int    `json:"return_code"`
+	ReturnMessage    string `json:"return_message"`
+	SubReturnCode    int    `json:"sub_return_code"`
+	SubReturnMessage string `json:"sub_return_message"`
+	IsProcessing     bool   `json:"is_processing"`
+	Amount           int64  `json:"amount"`
+	DiscountAmount   int64  `json:"discount_amount"`
+	ZpTransID        int64  `json:"zp_trans_id"`
+	ServerTime       int64  `json:"server_time"`
+}
+
+// RefundRequest hoàn tiền cho giao dịch ZpTransID; MRefundID dạng yymmdd_appid_xxx là mã riêng của lần hoàn
+type RefundRequest struct {
+	MRefundID   string
+	AppID       int
+	ZpTransID   int64
+	Amount      int64
+	Timestamp   int64 // Unix milliseconds
+	Description string
+	Mac         string
+}
+
+func (r RefundRequest) MacData() string {
+	return strings.Join([]string{
+		strconv.Itoa(r.AppID), strconv.FormatInt(r.ZpTransID, 10), strconv.FormatInt(r.Amount, 10),
+		r.Description, strconv.FormatInt(r.Timestamp, 10),
+	}, "|")
+}
+
+func (r RefundRequest) Form() url.Values {
+	return url.Values{
+		"m_refund_id": {r.MRefundID},
+		"app_id":      {strconv.Itoa(r.AppID)},
+		"zp_trans_id": {strconv.FormatInt(r.ZpTransID, 10)},
+		"amount":      {strconv.FormatInt(r.Amount, 10)},
+		"timestamp":   {strconv.FormatInt(r.Timestamp, 10)},
+		"description": {r.Description},
+		"mac":         {r.Mac},
+	}
+}
+
+// RefundRequestFromForm đọc RefundRequest từ form (dùng bởi fake gateway).
+func RefundRequestFromForm(form url.Values) (RefundRequest, error) {
+	r := RefundRequest{
+		MRefundID:   form.Get("m_refund_id"),
+		Description: form.Get("description"),
+		Mac:         form.Get("mac"),
+	}
+	var err error
+	if r.AppID, err = strconv.Atoi(form.Get("app_id")); err != nil {
+		return RefundRequest{}, fmt.Errorf("zalopay: invalid app_id: %w", err)
+	}
+	if r.ZpTransID, err = strconv.ParseInt(form.Get("zp_trans_id"), 10, 64); err != nil {
+		return RefundRequest{}, fmt.Errorf("zalopay: invalid zp_trans_id: %w", err)
+	}
+	if r.Amount, err = strconv.ParseInt(form.Get("amount"), 10, 64); err != nil {
+		return RefundRequest{}, fmt.Errorf("zalopay: invalid amount: %w", err)
+	}
+	if r.Timestamp, err = strconv.ParseInt(form.Get("timestamp"), 10, 64); err != nil {
+		return RefundRequest{}, fmt.Errorf("zalopay: invalid timestamp: %w", err)
+	}
+	return r, nil
+}
+
+type RefundResponse struct {
+	ReturnCode       int    `json:"return_code"`
+	ReturnMessage    string `json:"return_message"`
+	SubReturnCode    int    `json:"sub_return_code"`
+	SubReturnMessage string `json:"sub_return_message"`
+	RefundID         int64  `json:"refund_id"`
+}
+
+// Client gọi API của ZaloPay, tự điền app_id và MAC cho từng request.
+type Client struct {
+	cfg        *config.ZaloPayConfig
+	httpClient *http.Client
+}
+
+func NewClient(cfg *config.ZaloPayConfig, httpClient *http.Client) *Client {
+	return &Client{cfg: cfg, httpClient: httpClient}
+}
+
+func (c *Client) AppID() int {
+	return c.cfg.AppID
+}
+
+// VerifyCallback kiểm tra MAC của callback bằng key2 và giải mã phần data.
+func (c *Client) VerifyCallback(req CallbackRequest) (CallbackData, bool, error) {
+	if !VerifyMac(c.cfg.Key2, req.Data, req.Mac) {
+		return CallbackData{}, false, nil
+	}
+	var data CallbackData
+	if err := json.Unmarshal([]byte(req.Data), &data); err != nil {
+		return CallbackData{}, true, fmt.Errorf("zalopay: invalid callback data: %w", err)
+	}
+	return data, true, nil
+}
+
+// VerifyRedirect kiểm tra checksum của redirect bằng key2.
+func (c *Client) VerifyRedirect(r Redirect) bool {
+	return VerifyMac(c.cfg.Key2, r.ChecksumData(), r.Checksum)
+}
+
+func (c *Client) Create(ctx context.Context, order CreateOrder) (*CreateResponse, error) {
+	order.AppID = c.cfg.AppID
+	if order.CallbackURL == "" {
+		order.CallbackURL = c.cfg.CallbackURL
+	}
+	if order.EmbedData == "" {
+		embed, _ := json.Marshal(map[string]string{"redirecturl": c.cfg.RedirectURL})
+		order.EmbedData = string(embed)
+	}
+	if order.Item == "" {
+		order.Item = "[]"
+	}
+	if order.AppTime == 0 {
+		order.AppTime = time.Now().UnixMilli()
+	}
+	order.Mac = Mac(c.cfg.Key1, order.MacData())
+
+	var resp CreateResponse
+	if err := c.postForm(ctx, CreatePath, order.Form(), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) Query(ctx context.Context, appTransID string) (*QueryResponse, error) {
+	form := url.Values{
+		"app_id":       {strconv.Itoa(c.cfg.AppID)},
+		"app_trans_id": {appTransID},
+		"mac":          {Mac(c.cfg.Key1, QueryMacData(c.cfg.AppID, appTransID, c.cfg.Key1))},
+	}
+
+	var resp QueryResponse
+	if err := c.postForm(ctx, QueryPath, form, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) Refund(ctx context.Context, req RefundRequest) (*RefundResponse, error) {
+	req.AppID = c.cfg.AppID
+	req.Mac = Mac(c.cfg.Key1, req.MacData())
+
+	var resp RefundResponse
+	if err := c.postForm(ctx, RefundPath, req.Form(), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) postForm(ctx context.Context, path string, form url.Values, out interface{}) error {
+	endpoint := strings.TrimSuffix(c.cfg.Endpoint, "/") + path
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("zalopay: failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("zalopay: request to %s failed: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("zalopay: failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("zalopay: %s returned status %d: %s", path, resp.StatusCode, string(respBody))
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("zalopay: failed to decode response: %w", err)
+	}
+	return nil
+}
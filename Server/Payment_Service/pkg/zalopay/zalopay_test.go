@@ -0,0 +1,174 @@
+package zalopay
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"payment_service/config"
+)
+
+// Khoá sandbox công khai của ZaloPay (app_id 2553) và dữ liệu mẫu theo tài liệu API v2.
+// MAC mong đợi được tính độc lập với package này:
+//
+//	printf '%s' "<data>" | openssl dgst -sha256 -hmac "$KEY"
+const (
+	testAppID = 2553
+	testKey1  = "PcY4iZIKFCIdgZvA6ueMcMHHUbRLYjPL"
+	testKey2  = "kLtgPl8HHhfvMuDHPwKfgfsY4Ydm9eIz"
+
+	sampleCallbackData = `{"app_id":2553,"app_trans_id":"190613_123456","app_time":1560397946000,"app_user":"user123",` +
+		`"amount":50000,"embed_data":"{}","item":"[]","zp_trans_id":190613000000163,"server_time":1560397990000,` +
+		`"channel":38,"merchant_user_id":"rSVW3Ab0Yt2NFnqu6v0pdiyDs2eT6Z8_Lt3Kj-lhtPo","user_fee_amount":0,"discount_amount":0}`
+	sampleCallbackMac = "cf220d37f62f9b42ce0971112312eeb91f1819bff6ad83c35a323156528a4a33"
+)
+
+func sampleRedirect() Redirect {
+	return Redirect{
+		AppID:          "2553",
+		AppTransID:     "190613_123456",
+		Pmcid:          "38",
+		BankCode:       "zalopayapp",
+		Amount:         "50000",
+		DiscountAmount: "0",
+		Status:         RedirectStatusSuccess,
+		Checksum:       "475563832bcb15c0ce26fca04c470460ea1983619485ea173a2d4dc5a1bb2697",
+	}
+}
+
+func newTestClient() *Client {
+	return NewClient(&config.ZaloPayConfig{AppID: testAppID, Key1: testKey1, Key2: testKey2}, http.DefaultClient)
+}
+
+func TestMac(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		data     string
+		wantData string
+		wantMac  string
+	}{
+		{
+			name: "create order (key1)",
+			key:  testKey1,
+			data: CreateOrder{
+				AppID:      testAppID,
+				AppUser:    "user123",
+				AppTransID: "190613_123456",
+				AppTime:    1560397946000,
+				Amount:     50000,
+				Item:       "[]",
+				EmbedData:  "{}",
+			}.MacData(),
+			wantData: "2553|190613_123456|user123|50000|1560397946000|{}|[]",
+			wantMac:  "0149f8b747c843a061763796f300f380acc802e9901a305d27034ab38f624af8",
+		},
+		{
+			name:     "query (key1)",
+			key:      testKey1,
+			data:     QueryMacData(testAppID, "190613_123456", testKey1),
+			wantData: "2553|190613_123456|" + testKey1,
+			wantMac:  "7dfbc7ecf12fbc839bcd4359d769293a02284b49d7f6d8a995ee671d2a1ba7af",
+		},
+		{
+			name: "refund (key1)",
+			key:  testKey1,
+			data: RefundRequest{
+				AppID:       testAppID,
+				ZpTransID:   190613000000163,
+				Amount:      50000,
+				Timestamp:   1560397946000,
+				Description: "ZaloPay Refund Demo",
+			}.MacData(),
+			wantData: "2553|190613000000163|50000|ZaloPay Refund Demo|1560397946000",
+			wantMac:  "61a9cd45233ff9322e8d0d57359867779c912d0db510b1a297af2ffb92a0952f",
+		},
+		{
+			name:     "redirect checksum (key2)",
+			key:      testKey2,
+			data:     sampleRedirect().ChecksumData(),
+			wantData: "2553|190613_123456|38|zalopayapp|50000|0|1",
+			wantMac:  "475563832bcb15c0ce26fca04c470460ea1983619485ea173a2d4dc5a1bb2697",
+		},
+		{
+			name:     "callback data (key2)",
+			key:      testKey2,
+			data:     sampleCallbackData,
+			wantData: sampleCallbackData,
+			wantMac:  sampleCallbackMac,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.data != tt.wantData {
+				t.Fatalf("mac data = %q, want %q", tt.data, tt.wantData)
+			}
+			if got := Mac(tt.key, tt.data); got != tt.wantMac {
+				t.Fatalf("Mac() = %s, want %s", got, tt.wantMac)
+			}
+		})
+	}
+}
+
+func TestVerifyCallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       CallbackRequest
+		wantValid bool
+		wantErr   bool
+	}{
+		{name: "valid", req: CallbackRequest{Data: sampleCallbackData, Mac: sampleCallbackMac}, wantValid: true},
+		{name: "uppercase mac", req: CallbackRequest{Data: sampleCallbackData, Mac: strings.ToUpper(sampleCallbackMac)}, wantValid: true},
+		{
+			name: "tampered amount",
+			req:  CallbackRequest{Data: strings.Replace(sampleCallbackData, `"amount":50000`, `"amount":5000`, 1), Mac: sampleCallbackMac},
+		},
+		{name: "signed with key1", req: CallbackRequest{Data: sampleCallbackData, Mac: Mac(testKey1, sampleCallbackData)}},
+		{name: "empty mac", req: CallbackRequest{Data: sampleCallbackData}},
+		{
+			name:      "valid mac, malformed data",
+			req:       CallbackRequest{Data: "not-json", Mac: Mac(testKey2, "not-json")},
+			wantValid: true,
+			wantErr:   true,
+		},
+	}
+	client := newTestClient()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, valid, err := client.VerifyCallback(tt.req)
+			if valid != tt.wantValid || (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyCallback() valid = %v, err = %v; want valid = %v, err = %v", valid, err, tt.wantValid, tt.wantErr)
+			}
+			if !tt.wantValid || tt.wantErr {
+				return
+			}
+			if data.AppTransID != "190613_123456" || data.Amount != 50000 || data.ZpTransID != 190613000000163 {
+				t.Fatalf("VerifyCallback() data = %+v", data)
+			}
+		})
+	}
+}
+
+func TestVerifyRedirect(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(r *Redirect)
+		want   bool
+	}{
+		{name: "valid", mutate: func(r *Redirect) {}, want: true},
+		{name: "tampered status", mutate: func(r *Redirect) { r.Status = "-49" }, want: false},
+		{name: "tampered amount", mutate: func(r *Redirect) { r.Amount = "5000" }, want: false},
+		{name: "signed with key1", mutate: func(r *Redirect) { r.Checksum = Mac(testKey1, r.ChecksumData()) }, want: false},
+	}
+	client := newTestClient()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := sampleRedirect()
+			tt.mutate(&r)
+			// Đi qua query string như khi ZaloPay redirect về
+			if got := client.VerifyRedirect(RedirectFromValues(r.Values())); got != tt.want {
+				t.Fatalf("VerifyRedirect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"ticket-service/internal/services"
+	"ticket-service/pkg/manifestexport"
+	"ticket-service/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BoardingManifestController struct {
+	manifestService services.IBoardingManifestService
+	pdfFontPath     string
+	logger          utils.Logger
+}
+
+func NewBoardingManifestController(manifestService services.IBoardingManifestService, pdfFontPath string, logger utils.Logger) *BoardingManifestController {
+	return &BoardingManifestController{
+		manifestService: manifestService,
+		pdfFontPath:     pdfFontPath,
+		logger:          logger,
+	}
+}
+
+// GetBoardingManifestHandler trả về manifest lên xe của chuyến (GET /boarding-manifests/:tripId?format=json|csv|pdf).
+func (bc *BoardingManifestController) GetBoardingManifestHandler(c *gin.Context) {
+	if !requireCheckinStaffRole(c) {
+		return
+	}
+	tripID := c.Param("tripId")
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "format must be one of json, csv, pdf", "data": nil})
+		return
+	}
+
+	manifest, err := bc.manifestService.GetBoardingManifest(c.Request.Context(), tripID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Failed to get boarding manifest: " + err.Error(), "data": nil})
+		return
+	}
+
+	var (
+		buf         bytes.Buffer
+		contentType string
+	)
+	switch format {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Boarding manifest retrieved successfully", "data": manifest})
+		return
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+		err = manifestexport.WriteCSV(&buf, manifest)
+	case "pdf":
+		contentType = "application/pdf"
+		err = manifestexport.WritePDF(&buf, manifest, bc.pdfFontPath)
+	}
+	if err != nil {
+		bc.logger.Error("Failed to export boarding manifest of trip %s as %s: %v", tripID, format, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Failed to export boarding manifest", "data": nil})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="manifest-trip-%s.%s"`, tripID, format))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
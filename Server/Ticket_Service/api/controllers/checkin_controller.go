@@ -3,11 +3,11 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
-	"regexp"
-	"strconv"
 	"strings"
+	"ticket-service/domain/models"
 	"ticket-service/internal/services"
 	"ticket-service/pkg/utils"
 
@@ -26,14 +26,12 @@ func NewCheckinController(checkinService services.ICheckinService, logger utils.
 	}
 }
 
-// Struct mới cho request check-in
+// CheckinRequest nhận nguyên nội dung QR quét được, service tự xác thực chữ ký.
 type CheckinRequest struct {
 	QRContent string `json:"qr_content" binding:"required"`
 	TripID    string `json:"trip_id" binding:"required"`
 }
 
-var qrRegex = regexp.MustCompile(`^TICKET:(.*)-SEAT:(\d+)$`)
-
 func (cc *CheckinController) CheckinHandler(c *gin.Context) {
 	var req CheckinRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -46,48 +44,26 @@ func (cc *CheckinController) CheckinHandler(c *gin.Context) {
 		return
 	}
 
-	// Sử dụng Regex để phân tích chuỗi QRContent
-	matches := qrRegex.FindStringSubmatch(req.QRContent)
-
-	// matches[0] là toàn bộ chuỗi khớp
-	// matches[1] là group bắt giữ đầu tiên (ticketID)
-	// matches[2] là group bắt giữ thứ hai (seatTicketID)
-	if len(matches) != 3 {
-		cc.logger.Error("Check-in: Invalid QR content format: '%s'", req.QRContent)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    http.StatusBadRequest,
-			"message": "Invalid QR content format. Expected 'TICKET:{id}-SEAT:{id}'.",
-			"data":    nil,
-		})
-		return
-	}
-
-	ticketID := matches[1]
-	// Chuyển đổi seatTicketID từ string sang int
-	seatTicketID, err := strconv.Atoi(matches[2])
-	if err != nil {
-		// Trường hợp này hiếm khi xảy ra nếu regex đúng, nhưng vẫn nên kiểm tra
-		cc.logger.Error("Check-in: Could not parse Seat ID from QR '%s': %v", req.QRContent, err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    http.StatusBadRequest,
-			"message": "Invalid Seat ID in QR content.",
-			"data":    nil,
-		})
-		return
-	}
-
 	userID := c.GetHeader("X-User-ID")
 	userRole := c.GetHeader("X-User-Role")
 	note := fmt.Sprintf("Checked-in by User %s (Role: %s)", userID, userRole)
 
-	cc.logger.Info("Attempting Check-in for QR: '%s', TripID: %s", req.QRContent, req.TripID)
-	response, err := cc.checkinService.ProcessCheckin(c.Request.Context(), ticketID, int32(seatTicketID), req.TripID, note)
+	cc.logger.Info("Attempting Check-in for TripID: %s", req.TripID)
+	response, err := cc.checkinService.ProcessCheckin(c.Request.Context(), req.QRContent, req.TripID, note)
 	if err != nil {
-		cc.logger.Error("Check-in failed for QR '%s': %v", req.QRContent, err)
+		cc.logger.Error("Check-in failed for TripID %s: %v", req.TripID, err)
 		var statusCode int
 		errorMessage := err.Error()
 
 		switch {
+		case errors.Is(err, services.ErrInvalidQRCode):
+			statusCode = http.StatusBadRequest
+			errorMessage = services.ErrInvalidQRCode.Error()
+		case errors.Is(err, services.ErrQRCodeExpired):
+			statusCode = http.StatusGone
+			errorMessage = services.ErrQRCodeExpired.Error()
+		case errors.Is(err, services.ErrQRCodeReplayed):
+			statusCode = http.StatusConflict
 		case strings.Contains(errorMessage, "invalid or non-existent"):
 			statusCode = http.StatusNotFound
 		case strings.Contains(errorMessage, "mismatch") || strings.Contains(errorMessage, "not for this trip"):
@@ -140,3 +116,57 @@ func (cc *CheckinController) GetTripCheckinsHandler(c *gin.Context) {
 		"data":    response,
 	})
 }
+
+// requireCheckinStaffRole chỉ cho nhân viên soát vé (tài xế/phụ xe, lễ tân, admin, operator), trả về false nếu đã ghi response 403.
+func requireCheckinStaffRole(c *gin.Context) bool {
+	userRole := c.GetHeader("X-User-Role")
+	switch userRole {
+	case RoleDriver, RoleReception, RoleAdmin, RoleOperator:
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"code":    http.StatusForbidden,
+		"message": fmt.Sprintf("Access denied. Role '%s' is not authorized for this action.", userRole),
+	})
+	return false
+}
+
+// GetTripManifestHandler trả về manifest đã ký của chuyến để thiết bị soát vé tải trước khi xuất bến.
+func (cc *CheckinController) GetTripManifestHandler(c *gin.Context) {
+	if !requireCheckinStaffRole(c) {
+		return
+	}
+	tripID := c.Param("tripID")
+
+	manifest, err := cc.checkinService.GetTripManifest(c.Request.Context(), tripID)
+	if err != nil {
+		cc.logger.Error("Failed to build manifest for TripID %s: %v", tripID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Failed to build trip manifest.", "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Trip manifest generated successfully", "data": manifest})
+}
+
+// SyncOfflineCheckinsHandler nhận lô lượt quét offline và trả về kết quả của từng lượt.
+func (cc *CheckinController) SyncOfflineCheckinsHandler(c *gin.Context) {
+	if !requireCheckinStaffRole(c) {
+		return
+	}
+	var req models.OfflineCheckinBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid request body: " + err.Error(), "data": nil})
+		return
+	}
+
+	userID := c.GetHeader("X-User-ID")
+	userRole := c.GetHeader("X-User-Role")
+	note := fmt.Sprintf("Offline check-in by User %s (Role: %s) on device %s", userID, userRole, req.DeviceID)
+
+	response, err := cc.checkinService.SyncOfflineCheckins(c.Request.Context(), &req, note)
+	if err != nil {
+		cc.logger.Error("Offline check-in sync failed for TripID %s: %v", req.TripID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Failed to sync offline check-ins.", "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Offline check-ins synced", "data": response})
+}
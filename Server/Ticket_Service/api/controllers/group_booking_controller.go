@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"ticket-service/domain/models"
+	"ticket-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GroupBookingController struct {
+	ticketService services.ITicketService
+}
+
+func NewGroupBookingController(ticketService services.ITicketService) *GroupBookingController {
+	return &GroupBookingController{
+		ticketService: ticketService,
+	}
+}
+
+// CreateGroupBookingHandler đặt vé cho đoàn (POST /group-bookings).
+// Khách vãng lai được xác định bằng số điện thoại người liên hệ.
+func (g *GroupBookingController) CreateGroupBookingHandler(c *gin.Context) {
+	var req models.CreateGroupBookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid request body: " + err.Error(), "data": nil})
+		return
+	}
+	req.Actor.Phone = req.ContactPhone
+	if !bindTicketActor(c, &req.Actor, "book for a group") {
+		return
+	}
+
+	booking, err := g.ticketService.CreateGroupBooking(c.Request.Context(), &req)
+	if err != nil {
+		statusCode := groupBookingErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": "Failed to create group booking: " + err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"code": http.StatusCreated, "message": "Group booking created successfully", "data": booking})
+}
+
+// GetGroupBookingHandler trả về đơn đoàn kèm vé và hành khách (GET /group-bookings/:groupRef?phone=...).
+func (g *GroupBookingController) GetGroupBookingHandler(c *gin.Context) {
+	actor := models.TicketActor{Phone: c.Query("phone")}
+	if !bindTicketActor(c, &actor, "view a group booking") {
+		return
+	}
+
+	booking, err := g.ticketService.GetGroupBooking(c.Request.Context(), c.Param("groupRef"), &actor)
+	if err != nil {
+		statusCode := groupBookingErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": "Failed to retrieve group booking: " + err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Group booking retrieved successfully", "data": booking})
+}
+
+// CancelGroupPassengerHandler huỷ một hành khách của đoàn và hoàn phần tiền tương ứng
+// (POST /group-bookings/:groupRef/passengers/:seatTicketId/cancel).
+func (g *GroupBookingController) CancelGroupPassengerHandler(c *gin.Context) {
+	seatTicketID, err := strconv.Atoi(c.Param("seatTicketId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid seat ticket id", "data": nil})
+		return
+	}
+	var req models.CancelGroupPassengerRequest
+	// Body là tuỳ chọn với khách đã đăng nhập
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid request body: " + err.Error(), "data": nil})
+			return
+		}
+	}
+	if !bindTicketActor(c, &req.TicketActor, "cancel a passenger") {
+		return
+	}
+
+	result, err := g.ticketService.CancelGroupPassenger(c.Request.Context(), c.Param("groupRef"), int32(seatTicketID), &req)
+	if err != nil {
+		statusCode := groupBookingErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": "Failed to cancel passenger: " + err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Passenger cancelled successfully", "data": result})
+}
+
+// groupBookingErrorStatus map lỗi đơn đoàn sang HTTP status.
+func groupBookingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidSeatSegment), errors.Is(err, models.ErrUnknownStop), errors.Is(err, services.ErrDuplicateGroupSeat), errors.Is(err, services.ErrTooManyGroupPassengers):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrGroupBookingNotFound), errors.Is(err, services.ErrGroupPassengerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrGroupBookingUnavailable):
+		return http.StatusConflict
+	}
+	return cancelErrorStatus(err)
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"ticket-service/domain/models"
+	"ticket-service/internal/repositories"
+	"ticket-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SeatLayoutController struct {
+	seatLayoutService services.ISeatLayoutService
+	resolveSegment    services.SeatSegmentResolver
+}
+
+func NewSeatLayoutController(seatLayoutService services.ISeatLayoutService, resolveSegment services.SeatSegmentResolver) *SeatLayoutController {
+	return &SeatLayoutController{
+		seatLayoutService: seatLayoutService,
+		resolveSegment:    resolveSegment,
+	}
+}
+
+// ListSeatLayoutsHandler trả về danh sách mẫu sơ đồ ghế (không kèm vị trí ghế).
+func (s *SeatLayoutController) ListSeatLayoutsHandler(c *gin.Context) {
+	layouts, err := s.seatLayoutService.ListLayouts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Failed to list seat layouts: " + err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Seat layouts retrieved successfully", "data": layouts})
+}
+
+// GetSeatLayoutHandler trả về một mẫu sơ đồ kèm vị trí từng ghế.
+func (s *SeatLayoutController) GetSeatLayoutHandler(c *gin.Context) {
+	layoutID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid layout id", "data": nil})
+		return
+	}
+
+	layout, err := s.seatLayoutService.GetLayout(c.Request.Context(), int32(layoutID))
+	if err != nil {
+		statusCode := seatLayoutErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Seat layout retrieved successfully", "data": layout})
+}
+
+// GetVehicleTypeLayoutHandler trả về mẫu sơ đồ sẽ được dùng cho loại xe (GET /seat-layouts/vehicle-types/:typeId?seat_count=).
+func (s *SeatLayoutController) GetVehicleTypeLayoutHandler(c *gin.Context) {
+	typeID, err := strconv.Atoi(c.Param("typeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid vehicle type id", "data": nil})
+		return
+	}
+	seatCount, _ := strconv.Atoi(c.DefaultQuery("seat_count", "0"))
+
+	vehicle := &models.VehicleInfo{SeatNumber: seatCount, Type: &models.VehicleTypeInfo{ID: typeID}}
+	layout, err := s.seatLayoutService.ResolveLayout(c.Request.Context(), vehicle)
+	if err != nil {
+		statusCode := seatLayoutErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Seat layout retrieved successfully", "data": layout})
+}
+
+// UpsertSeatLayoutHandler tạo hoặc thay thế mẫu sơ đồ ghế của một loại xe (chỉ admin/operator).
+func (s *SeatLayoutController) UpsertSeatLayoutHandler(c *gin.Context) {
+	if !requireManagerRole(c) {
+		return
+	}
+
+	var req models.UpsertSeatLayoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid request body: " + err.Error(), "data": nil})
+		return
+	}
+
+	layout, err := s.seatLayoutService.UpsertLayout(c.Request.Context(), &req)
+	if err != nil {
+		statusCode := seatLayoutErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Seat layout saved successfully", "data": layout})
+}
+
+// DeleteSeatLayoutHandler xoá một mẫu sơ đồ ghế (chỉ admin/operator).
+func (s *SeatLayoutController) DeleteSeatLayoutHandler(c *gin.Context) {
+	if !requireManagerRole(c) {
+		return
+	}
+
+	layoutID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid layout id", "data": nil})
+		return
+	}
+
+	if err := s.seatLayoutService.DeleteLayout(c.Request.Context(), int32(layoutID)); err != nil {
+		statusCode := seatLayoutErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Seat layout deleted successfully", "data": nil})
+}
+
+// GetTripSeatMapHandler trả về sơ đồ ghế của chuyến kèm trạng thái còn trống (GET /seat-maps/:tripId?pickup_location=&dropoff_location=).
+func (s *SeatLayoutController) GetTripSeatMapHandler(c *gin.Context) {
+	segment, ok := bindSeatSegment(c, c.Param("tripId"), s.resolveSegment)
+	if !ok {
+		return
+	}
+	seatMap, err := s.seatLayoutService.GetTripSeatMap(c.Request.Context(), c.Param("tripId"), segment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Failed to get seat map: " + err.Error(), "data": nil})
+		return
+	}
+	if len(seatMap.Seats) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"code": http.StatusNotFound, "message": "No seats found for this trip", "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Seat map retrieved successfully", "data": seatMap})
+}
+
+// requireManagerRole chỉ cho phép admin/operator, trả về false nếu đã ghi response 403.
+func requireManagerRole(c *gin.Context) bool {
+	userRole := c.GetHeader("X-User-Role")
+	switch userRole {
+	case RoleAdmin, RoleOperator:
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"code":    http.StatusForbidden,
+		"message": fmt.Sprintf("Access denied. Role '%s' is not authorized for this action.", userRole),
+	})
+	return false
+}
+
+// seatLayoutErrorStatus map lỗi mẫu sơ đồ ghế sang HTTP status.
+func seatLayoutErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repositories.ErrSeatLayoutNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidSeatLayout):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"ticket-service/domain/models"
+	"ticket-service/internal/repositories"
 	"ticket-service/internal/services"
 	"time"
 
@@ -20,6 +22,7 @@ const (
 	RoleReception = "ROLE_RECEPTION"
 	RoleAdmin     = "ROLE_ADMIN"
 	RoleOperator  = "ROLE_OPERATOR"
+	RoleDriver    = "ROLE_DRIVER"
 )
 
 var (
@@ -65,7 +68,7 @@ func (t *TicketController) InitiateBookingHandler(c *gin.Context) {
 
 	// 3. GỌI SERVICE ĐỂ ĐƯA YÊU CẦU VÀO HÀNG ĐỢI
 	// Sử dụng context của request cho tác vụ nhanh này
-	err := t.ticketService.QueueNewBooking(c.Request.Context(), bookingID, &input, customerNullInt)
+	position, err := t.ticketService.QueueNewBooking(c.Request.Context(), bookingID, &input, customerNullInt)
 	if err != nil {
 		// Nếu service không đưa vào hàng đợi được, trả về lỗi server
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -75,15 +78,61 @@ func (t *TicketController) InitiateBookingHandler(c *gin.Context) {
 		return
 	}
 
-	// 4. Phản hồi ngay lập tức cho client
+	// 4. Phản hồi ngay lập tức cho client. Chuyến đang quá tải thì kèm vị trí trong phòng chờ;
+	// vị trí được cập nhật qua WebSocket (tin nhắn "queue") và GET /bookings/:bookingId.
+	if position != nil {
+		c.JSON(http.StatusAccepted, gin.H{
+			"code":    http.StatusAccepted,
+			"message": "This trip is in high demand. Your booking request is in the waiting room, please track your position via WebSocket or GET /bookings/:bookingId.",
+			"data": gin.H{
+				"bookingId": bookingID,
+				"queue":     position,
+			},
+		})
+		return
+	}
 	c.JSON(http.StatusAccepted, gin.H{
 		"code":    http.StatusAccepted,
-		"message": "Booking request accepted. Please track the progress via WebSocket.",
+		"message": "Booking request accepted. Please track the progress via WebSocket or GET /bookings/:bookingId.",
 		"data": gin.H{
 			"bookingId": bookingID,
 		},
 	})
 }
+
+// GetBookingStatusHandler trả về trạng thái yêu cầu đặt vé (GET /bookings/:bookingId), dùng khi client không giữ được WebSocket.
+// Lần poll đầu tiên cũng tính là client đã theo dõi booking, nên vé không bị huỷ vì không kết nối WebSocket.
+func (t *TicketController) GetBookingStatusHandler(c *gin.Context) {
+	var customerID sql.NullInt32
+	if c.GetHeader("X-User-Role") == RoleCustomer {
+		id, err := strconv.Atoi(c.GetHeader("X-User-ID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid X-User-ID format.", "data": nil})
+			return
+		}
+		customerID = sql.NullInt32{Int32: int32(id), Valid: true}
+	}
+
+	status, err := t.ticketService.TrackBookingRequest(c.Request.Context(), c.Param("bookingId"), customerID)
+	if err != nil {
+		statusCode := bookingRequestErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": "Failed to retrieve booking status: " + err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Booking status retrieved successfully", "data": status})
+}
+
+// bookingRequestErrorStatus map lỗi theo dõi booking sang HTTP status.
+func bookingRequestErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrBookingRequestNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrBookingRequestExpired):
+		return http.StatusGone
+	}
+	return http.StatusInternalServerError
+}
+
 func (t *TicketController) GetTicketHandler(c *gin.Context) {
 	userIDStr := c.GetHeader("X-User-ID")
 	userRole := c.GetHeader("X-User-Role")
@@ -276,12 +325,7 @@ func (t *TicketController) CreateTicketHandler(c *gin.Context) {
 		// Determine appropriate status code based on error type
 		// For example, if err is due to seat unavailability (conflict)
 		// For now, using a generic StatusConflict but can be refined
-		statusCode := http.StatusConflict
-		// if errors.Is(err, services.ErrSeatsNotAvailable) { // Example specific error
-		// 	statusCode = http.StatusConflict
-		// } else if errors.Is(err, someOtherValidationError) {
-		// 	statusCode = http.StatusBadRequest
-		// }
+		statusCode := fareErrorStatus(err, http.StatusConflict)
 		c.JSON(statusCode, gin.H{
 			"code":    statusCode,
 			"message": "Failed to create ticket: " + err.Error(),
@@ -340,8 +384,9 @@ func (t *TicketController) CreateTicketByStaffHandler(c *gin.Context) {
 
 	ticket, err := t.ticketService.CreateTicketByStaff(c.Request.Context(), &input, staffIDStr)
 	if err != nil {
-		c.JSON(http.StatusConflict, gin.H{
-			"code":    http.StatusConflict,
+		statusCode := fareErrorStatus(err, http.StatusConflict)
+		c.JSON(statusCode, gin.H{
+			"code":    statusCode,
 			"message": "Failed to create ticket by staff: " + err.Error(),
 			"data":    nil,
 		})
@@ -355,9 +400,44 @@ func (t *TicketController) CreateTicketByStaffHandler(c *gin.Context) {
 	})
 }
 
+// bindSeatSegment đọc điểm đón/trả (station id) từ query ?pickup_location=&dropoff_location= (bỏ trống là cả chuyến)
+// và quy đổi sang đoạn đường theo điểm dừng của chuyến, trả về false nếu đã ghi response lỗi.
+func bindSeatSegment(c *gin.Context, tripID string, resolve services.SeatSegmentResolver) (models.SeatSegment, bool) {
+	pickupLocation, errPickup := strconv.Atoi(c.DefaultQuery("pickup_location", "0"))
+	dropoffLocation, errDropoff := strconv.Atoi(c.DefaultQuery("dropoff_location", "0"))
+	if errPickup != nil || errDropoff != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid pickup_location/dropoff_location", "data": nil})
+		return models.SeatSegment{}, false
+	}
+	segment, err := resolve(c.Request.Context(), tripID, int32(pickupLocation), int32(dropoffLocation))
+	if err != nil {
+		statusCode := seatSegmentErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": err.Error(), "data": nil})
+		return models.SeatSegment{}, false
+	}
+	return segment, true
+}
+
+// seatSegmentErrorStatus map lỗi quy đổi điểm đón/trả sang HTTP status.
+func seatSegmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidSeatSegment), errors.Is(err, models.ErrUnknownStop):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrTripNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// GetAvailableHandler trả về ghế trống của chuyến, có thể lọc theo điểm đón/trả ?pickup_location=&dropoff_location=.
 func (t *TicketController) GetAvailableHandler(c *gin.Context) {
 	id := c.Param("id")
-	seats, err := t.ticketService.GetAvailableSeatsByTripID(c.Request.Context(), id)
+	segment, ok := bindSeatSegment(c, id, t.ticketService.ResolveSeatSegment)
+	if !ok {
+		return
+	}
+	seats, err := t.ticketService.GetAvailableSeatsByTripID(c.Request.Context(), id, segment)
 	if err != nil {
 		if errors.Is(err, ErrTripNotFoundFromService) { // Example specific error from service
 			c.JSON(http.StatusNotFound, gin.H{"code": http.StatusNotFound, "message": "Trip not found.", "data": nil})
@@ -377,7 +457,9 @@ func (t *TicketController) GetAvailableHandler(c *gin.Context) {
 
 func (t *TicketController) GetAvailableMultiTripsHandler(c *gin.Context) {
 	var input struct {
-		TripIDs []string `json:"trip_ids" binding:"required"`
+		TripIDs         []string `json:"trip_ids" binding:"required"`
+		PickupLocation  int32    `json:"pickup_location"` // Điểm đón/trả (station id) áp dụng cho mọi chuyến, bỏ trống là cả chuyến
+		DropoffLocation int32    `json:"dropoff_location"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -388,7 +470,6 @@ func (t *TicketController) GetAvailableMultiTripsHandler(c *gin.Context) {
 		})
 		return
 	}
-
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
@@ -402,7 +483,12 @@ func (t *TicketController) GetAvailableMultiTripsHandler(c *gin.Context) {
 
 	for _, tripID := range input.TripIDs {
 		go func(id string) {
-			seats, errService := t.ticketService.GetAvailableSeatsByTripID(ctx, id)
+			// Mỗi chuyến có danh sách điểm dừng riêng nên đoạn đường được quy đổi theo từng chuyến
+			segment, errService := t.ticketService.ResolveSeatSegment(ctx, id, input.PickupLocation, input.DropoffLocation)
+			var seats []models.SeatReturn
+			if errService == nil {
+				seats, errService = t.ticketService.GetAvailableSeatsByTripID(ctx, id, segment)
+			}
 			resultChan <- tripResult{
 				TripID: id,
 				Seats:  seats,
@@ -493,6 +579,105 @@ func (t *TicketController) GetAllTicketsPaginatedHandler(c *gin.Context) {
 	})
 }
 
+// SearchTicketsHandler tìm vé cho nhân viên (GET /tickets/search).
+// Query: phone, email, name, trip_id, from, to (YYYY-MM-DD hoặc RFC3339, "to" dạng ngày tính hết ngày đó),
+// status, payment_status, booking_channel, sort (booking_time|price), order (asc|desc, mặc định desc), cursor, limit.
+func (t *TicketController) SearchTicketsHandler(c *gin.Context) {
+	userRole := c.GetHeader("X-User-Role")
+	switch userRole {
+	case RoleReception, RoleAdmin, RoleOperator:
+	default:
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    http.StatusForbidden,
+			"message": fmt.Sprintf("Access denied. Role '%s' is not authorized for this action.", userRole),
+			"data":    nil,
+		})
+		return
+	}
+
+	filter, err := parseTicketSearchFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": err.Error(), "data": nil})
+		return
+	}
+
+	result, err := t.ticketService.SearchTickets(c.Request.Context(), filter)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, models.ErrInvalidTicketSearch) {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": "Failed to search tickets: " + err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Tickets retrieved successfully", "data": result})
+}
+
+func parseTicketSearchFilter(c *gin.Context) (*models.TicketSearchFilter, error) {
+	filter := &models.TicketSearchFilter{
+		Phone:    strings.TrimSpace(c.Query("phone")),
+		Email:    strings.TrimSpace(c.Query("email")),
+		Name:     strings.TrimSpace(c.Query("name")),
+		TripID:   strings.TrimSpace(c.Query("trip_id")),
+		SortBy:   c.Query("sort"),
+		SortDesc: !strings.EqualFold(c.DefaultQuery("order", "desc"), "asc"),
+		Cursor:   c.Query("cursor"),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit %q", limit)
+		}
+		filter.Limit = n
+	}
+
+	var err error
+	if filter.BookedFrom, err = parseSearchTime(c.Query("from"), false); err != nil {
+		return nil, err
+	}
+	if filter.BookedTo, err = parseSearchTime(c.Query("to"), true); err != nil {
+		return nil, err
+	}
+	for param, target := range map[string]**int16{
+		"status":          &filter.Status,
+		"payment_status":  &filter.PaymentStatus,
+		"booking_channel": &filter.BookingChannel,
+	} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", param, value)
+		}
+		v := int16(n)
+		*target = &v
+	}
+	return filter, nil
+}
+
+// parseSearchTime đọc "YYYY-MM-DD" (theo giờ Việt Nam) hoặc RFC3339 và trả về giờ UTC như Booking_Time trong DB.
+// endOfDay: ngày không kèm giờ được tính tới hết ngày đó (mốc "to" không bao gồm).
+func parseSearchTime(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		at = at.UTC()
+		return &at, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, models.TripLocation)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC3339", value)
+	}
+	if endOfDay {
+		day = day.AddDate(0, 0, 1)
+	}
+	day = day.UTC()
+	return &day, nil
+}
+
 func (t *TicketController) GetPublicTicketInfoByIDHandler(c *gin.Context) {
 
 	userRole := c.GetHeader("X-User-Role")
@@ -544,3 +729,180 @@ func (t *TicketController) GetPublicTicketInfoByIDHandler(c *gin.Context) {
 		"data":    gin.H{"ticket": ticket},
 	})
 }
+
+// QuoteFareHandler trả về giá vé do server tính để client hiển thị trước khi đặt.
+func (t *TicketController) QuoteFareHandler(c *gin.Context) {
+	var input models.TicketInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    http.StatusBadRequest,
+			"message": "Invalid request body: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	fare, err := t.ticketService.QuoteFare(c.Request.Context(), &input)
+	if err != nil {
+		statusCode := fareErrorStatus(err, http.StatusInternalServerError)
+		c.JSON(statusCode, gin.H{
+			"code":    statusCode,
+			"message": "Failed to compute fare: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    http.StatusOK,
+		"message": "Fare computed successfully",
+		"data":    fare,
+	})
+}
+
+// fareErrorStatus map lỗi của fare engine sang HTTP status, các lỗi khác dùng fallback.
+func fareErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.ErrPriceMismatch), errors.Is(err, repositories.ErrPolicyNotFound), errors.Is(err, models.ErrInvalidSeatSegment), errors.Is(err, models.ErrUnknownStop):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrTripNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrFareUnavailable):
+		return http.StatusUnprocessableEntity
+	}
+	return fallback
+}
+
+// CancelTicketHandler cho phép khách tự huỷ vé (POST /tickets/:id/cancel).
+// Khách đăng nhập được xác định qua X-User-ID, khách vãng lai phải gửi số điện thoại đặt vé trong body.
+func (t *TicketController) CancelTicketHandler(c *gin.Context) {
+	var req models.CancelTicketRequest
+	// Body là tuỳ chọn với khách đã đăng nhập
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid request body: " + err.Error(), "data": nil})
+			return
+		}
+	}
+
+	if !bindTicketActor(c, &req.TicketActor, "cancel a ticket") {
+		return
+	}
+
+	result, err := t.ticketService.CancelTicketByCustomer(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		statusCode := cancelErrorStatus(err)
+		c.JSON(statusCode, gin.H{
+			"code":    statusCode,
+			"message": "Failed to cancel ticket: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    http.StatusOK,
+		"message": "Ticket cancelled successfully",
+		"data":    result,
+	})
+}
+
+// GetTicketHistoryHandler trả về lịch sử chuyển trạng thái của vé (GET /tickets/:id/history?phone=...).
+func (t *TicketController) GetTicketHistoryHandler(c *gin.Context) {
+	actor := models.TicketActor{Phone: c.Query("phone")}
+	if !bindTicketActor(c, &actor, "view ticket history") {
+		return
+	}
+
+	history, err := t.ticketService.GetTicketHistory(c.Request.Context(), c.Param("id"), &actor)
+	if err != nil {
+		statusCode := cancelErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": "Failed to retrieve ticket history: " + err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Ticket history retrieved successfully", "data": history})
+}
+
+// cancelErrorStatus map lỗi huỷ vé sang HTTP status.
+func cancelErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrTicketNotFound), errors.Is(err, services.ErrTripNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrTicketNotOwned):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrTicketNotCancellable), errors.Is(err, services.ErrTripDeparted), errors.Is(err, services.ErrTicketBusy):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// bindTicketActor xác định người thao tác trên vé từ header X-User-ID/X-User-Role (hoặc số điện thoại với khách vãng lai).
+// Trả về false nếu đã ghi response lỗi.
+func bindTicketActor(c *gin.Context, actor *models.TicketActor, action string) bool {
+	userIDStr := c.GetHeader("X-User-ID")
+	userRole := c.GetHeader("X-User-Role")
+	switch userRole {
+	case RoleReception, RoleAdmin, RoleOperator:
+		actor.IsStaff = true
+	}
+	if userIDStr != "" {
+		id, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid X-User-ID format.", "data": nil})
+			return false
+		}
+		actor.CustomerID = sql.NullInt32{Int32: int32(id), Valid: !actor.IsStaff}
+		actor.Actor = fmt.Sprintf("%s:%d", userRole, id)
+		return true
+	}
+
+	actor.IsStaff = false // Không tin X-User-Role nếu không có X-User-ID
+	if actor.Phone == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Phone is required to " + action + " without logging in.", "data": nil})
+		return false
+	}
+	actor.Actor = "GUEST:" + actor.Phone
+	return true
+}
+
+// ChangeSeatsHandler đổi ghế hoặc đổi sang chuyến khác cùng tuyến (POST /tickets/:id/change-seats).
+func (t *TicketController) ChangeSeatsHandler(c *gin.Context) {
+	var req models.ChangeSeatsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid request body: " + err.Error(), "data": nil})
+		return
+	}
+	if !bindTicketActor(c, &req.TicketActor, "change seats") {
+		return
+	}
+
+	result, err := t.ticketService.ChangeSeats(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		statusCode := changeSeatsErrorStatus(err)
+		c.JSON(statusCode, gin.H{
+			"code":    statusCode,
+			"message": "Failed to change seats: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    http.StatusOK,
+		"message": "Seats changed successfully",
+		"data":    result,
+	})
+}
+
+// changeSeatsErrorStatus map lỗi đổi ghế sang HTTP status.
+func changeSeatsErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrAdditionalPaymentRequired):
+		return http.StatusPaymentRequired
+	case errors.Is(err, services.ErrSeatChangeNotAllowed), errors.Is(err, services.ErrSeatsUnavailable):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrDifferentRoute):
+		return http.StatusBadRequest
+	}
+	return cancelErrorStatus(err)
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"ticket-service/domain/models"
+	"ticket-service/internal/repositories"
+	"ticket-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WaitlistController struct {
+	waitlistService services.IWaitlistService
+}
+
+func NewWaitlistController(waitlistService services.IWaitlistService) *WaitlistController {
+	return &WaitlistController{
+		waitlistService: waitlistService,
+	}
+}
+
+// JoinWaitlistHandler đăng ký chờ ghế của chuyến đã hết chỗ (POST /waitlist).
+// Khi có ghế được trả lại, khách nhận thông báo và được giữ ghế trong một khoảng thời gian để đặt vé như bình thường.
+func (w *WaitlistController) JoinWaitlistHandler(c *gin.Context) {
+	customerID, ok := requireCustomerID(c)
+	if !ok {
+		return
+	}
+
+	var req models.JoinWaitlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid request body: " + err.Error(), "data": nil})
+		return
+	}
+
+	entry, err := w.waitlistService.JoinWaitlist(c.Request.Context(), customerID, &req)
+	if err != nil {
+		statusCode := waitlistErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"code": http.StatusCreated, "message": "Joined waitlist successfully", "data": entry})
+}
+
+// ListWaitlistHandler trả về các lượt chờ của khách kèm vị trí trong hàng và ghế đang được giữ (GET /waitlist).
+func (w *WaitlistController) ListWaitlistHandler(c *gin.Context) {
+	customerID, ok := requireCustomerID(c)
+	if !ok {
+		return
+	}
+
+	entries, err := w.waitlistService.ListCustomerWaitlist(c.Request.Context(), customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Failed to retrieve waitlist", "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Waitlist retrieved successfully", "data": entries})
+}
+
+// LeaveWaitlistHandler rời danh sách chờ; ghế đang giữ (nếu có) được chuyển cho người kế tiếp (DELETE /waitlist/:id).
+func (w *WaitlistController) LeaveWaitlistHandler(c *gin.Context) {
+	customerID, ok := requireCustomerID(c)
+	if !ok {
+		return
+	}
+	entryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid waitlist entry id", "data": nil})
+		return
+	}
+
+	if err := w.waitlistService.LeaveWaitlist(c.Request.Context(), customerID, int32(entryID)); err != nil {
+		statusCode := waitlistErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Left waitlist successfully", "data": nil})
+}
+
+// requireCustomerID đọc X-User-ID của khách đã đăng nhập, trả về false nếu đã ghi response lỗi.
+// Danh sách chờ cần tài khoản vì thông báo được gửi qua notification_service theo user id.
+func requireCustomerID(c *gin.Context) (int32, bool) {
+	userIDStr := c.GetHeader("X-User-ID")
+	if userIDStr == "" || c.GetHeader("X-User-Role") != RoleCustomer {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": http.StatusUnauthorized, "message": "Customer authentication required.", "data": nil})
+		return 0, false
+	}
+	customerID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid X-User-ID format.", "data": nil})
+		return 0, false
+	}
+	return int32(customerID), true
+}
+
+// waitlistErrorStatus map lỗi danh sách chờ sang HTTP status.
+func waitlistErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidSeatSegment), errors.Is(err, models.ErrUnknownStop):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrTripNotFound), errors.Is(err, repositories.ErrWaitlistEntryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrTripDeparted), errors.Is(err, services.ErrWaitlistSeatsAvailable), errors.Is(err, repositories.ErrAlreadyWaitlisted):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"shared/outbox"
 	"ticket-service/api/controllers"
 	"ticket-service/pkg/websocket"
 
@@ -12,39 +13,86 @@ func SetupRoutes(r *gin.Engine,
 	ticketController *controllers.TicketController,
 	tokenTestController *controllers.TokenTestController,
 	checkinController *controllers.CheckinController,
+	seatLayoutController *controllers.SeatLayoutController,
+	boardingManifestController *controllers.BoardingManifestController,
+	waitlistController *controllers.WaitlistController,
+	groupBookingController *controllers.GroupBookingController,
+	outboxAdminHandler *outbox.AdminHandler,
+	idempotent gin.HandlerFunc, // Idempotency-Key cho các endpoint tạo vé
 	wsManager *websocket.Manager,
 ) {
 	ticketGroup := r.Group("/api/v1")
 	{
-		ticketGroup.POST("/initiate-booking", ticketController.InitiateBookingHandler)
+		ticketGroup.POST("/initiate-booking", idempotent, ticketController.InitiateBookingHandler)
 
 		// Handler cho route WebSocket được định nghĩa ngay tại đây
 		// để có thể truyền wsManager đã được khởi tạo từ main vào
 		ticketGroup.GET("/ws/track/:bookingId", wsManager.HandleConnection)
+		// Poll trạng thái booking khi không dùng được WebSocket
+		ticketGroup.GET("/bookings/:bookingId", ticketController.GetBookingStatusHandler)
+		// ACK kết quả booking qua HTTP, replica nào nhận cũng được (trạng thái ACK nằm trong Redis)
+		ticketGroup.POST("/bookings/:bookingId/ack", wsManager.HandleAck)
 
 		//User get our ticket
 		ticketGroup.GET("/tickets/:id", ticketController.GetTicketHandler)
+		ticketGroup.POST("/tickets/:id/cancel", ticketController.CancelTicketHandler)
+		ticketGroup.POST("/tickets/:id/change-seats", ticketController.ChangeSeatsHandler)
+		ticketGroup.GET("/tickets/:id/history", ticketController.GetTicketHistoryHandler)
 		ticketGroup.GET("/tickets", ticketController.GetAllTicketHandler)
-		ticketGroup.POST("/tickets", ticketController.CreateTicketHandler)
+		ticketGroup.POST("/tickets", idempotent, ticketController.CreateTicketHandler)
+
+		// Server-side fare quote (giá vé do server tính)
+		ticketGroup.POST("/fares/quote", ticketController.QuoteFareHandler)
 
 		// New Public Route to get a ticket by ID
 		ticketGroup.GET("/public/ticket/:id", ticketController.GetPublicTicketInfoByIDHandler)
 
 		//User get our ticket
 		ticketGroup.GET("/tickets/all", ticketController.GetAllTicketsPaginatedHandler) // New route for all tickets (paginated)
+		ticketGroup.GET("/tickets/search", ticketController.SearchTicketsHandler)       // Tìm vé cho nhân viên quầy (lọc, sắp xếp, cursor)
 
 		ticketGroup.POST("/ticket-by-phone", ticketController.GetInfoTicketHandler)
 
 		// Staff create ticket (cash payment)
-		ticketGroup.POST("/staff/tickets", ticketController.CreateTicketByStaffHandler) // Requires staff authentication
+		ticketGroup.POST("/staff/tickets", idempotent, ticketController.CreateTicketByStaffHandler) // Requires staff authentication
 
 		//Get ticket have order
 		ticketGroup.GET("/tickets-available/:id", ticketController.GetAvailableHandler)
 		ticketGroup.POST("/trips-available-seats", ticketController.GetAvailableMultiTripsHandler)
 
+		// Sơ đồ ghế theo loại xe và theo chuyến
+		ticketGroup.GET("/seat-maps/:tripId", seatLayoutController.GetTripSeatMapHandler)
+		seatLayoutGroup := ticketGroup.Group("/seat-layouts")
+		{
+			seatLayoutGroup.GET("", seatLayoutController.ListSeatLayoutsHandler)
+			seatLayoutGroup.GET("/:id", seatLayoutController.GetSeatLayoutHandler)
+			seatLayoutGroup.GET("/vehicle-types/:typeId", seatLayoutController.GetVehicleTypeLayoutHandler)
+			seatLayoutGroup.PUT("", seatLayoutController.UpsertSeatLayoutHandler)        // Admin/operator
+			seatLayoutGroup.DELETE("/:id", seatLayoutController.DeleteSeatLayoutHandler) // Admin/operator
+		}
+
+		// Danh sách chờ khi chuyến hết ghế (khách đã đăng nhập)
+		waitlistGroup := ticketGroup.Group("/waitlist")
+		{
+			waitlistGroup.POST("", waitlistController.JoinWaitlistHandler)
+			waitlistGroup.GET("", waitlistController.ListWaitlistHandler)
+			waitlistGroup.DELETE("/:id", waitlistController.LeaveWaitlistHandler)
+		}
+
+		// Đặt vé đoàn: một hoá đơn chung theo mã đoàn, huỷ riêng từng hành khách
+		groupBookingGroup := ticketGroup.Group("/group-bookings")
+		{
+			groupBookingGroup.POST("", idempotent, groupBookingController.CreateGroupBookingHandler)
+			groupBookingGroup.GET("/:groupRef", groupBookingController.GetGroupBookingHandler)
+			groupBookingGroup.POST("/:groupRef/passengers/:seatTicketId/cancel", groupBookingController.CancelGroupPassengerHandler)
+		}
+
 		//Payment
 		ticketGroup.POST("/payments", managerTicketController.UpdateManagerTicketHandler)
 
+		// Manifest lên xe cho điều độ viên (JSON/CSV/PDF)
+		ticketGroup.GET("/boarding-manifests/:tripId", boardingManifestController.GetBoardingManifestHandler)
+
 		// Nhóm route mới cho check-in
 		checkinGroup := ticketGroup.Group("/checkin")
 		{
@@ -55,8 +103,15 @@ func SetupRoutes(r *gin.Engine,
 			// GET /api/v1/checkin/trip/{tripID}
 			// Route này lấy tất cả các lượt check-in của một chuyến đi
 			checkinGroup.GET("/trip/:tripID", checkinController.GetTripCheckinsHandler)
+
+			// Soát vé offline: tải manifest đã ký của chuyến và đồng bộ lô lượt quét khi có sóng
+			checkinGroup.GET("/trip/:tripID/manifest", checkinController.GetTripManifestHandler)
+			checkinGroup.POST("/offline-sync", checkinController.SyncOfflineCheckinsHandler)
 		}
 
+		// Quản trị outbox: liệt kê, replay hoặc huỷ các event publish thất bại (ROLE_ADMIN)
+		outboxAdminHandler.RegisterRoutes(ticketGroup.Group("/admin/ticket-outbox"))
+
 		// New token test route
 		ticketGroup.GET("/token-test", tokenTestController.ValidateTokenHandler)
 	}
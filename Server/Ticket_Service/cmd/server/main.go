@@ -8,18 +8,22 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"shared/idempotency"
+	"shared/outbox"
 	"syscall"
 	"ticket-service/api/controllers"
 	"ticket-service/api/routes"
 	"ticket-service/config"
 	"ticket-service/internal/consumers"
-	"ticket-service/internal/db"
 	"ticket-service/internal/repositories"
 	"ticket-service/internal/services"
 	"ticket-service/internal/workers"
 	"ticket-service/pkg/emailclient"
 	"ticket-service/pkg/kafkaclient"
+	"ticket-service/pkg/qrsign"
+	"ticket-service/pkg/tripclient"
 	"ticket-service/pkg/utils"
+	"ticket-service/pkg/waitingroom"
 	"ticket-service/pkg/websocket"
 	"time"
 
@@ -65,22 +69,54 @@ func main() {
 
 	auth := utils.NewAuth(cfg.JWT.SecretKey)
 	util := utils.NewUtils()
-	query := db.New(sqlDB)
+	outboxStore := outbox.NewStore(sqlDB)
 
 	emailClient := emailclient.NewEmailClient(kafkaPublisher, logger, cfg)
-	ticketRepo := repositories.NewTicketRepository(sqlDB, redisClient, util, logger)
+	tripConfig := tripclient.DefaultConfig()
+	tripConfig.BaseURL = cfg.TripService.URL
+	tripConfig.Timeout = cfg.TripService.Timeout
+	tripConfig.CacheTTL = cfg.TripService.CacheTTL
+	tripConfig.LocalCacheSize = cfg.TripService.LocalCacheSize
+	tripConfig.LocalCacheTTL = cfg.TripService.LocalCacheTTL
+	tripConfig.BreakerThreshold = cfg.TripService.BreakerThreshold
+	tripConfig.BreakerCooldown = cfg.TripService.BreakerCooldown
+	tripClient := tripclient.New(redisClient, tripConfig, logger)
+	fetchTrip := tripClient.GetTrip
+	ticketRepo := repositories.NewTicketRepository(sqlDB, redisClient, util, logger, tripClient)
 	manaRepo := repositories.NewManagerTicket(sqlDB, redisClient, ticketRepo, logger)
 	checkRepo := repositories.NewCheckinRepository(sqlDB, logger)
+	policyRepo := repositories.NewPolicyRepository(sqlDB, logger)
+	seatLayoutRepo := repositories.NewSeatLayoutRepository(sqlDB, logger)
+	waitlistRepo := repositories.NewWaitlistRepository(sqlDB, logger)
+
+	qrKeys, err := qrsign.ParseKeys(cfg.QR.SigningKeys)
+	if err != nil {
+		logger.Error("Invalid QR signing keys: %v", err)
+		os.Exit(1)
+	}
+	qrSigner, err := qrsign.NewSigner(cfg.QR.ActiveKeyID, qrKeys)
+	if err != nil {
+		logger.Error("Failed to create QR signer: %v", err)
+		os.Exit(1)
+	}
 
 	var ticketService services.ITicketService // Khai báo trước để giải quyết phụ thuộc vòng
-	manaService := services.NewManagerTicketService(manaRepo, ticketRepo, logger, cfg, kafkaPublisher, emailClient)
-	ticketService = services.NewTicketService(ticketRepo, util, logger, cfg, kafkaPublisher, redisClient)
-	checkService := services.NewCheckinService(checkRepo, logger)
+	qrService := services.NewTicketQRService(qrSigner, redisClient, fetchTrip, cfg, logger)
+	seatLayoutService := services.NewSeatLayoutService(seatLayoutRepo, logger)
+	manaService := services.NewManagerTicketService(manaRepo, ticketRepo, seatLayoutService, qrService, logger, cfg, kafkaPublisher, emailClient, tripClient)
+	fareService := services.NewFareService(policyRepo, fetchTrip, logger)
+	waitingRoomConfig := waitingroom.DefaultConfig()
+	waitingRoomConfig.AdmitRate = cfg.WaitingRoom.AdmitRate
+	waitingRoom := waitingroom.New(redisClient, waitingRoomConfig)
+	ticketService = services.NewTicketService(ticketRepo, util, logger, cfg, kafkaPublisher, redisClient, fareService, qrService, waitingRoom, tripClient)
+	checkService := services.NewCheckinService(checkRepo, qrService, logger)
+	boardingManifestService := services.NewBoardingManifestService(checkRepo, fetchTrip, cfg.Manifest.NoShowGrace, logger)
+	waitlistService := services.NewWaitlistService(waitlistRepo, ticketRepo, fetchTrip, cfg, logger)
 
 	// 4. Khởi tạo và chạy các Worker/Consumer trong Goroutine
 	consumerCtx, consumerCancel := context.WithCancel(context.Background())
 
-	// Khởi tạo WebSocket Manager với callback
+	// Khởi tạo WebSocket Manager với callback. Callback chạy trên đúng một replica (ACK sweeper dùng Redis)
 	onAckTimeout := func(bookingID string, ticketID string) {
 		logger.Info("[Main] ACK Timeout triggered for booking %s, ticket %s. Initiating cancellation.", bookingID, ticketID)
 		go func() {
@@ -91,15 +127,31 @@ func main() {
 			}
 		}()
 	}
-	wsManager := websocket.NewManager(redisClient, onAckTimeout)
+	wsConfig := websocket.DefaultConfig()
+	wsConfig.AckTimeout = cfg.WebSocket.AckTimeout
+	wsConfig.HandoverGrace = cfg.WebSocket.HandoverGrace
+	wsManager := websocket.NewManager(redisClient, ticketService, onAckTimeout, wsConfig)
+	go wsManager.StartAckSweeper(consumerCtx)
 
 	// Chạy các worker
-	outboxWorker := workers.NewOutboxPoller(query, kafkaPublisher, logger)
+	outboxConfig := outbox.DefaultConfig()
+	outboxConfig.MaxAttempts = cfg.Outbox.MaxAttempts
+	outboxConfig.MaxBackoff = cfg.Outbox.MaxBackoff
+	outboxWorker := outbox.NewPoller(outboxStore, kafkaPublisher, logger, outboxConfig)
 	go outboxWorker.Start(consumerCtx)
 
-	timeoutWorker := workers.NewTimeoutWorker(redisClient, ticketService, logger)
+	timeoutWorker := workers.NewTimeoutWorker(ticketService, logger)
 	go timeoutWorker.Start(consumerCtx)
 
+	waitingRoomWorker := workers.NewWaitingRoomWorker(ticketService, cfg.WaitingRoom.AdmitInterval, logger)
+	go waitingRoomWorker.Start(consumerCtx)
+
+	noShowWorker := workers.NewNoShowWorker(boardingManifestService, cfg.Manifest.NoShowInterval, logger)
+	go noShowWorker.Start(consumerCtx)
+
+	waitlistWorker := workers.NewWaitlistWorker(waitlistService, cfg.Waitlist.SweepInterval, logger)
+	go waitlistWorker.Start(consumerCtx)
+
 	// Chạy các consumer với context đã tạo
 	tripConsumer := consumers.NewTripConsumer(cfg, manaService, logger)
 	go tripConsumer.Start(consumerCtx)
@@ -110,6 +162,12 @@ func main() {
 	bookingRequestConsumer := consumers.NewBookingRequestConsumer(cfg, ticketService, redisClient, logger)
 	go bookingRequestConsumer.Start(consumerCtx)
 
+	seatsReleasedConsumer := consumers.NewSeatsReleasedConsumer(cfg, waitlistService, logger)
+	go seatsReleasedConsumer.Start(consumerCtx)
+
+	tripLifecycleConsumer := consumers.NewTripLifecycleConsumer(cfg, ticketService, logger)
+	go tripLifecycleConsumer.Start(consumerCtx)
+
 	if err := ticketRepo.SubscribeToSeatStatusChanges(consumerCtx); err != nil {
 		logger.Error("Failed to subscribe to seat status changes: %v", err)
 	}
@@ -123,7 +181,13 @@ func main() {
 	ticketController := controllers.NewTicketController(ticketService)
 	checkController := controllers.NewCheckinController(checkService, logger)
 	testController := controllers.NewTokenTestController(auth)
-	routes.SetupRoutes(router, manaController, ticketController, testController, checkController, wsManager)
+	seatLayoutController := controllers.NewSeatLayoutController(seatLayoutService, ticketService.ResolveSeatSegment)
+	boardingManifestController := controllers.NewBoardingManifestController(boardingManifestService, cfg.Manifest.PDFFontPath, logger)
+	waitlistController := controllers.NewWaitlistController(waitlistService)
+	groupBookingController := controllers.NewGroupBookingController(ticketService)
+	outboxAdminHandler := outbox.NewAdminHandler(outboxStore)
+	idempotencyStore := idempotency.NewStore(redisClient, idempotency.DefaultConfig("idempotency:ticket"))
+	routes.SetupRoutes(router, manaController, ticketController, testController, checkController, seatLayoutController, boardingManifestController, waitlistController, groupBookingController, outboxAdminHandler, idempotencyStore.Middleware(), wsManager)
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%s", cfg.Server.Port),
@@ -149,6 +213,12 @@ func main() {
 
 	logger.Info("Shutting down server and background workers...")
 
+	// Chuyển các kết nối WebSocket sang replica khác trước khi dừng worker (ACK sweeper cần Redis)
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), cfg.WebSocket.DrainTimeout)
+	drained := wsManager.Drain(drainCtx)
+	drainCancel()
+	logger.Info("Handed over %d WebSocket connection(s) to other replicas", drained)
+
 	// Gửi tín hiệu dừng đến tất cả các goroutine đang lắng nghe consumerCtx
 	consumerCancel()
 
@@ -19,12 +19,17 @@ type TopicConfig struct {
 // KafkaTopics định nghĩa tất cả các topic mà service tương tác.
 type KafkaTopics struct {
 	TripCreated         TopicConfig
+	TripUpdated         TopicConfig
+	TripCancelled       TopicConfig // GroupID dùng chung cho cả trip_updated và trip_cancelled (TripLifecycleConsumer)
 	TicketStatusUpdates TopicConfig
 	SeatsReserved       TopicConfig
 	SeatsReleased       TopicConfig
 	EmailRequests       TopicConfig
 	OrderQRRequests     TopicConfig
 	BookingRequests     TopicConfig
+	RefundRequests      TopicConfig
+	FareAdjustments     TopicConfig
+	Notifications       TopicConfig
 }
 
 // THAY ĐỔI: Cấu trúc KafkaConfig được thiết kế lại cho franz-go
@@ -62,6 +67,57 @@ type Config struct {
 		QRService string `mapstructure:"QR_SERVICE_URL"`
 	}
 	Kafka KafkaConfig
+	// Fare engine: giá vé luôn được tính lại phía server
+	Fare struct {
+		RejectPriceMismatch bool    // true: từ chối request khi giá client khác giá server, false: ghi đè
+		PriceTolerance      float64 // Sai lệch cho phép (VND) trước khi coi là không khớp
+	}
+	// QR check-in được ký HMAC; xoay khoá bằng cách thêm khoá mới vào SigningKeys và đổi ActiveKeyID,
+	// giữ khoá cũ cho tới khi QR ký bằng nó hết hạn.
+	QR struct {
+		SigningKeys     string        // "k1:secret1,k2:secret2"
+		ActiveKeyID     string        // Khoá dùng để ký QR mới
+		ExpiryAfterTrip time.Duration // QR hết hạn sau giờ khởi hành của chuyến bao lâu
+		DefaultTTL      time.Duration // Hạn QR khi không lấy được giờ khởi hành
+		AcceptUnsigned  bool          // Tạm chấp nhận QR cũ dạng TICKET:{id}-SEAT:{id} trong thời gian chuyển đổi
+	}
+	// Manifest lên xe và đánh dấu khách không đi (no-show)
+	Manifest struct {
+		PDFFontPath    string        // Font TTF hỗ trợ tiếng Việt cho file PDF, để trống thì dùng font mặc định và bỏ dấu
+		NoShowGrace    time.Duration // Sau giờ khởi hành bao lâu thì ghế lên xe ở điểm đầu chưa check-in bị đánh dấu no-show
+		NoShowInterval time.Duration // Chu kỳ quét các chuyến đã khởi hành
+	}
+	// Danh sách chờ khi chuyến hết ghế
+	Waitlist struct {
+		OfferTTL      time.Duration // Thời gian giữ ghế cho khách được mời trước khi chuyển cho người kế tiếp
+		SweepInterval time.Duration // Chu kỳ thu hồi các lượt giữ ghế đã hết hạn
+	}
+	// Phòng chờ ảo theo chuyến cho các chuyến cao điểm (pkg/waitingroom)
+	WaitingRoom struct {
+		AdmitRate     int           // Số yêu cầu đặt vé mỗi chuyến được đưa vào xử lý mỗi giây
+		AdmitInterval time.Duration // Chu kỳ WaitingRoomWorker đưa yêu cầu tới lượt vào Kafka
+	}
+	// Phiên WebSocket theo dõi booking (pkg/websocket), trạng thái nằm trong Redis để chạy nhiều replica
+	WebSocket struct {
+		AckTimeout    time.Duration // Thời gian client có để ACK kết quả trước khi vé bị huỷ
+		HandoverGrace time.Duration // Hạn ACK được lùi thêm khi replica tắt, để client kết nối lại replica khác
+		DrainTimeout  time.Duration // Thời gian tối đa chờ chuyển hết kết nối khi tắt
+	}
+	// Client gọi trip-service (pkg/tripclient)
+	TripService struct {
+		URL              string
+		Timeout          time.Duration // Timeout mỗi request
+		CacheTTL         time.Duration // Thời gian cache thông tin chuyến trong Redis
+		LocalCacheSize   int           // Số chuyến tối đa trong LRU của mỗi instance
+		LocalCacheTTL    time.Duration // Thời gian cache trong LRU
+		BreakerThreshold int           // Số lỗi liên tiếp trước khi ngừng gọi trip-service
+		BreakerCooldown  time.Duration // Thời gian ngừng gọi trước khi thử lại
+	}
+	// Relay outbox -> Kafka (shared/outbox)
+	Outbox struct {
+		MaxAttempts int           // Số lần publish thất bại trước khi event chuyển sang outbox_dead_letters
+		MaxBackoff  time.Duration // Khoảng chờ tối đa giữa hai lần retry
+	}
 }
 
 func LoadConfig() (Config, error) {
@@ -90,6 +146,55 @@ func LoadConfig() (Config, error) {
 	cfg.JWT.SecretKey = GetEnv("JWT_TOKEN", "your-very-secret-key-for-jwt")
 	cfg.URL.QRService = GetEnv("QR_SERVICE_URL", "http://localhost:8090/api/v1/qr/generate")
 
+	cfg.Fare.RejectPriceMismatch, _ = strconv.ParseBool(GetEnv("FARE_REJECT_PRICE_MISMATCH", "false"))
+	cfg.Fare.PriceTolerance, _ = strconv.ParseFloat(GetEnv("FARE_PRICE_TOLERANCE", "0.5"), 64)
+
+	cfg.QR.SigningKeys = GetEnv("QR_SIGNING_KEYS", "k1:your-very-secret-key-for-qr-signing")
+	cfg.QR.ActiveKeyID = GetEnv("QR_ACTIVE_KEY_ID", "k1")
+	qrExpiryHours, _ := strconv.Atoi(GetEnv("QR_EXPIRY_AFTER_DEPARTURE_HOURS", "12"))
+	qrDefaultTTLHours, _ := strconv.Atoi(GetEnv("QR_DEFAULT_TTL_HOURS", "720"))
+	cfg.QR.ExpiryAfterTrip = time.Duration(qrExpiryHours) * time.Hour
+	cfg.QR.DefaultTTL = time.Duration(qrDefaultTTLHours) * time.Hour
+	cfg.QR.AcceptUnsigned, _ = strconv.ParseBool(GetEnv("QR_ACCEPT_UNSIGNED", "false"))
+
+	cfg.Manifest.PDFFontPath = GetEnv("MANIFEST_PDF_FONT_PATH", "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf")
+	noShowGraceMinutes, _ := strconv.Atoi(GetEnv("NO_SHOW_GRACE_MINUTES", "30"))
+	noShowIntervalMinutes, _ := strconv.Atoi(GetEnv("NO_SHOW_SCAN_INTERVAL_MINUTES", "5"))
+	cfg.Manifest.NoShowGrace = time.Duration(noShowGraceMinutes) * time.Minute
+	cfg.Manifest.NoShowInterval = time.Duration(noShowIntervalMinutes) * time.Minute
+
+	waitlistOfferTTLMinutes, _ := strconv.Atoi(GetEnv("WAITLIST_OFFER_TTL_MINUTES", "15"))
+	waitlistSweepSeconds, _ := strconv.Atoi(GetEnv("WAITLIST_SWEEP_INTERVAL_SECONDS", "60"))
+	cfg.Waitlist.OfferTTL = time.Duration(waitlistOfferTTLMinutes) * time.Minute
+	cfg.Waitlist.SweepInterval = time.Duration(waitlistSweepSeconds) * time.Second
+
+	cfg.WaitingRoom.AdmitRate, _ = strconv.Atoi(GetEnv("WAITING_ROOM_ADMIT_RATE", "20"))
+	waitingRoomAdmitIntervalMs, _ := strconv.Atoi(GetEnv("WAITING_ROOM_ADMIT_INTERVAL_MS", "200"))
+	cfg.WaitingRoom.AdmitInterval = time.Duration(waitingRoomAdmitIntervalMs) * time.Millisecond
+
+	wsAckTimeoutSeconds, _ := strconv.Atoi(GetEnv("WS_ACK_TIMEOUT_SECONDS", "15"))
+	wsHandoverGraceSeconds, _ := strconv.Atoi(GetEnv("WS_HANDOVER_GRACE_SECONDS", "30"))
+	wsDrainTimeoutSeconds, _ := strconv.Atoi(GetEnv("WS_DRAIN_TIMEOUT_SECONDS", "10"))
+	cfg.WebSocket.AckTimeout = time.Duration(wsAckTimeoutSeconds) * time.Second
+	cfg.WebSocket.HandoverGrace = time.Duration(wsHandoverGraceSeconds) * time.Second
+	cfg.WebSocket.DrainTimeout = time.Duration(wsDrainTimeoutSeconds) * time.Second
+
+	cfg.TripService.URL = GetEnv("TRIP_SERVICE_URL", "http://localhost:8082")
+	tripTimeoutMs, _ := strconv.Atoi(GetEnv("TRIP_SERVICE_TIMEOUT_MS", "3000"))
+	tripCacheTTLSeconds, _ := strconv.Atoi(GetEnv("TRIP_CACHE_TTL_SECONDS", "120"))
+	tripLocalCacheTTLSeconds, _ := strconv.Atoi(GetEnv("TRIP_LOCAL_CACHE_TTL_SECONDS", "30"))
+	tripBreakerCooldownSeconds, _ := strconv.Atoi(GetEnv("TRIP_BREAKER_COOLDOWN_SECONDS", "30"))
+	cfg.TripService.Timeout = time.Duration(tripTimeoutMs) * time.Millisecond
+	cfg.TripService.CacheTTL = time.Duration(tripCacheTTLSeconds) * time.Second
+	cfg.TripService.LocalCacheSize, _ = strconv.Atoi(GetEnv("TRIP_LOCAL_CACHE_SIZE", "1000"))
+	cfg.TripService.LocalCacheTTL = time.Duration(tripLocalCacheTTLSeconds) * time.Second
+	cfg.TripService.BreakerThreshold, _ = strconv.Atoi(GetEnv("TRIP_BREAKER_THRESHOLD", "5"))
+	cfg.TripService.BreakerCooldown = time.Duration(tripBreakerCooldownSeconds) * time.Second
+
+	cfg.Outbox.MaxAttempts, _ = strconv.Atoi(GetEnv("OUTBOX_MAX_ATTEMPTS", "10"))
+	outboxMaxBackoffSeconds, _ := strconv.Atoi(GetEnv("OUTBOX_MAX_BACKOFF_SECONDS", "300"))
+	cfg.Outbox.MaxBackoff = time.Duration(outboxMaxBackoffSeconds) * time.Second
+
 	// THAY ĐỔI: Load cấu hình Kafka mới
 	kafkaEnableTLS, _ := strconv.ParseBool(GetEnv("KAFKA_ENABLE_TLS", "false"))
 	cfg.Kafka.Seeds = strings.Split(GetEnv("KAFKA_SEEDS", "localhost:9092"), ",")
@@ -101,6 +206,10 @@ func LoadConfig() (Config, error) {
 	cfg.Kafka.Topics.TripCreated.Topic = GetEnv("KAFKA_TOPIC_TRIP_CREATED", "trip_created")
 	cfg.Kafka.Topics.TripCreated.GroupID = GetEnv("KAFKA_GROUP_ID_TRIP_CREATED", "ticket_service_trip_group")
 
+	cfg.Kafka.Topics.TripUpdated.Topic = GetEnv("KAFKA_TOPIC_TRIP_UPDATED", "trip_updated")
+	cfg.Kafka.Topics.TripCancelled.Topic = GetEnv("KAFKA_TOPIC_TRIP_CANCELLED", "trip_cancelled")
+	cfg.Kafka.Topics.TripCancelled.GroupID = GetEnv("KAFKA_GROUP_ID_TRIP_LIFECYCLE", "ticket_service_trip_lifecycle_group")
+
 	cfg.Kafka.Topics.TicketStatusUpdates.Topic = GetEnv("KAFKA_TOPIC_TICKET_STATUS", "ticket_status_updates")
 	cfg.Kafka.Topics.TicketStatusUpdates.GroupID = GetEnv("KAFKA_GROUP_ID_TICKET_STATUS", "ticket_service_status_group")
 
@@ -109,8 +218,12 @@ func LoadConfig() (Config, error) {
 
 	cfg.Kafka.Topics.SeatsReserved.Topic = GetEnv("KAFKA_TOPIC_SEATS_RESERVED", "seats_reserved")
 	cfg.Kafka.Topics.SeatsReleased.Topic = GetEnv("KAFKA_TOPIC_SEATS_RELEASED", "seats_released")
+	cfg.Kafka.Topics.SeatsReleased.GroupID = GetEnv("KAFKA_GROUP_ID_SEATS_RELEASED", "ticket_service_waitlist_group")
 	cfg.Kafka.Topics.EmailRequests.Topic = GetEnv("KAFKA_TOPIC_EMAIL_REQUESTS", "email_requests")
 	cfg.Kafka.Topics.OrderQRRequests.Topic = GetEnv("KAFKA_TOPIC_QR_REQUESTS", "order_qr_requests")
+	cfg.Kafka.Topics.RefundRequests.Topic = GetEnv("KAFKA_TOPIC_REFUND_REQUESTS", "refund_requests")
+	cfg.Kafka.Topics.FareAdjustments.Topic = GetEnv("KAFKA_TOPIC_FARE_ADJUSTMENTS", "fare_adjustments")
+	cfg.Kafka.Topics.Notifications.Topic = GetEnv("KAFKA_TOPIC_NOTIFICATIONS", "notifications_topic")
 
 	return cfg, nil
 }
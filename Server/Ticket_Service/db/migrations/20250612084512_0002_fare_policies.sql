@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE policies (
+    policy_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    discount_percent DECIMAL(5,2) NOT NULL DEFAULT 0,            -- Giảm giá áp dụng trên tổng giá vé
+    round_trip_discount_percent DECIMAL(5,2) NOT NULL DEFAULT 0, -- Giảm thêm cho vé khứ hồi
+    status SMALLINT NOT NULL DEFAULT 1,                          -- 0: inactive, 1: active
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Breakdown of the server-computed fare (legs, special day surcharge, discounts).
+ALTER TABLE Ticket ADD COLUMN Fare_Breakdown JSONB NOT NULL DEFAULT '{}';
+
+CREATE TRIGGER set_policies_timestamp BEFORE UPDATE ON policies FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TRIGGER IF EXISTS set_policies_timestamp ON policies;
+ALTER TABLE Ticket DROP COLUMN IF EXISTS Fare_Breakdown;
+DROP TABLE IF EXISTS policies;
+
+-- +goose StatementEnd
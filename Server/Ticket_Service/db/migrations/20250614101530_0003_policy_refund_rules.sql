@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Quy tắc hoàn tiền theo policy: áp dụng rule có min_hours_before_departure lớn nhất
+-- mà thời gian còn lại trước giờ khởi hành vẫn đạt được.
+CREATE TABLE policy_refund_rules (
+    id SERIAL PRIMARY KEY,
+    policy_id INT NOT NULL,
+    min_hours_before_departure INT NOT NULL,
+    refund_percent DECIMAL(5,2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(policy_id, min_hours_before_departure),
+    FOREIGN KEY (policy_id) REFERENCES policies(policy_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_policy_refund_rules_policy_id ON policy_refund_rules(policy_id);
+
+-- Policy 0 là policy mặc định (không giảm giá) được dùng bởi các vé không chọn policy.
+INSERT INTO policies (policy_id, name) VALUES (0, 'Default') ON CONFLICT (policy_id) DO NOTHING;
+INSERT INTO policy_refund_rules (policy_id, min_hours_before_departure, refund_percent) VALUES
+    (0, 48, 90),
+    (0, 24, 70),
+    (0, 4, 50),
+    (0, 0, 0)
+ON CONFLICT (policy_id, min_hours_before_departure) DO NOTHING;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_policy_refund_rules_policy_id;
+DROP TABLE IF EXISTS policy_refund_rules;
+DELETE FROM policies WHERE policy_id = 0;
+
+-- +goose StatementEnd
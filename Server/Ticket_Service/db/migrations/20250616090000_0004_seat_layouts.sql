@@ -0,0 +1,111 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Mẫu sơ đồ ghế theo loại xe (vehicle type của trip-service).
+-- vehicle_type_id = 0 là mẫu mặc định, dùng khi loại xe chưa có mẫu riêng.
+CREATE TABLE seat_layouts (
+    id SERIAL PRIMARY KEY,
+    vehicle_type_id INT NOT NULL,
+    seat_count INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    decks SMALLINT NOT NULL DEFAULT 1,
+    row_count SMALLINT NOT NULL,
+    column_count SMALLINT NOT NULL,
+    aisle_after_columns INT[] NOT NULL DEFAULT '{}', -- Lối đi nằm sau các cột này (để client vẽ khoảng trống)
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(vehicle_type_id, seat_count)
+);
+
+-- Vị trí từng ghế trong mẫu. Ô (deck, row, col) không có ghế là khoảng trống.
+CREATE TABLE seat_layout_seats (
+    id SERIAL PRIMARY KEY,
+    layout_id INT NOT NULL,
+    seat_name VARCHAR(10) NOT NULL,
+    deck SMALLINT NOT NULL DEFAULT 1,
+    row_no SMALLINT NOT NULL,
+    col_no SMALLINT NOT NULL,
+    seat_class VARCHAR(20) NOT NULL DEFAULT 'STANDARD', -- STANDARD, VIP, SLEEPER
+    UNIQUE(layout_id, seat_name),
+    UNIQUE(layout_id, deck, row_no, col_no),
+    FOREIGN KEY (layout_id) REFERENCES seat_layouts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_seat_layout_seats_layout_id ON seat_layout_seats(layout_id);
+
+CREATE TRIGGER set_seat_layouts_timestamp BEFORE UPDATE ON seat_layouts FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+-- Ghế của chuyến lưu lại vị trí lấy từ mẫu; ghế cũ (trước khi có mẫu) để NULL.
+ALTER TABLE seats
+    ADD COLUMN layout_id INT REFERENCES seat_layouts(id) ON DELETE SET NULL,
+    ADD COLUMN deck SMALLINT,
+    ADD COLUMN row_no SMALLINT,
+    ADD COLUMN col_no SMALLINT,
+    ADD COLUMN seat_class VARCHAR(20);
+
+-- Mẫu mặc định: giữ nguyên 30 ghế A1-A15 (tầng dưới), B1-B15 (tầng trên) như trước.
+INSERT INTO seat_layouts (vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns)
+VALUES (0, 30, 'Mặc định 30 chỗ', 2, 5, 3, '{1,2}');
+INSERT INTO seat_layout_seats (layout_id, seat_name, deck, row_no, col_no)
+SELECT l.id, d.prefix || n, d.deck, (n - 1) / 3 + 1, (n - 1) % 3 + 1
+FROM seat_layouts l
+CROSS JOIN (VALUES (1, 'A'), (2, 'B')) AS d(deck, prefix)
+CROSS JOIN generate_series(1, 15) AS n
+WHERE l.vehicle_type_id = 0 AND l.seat_count = 30;
+
+-- Ghế ngồi 16 chỗ: 4 hàng x 4 ghế, lối đi giữa.
+INSERT INTO seat_layouts (vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns)
+VALUES (1, 16, 'Ghế ngồi 16 chỗ', 1, 4, 4, '{2}');
+INSERT INTO seat_layout_seats (layout_id, seat_name, deck, row_no, col_no)
+SELECT l.id, 'A' || n, 1, (n - 1) / 4 + 1, (n - 1) % 4 + 1
+FROM seat_layouts l
+CROSS JOIN generate_series(1, 16) AS n
+WHERE l.vehicle_type_id = 1 AND l.seat_count = 16;
+
+-- Ghế ngồi 45 chỗ: 10 hàng x 4 ghế (cột 3 là lối đi) và hàng cuối 5 ghế.
+INSERT INTO seat_layouts (vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns)
+VALUES (1, 45, 'Ghế ngồi 45 chỗ', 1, 11, 5, '{}');
+INSERT INTO seat_layout_seats (layout_id, seat_name, deck, row_no, col_no)
+SELECT l.id, 'A' || n, 1,
+       CASE WHEN n <= 40 THEN (n - 1) / 4 + 1 ELSE 11 END,
+       CASE WHEN n > 40 THEN n - 40 WHEN (n - 1) % 4 < 2 THEN (n - 1) % 4 + 1 ELSE (n - 1) % 4 + 2 END
+FROM seat_layouts l
+CROSS JOIN generate_series(1, 45) AS n
+WHERE l.vehicle_type_id = 1 AND l.seat_count = 45;
+
+-- Giường nằm 30 chỗ: 2 tầng x 5 hàng x 3 giường, 2 lối đi.
+INSERT INTO seat_layouts (vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns)
+VALUES (2, 30, 'Giường nằm 30 chỗ', 2, 5, 3, '{1,2}');
+INSERT INTO seat_layout_seats (layout_id, seat_name, deck, row_no, col_no, seat_class)
+SELECT l.id, d.prefix || n, d.deck, (n - 1) / 3 + 1, (n - 1) % 3 + 1, 'SLEEPER'
+FROM seat_layouts l
+CROSS JOIN (VALUES (1, 'A'), (2, 'B')) AS d(deck, prefix)
+CROSS JOIN generate_series(1, 15) AS n
+WHERE l.vehicle_type_id = 2 AND l.seat_count = 30;
+
+-- Limousine 9 chỗ: 3 hàng x 3 ghế VIP, lối đi sau cột 1.
+INSERT INTO seat_layouts (vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns)
+VALUES (3, 9, 'Limousine 9 chỗ', 1, 3, 3, '{1}');
+INSERT INTO seat_layout_seats (layout_id, seat_name, deck, row_no, col_no, seat_class)
+SELECT l.id, 'A' || n, 1, (n - 1) / 3 + 1, (n - 1) % 3 + 1, 'VIP'
+FROM seat_layouts l
+CROSS JOIN generate_series(1, 9) AS n
+WHERE l.vehicle_type_id = 3 AND l.seat_count = 9;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE seats
+    DROP COLUMN IF EXISTS seat_class,
+    DROP COLUMN IF EXISTS col_no,
+    DROP COLUMN IF EXISTS row_no,
+    DROP COLUMN IF EXISTS deck,
+    DROP COLUMN IF EXISTS layout_id;
+DROP INDEX IF EXISTS idx_seat_layout_seats_layout_id;
+DROP TRIGGER IF EXISTS set_seat_layouts_timestamp ON seat_layouts;
+DROP TABLE IF EXISTS seat_layout_seats;
+DROP TABLE IF EXISTS seat_layouts;
+
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Ghế được giữ theo đoạn đường [from_stop, to_stop) thay vì cả chuyến.
+-- from_stop/to_stop là thứ tự điểm dừng dọc tuyến (1 = điểm đầu) do trip-service tính từ chuỗi pickup.self_id
+-- (trip.stops[].stopOrder), KHÔNG phải Pickup/Dropoff_Location của Ticket_Details (station id client gửi lên).
+-- Ticket-service quy đổi station id sang stopOrder theo điểm dừng của chuyến trước khi ghi.
+-- Vé cũ mặc định giữ ghế cả chuyến (0 -> 32767).
+ALTER TABLE seat_tickets
+    ADD COLUMN from_stop SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN to_stop SMALLINT NOT NULL DEFAULT 32767,
+    ADD CONSTRAINT seat_tickets_segment_check CHECK (from_stop < to_stop);
+
+CREATE INDEX idx_seat_tickets_seat_id_status ON seat_tickets(seat_id, status);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_seat_tickets_seat_id_status;
+ALTER TABLE seat_tickets
+    DROP CONSTRAINT IF EXISTS seat_tickets_segment_check,
+    DROP COLUMN IF EXISTS to_stop,
+    DROP COLUMN IF EXISTS from_stop;
+
+-- +goose StatementEnd
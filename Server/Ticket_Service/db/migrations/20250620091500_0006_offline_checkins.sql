@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Check-in offline: soát vé quét khi mất sóng rồi đồng bộ lên sau.
+-- checked_in_at giữ thời điểm quét gốc trên thiết bị, synced_at là lúc server nhận được.
+-- (device_id, client_scan_id) chống ghi trùng khi thiết bị gửi lại cùng một lượt quét.
+-- conflict ghi lý do khi lượt quét không thể áp dụng (vd: vé bị huỷ sau khi tải manifest), để nhân viên đối soát.
+ALTER TABLE checkins
+    ADD COLUMN source VARCHAR(16) NOT NULL DEFAULT 'online',
+    ADD COLUMN device_id VARCHAR(64),
+    ADD COLUMN client_scan_id VARCHAR(64),
+    ADD COLUMN synced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN conflict VARCHAR(32);
+
+CREATE UNIQUE INDEX idx_checkins_device_scan ON checkins(device_id, client_scan_id)
+    WHERE client_scan_id IS NOT NULL;
+CREATE INDEX idx_seat_tickets_trip_id_status ON seat_tickets(trip_id, status);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_seat_tickets_trip_id_status;
+DROP INDEX IF EXISTS idx_checkins_device_scan;
+ALTER TABLE checkins
+    DROP COLUMN IF EXISTS conflict,
+    DROP COLUMN IF EXISTS synced_at,
+    DROP COLUMN IF EXISTS client_scan_id,
+    DROP COLUMN IF EXISTS device_id,
+    DROP COLUMN IF EXISTS source;
+
+-- +goose StatementEnd
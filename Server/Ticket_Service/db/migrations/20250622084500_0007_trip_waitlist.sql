@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Danh sách chờ khi chuyến hết ghế. Thứ tự xếp hàng theo id (ai đăng ký trước được mời trước).
+-- status: 0 đang chờ, 1 đã được giữ ghế (chờ khách đặt), 2 đã đặt vé, 3 hết hạn giữ ghế, 4 khách rời danh sách.
+CREATE TABLE trip_waitlist (
+    id SERIAL PRIMARY KEY,
+    trip_id VARCHAR NOT NULL,
+    customer_id INT NOT NULL,
+    seat_count SMALLINT NOT NULL DEFAULT 1 CHECK (seat_count > 0),
+    from_stop SMALLINT NOT NULL DEFAULT 0,
+    to_stop SMALLINT NOT NULL DEFAULT 32767,
+    status SMALLINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT trip_waitlist_segment_check CHECK (from_stop < to_stop)
+);
+
+-- Mỗi khách chỉ có một lượt chờ còn hiệu lực trên một chuyến.
+CREATE UNIQUE INDEX idx_trip_waitlist_active ON trip_waitlist(trip_id, customer_id)
+    WHERE status IN (0, 1);
+CREATE INDEX idx_trip_waitlist_trip_status ON trip_waitlist(trip_id, status, id);
+
+-- Ghế được giữ cho khách trong danh sách chờ tới expires_at.
+-- status: 0 đang giữ, 1 khách đã đặt, 2 hết hạn, 3 khách rời danh sách.
+-- Ghế đang giữ được tính là đã có người trên đoạn [from_stop, to_stop) với mọi khách khác.
+CREATE TABLE waitlist_offers (
+    id SERIAL PRIMARY KEY,
+    waitlist_id INT NOT NULL REFERENCES trip_waitlist(id) ON DELETE CASCADE,
+    trip_id VARCHAR NOT NULL,
+    seat_id INT NOT NULL REFERENCES seats(id) ON DELETE CASCADE,
+    customer_id INT NOT NULL,
+    from_stop SMALLINT NOT NULL,
+    to_stop SMALLINT NOT NULL,
+    status SMALLINT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_waitlist_offers_seat_active ON waitlist_offers(seat_id) WHERE status = 0;
+CREATE INDEX idx_waitlist_offers_expiry ON waitlist_offers(expires_at) WHERE status = 0;
+CREATE INDEX idx_waitlist_offers_waitlist_id ON waitlist_offers(waitlist_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS waitlist_offers;
+DROP TABLE IF EXISTS trip_waitlist;
+
+-- +goose StatementEnd
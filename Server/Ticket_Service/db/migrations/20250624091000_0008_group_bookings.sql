@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Đơn đặt vé theo đoàn (công ty, đoàn du lịch): nhiều vé, có thể trên nhiều chuyến, thanh toán bằng một hoá đơn.
+-- group_ref được dùng làm ticket_id của hoá đơn bên payment_service.
+CREATE TABLE ticket_groups (
+    group_ref VARCHAR(20) PRIMARY KEY,
+    customer_id INT,
+    organization_name VARCHAR(255),
+    contact_name VARCHAR(100) NOT NULL,
+    contact_phone VARCHAR(15) NOT NULL,
+    contact_email VARCHAR(100),
+    booked_by VARCHAR(50),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_ticket_groups_customer_id ON ticket_groups(customer_id);
+
+-- Các vé thuộc đơn đoàn, mỗi vé là một chiều (chuyến) của đoàn.
+CREATE TABLE ticket_group_members (
+    ticket_id VARCHAR(6) PRIMARY KEY REFERENCES Ticket(Ticket_Id) ON DELETE CASCADE,
+    group_ref VARCHAR(20) NOT NULL REFERENCES ticket_groups(group_ref) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_ticket_group_members_group_ref ON ticket_group_members(group_ref);
+
+-- Hành khách của từng ghế (vé đoàn); NULL thì dùng tên/số điện thoại người đặt trên Ticket.
+ALTER TABLE seat_tickets
+    ADD COLUMN passenger_name VARCHAR(100),
+    ADD COLUMN passenger_phone VARCHAR(15);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE seat_tickets
+    DROP COLUMN IF EXISTS passenger_phone,
+    DROP COLUMN IF EXISTS passenger_name;
+DROP TABLE IF EXISTS ticket_group_members;
+DROP TABLE IF EXISTS ticket_groups;
+
+-- +goose StatementEnd
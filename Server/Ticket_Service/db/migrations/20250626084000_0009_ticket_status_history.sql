@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Lịch sử chuyển trạng thái của vé, thanh toán và từng ghế (xem models.ValidateTicketTransition).
+-- Các dòng log cũ (chỉ có Action) giữ nguyên, các cột mới NULL.
+ALTER TABLE Ticket_Logs
+    ADD COLUMN Entity VARCHAR(10),
+    ADD COLUMN Seat_Ticket_Id INT,
+    ADD COLUMN From_Status SMALLINT,
+    ADD COLUMN To_Status SMALLINT,
+    ADD COLUMN Actor VARCHAR(100),
+    ADD COLUMN Reason VARCHAR(255);
+
+CREATE INDEX idx_ticket_logs_ticket_id_created_at ON Ticket_Logs(Ticket_Id, Created_At);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_ticket_logs_ticket_id_created_at;
+ALTER TABLE Ticket_Logs
+    DROP COLUMN IF EXISTS Reason,
+    DROP COLUMN IF EXISTS Actor,
+    DROP COLUMN IF EXISTS To_Status,
+    DROP COLUMN IF EXISTS From_Status,
+    DROP COLUMN IF EXISTS Seat_Ticket_Id,
+    DROP COLUMN IF EXISTS Entity;
+
+-- +goose StatementEnd
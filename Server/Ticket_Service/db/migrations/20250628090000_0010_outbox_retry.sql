@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Poller dùng chung (shared/outbox): nhận event bằng FOR UPDATE SKIP LOCKED, retry với backoff luỹ thừa.
+ALTER TABLE outbox_events
+    ADD COLUMN attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN last_error TEXT;
+
+CREATE INDEX idx_outbox_events_next_attempt_at ON outbox_events(next_attempt_at, created_at);
+
+-- Event publish thất bại quá số lần cho phép, chờ admin replay hoặc huỷ.
+CREATE TABLE outbox_dead_letters (
+    id UUID PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_outbox_dead_letters_failed_at ON outbox_dead_letters(failed_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS outbox_dead_letters;
+DROP INDEX IF EXISTS idx_outbox_events_next_attempt_at;
+ALTER TABLE outbox_events
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS next_attempt_at,
+    DROP COLUMN IF EXISTS attempts;
+
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Trạng thái bền vững của các yêu cầu đặt vé bất đồng bộ (POST /initiate-booking -> Kafka -> BookingRequestConsumer).
+-- QUEUED -> PROCESSING -> COMPLETED | FAILED; EXPIRED khi client không theo dõi (WebSocket / poll) trước connect_deadline.
+CREATE TABLE booking_requests (
+    booking_id UUID PRIMARY KEY,
+    customer_id INT,
+    status VARCHAR(20) NOT NULL DEFAULT 'QUEUED'
+        CHECK (status IN ('QUEUED', 'PROCESSING', 'COMPLETED', 'FAILED', 'EXPIRED')),
+    request_data JSONB NOT NULL,
+    ticket_id VARCHAR(6) REFERENCES Ticket(Ticket_Id) ON DELETE SET NULL,
+    error_message TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    connect_deadline TIMESTAMPTZ NOT NULL,
+    connected_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- TimeoutWorker chỉ quét các yêu cầu chưa có client theo dõi và chưa kết thúc.
+CREATE INDEX idx_booking_requests_connect_deadline ON booking_requests(connect_deadline)
+    WHERE connected_at IS NULL AND status IN ('QUEUED', 'PROCESSING', 'COMPLETED');
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS booking_requests;
+
+-- +goose StatementEnd
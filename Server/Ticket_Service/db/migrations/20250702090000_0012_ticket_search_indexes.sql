@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Tìm kiếm vé cho nhân viên quầy (GET /tickets/search): keyset pagination theo (cột sắp xếp, Ticket_Id)
+-- và tra cứu email không phân biệt hoa thường.
+CREATE INDEX idx_ticket_booking_time_id ON Ticket(Booking_Time, Ticket_Id);
+CREATE INDEX idx_ticket_price_id ON Ticket(Price, Ticket_Id);
+CREATE INDEX idx_ticket_lower_email ON Ticket(lower(Email));
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_ticket_lower_email;
+DROP INDEX IF EXISTS idx_ticket_price_id;
+DROP INDEX IF EXISTS idx_ticket_booking_time_id;
+
+-- +goose StatementEnd
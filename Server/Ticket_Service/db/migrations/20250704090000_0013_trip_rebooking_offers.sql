@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Đổi chuyến miễn phí cho vé của chuyến bị dời giờ khởi hành (sự kiện trip_updated từ trip-service).
+-- Mỗi vé có một lượt đổi cho mỗi chuyến bị dời; used_at được ghi khi khách đổi ghế / đổi chuyến bằng lượt này.
+-- Chuyến bị dời thêm lần nữa thì lượt đổi được mở lại với giờ mới.
+CREATE TABLE trip_rebooking_offers (
+    ticket_id VARCHAR(6) NOT NULL REFERENCES Ticket(Ticket_Id) ON DELETE CASCADE,
+    trip_id VARCHAR NOT NULL,
+    old_departure TIMESTAMPTZ NOT NULL,
+    new_departure TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (ticket_id, trip_id)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS trip_rebooking_offers;
+
+-- +goose StatementEnd
@@ -19,9 +19,9 @@ ORDER BY seat_name;
 -- Inserts a new ticket record for both one-way and round-trip.
 INSERT INTO Ticket (
     Ticket_Id, Trip_Id_Begin, Trip_Id_End, Type, Customer_Id, Phone, Email, Name, Price, Status,
-    Booking_Time, Payment_Status, Booking_Channel, Policy_Id, Booked_By
+    Booking_Time, Payment_Status, Booking_Channel, Policy_Id, Booked_By, Fare_Breakdown
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
 ) RETURNING *;
 
 -- name: CreateTicketDetails :one
@@ -33,9 +33,9 @@ INSERT INTO Ticket_Details (
 ) RETURNING *;
 
 -- name: CreateSeatTicket :one
--- Links a seat to a ticket, now includes trip_id directly.
-INSERT INTO seat_tickets (seat_id, ticket_id, status, trip_id)
-VALUES ($1, $2, $3, $4)
+-- Links a seat to a ticket for the segment [from_stop, to_stop) of the trip.
+INSERT INTO seat_tickets (seat_id, ticket_id, status, trip_id, from_stop, to_stop, passenger_name, passenger_phone)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING *;
 
 -- name: GetTicketCore :one
@@ -104,10 +104,10 @@ WHERE id = $1
 RETURNING *;
 
 -- name: UpdateSeatTicketStatusByTicketID :many
--- Updates the status of all seat_ticket entries for a given ticket_id.
+-- Updates the status of all not-cancelled seat_ticket entries for a given ticket_id.
 UPDATE seat_tickets
 SET status = $2, updated_at = CURRENT_TIMESTAMP
-WHERE ticket_id = $1
+WHERE ticket_id = $1 AND status <> 2 -- ghế đã huỷ riêng (hành khách rời đoàn) không được khôi phục
 RETURNING *;
 
 -- name: GetSeatIDsByTicketID :many
@@ -116,14 +116,22 @@ SELECT seat_id FROM seat_tickets
 WHERE ticket_id = $1;
 
 -- name: ListAvailableSeatsByTripID :many
--- Lists all seats for a trip_id that are not in seat_tickets or have status 2 (cancelled).
+-- Lists all seats for a trip_id that are free on the segment [from_stop, to_stop)
+-- (no active seat_ticket or waitlist hold overlapping the segment; status 2 (cancelled) does not count).
 SELECT s.id, s.trip_id, s.seat_name
 FROM seats s
-WHERE s.trip_id = $1
+WHERE s.trip_id = @trip_id
 AND NOT EXISTS (
     SELECT 1
     FROM seat_tickets st
-    WHERE st.seat_id = s.id AND st.status IN (0, 1, 3) -- 0: pending, 1: paid, 3: checked-in
+    WHERE st.seat_id = s.id AND st.status IN (0, 1, 3, 4) -- 0: pending, 1: paid, 3: checked-in, 4: no-show
+      AND st.from_stop < @to_stop::smallint AND @from_stop::smallint < st.to_stop
+)
+AND NOT EXISTS (
+    SELECT 1
+    FROM waitlist_offers wo
+    WHERE wo.seat_id = s.id AND wo.status = 0 AND wo.expires_at > now() -- đang giữ cho khách trong danh sách chờ
+      AND wo.from_stop < @to_stop::smallint AND @from_stop::smallint < wo.to_stop
 )
 ORDER BY s.seat_name;
 
@@ -133,6 +141,17 @@ INSERT INTO Ticket_Logs (Ticket_Id, Action)
 VALUES ($1, $2)
 RETURNING *;
 
+-- name: CreateTicketStatusLog :exec
+-- Records a status transition of a ticket, its payment or one of its seats.
+INSERT INTO Ticket_Logs (Ticket_Id, Action, Entity, Seat_Ticket_Id, From_Status, To_Status, Actor, Reason)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: ListTicketLogs :many
+-- Lists the audit history of a ticket in chronological order.
+SELECT * FROM Ticket_Logs
+WHERE Ticket_Id = $1
+ORDER BY Created_At, Log_Id;
+
 -- name: GetSeatTicketAndSeatInfoByTicketID :one
 -- Retrieves seat_ticket and associated seat details for a given ticket_id.
 SELECT
@@ -147,7 +166,7 @@ WHERE st.ticket_id = $1 AND st.status = 1; -- Typically checkin for confirmed/pa
 SELECT st.*, s.seat_name
 FROM seat_tickets st
 JOIN seats s ON st.seat_id = s.id
-WHERE st.seat_id = $1 and st.ticket_id = $2 and st.status  = 1; -- Typically checkin for confirmed/paid tickets;
+WHERE st.seat_id = $1 and st.ticket_id = $2 and st.status IN (1, 4); -- Typically checkin for confirmed/paid tickets;
 
 
 -- name: CreateCheckin :one
@@ -171,6 +190,25 @@ SET status = $2, updated_at = CURRENT_TIMESTAMP -- $2 would be the 'checked-in'
 WHERE id = $1 -- seat_ticket.id
 RETURNING *;
 
+-- name: GetTicketStatesForUpdate :one
+-- Locks a ticket row and returns its current status and payment status for the state machine check.
+SELECT status, payment_status FROM Ticket
+WHERE Ticket_Id = $1
+FOR UPDATE;
+
+-- name: ListSeatTicketStatusesForUpdate :many
+-- Locks the seat_tickets of a ticket and returns their current status for the state machine check.
+SELECT id, status FROM seat_tickets
+WHERE ticket_id = $1
+ORDER BY id
+FOR UPDATE;
+
+-- name: GetSeatTicketStatusForUpdate :one
+-- Locks one seat_ticket of a ticket and returns its current status for the state machine check.
+SELECT status FROM seat_tickets
+WHERE id = $1 AND ticket_id = $2
+FOR UPDATE;
+
 -- name: GetTicketStatus :one
 -- Retrieves just the status of a ticket.
 SELECT status FROM Ticket
@@ -182,38 +220,110 @@ SELECT status FROM seat_tickets
 WHERE id = $1;
 
 -- name: AreSeatsAvailable :many
--- Checks if a list of seats are available (not booked or held).
+-- Checks if a list of seats are available (not booked or held) on the segment [from_stop, to_stop).
+-- Seats held for a waitlisted customer only count as free for that customer (holder_customer_id, 0 = nobody).
 SELECT s.id,
-       EXISTS(
+       (EXISTS(
            SELECT 1
            FROM seat_tickets st
-           WHERE st.seat_id = s.id AND st.status IN (0, 1, 3) -- 0: pending, 1: paid, 3: checked-in
-       ) as is_booked
+           WHERE st.seat_id = s.id AND st.status IN (0, 1, 3, 4) -- 0: pending, 1: paid, 3: checked-in, 4: no-show
+             AND st.from_stop < @to_stop::smallint AND @from_stop::smallint < st.to_stop
+       ) OR EXISTS(
+           SELECT 1
+           FROM waitlist_offers wo
+           WHERE wo.seat_id = s.id AND wo.status = 0 AND wo.expires_at > now()
+             AND wo.customer_id <> @holder_customer_id::int
+             AND wo.from_stop < @to_stop::smallint AND @from_stop::smallint < wo.to_stop
+       )) as is_booked
 FROM seats s
 WHERE s.id = ANY(@seat_ids::int[]);
 
+-- name: DeleteSeatTicketsBySeatIDs :exec
+-- Removes seat assignments of a ticket (used when the passenger changes seats).
+DELETE FROM seat_tickets
+WHERE ticket_id = @ticket_id AND seat_id = ANY(@seat_ids::int[]);
+
 
 -- name: CreateOutboxEvent :exec
 -- For Transactional Outbox Pattern
 INSERT INTO outbox_events (id, topic, key, payload)
 VALUES ($1, $2, $3, $4);
 
--- name: GetOutboxEvents :many
--- For the Outbox Poller/Relay
-SELECT * FROM outbox_events
-ORDER BY created_at
-LIMIT $1;
-
--- name: DeleteOutboxEvents :exec
--- For the Outbox Poller/Relay
-DELETE FROM outbox_events
-WHERE id = ANY(@event_ids::uuid[]);
-
 -- name: GetAllCheckinsByTripID :many
 SELECT * FROM checkins
 WHERE trip_id = $1
 ORDER BY checked_in_at DESC;
 
+-- name: GetSeatTicketForCheckin :one
+-- Locks the seat_ticket of a seat on a ticket for check-in, whatever its status.
+SELECT st.*, s.seat_name
+FROM seat_tickets st
+JOIN seats s ON st.seat_id = s.id
+WHERE st.seat_id = $1 AND st.ticket_id = $2
+ORDER BY st.id DESC
+LIMIT 1
+FOR UPDATE OF st;
+
+-- name: CreateOfflineCheckin :one
+-- Inserts a check-in replayed from a conductor device, keeping the original scan time.
+INSERT INTO checkins (seat_ticket_id, ticket_id, trip_id, seat_name, note, checked_in_at, source, device_id, client_scan_id, conflict)
+VALUES (@seat_ticket_id, @ticket_id, @trip_id, @seat_name, @note, @checked_in_at, 'offline', @device_id, @client_scan_id, sqlc.narg(conflict))
+RETURNING *;
+
+-- name: GetCheckinByClientScanID :one
+-- Finds a check-in already synced from the same device scan.
+SELECT * FROM checkins
+WHERE device_id = $1 AND client_scan_id = $2;
+
+-- name: GetFirstCheckinBySeatTicket :one
+-- Returns the accepted check-in of a seat on a ticket (conflict rows are ignored).
+SELECT * FROM checkins
+WHERE seat_ticket_id = $1 AND ticket_id = $2 AND conflict IS NULL
+ORDER BY checked_in_at
+LIMIT 1;
+
+-- name: GetBoardingManifest :many
+-- Lists every sold (not cancelled) seat of a trip with passenger, payment and check-in status for dispatchers.
+-- Per-seat passenger (group bookings) takes precedence over the name/phone of the booker.
+SELECT st.id, st.seat_id, st.ticket_id, st.status, st.from_stop, st.to_stop, s.seat_name,
+       COALESCE(st.passenger_name, t.name) AS name, COALESCE(st.passenger_phone, t.phone) AS phone,
+       t.payment_status, c.checked_in_at
+FROM seat_tickets st
+JOIN seats s ON st.seat_id = s.id
+JOIN Ticket t ON t.Ticket_Id = st.ticket_id
+LEFT JOIN LATERAL (
+    SELECT ci.checked_in_at
+    FROM checkins ci
+    WHERE ci.seat_ticket_id = st.seat_id AND ci.ticket_id = st.ticket_id AND ci.conflict IS NULL
+    ORDER BY ci.checked_in_at
+    LIMIT 1
+) c ON true
+WHERE st.trip_id = $1 AND st.status <> 2 -- 2: cancelled
+ORDER BY s.seat_name, st.from_stop;
+
+-- name: ListTripIDsWithConfirmedSeats :many
+-- Trips that still have confirmed seats not yet checked in (candidates for no-show marking).
+SELECT DISTINCT trip_id FROM seat_tickets
+WHERE status = 1;
+
+-- name: MarkSeatTicketsNoShow :many
+-- Marks confirmed seats of a trip boarding at or before stop @max_from_stop as no-show (status 4).
+UPDATE seat_tickets
+SET status = 4, updated_at = CURRENT_TIMESTAMP
+WHERE trip_id = @trip_id AND status = 1 AND from_stop <= @max_from_stop::smallint
+RETURNING *;
+
+-- name: GetTripManifest :many
+-- Lists confirmed and checked-in seat tickets of a trip for the offline boarding manifest.
+-- Per-seat passenger (group bookings) takes precedence over the name/phone of the booker.
+SELECT st.id, st.seat_id, st.ticket_id, st.status, st.from_stop, st.to_stop, s.seat_name,
+       COALESCE(st.passenger_name, t.name) AS name, COALESCE(st.passenger_phone, t.phone) AS phone
+FROM seat_tickets st
+JOIN seats s ON st.seat_id = s.id
+JOIN Ticket t ON t.Ticket_Id = st.ticket_id
+WHERE st.trip_id = $1 AND st.status IN (1, 3) -- 1: confirmed, 3: checked-in
+ORDER BY s.seat_name, st.from_stop;
+
 -- name: GetAllTickets :many
 -- Retrieves a paginated list of all tickets, ordered by booking time.
 SELECT * FROM Ticket
@@ -225,6 +335,50 @@ OFFSET $2;
 -- Retrieves the total number of tickets.
 SELECT COUNT(*) FROM Ticket;
 
+-- name: SearchTickets :many
+-- Staff ticket search. Every filter is optional (NULL = not filtered); trip_id matches either leg.
+-- Keyset pagination on (sort column, Ticket_Id): the cursor is the last row of the previous page,
+-- so pages stay stable while new tickets are booked. @sort_by is 'booking_time' or 'price'.
+SELECT t.*, COALESCE(m.group_ref, '')::varchar AS group_ref
+FROM Ticket t
+LEFT JOIN ticket_group_members m ON m.ticket_id = t.Ticket_Id
+WHERE (sqlc.narg(phone)::varchar IS NULL OR t.Phone = sqlc.narg(phone))
+  AND (sqlc.narg(email)::varchar IS NULL OR lower(t.Email) = lower(sqlc.narg(email)))
+  AND (sqlc.narg(name)::varchar IS NULL OR t.Name ILIKE '%' || sqlc.narg(name) || '%')
+  AND (sqlc.narg(trip_id)::varchar IS NULL OR t.Trip_Id_Begin = sqlc.narg(trip_id) OR t.Trip_Id_End = sqlc.narg(trip_id))
+  AND (sqlc.narg(booked_from)::timestamp IS NULL OR t.Booking_Time >= sqlc.narg(booked_from))
+  AND (sqlc.narg(booked_to)::timestamp IS NULL OR t.Booking_Time < sqlc.narg(booked_to))
+  AND (sqlc.narg(status)::smallint IS NULL OR t.Status = sqlc.narg(status))
+  AND (sqlc.narg(payment_status)::smallint IS NULL OR t.Payment_Status = sqlc.narg(payment_status))
+  AND (sqlc.narg(booking_channel)::smallint IS NULL OR t.Booking_Channel = sqlc.narg(booking_channel))
+  AND (sqlc.narg(cursor_id)::varchar IS NULL OR CASE
+        WHEN @sort_by::text = 'price' AND @sort_desc::boolean THEN (t.Price, t.Ticket_Id) < (sqlc.narg(cursor_price)::float8, sqlc.narg(cursor_id))
+        WHEN @sort_by = 'price' THEN (t.Price, t.Ticket_Id) > (sqlc.narg(cursor_price), sqlc.narg(cursor_id))
+        WHEN @sort_desc THEN (t.Booking_Time, t.Ticket_Id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id))
+        ELSE (t.Booking_Time, t.Ticket_Id) > (sqlc.narg(cursor_time), sqlc.narg(cursor_id))
+      END)
+ORDER BY
+  CASE WHEN @sort_by = 'price' AND @sort_desc THEN t.Price END DESC,
+  CASE WHEN @sort_by = 'price' AND NOT @sort_desc THEN t.Price END ASC,
+  CASE WHEN @sort_by <> 'price' AND @sort_desc THEN t.Booking_Time END DESC,
+  CASE WHEN @sort_by <> 'price' AND NOT @sort_desc THEN t.Booking_Time END ASC,
+  CASE WHEN @sort_desc THEN t.Ticket_Id END DESC,
+  CASE WHEN NOT @sort_desc THEN t.Ticket_Id END ASC
+LIMIT @row_limit;
+
+-- name: CountSearchTickets :one
+-- Counts tickets matching the SearchTickets filters (without the cursor).
+SELECT COUNT(*) FROM Ticket t
+WHERE (sqlc.narg(phone)::varchar IS NULL OR t.Phone = sqlc.narg(phone))
+  AND (sqlc.narg(email)::varchar IS NULL OR lower(t.Email) = lower(sqlc.narg(email)))
+  AND (sqlc.narg(name)::varchar IS NULL OR t.Name ILIKE '%' || sqlc.narg(name) || '%')
+  AND (sqlc.narg(trip_id)::varchar IS NULL OR t.Trip_Id_Begin = sqlc.narg(trip_id) OR t.Trip_Id_End = sqlc.narg(trip_id))
+  AND (sqlc.narg(booked_from)::timestamp IS NULL OR t.Booking_Time >= sqlc.narg(booked_from))
+  AND (sqlc.narg(booked_to)::timestamp IS NULL OR t.Booking_Time < sqlc.narg(booked_to))
+  AND (sqlc.narg(status)::smallint IS NULL OR t.Status = sqlc.narg(status))
+  AND (sqlc.narg(payment_status)::smallint IS NULL OR t.Payment_Status = sqlc.narg(payment_status))
+  AND (sqlc.narg(booking_channel)::smallint IS NULL OR t.Booking_Channel = sqlc.narg(booking_channel));
+
 -- name: GetSeatTicketsByTicketIDs :many
 -- Retrieves all seat_ticket entries for a given list of Ticket_Ids.
 SELECT st.*, s.seat_name
@@ -237,3 +391,323 @@ WHERE st.ticket_id = ANY(@ticket_ids::varchar[]);
 SELECT * FROM Ticket_Details
 WHERE Ticket_Id = ANY(@ticket_ids::varchar[]);
 
+-- name: GetTicketsCoreByIDs :many
+-- Retrieves core ticket information for a given list of Ticket_Ids.
+SELECT * FROM Ticket
+WHERE Ticket_Id = ANY(@ticket_ids::varchar[]);
+
+-- name: GetActivePolicyByID :one
+-- Retrieves an active pricing policy used by the fare engine.
+SELECT * FROM policies
+WHERE policy_id = $1 AND status = 1;
+
+-- name: ListRefundRulesByPolicyID :many
+-- Retrieves refund rules of a policy, most generous (longest notice) first.
+SELECT * FROM policy_refund_rules
+WHERE policy_id = $1
+ORDER BY min_hours_before_departure DESC;
+
+-- name: UpdateTicketFare :one
+-- Updates trips, price and fare breakdown of a ticket after a seat/trip change.
+UPDATE Ticket
+SET Trip_Id_Begin = $2, Trip_Id_End = $3, Price = $4, Fare_Breakdown = $5, Updated_At = CURRENT_TIMESTAMP
+WHERE Ticket_Id = $1
+RETURNING *;
+
+-- name: CreateSeatFromLayout :one
+-- Inserts a seat for a trip with its position taken from a seat layout template.
+INSERT INTO seats (trip_id, seat_name, layout_id, deck, row_no, col_no, seat_class)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: FindSeatLayoutForVehicle :one
+-- Picks the layout of a vehicle type (exact seat count first), falling back to the default layout (vehicle_type_id 0).
+SELECT * FROM seat_layouts
+WHERE vehicle_type_id IN (@vehicle_type_id::int, 0)
+ORDER BY (vehicle_type_id = @vehicle_type_id::int) DESC, (seat_count = @seat_count::int) DESC, id
+LIMIT 1;
+
+-- name: GetSeatLayoutByID :one
+-- Retrieves a seat layout template by its ID.
+SELECT * FROM seat_layouts
+WHERE id = $1;
+
+-- name: ListSeatLayouts :many
+-- Lists all seat layout templates.
+SELECT * FROM seat_layouts
+ORDER BY vehicle_type_id, seat_count;
+
+-- name: ListSeatLayoutSeats :many
+-- Lists seat positions of a layout template.
+SELECT * FROM seat_layout_seats
+WHERE layout_id = $1
+ORDER BY deck, row_no, col_no;
+
+-- name: UpsertSeatLayout :one
+-- Creates or replaces the layout template of a vehicle type and seat count.
+INSERT INTO seat_layouts (vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (vehicle_type_id, seat_count) DO UPDATE
+SET name = EXCLUDED.name,
+    decks = EXCLUDED.decks,
+    row_count = EXCLUDED.row_count,
+    column_count = EXCLUDED.column_count,
+    aisle_after_columns = EXCLUDED.aisle_after_columns
+RETURNING *;
+
+-- name: DeleteSeatLayoutSeats :exec
+-- Removes all seat positions of a layout template (before re-inserting them).
+DELETE FROM seat_layout_seats
+WHERE layout_id = $1;
+
+-- name: CreateSeatLayoutSeat :one
+-- Inserts a seat position into a layout template.
+INSERT INTO seat_layout_seats (layout_id, seat_name, deck, row_no, col_no, seat_class)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: DeleteSeatLayout :exec
+-- Deletes a seat layout template (seats already generated keep their positions).
+DELETE FROM seat_layouts
+WHERE id = $1;
+
+-- name: GetSeatMapByTripID :many
+-- Lists all seats of a trip with their layout position and whether they are taken on the segment [from_stop, to_stop).
+SELECT s.id, s.seat_name, s.layout_id, s.deck, s.row_no, s.col_no, s.seat_class,
+       (EXISTS (
+           SELECT 1 FROM seat_tickets st
+           WHERE st.seat_id = s.id AND st.status IN (0, 1, 3, 4) -- 0: pending, 1: paid, 3: checked-in, 4: no-show
+             AND st.from_stop < @to_stop::smallint AND @from_stop::smallint < st.to_stop
+       ) OR EXISTS (
+           SELECT 1 FROM waitlist_offers wo
+           WHERE wo.seat_id = s.id AND wo.status = 0 AND wo.expires_at > now()
+             AND wo.from_stop < @to_stop::smallint AND @from_stop::smallint < wo.to_stop
+       )) AS is_booked
+FROM seats s
+WHERE s.trip_id = @trip_id
+ORDER BY s.deck, s.row_no, s.col_no, s.seat_name;
+
+-- name: CreateWaitlistEntry :one
+-- Adds a customer to the waitlist of a sold-out trip.
+INSERT INTO trip_waitlist (trip_id, customer_id, seat_count, from_stop, to_stop)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetWaitlistEntryForUpdate :one
+-- Locks a waitlist entry while seats are offered to it.
+SELECT * FROM trip_waitlist
+WHERE id = $1
+FOR UPDATE;
+
+-- name: ListWaitlistEntriesByCustomer :many
+-- Lists the waitlist entries of a customer with their place in line (only meaningful while status = 0).
+SELECT w.id, w.trip_id, w.customer_id, w.seat_count, w.from_stop, w.to_stop, w.status, w.created_at, w.updated_at,
+       (SELECT COUNT(*) FROM trip_waitlist q
+        WHERE q.trip_id = w.trip_id AND q.status = 0 AND q.id <= w.id) AS position
+FROM trip_waitlist w
+WHERE w.customer_id = $1
+ORDER BY w.id DESC
+LIMIT 50;
+
+-- name: ListWaitingEntriesByTripID :many
+-- Lists waiting entries of a trip in queue order.
+SELECT * FROM trip_waitlist
+WHERE trip_id = $1 AND status = 0
+ORDER BY id
+LIMIT $2;
+
+-- name: UpdateWaitlistEntryStatus :exec
+UPDATE trip_waitlist
+SET status = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: CancelWaitlistEntry :one
+-- Removes a customer from the waitlist (status 4) if the entry is still waiting or holding seats.
+UPDATE trip_waitlist
+SET status = 4, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND customer_id = $2 AND status IN (0, 1)
+RETURNING *;
+
+-- name: CreateWaitlistOffer :one
+-- Holds a freed seat for a waitlist entry for @hold_seconds.
+INSERT INTO waitlist_offers (waitlist_id, trip_id, seat_id, customer_id, from_stop, to_stop, expires_at)
+VALUES (@waitlist_id, @trip_id, @seat_id, @customer_id, @from_stop, @to_stop, CURRENT_TIMESTAMP + make_interval(secs => @hold_seconds::int))
+RETURNING *;
+
+-- name: ListActiveWaitlistOffersByCustomer :many
+-- Lists seats currently held for a customer from the waitlist.
+SELECT wo.id, wo.waitlist_id, wo.trip_id, wo.seat_id, s.seat_name, wo.expires_at
+FROM waitlist_offers wo
+JOIN seats s ON s.id = wo.seat_id
+WHERE wo.customer_id = $1 AND wo.status = 0 AND wo.expires_at > now()
+ORDER BY wo.waitlist_id, s.seat_name;
+
+-- name: ReleaseWaitlistOffers :execrows
+-- Releases the seats still held for a waitlist entry the customer left (status 3).
+UPDATE waitlist_offers
+SET status = 3, updated_at = CURRENT_TIMESTAMP
+WHERE waitlist_id = $1 AND status = 0;
+
+-- name: ClaimWaitlistOffers :execrows
+-- Marks the held seats a customer has just booked as claimed, and their waitlist entries as booked (status 2).
+WITH claimed AS (
+    UPDATE waitlist_offers
+    SET status = 1, updated_at = CURRENT_TIMESTAMP
+    WHERE customer_id = @customer_id AND trip_id = @trip_id AND seat_id = ANY(@seat_ids::int[])
+      AND status = 0 AND expires_at > now()
+    RETURNING waitlist_id
+)
+UPDATE trip_waitlist
+SET status = 2, updated_at = CURRENT_TIMESTAMP
+WHERE id IN (SELECT waitlist_id FROM claimed) AND status = 1;
+
+-- name: ExpireWaitlistOffers :many
+-- Expires seat holds that were not booked in time (status 2).
+UPDATE waitlist_offers
+SET status = 2, updated_at = CURRENT_TIMESTAMP
+WHERE status = 0 AND expires_at <= now()
+RETURNING waitlist_id, trip_id;
+
+-- name: ExpireWaitlistEntries :many
+-- Marks entries whose offer lapsed as expired (status 3) so the next customer in line gets the seats.
+UPDATE trip_waitlist
+SET status = 3, updated_at = CURRENT_TIMESTAMP
+WHERE id = ANY(@ids::int[]) AND status = 1
+RETURNING *;
+
+-- name: CreateTicketGroup :one
+-- Creates a group booking that ties several tickets (possibly on different trips) to one invoice.
+INSERT INTO ticket_groups (group_ref, customer_id, organization_name, contact_name, contact_phone, contact_email, booked_by)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: AddTicketToGroup :exec
+-- Adds a ticket to a group booking.
+INSERT INTO ticket_group_members (ticket_id, group_ref)
+VALUES ($1, $2);
+
+-- name: GroupRefExists :one
+-- Checks whether a group reference is already used.
+SELECT EXISTS (
+    SELECT 1 FROM ticket_groups WHERE group_ref = $1
+);
+
+-- name: GetTicketGroup :one
+-- Retrieves a group booking by its reference.
+SELECT * FROM ticket_groups
+WHERE group_ref = $1;
+
+-- name: ListTicketIDsByGroupRef :many
+-- Lists the tickets of a group booking in booking order.
+SELECT ticket_id FROM ticket_group_members
+WHERE group_ref = $1
+ORDER BY created_at, ticket_id;
+
+-- name: GetGroupRefByTicketID :one
+-- Returns the group booking a ticket belongs to.
+SELECT group_ref FROM ticket_group_members
+WHERE ticket_id = $1;
+
+-- name: CreateBookingRequest :exec
+-- Records a queued booking request; the client must track it (WebSocket or polling) within @connect_timeout_seconds.
+INSERT INTO booking_requests (booking_id, customer_id, request_data, connect_deadline)
+VALUES (@booking_id, @customer_id, @request_data, NOW() + make_interval(secs => @connect_timeout_seconds::int));
+
+-- name: GetBookingRequest :one
+-- Retrieves a booking request by its booking id.
+SELECT * FROM booking_requests
+WHERE booking_id = $1;
+
+-- name: StartBookingRequest :one
+-- Marks a booking request as being processed by the consumer. A PROCESSING request can be picked up again
+-- (redelivered message after a crash): its ticket is only kept if CompleteBookingRequest succeeds.
+UPDATE booking_requests
+SET status = 'PROCESSING', attempts = attempts + 1, updated_at = NOW()
+WHERE booking_id = $1 AND status IN ('QUEUED', 'PROCESSING')
+RETURNING *;
+
+-- name: CompleteBookingRequest :execrows
+-- Attaches the created ticket to a booking request, in the ticket creation transaction.
+-- Affects no row if the request expired meanwhile, in which case the ticket must be rolled back.
+UPDATE booking_requests
+SET status = 'COMPLETED', ticket_id = $2, error_message = NULL, updated_at = NOW()
+WHERE booking_id = $1 AND status = 'PROCESSING';
+
+-- name: FailBookingRequest :execrows
+-- Marks a booking request that could not be fulfilled (seats taken, invalid input, ...).
+UPDATE booking_requests
+SET status = 'FAILED', error_message = $2, updated_at = NOW()
+WHERE booking_id = $1 AND status IN ('QUEUED', 'PROCESSING');
+
+-- name: ConnectBookingRequest :one
+-- Records that the client is tracking the booking request, unless it already expired.
+UPDATE booking_requests
+SET connected_at = COALESCE(connected_at, NOW()), updated_at = NOW()
+WHERE booking_id = $1 AND status <> 'EXPIRED'
+  AND (connected_at IS NOT NULL OR connect_deadline > NOW())
+RETURNING *;
+
+-- name: ExpireUnconnectedBookingRequests :many
+-- Expires up to @batch_size unfinished booking requests nobody tracked before their deadline.
+-- A returned ticket_id means the request was COMPLETED and its ticket must be cancelled.
+UPDATE booking_requests
+SET status = 'EXPIRED', updated_at = NOW()
+WHERE booking_id IN (
+    SELECT booking_id FROM booking_requests
+    WHERE connected_at IS NULL AND connect_deadline <= NOW()
+      AND status IN ('QUEUED', 'PROCESSING', 'COMPLETED')
+    ORDER BY connect_deadline
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING booking_id, ticket_id;
+
+-- name: ReopenExpiredBookingRequest :exec
+-- Puts back a COMPLETED booking request whose ticket could not be cancelled, so the next sweep retries.
+UPDATE booking_requests
+SET status = 'COMPLETED', updated_at = NOW()
+WHERE booking_id = $1 AND status = 'EXPIRED' AND ticket_id IS NOT NULL;
+
+-- name: ListActiveTicketIDsByTripID :many
+-- Lists the pending / active tickets that have a leg on a trip (trip cancelled or rescheduled).
+SELECT Ticket_Id FROM Ticket
+WHERE (Trip_Id_Begin = @trip_id::varchar OR Trip_Id_End = @trip_id::varchar) AND Status IN (0, 1)
+ORDER BY Ticket_Id;
+
+-- name: UpsertTripRebookingOffer :execrows
+-- Opens a free rebooking for a ticket of a rescheduled trip. Affects no row when the offer already
+-- exists for the same new departure (redelivered event), so the customer is not notified twice.
+INSERT INTO trip_rebooking_offers (ticket_id, trip_id, old_departure, new_departure)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (ticket_id, trip_id) DO UPDATE
+SET new_departure = EXCLUDED.new_departure, used_at = NULL, updated_at = NOW()
+WHERE trip_rebooking_offers.new_departure IS DISTINCT FROM EXCLUDED.new_departure;
+
+-- name: GetOpenTripRebookingOffer :one
+-- Retrieves the unused free rebooking of a ticket on a trip.
+SELECT * FROM trip_rebooking_offers
+WHERE ticket_id = $1 AND trip_id = $2 AND used_at IS NULL;
+
+-- name: UseTripRebookingOffer :execrows
+-- Marks a free rebooking as used, in the seat change transaction.
+UPDATE trip_rebooking_offers
+SET used_at = NOW(), updated_at = NOW()
+WHERE ticket_id = $1 AND trip_id = $2 AND used_at IS NULL;
+
+-- name: CloseTripWaitlist :many
+-- Closes the waitlist of a cancelled trip: held seats are released (status 3) and entries marked as left (status 4).
+WITH released AS (
+    UPDATE waitlist_offers
+    SET status = 3, updated_at = CURRENT_TIMESTAMP
+    WHERE trip_id = @trip_id::varchar AND status = 0
+)
+UPDATE trip_waitlist
+SET status = 4, updated_at = CURRENT_TIMESTAMP
+WHERE trip_id = @trip_id::varchar AND status IN (0, 1)
+RETURNING *;
+
+-- name: DeleteUnusedSeatsByTripID :execrows
+-- Deletes the seats of a cancelled trip that were never booked; seats referenced by tickets are kept for history.
+DELETE FROM seats s
+WHERE s.trip_id = $1
+  AND NOT EXISTS (SELECT 1 FROM seat_tickets st WHERE st.seat_id = s.id);
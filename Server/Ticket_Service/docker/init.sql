@@ -104,4 +104,322 @@ $$ LANGUAGE plpgsql;
 CREATE TRIGGER set_ticket_timestamp BEFORE UPDATE ON Ticket FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
 CREATE TRIGGER set_ticket_details_timestamp BEFORE UPDATE ON Ticket_Details FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
 CREATE TRIGGER set_seats_timestamp BEFORE UPDATE ON seats FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
-CREATE TRIGGER set_seat_tickets_timestamp BEFORE UPDATE ON seat_tickets FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
\ No newline at end of file
+CREATE TRIGGER set_seat_tickets_timestamp BEFORE UPDATE ON seat_tickets FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+-- 0002_fare_policies
+CREATE TABLE policies (
+    policy_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    discount_percent DECIMAL(5,2) NOT NULL DEFAULT 0,            -- Giảm giá áp dụng trên tổng giá vé
+    round_trip_discount_percent DECIMAL(5,2) NOT NULL DEFAULT 0, -- Giảm thêm cho vé khứ hồi
+    status SMALLINT NOT NULL DEFAULT 1,                          -- 0: inactive, 1: active
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Breakdown of the server-computed fare (legs, special day surcharge, discounts).
+ALTER TABLE Ticket ADD COLUMN Fare_Breakdown JSONB NOT NULL DEFAULT '{}';
+
+CREATE TRIGGER set_policies_timestamp BEFORE UPDATE ON policies FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+-- 0003_policy_refund_rules
+-- Quy tắc hoàn tiền theo policy: áp dụng rule có min_hours_before_departure lớn nhất
+-- mà thời gian còn lại trước giờ khởi hành vẫn đạt được.
+CREATE TABLE policy_refund_rules (
+    id SERIAL PRIMARY KEY,
+    policy_id INT NOT NULL,
+    min_hours_before_departure INT NOT NULL,
+    refund_percent DECIMAL(5,2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(policy_id, min_hours_before_departure),
+    FOREIGN KEY (policy_id) REFERENCES policies(policy_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_policy_refund_rules_policy_id ON policy_refund_rules(policy_id);
+
+-- Policy 0 là policy mặc định (không giảm giá) được dùng bởi các vé không chọn policy.
+INSERT INTO policies (policy_id, name) VALUES (0, 'Default') ON CONFLICT (policy_id) DO NOTHING;
+INSERT INTO policy_refund_rules (policy_id, min_hours_before_departure, refund_percent) VALUES
+    (0, 48, 90),
+    (0, 24, 70),
+    (0, 4, 50),
+    (0, 0, 0)
+ON CONFLICT (policy_id, min_hours_before_departure) DO NOTHING;
+
+-- 0004_seat_layouts
+-- Mẫu sơ đồ ghế theo loại xe (vehicle type của trip-service).
+-- vehicle_type_id = 0 là mẫu mặc định, dùng khi loại xe chưa có mẫu riêng.
+CREATE TABLE seat_layouts (
+    id SERIAL PRIMARY KEY,
+    vehicle_type_id INT NOT NULL,
+    seat_count INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    decks SMALLINT NOT NULL DEFAULT 1,
+    row_count SMALLINT NOT NULL,
+    column_count SMALLINT NOT NULL,
+    aisle_after_columns INT[] NOT NULL DEFAULT '{}', -- Lối đi nằm sau các cột này (để client vẽ khoảng trống)
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(vehicle_type_id, seat_count)
+);
+
+-- Vị trí từng ghế trong mẫu. Ô (deck, row, col) không có ghế là khoảng trống.
+CREATE TABLE seat_layout_seats (
+    id SERIAL PRIMARY KEY,
+    layout_id INT NOT NULL,
+    seat_name VARCHAR(10) NOT NULL,
+    deck SMALLINT NOT NULL DEFAULT 1,
+    row_no SMALLINT NOT NULL,
+    col_no SMALLINT NOT NULL,
+    seat_class VARCHAR(20) NOT NULL DEFAULT 'STANDARD', -- STANDARD, VIP, SLEEPER
+    UNIQUE(layout_id, seat_name),
+    UNIQUE(layout_id, deck, row_no, col_no),
+    FOREIGN KEY (layout_id) REFERENCES seat_layouts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_seat_layout_seats_layout_id ON seat_layout_seats(layout_id);
+
+CREATE TRIGGER set_seat_layouts_timestamp BEFORE UPDATE ON seat_layouts FOR EACH ROW EXECUTE FUNCTION trigger_set_timestamp();
+
+-- Ghế của chuyến lưu lại vị trí lấy từ mẫu; ghế cũ (trước khi có mẫu) để NULL.
+ALTER TABLE seats
+    ADD COLUMN layout_id INT REFERENCES seat_layouts(id) ON DELETE SET NULL,
+    ADD COLUMN deck SMALLINT,
+    ADD COLUMN row_no SMALLINT,
+    ADD COLUMN col_no SMALLINT,
+    ADD COLUMN seat_class VARCHAR(20);
+
+-- Mẫu mặc định: giữ nguyên 30 ghế A1-A15 (tầng dưới), B1-B15 (tầng trên) như trước.
+INSERT INTO seat_layouts (vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns)
+VALUES (0, 30, 'Mặc định 30 chỗ', 2, 5, 3, '{1,2}');
+INSERT INTO seat_layout_seats (layout_id, seat_name, deck, row_no, col_no)
+SELECT l.id, d.prefix || n, d.deck, (n - 1) / 3 + 1, (n - 1) % 3 + 1
+FROM seat_layouts l
+CROSS JOIN (VALUES (1, 'A'), (2, 'B')) AS d(deck, prefix)
+CROSS JOIN generate_series(1, 15) AS n
+WHERE l.vehicle_type_id = 0 AND l.seat_count = 30;
+
+-- Ghế ngồi 16 chỗ: 4 hàng x 4 ghế, lối đi giữa.
+INSERT INTO seat_layouts (vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns)
+VALUES (1, 16, 'Ghế ngồi 16 chỗ', 1, 4, 4, '{2}');
+INSERT INTO seat_layout_seats (layout_id, seat_name, deck, row_no, col_no)
+SELECT l.id, 'A' || n, 1, (n - 1) / 4 + 1, (n - 1) % 4 + 1
+FROM seat_layouts l
+CROSS JOIN generate_series(1, 16) AS n
+WHERE l.vehicle_type_id = 1 AND l.seat_count = 16;
+
+-- Ghế ngồi 45 chỗ: 10 hàng x 4 ghế (cột 3 là lối đi) và hàng cuối 5 ghế.
+INSERT INTO seat_layouts (vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns)
+VALUES (1, 45, 'Ghế ngồi 45 chỗ', 1, 11, 5, '{}');
+INSERT INTO seat_layout_seats (layout_id, seat_name, deck, row_no, col_no)
+SELECT l.id, 'A' || n, 1,
+       CASE WHEN n <= 40 THEN (n - 1) / 4 + 1 ELSE 11 END,
+       CASE WHEN n > 40 THEN n - 40 WHEN (n - 1) % 4 < 2 THEN (n - 1) % 4 + 1 ELSE (n - 1) % 4 + 2 END
+FROM seat_layouts l
+CROSS JOIN generate_series(1, 45) AS n
+WHERE l.vehicle_type_id = 1 AND l.seat_count = 45;
+
+-- Giường nằm 30 chỗ: 2 tầng x 5 hàng x 3 giường, 2 lối đi.
+INSERT INTO seat_layouts (vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns)
+VALUES (2, 30, 'Giường nằm 30 chỗ', 2, 5, 3, '{1,2}');
+INSERT INTO seat_layout_seats (layout_id, seat_name, deck, row_no, col_no, seat_class)
+SELECT l.id, d.prefix || n, d.deck, (n - 1) / 3 + 1, (n - 1) % 3 + 1, 'SLEEPER'
+FROM seat_layouts l
+CROSS JOIN (VALUES (1, 'A'), (2, 'B')) AS d(deck, prefix)
+CROSS JOIN generate_series(1, 15) AS n
+WHERE l.vehicle_type_id = 2 AND l.seat_count = 30;
+
+-- Limousine 9 chỗ: 3 hàng x 3 ghế VIP, lối đi sau cột 1.
+INSERT INTO seat_layouts (vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns)
+VALUES (3, 9, 'Limousine 9 chỗ', 1, 3, 3, '{1}');
+INSERT INTO seat_layout_seats (layout_id, seat_name, deck, row_no, col_no, seat_class)
+SELECT l.id, 'A' || n, 1, (n - 1) / 3 + 1, (n - 1) % 3 + 1, 'VIP'
+FROM seat_layouts l
+CROSS JOIN generate_series(1, 9) AS n
+WHERE l.vehicle_type_id = 3 AND l.seat_count = 9;
+
+-- 0005_seat_segments
+-- Ghế được giữ theo đoạn đường [from_stop, to_stop) thay vì cả chuyến.
+-- from_stop/to_stop là thứ tự điểm dừng dọc tuyến (1 = điểm đầu) do trip-service tính từ chuỗi pickup.self_id
+-- (trip.stops[].stopOrder), KHÔNG phải Pickup/Dropoff_Location của Ticket_Details (station id client gửi lên).
+-- Ticket-service quy đổi station id sang stopOrder theo điểm dừng của chuyến trước khi ghi.
+-- Vé cũ mặc định giữ ghế cả chuyến (0 -> 32767).
+ALTER TABLE seat_tickets
+    ADD COLUMN from_stop SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN to_stop SMALLINT NOT NULL DEFAULT 32767,
+    ADD CONSTRAINT seat_tickets_segment_check CHECK (from_stop < to_stop);
+
+CREATE INDEX idx_seat_tickets_seat_id_status ON seat_tickets(seat_id, status);
+
+-- 0006_offline_checkins
+-- Check-in offline: soát vé quét khi mất sóng rồi đồng bộ lên sau.
+-- checked_in_at giữ thời điểm quét gốc trên thiết bị, synced_at là lúc server nhận được.
+-- (device_id, client_scan_id) chống ghi trùng khi thiết bị gửi lại cùng một lượt quét.
+-- conflict ghi lý do khi lượt quét không thể áp dụng (vd: vé bị huỷ sau khi tải manifest), để nhân viên đối soát.
+ALTER TABLE checkins
+    ADD COLUMN source VARCHAR(16) NOT NULL DEFAULT 'online',
+    ADD COLUMN device_id VARCHAR(64),
+    ADD COLUMN client_scan_id VARCHAR(64),
+    ADD COLUMN synced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN conflict VARCHAR(32);
+
+CREATE UNIQUE INDEX idx_checkins_device_scan ON checkins(device_id, client_scan_id)
+    WHERE client_scan_id IS NOT NULL;
+CREATE INDEX idx_seat_tickets_trip_id_status ON seat_tickets(trip_id, status);
+
+-- 0007_trip_waitlist
+-- Danh sách chờ khi chuyến hết ghế. Thứ tự xếp hàng theo id (ai đăng ký trước được mời trước).
+-- status: 0 đang chờ, 1 đã được giữ ghế (chờ khách đặt), 2 đã đặt vé, 3 hết hạn giữ ghế, 4 khách rời danh sách.
+CREATE TABLE trip_waitlist (
+    id SERIAL PRIMARY KEY,
+    trip_id VARCHAR NOT NULL,
+    customer_id INT NOT NULL,
+    seat_count SMALLINT NOT NULL DEFAULT 1 CHECK (seat_count > 0),
+    from_stop SMALLINT NOT NULL DEFAULT 0,
+    to_stop SMALLINT NOT NULL DEFAULT 32767,
+    status SMALLINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT trip_waitlist_segment_check CHECK (from_stop < to_stop)
+);
+
+-- Mỗi khách chỉ có một lượt chờ còn hiệu lực trên một chuyến.
+CREATE UNIQUE INDEX idx_trip_waitlist_active ON trip_waitlist(trip_id, customer_id)
+    WHERE status IN (0, 1);
+CREATE INDEX idx_trip_waitlist_trip_status ON trip_waitlist(trip_id, status, id);
+
+-- Ghế được giữ cho khách trong danh sách chờ tới expires_at.
+-- status: 0 đang giữ, 1 khách đã đặt, 2 hết hạn, 3 khách rời danh sách.
+-- Ghế đang giữ được tính là đã có người trên đoạn [from_stop, to_stop) với mọi khách khác.
+CREATE TABLE waitlist_offers (
+    id SERIAL PRIMARY KEY,
+    waitlist_id INT NOT NULL REFERENCES trip_waitlist(id) ON DELETE CASCADE,
+    trip_id VARCHAR NOT NULL,
+    seat_id INT NOT NULL REFERENCES seats(id) ON DELETE CASCADE,
+    customer_id INT NOT NULL,
+    from_stop SMALLINT NOT NULL,
+    to_stop SMALLINT NOT NULL,
+    status SMALLINT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_waitlist_offers_seat_active ON waitlist_offers(seat_id) WHERE status = 0;
+CREATE INDEX idx_waitlist_offers_expiry ON waitlist_offers(expires_at) WHERE status = 0;
+CREATE INDEX idx_waitlist_offers_waitlist_id ON waitlist_offers(waitlist_id);
+
+-- 0008_group_bookings
+-- Đơn đặt vé theo đoàn (công ty, đoàn du lịch): nhiều vé, có thể trên nhiều chuyến, thanh toán bằng một hoá đơn.
+-- group_ref được dùng làm ticket_id của hoá đơn bên payment_service.
+CREATE TABLE ticket_groups (
+    group_ref VARCHAR(20) PRIMARY KEY,
+    customer_id INT,
+    organization_name VARCHAR(255),
+    contact_name VARCHAR(100) NOT NULL,
+    contact_phone VARCHAR(15) NOT NULL,
+    contact_email VARCHAR(100),
+    booked_by VARCHAR(50),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_ticket_groups_customer_id ON ticket_groups(customer_id);
+
+-- Các vé thuộc đơn đoàn, mỗi vé là một chiều (chuyến) của đoàn.
+CREATE TABLE ticket_group_members (
+    ticket_id VARCHAR(6) PRIMARY KEY REFERENCES Ticket(Ticket_Id) ON DELETE CASCADE,
+    group_ref VARCHAR(20) NOT NULL REFERENCES ticket_groups(group_ref) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_ticket_group_members_group_ref ON ticket_group_members(group_ref);
+
+-- Hành khách của từng ghế (vé đoàn); NULL thì dùng tên/số điện thoại người đặt trên Ticket.
+ALTER TABLE seat_tickets
+    ADD COLUMN passenger_name VARCHAR(100),
+    ADD COLUMN passenger_phone VARCHAR(15);
+
+-- 0009_ticket_status_history
+-- Lịch sử chuyển trạng thái của vé, thanh toán và từng ghế (xem models.ValidateTicketTransition).
+-- Các dòng log cũ (chỉ có Action) giữ nguyên, các cột mới NULL.
+ALTER TABLE Ticket_Logs
+    ADD COLUMN Entity VARCHAR(10),
+    ADD COLUMN Seat_Ticket_Id INT,
+    ADD COLUMN From_Status SMALLINT,
+    ADD COLUMN To_Status SMALLINT,
+    ADD COLUMN Actor VARCHAR(100),
+    ADD COLUMN Reason VARCHAR(255);
+
+CREATE INDEX idx_ticket_logs_ticket_id_created_at ON Ticket_Logs(Ticket_Id, Created_At);
+
+
+-- 0010_outbox_retry
+-- Poller dùng chung (shared/outbox): nhận event bằng FOR UPDATE SKIP LOCKED, retry với backoff luỹ thừa.
+ALTER TABLE outbox_events
+    ADD COLUMN attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN last_error TEXT;
+
+CREATE INDEX idx_outbox_events_next_attempt_at ON outbox_events(next_attempt_at, created_at);
+
+-- Event publish thất bại quá số lần cho phép, chờ admin replay hoặc huỷ.
+CREATE TABLE outbox_dead_letters (
+    id UUID PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_outbox_dead_letters_failed_at ON outbox_dead_letters(failed_at);
+
+
+-- 0011_booking_requests
+-- Trạng thái bền vững của các yêu cầu đặt vé bất đồng bộ (POST /initiate-booking -> Kafka -> BookingRequestConsumer).
+-- QUEUED -> PROCESSING -> COMPLETED | FAILED; EXPIRED khi client không theo dõi (WebSocket / poll) trước connect_deadline.
+CREATE TABLE booking_requests (
+    booking_id UUID PRIMARY KEY,
+    customer_id INT,
+    status VARCHAR(20) NOT NULL DEFAULT 'QUEUED'
+        CHECK (status IN ('QUEUED', 'PROCESSING', 'COMPLETED', 'FAILED', 'EXPIRED')),
+    request_data JSONB NOT NULL,
+    ticket_id VARCHAR(6) REFERENCES Ticket(Ticket_Id) ON DELETE SET NULL,
+    error_message TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    connect_deadline TIMESTAMPTZ NOT NULL,
+    connected_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- TimeoutWorker chỉ quét các yêu cầu chưa có client theo dõi và chưa kết thúc.
+CREATE INDEX idx_booking_requests_connect_deadline ON booking_requests(connect_deadline)
+    WHERE connected_at IS NULL AND status IN ('QUEUED', 'PROCESSING', 'COMPLETED');
+
+
+-- 0012_ticket_search_indexes
+-- Tìm kiếm vé cho nhân viên quầy (GET /tickets/search): keyset pagination theo (cột sắp xếp, Ticket_Id)
+-- và tra cứu email không phân biệt hoa thường.
+CREATE INDEX idx_ticket_booking_time_id ON Ticket(Booking_Time, Ticket_Id);
+CREATE INDEX idx_ticket_price_id ON Ticket(Price, Ticket_Id);
+CREATE INDEX idx_ticket_lower_email ON Ticket(lower(Email));
+
+
+-- 0013_trip_rebooking_offers
+-- Đổi chuyến miễn phí cho vé của chuyến bị dời giờ khởi hành (sự kiện trip_updated từ trip-service).
+-- Mỗi vé có một lượt đổi cho mỗi chuyến bị dời; used_at được ghi khi khách đổi ghế / đổi chuyến bằng lượt này.
+-- Chuyến bị dời thêm lần nữa thì lượt đổi được mở lại với giờ mới.
+CREATE TABLE trip_rebooking_offers (
+    ticket_id VARCHAR(6) NOT NULL REFERENCES Ticket(Ticket_Id) ON DELETE CASCADE,
+    trip_id VARCHAR NOT NULL,
+    old_departure TIMESTAMPTZ NOT NULL,
+    new_departure TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (ticket_id, trip_id)
+);
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Trạng thái lên xe của một ghế trong manifest điều độ.
+const (
+	BoardingStatusPendingPayment = "pending_payment"
+	BoardingStatusAwaiting       = "awaiting" // Đã xác nhận, chưa check-in
+	BoardingStatusCheckedIn      = "checked_in"
+	BoardingStatusNoShow         = "no_show"
+)
+
+// BoardingManifest là danh sách đầy đủ các ghế đã bán của chuyến cho điều độ viên.
+type BoardingManifest struct {
+	TripID      string              `json:"trip_id"`
+	Route       string              `json:"route,omitempty"`
+	DepartureAt *time.Time          `json:"departure_at,omitempty"`
+	GeneratedAt time.Time           `json:"generated_at"`
+	Summary     BoardingSummary     `json:"summary"`
+	Passengers  []BoardingPassenger `json:"passengers"`
+}
+
+type BoardingSummary struct {
+	Total          int `json:"total"`
+	CheckedIn      int `json:"checked_in"`
+	Awaiting       int `json:"awaiting"`
+	NoShow         int `json:"no_show"`
+	PendingPayment int `json:"pending_payment"`
+}
+
+type BoardingPassenger struct {
+	SeatName       string     `json:"seat_name"`
+	TicketID       string     `json:"ticket_id"`
+	PassengerName  string     `json:"passenger_name"`
+	Phone          string     `json:"phone"`
+	PickupStop     *int16     `json:"pickup_stop"`  // nil: điểm đầu tuyến
+	DropoffStop    *int16     `json:"dropoff_stop"` // nil: điểm cuối tuyến
+	PaymentStatus  string     `json:"payment_status"`
+	BoardingStatus string     `json:"boarding_status"`
+	CheckedInAt    *time.Time `json:"checked_in_at,omitempty"`
+}
+
+// BoardingStatusOf đổi trạng thái seat_tickets sang trạng thái lên xe.
+func BoardingStatusOf(seatStatus int16) string {
+	switch seatStatus {
+	case SeatStatusCheckedIn:
+		return BoardingStatusCheckedIn
+	case SeatStatusMissed:
+		return BoardingStatusNoShow
+	case SeatStatusConfirmed:
+		return BoardingStatusAwaiting
+	}
+	return BoardingStatusPendingPayment
+}
+
+// PaymentStatusLabel trả về tên trạng thái thanh toán của vé.
+func PaymentStatusLabel(status int16) string {
+	switch status {
+	case PaymentStatusPending:
+		return "pending"
+	case PaymentStatusPaid:
+		return "paid"
+	case PaymentStatusFailed:
+		return "failed"
+	case PaymentStatusRefundPending:
+		return "refund_pending"
+	case PaymentStatusRefunded:
+		return "refunded"
+	}
+	return "unknown"
+}
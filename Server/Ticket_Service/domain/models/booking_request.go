@@ -0,0 +1,54 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Trạng thái yêu cầu đặt vé bất đồng bộ (booking_requests.status)
+const (
+	BookingRequestQueued     = "QUEUED"     // Đã nhận, chờ BookingRequestConsumer xử lý
+	BookingRequestProcessing = "PROCESSING" // Consumer đang tạo vé
+	BookingRequestCompleted  = "COMPLETED"  // Đã tạo vé (ticket_id)
+	BookingRequestFailed     = "FAILED"     // Không tạo được vé (error_message)
+	BookingRequestExpired    = "EXPIRED"    // Client không theo dõi kết quả kịp thời, vé (nếu có) đã bị huỷ
+)
+
+var (
+	// ErrBookingRequestNotFound được trả về khi bookingId không tồn tại.
+	ErrBookingRequestNotFound = errors.New("booking request not found")
+	// ErrBookingRequestExpired được trả về khi client bắt đầu theo dõi sau hạn kết nối.
+	ErrBookingRequestExpired = errors.New("booking request expired")
+	// ErrBookingRequestClosed được trả về khi yêu cầu không còn chờ xử lý (đã hết hạn hoặc đã có kết quả);
+	// vé tạo cho yêu cầu đó bị rollback cùng transaction.
+	ErrBookingRequestClosed = errors.New("booking request is no longer open")
+)
+
+// BookingRequestStatus là trạng thái một yêu cầu đặt vé, trả về cho GET /bookings/:bookingId
+// và dùng làm kết quả gửi qua WebSocket.
+type BookingRequestStatus struct {
+	BookingID       string               `json:"booking_id"`
+	Status          string               `json:"status"`
+	CustomerID      sql.NullInt32        `json:"-"`
+	TicketID        string               `json:"ticket_id,omitempty"`
+	Error           string               `json:"error,omitempty"`
+	Ticket          *TicketReturn        `json:"ticket,omitempty"` // Chỉ có khi COMPLETED
+	Queue           *WaitingRoomPosition `json:"queue,omitempty"`  // Chỉ có khi đang xếp hàng trong phòng chờ của chuyến
+	ConnectDeadline time.Time            `json:"connect_deadline"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
+}
+
+// Finished cho biết yêu cầu đã có kết quả cuối cùng.
+func (s *BookingRequestStatus) Finished() bool {
+	return s.Status == BookingRequestCompleted || s.Status == BookingRequestFailed || s.Status == BookingRequestExpired
+}
+
+// WaitingRoomPosition là vị trí của một yêu cầu đặt vé trong phòng chờ của chuyến (pkg/waitingroom).
+type WaitingRoomPosition struct {
+	TripID        string `json:"trip_id"`
+	Position      int64  `json:"position"`               // Bắt đầu từ 1
+	QueueLength   int64  `json:"queue_length"`           // Tổng số yêu cầu đang chờ của chuyến
+	EstimatedWait int64  `json:"estimated_wait_seconds"` // Ước lượng theo tốc độ đưa vào xử lý
+}
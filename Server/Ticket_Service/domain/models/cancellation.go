@@ -0,0 +1,53 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TicketActor là người thực hiện thao tác trên vé (huỷ, đổi ghế...).
+type TicketActor struct {
+	Phone string `json:"phone,omitempty"` // Bắt buộc với khách không đăng nhập (không có X-User-ID)
+
+	// Các trường dưới đây do controller điền từ header, không nhận từ body
+	CustomerID sql.NullInt32 `json:"-"`
+	IsStaff    bool          `json:"-"`
+	Actor      string        `json:"-"`
+}
+
+// CanModify kiểm tra quyền: nhân viên, khách sở hữu vé (X-User-ID) hoặc khách vãng lai có đúng số điện thoại.
+func (a *TicketActor) CanModify(ticket *TicketReturn) bool {
+	if a.IsStaff {
+		return true
+	}
+	if a.CustomerID.Valid && ticket.CustomerID.Valid && a.CustomerID.Int32 == ticket.CustomerID.Int32 {
+		return true
+	}
+	return a.Phone != "" && ticket.Phone.Valid && a.Phone == ticket.Phone.String
+}
+
+// CancelTicketRequest là body của POST /tickets/:id/cancel.
+type CancelTicketRequest struct {
+	TicketActor
+	Reason string `json:"reason,omitempty"`
+}
+
+// RefundQuote là kết quả áp dụng refund rule của policy theo thời gian còn lại trước giờ khởi hành.
+type RefundQuote struct {
+	PolicyID             int32     `json:"policy_id"`
+	DepartureAt          time.Time `json:"departure_at"`
+	HoursBeforeDeparture float64   `json:"hours_before_departure"`
+	RuleMinHours         int32     `json:"rule_min_hours"`
+	RefundPercent        float64   `json:"refund_percent"`
+	PaidAmount           float64   `json:"paid_amount"`
+	RefundAmount         float64   `json:"refund_amount"`
+}
+
+// CancellationResult trả về cho client sau khi huỷ vé.
+type CancellationResult struct {
+	TicketID        string       `json:"ticket_id"`
+	Status          int16        `json:"status"`
+	PaymentStatus   int16        `json:"payment_status"`
+	Refund          *RefundQuote `json:"refund"`
+	RefundRequested bool         `json:"refund_requested"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// FareLeg là giá của một chiều (trip) trong vé.
+type FareLeg struct {
+	TripID            string  `json:"trip_id"`
+	SeatCount         int     `json:"seat_count"`
+	BaseFare          float64 `json:"base_fare"`                     // Giá tuyến cho một ghế
+	SpecialDayName    string  `json:"special_day_name,omitempty"`    // Tên ngày đặc biệt (nếu có)
+	SpecialDayPercent int     `json:"special_day_percent,omitempty"` // % phụ thu ngày đặc biệt
+	UnitFare          float64 `json:"unit_fare"`                     // Giá một ghế sau phụ thu
+	Subtotal          float64 `json:"subtotal"`                      // UnitFare * SeatCount
+}
+
+// FareBreakdown is the server-computed price of a ticket. It is stored on the
+// ticket (Ticket.Fare_Breakdown) so the charged amount can always be explained.
+type FareBreakdown struct {
+	Legs                     []FareLeg `json:"legs"`
+	GrossAmount              float64   `json:"gross_amount"`
+	PolicyID                 int32     `json:"policy_id"`
+	PolicyName               string    `json:"policy_name,omitempty"`
+	PolicyDiscountPercent    float64   `json:"policy_discount_percent"`
+	PolicyDiscount           float64   `json:"policy_discount"`
+	RoundTripDiscountPercent float64   `json:"round_trip_discount_percent"`
+	RoundTripDiscount        float64   `json:"round_trip_discount"`
+	Total                    float64   `json:"total"`
+	ClientPrice              float64   `json:"client_price"` // Giá client gửi lên, chỉ để đối chiếu
+	Currency                 string    `json:"currency"`
+	// Các lần điều chỉnh giá sau khi đặt (đổi ghế/chuyến)
+	Adjustments []FareAdjustment `json:"adjustments,omitempty"`
+}
+
+// FareAdjustment ghi lại chênh lệch giá khi đổi ghế/chuyến.
+type FareAdjustment struct {
+	Reason    string    `json:"reason"`
+	Amount    float64   `json:"amount"` // > 0: thu thêm, < 0: hoàn lại
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const FareCurrencyVND = "VND"
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// GroupRefPrefix là tiền tố mã đơn đoàn, phân biệt với mã vé (6 ký tự) khi payment_service gửi kết quả thanh toán.
+const GroupRefPrefix = "GRP-"
+
+// MaxGroupPassengersPerLeg là số hành khách tối đa của đơn đoàn trên một chuyến.
+const MaxGroupPassengersPerLeg = 40
+
+// IsGroupRef cho biết mã thanh toán là mã đơn đoàn (thay vì mã vé).
+func IsGroupRef(ref string) bool {
+	return strings.HasPrefix(ref, GroupRefPrefix)
+}
+
+// GroupPassenger là hành khách ngồi một ghế trong đơn đoàn.
+type GroupPassenger struct {
+	SeatID int32  `json:"seat_id" binding:"required"`
+	Name   string `json:"name" binding:"required,max=100"`
+	Phone  string `json:"phone,omitempty" binding:"max=15"`
+}
+
+// GroupBookingLeg là một chuyến của đoàn; mỗi chiều được xuất thành một vé một chiều.
+// Điểm đón/trả <= 0 nghĩa là đi cả chuyến.
+type GroupBookingLeg struct {
+	TripID          string           `json:"trip_id" binding:"required"`
+	PickupLocation  int32            `json:"pickup_location,omitempty"`
+	DropoffLocation int32            `json:"dropoff_location,omitempty"`
+	Passengers      []GroupPassenger `json:"passengers" binding:"required,min=1,dive"`
+}
+
+// Segment trả về đoạn đường giữ ghế của chiều này theo điểm dừng của chuyến.
+func (l *GroupBookingLeg) Segment(trip *TripInfo) (SeatSegment, error) {
+	return trip.SeatSegment(l.PickupLocation, l.DropoffLocation)
+}
+
+// SeatIDs trả về các ghế của chiều theo thứ tự hành khách.
+func (l *GroupBookingLeg) SeatIDs() []int32 {
+	seatIDs := make([]int32, 0, len(l.Passengers))
+	for _, p := range l.Passengers {
+		seatIDs = append(seatIDs, p.SeatID)
+	}
+	return seatIDs
+}
+
+// CreateGroupBookingRequest là body của POST /group-bookings.
+// Người liên hệ nhận email/QR của cả đoàn và là người thanh toán hoá đơn chung.
+type CreateGroupBookingRequest struct {
+	OrganizationName string            `json:"organization_name,omitempty" binding:"max=255"`
+	ContactName      string            `json:"contact_name" binding:"required,max=100"`
+	ContactPhone     string            `json:"contact_phone" binding:"required,max=15"`
+	ContactEmail     string            `json:"contact_email,omitempty" binding:"omitempty,email,max=100"`
+	PolicyID         int32             `json:"policy_id"`
+	BookingChannel   int16             `json:"booking_channel"`
+	Legs             []GroupBookingLeg `json:"legs" binding:"required,min=1,max=10,dive"`
+
+	// Do controller điền từ header, không nhận từ body
+	Actor TicketActor `json:"-"`
+}
+
+// GroupBooking là đơn đoàn kèm các vé và hành khách, trả về cho client.
+// GroupRef được dùng làm ticket_id khi tạo hoá đơn ở payment_service.
+type GroupBooking struct {
+	GroupRef         string        `json:"group_ref"`
+	OrganizationName string        `json:"organization_name,omitempty"`
+	ContactName      string        `json:"contact_name"`
+	ContactPhone     string        `json:"contact_phone"`
+	ContactEmail     string        `json:"contact_email,omitempty"`
+	BookedBy         string        `json:"booked_by,omitempty"`
+	TotalPrice       float64       `json:"total_price"` // Tổng giá các vé còn hiệu lực
+	PassengerCount   int           `json:"passenger_count"`
+	CreatedAt        time.Time     `json:"created_at"`
+	Tickets          []GroupTicket `json:"tickets"`
+}
+
+// GroupTicket là một vé (một chuyến) trong đơn đoàn.
+type GroupTicket struct {
+	TicketID      string               `json:"ticket_id"`
+	TripID        string               `json:"trip_id"`
+	Status        int16                `json:"status"`
+	PaymentStatus int16                `json:"payment_status"`
+	Price         float64              `json:"price"`
+	Passengers    []GroupPassengerSeat `json:"passengers"`
+}
+
+// GroupPassengerSeat là hành khách trên một ghế của vé đoàn; SeatTicketID dùng để huỷ riêng hành khách.
+type GroupPassengerSeat struct {
+	SeatTicketID int32  `json:"seat_ticket_id"`
+	SeatID       int32  `json:"seat_id"`
+	SeatName     string `json:"seat_name"`
+	Name         string `json:"name"`
+	Phone        string `json:"phone,omitempty"`
+	Status       int16  `json:"status"`
+}
+
+// CancelGroupPassengerRequest là body của POST /group-bookings/:groupRef/passengers/:seatTicketId/cancel.
+type CancelGroupPassengerRequest struct {
+	TicketActor
+	Reason string `json:"reason,omitempty"`
+}
+
+// GroupPassengerCancellation trả về cho client sau khi huỷ một hành khách của đoàn.
+type GroupPassengerCancellation struct {
+	GroupRef        string       `json:"group_ref"`
+	TicketID        string       `json:"ticket_id"`
+	SeatTicketID    int32        `json:"seat_ticket_id"`
+	SeatID          int32        `json:"seat_id"`
+	TicketStatus    int16        `json:"ticket_status"`
+	TicketPrice     float64      `json:"ticket_price"` // Giá vé sau khi bớt hành khách
+	Refund          *RefundQuote `json:"refund"`
+	RefundRequested bool         `json:"refund_requested"`
+}
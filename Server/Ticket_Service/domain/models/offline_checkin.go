@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// Kết quả xử lý một lượt quét offline khi thiết bị soát vé đồng bộ lên.
+const (
+	OfflineScanAccepted  = "accepted"  // Check-in được ghi nhận
+	OfflineScanDuplicate = "duplicate" // Đã check-in trước đó (quét trùng hoặc gửi lại), giữ bản ghi đầu tiên
+	OfflineScanConflict  = "conflict"  // Hành khách đã lên xe nhưng vé không còn hợp lệ, cần nhân viên đối soát
+	OfflineScanRejected  = "rejected"  // QR giả, hết hạn, sai chuyến hoặc không tồn tại
+	OfflineScanRetry     = "retry"     // Lỗi tạm thời, thiết bị giữ lại lượt quét và gửi lại sau
+)
+
+// Lý do đi kèm kết quả; các lý do conflict cũng được lưu vào checkins.conflict.
+const (
+	OfflineReasonAlreadySynced          = "already_synced"
+	OfflineReasonAlreadyCheckedIn       = "already_checked_in"
+	OfflineReasonCancelledAfterSnapshot = "cancelled_after_snapshot"
+	OfflineReasonTicketCancelled        = "ticket_cancelled"
+	OfflineReasonPendingPayment         = "pending_payment"
+	OfflineReasonInvalidQR              = "invalid_qr"
+	OfflineReasonQRExpired              = "qr_expired"
+	OfflineReasonWrongTrip              = "wrong_trip"
+	OfflineReasonNotFound               = "not_found"
+	OfflineReasonScanInFuture           = "scan_in_future"
+	OfflineReasonInternalError          = "internal_error"
+)
+
+// TripManifest là ảnh chụp danh sách ghế đã xác nhận của chuyến để soát vé khi mất sóng.
+type TripManifest struct {
+	TripID      string          `json:"trip_id"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Entries     []ManifestEntry `json:"entries"`
+}
+
+type ManifestEntry struct {
+	SeatTicketID  int32       `json:"seat_ticket_id"`
+	SeatID        int32       `json:"seat_id"`
+	SeatName      string      `json:"seat_name"`
+	TicketID      string      `json:"ticket_id"`
+	PassengerName string      `json:"passenger_name"`
+	PhoneSuffix   string      `json:"phone_suffix"` // Chỉ 3 số cuối để đối chiếu, không lộ cả số điện thoại
+	Segment       SeatSegment `json:"segment"`
+	CheckedIn     bool        `json:"checked_in"`
+}
+
+// SignedTripManifest kèm chữ ký HMAC của manifest (ký trên JSON của trường manifest).
+type SignedTripManifest struct {
+	Manifest  TripManifest `json:"manifest"`
+	KeyID     string       `json:"key_id"`
+	Signature string       `json:"signature"`
+}
+
+// OfflineCheckinBatchRequest là lô lượt quét offline thiết bị gửi lên khi có sóng trở lại.
+type OfflineCheckinBatchRequest struct {
+	TripID              string        `json:"trip_id" binding:"required"`
+	DeviceID            string        `json:"device_id" binding:"required,max=64"`
+	ManifestGeneratedAt time.Time     `json:"manifest_generated_at"` // generated_at của manifest đã dùng để soát
+	Scans               []OfflineScan `json:"scans" binding:"required,min=1,max=500,dive"`
+}
+
+type OfflineScan struct {
+	ClientScanID string    `json:"client_scan_id" binding:"required,max=64"` // Mã lượt quét do thiết bị sinh, dùng để gửi lại an toàn
+	QRContent    string    `json:"qr_content" binding:"required"`
+	ScannedAt    time.Time `json:"scanned_at" binding:"required"`
+}
+
+type OfflineScanResult struct {
+	ClientScanID string     `json:"client_scan_id"`
+	TicketID     string     `json:"ticket_id,omitempty"`
+	SeatName     string     `json:"seat_name,omitempty"`
+	Outcome      string     `json:"outcome"`
+	Reason       string     `json:"reason,omitempty"`
+	CheckedInAt  *time.Time `json:"checked_in_at,omitempty"`
+}
+
+type OfflineCheckinBatchResponse struct {
+	TripID     string              `json:"trip_id"`
+	Accepted   int                 `json:"accepted"`
+	Duplicates int                 `json:"duplicates"`
+	Conflicts  int                 `json:"conflicts"`
+	Rejected   int                 `json:"rejected"`
+	Retry      int                 `json:"retry"`
+	Results    []OfflineScanResult `json:"results"`
+}
@@ -78,10 +78,11 @@ const (
 // Constants for PaymentStatus
 // Ensure these values are consistent with your database schema and application logic.
 const (
-	PaymentStatusPending int16 = 0 // Payment is initiated but not yet confirmed
-	PaymentStatusPaid    int16 = 1 // Payment is successfully completed
-	PaymentStatusFailed  int16 = 2 // Payment failed or was cancelled
-	// Add other statuses like REFUNDED if needed
+	PaymentStatusPending       int16 = 0 // Payment is initiated but not yet confirmed
+	PaymentStatusPaid          int16 = 1 // Payment is successfully completed
+	PaymentStatusFailed        int16 = 2 // Payment failed or was cancelled
+	PaymentStatusRefundPending int16 = 3 // Ticket cancelled, refund requested from payment_service
+	PaymentStatusRefunded      int16 = 4 // payment_service confirmed the refund
 )
 
 // Constants for TicketStatus
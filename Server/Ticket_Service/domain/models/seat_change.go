@@ -0,0 +1,33 @@
+package models
+
+// Chiều của vé được đổi ghế
+const (
+	TicketLegBegin = "begin" // Chiều đi
+	TicketLegEnd   = "end"   // Chiều về (vé khứ hồi)
+)
+
+// ChangeSeatsRequest là body của POST /tickets/:id/change-seats.
+// NewTripID để trống nghĩa là đổi ghế trên cùng chuyến.
+type ChangeSeatsRequest struct {
+	TicketActor
+	Leg        string  `json:"leg,omitempty"` // "begin" (mặc định) hoặc "end"
+	NewTripID  string  `json:"new_trip_id,omitempty"`
+	NewSeatIDs []int32 `json:"new_seat_ids" binding:"required,min=1"`
+	Reason     string  `json:"reason,omitempty"`
+}
+
+// ChangeSeatsResult trả về cho client sau khi đổi ghế/chuyến.
+type ChangeSeatsResult struct {
+	TicketID            string         `json:"ticket_id"`
+	Leg                 string         `json:"leg"`
+	OldTripID           string         `json:"old_trip_id"`
+	NewTripID           string         `json:"new_trip_id"`
+	OldSeatIDs          []int32        `json:"old_seat_ids"`
+	NewSeatIDs          []int32        `json:"new_seat_ids"`
+	OldPrice            float64        `json:"old_price"`
+	NewPrice            float64        `json:"new_price"`
+	FareDifference      float64        `json:"fare_difference"` // > 0: khách trả thêm, < 0: hoàn lại
+	AdjustmentRequested bool           `json:"adjustment_requested"`
+	FreeRebooking       bool           `json:"free_rebooking,omitempty"` // Đổi miễn phí do chuyến cũ bị dời giờ
+	Fare                *FareBreakdown `json:"fare"`
+}
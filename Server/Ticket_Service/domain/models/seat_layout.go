@@ -0,0 +1,65 @@
+package models
+
+// Hạng ghế trong sơ đồ xe
+const (
+	SeatClassStandard = "STANDARD"
+	SeatClassVIP      = "VIP"
+	SeatClassSleeper  = "SLEEPER"
+)
+
+// DefaultVehicleTypeID là loại xe của mẫu sơ đồ mặc định (dùng khi loại xe chưa có mẫu riêng).
+const DefaultVehicleTypeID = 0
+
+// LayoutSeat là vị trí một ghế trong mẫu sơ đồ.
+type LayoutSeat struct {
+	Name  string `json:"name" binding:"required,max=10"`
+	Deck  int16  `json:"deck" binding:"required,min=1"`
+	Row   int16  `json:"row" binding:"required,min=1"`
+	Col   int16  `json:"col" binding:"required,min=1"`
+	Class string `json:"class,omitempty" binding:"omitempty,oneof=STANDARD VIP SLEEPER"`
+}
+
+// SeatLayout là mẫu sơ đồ ghế theo loại xe: số tầng, số hàng, số cột và vị trí lối đi.
+type SeatLayout struct {
+	ID                int32        `json:"id"`
+	VehicleTypeID     int32        `json:"vehicle_type_id"`
+	SeatCount         int32        `json:"seat_count"`
+	Name              string       `json:"name"`
+	Decks             int16        `json:"decks"`
+	Rows              int16        `json:"rows"`
+	Columns           int16        `json:"columns"`
+	AisleAfterColumns []int32      `json:"aisle_after_columns"`
+	Seats             []LayoutSeat `json:"seats,omitempty"`
+}
+
+// UpsertSeatLayoutRequest là body tạo/thay thế mẫu sơ đồ ghế (admin).
+// Mẫu được xác định bởi vehicle_type_id và số ghế (len(seats)).
+type UpsertSeatLayoutRequest struct {
+	VehicleTypeID     int32        `json:"vehicle_type_id" binding:"min=0"`
+	Name              string       `json:"name" binding:"required,max=100"`
+	Decks             int16        `json:"decks" binding:"required,min=1,max=2"`
+	Rows              int16        `json:"rows" binding:"required,min=1"`
+	Columns           int16        `json:"columns" binding:"required,min=1"`
+	AisleAfterColumns []int32      `json:"aisle_after_columns"`
+	Seats             []LayoutSeat `json:"seats" binding:"required,min=1,dive"`
+}
+
+// TripSeat là một ghế của chuyến trên sơ đồ, kèm trạng thái còn trống trên đoạn đường được hỏi.
+type TripSeat struct {
+	ID        int32  `json:"id"`
+	Name      string `json:"name"`
+	Deck      int16  `json:"deck,omitempty"`
+	Row       int16  `json:"row,omitempty"`
+	Col       int16  `json:"col,omitempty"`
+	Class     string `json:"class,omitempty"`
+	Available bool   `json:"available"`
+}
+
+// TripSeatMap là sơ đồ ghế của một chuyến để client vẽ.
+// Layout rỗng với các chuyến tạo ghế trước khi có mẫu sơ đồ.
+type TripSeatMap struct {
+	TripID  string      `json:"trip_id"`
+	Segment SeatSegment `json:"segment"` // Đoạn đường dùng để tính trạng thái còn trống
+	Layout  *SeatLayout `json:"layout,omitempty"`
+	Seats   []TripSeat  `json:"seats"`
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Thứ tự điểm dừng dọc tuyến: 1 là điểm đầu, tăng dần theo hành trình (TripStop.StopOrder do trip-service tính
+// từ chuỗi pickup.self_id). Client gửi điểm đón/trả là station id nên phải quy đổi qua TripInfo.SeatSegment.
+// Đoạn [TripStartStop, TripEndStop) là cả chuyến, dùng cho vé cũ và khi client không gửi điểm đón/trả.
+const (
+	TripStartStop = 0
+	TripEndStop   = math.MaxInt16
+)
+
+var (
+	ErrInvalidSeatSegment = errors.New("pickup stop must come before dropoff stop")
+	ErrUnknownStop        = errors.New("pickup/dropoff location is not a stop of this trip")
+)
+
+// SeatSegment là đoạn đường [FromStop, ToStop) mà một ghế được giữ.
+// Hai vé cùng ghế không xung đột nếu đoạn của chúng không giao nhau (A->B và B->C).
+type SeatSegment struct {
+	FromStop int16 `json:"from_stop"`
+	ToStop   int16 `json:"to_stop"`
+}
+
+// FullTripSegment là đoạn cả chuyến.
+var FullTripSegment = SeatSegment{FromStop: TripStartStop, ToStop: TripEndStop}
+
+// NewSeatSegment tạo đoạn từ thứ tự điểm dừng đã quy đổi; giá trị <= 0 nghĩa là từ đầu tuyến / đến cuối tuyến.
+func NewSeatSegment(fromStop, toStop int16) (SeatSegment, error) {
+	segment := FullTripSegment
+	if fromStop > 0 {
+		segment.FromStop = fromStop
+	}
+	if toStop > 0 {
+		segment.ToStop = toStop
+	}
+	if segment.FromStop >= segment.ToStop {
+		return SeatSegment{}, fmt.Errorf("from stop %d, to stop %d: %w", fromStop, toStop, ErrInvalidSeatSegment)
+	}
+	return segment, nil
+}
+
+// IsFullTrip cho biết đoạn có phủ cả chuyến không.
+func (s SeatSegment) IsFullTrip() bool {
+	return s.FromStop <= TripStartStop && s.ToStop >= TripEndStop
+}
+
+// Overlaps cho biết hai đoạn có dùng chung ít nhất một chặng không.
+func (s SeatSegment) Overlaps(other SeatSegment) bool {
+	return s.FromStop < other.ToStop && other.FromStop < s.ToStop
+}
+
+// String dùng làm field của cache ghế trống theo đoạn.
+func (s SeatSegment) String() string {
+	return fmt.Sprintf("%d-%d", s.FromStop, s.ToStop)
+}
+
+// TripStop là một điểm dừng của chuyến theo thứ tự hành trình, trip-service trả về trong TripInfo.Stops.
+type TripStop struct {
+	StopOrder   int16  `json:"stopOrder"` // 1 là điểm đầu
+	PickupID    string `json:"pickupId"`
+	StationID   int32  `json:"stationId"` // Giá trị client gửi làm điểm đón/trả (id trong fullRoute)
+	StationName string `json:"stationName,omitempty"`
+}
+
+// SeatSegment quy đổi điểm đón/trả client gửi (station id, <= 0 là từ đầu / đến cuối tuyến) sang đoạn theo
+// thứ tự điểm dừng của chuyến. Điểm trả được tìm sau điểm đón vì một bến có thể xuất hiện nhiều lần trên tuyến.
+// Chuyến chưa có danh sách điểm dừng được giữ ghế cả chuyến để không bán trùng chặng.
+func (t *TripInfo) SeatSegment(pickupLocation, dropoffLocation int32) (SeatSegment, error) {
+	if len(t.Stops) == 0 || (pickupLocation <= 0 && dropoffLocation <= 0) {
+		return FullTripSegment, nil
+	}
+
+	fromStop, toStop := int16(TripStartStop), int16(TripEndStop)
+	if pickupLocation > 0 {
+		stop, ok := t.stopAfter(pickupLocation, TripStartStop)
+		if !ok {
+			return SeatSegment{}, fmt.Errorf("trip %s, pickup %d: %w", t.ID, pickupLocation, ErrUnknownStop)
+		}
+		fromStop = stop.StopOrder
+	}
+	if dropoffLocation > 0 {
+		stop, ok := t.stopAfter(dropoffLocation, fromStop)
+		if !ok {
+			if _, onTrip := t.stopAfter(dropoffLocation, TripStartStop); onTrip {
+				return SeatSegment{}, fmt.Errorf("trip %s, pickup %d, dropoff %d: %w", t.ID, pickupLocation, dropoffLocation, ErrInvalidSeatSegment)
+			}
+			return SeatSegment{}, fmt.Errorf("trip %s, dropoff %d: %w", t.ID, dropoffLocation, ErrUnknownStop)
+		}
+		toStop = stop.StopOrder
+	}
+	return NewSeatSegment(fromStop, toStop)
+}
+
+// stopAfter trả về điểm dừng đầu tiên của bến stationID có thứ tự lớn hơn afterStop.
+func (t *TripInfo) stopAfter(stationID int32, afterStop int16) (TripStop, bool) {
+	for _, stop := range t.Stops {
+		if stop.StationID == stationID && stop.StopOrder > afterStop {
+			return stop, true
+		}
+	}
+	return TripStop{}, false
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"ticket-service/internal/db"
 	"time"
 )
@@ -32,6 +34,16 @@ type TicketInput struct {
 	DropoffLocationEnd int32   `json:"dropoff_location_end,omitempty"`
 }
 
+// SegmentBegin trả về đoạn đường giữ ghế của chiều đi theo điểm dừng của chuyến đi.
+func (in *TicketInput) SegmentBegin(trip *TripInfo) (SeatSegment, error) {
+	return trip.SeatSegment(in.PickupLocationBegin, in.DropoffLocationBegin)
+}
+
+// SegmentEnd trả về đoạn đường giữ ghế của chiều về (vé khứ hồi) theo điểm dừng của chuyến về.
+func (in *TicketInput) SegmentEnd(trip *TripInfo) (SeatSegment, error) {
+	return trip.SeatSegment(in.PickupLocationEnd, in.DropoffLocationEnd)
+}
+
 type TicketInfoInput struct {
 	Phone    string `json:"phone,omitempty"`
 	TicketID string `json:"ticket_id"`
@@ -39,42 +51,51 @@ type TicketInfoInput struct {
 
 // TicketReturn defines the structure for returning ticket data to the client.
 type TicketReturn struct {
-	TicketID         string         `json:"ticket_id"`
-	Type             int16          `json:"type"`
-	TripIDBegin      string         `json:"trip_id_begin"`
-	TripIDEnd        sql.NullString `json:"trip_id_end,omitempty"`
-	CustomerID       sql.NullInt32  `json:"customer_id"`
-	Phone            sql.NullString `json:"phone,omitempty"`
-	Email            sql.NullString `json:"email,omitempty"`
-	Name             sql.NullString `json:"name"`
-	Price            float64        `json:"price"`
-	Status           int16          `json:"status"`
-	BookingTime      time.Time      `json:"booking_time"`
-	PaymentStatus    int16          `json:"payment_status"`
-	BookingChannel   int16          `json:"booking_channel"`
-	CreatedAt        time.Time      `json:"created_at"`
-	UpdatedAt        time.Time      `json:"updated_at"`
-	PolicyID         int32          `json:"policy_id"`
-	BookedBy         sql.NullString `json:"booked_by"`
+	TicketID         string          `json:"ticket_id"`
+	Type             int16           `json:"type"`
+	TripIDBegin      string          `json:"trip_id_begin"`
+	TripIDEnd        sql.NullString  `json:"trip_id_end,omitempty"`
+	CustomerID       sql.NullInt32   `json:"customer_id"`
+	Phone            sql.NullString  `json:"phone,omitempty"`
+	Email            sql.NullString  `json:"email,omitempty"`
+	Name             sql.NullString  `json:"name"`
+	Price            float64         `json:"price"`
+	Status           int16           `json:"status"`
+	BookingTime      time.Time       `json:"booking_time"`
+	PaymentStatus    int16           `json:"payment_status"`
+	BookingChannel   int16           `json:"booking_channel"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+	PolicyID         int32           `json:"policy_id"`
+	BookedBy         sql.NullString  `json:"booked_by"`
+	FareBreakdown    json.RawMessage `json:"fare_breakdown,omitempty"`
 	Details          []db.TicketDetail
 	SeatTicketsBegin []db.GetSeatTicketsByTicketIDRow
 	SeatTicketsEnd   []db.GetSeatTicketsByTicketIDRow // Updated to use the generated row struct
 	TripDetails      *TripInfo                        `json:"trip_details,omitempty"`
+	TripDetailsEnd   *TripInfo                        `json:"trip_details_end,omitempty"` // Chuyến về của vé khứ hồi
+	Invoice          *TicketInvoice                   `json:"invoice,omitempty"`          // Chỉ có trong kết quả tìm kiếm vé
 }
 
 // VehicleInfo corresponds to the Java Vehicle entity (customize fields as needed)
 type VehicleInfo struct {
-	ID           int    `json:"id"`
-	LicensePlate string `json:"licensePlate,omitempty"` // Example field
-	Type         string `json:"type,omitempty"`         // Example field
-	// Add other relevant fields from your Java Vehicle entity
+	ID           int              `json:"id"`
+	LicensePlate string           `json:"license,omitempty"`
+	SeatNumber   int              `json:"seat_number,omitempty"`
+	Type         *VehicleTypeInfo `json:"type,omitempty"` // Java Vehicle.type là object Type {id, name}
+}
+
+// VehicleTypeInfo corresponds to the Java Type entity (loại xe)
+type VehicleTypeInfo struct {
+	ID   int    `json:"id"`
+	Name string `json:"name,omitempty"`
 }
 
 // SpecialDayInfo corresponds to the Java SpecialDay entity (customize fields as needed)
 type SpecialDayInfo struct {
-	ID   int    `json:"id"`
-	Name string `json:"name,omitempty"` // Example field
-	// Add other relevant fields
+	ID      int    `json:"id"`
+	Name    string `json:"name,omitempty"`
+	Percent int    `json:"percent"` // Phụ thu theo ngày lễ, tính theo % giá tuyến
 }
 
 // RouteInfo corresponds to the Java Route entity (customize fields as needed)
@@ -84,12 +105,12 @@ type RouteInfo struct {
 	Destination   string `json:"destination,omitempty"`   // Example field
 	DepartureStop string `json:"departureStop,omitempty"` // Example field
 	ArrivalStop   string `json:"arrivalStop,omitempty"`   // Example field
-	// Add other relevant fields
+	Price         int    `json:"price"`                   // Giá vé gốc của tuyến (VND / ghế)
 }
 
 // TripInfo is the Go representation of the Trip data from trip-service
 type TripInfo struct {
-	ID            string          `json:"id"`            // Changed to string to match Trip_Id format
+	ID            json.Number     `json:"id"`            // Java trả về Integer, Trip_Id bên ticket lưu dạng string
 	DepartureDate string          `json:"departureDate"` // e.g., "YYYY-MM-DD"
 	DepartureTime string          `json:"departureTime"` // e.g., "HH:MM:SS"
 	ArrivalDate   string          `json:"arrivalDate"`
@@ -103,6 +124,37 @@ type TripInfo struct {
 	Route         *RouteInfo      `json:"route,omitempty"`     // Use pointer if it can be null
 	CreatedAt     string          `json:"createdAt,omitempty"` // Expecting ISO 8601 string or timestamp string
 	CreatedBy     *int            `json:"createdBy,omitempty"`
+	Stops         []TripStop      `json:"stops,omitempty"` // Điểm dừng theo thứ tự hành trình
+}
+
+// TripLocation là múi giờ của giờ khởi hành do trip-service trả về (giờ Việt Nam).
+var TripLocation = time.FixedZone("ICT", 7*60*60)
+
+// DepartureAt ghép DepartureDate ("2006-01-02") và DepartureTime ("15:04:05" hoặc "15:04") thành time.Time.
+func (t *TripInfo) DepartureAt() (time.Time, error) {
+	at, ok := parseTripTime(t.DepartureDate, t.DepartureTime)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid departure date/time %q %q for trip %s", t.DepartureDate, t.DepartureTime, t.ID)
+	}
+	return at, nil
+}
+
+// ArrivalAt ghép ArrivalDate và ArrivalTime thành time.Time, cùng định dạng với DepartureAt.
+func (t *TripInfo) ArrivalAt() (time.Time, error) {
+	at, ok := parseTripTime(t.ArrivalDate, t.ArrivalTime)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid arrival date/time %q %q for trip %s", t.ArrivalDate, t.ArrivalTime, t.ID)
+	}
+	return at, nil
+}
+
+func parseTripTime(date, clock string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if at, err := time.ParseInLocation(layout, date+" "+clock, TripLocation); err == nil {
+			return at, true
+		}
+	}
+	return time.Time{}, false
 }
 
 type PaginatedTickets struct {
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Cột sắp xếp của tìm kiếm vé (GET /tickets/search?sort=...)
+const (
+	TicketSortBookingTime = "booking_time"
+	TicketSortPrice       = "price"
+)
+
+// ErrInvalidTicketSearch được trả về khi tham số tìm kiếm hoặc cursor không hợp lệ.
+var ErrInvalidTicketSearch = errors.New("invalid ticket search")
+
+// TicketSearchFilter là điều kiện tìm kiếm vé của nhân viên quầy. Trường rỗng / nil là không lọc.
+type TicketSearchFilter struct {
+	Phone          string
+	Email          string
+	Name           string     // Tìm gần đúng, không phân biệt hoa thường
+	TripID         string     // Khớp chiều đi hoặc chiều về
+	BookedFrom     *time.Time // Booking_Time >= BookedFrom
+	BookedTo       *time.Time // Booking_Time < BookedTo
+	Status         *int16
+	PaymentStatus  *int16
+	BookingChannel *int16
+	SortBy         string // TicketSortBookingTime (mặc định) hoặc TicketSortPrice
+	SortDesc       bool
+	Cursor         string // next_cursor của trang trước, rỗng là trang đầu
+	Limit          int
+}
+
+// TicketInvoice là trạng thái hoá đơn của vé: vé đoàn dùng chung hoá đơn theo group_ref, vé lẻ theo Ticket_Id.
+type TicketInvoice struct {
+	Ref           string `json:"ref"`
+	GroupBooking  bool   `json:"group_booking"`
+	PaymentStatus int16  `json:"payment_status"`
+	Status        string `json:"status"` // PaymentStatusLabel
+}
+
+// TicketSearchResult là một trang kết quả tìm kiếm. Total đếm mọi vé khớp bộ lọc, không phụ thuộc cursor.
+type TicketSearchResult struct {
+	Tickets    []*TicketReturn `json:"tickets"`
+	Total      int64           `json:"total"`
+	Limit      int             `json:"limit"`
+	NextCursor string          `json:"next_cursor,omitempty"` // Rỗng khi đã hết kết quả
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrIllegalTransition được trả về khi một thao tác yêu cầu chuyển trạng thái không có trong state machine
+// (VD: check-in ghế đã huỷ, thanh toán vé đã hết hạn).
+var ErrIllegalTransition = errors.New("illegal status transition")
+
+// Mã kết quả thanh toán payment_service gửi qua TicketStatusUpdateEvent.StatusCode.
+const (
+	PaymentResultPaid     = "1" // Thanh toán thành công
+	PaymentResultRefunded = "2" // Đã hoàn tiền
+	PaymentResultFailed   = "3" // Thanh toán thất bại / hết hạn
+)
+
+// Đối tượng của một lần chuyển trạng thái, lưu ở cột Entity của Ticket_Logs.
+const (
+	StatusEntityTicket  = "TICKET"
+	StatusEntityPayment = "PAYMENT"
+	StatusEntitySeat    = "SEAT"
+)
+
+// ticketTransitions là các trạng thái vé có thể chuyển tới từ mỗi trạng thái. Cancelled, Used là trạng thái cuối.
+var ticketTransitions = map[int16][]int16{
+	TicketStatusPendingConfirmation: {TicketStatusActive, TicketStatusCancelled, TicketStatusExpired},
+	TicketStatusActive:              {TicketStatusUsed, TicketStatusCancelled, TicketStatusExpired},
+	TicketStatusExpired:             {TicketStatusCancelled}, // Thanh toán thất bại của vé đã hết hạn giữ chỗ
+}
+
+// paymentTransitions là các trạng thái thanh toán có thể chuyển tới từ mỗi trạng thái. Failed, Refunded là trạng thái cuối.
+var paymentTransitions = map[int16][]int16{
+	PaymentStatusPending:       {PaymentStatusPaid, PaymentStatusFailed},
+	PaymentStatusPaid:          {PaymentStatusRefundPending, PaymentStatusRefunded},
+	PaymentStatusRefundPending: {PaymentStatusRefunded, PaymentStatusPaid}, // Paid: payment_service từ chối hoàn tiền
+}
+
+// seatTransitions là các trạng thái ghế có thể chuyển tới từ mỗi trạng thái. Cancelled, CheckedIn là trạng thái cuối.
+var seatTransitions = map[int16][]int16{
+	SeatStatusPendingPayment: {SeatStatusConfirmed, SeatStatusCancelled},
+	SeatStatusConfirmed:      {SeatStatusCheckedIn, SeatStatusMissed, SeatStatusCancelled},
+	SeatStatusMissed:         {SeatStatusCheckedIn}, // Khách lên xe muộn sau khi đã bị đánh dấu no-show
+}
+
+// ValidateTicketTransition kiểm tra vé được phép chuyển từ from sang to. Giữ nguyên trạng thái luôn hợp lệ.
+func ValidateTicketTransition(from, to int16) error {
+	return validateTransition(ticketTransitions, StatusEntityTicket, from, to, TicketStatusName)
+}
+
+// ValidatePaymentTransition kiểm tra trạng thái thanh toán của vé được phép chuyển từ from sang to.
+func ValidatePaymentTransition(from, to int16) error {
+	return validateTransition(paymentTransitions, StatusEntityPayment, from, to, PaymentStatusName)
+}
+
+// ValidateSeatTransition kiểm tra ghế (seat_ticket) được phép chuyển từ from sang to.
+func ValidateSeatTransition(from, to int16) error {
+	return validateTransition(seatTransitions, StatusEntitySeat, from, to, SeatStatusName)
+}
+
+func validateTransition(transitions map[int16][]int16, entity string, from, to int16, name func(int16) string) error {
+	if from == to {
+		return nil
+	}
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %s -> %s: %w", entity, name(from), name(to), ErrIllegalTransition)
+}
+
+// TicketStatusName trả về tên trạng thái vé dùng trong lịch sử và thông báo lỗi.
+func TicketStatusName(status int16) string {
+	switch status {
+	case TicketStatusPendingConfirmation:
+		return "PENDING"
+	case TicketStatusActive:
+		return "CONFIRMED"
+	case TicketStatusCancelled:
+		return "CANCELLED"
+	case TicketStatusUsed:
+		return "USED"
+	case TicketStatusExpired:
+		return "EXPIRED"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", status)
+}
+
+// PaymentStatusName trả về tên trạng thái thanh toán.
+func PaymentStatusName(status int16) string {
+	switch status {
+	case PaymentStatusPending:
+		return "PENDING"
+	case PaymentStatusPaid:
+		return "PAID"
+	case PaymentStatusFailed:
+		return "FAILED"
+	case PaymentStatusRefundPending:
+		return "REFUND_PENDING"
+	case PaymentStatusRefunded:
+		return "REFUNDED"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", status)
+}
+
+// SeatStatusName trả về tên trạng thái ghế.
+func SeatStatusName(status int16) string {
+	switch status {
+	case SeatStatusPendingPayment:
+		return "PENDING"
+	case SeatStatusConfirmed:
+		return "CONFIRMED"
+	case SeatStatusCancelled:
+		return "CANCELLED"
+	case SeatStatusCheckedIn:
+		return "CHECKED_IN"
+	case SeatStatusMissed:
+		return "NO_SHOW"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", status)
+}
+
+// StatusChange là người thực hiện và lý do của một lần chuyển trạng thái, được ghi cùng trạng thái cũ/mới vào Ticket_Logs.
+type StatusChange struct {
+	Actor  string // VD: "ROLE_CUSTOMER:12", "GUEST:0901234567", "payment-service", "system"
+	Reason string
+}
+
+// TicketHistoryEntry là một dòng lịch sử của vé (GET /tickets/:id/history).
+// Các dòng thao tác (đổi ghế, huỷ...) chỉ có Action; dòng chuyển trạng thái có thêm Entity và trạng thái cũ/mới.
+type TicketHistoryEntry struct {
+	ID           int32     `json:"id"`
+	Action       string    `json:"action"`
+	Entity       string    `json:"entity,omitempty"`
+	SeatTicketID *int32    `json:"seat_ticket_id,omitempty"`
+	FromStatus   *int16    `json:"from_status,omitempty"`
+	ToStatus     *int16    `json:"to_status,omitempty"`
+	FromState    string    `json:"from_state,omitempty"`
+	ToState      string    `json:"to_state,omitempty"`
+	Actor        string    `json:"actor,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// StatusName trả về tên trạng thái theo đối tượng của dòng lịch sử.
+func StatusName(entity string, status int16) string {
+	switch entity {
+	case StatusEntityPayment:
+		return PaymentStatusName(status)
+	case StatusEntitySeat:
+		return SeatStatusName(status)
+	}
+	return TicketStatusName(status)
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Loại thông báo gửi cho khách khi chuyến thay đổi.
+const (
+	NotificationTypeTripCancelled   = "TRIP_CANCELLED"
+	NotificationTypeTripRescheduled = "TRIP_RESCHEDULED"
+)
+
+// TripCancellation là chuyến bị huỷ bên trip-service (sự kiện trip_cancelled).
+type TripCancellation struct {
+	TripID      string
+	Reason      string
+	CancelledAt time.Time
+}
+
+// TripReschedule là chuyến bị dời giờ khởi hành bên trip-service (sự kiện trip_updated).
+type TripReschedule struct {
+	TripID       string
+	OldDeparture time.Time
+	NewDeparture time.Time
+}
+
+// TripCancellationResult tổng kết việc xử lý một chuyến bị huỷ.
+type TripCancellationResult struct {
+	CancelledTickets int   // Vé bị huỷ trong lần xử lý này
+	SkippedTickets   int   // Vé không huỷ được (VD: đã check-in)
+	ClosedWaitlist   int   // Lượt chờ bị đóng
+	DeletedSeats     int64 // Ghế chưa từng được đặt bị xoá
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Trạng thái lượt chờ (trip_waitlist.status)
+const (
+	WaitlistStatusWaiting = 0 // Đang chờ ghế
+	WaitlistStatusOffered = 1 // Đang được giữ ghế, chờ khách đặt vé
+	WaitlistStatusBooked  = 2 // Khách đã đặt vé bằng ghế được giữ
+	WaitlistStatusExpired = 3 // Hết hạn giữ ghế mà khách không đặt
+	WaitlistStatusLeft    = 4 // Khách tự rời danh sách chờ
+)
+
+// NotificationTypeWaitlistSeatOffered là loại thông báo gửi cho khách khi có ghế được giữ.
+const NotificationTypeWaitlistSeatOffered = "WAITLIST_SEAT_OFFERED"
+
+// JoinWaitlistRequest là body của POST /waitlist.
+// Điểm đón/trả <= 0 nghĩa là chờ ghế cả chuyến.
+type JoinWaitlistRequest struct {
+	TripID          string `json:"trip_id" binding:"required"`
+	SeatCount       int16  `json:"seat_count" binding:"required,min=1,max=10"`
+	PickupLocation  int32  `json:"pickup_location,omitempty"`
+	DropoffLocation int32  `json:"dropoff_location,omitempty"`
+}
+
+// Segment trả về đoạn đường khách muốn đặt theo điểm dừng của chuyến.
+func (r *JoinWaitlistRequest) Segment(trip *TripInfo) (SeatSegment, error) {
+	return trip.SeatSegment(r.PickupLocation, r.DropoffLocation)
+}
+
+// WaitlistEntry là một lượt chờ của khách, kèm ghế đang được giữ (nếu có).
+type WaitlistEntry struct {
+	ID        int32               `json:"id"`
+	TripID    string              `json:"trip_id"`
+	SeatCount int16               `json:"seat_count"`
+	Segment   SeatSegment         `json:"segment"`
+	Status    int16               `json:"status"`
+	Position  int64               `json:"position,omitempty"` // Vị trí trong hàng chờ, chỉ có khi đang chờ
+	CreatedAt time.Time           `json:"created_at"`
+	Offers    []WaitlistSeatOffer `json:"offers,omitempty"`
+}
+
+// WaitlistSeatOffer là ghế đang được giữ cho khách; khách đặt vé ghế này như bình thường trước ExpiresAt.
+type WaitlistSeatOffer struct {
+	SeatID    int32     `json:"seat_id"`
+	SeatName  string    `json:"seat_name"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -5,8 +5,10 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"ticket-service/config"
+	"ticket-service/domain/models"
 	"ticket-service/internal/services"
 	"ticket-service/pkg/kafkaclient"
 	"ticket-service/pkg/utils"
@@ -101,63 +103,22 @@ func (c *BookingRequestConsumer) handleMessage(ctx context.Context, record *kgo.
 	handleCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 
-	redisStateKey := fmt.Sprintf("booking:state:%s", event.BookingID)
-
-	stateExists, err := c.redisClient.Exists(handleCtx, redisStateKey).Result()
-	if err != nil {
-		c.logger.Error("BookingConsumer: Failed to check state existence for %s: %v", event.BookingID, err)
-		return // Không commit để thử lại
-	}
-	if stateExists == 0 {
-		c.logger.Info("BookingConsumer: State for bookingId %s no longer exists. Likely timed out. Skipping processing.", event.BookingID)
+	// 1. Tạo vé; trạng thái trong booking_requests được cập nhật cùng transaction tạo vé
+	status, err := c.ticketService.ProcessBookingRequest(handleCtx, event)
+	if errors.Is(err, models.ErrBookingRequestClosed) || errors.Is(err, models.ErrBookingRequestNotFound) {
+		c.logger.Info("BookingConsumer: Booking %s is no longer open (likely timed out or already processed). Skipping: %v", event.BookingID, err)
 		if err := client.CommitRecords(ctx, record); err != nil {
-			c.logger.Error("BookingConsumer: Failed to commit timed-out record: %v", err)
+			c.logger.Error("BookingConsumer: Failed to commit skipped record: %v", err)
 		}
 		return
 	}
-
-	// 1. Cập nhật trạng thái trong Redis -> PROCESSING
-	if err := c.redisClient.HSet(handleCtx, redisStateKey, "status", "PROCESSING").Err(); err != nil {
-		c.logger.Error("BookingConsumer: Failed to update Redis state to PROCESSING for %s: %v", event.BookingID, err)
-	}
-
-	// 2. Gọi logic tạo vé cốt lõi
-	ticket, err := c.ticketService.CreateTicket(handleCtx, &event.Input, event.CustomerID)
-
-	var messageToPublish websocket.Message
-	redisFields := make(map[string]interface{})
-
-	// 3. Chuẩn bị kết quả
 	if err != nil {
-		c.logger.Error("[Notify] Failed to create ticket for bookingId %s: %v", event.BookingID, err)
-		errorPayload := map[string]string{"error": err.Error()}
-		messageToPublish = websocket.Message{Type: "error", Payload: errorPayload}
-		redisFields["status"] = "FAILED"
-		redisFields["error_message"] = err.Error()
-	} else {
-		c.logger.Info("[Notify] Ticket %s created successfully for bookingId %s.", ticket.TicketID, event.BookingID)
-		ticketReturn, repoErr := c.ticketService.GetTicketByID(handleCtx, ticket.TicketID)
-		if repoErr != nil {
-			errorPayload := map[string]string{"error": "Không thể lấy chi tiết vé sau khi tạo."}
-			messageToPublish = websocket.Message{Type: "error", Payload: errorPayload}
-			redisFields["status"] = "FAILED"
-			redisFields["error_message"] = "Không thể lấy chi tiết vé sau khi tạo."
-		} else {
-			messageToPublish = websocket.Message{Type: "result", Payload: ticketReturn}
-			resultPayloadBytes, _ := json.Marshal(ticketReturn)
-			redisFields["status"] = "COMPLETED"
-			redisFields["ticket_id"] = ticket.TicketID
-			redisFields["result_payload"] = string(resultPayloadBytes)
-		}
-	}
-
-	// 4. Cập nhật trạng thái cuối cùng vào Redis Hash
-	if err := c.redisClient.HSet(handleCtx, redisStateKey, redisFields).Err(); err != nil {
-		c.logger.Error("BookingConsumer: Failed to update final Redis state for %s: %v", event.BookingID, err)
+		c.logger.Error("BookingConsumer: Failed to process booking %s: %v", event.BookingID, err)
+		return // Không commit để thử lại
 	}
 
-	// 5. Publish kết quả lên kênh Pub/Sub để WebSocket client nhận
-	payloadBytes, _ := json.Marshal(messageToPublish)
+	// 2. Publish kết quả lên kênh Pub/Sub để WebSocket client nhận; client poll đọc thẳng từ DB
+	payloadBytes, _ := json.Marshal(websocket.ResultMessage(status))
 	redisChannel := fmt.Sprintf("booking-result:%s", event.BookingID)
 	if pubErr := c.redisClient.Publish(context.Background(), redisChannel, payloadBytes).Err(); pubErr != nil {
 		c.logger.Error("[Notify] Failed to publish result to Redis for bookingId %s: %v", event.BookingID, pubErr)
@@ -165,7 +126,7 @@ func (c *BookingRequestConsumer) handleMessage(ctx context.Context, record *kgo.
 		c.logger.Info("[Notify] Successfully published result to Redis channel %s", redisChannel)
 	}
 
-	// 6. Commit message Kafka sau khi đã xử lý xong
+	// 3. Commit message Kafka sau khi đã xử lý xong
 	if err := client.CommitRecords(ctx, record); err != nil {
 		c.logger.Error("BookingConsumer: Failed to commit final record: %v", err)
 	}
@@ -0,0 +1,57 @@
+package consumers
+
+import (
+	"context"
+	"ticket-service/pkg/utils"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// retryBackoff là thời gian chờ trước khi xử lý lại partition có record thất bại.
+const retryBackoff = 5 * time.Second
+
+// handlePartition xử lý tuần tự các record của một partition bằng handle (true = có thể commit) và commit
+// tới record thành công cuối cùng. Gặp record thất bại thì seek partition về record đó, bỏ qua phần còn lại
+// để lần poll sau xử lý lại từ record lỗi, trả về false. Client cần kgo.BlockRebalanceOnPoll().
+func handlePartition(client *kgo.Client, p kgo.FetchTopicPartition, handle func(*kgo.Record) bool, logger utils.Logger, name string) bool {
+	var lastHandled *kgo.Record
+	ok := true
+	for _, record := range p.Records {
+		if !handle(record) {
+			client.SetOffsets(map[string]map[int32]kgo.EpochOffset{
+				record.Topic: {record.Partition: {Epoch: record.LeaderEpoch, Offset: record.Offset}},
+			})
+			logger.Error("%s: Stopping partition %s/%d at offset %d, will retry.", name, record.Topic, record.Partition, record.Offset)
+			ok = false
+			break
+		}
+		lastHandled = record
+	}
+
+	if lastHandled != nil {
+		if err := client.CommitRecords(context.Background(), lastHandled); err != nil {
+			logger.Error("%s: Failed to commit offset %d of %s/%d: %v", name, lastHandled.Offset, lastHandled.Topic, lastHandled.Partition, err)
+		}
+	}
+	return ok
+}
+
+// handleFetches chạy handlePartition cho từng partition rồi cho phép rebalance; nếu có partition thất bại
+// thì chờ retryBackoff (hoặc tới khi ctx bị huỷ) trước khi poll tiếp.
+func handleFetches(ctx context.Context, client *kgo.Client, fetches kgo.Fetches, handle func(*kgo.Record) bool, logger utils.Logger, name string) {
+	failed := false
+	fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+		if !handlePartition(client, p, handle, logger, name) {
+			failed = true
+		}
+	})
+	client.AllowRebalance()
+
+	if failed {
+		select {
+		case <-ctx.Done():
+		case <-time.After(retryBackoff):
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// file: ticket-service/internal/consumers/seats_released_consumer.go
+package consumers
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"ticket-service/config"
+	"ticket-service/internal/services"
+	"ticket-service/pkg/kafkaclient"
+	"ticket-service/pkg/utils"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// SeatsReleasedConsumer nghe sự kiện ghế được trả lại (huỷ vé, vé hết hạn thanh toán, đổi ghế...)
+// để giữ ghế cho khách trong danh sách chờ của chuyến.
+type SeatsReleasedConsumer struct {
+	waitlistService services.IWaitlistService
+	logger          utils.Logger
+	kafkaCfg        config.KafkaConfig
+	topicCfg        config.TopicConfig
+}
+
+func NewSeatsReleasedConsumer(cfg config.Config, waitlistService services.IWaitlistService, logger utils.Logger) *SeatsReleasedConsumer {
+	return &SeatsReleasedConsumer{
+		waitlistService: waitlistService,
+		logger:          logger,
+		kafkaCfg:        cfg.Kafka,
+		topicCfg:        cfg.Kafka.Topics.SeatsReleased,
+	}
+}
+
+func (c *SeatsReleasedConsumer) Start(ctx context.Context) {
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(c.kafkaCfg.Seeds...),
+		kgo.ConsumerGroup(c.topicCfg.GroupID),
+		kgo.ConsumeTopics(c.topicCfg.Topic),
+		kgo.DisableAutoCommit(),
+		kgo.BlockRebalanceOnPoll(), // SetOffsets/commit không chạy song song với rebalance
+	}
+
+	if c.kafkaCfg.EnableTLS {
+		opts = append(opts, kgo.DialTLSConfig(new(tls.Config)))
+	}
+	if c.kafkaCfg.SASLUser != "" && c.kafkaCfg.SASLPass != "" {
+		opts = append(opts, kgo.SASL(scram.Auth{
+			User: c.kafkaCfg.SASLUser,
+			Pass: c.kafkaCfg.SASLPass,
+		}.AsSha256Mechanism()))
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		c.logger.Error("SeatsReleasedConsumer: Failed to create Kafka client: %v", err)
+		return
+	}
+	defer client.Close()
+
+	c.logger.Info("Starting Kafka consumer for topic: %s", c.topicCfg.Topic)
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Info("SeatsReleasedConsumer: Shutting down.")
+			return
+		default:
+			fetches := client.PollFetches(ctx)
+			if errs := fetches.Errors(); len(errs) > 0 {
+				c.logger.Error("SeatsReleasedConsumer: Fetch errors: %v", errs)
+				client.AllowRebalance()
+				continue
+			}
+
+			handleFetches(ctx, client, fetches, c.handleRecord, c.logger, "SeatsReleasedConsumer")
+		}
+	}
+}
+
+// handleRecord trả về true nếu record đã xử lý xong (hoặc hỏng, bỏ qua) và có thể commit.
+// Record thất bại chặn partition của nó và được xử lý lại sau retryBackoff để ghế không bị bỏ sót.
+func (c *SeatsReleasedConsumer) handleRecord(record *kgo.Record) bool {
+	var event kafkaclient.SeatUpdateEvent
+	if err := json.Unmarshal(record.Value, &event); err != nil || event.TripID == "" {
+		c.logger.Error("SeatsReleasedConsumer: Failed to unmarshal event: %v. Skipping.", err)
+		return true
+	}
+
+	handleCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	offered, err := c.waitlistService.OfferFreedSeats(handleCtx, event.TripID)
+	if err != nil {
+		c.logger.Error("Failed to offer released seats of TripID %s to waitlist: %v. Not committing.", event.TripID, err)
+		return false
+	}
+	if offered > 0 {
+		c.logger.Info("SeatsReleasedConsumer: Offered released seats of trip %s to %d waitlisted customer(s)", event.TripID, offered)
+	}
+	return true
+}
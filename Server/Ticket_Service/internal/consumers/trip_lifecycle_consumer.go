@@ -0,0 +1,167 @@
+// file: ticket-service/internal/consumers/trip_lifecycle_consumer.go
+package consumers
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"ticket-service/config"
+	"ticket-service/domain/models"
+	"ticket-service/internal/services"
+	"ticket-service/pkg/utils"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// TripCancelledEvent là sự kiện trip_cancelled của trip-service (chuyến chuyển sang trạng thái huỷ hoặc bị xoá).
+type TripCancelledEvent struct {
+	TripID      string    `json:"tripId"`
+	Reason      string    `json:"reason"`
+	CancelledAt MilliTime `json:"cancelledAt"`
+}
+
+// TripUpdatedEvent là sự kiện trip_updated của trip-service khi giờ khởi hành của chuyến thay đổi.
+type TripUpdatedEvent struct {
+	TripID           string    `json:"tripId"`
+	OldDepartureDate string    `json:"oldDepartureDate"`
+	OldDepartureTime string    `json:"oldDepartureTime"`
+	DepartureDate    string    `json:"departureDate"`
+	DepartureTime    string    `json:"departureTime"`
+	UpdatedAt        MilliTime `json:"updatedAt"`
+}
+
+// toReschedule ghép ngày/giờ cũ và mới thành models.TripReschedule.
+func (e TripUpdatedEvent) toReschedule() (models.TripReschedule, error) {
+	oldTrip := models.TripInfo{DepartureDate: e.OldDepartureDate, DepartureTime: e.OldDepartureTime}
+	oldDeparture, err := oldTrip.DepartureAt()
+	if err != nil {
+		return models.TripReschedule{}, fmt.Errorf("old departure: %w", err)
+	}
+	newTrip := models.TripInfo{DepartureDate: e.DepartureDate, DepartureTime: e.DepartureTime}
+	newDeparture, err := newTrip.DepartureAt()
+	if err != nil {
+		return models.TripReschedule{}, fmt.Errorf("new departure: %w", err)
+	}
+	return models.TripReschedule{TripID: e.TripID, OldDeparture: oldDeparture, NewDeparture: newDeparture}, nil
+}
+
+// TripLifecycleConsumer nghe sự kiện chuyến bị huỷ / dời giờ từ trip-service để huỷ - hoàn tiền
+// hoặc mở lượt đổi chuyến miễn phí cho các vé của chuyến.
+type TripLifecycleConsumer struct {
+	ticketService services.ITicketService
+	logger        utils.Logger
+	kafkaCfg      config.KafkaConfig
+	updatedTopic  string
+	cancelledCfg  config.TopicConfig
+}
+
+func NewTripLifecycleConsumer(cfg config.Config, ticketService services.ITicketService, logger utils.Logger) *TripLifecycleConsumer {
+	return &TripLifecycleConsumer{
+		ticketService: ticketService,
+		logger:        logger,
+		kafkaCfg:      cfg.Kafka,
+		updatedTopic:  cfg.Kafka.Topics.TripUpdated.Topic,
+		cancelledCfg:  cfg.Kafka.Topics.TripCancelled,
+	}
+}
+
+func (c *TripLifecycleConsumer) Start(ctx context.Context) {
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(c.kafkaCfg.Seeds...),
+		kgo.ConsumerGroup(c.cancelledCfg.GroupID),
+		kgo.ConsumeTopics(c.updatedTopic, c.cancelledCfg.Topic),
+		kgo.DisableAutoCommit(),
+		kgo.BlockRebalanceOnPoll(), // SetOffsets/commit không chạy song song với rebalance
+	}
+
+	if c.kafkaCfg.EnableTLS {
+		opts = append(opts, kgo.DialTLSConfig(new(tls.Config)))
+	}
+	if c.kafkaCfg.SASLUser != "" && c.kafkaCfg.SASLPass != "" {
+		opts = append(opts, kgo.SASL(scram.Auth{
+			User: c.kafkaCfg.SASLUser,
+			Pass: c.kafkaCfg.SASLPass,
+		}.AsSha256Mechanism()))
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		c.logger.Error("TripLifecycleConsumer: Failed to create Kafka client: %v", err)
+		return
+	}
+	defer client.Close()
+
+	c.logger.Info("Starting Kafka consumer for topics: %s, %s", c.updatedTopic, c.cancelledCfg.Topic)
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Info("TripLifecycleConsumer: Shutting down.")
+			return
+		default:
+			fetches := client.PollFetches(ctx)
+			if errs := fetches.Errors(); len(errs) > 0 {
+				c.logger.Error("TripLifecycleConsumer: Fetch errors: %v", errs)
+				client.AllowRebalance()
+				continue
+			}
+
+			handleFetches(ctx, client, fetches, c.handleRecord, c.logger, "TripLifecycleConsumer")
+		}
+	}
+}
+
+// handleRecord trả về true nếu record có thể commit. Record thất bại (VD HandleTripCancelled gặp ErrTicketBusy)
+// chặn partition của nó và được xử lý lại sau retryBackoff.
+func (c *TripLifecycleConsumer) handleRecord(record *kgo.Record) bool {
+	handleCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if record.Topic == c.cancelledCfg.Topic {
+		return c.handleCancelled(handleCtx, record)
+	}
+	return c.handleUpdated(handleCtx, record)
+}
+
+// handleCancelled trả về true nếu record đã xử lý xong (hoặc hỏng, bỏ qua) và có thể commit.
+func (c *TripLifecycleConsumer) handleCancelled(ctx context.Context, record *kgo.Record) bool {
+	var event TripCancelledEvent
+	if err := json.Unmarshal(record.Value, &event); err != nil || event.TripID == "" {
+		c.logger.Error("TripLifecycleConsumer: Failed to unmarshal trip cancelled event: %v. Skipping.", err)
+		return true
+	}
+
+	_, err := c.ticketService.HandleTripCancelled(ctx, models.TripCancellation{
+		TripID:      event.TripID,
+		Reason:      event.Reason,
+		CancelledAt: time.Time(event.CancelledAt),
+	})
+	if err != nil {
+		c.logger.Error("Failed to handle cancellation of TripID %s: %v. Not committing.", event.TripID, err)
+		return false
+	}
+	return true
+}
+
+// handleUpdated trả về true nếu record đã xử lý xong (hoặc hỏng, bỏ qua) và có thể commit.
+func (c *TripLifecycleConsumer) handleUpdated(ctx context.Context, record *kgo.Record) bool {
+	var event TripUpdatedEvent
+	if err := json.Unmarshal(record.Value, &event); err != nil || event.TripID == "" {
+		c.logger.Error("TripLifecycleConsumer: Failed to unmarshal trip updated event: %v. Skipping.", err)
+		return true
+	}
+	reschedule, err := event.toReschedule()
+	if err != nil {
+		c.logger.Error("TripLifecycleConsumer: Invalid trip updated event for TripID %s: %v. Skipping.", event.TripID, err)
+		return true
+	}
+
+	if _, err := c.ticketService.HandleTripRescheduled(ctx, reschedule); err != nil {
+		c.logger.Error("Failed to handle reschedule of TripID %s: %v. Not committing.", event.TripID, err)
+		return false
+	}
+	return true
+}
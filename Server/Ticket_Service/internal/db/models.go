@@ -12,6 +12,20 @@ import (
 	"github.com/google/uuid"
 )
 
+type BookingRequest struct {
+	BookingID       uuid.UUID       `json:"booking_id"`
+	CustomerID      sql.NullInt32   `json:"customer_id"`
+	Status          string          `json:"status"`
+	RequestData     json.RawMessage `json:"request_data"`
+	TicketID        sql.NullString  `json:"ticket_id"`
+	ErrorMessage    sql.NullString  `json:"error_message"`
+	Attempts        int32           `json:"attempts"`
+	ConnectDeadline time.Time       `json:"connect_deadline"`
+	ConnectedAt     sql.NullTime    `json:"connected_at"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
 type Checkin struct {
 	ID           int32          `json:"id"`
 	SeatTicketID int32          `json:"seat_ticket_id"`
@@ -20,14 +34,51 @@ type Checkin struct {
 	SeatName     sql.NullString `json:"seat_name"`
 	CheckedInAt  sql.NullTime   `json:"checked_in_at"`
 	Note         string         `json:"note"`
+	Source       string         `json:"source"`
+	DeviceID     sql.NullString `json:"device_id"`
+	ClientScanID sql.NullString `json:"client_scan_id"`
+	SyncedAt     sql.NullTime   `json:"synced_at"`
+	Conflict     sql.NullString `json:"conflict"`
 }
 
-type OutboxEvent struct {
+type OutboxDeadLetter struct {
 	ID        uuid.UUID       `json:"id"`
 	Topic     string          `json:"topic"`
 	Key       string          `json:"key"`
 	Payload   json.RawMessage `json:"payload"`
+	Attempts  int32           `json:"attempts"`
+	LastError sql.NullString  `json:"last_error"`
 	CreatedAt time.Time       `json:"created_at"`
+	FailedAt  time.Time       `json:"failed_at"`
+}
+
+type OutboxEvent struct {
+	ID            uuid.UUID       `json:"id"`
+	Topic         string          `json:"topic"`
+	Key           string          `json:"key"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Attempts      int32           `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     sql.NullString  `json:"last_error"`
+}
+
+type Policy struct {
+	PolicyID                 int32     `json:"policy_id"`
+	Name                     string    `json:"name"`
+	DiscountPercent          float64   `json:"discount_percent"`
+	RoundTripDiscountPercent float64   `json:"round_trip_discount_percent"`
+	Status                   int16     `json:"status"`
+	CreatedAt                time.Time `json:"created_at"`
+	UpdatedAt                time.Time `json:"updated_at"`
+}
+
+type PolicyRefundRule struct {
+	ID                      int32     `json:"id"`
+	PolicyID                int32     `json:"policy_id"`
+	MinHoursBeforeDeparture int32     `json:"min_hours_before_departure"`
+	RefundPercent           float64   `json:"refund_percent"`
+	CreatedAt               time.Time `json:"created_at"`
 }
 
 type Seat struct {
@@ -36,36 +87,69 @@ type Seat struct {
 	SeatName  sql.NullString `json:"seat_name"`
 	CreatedAt sql.NullTime   `json:"created_at"`
 	UpdatedAt sql.NullTime   `json:"updated_at"`
+	LayoutID  sql.NullInt32  `json:"layout_id"`
+	Deck      sql.NullInt16  `json:"deck"`
+	RowNo     sql.NullInt16  `json:"row_no"`
+	ColNo     sql.NullInt16  `json:"col_no"`
+	SeatClass sql.NullString `json:"seat_class"`
 }
 
-type SeatTicket struct {
-	ID        int32        `json:"id"`
-	SeatID    int32        `json:"seat_id"`
-	TicketID  string       `json:"ticket_id"`
-	Status    int16        `json:"status"`
-	TripID    string       `json:"trip_id"`
-	CreatedAt sql.NullTime `json:"created_at"`
-	UpdatedAt sql.NullTime `json:"updated_at"`
+type SeatLayout struct {
+	ID                int32     `json:"id"`
+	VehicleTypeID     int32     `json:"vehicle_type_id"`
+	SeatCount         int32     `json:"seat_count"`
+	Name              string    `json:"name"`
+	Decks             int16     `json:"decks"`
+	RowCount          int16     `json:"row_count"`
+	ColumnCount       int16     `json:"column_count"`
+	AisleAfterColumns []int32   `json:"aisle_after_columns"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
 }
 
-type Ticket struct {
+type SeatLayoutSeat struct {
+	ID        int32  `json:"id"`
+	LayoutID  int32  `json:"layout_id"`
+	SeatName  string `json:"seat_name"`
+	Deck      int16  `json:"deck"`
+	RowNo     int16  `json:"row_no"`
+	ColNo     int16  `json:"col_no"`
+	SeatClass string `json:"seat_class"`
+}
+
+type SeatTicket struct {
+	ID             int32          `json:"id"`
+	SeatID         int32          `json:"seat_id"`
 	TicketID       string         `json:"ticket_id"`
-	TripIDBegin    string         `json:"trip_id_begin"`
-	TripIDEnd      sql.NullString `json:"trip_id_end"`
-	Type           int16          `json:"type"`
-	CustomerID     sql.NullInt32  `json:"customer_id"`
-	Phone          sql.NullString `json:"phone"`
-	Email          sql.NullString `json:"email"`
-	Name           sql.NullString `json:"name"`
-	Price          float64        `json:"price"`
 	Status         int16          `json:"status"`
-	BookingTime    time.Time      `json:"booking_time"`
-	PaymentStatus  int16          `json:"payment_status"`
-	BookingChannel int16          `json:"booking_channel"`
-	CreatedAt      time.Time      `json:"created_at"`
-	UpdatedAt      time.Time      `json:"updated_at"`
-	PolicyID       int32          `json:"policy_id"`
-	BookedBy       sql.NullString `json:"booked_by"`
+	TripID         string         `json:"trip_id"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	UpdatedAt      sql.NullTime   `json:"updated_at"`
+	FromStop       int16          `json:"from_stop"`
+	ToStop         int16          `json:"to_stop"`
+	PassengerName  sql.NullString `json:"passenger_name"`
+	PassengerPhone sql.NullString `json:"passenger_phone"`
+}
+
+type Ticket struct {
+	TicketID       string          `json:"ticket_id"`
+	TripIDBegin    string          `json:"trip_id_begin"`
+	TripIDEnd      sql.NullString  `json:"trip_id_end"`
+	Type           int16           `json:"type"`
+	CustomerID     sql.NullInt32   `json:"customer_id"`
+	Phone          sql.NullString  `json:"phone"`
+	Email          sql.NullString  `json:"email"`
+	Name           sql.NullString  `json:"name"`
+	Price          float64         `json:"price"`
+	Status         int16           `json:"status"`
+	BookingTime    time.Time       `json:"booking_time"`
+	PaymentStatus  int16           `json:"payment_status"`
+	BookingChannel int16           `json:"booking_channel"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	PolicyID       int32           `json:"policy_id"`
+	BookedBy       sql.NullString  `json:"booked_by"`
+	FareBreakdown  json.RawMessage `json:"fare_breakdown"`
 }
 
 type TicketDetail struct {
@@ -79,9 +163,69 @@ type TicketDetail struct {
 	UpdatedAt            time.Time     `json:"updated_at"`
 }
 
-type TicketLog struct {
-	LogID     int32     `json:"log_id"`
+type TicketGroup struct {
+	GroupRef         string         `json:"group_ref"`
+	CustomerID       sql.NullInt32  `json:"customer_id"`
+	OrganizationName sql.NullString `json:"organization_name"`
+	ContactName      string         `json:"contact_name"`
+	ContactPhone     string         `json:"contact_phone"`
+	ContactEmail     sql.NullString `json:"contact_email"`
+	BookedBy         sql.NullString `json:"booked_by"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+type TicketGroupMember struct {
 	TicketID  string    `json:"ticket_id"`
-	Action    string    `json:"action"`
+	GroupRef  string    `json:"group_ref"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+type TicketLog struct {
+	LogID        int32          `json:"log_id"`
+	TicketID     string         `json:"ticket_id"`
+	Action       string         `json:"action"`
+	CreatedAt    time.Time      `json:"created_at"`
+	Entity       sql.NullString `json:"entity"`
+	SeatTicketID sql.NullInt32  `json:"seat_ticket_id"`
+	FromStatus   sql.NullInt16  `json:"from_status"`
+	ToStatus     sql.NullInt16  `json:"to_status"`
+	Actor        sql.NullString `json:"actor"`
+	Reason       sql.NullString `json:"reason"`
+}
+
+type TripRebookingOffer struct {
+	TicketID     string       `json:"ticket_id"`
+	TripID       string       `json:"trip_id"`
+	OldDeparture time.Time    `json:"old_departure"`
+	NewDeparture time.Time    `json:"new_departure"`
+	UsedAt       sql.NullTime `json:"used_at"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+type TripWaitlist struct {
+	ID         int32        `json:"id"`
+	TripID     string       `json:"trip_id"`
+	CustomerID int32        `json:"customer_id"`
+	SeatCount  int16        `json:"seat_count"`
+	FromStop   int16        `json:"from_stop"`
+	ToStop     int16        `json:"to_stop"`
+	Status     int16        `json:"status"`
+	CreatedAt  sql.NullTime `json:"created_at"`
+	UpdatedAt  sql.NullTime `json:"updated_at"`
+}
+
+type WaitlistOffer struct {
+	ID         int32        `json:"id"`
+	WaitlistID int32        `json:"waitlist_id"`
+	TripID     string       `json:"trip_id"`
+	SeatID     int32        `json:"seat_id"`
+	CustomerID int32        `json:"customer_id"`
+	FromStop   int16        `json:"from_stop"`
+	ToStop     int16        `json:"to_stop"`
+	Status     int16        `json:"status"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	CreatedAt  sql.NullTime `json:"created_at"`
+	UpdatedAt  sql.NullTime `json:"updated_at"`
+}
@@ -12,39 +12,106 @@ import (
 )
 
 type Querier interface {
-	// Checks if a list of seats are available (not booked or held).
-	AreSeatsAvailable(ctx context.Context, seatIds []int32) ([]AreSeatsAvailableRow, error)
+	// Adds a ticket to a group booking.
+	AddTicketToGroup(ctx context.Context, arg AddTicketToGroupParams) error
+	// Checks if a list of seats are available (not booked or held) on the segment [from_stop, to_stop).
+	AreSeatsAvailable(ctx context.Context, arg AreSeatsAvailableParams) ([]AreSeatsAvailableRow, error)
+	// Removes a customer from the waitlist (status 4) if the entry is still waiting or holding seats.
+	CancelWaitlistEntry(ctx context.Context, arg CancelWaitlistEntryParams) (TripWaitlist, error)
+	// Marks the held seats a customer has just booked as claimed, and their waitlist entries as booked (status 2).
+	ClaimWaitlistOffers(ctx context.Context, arg ClaimWaitlistOffersParams) (int64, error)
+	// Closes the waitlist of a cancelled trip: held seats are released (status 3) and entries marked as left (status 4).
+	CloseTripWaitlist(ctx context.Context, tripID string) ([]TripWaitlist, error)
+	// Attaches the created ticket to a booking request, in the ticket creation transaction.
+	// Affects no row if the request expired meanwhile, in which case the ticket must be rolled back.
+	CompleteBookingRequest(ctx context.Context, arg CompleteBookingRequestParams) (int64, error)
+	// Records that the client is tracking the booking request, unless it already expired.
+	ConnectBookingRequest(ctx context.Context, bookingID uuid.UUID) (BookingRequest, error)
+	// Counts tickets matching the SearchTickets filters (without the cursor).
+	CountSearchTickets(ctx context.Context, arg CountSearchTicketsParams) (int64, error)
+	// Records a queued booking request; the client must track it (WebSocket or polling) within @connect_timeout_seconds.
+	CreateBookingRequest(ctx context.Context, arg CreateBookingRequestParams) error
 	// Typically checkin for confirmed/paid tickets;
 	// Inserts a new checkin record - can now get trip_id from seat_tickets directly.
 	CreateCheckin(ctx context.Context, arg CreateCheckinParams) (Checkin, error)
+	// Inserts a check-in replayed from a conductor device, keeping the original scan time.
+	CreateOfflineCheckin(ctx context.Context, arg CreateOfflineCheckinParams) (Checkin, error)
 	// For Transactional Outbox Pattern
 	CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) error
 	// Inserts a new seat for a trip.
 	CreateSeat(ctx context.Context, arg CreateSeatParams) (Seat, error)
-	// Links a seat to a ticket, now includes trip_id directly.
+	// Inserts a seat for a trip with its position taken from a seat layout template.
+	CreateSeatFromLayout(ctx context.Context, arg CreateSeatFromLayoutParams) (Seat, error)
+	// Inserts a seat position into a layout template.
+	CreateSeatLayoutSeat(ctx context.Context, arg CreateSeatLayoutSeatParams) (SeatLayoutSeat, error)
+	// Links a seat to a ticket for the segment [from_stop, to_stop) of the trip.
 	CreateSeatTicket(ctx context.Context, arg CreateSeatTicketParams) (SeatTicket, error)
 	// Inserts a new ticket record for both one-way and round-trip.
 	CreateTicket(ctx context.Context, arg CreateTicketParams) (Ticket, error)
 	// Inserts details for a ticket.
 	CreateTicketDetails(ctx context.Context, arg CreateTicketDetailsParams) (TicketDetail, error)
+	// Creates a group booking that ties several tickets (possibly on different trips) to one invoice.
+	CreateTicketGroup(ctx context.Context, arg CreateTicketGroupParams) (TicketGroup, error)
 	// Inserts a log entry for a ticket action.
 	CreateTicketLog(ctx context.Context, arg CreateTicketLogParams) (TicketLog, error)
-	// For the Outbox Poller/Relay
-	DeleteOutboxEvents(ctx context.Context, eventIds []uuid.UUID) error
+	// Records a status transition of a ticket, its payment or one of its seats.
+	CreateTicketStatusLog(ctx context.Context, arg CreateTicketStatusLogParams) error
+	// Adds a customer to the waitlist of a sold-out trip.
+	CreateWaitlistEntry(ctx context.Context, arg CreateWaitlistEntryParams) (TripWaitlist, error)
+	// Holds a freed seat for a waitlist entry for @hold_seconds.
+	CreateWaitlistOffer(ctx context.Context, arg CreateWaitlistOfferParams) (WaitlistOffer, error)
+	// Deletes a seat layout template (seats already generated keep their positions).
+	DeleteSeatLayout(ctx context.Context, id int32) error
+	// Removes all seat positions of a layout template (before re-inserting them).
+	DeleteSeatLayoutSeats(ctx context.Context, layoutID int32) error
+	// Removes seat assignments of a ticket (used when the passenger changes seats).
+	DeleteSeatTicketsBySeatIDs(ctx context.Context, arg DeleteSeatTicketsBySeatIDsParams) error
+	// Deletes the seats of a cancelled trip that were never booked; seats referenced by tickets are kept for history.
+	DeleteUnusedSeatsByTripID(ctx context.Context, tripID string) (int64, error)
+	// Expires up to @batch_size unfinished booking requests nobody tracked before their deadline.
+	// A returned ticket_id means the request was COMPLETED and its ticket must be cancelled.
+	ExpireUnconnectedBookingRequests(ctx context.Context, batchSize int32) ([]ExpireUnconnectedBookingRequestsRow, error)
+	// Marks entries whose offer lapsed as expired (status 3) so the next customer in line gets the seats.
+	ExpireWaitlistEntries(ctx context.Context, ids []int32) ([]TripWaitlist, error)
+	// Expires seat holds that were not booked in time (status 2).
+	ExpireWaitlistOffers(ctx context.Context) ([]ExpireWaitlistOffersRow, error)
+	// Marks a booking request that could not be fulfilled (seats taken, invalid input, ...).
+	FailBookingRequest(ctx context.Context, arg FailBookingRequestParams) (int64, error)
+	// Picks the layout of a vehicle type (exact seat count first), falling back to the default layout (vehicle_type_id 0).
+	FindSeatLayoutForVehicle(ctx context.Context, arg FindSeatLayoutForVehicleParams) (SeatLayout, error)
+	// Retrieves an active pricing policy used by the fare engine.
+	GetActivePolicyByID(ctx context.Context, policyID int32) (Policy, error)
 	GetAllCheckinsByTripID(ctx context.Context, tripID string) ([]Checkin, error)
 	// Retrieves a paginated list of all tickets, ordered by booking time.
 	GetAllTickets(ctx context.Context, arg GetAllTicketsParams) ([]Ticket, error)
-	// For the Outbox Poller/Relay
-	GetOutboxEvents(ctx context.Context, limit int32) ([]OutboxEvent, error)
+	// Lists every sold (not cancelled) seat of a trip with passenger, payment and check-in status for dispatchers.
+	// Per-seat passenger (group bookings) takes precedence over the name/phone of the booker.
+	GetBoardingManifest(ctx context.Context, tripID string) ([]GetBoardingManifestRow, error)
+	// Retrieves a booking request by its booking id.
+	GetBookingRequest(ctx context.Context, bookingID uuid.UUID) (BookingRequest, error)
+	// Finds a check-in already synced from the same device scan.
+	GetCheckinByClientScanID(ctx context.Context, arg GetCheckinByClientScanIDParams) (Checkin, error)
+	// Returns the accepted check-in of a seat on a ticket (conflict rows are ignored).
+	GetFirstCheckinBySeatTicket(ctx context.Context, arg GetFirstCheckinBySeatTicketParams) (Checkin, error)
+	// Returns the group booking a ticket belongs to.
+	GetGroupRefByTicketID(ctx context.Context, ticketID string) (string, error)
+	// Retrieves the unused free rebooking of a ticket on a trip.
+	GetOpenTripRebookingOffer(ctx context.Context, arg GetOpenTripRebookingOfferParams) (TripRebookingOffer, error)
 	// Retrieves a specific seat by its ID.
 	GetSeatByID(ctx context.Context, id int32) (Seat, error)
 	// Retrieves seat_ids associated with a ticket_id.
 	GetSeatIDsByTicketID(ctx context.Context, ticketID string) ([]int32, error)
+	// Retrieves a seat layout template by its ID.
+	GetSeatLayoutByID(ctx context.Context, id int32) (SeatLayout, error)
+	// Lists all seats of a trip with their layout position and whether they are taken on the segment [from_stop, to_stop).
+	GetSeatMapByTripID(ctx context.Context, arg GetSeatMapByTripIDParams) ([]GetSeatMapByTripIDRow, error)
 	// Retrieves seat_ticket and associated seat details for a given ticket_id.
 	GetSeatTicketAndSeatInfoByTicketID(ctx context.Context, ticketID string) (GetSeatTicketAndSeatInfoByTicketIDRow, error)
 	// Typically checkin for confirmed/paid tickets
 	// Retrieves a specific seat_ticket by its ID - now uses trip_id directly.
 	GetSeatTicketByID(ctx context.Context, arg GetSeatTicketByIDParams) (GetSeatTicketByIDRow, error)
+	// Locks the seat_ticket of a seat on a ticket for check-in, whatever its status.
+	GetSeatTicketForCheckin(ctx context.Context, arg GetSeatTicketForCheckinParams) (GetSeatTicketForCheckinRow, error)
 	// Retrieves just the status of a seat_ticket.
 	GetSeatTicketStatus(ctx context.Context, id int32) (int16, error)
 	// Retrieves all seat_ticket entries for a given Ticket_Id, no longer needs JOIN with seats.
@@ -53,6 +120,8 @@ type Querier interface {
 	GetSeatTicketsByTicketIDs(ctx context.Context, ticketIds []string) ([]GetSeatTicketsByTicketIDsRow, error)
 	// Retrieves all seats for a given trip_id.
 	GetSeatsByTripID(ctx context.Context, tripID string) ([]Seat, error)
+	// Locks one seat_ticket of a ticket and returns its current status for the state machine check.
+	GetSeatTicketStatusForUpdate(ctx context.Context, arg GetSeatTicketStatusForUpdateParams) (int16, error)
 	// Retrieves core ticket information by Ticket_Id and Phone.
 	GetTicketByPhoneAndIDCore(ctx context.Context, arg GetTicketByPhoneAndIDCoreParams) (Ticket, error)
 	// Retrieves core ticket information by Ticket_Id.
@@ -61,30 +130,89 @@ type Querier interface {
 	GetTicketDetailsByTicketID(ctx context.Context, ticketID string) ([]TicketDetail, error)
 	// Retrieves all details for a given list of Ticket_Ids.
 	GetTicketDetailsByTicketIDs(ctx context.Context, ticketIds []string) ([]TicketDetail, error)
+	// Retrieves a group booking by its reference.
+	GetTicketGroup(ctx context.Context, groupRef string) (TicketGroup, error)
+	// Retrieves core ticket information for a given list of Ticket_Ids.
+	GetTicketsCoreByIDs(ctx context.Context, ticketIds []string) ([]Ticket, error)
+	// Locks a ticket row and returns its current status and payment status for the state machine check.
+	GetTicketStatesForUpdate(ctx context.Context, ticketID string) (GetTicketStatesForUpdateRow, error)
 	// Retrieves just the status of a ticket.
 	GetTicketStatus(ctx context.Context, ticketID string) (int16, error)
 	// Retrieves all core ticket information for a given Customer_Id.
 	GetTicketsByCustomerIDCore(ctx context.Context, customerID sql.NullInt32) ([]Ticket, error)
 	// Retrieves the total number of tickets.
 	GetTotalTicketCount(ctx context.Context) (int64, error)
+	// Lists confirmed and checked-in seat tickets of a trip for the offline boarding manifest.
+	// Per-seat passenger (group bookings) takes precedence over the name/phone of the booker.
+	GetTripManifest(ctx context.Context, tripID string) ([]GetTripManifestRow, error)
+	// Locks a waitlist entry while seats are offered to it.
+	GetWaitlistEntryForUpdate(ctx context.Context, id int32) (TripWaitlist, error)
+	// Checks whether a group reference is already used.
+	GroupRefExists(ctx context.Context, groupRef string) (bool, error)
 	// Checks if a specific seat_id is booked on a specific trip - now uses trip_id directly.
 	IsSeatBookedOnTrip(ctx context.Context, arg IsSeatBookedOnTripParams) (bool, error)
 	// Checks if a specific seat_id is currently booked or pending (status 0 or 1).
 	IsSeatGenerallyBooked(ctx context.Context, seatID int32) (bool, error)
-	// Lists all seats for a trip_id that are not in seat_tickets or have status 2 (cancelled).
-	ListAvailableSeatsByTripID(ctx context.Context, tripID string) ([]ListAvailableSeatsByTripIDRow, error)
+	// Lists the pending / active tickets that have a leg on a trip (trip cancelled or rescheduled).
+	ListActiveTicketIDsByTripID(ctx context.Context, tripID string) ([]string, error)
+	// Lists seats currently held for a customer from the waitlist.
+	ListActiveWaitlistOffersByCustomer(ctx context.Context, customerID int32) ([]ListActiveWaitlistOffersByCustomerRow, error)
+	// Lists all seats for a trip_id that are free on the segment [from_stop, to_stop)
+	// (no active seat_ticket overlapping the segment; status 2 (cancelled) does not count).
+	ListAvailableSeatsByTripID(ctx context.Context, arg ListAvailableSeatsByTripIDParams) ([]ListAvailableSeatsByTripIDRow, error)
+	// Retrieves refund rules of a policy, most generous (longest notice) first.
+	ListRefundRulesByPolicyID(ctx context.Context, policyID int32) ([]PolicyRefundRule, error)
+	// Lists seat positions of a layout template.
+	ListSeatLayoutSeats(ctx context.Context, layoutID int32) ([]SeatLayoutSeat, error)
+	// Lists all seat layout templates.
+	ListSeatLayouts(ctx context.Context) ([]SeatLayout, error)
+	// Locks the seat_tickets of a ticket and returns their current status for the state machine check.
+	ListSeatTicketStatusesForUpdate(ctx context.Context, ticketID string) ([]ListSeatTicketStatusesForUpdateRow, error)
+	// Lists the tickets of a group booking in booking order.
+	ListTicketIDsByGroupRef(ctx context.Context, groupRef string) ([]string, error)
+	// Lists the audit history of a ticket in chronological order.
+	ListTicketLogs(ctx context.Context, ticketID string) ([]TicketLog, error)
+	// Trips that still have confirmed seats not yet checked in (candidates for no-show marking).
+	ListTripIDsWithConfirmedSeats(ctx context.Context) ([]string, error)
+	// Lists waiting entries of a trip in queue order.
+	ListWaitingEntriesByTripID(ctx context.Context, arg ListWaitingEntriesByTripIDParams) ([]TripWaitlist, error)
+	// Lists the waitlist entries of a customer with their place in line (only meaningful while status = 0).
+	ListWaitlistEntriesByCustomer(ctx context.Context, customerID int32) ([]ListWaitlistEntriesByCustomerRow, error)
+	// Marks confirmed seats of a trip boarding at or before stop @max_from_stop as no-show (status 4).
+	MarkSeatTicketsNoShow(ctx context.Context, arg MarkSeatTicketsNoShowParams) ([]SeatTicket, error)
+	// Releases the seats still held for a waitlist entry the customer left (status 3).
+	ReleaseWaitlistOffers(ctx context.Context, waitlistID int32) (int64, error)
+	// Puts back a COMPLETED booking request whose ticket could not be cancelled, so the next sweep retries.
+	ReopenExpiredBookingRequest(ctx context.Context, bookingID uuid.UUID) error
+	// Staff ticket search. Every filter is optional (NULL = not filtered); trip_id matches either leg.
+	// Keyset pagination on (sort column, Ticket_Id): the cursor is the last row of the previous page,
+	// so pages stay stable while new tickets are booked. @sort_by is 'booking_time' or 'price'.
+	SearchTickets(ctx context.Context, arg SearchTicketsParams) ([]SearchTicketsRow, error)
+	// Marks a booking request as being processed by the consumer. A PROCESSING request can be picked up again
+	// (redelivered message after a crash): its ticket is only kept if CompleteBookingRequest succeeds.
+	StartBookingRequest(ctx context.Context, bookingID uuid.UUID) (BookingRequest, error)
 	// Updates the status of a seat_ticket entry by its ID.
 	UpdateSeatTicketStatus(ctx context.Context, arg UpdateSeatTicketStatusParams) (SeatTicket, error)
 	// Updates the seat_ticket status to 'checked-in'.
 	UpdateSeatTicketStatusAfterCheckin(ctx context.Context, arg UpdateSeatTicketStatusAfterCheckinParams) (SeatTicket, error)
-	// Updates the status of all seat_ticket entries for a given ticket_id.
+	// Updates the status of all not-cancelled seat_ticket entries for a given ticket_id.
 	UpdateSeatTicketStatusByTicketID(ctx context.Context, arg UpdateSeatTicketStatusByTicketIDParams) ([]SeatTicket, error)
+	// Updates trips, price and fare breakdown of a ticket after a seat/trip change.
+	UpdateTicketFare(ctx context.Context, arg UpdateTicketFareParams) (Ticket, error)
 	// Updates the payment_status and general status of a ticket.
 	UpdateTicketPaymentStatus(ctx context.Context, arg UpdateTicketPaymentStatusParams) (Ticket, error)
 	// Updates the status of a ticket.
 	UpdateTicketStatus(ctx context.Context, arg UpdateTicketStatusParams) (Ticket, error)
 	// Updates the ticket's main status to 'used'.
 	UpdateTicketStatusAfterCheckin(ctx context.Context, arg UpdateTicketStatusAfterCheckinParams) (Ticket, error)
+	UpdateWaitlistEntryStatus(ctx context.Context, arg UpdateWaitlistEntryStatusParams) error
+	// Creates or replaces the layout template of a vehicle type and seat count.
+	UpsertSeatLayout(ctx context.Context, arg UpsertSeatLayoutParams) (SeatLayout, error)
+	// Opens a free rebooking for a ticket of a rescheduled trip. Affects no row when the offer already
+	// exists for the same new departure (redelivered event), so the customer is not notified twice.
+	UpsertTripRebookingOffer(ctx context.Context, arg UpsertTripRebookingOfferParams) (int64, error)
+	// Marks a free rebooking as used, in the seat change transaction.
+	UseTripRebookingOffer(ctx context.Context, arg UseTripRebookingOfferParams) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
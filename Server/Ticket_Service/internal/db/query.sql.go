@@ -15,25 +15,61 @@ import (
 	"github.com/lib/pq"
 )
 
+const addTicketToGroup = `-- name: AddTicketToGroup :exec
+INSERT INTO ticket_group_members (ticket_id, group_ref)
+VALUES ($1, $2)
+`
+
+type AddTicketToGroupParams struct {
+	TicketID string `json:"ticket_id"`
+	GroupRef string `json:"group_ref"`
+}
+
+// Adds a ticket to a group booking.
+func (q *Queries) AddTicketToGroup(ctx context.Context, arg AddTicketToGroupParams) error {
+	_, err := q.db.ExecContext(ctx, addTicketToGroup, arg.TicketID, arg.GroupRef)
+	return err
+}
+
 const areSeatsAvailable = `-- name: AreSeatsAvailable :many
 SELECT s.id,
-       EXISTS(
+       (EXISTS(
            SELECT 1
            FROM seat_tickets st
-           WHERE st.seat_id = s.id AND st.status IN (0, 1, 3) -- 0: pending, 1: paid, 3: checked-in
-       ) as is_booked
+           WHERE st.seat_id = s.id AND st.status IN (0, 1, 3, 4) -- 0: pending, 1: paid, 3: checked-in, 4: no-show
+             AND st.from_stop < $1::smallint AND $2::smallint < st.to_stop
+       ) OR EXISTS(
+           SELECT 1
+           FROM waitlist_offers wo
+           WHERE wo.seat_id = s.id AND wo.status = 0 AND wo.expires_at > now()
+             AND wo.customer_id <> $3::int
+             AND wo.from_stop < $1::smallint AND $2::smallint < wo.to_stop
+       )) as is_booked
 FROM seats s
-WHERE s.id = ANY($1::int[])
+WHERE s.id = ANY($4::int[])
 `
 
+type AreSeatsAvailableParams struct {
+	ToStop           int16   `json:"to_stop"`
+	FromStop         int16   `json:"from_stop"`
+	HolderCustomerID int32   `json:"holder_customer_id"`
+	SeatIds          []int32 `json:"seat_ids"`
+}
+
 type AreSeatsAvailableRow struct {
 	ID       int32 `json:"id"`
 	IsBooked bool  `json:"is_booked"`
 }
 
-// Checks if a list of seats are available (not booked or held).
-func (q *Queries) AreSeatsAvailable(ctx context.Context, seatIds []int32) ([]AreSeatsAvailableRow, error) {
-	rows, err := q.db.QueryContext(ctx, areSeatsAvailable, pq.Array(seatIds))
+// Checks if a list of seats are available (not booked or held) on the segment [from_stop, to_stop).
+// Seats held for a waitlisted customer only count as free for that customer (holder_customer_id, 0 = nobody).
+func (q *Queries) AreSeatsAvailable(ctx context.Context, arg AreSeatsAvailableParams) ([]AreSeatsAvailableRow, error) {
+	rows, err := q.db.QueryContext(ctx, areSeatsAvailable,
+		arg.ToStop,
+		arg.FromStop,
+		arg.HolderCustomerID,
+		pq.Array(arg.SeatIds),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -55,12 +91,231 @@ func (q *Queries) AreSeatsAvailable(ctx context.Context, seatIds []int32) ([]Are
 	return items, nil
 }
 
+const cancelWaitlistEntry = `-- name: CancelWaitlistEntry :one
+UPDATE trip_waitlist
+SET status = 4, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND customer_id = $2 AND status IN (0, 1)
+RETURNING id, trip_id, customer_id, seat_count, from_stop, to_stop, status, created_at, updated_at
+`
+
+type CancelWaitlistEntryParams struct {
+	ID         int32 `json:"id"`
+	CustomerID int32 `json:"customer_id"`
+}
+
+// Removes a customer from the waitlist (status 4) if the entry is still waiting or holding seats.
+func (q *Queries) CancelWaitlistEntry(ctx context.Context, arg CancelWaitlistEntryParams) (TripWaitlist, error) {
+	row := q.db.QueryRowContext(ctx, cancelWaitlistEntry, arg.ID, arg.CustomerID)
+	var i TripWaitlist
+	err := row.Scan(
+		&i.ID,
+		&i.TripID,
+		&i.CustomerID,
+		&i.SeatCount,
+		&i.FromStop,
+		&i.ToStop,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const claimWaitlistOffers = `-- name: ClaimWaitlistOffers :execrows
+WITH claimed AS (
+    UPDATE waitlist_offers
+    SET status = 1, updated_at = CURRENT_TIMESTAMP
+    WHERE customer_id = $1 AND trip_id = $2 AND seat_id = ANY($3::int[])
+      AND status = 0 AND expires_at > now()
+    RETURNING waitlist_id
+)
+UPDATE trip_waitlist
+SET status = 2, updated_at = CURRENT_TIMESTAMP
+WHERE id IN (SELECT waitlist_id FROM claimed) AND status = 1
+`
+
+type ClaimWaitlistOffersParams struct {
+	CustomerID int32   `json:"customer_id"`
+	TripID     string  `json:"trip_id"`
+	SeatIds    []int32 `json:"seat_ids"`
+}
+
+// Marks the held seats a customer has just booked as claimed, and their waitlist entries as booked (status 2).
+func (q *Queries) ClaimWaitlistOffers(ctx context.Context, arg ClaimWaitlistOffersParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimWaitlistOffers, arg.CustomerID, arg.TripID, pq.Array(arg.SeatIds))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const closeTripWaitlist = `-- name: CloseTripWaitlist :many
+WITH released AS (
+    UPDATE waitlist_offers
+    SET status = 3, updated_at = CURRENT_TIMESTAMP
+    WHERE trip_id = $1::varchar AND status = 0
+)
+UPDATE trip_waitlist
+SET status = 4, updated_at = CURRENT_TIMESTAMP
+WHERE trip_id = $1::varchar AND status IN (0, 1)
+RETURNING id, trip_id, customer_id, seat_count, from_stop, to_stop, status, created_at, updated_at
+`
+
+// Closes the waitlist of a cancelled trip: held seats are released (status 3) and entries marked as left (status 4).
+func (q *Queries) CloseTripWaitlist(ctx context.Context, tripID string) ([]TripWaitlist, error) {
+	rows, err := q.db.QueryContext(ctx, closeTripWaitlist, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TripWaitlist
+	for rows.Next() {
+		var i TripWaitlist
+		if err := rows.Scan(
+			&i.ID,
+			&i.TripID,
+			&i.CustomerID,
+			&i.SeatCount,
+			&i.FromStop,
+			&i.ToStop,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeBookingRequest = `-- name: CompleteBookingRequest :execrows
+UPDATE booking_requests
+SET status = 'COMPLETED', ticket_id = $2, error_message = NULL, updated_at = NOW()
+WHERE booking_id = $1 AND status = 'PROCESSING'
+`
+
+type CompleteBookingRequestParams struct {
+	BookingID uuid.UUID      `json:"booking_id"`
+	TicketID  sql.NullString `json:"ticket_id"`
+}
+
+// Attaches the created ticket to a booking request, in the ticket creation transaction.
+// Affects no row if the request expired meanwhile, in which case the ticket must be rolled back.
+func (q *Queries) CompleteBookingRequest(ctx context.Context, arg CompleteBookingRequestParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, completeBookingRequest, arg.BookingID, arg.TicketID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const connectBookingRequest = `-- name: ConnectBookingRequest :one
+UPDATE booking_requests
+SET connected_at = COALESCE(connected_at, NOW()), updated_at = NOW()
+WHERE booking_id = $1 AND status <> 'EXPIRED'
+  AND (connected_at IS NOT NULL OR connect_deadline > NOW())
+RETURNING booking_id, customer_id, status, request_data, ticket_id, error_message, attempts, connect_deadline, connected_at, created_at, updated_at
+`
+
+// Records that the client is tracking the booking request, unless it already expired.
+func (q *Queries) ConnectBookingRequest(ctx context.Context, bookingID uuid.UUID) (BookingRequest, error) {
+	row := q.db.QueryRowContext(ctx, connectBookingRequest, bookingID)
+	var i BookingRequest
+	err := row.Scan(
+		&i.BookingID,
+		&i.CustomerID,
+		&i.Status,
+		&i.RequestData,
+		&i.TicketID,
+		&i.ErrorMessage,
+		&i.Attempts,
+		&i.ConnectDeadline,
+		&i.ConnectedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const countSearchTickets = `-- name: CountSearchTickets :one
+SELECT COUNT(*) FROM Ticket t
+WHERE ($1::varchar IS NULL OR t.Phone = $1)
+  AND ($2::varchar IS NULL OR lower(t.Email) = lower($2))
+  AND ($3::varchar IS NULL OR t.Name ILIKE '%' || $3 || '%')
+  AND ($4::varchar IS NULL OR t.Trip_Id_Begin = $4 OR t.Trip_Id_End = $4)
+  AND ($5::timestamp IS NULL OR t.Booking_Time >= $5)
+  AND ($6::timestamp IS NULL OR t.Booking_Time < $6)
+  AND ($7::smallint IS NULL OR t.Status = $7)
+  AND ($8::smallint IS NULL OR t.Payment_Status = $8)
+  AND ($9::smallint IS NULL OR t.Booking_Channel = $9)
+`
+
+type CountSearchTicketsParams struct {
+	Phone          sql.NullString `json:"phone"`
+	Email          sql.NullString `json:"email"`
+	Name           sql.NullString `json:"name"`
+	TripID         sql.NullString `json:"trip_id"`
+	BookedFrom     sql.NullTime   `json:"booked_from"`
+	BookedTo       sql.NullTime   `json:"booked_to"`
+	Status         sql.NullInt16  `json:"status"`
+	PaymentStatus  sql.NullInt16  `json:"payment_status"`
+	BookingChannel sql.NullInt16  `json:"booking_channel"`
+}
+
+// Counts tickets matching the SearchTickets filters (without the cursor).
+func (q *Queries) CountSearchTickets(ctx context.Context, arg CountSearchTicketsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countSearchTickets,
+		arg.Phone,
+		arg.Email,
+		arg.Name,
+		arg.TripID,
+		arg.BookedFrom,
+		arg.BookedTo,
+		arg.Status,
+		arg.PaymentStatus,
+		arg.BookingChannel,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createBookingRequest = `-- name: CreateBookingRequest :exec
+INSERT INTO booking_requests (booking_id, customer_id, request_data, connect_deadline)
+VALUES ($1, $2, $3, NOW() + make_interval(secs => $4::int))
+`
+
+type CreateBookingRequestParams struct {
+	BookingID             uuid.UUID       `json:"booking_id"`
+	CustomerID            sql.NullInt32   `json:"customer_id"`
+	RequestData           json.RawMessage `json:"request_data"`
+	ConnectTimeoutSeconds int32           `json:"connect_timeout_seconds"`
+}
+
+// Records a queued booking request; the client must track it (WebSocket or polling) within @connect_timeout_seconds.
+func (q *Queries) CreateBookingRequest(ctx context.Context, arg CreateBookingRequestParams) error {
+	_, err := q.db.ExecContext(ctx, createBookingRequest,
+		arg.BookingID,
+		arg.CustomerID,
+		arg.RequestData,
+		arg.ConnectTimeoutSeconds,
+	)
+	return err
+}
+
 const createCheckin = `-- name: CreateCheckin :one
 
 
 INSERT INTO checkins (seat_ticket_id, ticket_id, trip_id, seat_name, note)
 VALUES ($1, $2, $3, $4, $5)
-RETURNING id, seat_ticket_id, ticket_id, trip_id, seat_name, checked_in_at, note
+RETURNING id, seat_ticket_id, ticket_id, trip_id, seat_name, checked_in_at, note, source, device_id, client_scan_id, synced_at, conflict
 `
 
 type CreateCheckinParams struct {
@@ -90,6 +345,60 @@ func (q *Queries) CreateCheckin(ctx context.Context, arg CreateCheckinParams) (C
 		&i.SeatName,
 		&i.CheckedInAt,
 		&i.Note,
+		&i.Source,
+		&i.DeviceID,
+		&i.ClientScanID,
+		&i.SyncedAt,
+		&i.Conflict,
+	)
+	return i, err
+}
+
+const createOfflineCheckin = `-- name: CreateOfflineCheckin :one
+INSERT INTO checkins (seat_ticket_id, ticket_id, trip_id, seat_name, note, checked_in_at, source, device_id, client_scan_id, conflict)
+VALUES ($1, $2, $3, $4, $5, $6, 'offline', $7, $8, $9)
+RETURNING id, seat_ticket_id, ticket_id, trip_id, seat_name, checked_in_at, note, source, device_id, client_scan_id, synced_at, conflict
+`
+
+type CreateOfflineCheckinParams struct {
+	SeatTicketID int32          `json:"seat_ticket_id"`
+	TicketID     string         `json:"ticket_id"`
+	TripID       string         `json:"trip_id"`
+	SeatName     sql.NullString `json:"seat_name"`
+	Note         string         `json:"note"`
+	CheckedInAt  sql.NullTime   `json:"checked_in_at"`
+	DeviceID     sql.NullString `json:"device_id"`
+	ClientScanID sql.NullString `json:"client_scan_id"`
+	Conflict     sql.NullString `json:"conflict"`
+}
+
+// Inserts a check-in replayed from a conductor device, keeping the original scan time.
+func (q *Queries) CreateOfflineCheckin(ctx context.Context, arg CreateOfflineCheckinParams) (Checkin, error) {
+	row := q.db.QueryRowContext(ctx, createOfflineCheckin,
+		arg.SeatTicketID,
+		arg.TicketID,
+		arg.TripID,
+		arg.SeatName,
+		arg.Note,
+		arg.CheckedInAt,
+		arg.DeviceID,
+		arg.ClientScanID,
+		arg.Conflict,
+	)
+	var i Checkin
+	err := row.Scan(
+		&i.ID,
+		&i.SeatTicketID,
+		&i.TicketID,
+		&i.TripID,
+		&i.SeatName,
+		&i.CheckedInAt,
+		&i.Note,
+		&i.Source,
+		&i.DeviceID,
+		&i.ClientScanID,
+		&i.SyncedAt,
+		&i.Conflict,
 	)
 	return i, err
 }
@@ -120,7 +429,7 @@ func (q *Queries) CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventPa
 const createSeat = `-- name: CreateSeat :one
 INSERT INTO seats (trip_id, seat_name)
 VALUES ($1, $2)
-RETURNING id, trip_id, seat_name, created_at, updated_at
+RETURNING id, trip_id, seat_name, created_at, updated_at, layout_id, deck, row_no, col_no, seat_class
 `
 
 type CreateSeatParams struct {
@@ -138,30 +447,124 @@ func (q *Queries) CreateSeat(ctx context.Context, arg CreateSeatParams) (Seat, e
 		&i.SeatName,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LayoutID,
+		&i.Deck,
+		&i.RowNo,
+		&i.ColNo,
+		&i.SeatClass,
+	)
+	return i, err
+}
+
+const createSeatFromLayout = `-- name: CreateSeatFromLayout :one
+INSERT INTO seats (trip_id, seat_name, layout_id, deck, row_no, col_no, seat_class)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, trip_id, seat_name, created_at, updated_at, layout_id, deck, row_no, col_no, seat_class
+`
+
+type CreateSeatFromLayoutParams struct {
+	TripID    string         `json:"trip_id"`
+	SeatName  sql.NullString `json:"seat_name"`
+	LayoutID  sql.NullInt32  `json:"layout_id"`
+	Deck      sql.NullInt16  `json:"deck"`
+	RowNo     sql.NullInt16  `json:"row_no"`
+	ColNo     sql.NullInt16  `json:"col_no"`
+	SeatClass sql.NullString `json:"seat_class"`
+}
+
+// Inserts a seat for a trip with its position taken from a seat layout template.
+func (q *Queries) CreateSeatFromLayout(ctx context.Context, arg CreateSeatFromLayoutParams) (Seat, error) {
+	row := q.db.QueryRowContext(ctx, createSeatFromLayout,
+		arg.TripID,
+		arg.SeatName,
+		arg.LayoutID,
+		arg.Deck,
+		arg.RowNo,
+		arg.ColNo,
+		arg.SeatClass,
+	)
+	var i Seat
+	err := row.Scan(
+		&i.ID,
+		&i.TripID,
+		&i.SeatName,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LayoutID,
+		&i.Deck,
+		&i.RowNo,
+		&i.ColNo,
+		&i.SeatClass,
+	)
+	return i, err
+}
+
+const createSeatLayoutSeat = `-- name: CreateSeatLayoutSeat :one
+INSERT INTO seat_layout_seats (layout_id, seat_name, deck, row_no, col_no, seat_class)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, layout_id, seat_name, deck, row_no, col_no, seat_class
+`
+
+type CreateSeatLayoutSeatParams struct {
+	LayoutID  int32  `json:"layout_id"`
+	SeatName  string `json:"seat_name"`
+	Deck      int16  `json:"deck"`
+	RowNo     int16  `json:"row_no"`
+	ColNo     int16  `json:"col_no"`
+	SeatClass string `json:"seat_class"`
+}
+
+// Inserts a seat position into a layout template.
+func (q *Queries) CreateSeatLayoutSeat(ctx context.Context, arg CreateSeatLayoutSeatParams) (SeatLayoutSeat, error) {
+	row := q.db.QueryRowContext(ctx, createSeatLayoutSeat,
+		arg.LayoutID,
+		arg.SeatName,
+		arg.Deck,
+		arg.RowNo,
+		arg.ColNo,
+		arg.SeatClass,
+	)
+	var i SeatLayoutSeat
+	err := row.Scan(
+		&i.ID,
+		&i.LayoutID,
+		&i.SeatName,
+		&i.Deck,
+		&i.RowNo,
+		&i.ColNo,
+		&i.SeatClass,
 	)
 	return i, err
 }
 
 const createSeatTicket = `-- name: CreateSeatTicket :one
-INSERT INTO seat_tickets (seat_id, ticket_id, status, trip_id)
-VALUES ($1, $2, $3, $4)
-RETURNING id, seat_id, ticket_id, status, trip_id, created_at, updated_at
+INSERT INTO seat_tickets (seat_id, ticket_id, status, trip_id, from_stop, to_stop, passenger_name, passenger_phone)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, seat_id, ticket_id, status, trip_id, created_at, updated_at, from_stop, to_stop, passenger_name, passenger_phone
 `
 
 type CreateSeatTicketParams struct {
-	SeatID   int32  `json:"seat_id"`
-	TicketID string `json:"ticket_id"`
-	Status   int16  `json:"status"`
-	TripID   string `json:"trip_id"`
+	SeatID         int32          `json:"seat_id"`
+	TicketID       string         `json:"ticket_id"`
+	Status         int16          `json:"status"`
+	TripID         string         `json:"trip_id"`
+	FromStop       int16          `json:"from_stop"`
+	ToStop         int16          `json:"to_stop"`
+	PassengerName  sql.NullString `json:"passenger_name"`
+	PassengerPhone sql.NullString `json:"passenger_phone"`
 }
 
-// Links a seat to a ticket, now includes trip_id directly.
+// Links a seat to a ticket for the segment [from_stop, to_stop) of the trip.
 func (q *Queries) CreateSeatTicket(ctx context.Context, arg CreateSeatTicketParams) (SeatTicket, error) {
 	row := q.db.QueryRowContext(ctx, createSeatTicket,
 		arg.SeatID,
 		arg.TicketID,
 		arg.Status,
 		arg.TripID,
+		arg.FromStop,
+		arg.ToStop,
+		arg.PassengerName,
+		arg.PassengerPhone,
 	)
 	var i SeatTicket
 	err := row.Scan(
@@ -172,6 +575,10 @@ func (q *Queries) CreateSeatTicket(ctx context.Context, arg CreateSeatTicketPara
 		&i.TripID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FromStop,
+		&i.ToStop,
+		&i.PassengerName,
+		&i.PassengerPhone,
 	)
 	return i, err
 }
@@ -179,28 +586,29 @@ func (q *Queries) CreateSeatTicket(ctx context.Context, arg CreateSeatTicketPara
 const createTicket = `-- name: CreateTicket :one
 INSERT INTO Ticket (
     Ticket_Id, Trip_Id_Begin, Trip_Id_End, Type, Customer_Id, Phone, Email, Name, Price, Status,
-    Booking_Time, Payment_Status, Booking_Channel, Policy_Id, Booked_By
+    Booking_Time, Payment_Status, Booking_Channel, Policy_Id, Booked_By, Fare_Breakdown
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
-) RETURNING ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
+) RETURNING ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by, fare_breakdown
 `
 
 type CreateTicketParams struct {
-	TicketID       string         `json:"ticket_id"`
-	TripIDBegin    string         `json:"trip_id_begin"`
-	TripIDEnd      sql.NullString `json:"trip_id_end"`
-	Type           int16          `json:"type"`
-	CustomerID     sql.NullInt32  `json:"customer_id"`
-	Phone          sql.NullString `json:"phone"`
-	Email          sql.NullString `json:"email"`
-	Name           sql.NullString `json:"name"`
-	Price          float64        `json:"price"`
-	Status         int16          `json:"status"`
-	BookingTime    time.Time      `json:"booking_time"`
-	PaymentStatus  int16          `json:"payment_status"`
-	BookingChannel int16          `json:"booking_channel"`
-	PolicyID       int32          `json:"policy_id"`
-	BookedBy       sql.NullString `json:"booked_by"`
+	TicketID       string          `json:"ticket_id"`
+	TripIDBegin    string          `json:"trip_id_begin"`
+	TripIDEnd      sql.NullString  `json:"trip_id_end"`
+	Type           int16           `json:"type"`
+	CustomerID     sql.NullInt32   `json:"customer_id"`
+	Phone          sql.NullString  `json:"phone"`
+	Email          sql.NullString  `json:"email"`
+	Name           sql.NullString  `json:"name"`
+	Price          float64         `json:"price"`
+	Status         int16           `json:"status"`
+	BookingTime    time.Time       `json:"booking_time"`
+	PaymentStatus  int16           `json:"payment_status"`
+	BookingChannel int16           `json:"booking_channel"`
+	PolicyID       int32           `json:"policy_id"`
+	BookedBy       sql.NullString  `json:"booked_by"`
+	FareBreakdown  json.RawMessage `json:"fare_breakdown"`
 }
 
 // Inserts a new ticket record for both one-way and round-trip.
@@ -221,6 +629,7 @@ func (q *Queries) CreateTicket(ctx context.Context, arg CreateTicketParams) (Tic
 		arg.BookingChannel,
 		arg.PolicyID,
 		arg.BookedBy,
+		arg.FareBreakdown,
 	)
 	var i Ticket
 	err := row.Scan(
@@ -241,6 +650,7 @@ func (q *Queries) CreateTicket(ctx context.Context, arg CreateTicketParams) (Tic
 		&i.UpdatedAt,
 		&i.PolicyID,
 		&i.BookedBy,
+		&i.FareBreakdown,
 	)
 	return i, err
 }
@@ -284,10 +694,52 @@ func (q *Queries) CreateTicketDetails(ctx context.Context, arg CreateTicketDetai
 	return i, err
 }
 
+const createTicketGroup = `-- name: CreateTicketGroup :one
+INSERT INTO ticket_groups (group_ref, customer_id, organization_name, contact_name, contact_phone, contact_email, booked_by)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING group_ref, customer_id, organization_name, contact_name, contact_phone, contact_email, booked_by, created_at, updated_at
+`
+
+type CreateTicketGroupParams struct {
+	GroupRef         string         `json:"group_ref"`
+	CustomerID       sql.NullInt32  `json:"customer_id"`
+	OrganizationName sql.NullString `json:"organization_name"`
+	ContactName      string         `json:"contact_name"`
+	ContactPhone     string         `json:"contact_phone"`
+	ContactEmail     sql.NullString `json:"contact_email"`
+	BookedBy         sql.NullString `json:"booked_by"`
+}
+
+// Creates a group booking that ties several tickets (possibly on different trips) to one invoice.
+func (q *Queries) CreateTicketGroup(ctx context.Context, arg CreateTicketGroupParams) (TicketGroup, error) {
+	row := q.db.QueryRowContext(ctx, createTicketGroup,
+		arg.GroupRef,
+		arg.CustomerID,
+		arg.OrganizationName,
+		arg.ContactName,
+		arg.ContactPhone,
+		arg.ContactEmail,
+		arg.BookedBy,
+	)
+	var i TicketGroup
+	err := row.Scan(
+		&i.GroupRef,
+		&i.CustomerID,
+		&i.OrganizationName,
+		&i.ContactName,
+		&i.ContactPhone,
+		&i.ContactEmail,
+		&i.BookedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const createTicketLog = `-- name: CreateTicketLog :one
 INSERT INTO Ticket_Logs (Ticket_Id, Action)
 VALUES ($1, $2)
-RETURNING log_id, ticket_id, action, created_at
+RETURNING log_id, ticket_id, action, created_at, entity, seat_ticket_id, from_status, to_status, actor, reason
 `
 
 type CreateTicketLogParams struct {
@@ -304,99 +756,213 @@ func (q *Queries) CreateTicketLog(ctx context.Context, arg CreateTicketLogParams
 		&i.TicketID,
 		&i.Action,
 		&i.CreatedAt,
+		&i.Entity,
+		&i.SeatTicketID,
+		&i.FromStatus,
+		&i.ToStatus,
+		&i.Actor,
+		&i.Reason,
 	)
 	return i, err
 }
 
-const deleteOutboxEvents = `-- name: DeleteOutboxEvents :exec
-DELETE FROM outbox_events
-WHERE id = ANY($1::uuid[])
+const createTicketStatusLog = `-- name: CreateTicketStatusLog :exec
+INSERT INTO Ticket_Logs (Ticket_Id, Action, Entity, Seat_Ticket_Id, From_Status, To_Status, Actor, Reason)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 `
 
-// For the Outbox Poller/Relay
-func (q *Queries) DeleteOutboxEvents(ctx context.Context, eventIds []uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteOutboxEvents, pq.Array(eventIds))
-	return err
+type CreateTicketStatusLogParams struct {
+	TicketID     string         `json:"ticket_id"`
+	Action       string         `json:"action"`
+	Entity       sql.NullString `json:"entity"`
+	SeatTicketID sql.NullInt32  `json:"seat_ticket_id"`
+	FromStatus   sql.NullInt16  `json:"from_status"`
+	ToStatus     sql.NullInt16  `json:"to_status"`
+	Actor        sql.NullString `json:"actor"`
+	Reason       sql.NullString `json:"reason"`
 }
 
-const getAllCheckinsByTripID = `-- name: GetAllCheckinsByTripID :many
-SELECT id, seat_ticket_id, ticket_id, trip_id, seat_name, checked_in_at, note FROM checkins
-WHERE trip_id = $1
-ORDER BY checked_in_at DESC
-`
-
-func (q *Queries) GetAllCheckinsByTripID(ctx context.Context, tripID string) ([]Checkin, error) {
-	rows, err := q.db.QueryContext(ctx, getAllCheckinsByTripID, tripID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	items := []Checkin{}
-	for rows.Next() {
-		var i Checkin
-		if err := rows.Scan(
-			&i.ID,
-			&i.SeatTicketID,
-			&i.TicketID,
-			&i.TripID,
-			&i.SeatName,
-			&i.CheckedInAt,
-			&i.Note,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+// Records a status transition of a ticket, its payment or one of its seats.
+func (q *Queries) CreateTicketStatusLog(ctx context.Context, arg CreateTicketStatusLogParams) error {
+	_, err := q.db.ExecContext(ctx, createTicketStatusLog,
+		arg.TicketID,
+		arg.Action,
+		arg.Entity,
+		arg.SeatTicketID,
+		arg.FromStatus,
+		arg.ToStatus,
+		arg.Actor,
+		arg.Reason,
+	)
+	return err
 }
 
-const getAllTickets = `-- name: GetAllTickets :many
-SELECT ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by FROM Ticket
-ORDER BY Booking_Time DESC
-LIMIT $1
-OFFSET $2
+const createWaitlistEntry = `-- name: CreateWaitlistEntry :one
+INSERT INTO trip_waitlist (trip_id, customer_id, seat_count, from_stop, to_stop)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, trip_id, customer_id, seat_count, from_stop, to_stop, status, created_at, updated_at
 `
 
-type GetAllTicketsParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+type CreateWaitlistEntryParams struct {
+	TripID     string `json:"trip_id"`
+	CustomerID int32  `json:"customer_id"`
+	SeatCount  int16  `json:"seat_count"`
+	FromStop   int16  `json:"from_stop"`
+	ToStop     int16  `json:"to_stop"`
 }
 
-// Retrieves a paginated list of all tickets, ordered by booking time.
-func (q *Queries) GetAllTickets(ctx context.Context, arg GetAllTicketsParams) ([]Ticket, error) {
-	rows, err := q.db.QueryContext(ctx, getAllTickets, arg.Limit, arg.Offset)
+// Adds a customer to the waitlist of a sold-out trip.
+func (q *Queries) CreateWaitlistEntry(ctx context.Context, arg CreateWaitlistEntryParams) (TripWaitlist, error) {
+	row := q.db.QueryRowContext(ctx, createWaitlistEntry,
+		arg.TripID,
+		arg.CustomerID,
+		arg.SeatCount,
+		arg.FromStop,
+		arg.ToStop,
+	)
+	var i TripWaitlist
+	err := row.Scan(
+		&i.ID,
+		&i.TripID,
+		&i.CustomerID,
+		&i.SeatCount,
+		&i.FromStop,
+		&i.ToStop,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createWaitlistOffer = `-- name: CreateWaitlistOffer :one
+INSERT INTO waitlist_offers (waitlist_id, trip_id, seat_id, customer_id, from_stop, to_stop, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP + make_interval(secs => $7::int))
+RETURNING id, waitlist_id, trip_id, seat_id, customer_id, from_stop, to_stop, status, expires_at, created_at, updated_at
+`
+
+type CreateWaitlistOfferParams struct {
+	WaitlistID  int32  `json:"waitlist_id"`
+	TripID      string `json:"trip_id"`
+	SeatID      int32  `json:"seat_id"`
+	CustomerID  int32  `json:"customer_id"`
+	FromStop    int16  `json:"from_stop"`
+	ToStop      int16  `json:"to_stop"`
+	HoldSeconds int32  `json:"hold_seconds"`
+}
+
+// Holds a freed seat for a waitlist entry for @hold_seconds.
+func (q *Queries) CreateWaitlistOffer(ctx context.Context, arg CreateWaitlistOfferParams) (WaitlistOffer, error) {
+	row := q.db.QueryRowContext(ctx, createWaitlistOffer,
+		arg.WaitlistID,
+		arg.TripID,
+		arg.SeatID,
+		arg.CustomerID,
+		arg.FromStop,
+		arg.ToStop,
+		arg.HoldSeconds,
+	)
+	var i WaitlistOffer
+	err := row.Scan(
+		&i.ID,
+		&i.WaitlistID,
+		&i.TripID,
+		&i.SeatID,
+		&i.CustomerID,
+		&i.FromStop,
+		&i.ToStop,
+		&i.Status,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteSeatLayout = `-- name: DeleteSeatLayout :exec
+DELETE FROM seat_layouts
+WHERE id = $1
+`
+
+// Deletes a seat layout template (seats already generated keep their positions).
+func (q *Queries) DeleteSeatLayout(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, deleteSeatLayout, id)
+	return err
+}
+
+const deleteSeatLayoutSeats = `-- name: DeleteSeatLayoutSeats :exec
+DELETE FROM seat_layout_seats
+WHERE layout_id = $1
+`
+
+// Removes all seat positions of a layout template (before re-inserting them).
+func (q *Queries) DeleteSeatLayoutSeats(ctx context.Context, layoutID int32) error {
+	_, err := q.db.ExecContext(ctx, deleteSeatLayoutSeats, layoutID)
+	return err
+}
+
+const deleteSeatTicketsBySeatIDs = `-- name: DeleteSeatTicketsBySeatIDs :exec
+DELETE FROM seat_tickets
+WHERE ticket_id = $1 AND seat_id = ANY($2::int[])
+`
+
+type DeleteSeatTicketsBySeatIDsParams struct {
+	TicketID string  `json:"ticket_id"`
+	SeatIds  []int32 `json:"seat_ids"`
+}
+
+// Removes seat assignments of a ticket (used when the passenger changes seats).
+func (q *Queries) DeleteSeatTicketsBySeatIDs(ctx context.Context, arg DeleteSeatTicketsBySeatIDsParams) error {
+	_, err := q.db.ExecContext(ctx, deleteSeatTicketsBySeatIDs, arg.TicketID, pq.Array(arg.SeatIds))
+	return err
+}
+
+const deleteUnusedSeatsByTripID = `-- name: DeleteUnusedSeatsByTripID :execrows
+DELETE FROM seats s
+WHERE s.trip_id = $1
+  AND NOT EXISTS (SELECT 1 FROM seat_tickets st WHERE st.seat_id = s.id)
+`
+
+// Deletes the seats of a cancelled trip that were never booked; seats referenced by tickets are kept for history.
+func (q *Queries) DeleteUnusedSeatsByTripID(ctx context.Context, tripID string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUnusedSeatsByTripID, tripID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const expireUnconnectedBookingRequests = `-- name: ExpireUnconnectedBookingRequests :many
+UPDATE booking_requests
+SET status = 'EXPIRED', updated_at = NOW()
+WHERE booking_id IN (
+    SELECT booking_id FROM booking_requests
+    WHERE connected_at IS NULL AND connect_deadline <= NOW()
+      AND status IN ('QUEUED', 'PROCESSING', 'COMPLETED')
+    ORDER BY connect_deadline
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING booking_id, ticket_id
+`
+
+type ExpireUnconnectedBookingRequestsRow struct {
+	BookingID uuid.UUID      `json:"booking_id"`
+	TicketID  sql.NullString `json:"ticket_id"`
+}
+
+// Expires up to @batch_size unfinished booking requests nobody tracked before their deadline.
+// A returned ticket_id means the request was COMPLETED and its ticket must be cancelled.
+func (q *Queries) ExpireUnconnectedBookingRequests(ctx context.Context, batchSize int32) ([]ExpireUnconnectedBookingRequestsRow, error) {
+	rows, err := q.db.QueryContext(ctx, expireUnconnectedBookingRequests, batchSize)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Ticket{}
+	items := []ExpireUnconnectedBookingRequestsRow{}
 	for rows.Next() {
-		var i Ticket
-		if err := rows.Scan(
-			&i.TicketID,
-			&i.TripIDBegin,
-			&i.TripIDEnd,
-			&i.Type,
-			&i.CustomerID,
-			&i.Phone,
-			&i.Email,
-			&i.Name,
-			&i.Price,
-			&i.Status,
-			&i.BookingTime,
-			&i.PaymentStatus,
-			&i.BookingChannel,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.PolicyID,
-			&i.BookedBy,
-		); err != nil {
+		var i ExpireUnconnectedBookingRequestsRow
+		if err := rows.Scan(&i.BookingID, &i.TicketID); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -410,28 +976,33 @@ func (q *Queries) GetAllTickets(ctx context.Context, arg GetAllTicketsParams) ([
 	return items, nil
 }
 
-const getOutboxEvents = `-- name: GetOutboxEvents :many
-SELECT id, topic, key, payload, created_at FROM outbox_events
-ORDER BY created_at
-LIMIT $1
+const expireWaitlistEntries = `-- name: ExpireWaitlistEntries :many
+UPDATE trip_waitlist
+SET status = 3, updated_at = CURRENT_TIMESTAMP
+WHERE id = ANY($1::int[]) AND status = 1
+RETURNING id, trip_id, customer_id, seat_count, from_stop, to_stop, status, created_at, updated_at
 `
 
-// For the Outbox Poller/Relay
-func (q *Queries) GetOutboxEvents(ctx context.Context, limit int32) ([]OutboxEvent, error) {
-	rows, err := q.db.QueryContext(ctx, getOutboxEvents, limit)
+// Marks entries whose offer lapsed as expired (status 3) so the next customer in line gets the seats.
+func (q *Queries) ExpireWaitlistEntries(ctx context.Context, ids []int32) ([]TripWaitlist, error) {
+	rows, err := q.db.QueryContext(ctx, expireWaitlistEntries, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []OutboxEvent{}
+	items := []TripWaitlist{}
 	for rows.Next() {
-		var i OutboxEvent
+		var i TripWaitlist
 		if err := rows.Scan(
 			&i.ID,
-			&i.Topic,
-			&i.Key,
-			&i.Payload,
+			&i.TripID,
+			&i.CustomerID,
+			&i.SeatCount,
+			&i.FromStop,
+			&i.ToStop,
+			&i.Status,
 			&i.CreatedAt,
+			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -446,44 +1017,32 @@ func (q *Queries) GetOutboxEvents(ctx context.Context, limit int32) ([]OutboxEve
 	return items, nil
 }
 
-const getSeatByID = `-- name: GetSeatByID :one
-SELECT id, trip_id, seat_name, created_at, updated_at FROM seats
-WHERE id = $1
+const expireWaitlistOffers = `-- name: ExpireWaitlistOffers :many
+UPDATE waitlist_offers
+SET status = 2, updated_at = CURRENT_TIMESTAMP
+WHERE status = 0 AND expires_at <= now()
+RETURNING waitlist_id, trip_id
 `
 
-// Retrieves a specific seat by its ID.
-func (q *Queries) GetSeatByID(ctx context.Context, id int32) (Seat, error) {
-	row := q.db.QueryRowContext(ctx, getSeatByID, id)
-	var i Seat
-	err := row.Scan(
-		&i.ID,
-		&i.TripID,
-		&i.SeatName,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-	)
-	return i, err
+type ExpireWaitlistOffersRow struct {
+	WaitlistID int32  `json:"waitlist_id"`
+	TripID     string `json:"trip_id"`
 }
 
-const getSeatIDsByTicketID = `-- name: GetSeatIDsByTicketID :many
-SELECT seat_id FROM seat_tickets
-WHERE ticket_id = $1
-`
-
-// Retrieves seat_ids associated with a ticket_id.
-func (q *Queries) GetSeatIDsByTicketID(ctx context.Context, ticketID string) ([]int32, error) {
-	rows, err := q.db.QueryContext(ctx, getSeatIDsByTicketID, ticketID)
+// Expires seat holds that were not booked in time (status 2).
+func (q *Queries) ExpireWaitlistOffers(ctx context.Context) ([]ExpireWaitlistOffersRow, error) {
+	rows, err := q.db.QueryContext(ctx, expireWaitlistOffers)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []int32{}
+	items := []ExpireWaitlistOffersRow{}
 	for rows.Next() {
-		var seat_id int32
-		if err := rows.Scan(&seat_id); err != nil {
+		var i ExpireWaitlistOffersRow
+		if err := rows.Scan(&i.WaitlistID, &i.TripID); err != nil {
 			return nil, err
 		}
-		items = append(items, seat_id)
+		items = append(items, i)
 	}
 	if err := rows.Close(); err != nil {
 		return nil, err
@@ -494,133 +1053,106 @@ func (q *Queries) GetSeatIDsByTicketID(ctx context.Context, ticketID string) ([]
 	return items, nil
 }
 
-const getSeatTicketAndSeatInfoByTicketID = `-- name: GetSeatTicketAndSeatInfoByTicketID :one
-SELECT
-    st.id as seat_ticket_id, st.seat_id, st.ticket_id, st.status as seat_ticket_status, st.trip_id,
-    s.id as seat_table_id, s.seat_name
-FROM seat_tickets st
-JOIN seats s ON st.seat_id = s.id
-WHERE st.ticket_id = $1 AND st.status = 1
+const failBookingRequest = `-- name: FailBookingRequest :execrows
+UPDATE booking_requests
+SET status = 'FAILED', error_message = $2, updated_at = NOW()
+WHERE booking_id = $1 AND status IN ('QUEUED', 'PROCESSING')
 `
 
-type GetSeatTicketAndSeatInfoByTicketIDRow struct {
-	SeatTicketID     int32          `json:"seat_ticket_id"`
-	SeatID           int32          `json:"seat_id"`
-	TicketID         string         `json:"ticket_id"`
-	SeatTicketStatus int16          `json:"seat_ticket_status"`
-	TripID           string         `json:"trip_id"`
-	SeatTableID      int32          `json:"seat_table_id"`
-	SeatName         sql.NullString `json:"seat_name"`
+type FailBookingRequestParams struct {
+	BookingID    uuid.UUID      `json:"booking_id"`
+	ErrorMessage sql.NullString `json:"error_message"`
 }
 
-// Retrieves seat_ticket and associated seat details for a given ticket_id.
-func (q *Queries) GetSeatTicketAndSeatInfoByTicketID(ctx context.Context, ticketID string) (GetSeatTicketAndSeatInfoByTicketIDRow, error) {
-	row := q.db.QueryRowContext(ctx, getSeatTicketAndSeatInfoByTicketID, ticketID)
-	var i GetSeatTicketAndSeatInfoByTicketIDRow
-	err := row.Scan(
-		&i.SeatTicketID,
-		&i.SeatID,
-		&i.TicketID,
-		&i.SeatTicketStatus,
-		&i.TripID,
-		&i.SeatTableID,
-		&i.SeatName,
-	)
-	return i, err
+// Marks a booking request that could not be fulfilled (seats taken, invalid input, ...).
+func (q *Queries) FailBookingRequest(ctx context.Context, arg FailBookingRequestParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, failBookingRequest, arg.BookingID, arg.ErrorMessage)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const getSeatTicketByID = `-- name: GetSeatTicketByID :one
-
-SELECT st.id, st.seat_id, st.ticket_id, st.status, st.trip_id, st.created_at, st.updated_at, s.seat_name
-FROM seat_tickets st
-JOIN seats s ON st.seat_id = s.id
-WHERE st.seat_id = $1 and st.ticket_id = $2 and st.status  = 1
+const findSeatLayoutForVehicle = `-- name: FindSeatLayoutForVehicle :one
+SELECT id, vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns, created_at, updated_at FROM seat_layouts
+WHERE vehicle_type_id IN ($1::int, 0)
+ORDER BY (vehicle_type_id = $1::int) DESC, (seat_count = $2::int) DESC, id
+LIMIT 1
 `
 
-type GetSeatTicketByIDParams struct {
-	SeatID   int32  `json:"seat_id"`
-	TicketID string `json:"ticket_id"`
-}
-
-type GetSeatTicketByIDRow struct {
-	ID        int32          `json:"id"`
-	SeatID    int32          `json:"seat_id"`
-	TicketID  string         `json:"ticket_id"`
-	Status    int16          `json:"status"`
-	TripID    string         `json:"trip_id"`
-	CreatedAt sql.NullTime   `json:"created_at"`
-	UpdatedAt sql.NullTime   `json:"updated_at"`
-	SeatName  sql.NullString `json:"seat_name"`
+type FindSeatLayoutForVehicleParams struct {
+	VehicleTypeID int32 `json:"vehicle_type_id"`
+	SeatCount     int32 `json:"seat_count"`
 }
 
-// Typically checkin for confirmed/paid tickets
-// Retrieves a specific seat_ticket by its ID - now uses trip_id directly.
-func (q *Queries) GetSeatTicketByID(ctx context.Context, arg GetSeatTicketByIDParams) (GetSeatTicketByIDRow, error) {
-	row := q.db.QueryRowContext(ctx, getSeatTicketByID, arg.SeatID, arg.TicketID)
-	var i GetSeatTicketByIDRow
+// Picks the layout of a vehicle type (exact seat count first), falling back to the default layout (vehicle_type_id 0).
+func (q *Queries) FindSeatLayoutForVehicle(ctx context.Context, arg FindSeatLayoutForVehicleParams) (SeatLayout, error) {
+	row := q.db.QueryRowContext(ctx, findSeatLayoutForVehicle, arg.VehicleTypeID, arg.SeatCount)
+	var i SeatLayout
 	err := row.Scan(
 		&i.ID,
-		&i.SeatID,
-		&i.TicketID,
-		&i.Status,
-		&i.TripID,
+		&i.VehicleTypeID,
+		&i.SeatCount,
+		&i.Name,
+		&i.Decks,
+		&i.RowCount,
+		&i.ColumnCount,
+		pq.Array(&i.AisleAfterColumns),
 		&i.CreatedAt,
 		&i.UpdatedAt,
-		&i.SeatName,
 	)
 	return i, err
 }
 
-const getSeatTicketStatus = `-- name: GetSeatTicketStatus :one
-SELECT status FROM seat_tickets
-WHERE id = $1
+const getActivePolicyByID = `-- name: GetActivePolicyByID :one
+SELECT policy_id, name, discount_percent, round_trip_discount_percent, status, created_at, updated_at FROM policies
+WHERE policy_id = $1 AND status = 1
 `
 
-// Retrieves just the status of a seat_ticket.
-func (q *Queries) GetSeatTicketStatus(ctx context.Context, id int32) (int16, error) {
-	row := q.db.QueryRowContext(ctx, getSeatTicketStatus, id)
-	var status int16
-	err := row.Scan(&status)
-	return status, err
+// Retrieves an active pricing policy used by the fare engine.
+func (q *Queries) GetActivePolicyByID(ctx context.Context, policyID int32) (Policy, error) {
+	row := q.db.QueryRowContext(ctx, getActivePolicyByID, policyID)
+	var i Policy
+	err := row.Scan(
+		&i.PolicyID,
+		&i.Name,
+		&i.DiscountPercent,
+		&i.RoundTripDiscountPercent,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
 }
 
-const getSeatTicketsByTicketID = `-- name: GetSeatTicketsByTicketID :many
-SELECT st.id, st.seat_id, st.ticket_id, st.status, st.trip_id, st.created_at, st.updated_at, s.seat_name
-FROM seat_tickets st
-JOIN seats s ON st.seat_id = s.id
-WHERE st.ticket_id = $1
+const getAllCheckinsByTripID = `-- name: GetAllCheckinsByTripID :many
+SELECT id, seat_ticket_id, ticket_id, trip_id, seat_name, checked_in_at, note, source, device_id, client_scan_id, synced_at, conflict FROM checkins
+WHERE trip_id = $1
+ORDER BY checked_in_at DESC
 `
 
-type GetSeatTicketsByTicketIDRow struct {
-	ID        int32          `json:"id"`
-	SeatID    int32          `json:"seat_id"`
-	TicketID  string         `json:"ticket_id"`
-	Status    int16          `json:"status"`
-	TripID    string         `json:"trip_id"`
-	CreatedAt sql.NullTime   `json:"created_at"`
-	UpdatedAt sql.NullTime   `json:"updated_at"`
-	SeatName  sql.NullString `json:"seat_name"`
-}
-
-// Retrieves all seat_ticket entries for a given Ticket_Id, no longer needs JOIN with seats.
-func (q *Queries) GetSeatTicketsByTicketID(ctx context.Context, ticketID string) ([]GetSeatTicketsByTicketIDRow, error) {
-	rows, err := q.db.QueryContext(ctx, getSeatTicketsByTicketID, ticketID)
+func (q *Queries) GetAllCheckinsByTripID(ctx context.Context, tripID string) ([]Checkin, error) {
+	rows, err := q.db.QueryContext(ctx, getAllCheckinsByTripID, tripID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []GetSeatTicketsByTicketIDRow{}
+	items := []Checkin{}
 	for rows.Next() {
-		var i GetSeatTicketsByTicketIDRow
+		var i Checkin
 		if err := rows.Scan(
 			&i.ID,
-			&i.SeatID,
+			&i.SeatTicketID,
 			&i.TicketID,
-			&i.Status,
 			&i.TripID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
 			&i.SeatName,
+			&i.CheckedInAt,
+			&i.Note,
+			&i.Source,
+			&i.DeviceID,
+			&i.ClientScanID,
+			&i.SyncedAt,
+			&i.Conflict,
 		); err != nil {
 			return nil, err
 		}
@@ -635,43 +1167,47 @@ func (q *Queries) GetSeatTicketsByTicketID(ctx context.Context, ticketID string)
 	return items, nil
 }
 
-const getSeatTicketsByTicketIDs = `-- name: GetSeatTicketsByTicketIDs :many
-SELECT st.id, st.seat_id, st.ticket_id, st.status, st.trip_id, st.created_at, st.updated_at, s.seat_name
-FROM seat_tickets st
-JOIN seats s ON st.seat_id = s.id
-WHERE st.ticket_id = ANY($1::varchar[])
+const getAllTickets = `-- name: GetAllTickets :many
+SELECT ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by, fare_breakdown FROM Ticket
+ORDER BY Booking_Time DESC
+LIMIT $1
+OFFSET $2
 `
 
-type GetSeatTicketsByTicketIDsRow struct {
-	ID        int32          `json:"id"`
-	SeatID    int32          `json:"seat_id"`
-	TicketID  string         `json:"ticket_id"`
-	Status    int16          `json:"status"`
-	TripID    string         `json:"trip_id"`
-	CreatedAt sql.NullTime   `json:"created_at"`
-	UpdatedAt sql.NullTime   `json:"updated_at"`
-	SeatName  sql.NullString `json:"seat_name"`
+type GetAllTicketsParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
 }
 
-// Retrieves all seat_ticket entries for a given list of Ticket_Ids.
-func (q *Queries) GetSeatTicketsByTicketIDs(ctx context.Context, ticketIds []string) ([]GetSeatTicketsByTicketIDsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getSeatTicketsByTicketIDs, pq.Array(ticketIds))
+// Retrieves a paginated list of all tickets, ordered by booking time.
+func (q *Queries) GetAllTickets(ctx context.Context, arg GetAllTicketsParams) ([]Ticket, error) {
+	rows, err := q.db.QueryContext(ctx, getAllTickets, arg.Limit, arg.Offset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []GetSeatTicketsByTicketIDsRow{}
+	items := []Ticket{}
 	for rows.Next() {
-		var i GetSeatTicketsByTicketIDsRow
+		var i Ticket
 		if err := rows.Scan(
-			&i.ID,
-			&i.SeatID,
 			&i.TicketID,
+			&i.TripIDBegin,
+			&i.TripIDEnd,
+			&i.Type,
+			&i.CustomerID,
+			&i.Phone,
+			&i.Email,
+			&i.Name,
+			&i.Price,
 			&i.Status,
-			&i.TripID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.SeatName,
+			&i.BookingTime,
+			&i.PaymentStatus,
+			&i.BookingChannel,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PolicyID,
+			&i.BookedBy,
+			&i.FareBreakdown,
 		); err != nil {
 			return nil, err
 		}
@@ -686,28 +1222,61 @@ func (q *Queries) GetSeatTicketsByTicketIDs(ctx context.Context, ticketIds []str
 	return items, nil
 }
 
-const getSeatsByTripID = `-- name: GetSeatsByTripID :many
-SELECT id, trip_id, seat_name, created_at, updated_at FROM seats
-WHERE trip_id = $1
-ORDER BY seat_name
+const getBoardingManifest = `-- name: GetBoardingManifest :many
+SELECT st.id, st.seat_id, st.ticket_id, st.status, st.from_stop, st.to_stop, s.seat_name,
+       COALESCE(st.passenger_name, t.name) AS name, COALESCE(st.passenger_phone, t.phone) AS phone,
+       t.payment_status, c.checked_in_at
+FROM seat_tickets st
+JOIN seats s ON st.seat_id = s.id
+JOIN Ticket t ON t.Ticket_Id = st.ticket_id
+LEFT JOIN LATERAL (
+    SELECT ci.checked_in_at
+    FROM checkins ci
+    WHERE ci.seat_ticket_id = st.seat_id AND ci.ticket_id = st.ticket_id AND ci.conflict IS NULL
+    ORDER BY ci.checked_in_at
+    LIMIT 1
+) c ON true
+WHERE st.trip_id = $1 AND st.status <> 2 -- 2: cancelled
+ORDER BY s.seat_name, st.from_stop
 `
 
-// Retrieves all seats for a given trip_id.
-func (q *Queries) GetSeatsByTripID(ctx context.Context, tripID string) ([]Seat, error) {
-	rows, err := q.db.QueryContext(ctx, getSeatsByTripID, tripID)
+type GetBoardingManifestRow struct {
+	ID            int32          `json:"id"`
+	SeatID        int32          `json:"seat_id"`
+	TicketID      string         `json:"ticket_id"`
+	Status        int16          `json:"status"`
+	FromStop      int16          `json:"from_stop"`
+	ToStop        int16          `json:"to_stop"`
+	SeatName      sql.NullString `json:"seat_name"`
+	Name          sql.NullString `json:"name"`
+	Phone         sql.NullString `json:"phone"`
+	PaymentStatus int16          `json:"payment_status"`
+	CheckedInAt   sql.NullTime   `json:"checked_in_at"`
+}
+
+// Lists every sold (not cancelled) seat of a trip with passenger, payment and check-in status for dispatchers.
+// Per-seat passenger (group bookings) takes precedence over the name/phone of the booker.
+func (q *Queries) GetBoardingManifest(ctx context.Context, tripID string) ([]GetBoardingManifestRow, error) {
+	rows, err := q.db.QueryContext(ctx, getBoardingManifest, tripID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Seat{}
+	items := []GetBoardingManifestRow{}
 	for rows.Next() {
-		var i Seat
+		var i GetBoardingManifestRow
 		if err := rows.Scan(
 			&i.ID,
-			&i.TripID,
+			&i.SeatID,
+			&i.TicketID,
+			&i.Status,
+			&i.FromStop,
+			&i.ToStop,
 			&i.SeatName,
-			&i.CreatedAt,
-			&i.UpdatedAt,
+			&i.Name,
+			&i.Phone,
+			&i.PaymentStatus,
+			&i.CheckedInAt,
 		); err != nil {
 			return nil, err
 		}
@@ -722,95 +1291,1459 @@ func (q *Queries) GetSeatsByTripID(ctx context.Context, tripID string) ([]Seat,
 	return items, nil
 }
 
-const getTicketByPhoneAndIDCore = `-- name: GetTicketByPhoneAndIDCore :one
-SELECT ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by FROM Ticket
-WHERE Ticket_Id = $1 AND Phone = $2
+const getBookingRequest = `-- name: GetBookingRequest :one
+SELECT booking_id, customer_id, status, request_data, ticket_id, error_message, attempts, connect_deadline, connected_at, created_at, updated_at FROM booking_requests
+WHERE booking_id = $1
 `
 
-type GetTicketByPhoneAndIDCoreParams struct {
-	TicketID string         `json:"ticket_id"`
-	Phone    sql.NullString `json:"phone"`
-}
-
-// Retrieves core ticket information by Ticket_Id and Phone.
-func (q *Queries) GetTicketByPhoneAndIDCore(ctx context.Context, arg GetTicketByPhoneAndIDCoreParams) (Ticket, error) {
-	row := q.db.QueryRowContext(ctx, getTicketByPhoneAndIDCore, arg.TicketID, arg.Phone)
-	var i Ticket
+// Retrieves a booking request by its booking id.
+func (q *Queries) GetBookingRequest(ctx context.Context, bookingID uuid.UUID) (BookingRequest, error) {
+	row := q.db.QueryRowContext(ctx, getBookingRequest, bookingID)
+	var i BookingRequest
 	err := row.Scan(
-		&i.TicketID,
-		&i.TripIDBegin,
-		&i.TripIDEnd,
-		&i.Type,
+		&i.BookingID,
 		&i.CustomerID,
-		&i.Phone,
-		&i.Email,
-		&i.Name,
-		&i.Price,
 		&i.Status,
-		&i.BookingTime,
-		&i.PaymentStatus,
-		&i.BookingChannel,
+		&i.RequestData,
+		&i.TicketID,
+		&i.ErrorMessage,
+		&i.Attempts,
+		&i.ConnectDeadline,
+		&i.ConnectedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
-		&i.PolicyID,
-		&i.BookedBy,
 	)
 	return i, err
 }
 
-const getTicketCore = `-- name: GetTicketCore :one
-SELECT ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by FROM Ticket
-WHERE Ticket_Id = $1
+const getCheckinByClientScanID = `-- name: GetCheckinByClientScanID :one
+SELECT id, seat_ticket_id, ticket_id, trip_id, seat_name, checked_in_at, note, source, device_id, client_scan_id, synced_at, conflict FROM checkins
+WHERE device_id = $1 AND client_scan_id = $2
 `
 
-// Retrieves core ticket information by Ticket_Id.
-func (q *Queries) GetTicketCore(ctx context.Context, ticketID string) (Ticket, error) {
-	row := q.db.QueryRowContext(ctx, getTicketCore, ticketID)
-	var i Ticket
+type GetCheckinByClientScanIDParams struct {
+	DeviceID     sql.NullString `json:"device_id"`
+	ClientScanID sql.NullString `json:"client_scan_id"`
+}
+
+// Finds a check-in already synced from the same device scan.
+func (q *Queries) GetCheckinByClientScanID(ctx context.Context, arg GetCheckinByClientScanIDParams) (Checkin, error) {
+	row := q.db.QueryRowContext(ctx, getCheckinByClientScanID, arg.DeviceID, arg.ClientScanID)
+	var i Checkin
 	err := row.Scan(
+		&i.ID,
+		&i.SeatTicketID,
 		&i.TicketID,
-		&i.TripIDBegin,
-		&i.TripIDEnd,
-		&i.Type,
-		&i.CustomerID,
-		&i.Phone,
-		&i.Email,
+		&i.TripID,
+		&i.SeatName,
+		&i.CheckedInAt,
+		&i.Note,
+		&i.Source,
+		&i.DeviceID,
+		&i.ClientScanID,
+		&i.SyncedAt,
+		&i.Conflict,
+	)
+	return i, err
+}
+
+const getFirstCheckinBySeatTicket = `-- name: GetFirstCheckinBySeatTicket :one
+SELECT id, seat_ticket_id, ticket_id, trip_id, seat_name, checked_in_at, note, source, device_id, client_scan_id, synced_at, conflict FROM checkins
+WHERE seat_ticket_id = $1 AND ticket_id = $2 AND conflict IS NULL
+ORDER BY checked_in_at
+LIMIT 1
+`
+
+type GetFirstCheckinBySeatTicketParams struct {
+	SeatTicketID int32  `json:"seat_ticket_id"`
+	TicketID     string `json:"ticket_id"`
+}
+
+// Returns the accepted check-in of a seat on a ticket (conflict rows are ignored).
+func (q *Queries) GetFirstCheckinBySeatTicket(ctx context.Context, arg GetFirstCheckinBySeatTicketParams) (Checkin, error) {
+	row := q.db.QueryRowContext(ctx, getFirstCheckinBySeatTicket, arg.SeatTicketID, arg.TicketID)
+	var i Checkin
+	err := row.Scan(
+		&i.ID,
+		&i.SeatTicketID,
+		&i.TicketID,
+		&i.TripID,
+		&i.SeatName,
+		&i.CheckedInAt,
+		&i.Note,
+		&i.Source,
+		&i.DeviceID,
+		&i.ClientScanID,
+		&i.SyncedAt,
+		&i.Conflict,
+	)
+	return i, err
+}
+
+const getGroupRefByTicketID = `-- name: GetGroupRefByTicketID :one
+SELECT group_ref FROM ticket_group_members
+WHERE ticket_id = $1
+`
+
+// Returns the group booking a ticket belongs to.
+func (q *Queries) GetGroupRefByTicketID(ctx context.Context, ticketID string) (string, error) {
+	row := q.db.QueryRowContext(ctx, getGroupRefByTicketID, ticketID)
+	var group_ref string
+	err := row.Scan(&group_ref)
+	return group_ref, err
+}
+
+const getOpenTripRebookingOffer = `-- name: GetOpenTripRebookingOffer :one
+SELECT ticket_id, trip_id, old_departure, new_departure, used_at, created_at, updated_at FROM trip_rebooking_offers
+WHERE ticket_id = $1 AND trip_id = $2 AND used_at IS NULL
+`
+
+type GetOpenTripRebookingOfferParams struct {
+	TicketID string `json:"ticket_id"`
+	TripID   string `json:"trip_id"`
+}
+
+// Retrieves the unused free rebooking of a ticket on a trip.
+func (q *Queries) GetOpenTripRebookingOffer(ctx context.Context, arg GetOpenTripRebookingOfferParams) (TripRebookingOffer, error) {
+	row := q.db.QueryRowContext(ctx, getOpenTripRebookingOffer, arg.TicketID, arg.TripID)
+	var i TripRebookingOffer
+	err := row.Scan(
+		&i.TicketID,
+		&i.TripID,
+		&i.OldDeparture,
+		&i.NewDeparture,
+		&i.UsedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getSeatByID = `-- name: GetSeatByID :one
+SELECT id, trip_id, seat_name, created_at, updated_at, layout_id, deck, row_no, col_no, seat_class FROM seats
+WHERE id = $1
+`
+
+// Retrieves a specific seat by its ID.
+func (q *Queries) GetSeatByID(ctx context.Context, id int32) (Seat, error) {
+	row := q.db.QueryRowContext(ctx, getSeatByID, id)
+	var i Seat
+	err := row.Scan(
+		&i.ID,
+		&i.TripID,
+		&i.SeatName,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LayoutID,
+		&i.Deck,
+		&i.RowNo,
+		&i.ColNo,
+		&i.SeatClass,
+	)
+	return i, err
+}
+
+const getSeatIDsByTicketID = `-- name: GetSeatIDsByTicketID :many
+SELECT seat_id FROM seat_tickets
+WHERE ticket_id = $1
+`
+
+// Retrieves seat_ids associated with a ticket_id.
+func (q *Queries) GetSeatIDsByTicketID(ctx context.Context, ticketID string) ([]int32, error) {
+	rows, err := q.db.QueryContext(ctx, getSeatIDsByTicketID, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int32{}
+	for rows.Next() {
+		var seat_id int32
+		if err := rows.Scan(&seat_id); err != nil {
+			return nil, err
+		}
+		items = append(items, seat_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSeatLayoutByID = `-- name: GetSeatLayoutByID :one
+SELECT id, vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns, created_at, updated_at FROM seat_layouts
+WHERE id = $1
+`
+
+// Retrieves a seat layout template by its ID.
+func (q *Queries) GetSeatLayoutByID(ctx context.Context, id int32) (SeatLayout, error) {
+	row := q.db.QueryRowContext(ctx, getSeatLayoutByID, id)
+	var i SeatLayout
+	err := row.Scan(
+		&i.ID,
+		&i.VehicleTypeID,
+		&i.SeatCount,
 		&i.Name,
-		&i.Price,
-		&i.Status,
-		&i.BookingTime,
-		&i.PaymentStatus,
-		&i.BookingChannel,
+		&i.Decks,
+		&i.RowCount,
+		&i.ColumnCount,
+		pq.Array(&i.AisleAfterColumns),
 		&i.CreatedAt,
 		&i.UpdatedAt,
-		&i.PolicyID,
-		&i.BookedBy,
 	)
 	return i, err
 }
 
-const getTicketDetailsByTicketID = `-- name: GetTicketDetailsByTicketID :many
-SELECT detail_id, ticket_id, pickup_location_begin, dropoff_location_begin, pickup_location_end, dropoff_location_end, created_at, updated_at FROM Ticket_Details
-WHERE Ticket_Id = $1
+const getSeatMapByTripID = `-- name: GetSeatMapByTripID :many
+SELECT s.id, s.seat_name, s.layout_id, s.deck, s.row_no, s.col_no, s.seat_class,
+       (EXISTS (
+           SELECT 1 FROM seat_tickets st
+           WHERE st.seat_id = s.id AND st.status IN (0, 1, 3, 4) -- 0: pending, 1: paid, 3: checked-in, 4: no-show
+             AND st.from_stop < $1::smallint AND $2::smallint < st.to_stop
+       ) OR EXISTS (
+           SELECT 1 FROM waitlist_offers wo
+           WHERE wo.seat_id = s.id AND wo.status = 0 AND wo.expires_at > now()
+             AND wo.from_stop < $1::smallint AND $2::smallint < wo.to_stop
+       )) AS is_booked
+FROM seats s
+WHERE s.trip_id = $3
+ORDER BY s.deck, s.row_no, s.col_no, s.seat_name
+`
+
+type GetSeatMapByTripIDParams struct {
+	ToStop   int16  `json:"to_stop"`
+	FromStop int16  `json:"from_stop"`
+	TripID   string `json:"trip_id"`
+}
+
+type GetSeatMapByTripIDRow struct {
+	ID        int32          `json:"id"`
+	SeatName  sql.NullString `json:"seat_name"`
+	LayoutID  sql.NullInt32  `json:"layout_id"`
+	Deck      sql.NullInt16  `json:"deck"`
+	RowNo     sql.NullInt16  `json:"row_no"`
+	ColNo     sql.NullInt16  `json:"col_no"`
+	SeatClass sql.NullString `json:"seat_class"`
+	IsBooked  bool           `json:"is_booked"`
+}
+
+// Lists all seats of a trip with their layout position and whether they are taken on the segment [from_stop, to_stop).
+func (q *Queries) GetSeatMapByTripID(ctx context.Context, arg GetSeatMapByTripIDParams) ([]GetSeatMapByTripIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSeatMapByTripID, arg.ToStop, arg.FromStop, arg.TripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetSeatMapByTripIDRow{}
+	for rows.Next() {
+		var i GetSeatMapByTripIDRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SeatName,
+			&i.LayoutID,
+			&i.Deck,
+			&i.RowNo,
+			&i.ColNo,
+			&i.SeatClass,
+			&i.IsBooked,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSeatTicketAndSeatInfoByTicketID = `-- name: GetSeatTicketAndSeatInfoByTicketID :one
+SELECT
+    st.id as seat_ticket_id, st.seat_id, st.ticket_id, st.status as seat_ticket_status, st.trip_id,
+    s.id as seat_table_id, s.seat_name
+FROM seat_tickets st
+JOIN seats s ON st.seat_id = s.id
+WHERE st.ticket_id = $1 AND st.status = 1
+`
+
+type GetSeatTicketAndSeatInfoByTicketIDRow struct {
+	SeatTicketID     int32          `json:"seat_ticket_id"`
+	SeatID           int32          `json:"seat_id"`
+	TicketID         string         `json:"ticket_id"`
+	SeatTicketStatus int16          `json:"seat_ticket_status"`
+	TripID           string         `json:"trip_id"`
+	SeatTableID      int32          `json:"seat_table_id"`
+	SeatName         sql.NullString `json:"seat_name"`
+}
+
+// Retrieves seat_ticket and associated seat details for a given ticket_id.
+func (q *Queries) GetSeatTicketAndSeatInfoByTicketID(ctx context.Context, ticketID string) (GetSeatTicketAndSeatInfoByTicketIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getSeatTicketAndSeatInfoByTicketID, ticketID)
+	var i GetSeatTicketAndSeatInfoByTicketIDRow
+	err := row.Scan(
+		&i.SeatTicketID,
+		&i.SeatID,
+		&i.TicketID,
+		&i.SeatTicketStatus,
+		&i.TripID,
+		&i.SeatTableID,
+		&i.SeatName,
+	)
+	return i, err
+}
+
+const getSeatTicketByID = `-- name: GetSeatTicketByID :one
+
+SELECT st.id, st.seat_id, st.ticket_id, st.status, st.trip_id, st.created_at, st.updated_at, st.from_stop, st.to_stop, st.passenger_name, st.passenger_phone, s.seat_name
+FROM seat_tickets st
+JOIN seats s ON st.seat_id = s.id
+WHERE st.seat_id = $1 and st.ticket_id = $2 and st.status IN (1, 4)
+`
+
+type GetSeatTicketByIDParams struct {
+	SeatID   int32  `json:"seat_id"`
+	TicketID string `json:"ticket_id"`
+}
+
+type GetSeatTicketByIDRow struct {
+	ID             int32          `json:"id"`
+	SeatID         int32          `json:"seat_id"`
+	TicketID       string         `json:"ticket_id"`
+	Status         int16          `json:"status"`
+	TripID         string         `json:"trip_id"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	UpdatedAt      sql.NullTime   `json:"updated_at"`
+	FromStop       int16          `json:"from_stop"`
+	ToStop         int16          `json:"to_stop"`
+	PassengerName  sql.NullString `json:"passenger_name"`
+	PassengerPhone sql.NullString `json:"passenger_phone"`
+	SeatName       sql.NullString `json:"seat_name"`
+}
+
+// Typically checkin for confirmed/paid tickets
+// Retrieves a specific seat_ticket by its ID - now uses trip_id directly.
+func (q *Queries) GetSeatTicketByID(ctx context.Context, arg GetSeatTicketByIDParams) (GetSeatTicketByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getSeatTicketByID, arg.SeatID, arg.TicketID)
+	var i GetSeatTicketByIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.SeatID,
+		&i.TicketID,
+		&i.Status,
+		&i.TripID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FromStop,
+		&i.ToStop,
+		&i.PassengerName,
+		&i.PassengerPhone,
+		&i.SeatName,
+	)
+	return i, err
+}
+
+const getSeatTicketForCheckin = `-- name: GetSeatTicketForCheckin :one
+SELECT st.id, st.seat_id, st.ticket_id, st.status, st.trip_id, st.created_at, st.updated_at, st.from_stop, st.to_stop, st.passenger_name, st.passenger_phone, s.seat_name
+FROM seat_tickets st
+JOIN seats s ON st.seat_id = s.id
+WHERE st.seat_id = $1 AND st.ticket_id = $2
+ORDER BY st.id DESC
+LIMIT 1
+FOR UPDATE OF st
+`
+
+type GetSeatTicketForCheckinParams struct {
+	SeatID   int32  `json:"seat_id"`
+	TicketID string `json:"ticket_id"`
+}
+
+type GetSeatTicketForCheckinRow struct {
+	ID             int32          `json:"id"`
+	SeatID         int32          `json:"seat_id"`
+	TicketID       string         `json:"ticket_id"`
+	Status         int16          `json:"status"`
+	TripID         string         `json:"trip_id"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	UpdatedAt      sql.NullTime   `json:"updated_at"`
+	FromStop       int16          `json:"from_stop"`
+	ToStop         int16          `json:"to_stop"`
+	PassengerName  sql.NullString `json:"passenger_name"`
+	PassengerPhone sql.NullString `json:"passenger_phone"`
+	SeatName       sql.NullString `json:"seat_name"`
+}
+
+// Locks the seat_ticket of a seat on a ticket for check-in, whatever its status.
+func (q *Queries) GetSeatTicketForCheckin(ctx context.Context, arg GetSeatTicketForCheckinParams) (GetSeatTicketForCheckinRow, error) {
+	row := q.db.QueryRowContext(ctx, getSeatTicketForCheckin, arg.SeatID, arg.TicketID)
+	var i GetSeatTicketForCheckinRow
+	err := row.Scan(
+		&i.ID,
+		&i.SeatID,
+		&i.TicketID,
+		&i.Status,
+		&i.TripID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FromStop,
+		&i.ToStop,
+		&i.PassengerName,
+		&i.PassengerPhone,
+		&i.SeatName,
+	)
+	return i, err
+}
+
+const getSeatTicketStatus = `-- name: GetSeatTicketStatus :one
+SELECT status FROM seat_tickets
+WHERE id = $1
+`
+
+// Retrieves just the status of a seat_ticket.
+func (q *Queries) GetSeatTicketStatus(ctx context.Context, id int32) (int16, error) {
+	row := q.db.QueryRowContext(ctx, getSeatTicketStatus, id)
+	var status int16
+	err := row.Scan(&status)
+	return status, err
+}
+
+const getSeatTicketsByTicketID = `-- name: GetSeatTicketsByTicketID :many
+SELECT st.id, st.seat_id, st.ticket_id, st.status, st.trip_id, st.created_at, st.updated_at, st.from_stop, st.to_stop, st.passenger_name, st.passenger_phone, s.seat_name
+FROM seat_tickets st
+JOIN seats s ON st.seat_id = s.id
+WHERE st.ticket_id = $1
+`
+
+type GetSeatTicketsByTicketIDRow struct {
+	ID             int32          `json:"id"`
+	SeatID         int32          `json:"seat_id"`
+	TicketID       string         `json:"ticket_id"`
+	Status         int16          `json:"status"`
+	TripID         string         `json:"trip_id"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	UpdatedAt      sql.NullTime   `json:"updated_at"`
+	FromStop       int16          `json:"from_stop"`
+	ToStop         int16          `json:"to_stop"`
+	PassengerName  sql.NullString `json:"passenger_name"`
+	PassengerPhone sql.NullString `json:"passenger_phone"`
+	SeatName       sql.NullString `json:"seat_name"`
+}
+
+// Retrieves all seat_ticket entries for a given Ticket_Id, no longer needs JOIN with seats.
+func (q *Queries) GetSeatTicketsByTicketID(ctx context.Context, ticketID string) ([]GetSeatTicketsByTicketIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSeatTicketsByTicketID, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetSeatTicketsByTicketIDRow{}
+	for rows.Next() {
+		var i GetSeatTicketsByTicketIDRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SeatID,
+			&i.TicketID,
+			&i.Status,
+			&i.TripID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FromStop,
+			&i.ToStop,
+			&i.PassengerName,
+			&i.PassengerPhone,
+			&i.SeatName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSeatTicketsByTicketIDs = `-- name: GetSeatTicketsByTicketIDs :many
+SELECT st.id, st.seat_id, st.ticket_id, st.status, st.trip_id, st.created_at, st.updated_at, st.from_stop, st.to_stop, st.passenger_name, st.passenger_phone, s.seat_name
+FROM seat_tickets st
+JOIN seats s ON st.seat_id = s.id
+WHERE st.ticket_id = ANY($1::varchar[])
+`
+
+type GetSeatTicketsByTicketIDsRow struct {
+	ID             int32          `json:"id"`
+	SeatID         int32          `json:"seat_id"`
+	TicketID       string         `json:"ticket_id"`
+	Status         int16          `json:"status"`
+	TripID         string         `json:"trip_id"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	UpdatedAt      sql.NullTime   `json:"updated_at"`
+	FromStop       int16          `json:"from_stop"`
+	ToStop         int16          `json:"to_stop"`
+	PassengerName  sql.NullString `json:"passenger_name"`
+	PassengerPhone sql.NullString `json:"passenger_phone"`
+	SeatName       sql.NullString `json:"seat_name"`
+}
+
+// Retrieves all seat_ticket entries for a given list of Ticket_Ids.
+func (q *Queries) GetSeatTicketsByTicketIDs(ctx context.Context, ticketIds []string) ([]GetSeatTicketsByTicketIDsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSeatTicketsByTicketIDs, pq.Array(ticketIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetSeatTicketsByTicketIDsRow{}
+	for rows.Next() {
+		var i GetSeatTicketsByTicketIDsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SeatID,
+			&i.TicketID,
+			&i.Status,
+			&i.TripID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FromStop,
+			&i.ToStop,
+			&i.PassengerName,
+			&i.PassengerPhone,
+			&i.SeatName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSeatsByTripID = `-- name: GetSeatsByTripID :many
+SELECT id, trip_id, seat_name, created_at, updated_at, layout_id, deck, row_no, col_no, seat_class FROM seats
+WHERE trip_id = $1
+ORDER BY seat_name
+`
+
+// Retrieves all seats for a given trip_id.
+func (q *Queries) GetSeatsByTripID(ctx context.Context, tripID string) ([]Seat, error) {
+	rows, err := q.db.QueryContext(ctx, getSeatsByTripID, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Seat{}
+	for rows.Next() {
+		var i Seat
+		if err := rows.Scan(
+			&i.ID,
+			&i.TripID,
+			&i.SeatName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LayoutID,
+			&i.Deck,
+			&i.RowNo,
+			&i.ColNo,
+			&i.SeatClass,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSeatTicketStatusForUpdate = `-- name: GetSeatTicketStatusForUpdate :one
+SELECT status FROM seat_tickets
+WHERE id = $1 AND ticket_id = $2
+FOR UPDATE
+`
+
+type GetSeatTicketStatusForUpdateParams struct {
+	ID       int32  `json:"id"`
+	TicketID string `json:"ticket_id"`
+}
+
+// Locks one seat_ticket of a ticket and returns its current status for the state machine check.
+func (q *Queries) GetSeatTicketStatusForUpdate(ctx context.Context, arg GetSeatTicketStatusForUpdateParams) (int16, error) {
+	row := q.db.QueryRowContext(ctx, getSeatTicketStatusForUpdate, arg.ID, arg.TicketID)
+	var status int16
+	err := row.Scan(&status)
+	return status, err
+}
+
+const getTicketByPhoneAndIDCore = `-- name: GetTicketByPhoneAndIDCore :one
+SELECT ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by, fare_breakdown FROM Ticket
+WHERE Ticket_Id = $1 AND Phone = $2
+`
+
+type GetTicketByPhoneAndIDCoreParams struct {
+	TicketID string         `json:"ticket_id"`
+	Phone    sql.NullString `json:"phone"`
+}
+
+// Retrieves core ticket information by Ticket_Id and Phone.
+func (q *Queries) GetTicketByPhoneAndIDCore(ctx context.Context, arg GetTicketByPhoneAndIDCoreParams) (Ticket, error) {
+	row := q.db.QueryRowContext(ctx, getTicketByPhoneAndIDCore, arg.TicketID, arg.Phone)
+	var i Ticket
+	err := row.Scan(
+		&i.TicketID,
+		&i.TripIDBegin,
+		&i.TripIDEnd,
+		&i.Type,
+		&i.CustomerID,
+		&i.Phone,
+		&i.Email,
+		&i.Name,
+		&i.Price,
+		&i.Status,
+		&i.BookingTime,
+		&i.PaymentStatus,
+		&i.BookingChannel,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.PolicyID,
+		&i.BookedBy,
+		&i.FareBreakdown,
+	)
+	return i, err
+}
+
+const getTicketCore = `-- name: GetTicketCore :one
+SELECT ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by, fare_breakdown FROM Ticket
+WHERE Ticket_Id = $1
+`
+
+// Retrieves core ticket information by Ticket_Id.
+func (q *Queries) GetTicketCore(ctx context.Context, ticketID string) (Ticket, error) {
+	row := q.db.QueryRowContext(ctx, getTicketCore, ticketID)
+	var i Ticket
+	err := row.Scan(
+		&i.TicketID,
+		&i.TripIDBegin,
+		&i.TripIDEnd,
+		&i.Type,
+		&i.CustomerID,
+		&i.Phone,
+		&i.Email,
+		&i.Name,
+		&i.Price,
+		&i.Status,
+		&i.BookingTime,
+		&i.PaymentStatus,
+		&i.BookingChannel,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.PolicyID,
+		&i.BookedBy,
+		&i.FareBreakdown,
+	)
+	return i, err
+}
+
+const getTicketDetailsByTicketID = `-- name: GetTicketDetailsByTicketID :many
+SELECT detail_id, ticket_id, pickup_location_begin, dropoff_location_begin, pickup_location_end, dropoff_location_end, created_at, updated_at FROM Ticket_Details
+WHERE Ticket_Id = $1
+`
+
+// Retrieves all details for a given Ticket_Id.
+func (q *Queries) GetTicketDetailsByTicketID(ctx context.Context, ticketID string) ([]TicketDetail, error) {
+	rows, err := q.db.QueryContext(ctx, getTicketDetailsByTicketID, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []TicketDetail{}
+	for rows.Next() {
+		var i TicketDetail
+		if err := rows.Scan(
+			&i.DetailID,
+			&i.TicketID,
+			&i.PickupLocationBegin,
+			&i.DropoffLocationBegin,
+			&i.PickupLocationEnd,
+			&i.DropoffLocationEnd,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTicketDetailsByTicketIDs = `-- name: GetTicketDetailsByTicketIDs :many
+SELECT detail_id, ticket_id, pickup_location_begin, dropoff_location_begin, pickup_location_end, dropoff_location_end, created_at, updated_at FROM Ticket_Details
+WHERE Ticket_Id = ANY($1::varchar[])
+`
+
+// Retrieves all details for a given list of Ticket_Ids.
+func (q *Queries) GetTicketDetailsByTicketIDs(ctx context.Context, ticketIds []string) ([]TicketDetail, error) {
+	rows, err := q.db.QueryContext(ctx, getTicketDetailsByTicketIDs, pq.Array(ticketIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []TicketDetail{}
+	for rows.Next() {
+		var i TicketDetail
+		if err := rows.Scan(
+			&i.DetailID,
+			&i.TicketID,
+			&i.PickupLocationBegin,
+			&i.DropoffLocationBegin,
+			&i.PickupLocationEnd,
+			&i.DropoffLocationEnd,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTicketGroup = `-- name: GetTicketGroup :one
+SELECT group_ref, customer_id, organization_name, contact_name, contact_phone, contact_email, booked_by, created_at, updated_at FROM ticket_groups
+WHERE group_ref = $1
+`
+
+// Retrieves a group booking by its reference.
+func (q *Queries) GetTicketGroup(ctx context.Context, groupRef string) (TicketGroup, error) {
+	row := q.db.QueryRowContext(ctx, getTicketGroup, groupRef)
+	var i TicketGroup
+	err := row.Scan(
+		&i.GroupRef,
+		&i.CustomerID,
+		&i.OrganizationName,
+		&i.ContactName,
+		&i.ContactPhone,
+		&i.ContactEmail,
+		&i.BookedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getTicketsCoreByIDs = `-- name: GetTicketsCoreByIDs :many
+SELECT ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by, fare_breakdown FROM Ticket
+WHERE Ticket_Id = ANY($1::varchar[])
+`
+
+// Retrieves core ticket information for a given list of Ticket_Ids.
+func (q *Queries) GetTicketsCoreByIDs(ctx context.Context, ticketIds []string) ([]Ticket, error) {
+	rows, err := q.db.QueryContext(ctx, getTicketsCoreByIDs, pq.Array(ticketIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Ticket{}
+	for rows.Next() {
+		var i Ticket
+		if err := rows.Scan(
+			&i.TicketID,
+			&i.TripIDBegin,
+			&i.TripIDEnd,
+			&i.Type,
+			&i.CustomerID,
+			&i.Phone,
+			&i.Email,
+			&i.Name,
+			&i.Price,
+			&i.Status,
+			&i.BookingTime,
+			&i.PaymentStatus,
+			&i.BookingChannel,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PolicyID,
+			&i.BookedBy,
+			&i.FareBreakdown,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTicketStatesForUpdate = `-- name: GetTicketStatesForUpdate :one
+SELECT status, payment_status FROM Ticket
+WHERE Ticket_Id = $1
+FOR UPDATE
+`
+
+type GetTicketStatesForUpdateRow struct {
+	Status        int16 `json:"status"`
+	PaymentStatus int16 `json:"payment_status"`
+}
+
+// Locks a ticket row and returns its current status and payment status for the state machine check.
+func (q *Queries) GetTicketStatesForUpdate(ctx context.Context, ticketID string) (GetTicketStatesForUpdateRow, error) {
+	row := q.db.QueryRowContext(ctx, getTicketStatesForUpdate, ticketID)
+	var i GetTicketStatesForUpdateRow
+	err := row.Scan(&i.Status, &i.PaymentStatus)
+	return i, err
+}
+
+const getTicketStatus = `-- name: GetTicketStatus :one
+SELECT status FROM Ticket
+WHERE Ticket_Id = $1
+`
+
+// Retrieves just the status of a ticket.
+func (q *Queries) GetTicketStatus(ctx context.Context, ticketID string) (int16, error) {
+	row := q.db.QueryRowContext(ctx, getTicketStatus, ticketID)
+	var status int16
+	err := row.Scan(&status)
+	return status, err
+}
+
+const getTicketsByCustomerIDCore = `-- name: GetTicketsByCustomerIDCore :many
+SELECT ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by, fare_breakdown FROM Ticket
+WHERE Customer_Id = $1
+ORDER BY Booking_Time DESC
+`
+
+// Retrieves all core ticket information for a given Customer_Id.
+func (q *Queries) GetTicketsByCustomerIDCore(ctx context.Context, customerID sql.NullInt32) ([]Ticket, error) {
+	rows, err := q.db.QueryContext(ctx, getTicketsByCustomerIDCore, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Ticket{}
+	for rows.Next() {
+		var i Ticket
+		if err := rows.Scan(
+			&i.TicketID,
+			&i.TripIDBegin,
+			&i.TripIDEnd,
+			&i.Type,
+			&i.CustomerID,
+			&i.Phone,
+			&i.Email,
+			&i.Name,
+			&i.Price,
+			&i.Status,
+			&i.BookingTime,
+			&i.PaymentStatus,
+			&i.BookingChannel,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PolicyID,
+			&i.BookedBy,
+			&i.FareBreakdown,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTotalTicketCount = `-- name: GetTotalTicketCount :one
+SELECT COUNT(*) FROM Ticket
+`
+
+// Retrieves the total number of tickets.
+func (q *Queries) GetTotalTicketCount(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getTotalTicketCount)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getTripManifest = `-- name: GetTripManifest :many
+SELECT st.id, st.seat_id, st.ticket_id, st.status, st.from_stop, st.to_stop, s.seat_name,
+       COALESCE(st.passenger_name, t.name) AS name, COALESCE(st.passenger_phone, t.phone) AS phone
+FROM seat_tickets st
+JOIN seats s ON st.seat_id = s.id
+JOIN Ticket t ON t.Ticket_Id = st.ticket_id
+WHERE st.trip_id = $1 AND st.status IN (1, 3) -- 1: confirmed, 3: checked-in
+ORDER BY s.seat_name, st.from_stop
+`
+
+type GetTripManifestRow struct {
+	ID       int32          `json:"id"`
+	SeatID   int32          `json:"seat_id"`
+	TicketID string         `json:"ticket_id"`
+	Status   int16          `json:"status"`
+	FromStop int16          `json:"from_stop"`
+	ToStop   int16          `json:"to_stop"`
+	SeatName sql.NullString `json:"seat_name"`
+	Name     sql.NullString `json:"name"`
+	Phone    sql.NullString `json:"phone"`
+}
+
+// Lists confirmed and checked-in seat tickets of a trip for the offline boarding manifest.
+// Per-seat passenger (group bookings) takes precedence over the name/phone of the booker.
+func (q *Queries) GetTripManifest(ctx context.Context, tripID string) ([]GetTripManifestRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTripManifest, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetTripManifestRow{}
+	for rows.Next() {
+		var i GetTripManifestRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SeatID,
+			&i.TicketID,
+			&i.Status,
+			&i.FromStop,
+			&i.ToStop,
+			&i.SeatName,
+			&i.Name,
+			&i.Phone,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWaitlistEntryForUpdate = `-- name: GetWaitlistEntryForUpdate :one
+SELECT id, trip_id, customer_id, seat_count, from_stop, to_stop, status, created_at, updated_at FROM trip_waitlist
+WHERE id = $1
+FOR UPDATE
+`
+
+// Locks a waitlist entry while seats are offered to it.
+func (q *Queries) GetWaitlistEntryForUpdate(ctx context.Context, id int32) (TripWaitlist, error) {
+	row := q.db.QueryRowContext(ctx, getWaitlistEntryForUpdate, id)
+	var i TripWaitlist
+	err := row.Scan(
+		&i.ID,
+		&i.TripID,
+		&i.CustomerID,
+		&i.SeatCount,
+		&i.FromStop,
+		&i.ToStop,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const groupRefExists = `-- name: GroupRefExists :one
+SELECT EXISTS (
+    SELECT 1 FROM ticket_groups WHERE group_ref = $1
+)
+`
+
+// Checks whether a group reference is already used.
+func (q *Queries) GroupRefExists(ctx context.Context, groupRef string) (bool, error) {
+	row := q.db.QueryRowContext(ctx, groupRefExists, groupRef)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const isSeatBookedOnTrip = `-- name: IsSeatBookedOnTrip :one
+SELECT EXISTS (
+    SELECT 1
+    FROM seat_tickets st
+    WHERE st.seat_id = $1 AND st.trip_id = $2 AND st.status IN (0, 1)
+)
+`
+
+type IsSeatBookedOnTripParams struct {
+	SeatID int32  `json:"seat_id"`
+	TripID string `json:"trip_id"`
+}
+
+// Checks if a specific seat_id is booked on a specific trip - now uses trip_id directly.
+func (q *Queries) IsSeatBookedOnTrip(ctx context.Context, arg IsSeatBookedOnTripParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isSeatBookedOnTrip, arg.SeatID, arg.TripID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const isSeatGenerallyBooked = `-- name: IsSeatGenerallyBooked :one
+SELECT EXISTS (
+    SELECT 1
+    FROM seat_tickets
+    WHERE seat_id = $1 AND status IN (0, 1)
+)
+`
+
+// Checks if a specific seat_id is currently booked or pending (status 0 or 1).
+func (q *Queries) IsSeatGenerallyBooked(ctx context.Context, seatID int32) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isSeatGenerallyBooked, seatID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const listActiveTicketIDsByTripID = `-- name: ListActiveTicketIDsByTripID :many
+SELECT Ticket_Id FROM Ticket
+WHERE (Trip_Id_Begin = $1::varchar OR Trip_Id_End = $1::varchar) AND Status IN (0, 1)
+ORDER BY Ticket_Id
+`
+
+// Lists the pending / active tickets that have a leg on a trip (trip cancelled or rescheduled).
+func (q *Queries) ListActiveTicketIDsByTripID(ctx context.Context, tripID string) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveTicketIDsByTripID, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var ticket_id string
+		if err := rows.Scan(&ticket_id); err != nil {
+			return nil, err
+		}
+		items = append(items, ticket_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listActiveWaitlistOffersByCustomer = `-- name: ListActiveWaitlistOffersByCustomer :many
+SELECT wo.id, wo.waitlist_id, wo.trip_id, wo.seat_id, s.seat_name, wo.expires_at
+FROM waitlist_offers wo
+JOIN seats s ON s.id = wo.seat_id
+WHERE wo.customer_id = $1 AND wo.status = 0 AND wo.expires_at > now()
+ORDER BY wo.waitlist_id, s.seat_name
+`
+
+type ListActiveWaitlistOffersByCustomerRow struct {
+	ID         int32          `json:"id"`
+	WaitlistID int32          `json:"waitlist_id"`
+	TripID     string         `json:"trip_id"`
+	SeatID     int32          `json:"seat_id"`
+	SeatName   sql.NullString `json:"seat_name"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+}
+
+// Lists seats currently held for a customer from the waitlist.
+func (q *Queries) ListActiveWaitlistOffersByCustomer(ctx context.Context, customerID int32) ([]ListActiveWaitlistOffersByCustomerRow, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveWaitlistOffersByCustomer, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListActiveWaitlistOffersByCustomerRow{}
+	for rows.Next() {
+		var i ListActiveWaitlistOffersByCustomerRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WaitlistID,
+			&i.TripID,
+			&i.SeatID,
+			&i.SeatName,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAvailableSeatsByTripID = `-- name: ListAvailableSeatsByTripID :many
+SELECT s.id, s.trip_id, s.seat_name
+FROM seats s
+WHERE s.trip_id = $1
+AND NOT EXISTS (
+    SELECT 1
+    FROM seat_tickets st
+    WHERE st.seat_id = s.id AND st.status IN (0, 1, 3, 4) -- 0: pending, 1: paid, 3: checked-in, 4: no-show
+      AND st.from_stop < $2::smallint AND $3::smallint < st.to_stop
+)
+AND NOT EXISTS (
+    SELECT 1
+    FROM waitlist_offers wo
+    WHERE wo.seat_id = s.id AND wo.status = 0 AND wo.expires_at > now() -- đang giữ cho khách trong danh sách chờ
+      AND wo.from_stop < $2::smallint AND $3::smallint < wo.to_stop
+)
+ORDER BY s.seat_name
+`
+
+type ListAvailableSeatsByTripIDParams struct {
+	TripID   string `json:"trip_id"`
+	ToStop   int16  `json:"to_stop"`
+	FromStop int16  `json:"from_stop"`
+}
+
+type ListAvailableSeatsByTripIDRow struct {
+	ID       int32          `json:"id"`
+	TripID   string         `json:"trip_id"`
+	SeatName sql.NullString `json:"seat_name"`
+}
+
+// Lists all seats for a trip_id that are free on the segment [from_stop, to_stop)
+// (no active seat_ticket or waitlist hold overlapping the segment; status 2 (cancelled) does not count).
+func (q *Queries) ListAvailableSeatsByTripID(ctx context.Context, arg ListAvailableSeatsByTripIDParams) ([]ListAvailableSeatsByTripIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAvailableSeatsByTripID, arg.TripID, arg.ToStop, arg.FromStop)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAvailableSeatsByTripIDRow{}
+	for rows.Next() {
+		var i ListAvailableSeatsByTripIDRow
+		if err := rows.Scan(&i.ID, &i.TripID, &i.SeatName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRefundRulesByPolicyID = `-- name: ListRefundRulesByPolicyID :many
+SELECT id, policy_id, min_hours_before_departure, refund_percent, created_at FROM policy_refund_rules
+WHERE policy_id = $1
+ORDER BY min_hours_before_departure DESC
+`
+
+// Retrieves refund rules of a policy, most generous (longest notice) first.
+func (q *Queries) ListRefundRulesByPolicyID(ctx context.Context, policyID int32) ([]PolicyRefundRule, error) {
+	rows, err := q.db.QueryContext(ctx, listRefundRulesByPolicyID, policyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []PolicyRefundRule{}
+	for rows.Next() {
+		var i PolicyRefundRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.PolicyID,
+			&i.MinHoursBeforeDeparture,
+			&i.RefundPercent,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSeatLayoutSeats = `-- name: ListSeatLayoutSeats :many
+SELECT id, layout_id, seat_name, deck, row_no, col_no, seat_class FROM seat_layout_seats
+WHERE layout_id = $1
+ORDER BY deck, row_no, col_no
+`
+
+// Lists seat positions of a layout template.
+func (q *Queries) ListSeatLayoutSeats(ctx context.Context, layoutID int32) ([]SeatLayoutSeat, error) {
+	rows, err := q.db.QueryContext(ctx, listSeatLayoutSeats, layoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SeatLayoutSeat{}
+	for rows.Next() {
+		var i SeatLayoutSeat
+		if err := rows.Scan(
+			&i.ID,
+			&i.LayoutID,
+			&i.SeatName,
+			&i.Deck,
+			&i.RowNo,
+			&i.ColNo,
+			&i.SeatClass,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSeatLayouts = `-- name: ListSeatLayouts :many
+SELECT id, vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns, created_at, updated_at FROM seat_layouts
+ORDER BY vehicle_type_id, seat_count
+`
+
+// Lists all seat layout templates.
+func (q *Queries) ListSeatLayouts(ctx context.Context) ([]SeatLayout, error) {
+	rows, err := q.db.QueryContext(ctx, listSeatLayouts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SeatLayout{}
+	for rows.Next() {
+		var i SeatLayout
+		if err := rows.Scan(
+			&i.ID,
+			&i.VehicleTypeID,
+			&i.SeatCount,
+			&i.Name,
+			&i.Decks,
+			&i.RowCount,
+			&i.ColumnCount,
+			pq.Array(&i.AisleAfterColumns),
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSeatTicketStatusesForUpdate = `-- name: ListSeatTicketStatusesForUpdate :many
+SELECT id, status FROM seat_tickets
+WHERE ticket_id = $1
+ORDER BY id
+FOR UPDATE
+`
+
+type ListSeatTicketStatusesForUpdateRow struct {
+	ID     int32 `json:"id"`
+	Status int16 `json:"status"`
+}
+
+// Locks the seat_tickets of a ticket and returns their current status for the state machine check.
+func (q *Queries) ListSeatTicketStatusesForUpdate(ctx context.Context, ticketID string) ([]ListSeatTicketStatusesForUpdateRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSeatTicketStatusesForUpdate, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSeatTicketStatusesForUpdateRow
+	for rows.Next() {
+		var i ListSeatTicketStatusesForUpdateRow
+		if err := rows.Scan(&i.ID, &i.Status); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTicketIDsByGroupRef = `-- name: ListTicketIDsByGroupRef :many
+SELECT ticket_id FROM ticket_group_members
+WHERE group_ref = $1
+ORDER BY created_at, ticket_id
+`
+
+// Lists the tickets of a group booking in booking order.
+func (q *Queries) ListTicketIDsByGroupRef(ctx context.Context, groupRef string) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listTicketIDsByGroupRef, groupRef)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var ticket_id string
+		if err := rows.Scan(&ticket_id); err != nil {
+			return nil, err
+		}
+		items = append(items, ticket_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTicketLogs = `-- name: ListTicketLogs :many
+SELECT log_id, ticket_id, action, created_at, entity, seat_ticket_id, from_status, to_status, actor, reason FROM Ticket_Logs
+WHERE Ticket_Id = $1
+ORDER BY Created_At, Log_Id
+`
+
+// Lists the audit history of a ticket in chronological order.
+func (q *Queries) ListTicketLogs(ctx context.Context, ticketID string) ([]TicketLog, error) {
+	rows, err := q.db.QueryContext(ctx, listTicketLogs, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TicketLog
+	for rows.Next() {
+		var i TicketLog
+		if err := rows.Scan(
+			&i.LogID,
+			&i.TicketID,
+			&i.Action,
+			&i.CreatedAt,
+			&i.Entity,
+			&i.SeatTicketID,
+			&i.FromStatus,
+			&i.ToStatus,
+			&i.Actor,
+			&i.Reason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTripIDsWithConfirmedSeats = `-- name: ListTripIDsWithConfirmedSeats :many
+SELECT DISTINCT trip_id FROM seat_tickets
+WHERE status = 1
+`
+
+// Trips that still have confirmed seats not yet checked in (candidates for no-show marking).
+func (q *Queries) ListTripIDsWithConfirmedSeats(ctx context.Context) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listTripIDsWithConfirmedSeats)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var trip_id string
+		if err := rows.Scan(&trip_id); err != nil {
+			return nil, err
+		}
+		items = append(items, trip_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWaitingEntriesByTripID = `-- name: ListWaitingEntriesByTripID :many
+SELECT id, trip_id, customer_id, seat_count, from_stop, to_stop, status, created_at, updated_at FROM trip_waitlist
+WHERE trip_id = $1 AND status = 0
+ORDER BY id
+LIMIT $2
 `
 
-// Retrieves all details for a given Ticket_Id.
-func (q *Queries) GetTicketDetailsByTicketID(ctx context.Context, ticketID string) ([]TicketDetail, error) {
-	rows, err := q.db.QueryContext(ctx, getTicketDetailsByTicketID, ticketID)
+type ListWaitingEntriesByTripIDParams struct {
+	TripID string `json:"trip_id"`
+	Limit  int32  `json:"limit"`
+}
+
+// Lists waiting entries of a trip in queue order.
+func (q *Queries) ListWaitingEntriesByTripID(ctx context.Context, arg ListWaitingEntriesByTripIDParams) ([]TripWaitlist, error) {
+	rows, err := q.db.QueryContext(ctx, listWaitingEntriesByTripID, arg.TripID, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []TicketDetail{}
+	items := []TripWaitlist{}
 	for rows.Next() {
-		var i TicketDetail
+		var i TripWaitlist
 		if err := rows.Scan(
-			&i.DetailID,
-			&i.TicketID,
-			&i.PickupLocationBegin,
-			&i.DropoffLocationBegin,
-			&i.PickupLocationEnd,
-			&i.DropoffLocationEnd,
+			&i.ID,
+			&i.TripID,
+			&i.CustomerID,
+			&i.SeatCount,
+			&i.FromStop,
+			&i.ToStop,
+			&i.Status,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 		); err != nil {
@@ -827,30 +2760,50 @@ func (q *Queries) GetTicketDetailsByTicketID(ctx context.Context, ticketID strin
 	return items, nil
 }
 
-const getTicketDetailsByTicketIDs = `-- name: GetTicketDetailsByTicketIDs :many
-SELECT detail_id, ticket_id, pickup_location_begin, dropoff_location_begin, pickup_location_end, dropoff_location_end, created_at, updated_at FROM Ticket_Details
-WHERE Ticket_Id = ANY($1::varchar[])
+const listWaitlistEntriesByCustomer = `-- name: ListWaitlistEntriesByCustomer :many
+SELECT w.id, w.trip_id, w.customer_id, w.seat_count, w.from_stop, w.to_stop, w.status, w.created_at, w.updated_at,
+       (SELECT COUNT(*) FROM trip_waitlist q
+        WHERE q.trip_id = w.trip_id AND q.status = 0 AND q.id <= w.id) AS position
+FROM trip_waitlist w
+WHERE w.customer_id = $1
+ORDER BY w.id DESC
+LIMIT 50
 `
 
-// Retrieves all details for a given list of Ticket_Ids.
-func (q *Queries) GetTicketDetailsByTicketIDs(ctx context.Context, ticketIds []string) ([]TicketDetail, error) {
-	rows, err := q.db.QueryContext(ctx, getTicketDetailsByTicketIDs, pq.Array(ticketIds))
+type ListWaitlistEntriesByCustomerRow struct {
+	ID         int32        `json:"id"`
+	TripID     string       `json:"trip_id"`
+	CustomerID int32        `json:"customer_id"`
+	SeatCount  int16        `json:"seat_count"`
+	FromStop   int16        `json:"from_stop"`
+	ToStop     int16        `json:"to_stop"`
+	Status     int16        `json:"status"`
+	CreatedAt  sql.NullTime `json:"created_at"`
+	UpdatedAt  sql.NullTime `json:"updated_at"`
+	Position   int64        `json:"position"`
+}
+
+// Lists the waitlist entries of a customer with their place in line (only meaningful while status = 0).
+func (q *Queries) ListWaitlistEntriesByCustomer(ctx context.Context, customerID int32) ([]ListWaitlistEntriesByCustomerRow, error) {
+	rows, err := q.db.QueryContext(ctx, listWaitlistEntriesByCustomer, customerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []TicketDetail{}
+	items := []ListWaitlistEntriesByCustomerRow{}
 	for rows.Next() {
-		var i TicketDetail
+		var i ListWaitlistEntriesByCustomerRow
 		if err := rows.Scan(
-			&i.DetailID,
-			&i.TicketID,
-			&i.PickupLocationBegin,
-			&i.DropoffLocationBegin,
-			&i.PickupLocationEnd,
-			&i.DropoffLocationEnd,
+			&i.ID,
+			&i.TripID,
+			&i.CustomerID,
+			&i.SeatCount,
+			&i.FromStop,
+			&i.ToStop,
+			&i.Status,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Position,
 		); err != nil {
 			return nil, err
 		}
@@ -865,53 +2818,40 @@ func (q *Queries) GetTicketDetailsByTicketIDs(ctx context.Context, ticketIds []s
 	return items, nil
 }
 
-const getTicketStatus = `-- name: GetTicketStatus :one
-SELECT status FROM Ticket
-WHERE Ticket_Id = $1
+const markSeatTicketsNoShow = `-- name: MarkSeatTicketsNoShow :many
+UPDATE seat_tickets
+SET status = 4, updated_at = CURRENT_TIMESTAMP
+WHERE trip_id = $1 AND status = 1 AND from_stop <= $2::smallint
+RETURNING id, seat_id, ticket_id, status, trip_id, created_at, updated_at, from_stop, to_stop, passenger_name, passenger_phone
 `
 
-// Retrieves just the status of a ticket.
-func (q *Queries) GetTicketStatus(ctx context.Context, ticketID string) (int16, error) {
-	row := q.db.QueryRowContext(ctx, getTicketStatus, ticketID)
-	var status int16
-	err := row.Scan(&status)
-	return status, err
+type MarkSeatTicketsNoShowParams struct {
+	TripID      string `json:"trip_id"`
+	MaxFromStop int16  `json:"max_from_stop"`
 }
 
-const getTicketsByCustomerIDCore = `-- name: GetTicketsByCustomerIDCore :many
-SELECT ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by FROM Ticket
-WHERE Customer_Id = $1
-ORDER BY Booking_Time DESC
-`
-
-// Retrieves all core ticket information for a given Customer_Id.
-func (q *Queries) GetTicketsByCustomerIDCore(ctx context.Context, customerID sql.NullInt32) ([]Ticket, error) {
-	rows, err := q.db.QueryContext(ctx, getTicketsByCustomerIDCore, customerID)
+// Marks confirmed seats of a trip boarding at or before stop @max_from_stop as no-show (status 4).
+func (q *Queries) MarkSeatTicketsNoShow(ctx context.Context, arg MarkSeatTicketsNoShowParams) ([]SeatTicket, error) {
+	rows, err := q.db.QueryContext(ctx, markSeatTicketsNoShow, arg.TripID, arg.MaxFromStop)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Ticket{}
+	items := []SeatTicket{}
 	for rows.Next() {
-		var i Ticket
+		var i SeatTicket
 		if err := rows.Scan(
+			&i.ID,
+			&i.SeatID,
 			&i.TicketID,
-			&i.TripIDBegin,
-			&i.TripIDEnd,
-			&i.Type,
-			&i.CustomerID,
-			&i.Phone,
-			&i.Email,
-			&i.Name,
-			&i.Price,
 			&i.Status,
-			&i.BookingTime,
-			&i.PaymentStatus,
-			&i.BookingChannel,
+			&i.TripID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
-			&i.PolicyID,
-			&i.BookedBy,
+			&i.FromStop,
+			&i.ToStop,
+			&i.PassengerName,
+			&i.PassengerPhone,
 		); err != nil {
 			return nil, err
 		}
@@ -926,84 +2866,151 @@ func (q *Queries) GetTicketsByCustomerIDCore(ctx context.Context, customerID sql
 	return items, nil
 }
 
-const getTotalTicketCount = `-- name: GetTotalTicketCount :one
-SELECT COUNT(*) FROM Ticket
+const releaseWaitlistOffers = `-- name: ReleaseWaitlistOffers :execrows
+UPDATE waitlist_offers
+SET status = 3, updated_at = CURRENT_TIMESTAMP
+WHERE waitlist_id = $1 AND status = 0
 `
 
-// Retrieves the total number of tickets.
-func (q *Queries) GetTotalTicketCount(ctx context.Context) (int64, error) {
-	row := q.db.QueryRowContext(ctx, getTotalTicketCount)
-	var count int64
-	err := row.Scan(&count)
-	return count, err
+// Releases the seats still held for a waitlist entry the customer left (status 3).
+func (q *Queries) ReleaseWaitlistOffers(ctx context.Context, waitlistID int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, releaseWaitlistOffers, waitlistID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const isSeatBookedOnTrip = `-- name: IsSeatBookedOnTrip :one
-SELECT EXISTS (
-    SELECT 1
-    FROM seat_tickets st
-    WHERE st.seat_id = $1 AND st.trip_id = $2 AND st.status IN (0, 1)
-)
+const reopenExpiredBookingRequest = `-- name: ReopenExpiredBookingRequest :exec
+UPDATE booking_requests
+SET status = 'COMPLETED', updated_at = NOW()
+WHERE booking_id = $1 AND status = 'EXPIRED' AND ticket_id IS NOT NULL
 `
 
-type IsSeatBookedOnTripParams struct {
-	SeatID int32  `json:"seat_id"`
-	TripID string `json:"trip_id"`
-}
-
-// Checks if a specific seat_id is booked on a specific trip - now uses trip_id directly.
-func (q *Queries) IsSeatBookedOnTrip(ctx context.Context, arg IsSeatBookedOnTripParams) (bool, error) {
-	row := q.db.QueryRowContext(ctx, isSeatBookedOnTrip, arg.SeatID, arg.TripID)
-	var exists bool
-	err := row.Scan(&exists)
-	return exists, err
+// Puts back a COMPLETED booking request whose ticket could not be cancelled, so the next sweep retries.
+func (q *Queries) ReopenExpiredBookingRequest(ctx context.Context, bookingID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, reopenExpiredBookingRequest, bookingID)
+	return err
 }
 
-const isSeatGenerallyBooked = `-- name: IsSeatGenerallyBooked :one
-SELECT EXISTS (
-    SELECT 1
-    FROM seat_tickets
-    WHERE seat_id = $1 AND status IN (0, 1)
-)
+const searchTickets = `-- name: SearchTickets :many
+SELECT t.ticket_id, t.trip_id_begin, t.trip_id_end, t.type, t.customer_id, t.phone, t.email, t.name, t.price, t.status, t.booking_time, t.payment_status, t.booking_channel, t.created_at, t.updated_at, t.policy_id, t.booked_by, t.fare_breakdown, COALESCE(m.group_ref, '')::varchar AS group_ref
+FROM Ticket t
+LEFT JOIN ticket_group_members m ON m.ticket_id = t.Ticket_Id
+WHERE ($1::varchar IS NULL OR t.Phone = $1)
+  AND ($2::varchar IS NULL OR lower(t.Email) = lower($2))
+  AND ($3::varchar IS NULL OR t.Name ILIKE '%' || $3 || '%')
+  AND ($4::varchar IS NULL OR t.Trip_Id_Begin = $4 OR t.Trip_Id_End = $4)
+  AND ($5::timestamp IS NULL OR t.Booking_Time >= $5)
+  AND ($6::timestamp IS NULL OR t.Booking_Time < $6)
+  AND ($7::smallint IS NULL OR t.Status = $7)
+  AND ($8::smallint IS NULL OR t.Payment_Status = $8)
+  AND ($9::smallint IS NULL OR t.Booking_Channel = $9)
+  AND ($10::varchar IS NULL OR CASE
+        WHEN $11::text = 'price' AND $12::boolean THEN (t.Price, t.Ticket_Id) < ($13::float8, $10)
+        WHEN $11 = 'price' THEN (t.Price, t.Ticket_Id) > ($13, $10)
+        WHEN $12 THEN (t.Booking_Time, t.Ticket_Id) < ($14::timestamp, $10)
+        ELSE (t.Booking_Time, t.Ticket_Id) > ($14, $10)
+      END)
+ORDER BY
+  CASE WHEN $11 = 'price' AND $12 THEN t.Price END DESC,
+  CASE WHEN $11 = 'price' AND NOT $12 THEN t.Price END ASC,
+  CASE WHEN $11 <> 'price' AND $12 THEN t.Booking_Time END DESC,
+  CASE WHEN $11 <> 'price' AND NOT $12 THEN t.Booking_Time END ASC,
+  CASE WHEN $12 THEN t.Ticket_Id END DESC,
+  CASE WHEN NOT $12 THEN t.Ticket_Id END ASC
+LIMIT $15
 `
 
-// Checks if a specific seat_id is currently booked or pending (status 0 or 1).
-func (q *Queries) IsSeatGenerallyBooked(ctx context.Context, seatID int32) (bool, error) {
-	row := q.db.QueryRowContext(ctx, isSeatGenerallyBooked, seatID)
-	var exists bool
-	err := row.Scan(&exists)
-	return exists, err
+type SearchTicketsParams struct {
+	Phone          sql.NullString  `json:"phone"`
+	Email          sql.NullString  `json:"email"`
+	Name           sql.NullString  `json:"name"`
+	TripID         sql.NullString  `json:"trip_id"`
+	BookedFrom     sql.NullTime    `json:"booked_from"`
+	BookedTo       sql.NullTime    `json:"booked_to"`
+	Status         sql.NullInt16   `json:"status"`
+	PaymentStatus  sql.NullInt16   `json:"payment_status"`
+	BookingChannel sql.NullInt16   `json:"booking_channel"`
+	CursorID       sql.NullString  `json:"cursor_id"`
+	SortBy         string          `json:"sort_by"`
+	SortDesc       bool            `json:"sort_desc"`
+	CursorPrice    sql.NullFloat64 `json:"cursor_price"`
+	CursorTime     sql.NullTime    `json:"cursor_time"`
+	RowLimit       int32           `json:"row_limit"`
 }
 
-const listAvailableSeatsByTripID = `-- name: ListAvailableSeatsByTripID :many
-SELECT s.id, s.trip_id, s.seat_name
-FROM seats s
-WHERE s.trip_id = $1
-AND NOT EXISTS (
-    SELECT 1
-    FROM seat_tickets st
-    WHERE st.seat_id = s.id AND st.status IN (0, 1, 3) -- 0: pending, 1: paid, 3: checked-in
-)
-ORDER BY s.seat_name
-`
-
-type ListAvailableSeatsByTripIDRow struct {
-	ID       int32          `json:"id"`
-	TripID   string         `json:"trip_id"`
-	SeatName sql.NullString `json:"seat_name"`
+type SearchTicketsRow struct {
+	TicketID       string          `json:"ticket_id"`
+	TripIDBegin    string          `json:"trip_id_begin"`
+	TripIDEnd      sql.NullString  `json:"trip_id_end"`
+	Type           int16           `json:"type"`
+	CustomerID     sql.NullInt32   `json:"customer_id"`
+	Phone          sql.NullString  `json:"phone"`
+	Email          sql.NullString  `json:"email"`
+	Name           sql.NullString  `json:"name"`
+	Price          float64         `json:"price"`
+	Status         int16           `json:"status"`
+	BookingTime    time.Time       `json:"booking_time"`
+	PaymentStatus  int16           `json:"payment_status"`
+	BookingChannel int16           `json:"booking_channel"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	PolicyID       int32           `json:"policy_id"`
+	BookedBy       sql.NullString  `json:"booked_by"`
+	FareBreakdown  json.RawMessage `json:"fare_breakdown"`
+	GroupRef       string          `json:"group_ref"`
 }
 
-// Lists all seats for a trip_id that are not in seat_tickets or have status 2 (cancelled).
-func (q *Queries) ListAvailableSeatsByTripID(ctx context.Context, tripID string) ([]ListAvailableSeatsByTripIDRow, error) {
-	rows, err := q.db.QueryContext(ctx, listAvailableSeatsByTripID, tripID)
+// Staff ticket search. Every filter is optional (NULL = not filtered); trip_id matches either leg.
+// Keyset pagination on (sort column, Ticket_Id): the cursor is the last row of the previous page,
+// so pages stay stable while new tickets are booked. @sort_by is 'booking_time' or 'price'.
+func (q *Queries) SearchTickets(ctx context.Context, arg SearchTicketsParams) ([]SearchTicketsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchTickets,
+		arg.Phone,
+		arg.Email,
+		arg.Name,
+		arg.TripID,
+		arg.BookedFrom,
+		arg.BookedTo,
+		arg.Status,
+		arg.PaymentStatus,
+		arg.BookingChannel,
+		arg.CursorID,
+		arg.SortBy,
+		arg.SortDesc,
+		arg.CursorPrice,
+		arg.CursorTime,
+		arg.RowLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []ListAvailableSeatsByTripIDRow{}
+	items := []SearchTicketsRow{}
 	for rows.Next() {
-		var i ListAvailableSeatsByTripIDRow
-		if err := rows.Scan(&i.ID, &i.TripID, &i.SeatName); err != nil {
+		var i SearchTicketsRow
+		if err := rows.Scan(
+			&i.TicketID,
+			&i.TripIDBegin,
+			&i.TripIDEnd,
+			&i.Type,
+			&i.CustomerID,
+			&i.Phone,
+			&i.Email,
+			&i.Name,
+			&i.Price,
+			&i.Status,
+			&i.BookingTime,
+			&i.PaymentStatus,
+			&i.BookingChannel,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PolicyID,
+			&i.BookedBy,
+			&i.FareBreakdown,
+			&i.GroupRef,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -1017,11 +3024,39 @@ func (q *Queries) ListAvailableSeatsByTripID(ctx context.Context, tripID string)
 	return items, nil
 }
 
+const startBookingRequest = `-- name: StartBookingRequest :one
+UPDATE booking_requests
+SET status = 'PROCESSING', attempts = attempts + 1, updated_at = NOW()
+WHERE booking_id = $1 AND status IN ('QUEUED', 'PROCESSING')
+RETURNING booking_id, customer_id, status, request_data, ticket_id, error_message, attempts, connect_deadline, connected_at, created_at, updated_at
+`
+
+// Marks a booking request as being processed by the consumer. A PROCESSING request can be picked up again
+// (redelivered message after a crash): its ticket is only kept if CompleteBookingRequest succeeds.
+func (q *Queries) StartBookingRequest(ctx context.Context, bookingID uuid.UUID) (BookingRequest, error) {
+	row := q.db.QueryRowContext(ctx, startBookingRequest, bookingID)
+	var i BookingRequest
+	err := row.Scan(
+		&i.BookingID,
+		&i.CustomerID,
+		&i.Status,
+		&i.RequestData,
+		&i.TicketID,
+		&i.ErrorMessage,
+		&i.Attempts,
+		&i.ConnectDeadline,
+		&i.ConnectedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const updateSeatTicketStatus = `-- name: UpdateSeatTicketStatus :one
 UPDATE seat_tickets
 SET status = $2, updated_at = CURRENT_TIMESTAMP
 WHERE id = $1
-RETURNING id, seat_id, ticket_id, status, trip_id, created_at, updated_at
+RETURNING id, seat_id, ticket_id, status, trip_id, created_at, updated_at, from_stop, to_stop, passenger_name, passenger_phone
 `
 
 type UpdateSeatTicketStatusParams struct {
@@ -1041,6 +3076,10 @@ func (q *Queries) UpdateSeatTicketStatus(ctx context.Context, arg UpdateSeatTick
 		&i.TripID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FromStop,
+		&i.ToStop,
+		&i.PassengerName,
+		&i.PassengerPhone,
 	)
 	return i, err
 }
@@ -1049,7 +3088,7 @@ const updateSeatTicketStatusAfterCheckin = `-- name: UpdateSeatTicketStatusAfter
 UPDATE seat_tickets
 SET status = $2, updated_at = CURRENT_TIMESTAMP -- $2 would be the 'checked-in' status
 WHERE id = $1 -- seat_ticket.id
-RETURNING id, seat_id, ticket_id, status, trip_id, created_at, updated_at
+RETURNING id, seat_id, ticket_id, status, trip_id, created_at, updated_at, from_stop, to_stop, passenger_name, passenger_phone
 `
 
 type UpdateSeatTicketStatusAfterCheckinParams struct {
@@ -1069,6 +3108,10 @@ func (q *Queries) UpdateSeatTicketStatusAfterCheckin(ctx context.Context, arg Up
 		&i.TripID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FromStop,
+		&i.ToStop,
+		&i.PassengerName,
+		&i.PassengerPhone,
 	)
 	return i, err
 }
@@ -1076,8 +3119,8 @@ func (q *Queries) UpdateSeatTicketStatusAfterCheckin(ctx context.Context, arg Up
 const updateSeatTicketStatusByTicketID = `-- name: UpdateSeatTicketStatusByTicketID :many
 UPDATE seat_tickets
 SET status = $2, updated_at = CURRENT_TIMESTAMP
-WHERE ticket_id = $1
-RETURNING id, seat_id, ticket_id, status, trip_id, created_at, updated_at
+WHERE ticket_id = $1 AND status <> 2 -- ghế đã huỷ riêng (hành khách rời đoàn) không được khôi phục
+RETURNING id, seat_id, ticket_id, status, trip_id, created_at, updated_at, from_stop, to_stop, passenger_name, passenger_phone
 `
 
 type UpdateSeatTicketStatusByTicketIDParams struct {
@@ -1085,7 +3128,7 @@ type UpdateSeatTicketStatusByTicketIDParams struct {
 	Status   int16  `json:"status"`
 }
 
-// Updates the status of all seat_ticket entries for a given ticket_id.
+// Updates the status of all not-cancelled seat_ticket entries for a given ticket_id.
 func (q *Queries) UpdateSeatTicketStatusByTicketID(ctx context.Context, arg UpdateSeatTicketStatusByTicketIDParams) ([]SeatTicket, error) {
 	rows, err := q.db.QueryContext(ctx, updateSeatTicketStatusByTicketID, arg.TicketID, arg.Status)
 	if err != nil {
@@ -1103,6 +3146,10 @@ func (q *Queries) UpdateSeatTicketStatusByTicketID(ctx context.Context, arg Upda
 			&i.TripID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.FromStop,
+			&i.ToStop,
+			&i.PassengerName,
+			&i.PassengerPhone,
 		); err != nil {
 			return nil, err
 		}
@@ -1117,11 +3164,59 @@ func (q *Queries) UpdateSeatTicketStatusByTicketID(ctx context.Context, arg Upda
 	return items, nil
 }
 
+const updateTicketFare = `-- name: UpdateTicketFare :one
+UPDATE Ticket
+SET Trip_Id_Begin = $2, Trip_Id_End = $3, Price = $4, Fare_Breakdown = $5, Updated_At = CURRENT_TIMESTAMP
+WHERE Ticket_Id = $1
+RETURNING ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by, fare_breakdown
+`
+
+type UpdateTicketFareParams struct {
+	TicketID      string          `json:"ticket_id"`
+	TripIDBegin   string          `json:"trip_id_begin"`
+	TripIDEnd     sql.NullString  `json:"trip_id_end"`
+	Price         float64         `json:"price"`
+	FareBreakdown json.RawMessage `json:"fare_breakdown"`
+}
+
+// Updates trips, price and fare breakdown of a ticket after a seat/trip change.
+func (q *Queries) UpdateTicketFare(ctx context.Context, arg UpdateTicketFareParams) (Ticket, error) {
+	row := q.db.QueryRowContext(ctx, updateTicketFare,
+		arg.TicketID,
+		arg.TripIDBegin,
+		arg.TripIDEnd,
+		arg.Price,
+		arg.FareBreakdown,
+	)
+	var i Ticket
+	err := row.Scan(
+		&i.TicketID,
+		&i.TripIDBegin,
+		&i.TripIDEnd,
+		&i.Type,
+		&i.CustomerID,
+		&i.Phone,
+		&i.Email,
+		&i.Name,
+		&i.Price,
+		&i.Status,
+		&i.BookingTime,
+		&i.PaymentStatus,
+		&i.BookingChannel,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.PolicyID,
+		&i.BookedBy,
+		&i.FareBreakdown,
+	)
+	return i, err
+}
+
 const updateTicketPaymentStatus = `-- name: UpdateTicketPaymentStatus :one
 UPDATE Ticket
 SET Payment_Status = $2, Status = $3, Updated_At = CURRENT_TIMESTAMP
 WHERE Ticket_Id = $1
-RETURNING ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by
+RETURNING ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by, fare_breakdown
 `
 
 type UpdateTicketPaymentStatusParams struct {
@@ -1152,6 +3247,7 @@ func (q *Queries) UpdateTicketPaymentStatus(ctx context.Context, arg UpdateTicke
 		&i.UpdatedAt,
 		&i.PolicyID,
 		&i.BookedBy,
+		&i.FareBreakdown,
 	)
 	return i, err
 }
@@ -1160,7 +3256,7 @@ const updateTicketStatus = `-- name: UpdateTicketStatus :one
 UPDATE Ticket
 SET Status = $2, Updated_At = CURRENT_TIMESTAMP
 WHERE Ticket_Id = $1
-RETURNING ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by
+RETURNING ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by, fare_breakdown
 `
 
 type UpdateTicketStatusParams struct {
@@ -1190,6 +3286,7 @@ func (q *Queries) UpdateTicketStatus(ctx context.Context, arg UpdateTicketStatus
 		&i.UpdatedAt,
 		&i.PolicyID,
 		&i.BookedBy,
+		&i.FareBreakdown,
 	)
 	return i, err
 }
@@ -1198,7 +3295,7 @@ const updateTicketStatusAfterCheckin = `-- name: UpdateTicketStatusAfterCheckin
 UPDATE Ticket
 SET Status = $2, Updated_At = CURRENT_TIMESTAMP -- $2 would be the 'used' status
 WHERE Ticket_Id = $1
-RETURNING ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by
+RETURNING ticket_id, trip_id_begin, trip_id_end, type, customer_id, phone, email, name, price, status, booking_time, payment_status, booking_channel, created_at, updated_at, policy_id, booked_by, fare_breakdown
 `
 
 type UpdateTicketStatusAfterCheckinParams struct {
@@ -1228,6 +3325,122 @@ func (q *Queries) UpdateTicketStatusAfterCheckin(ctx context.Context, arg Update
 		&i.UpdatedAt,
 		&i.PolicyID,
 		&i.BookedBy,
+		&i.FareBreakdown,
+	)
+	return i, err
+}
+
+const updateWaitlistEntryStatus = `-- name: UpdateWaitlistEntryStatus :exec
+UPDATE trip_waitlist
+SET status = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type UpdateWaitlistEntryStatusParams struct {
+	ID     int32 `json:"id"`
+	Status int16 `json:"status"`
+}
+
+func (q *Queries) UpdateWaitlistEntryStatus(ctx context.Context, arg UpdateWaitlistEntryStatusParams) error {
+	_, err := q.db.ExecContext(ctx, updateWaitlistEntryStatus, arg.ID, arg.Status)
+	return err
+}
+
+const upsertSeatLayout = `-- name: UpsertSeatLayout :one
+INSERT INTO seat_layouts (vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (vehicle_type_id, seat_count) DO UPDATE
+SET name = EXCLUDED.name,
+    decks = EXCLUDED.decks,
+    row_count = EXCLUDED.row_count,
+    column_count = EXCLUDED.column_count,
+    aisle_after_columns = EXCLUDED.aisle_after_columns
+RETURNING id, vehicle_type_id, seat_count, name, decks, row_count, column_count, aisle_after_columns, created_at, updated_at
+`
+
+type UpsertSeatLayoutParams struct {
+	VehicleTypeID     int32   `json:"vehicle_type_id"`
+	SeatCount         int32   `json:"seat_count"`
+	Name              string  `json:"name"`
+	Decks             int16   `json:"decks"`
+	RowCount          int16   `json:"row_count"`
+	ColumnCount       int16   `json:"column_count"`
+	AisleAfterColumns []int32 `json:"aisle_after_columns"`
+}
+
+// Creates or replaces the layout template of a vehicle type and seat count.
+func (q *Queries) UpsertSeatLayout(ctx context.Context, arg UpsertSeatLayoutParams) (SeatLayout, error) {
+	row := q.db.QueryRowContext(ctx, upsertSeatLayout,
+		arg.VehicleTypeID,
+		arg.SeatCount,
+		arg.Name,
+		arg.Decks,
+		arg.RowCount,
+		arg.ColumnCount,
+		pq.Array(arg.AisleAfterColumns),
+	)
+	var i SeatLayout
+	err := row.Scan(
+		&i.ID,
+		&i.VehicleTypeID,
+		&i.SeatCount,
+		&i.Name,
+		&i.Decks,
+		&i.RowCount,
+		&i.ColumnCount,
+		pq.Array(&i.AisleAfterColumns),
+		&i.CreatedAt,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
+
+const upsertTripRebookingOffer = `-- name: UpsertTripRebookingOffer :execrows
+INSERT INTO trip_rebooking_offers (ticket_id, trip_id, old_departure, new_departure)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (ticket_id, trip_id) DO UPDATE
+SET new_departure = EXCLUDED.new_departure, used_at = NULL, updated_at = NOW()
+WHERE trip_rebooking_offers.new_departure IS DISTINCT FROM EXCLUDED.new_departure
+`
+
+type UpsertTripRebookingOfferParams struct {
+	TicketID     string    `json:"ticket_id"`
+	TripID       string    `json:"trip_id"`
+	OldDeparture time.Time `json:"old_departure"`
+	NewDeparture time.Time `json:"new_departure"`
+}
+
+// Opens a free rebooking for a ticket of a rescheduled trip. Affects no row when the offer already
+// exists for the same new departure (redelivered event), so the customer is not notified twice.
+func (q *Queries) UpsertTripRebookingOffer(ctx context.Context, arg UpsertTripRebookingOfferParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, upsertTripRebookingOffer,
+		arg.TicketID,
+		arg.TripID,
+		arg.OldDeparture,
+		arg.NewDeparture,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useTripRebookingOffer = `-- name: UseTripRebookingOffer :execrows
+UPDATE trip_rebooking_offers
+SET used_at = NOW(), updated_at = NOW()
+WHERE ticket_id = $1 AND trip_id = $2 AND used_at IS NULL
+`
+
+type UseTripRebookingOfferParams struct {
+	TicketID string `json:"ticket_id"`
+	TripID   string `json:"trip_id"`
+}
+
+// Marks a free rebooking as used, in the seat change transaction.
+func (q *Queries) UseTripRebookingOffer(ctx context.Context, arg UseTripRebookingOfferParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTripRebookingOffer, arg.TicketID, arg.TripID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+	"ticket-service/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+// Trạng thái của các yêu cầu đặt vé bất đồng bộ nằm trong bảng booking_requests (xem db/migrations/*_0011_booking_requests.sql).
+// Redis chỉ còn dùng để đẩy kết quả tới WebSocket (Pub/Sub), nên Redis khởi động lại không làm mất booking.
+
+// CreateBookingRequest ghi nhận một yêu cầu đặt vé ở trạng thái QUEUED.
+func (r *ticketRepositoryImpl) CreateBookingRequest(ctx context.Context, params db.CreateBookingRequestParams) error {
+	if err := r.q.CreateBookingRequest(ctx, params); err != nil {
+		return fmt.Errorf("failed to create booking request %s: %w", params.BookingID, err)
+	}
+	return nil
+}
+
+// GetBookingRequest trả về models.ErrBookingRequestNotFound nếu bookingID không tồn tại.
+func (r *ticketRepositoryImpl) GetBookingRequest(ctx context.Context, bookingID uuid.UUID) (db.BookingRequest, error) {
+	request, err := r.q.GetBookingRequest(ctx, bookingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.BookingRequest{}, models.ErrBookingRequestNotFound
+	}
+	if err != nil {
+		return db.BookingRequest{}, fmt.Errorf("failed to get booking request %s: %w", bookingID, err)
+	}
+	return request, nil
+}
+
+// StartBookingRequest chuyển yêu cầu sang PROCESSING trước khi consumer tạo vé.
+// Trả về models.ErrBookingRequestClosed nếu yêu cầu đã hết hạn hoặc đã có kết quả (message Kafka bị giao lại).
+func (r *ticketRepositoryImpl) StartBookingRequest(ctx context.Context, bookingID uuid.UUID) (db.BookingRequest, error) {
+	request, err := r.q.StartBookingRequest(ctx, bookingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.BookingRequest{}, fmt.Errorf("booking request %s: %w", bookingID, models.ErrBookingRequestClosed)
+	}
+	if err != nil {
+		return db.BookingRequest{}, fmt.Errorf("failed to start booking request %s: %w", bookingID, err)
+	}
+	return request, nil
+}
+
+// FailBookingRequest ghi lỗi khiến yêu cầu không tạo được vé.
+func (r *ticketRepositoryImpl) FailBookingRequest(ctx context.Context, bookingID uuid.UUID, reason string) error {
+	affected, err := r.q.FailBookingRequest(ctx, db.FailBookingRequestParams{
+		BookingID:    bookingID,
+		ErrorMessage: utils.ToNullString(reason),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark booking request %s as failed: %w", bookingID, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("booking request %s: %w", bookingID, models.ErrBookingRequestClosed)
+	}
+	return nil
+}
+
+// ConnectBookingRequest ghi nhận client đã theo dõi yêu cầu (WebSocket hoặc poll) để TimeoutWorker không huỷ vé.
+// Trả về models.ErrBookingRequestExpired nếu đã quá hạn kết nối.
+func (r *ticketRepositoryImpl) ConnectBookingRequest(ctx context.Context, bookingID uuid.UUID) (db.BookingRequest, error) {
+	request, err := r.q.ConnectBookingRequest(ctx, bookingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.BookingRequest{}, models.ErrBookingRequestExpired
+	}
+	if err != nil {
+		return db.BookingRequest{}, fmt.Errorf("failed to connect booking request %s: %w", bookingID, err)
+	}
+	return request, nil
+}
+
+// ExpireUnconnectedBookingRequests chuyển tối đa limit yêu cầu quá hạn kết nối sang EXPIRED.
+// Dòng có TicketID là yêu cầu đã COMPLETED: caller phải huỷ vé, hoặc gọi ReopenExpiredBookingRequest nếu huỷ thất bại.
+func (r *ticketRepositoryImpl) ExpireUnconnectedBookingRequests(ctx context.Context, limit int32) ([]db.ExpireUnconnectedBookingRequestsRow, error) {
+	rows, err := r.q.ExpireUnconnectedBookingRequests(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire booking requests: %w", err)
+	}
+	return rows, nil
+}
+
+// ReopenExpiredBookingRequest đưa yêu cầu về COMPLETED để lượt quét sau huỷ lại vé.
+func (r *ticketRepositoryImpl) ReopenExpiredBookingRequest(ctx context.Context, bookingID uuid.UUID) error {
+	if err := r.q.ReopenExpiredBookingRequest(ctx, bookingID); err != nil {
+		return fmt.Errorf("failed to reopen booking request %s: %w", bookingID, err)
+	}
+	return nil
+}
+
+// completeBookingRequest gắn vé vừa tạo vào yêu cầu đặt vé trong transaction tạo vé (qtx).
+func completeBookingRequest(ctx context.Context, qtx *db.Queries, bookingID uuid.UUID, ticketID string) error {
+	affected, err := qtx.CompleteBookingRequest(ctx, db.CompleteBookingRequestParams{
+		BookingID: bookingID,
+		TicketID:  utils.ToNullString(ticketID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete booking request %s: %w", bookingID, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("booking request %s: %w", bookingID, models.ErrBookingRequestClosed)
+	}
+	return nil
+}
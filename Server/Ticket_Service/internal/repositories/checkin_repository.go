@@ -3,11 +3,14 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"ticket-service/domain/models"
 	"ticket-service/internal/db" // sqlc generated package
 
 	// For domain-specific models/constants not in db
 	"ticket-service/pkg/utils"
+	"time"
 	// "github.com/jackc/pgx/v4" // No longer needed for pgx.ErrNoRows directly if using sql.ErrNoRows
 )
 
@@ -20,6 +23,30 @@ type CheckinRepositoryInterface interface {
 	PerformCheckin(ctx context.Context, seatTicketID int32, ticketID string, tripID string, seatName sql.NullString, note string, newSeatTicketStatus int16, newTicketStatus int16) (*db.Checkin, error)
 	// Mới: Thêm phương thức lấy tất cả check-in theo tripID
 	GetAllCheckinsByTripID(ctx context.Context, tripID string) ([]db.Checkin, error)
+	GetTripManifest(ctx context.Context, tripID string) ([]db.GetTripManifestRow, error)
+	ApplyOfflineCheckin(ctx context.Context, params OfflineCheckinParams) (*OfflineCheckinOutcome, error)
+	GetBoardingManifest(ctx context.Context, tripID string) ([]db.GetBoardingManifestRow, error)
+	ListTripIDsWithConfirmedSeats(ctx context.Context) ([]string, error)
+	MarkNoShows(ctx context.Context, tripID string, maxFromStop int16) ([]db.SeatTicket, error)
+}
+
+// OfflineCheckinParams là một lượt quét offline đã qua bước xác thực QR.
+type OfflineCheckinParams struct {
+	DeviceID     string
+	ClientScanID string
+	TicketID     string
+	SeatID       int32
+	TripID       string
+	ScannedAt    time.Time
+	SnapshotAt   time.Time // generated_at của manifest thiết bị đã dùng, zero nếu không rõ
+	Note         string
+}
+
+// OfflineCheckinOutcome là kết quả áp dụng lượt quét (xem models.OfflineScan*), Checkin là bản ghi liên quan nếu có.
+type OfflineCheckinOutcome struct {
+	Outcome string
+	Reason  string
+	Checkin *db.Checkin
 }
 
 type CheckinRepository struct {
@@ -106,6 +133,16 @@ func (r *CheckinRepository) PerformCheckin(
 
 	qtx := r.q.WithTx(tx)
 
+	// Khoá dòng seat_tickets để hai lượt quét đồng thời (online hoặc đồng bộ offline) không cùng check-in một ghế
+	seatTicket, err := qtx.GetSeatTicketForCheckin(ctx, db.GetSeatTicketForCheckinParams{SeatID: seatTicketID, TicketID: ticketID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock seat_ticket: %w", err)
+	}
+	if err := models.ValidateSeatTransition(seatTicket.Status, newSeatTicketStatus); err != nil || seatTicket.Status == newSeatTicketStatus {
+		return nil, fmt.Errorf("seat ticket is no longer in a checkable state (status %d)", seatTicket.Status)
+	}
+	change := models.StatusChange{Actor: "checkin", Reason: note}
+
 	checkinParams := db.CreateCheckinParams{
 		SeatTicketID: seatTicketID,
 		TicketID:     ticketID,
@@ -120,7 +157,7 @@ func (r *CheckinRepository) PerformCheckin(
 	}
 
 	updateSeatTicketParams := db.UpdateSeatTicketStatusAfterCheckinParams{
-		ID:     createdCheckin.ID,
+		ID:     seatTicket.ID,
 		Status: newSeatTicketStatus,
 	}
 	_, err = qtx.UpdateSeatTicketStatusAfterCheckin(ctx, updateSeatTicketParams)
@@ -128,13 +165,11 @@ func (r *CheckinRepository) PerformCheckin(
 		r.logger.Error("Failed to update seat_tickets status: %v", err)
 		return nil, fmt.Errorf("failed to update seat_ticket status: %w", err)
 	}
-
-	updateTicketParams := db.UpdateTicketStatusAfterCheckinParams{
-		TicketID: ticketID,
-		Status:   newTicketStatus,
+	if err := logStatusChange(ctx, qtx, ticketID, models.StatusEntitySeat, seatTicket.ID, seatTicket.Status, newSeatTicketStatus, change); err != nil {
+		return nil, err
 	}
-	_, err = qtx.UpdateTicketStatusAfterCheckin(ctx, updateTicketParams)
-	if err != nil {
+
+	if _, err := transitionTicketStatus(ctx, qtx, ticketID, newTicketStatus, change); err != nil {
 		r.logger.Error("Failed to update Ticket status: %v", err)
 		return nil, fmt.Errorf("failed to update ticket status: %w", err)
 	}
@@ -145,3 +180,160 @@ func (r *CheckinRepository) PerformCheckin(
 
 	return &createdCheckin, nil
 }
+
+// GetTripManifest lấy các ghế đã xác nhận / đã check-in của chuyến cho manifest soát vé offline.
+func (r *CheckinRepository) GetTripManifest(ctx context.Context, tripID string) ([]db.GetTripManifestRow, error) {
+	rows, err := r.q.GetTripManifest(ctx, tripID)
+	if err != nil {
+		r.logger.Error("Error getting manifest for trip %s: %v", tripID, err)
+		return nil, fmt.Errorf("database error when fetching manifest for trip %s: %w", tripID, err)
+	}
+	return rows, nil
+}
+
+// ApplyOfflineCheckin ghi một lượt quét offline trong transaction, giải quyết xung đột theo trạng thái hiện tại của ghế:
+// ghế còn xác nhận thì check-in với thời điểm quét gốc; đã check-in thì coi là quét trùng;
+// vé đã huỷ / chưa thanh toán thì vẫn lưu lượt quét kèm lý do conflict để đối soát, không đổi trạng thái vé.
+func (r *CheckinRepository) ApplyOfflineCheckin(ctx context.Context, params OfflineCheckinParams) (*OfflineCheckinOutcome, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := r.q.WithTx(tx)
+	deviceID := sql.NullString{String: params.DeviceID, Valid: true}
+	clientScanID := sql.NullString{String: params.ClientScanID, Valid: true}
+
+	// Thiết bị gửi lại lô đã đồng bộ (mất phản hồi lần trước)
+	synced, err := qtx.GetCheckinByClientScanID(ctx, db.GetCheckinByClientScanIDParams{DeviceID: deviceID, ClientScanID: clientScanID})
+	if err == nil {
+		return &OfflineCheckinOutcome{Outcome: models.OfflineScanDuplicate, Reason: models.OfflineReasonAlreadySynced, Checkin: &synced}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to look up synced scan: %w", err)
+	}
+
+	seatTicket, err := qtx.GetSeatTicketForCheckin(ctx, db.GetSeatTicketForCheckinParams{SeatID: params.SeatID, TicketID: params.TicketID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &OfflineCheckinOutcome{Outcome: models.OfflineScanRejected, Reason: models.OfflineReasonNotFound}, nil
+		}
+		return nil, fmt.Errorf("failed to lock seat_ticket: %w", err)
+	}
+	if seatTicket.TripID != params.TripID {
+		return &OfflineCheckinOutcome{Outcome: models.OfflineScanRejected, Reason: models.OfflineReasonWrongTrip}, nil
+	}
+
+	createParams := db.CreateOfflineCheckinParams{
+		SeatTicketID: params.SeatID,
+		TicketID:     params.TicketID,
+		TripID:       params.TripID,
+		SeatName:     seatTicket.SeatName,
+		Note:         params.Note,
+		CheckedInAt:  sql.NullTime{Time: params.ScannedAt, Valid: true},
+		DeviceID:     deviceID,
+		ClientScanID: clientScanID,
+	}
+
+	outcome := &OfflineCheckinOutcome{}
+	switch seatTicket.Status {
+	case models.SeatStatusConfirmed, models.SeatStatusMissed:
+		// Ghế bị đánh dấu no-show trước khi lô offline về tới server: khách thực tế đã lên xe
+		outcome.Outcome = models.OfflineScanAccepted
+	case models.SeatStatusCheckedIn:
+		// Đã có lượt check-in khác (online hoặc thiết bị khác), giữ bản ghi đầu tiên
+		first, err := qtx.GetFirstCheckinBySeatTicket(ctx, db.GetFirstCheckinBySeatTicketParams{SeatTicketID: params.SeatID, TicketID: params.TicketID})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to get existing checkin: %w", err)
+		}
+		outcome.Outcome, outcome.Reason = models.OfflineScanDuplicate, models.OfflineReasonAlreadyCheckedIn
+		if err == nil {
+			outcome.Checkin = &first
+		}
+		return outcome, nil
+	case models.SeatStatusCancelled:
+		outcome.Outcome, outcome.Reason = models.OfflineScanConflict, models.OfflineReasonTicketCancelled
+		if !params.SnapshotAt.IsZero() && seatTicket.UpdatedAt.Valid && seatTicket.UpdatedAt.Time.After(params.SnapshotAt) {
+			outcome.Reason = models.OfflineReasonCancelledAfterSnapshot
+		}
+	case models.SeatStatusPendingPayment:
+		outcome.Outcome, outcome.Reason = models.OfflineScanConflict, models.OfflineReasonPendingPayment
+	default:
+		return &OfflineCheckinOutcome{Outcome: models.OfflineScanRejected, Reason: models.OfflineReasonNotFound}, nil
+	}
+	if outcome.Reason != "" {
+		createParams.Conflict = sql.NullString{String: outcome.Reason, Valid: true}
+	}
+
+	created, err := qtx.CreateOfflineCheckin(ctx, createParams)
+	if err != nil {
+		r.logger.Error("Failed to insert offline checkin of ticket %s seat %d: %v", params.TicketID, params.SeatID, err)
+		return nil, fmt.Errorf("failed to create checkin record: %w", err)
+	}
+	outcome.Checkin = &created
+
+	if outcome.Outcome == models.OfflineScanAccepted {
+		change := models.StatusChange{Actor: "device:" + params.DeviceID, Reason: params.Note}
+		if _, err := qtx.UpdateSeatTicketStatusAfterCheckin(ctx, db.UpdateSeatTicketStatusAfterCheckinParams{ID: seatTicket.ID, Status: models.SeatStatusCheckedIn}); err != nil {
+			return nil, fmt.Errorf("failed to update seat_ticket status: %w", err)
+		}
+		if err := logStatusChange(ctx, qtx, params.TicketID, models.StatusEntitySeat, seatTicket.ID, seatTicket.Status, models.SeatStatusCheckedIn, change); err != nil {
+			return nil, err
+		}
+		if _, err := transitionTicketStatus(ctx, qtx, params.TicketID, models.TicketStatusUsed, change); err != nil {
+			return nil, fmt.Errorf("failed to update ticket status: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return outcome, nil
+}
+
+// GetBoardingManifest lấy tất cả ghế đã bán (chưa huỷ) của chuyến kèm thông tin khách, thanh toán và check-in.
+func (r *CheckinRepository) GetBoardingManifest(ctx context.Context, tripID string) ([]db.GetBoardingManifestRow, error) {
+	rows, err := r.q.GetBoardingManifest(ctx, tripID)
+	if err != nil {
+		r.logger.Error("Error getting boarding manifest for trip %s: %v", tripID, err)
+		return nil, fmt.Errorf("database error when fetching boarding manifest for trip %s: %w", tripID, err)
+	}
+	return rows, nil
+}
+
+func (r *CheckinRepository) ListTripIDsWithConfirmedSeats(ctx context.Context) ([]string, error) {
+	tripIDs, err := r.q.ListTripIDsWithConfirmedSeats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("database error when listing trips with confirmed seats: %w", err)
+	}
+	return tripIDs, nil
+}
+
+// MarkNoShows đánh dấu no-show các ghế đã xác nhận nhưng chưa check-in, lên xe tại điểm dừng <= maxFromStop.
+// Mỗi ghế bị đánh dấu được ghi lịch sử CONFIRMED -> NO_SHOW trong cùng transaction.
+func (r *CheckinRepository) MarkNoShows(ctx context.Context, tripID string, maxFromStop int16) ([]db.SeatTicket, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := r.q.WithTx(tx)
+	seatTickets, err := qtx.MarkSeatTicketsNoShow(ctx, db.MarkSeatTicketsNoShowParams{TripID: tripID, MaxFromStop: maxFromStop})
+	if err != nil {
+		r.logger.Error("Error marking no-shows for trip %s: %v", tripID, err)
+		return nil, fmt.Errorf("database error when marking no-shows for trip %s: %w", tripID, err)
+	}
+	change := models.StatusChange{Actor: "system", Reason: "no-show after departure from boarding stop"}
+	for _, seatTicket := range seatTickets {
+		if err := logStatusChange(ctx, qtx, seatTicket.TicketID, models.StatusEntitySeat, seatTicket.ID, models.SeatStatusConfirmed, models.SeatStatusMissed, change); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return seatTickets, nil
+}
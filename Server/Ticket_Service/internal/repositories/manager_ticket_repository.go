@@ -16,11 +16,13 @@ type UpdateStatusTransactionParams struct {
 	PaymentStatus       int16
 	GeneralTicketStatus int16
 	SeatTicketStatus    int16
+	Change              models.StatusChange // Người thực hiện / lý do, ghi vào Ticket_Logs cùng trạng thái cũ/mới
 	OutboxEvents        []db.CreateOutboxEventParams
 }
 
 type ManagerTicketInterface interface {
 	CreateManagerTicket(ctx context.Context, seat *db.Seat) error // Use db.Seat
+	CreateSeatsForTrip(ctx context.Context, seats []db.CreateSeatFromLayoutParams) ([]db.Seat, error)
 	UpdateStatusByTicketID(ctx context.Context, ticketID string, statusCode string) error
 	GetSeatsByTripID(ctx context.Context, tripID string) ([]db.Seat, error) // Use db.Seat
 	UpdateStatusInTransaction(ctx context.Context, params UpdateStatusTransactionParams) error
@@ -62,6 +64,31 @@ func (m *ManagerTicketRepository) CreateManagerTicket(ctx context.Context, seat
 	return nil
 }
 
+// CreateSeatsForTrip tạo toàn bộ ghế của chuyến (theo mẫu sơ đồ) trong một transaction.
+func (m *ManagerTicketRepository) CreateSeatsForTrip(ctx context.Context, seats []db.CreateSeatFromLayoutParams) ([]db.Seat, error) {
+	tx, err := m.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := m.q.WithTx(tx)
+
+	created := make([]db.Seat, 0, len(seats))
+	for _, seat := range seats {
+		createdSeat, err := qtx.CreateSeatFromLayout(ctx, seat)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create seat %s for trip %s: %w", seat.SeatName.String, seat.TripID, err)
+		}
+		created = append(created, createdSeat)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return created, nil
+}
+
 func (m *ManagerTicketRepository) GetSeatsByTripID(ctx context.Context, tripID string) ([]db.Seat, error) {
 	return m.q.GetSeatsByTripID(ctx, tripID)
 }
@@ -75,23 +102,14 @@ func (m *ManagerTicketRepository) UpdateStatusInTransaction(ctx context.Context,
 
 	qtx := m.q.WithTx(tx)
 
-	// 1. Update ticket's main status
-	updateTicketParams := db.UpdateTicketPaymentStatusParams{
-		TicketID:      params.TicketID,
-		PaymentStatus: params.PaymentStatus,
-		Status:        params.GeneralTicketStatus,
-	}
-	if _, err := qtx.UpdateTicketPaymentStatus(ctx, updateTicketParams); err != nil {
-		return fmt.Errorf("failed to update ticket payment status: %w", err)
+	// 1. Update ticket's main status (state machine checked)
+	if _, err := transitionTicket(ctx, qtx, params.TicketID, params.GeneralTicketStatus, params.PaymentStatus, params.Change); err != nil {
+		return err
 	}
 
 	// 2. Update status of associated seat_tickets
-	updateSeatStatusParams := db.UpdateSeatTicketStatusByTicketIDParams{
-		TicketID: params.TicketID,
-		Status:   params.SeatTicketStatus,
-	}
-	if _, err := qtx.UpdateSeatTicketStatusByTicketID(ctx, updateSeatStatusParams); err != nil {
-		return fmt.Errorf("failed to update seat_tickets status: %w", err)
+	if err := transitionSeatTickets(ctx, qtx, params.TicketID, params.SeatTicketStatus, params.Change); err != nil {
+		return err
 	}
 
 	// 3. Create all required outbox events
@@ -109,60 +127,20 @@ func (m *ManagerTicketRepository) UpdateStatusInTransaction(ctx context.Context,
 
 // Cập nhật trạng thái vé theo payment callback
 func (m *ManagerTicketRepository) UpdateStatusByTicketID(ctx context.Context, ticketID string, statusCode string) error {
-	var paymentStatus int16
-	var generalTicketStatus int16 // Required for UpdateTicketPaymentStatus sqlc query
-
-	if statusCode == "1" { // Payment successful
-		paymentStatus = int16(models.PaymentStatusPaid)
-		generalTicketStatus = int16(models.TicketStatusConfirmed) // Assuming successful payment confirms the ticket
+	params := UpdateStatusTransactionParams{
+		TicketID: ticketID,
+		Change:   models.StatusChange{Actor: "payment-callback", Reason: "payment status code " + statusCode},
+	}
+	if statusCode == models.PaymentResultPaid { // Payment successful
+		params.PaymentStatus = models.PaymentStatusPaid
+		params.GeneralTicketStatus = models.TicketStatusConfirmed // Assuming successful payment confirms the ticket
+		params.SeatTicketStatus = models.SeatStatusConfirmed
 	} else if statusCode == "2" { // Payment failed
-		paymentStatus = int16(models.PaymentStatusFailed)
-		generalTicketStatus = int16(models.TicketStatusCancelled) // Assuming failed payment cancels the ticket
+		params.PaymentStatus = models.PaymentStatusFailed
+		params.GeneralTicketStatus = models.TicketStatusCancelled // Assuming failed payment cancels the ticket
+		params.SeatTicketStatus = models.SeatStatusCancelled
 	} else {
 		return fmt.Errorf("invalid status code: %s", statusCode)
 	}
-
-	tx, err := m.sqlDB.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	qtx := m.q.WithTx(tx)
-
-	// Cập nhật trạng thái thanh toán và trạng thái chung của vé
-	// sqlc.UpdateTicketPaymentStatus updates both payment_status and general status.
-	updateTicketParams := db.UpdateTicketPaymentStatusParams{
-		TicketID:      ticketID,
-		PaymentStatus: paymentStatus,
-		Status:        generalTicketStatus, // General ticket status
-	}
-	_, err = qtx.UpdateTicketPaymentStatus(ctx, updateTicketParams)
-	if err != nil {
-		return fmt.Errorf("failed to update ticket payment status and general status: %w", err)
-	}
-
-	// Cập nhật trạng thái seat_tickets
-	var seatTicketStatus int16
-	if statusCode == "1" { // Payment successful
-		seatTicketStatus = int16(models.SeatStatusConfirmed)
-	} else if statusCode == "2" { // Payment failed
-		seatTicketStatus = int16(models.SeatStatusCancelled)
-	}
-	// Only update seat tickets if status is 1 or 2
-	if statusCode == "1" || statusCode == "2" {
-		updateSeatStatusParams := db.UpdateSeatTicketStatusByTicketIDParams{
-			TicketID: ticketID,
-			Status:   seatTicketStatus,
-		}
-		_, err = qtx.UpdateSeatTicketStatusByTicketID(ctx, updateSeatStatusParams)
-		if err != nil {
-			return fmt.Errorf("failed to update seat_tickets status: %w", err)
-		}
-	}
-
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-	return nil
+	return m.UpdateStatusInTransaction(ctx, params)
 }
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ticket-service/internal/db"
+	"ticket-service/pkg/utils"
+)
+
+var ErrPolicyNotFound = errors.New("policy not found or inactive")
+
+type PolicyRepositoryInterface interface {
+	GetActivePolicy(ctx context.Context, policyID int32) (*db.Policy, error)
+	ListRefundRules(ctx context.Context, policyID int32) ([]db.PolicyRefundRule, error)
+}
+
+type PolicyRepository struct {
+	q      db.Querier
+	logger utils.Logger
+}
+
+func NewPolicyRepository(sqlDB *sql.DB, logger utils.Logger) PolicyRepositoryInterface {
+	return &PolicyRepository{
+		q:      db.New(sqlDB),
+		logger: logger,
+	}
+}
+
+// GetActivePolicy trả về policy đang hoạt động, ErrPolicyNotFound nếu không tồn tại.
+func (r *PolicyRepository) GetActivePolicy(ctx context.Context, policyID int32) (*db.Policy, error) {
+	policy, err := r.q.GetActivePolicyByID(ctx, policyID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("policy %d: %w", policyID, ErrPolicyNotFound)
+		}
+		r.logger.Error("Error getting policy %d: %v", policyID, err)
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ListRefundRules trả về các refund rule của policy, sắp xếp theo min_hours_before_departure giảm dần.
+func (r *PolicyRepository) ListRefundRules(ctx context.Context, policyID int32) ([]db.PolicyRefundRule, error) {
+	rules, err := r.q.ListRefundRulesByPolicyID(ctx, policyID)
+	if err != nil {
+		r.logger.Error("Error listing refund rules for policy %d: %v", policyID, err)
+		return nil, err
+	}
+	return rules, nil
+}
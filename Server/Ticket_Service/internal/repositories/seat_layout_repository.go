@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+	"ticket-service/pkg/utils"
+)
+
+var ErrSeatLayoutNotFound = errors.New("seat layout not found")
+
+type SeatLayoutRepositoryInterface interface {
+	FindLayoutForVehicle(ctx context.Context, vehicleTypeID, seatCount int32) (*db.SeatLayout, []db.SeatLayoutSeat, error)
+	GetLayout(ctx context.Context, layoutID int32) (*db.SeatLayout, []db.SeatLayoutSeat, error)
+	ListLayouts(ctx context.Context) ([]db.SeatLayout, error)
+	UpsertLayout(ctx context.Context, layout db.UpsertSeatLayoutParams, seats []db.CreateSeatLayoutSeatParams) (*db.SeatLayout, []db.SeatLayoutSeat, error)
+	DeleteLayout(ctx context.Context, layoutID int32) error
+	GetSeatMap(ctx context.Context, tripID string, segment models.SeatSegment) ([]db.GetSeatMapByTripIDRow, error)
+}
+
+type SeatLayoutRepository struct {
+	sqlDB  *sql.DB
+	q      *db.Queries
+	logger utils.Logger
+}
+
+func NewSeatLayoutRepository(sqlDB *sql.DB, logger utils.Logger) SeatLayoutRepositoryInterface {
+	return &SeatLayoutRepository{
+		sqlDB:  sqlDB,
+		q:      db.New(sqlDB),
+		logger: logger,
+	}
+}
+
+// FindLayoutForVehicle trả về mẫu sơ đồ của loại xe (ưu tiên mẫu đúng số ghế), hoặc mẫu mặc định.
+func (r *SeatLayoutRepository) FindLayoutForVehicle(ctx context.Context, vehicleTypeID, seatCount int32) (*db.SeatLayout, []db.SeatLayoutSeat, error) {
+	layout, err := r.q.FindSeatLayoutForVehicle(ctx, db.FindSeatLayoutForVehicleParams{
+		VehicleTypeID: vehicleTypeID,
+		SeatCount:     seatCount,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("vehicle type %d: %w", vehicleTypeID, ErrSeatLayoutNotFound)
+		}
+		r.logger.Error("Error finding seat layout for vehicle type %d: %v", vehicleTypeID, err)
+		return nil, nil, err
+	}
+	seats, err := r.q.ListSeatLayoutSeats(ctx, layout.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list seats of layout %d: %w", layout.ID, err)
+	}
+	return &layout, seats, nil
+}
+
+func (r *SeatLayoutRepository) GetLayout(ctx context.Context, layoutID int32) (*db.SeatLayout, []db.SeatLayoutSeat, error) {
+	layout, err := r.q.GetSeatLayoutByID(ctx, layoutID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("layout %d: %w", layoutID, ErrSeatLayoutNotFound)
+		}
+		r.logger.Error("Error getting seat layout %d: %v", layoutID, err)
+		return nil, nil, err
+	}
+	seats, err := r.q.ListSeatLayoutSeats(ctx, layout.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list seats of layout %d: %w", layout.ID, err)
+	}
+	return &layout, seats, nil
+}
+
+func (r *SeatLayoutRepository) ListLayouts(ctx context.Context) ([]db.SeatLayout, error) {
+	return r.q.ListSeatLayouts(ctx)
+}
+
+// UpsertLayout tạo hoặc thay thế mẫu sơ đồ cùng toàn bộ vị trí ghế trong một transaction.
+func (r *SeatLayoutRepository) UpsertLayout(ctx context.Context, layout db.UpsertSeatLayoutParams, seats []db.CreateSeatLayoutSeatParams) (*db.SeatLayout, []db.SeatLayoutSeat, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := r.q.WithTx(tx)
+
+	saved, err := qtx.UpsertSeatLayout(ctx, layout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to upsert seat layout: %w", err)
+	}
+	if err := qtx.DeleteSeatLayoutSeats(ctx, saved.ID); err != nil {
+		return nil, nil, fmt.Errorf("failed to clear seats of layout %d: %w", saved.ID, err)
+	}
+
+	savedSeats := make([]db.SeatLayoutSeat, 0, len(seats))
+	for _, seat := range seats {
+		seat.LayoutID = saved.ID
+		savedSeat, err := qtx.CreateSeatLayoutSeat(ctx, seat)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create seat %s of layout %d: %w", seat.SeatName, saved.ID, err)
+		}
+		savedSeats = append(savedSeats, savedSeat)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &saved, savedSeats, nil
+}
+
+func (r *SeatLayoutRepository) DeleteLayout(ctx context.Context, layoutID int32) error {
+	return r.q.DeleteSeatLayout(ctx, layoutID)
+}
+
+func (r *SeatLayoutRepository) GetSeatMap(ctx context.Context, tripID string, segment models.SeatSegment) ([]db.GetSeatMapByTripIDRow, error) {
+	return r.q.GetSeatMapByTripID(ctx, db.GetSeatMapByTripIDParams{
+		ToStop:   segment.ToStop,
+		FromStop: segment.FromStop,
+		TripID:   tripID,
+	})
+}
@@ -0,0 +1,169 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+)
+
+// Ghép TicketReturn với số truy vấn cố định, không phụ thuộc số vé:
+// một truy vấn lấy vé, một lấy Ticket_Details, một lấy seat_ticket của cả danh sách,
+// và (tuỳ chọn) một lần tripclient.GetTrips cho thông tin chuyến của cả danh sách.
+
+// assembleTicket ghép đầy đủ chi tiết và ghế cho một vé.
+func (r *ticketRepositoryImpl) assembleTicket(ctx context.Context, coreTicket db.Ticket) (*models.TicketReturn, error) {
+	tickets, err := r.assembleTickets(ctx, []db.Ticket{coreTicket})
+	if err != nil {
+		return nil, err
+	}
+	return tickets[0], nil
+}
+
+// assembleTickets ghép chi tiết và ghế cho cả danh sách vé, giữ nguyên thứ tự.
+func (r *ticketRepositoryImpl) assembleTickets(ctx context.Context, coreTickets []db.Ticket) ([]*models.TicketReturn, error) {
+	tickets := make([]*models.TicketReturn, len(coreTickets))
+	for i, coreTicket := range coreTickets {
+		tickets[i] = newTicketReturn(coreTicket)
+	}
+	if err := r.attachTicketRelations(ctx, tickets); err != nil {
+		return nil, err
+	}
+	return tickets, nil
+}
+
+// getTicketsByIDs trả về các vé theo thứ tự ticketIDs; vé không tồn tại bị bỏ qua.
+func (r *ticketRepositoryImpl) getTicketsByIDs(ctx context.Context, ticketIDs []string) ([]*models.TicketReturn, error) {
+	if len(ticketIDs) == 0 {
+		return []*models.TicketReturn{}, nil
+	}
+	coreTickets, err := r.q.GetTicketsCoreByIDs(ctx, ticketIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get core tickets by IDs: %w", err)
+	}
+	byID := make(map[string]db.Ticket, len(coreTickets))
+	for _, coreTicket := range coreTickets {
+		byID[coreTicket.TicketID] = coreTicket
+	}
+	ordered := make([]db.Ticket, 0, len(coreTickets))
+	for _, ticketID := range ticketIDs {
+		if coreTicket, ok := byID[ticketID]; ok {
+			ordered = append(ordered, coreTicket)
+		}
+	}
+	return r.assembleTickets(ctx, ordered)
+}
+
+// newTicketReturn chuyển một dòng Ticket thành TicketReturn (chưa có ghế / chi tiết).
+func newTicketReturn(coreTicket db.Ticket) *models.TicketReturn {
+	return &models.TicketReturn{
+		TicketID:       coreTicket.TicketID,
+		TripIDBegin:    coreTicket.TripIDBegin,
+		TripIDEnd:      coreTicket.TripIDEnd,
+		Type:           coreTicket.Type,
+		CustomerID:     coreTicket.CustomerID,
+		Phone:          coreTicket.Phone,
+		Email:          coreTicket.Email,
+		Name:           coreTicket.Name,
+		Price:          coreTicket.Price,
+		Status:         coreTicket.Status,
+		BookingTime:    coreTicket.BookingTime,
+		PaymentStatus:  coreTicket.PaymentStatus,
+		BookingChannel: coreTicket.BookingChannel,
+		CreatedAt:      coreTicket.CreatedAt,
+		UpdatedAt:      coreTicket.UpdatedAt,
+		PolicyID:       coreTicket.PolicyID,
+		BookedBy:       coreTicket.BookedBy,
+		FareBreakdown:  coreTicket.FareBreakdown,
+	}
+}
+
+// attachTicketRelations loads details and seat tickets of a list of tickets with one query each,
+// instead of one query per ticket.
+func (r *ticketRepositoryImpl) attachTicketRelations(ctx context.Context, tickets []*models.TicketReturn) error {
+	if len(tickets) == 0 {
+		return nil
+	}
+	ticketIDs := make([]string, len(tickets))
+	ticketsMap := make(map[string]*models.TicketReturn, len(tickets))
+	for i, t := range tickets {
+		ticketIDs[i] = t.TicketID
+		ticketsMap[t.TicketID] = t
+	}
+
+	details, err := r.q.GetTicketDetailsByTicketIDs(ctx, ticketIDs)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get ticket details for tickets: %w", err)
+	}
+
+	seatTicketRows, err := r.q.GetSeatTicketsByTicketIDs(ctx, ticketIDs)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get seat tickets for tickets: %w", err)
+	}
+
+	// Map the details back to their respective tickets
+	for _, det := range details {
+		if ticket, ok := ticketsMap[det.TicketID]; ok {
+			ticket.Details = append(ticket.Details, det)
+		}
+	}
+
+	// Map the seat tickets back to their respective tickets
+	for _, stRow := range seatTicketRows {
+		if ticket, ok := ticketsMap[stRow.TicketID]; ok {
+			seatTicket := db.GetSeatTicketsByTicketIDRow{
+				ID:             stRow.ID,
+				SeatID:         stRow.SeatID,
+				TicketID:       stRow.TicketID,
+				Status:         stRow.Status,
+				TripID:         stRow.TripID,
+				CreatedAt:      stRow.CreatedAt,
+				UpdatedAt:      stRow.UpdatedAt,
+				FromStop:       stRow.FromStop,
+				ToStop:         stRow.ToStop,
+				SeatName:       stRow.SeatName,
+				PassengerName:  stRow.PassengerName,
+				PassengerPhone: stRow.PassengerPhone,
+			}
+			if stRow.TripID == ticket.TripIDBegin {
+				ticket.SeatTicketsBegin = append(ticket.SeatTicketsBegin, seatTicket)
+			} else {
+				ticket.SeatTicketsEnd = append(ticket.SeatTicketsEnd, seatTicket)
+			}
+		}
+	}
+	return nil
+}
+
+// AttachTripDetails gắn thông tin chuyến đi / chuyến về cho danh sách vé. Mỗi chuyến chỉ được tra một lần
+// qua tripclient (có cache). Lỗi trip-service chỉ được log: vé vẫn được trả về, TripDetails để trống.
+func (r *ticketRepositoryImpl) AttachTripDetails(ctx context.Context, tickets []*models.TicketReturn) {
+	if r.trips == nil || len(tickets) == 0 {
+		return
+	}
+	tripIDs := make([]string, 0, len(tickets))
+	seen := make(map[string]bool, len(tickets))
+	for _, t := range tickets {
+		for _, tripID := range []string{t.TripIDBegin, t.TripIDEnd.String} {
+			if tripID != "" && !seen[tripID] {
+				seen[tripID] = true
+				tripIDs = append(tripIDs, tripID)
+			}
+		}
+	}
+	if len(tripIDs) == 0 {
+		return
+	}
+
+	trips, err := r.trips.GetTrips(ctx, tripIDs)
+	if err != nil {
+		r.logger.Error("Failed to retrieve trip details for %d trips: %v", len(tripIDs), err)
+	}
+	for _, t := range tickets {
+		t.TripDetails = trips[t.TripIDBegin]
+		if t.TripIDEnd.Valid {
+			t.TripDetailsEnd = trips[t.TripIDEnd.String]
+		}
+	}
+}
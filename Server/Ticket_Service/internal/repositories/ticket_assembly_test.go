@@ -0,0 +1,194 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+	"ticket-service/pkg/tripclient"
+)
+
+// Một trang vé (GetAllTickets + AttachTripDetails) phải tốn số truy vấn cố định, không phụ thuộc số vé:
+// một truy vấn Ticket, một Ticket_Details, một seat_tickets và một lần tripclient.GetTrips cho cả trang.
+func TestGetAllTicketsQueriesPerPage(t *testing.T) {
+	trips := tripclient.NewFake(
+		&models.TripInfo{ID: "1"}, &models.TripInfo{ID: "2"}, &models.TripInfo{ID: "3"}, &models.TripInfo{ID: "4"},
+	)
+
+	for _, pageSize := range []int{1, 10, 50, 200} {
+		t.Run(fmt.Sprintf("%d tickets", pageSize), func(t *testing.T) {
+			conn := &countingConn{queries: map[string]int{}}
+			sqlDB := sql.OpenDB(conn)
+			defer sqlDB.Close()
+			repo := &ticketRepositoryImpl{sqlDB: sqlDB, q: db.New(sqlDB), trips: trips}
+			callsBefore := trips.Calls()
+
+			tickets, err := repo.GetAllTickets(context.Background(), pageSize, 0)
+			if err != nil {
+				t.Fatalf("GetAllTickets() err = %v", err)
+			}
+			repo.AttachTripDetails(context.Background(), tickets)
+
+			if len(tickets) != pageSize {
+				t.Fatalf("GetAllTickets() returned %d tickets, want %d", len(tickets), pageSize)
+			}
+			want := map[string]int{"Ticket": 1, "Ticket_Details": 1, "seat_tickets": 1}
+			if got := conn.counts(); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("queries per page = %v, want %v", got, want)
+			}
+			if calls := trips.Calls() - callsBefore; calls != 1 {
+				t.Fatalf("tripclient.GetTrips calls per page = %d, want 1", calls)
+			}
+
+			for _, ticket := range tickets {
+				if len(ticket.Details) != 1 || len(ticket.SeatTicketsBegin) != 1 {
+					t.Fatalf("ticket %s has %d details, %d outbound seats, want 1 and 1",
+						ticket.TicketID, len(ticket.Details), len(ticket.SeatTicketsBegin))
+				}
+				if ticket.TripDetails == nil || ticket.TripDetails.ID.String() != ticket.TripIDBegin {
+					t.Fatalf("ticket %s trip details = %v, want trip %s", ticket.TicketID, ticket.TripDetails, ticket.TripIDBegin)
+				}
+				if ticket.TripIDEnd.Valid && (len(ticket.SeatTicketsEnd) != 1 || ticket.TripDetailsEnd == nil) {
+					t.Fatalf("round-trip ticket %s is missing its return seats or trip details", ticket.TicketID)
+				}
+			}
+		})
+	}
+}
+
+// pageTicket là vé thứ i của trang: chuyến đi xoay vòng 1..3, vé lẻ là vé khứ hồi về bằng chuyến 4.
+func pageTicket(i int) (ticketID, tripBegin string, tripEnd sql.NullString) {
+	ticketID = fmt.Sprintf("T%06d", i)
+	tripBegin = fmt.Sprint(i%3 + 1)
+	if i%2 == 1 {
+		tripEnd = sql.NullString{String: "4", Valid: true}
+	}
+	return ticketID, tripBegin, tripEnd
+}
+
+// countingConn là driver SQL giả đếm số truy vấn theo bảng và trả dữ liệu cho các truy vấn của một trang vé.
+type countingConn struct {
+	mu      sync.Mutex
+	queries map[string]int
+}
+
+func (c *countingConn) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *countingConn) Driver() driver.Driver                        { return nil }
+
+func (c *countingConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("countingConn: prepared statements are not supported")
+}
+func (c *countingConn) Close() error { return nil }
+func (c *countingConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("countingConn: transactions are not supported")
+}
+
+func (c *countingConn) counts() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int, len(c.queries))
+	for table, n := range c.queries {
+		counts[table] = n
+	}
+	return counts
+}
+
+// QueryContext trả về dữ liệu theo bảng được truy vấn. Truy vấn Ticket nhận LIMIT/OFFSET, truy vấn Ticket_Details và
+// seat_tickets nhận mảng Postgres ticket ID {"T1","T2"}.
+func (c *countingConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	now := time.Now()
+	rows := &fakeRows{}
+	var table string
+	switch {
+	case strings.Contains(query, "FROM seat_tickets"):
+		table = "seat_tickets"
+		rows.columns = []string{"id", "seat_id", "ticket_id", "status", "trip_id", "created_at", "updated_at",
+			"from_stop", "to_stop", "passenger_name", "passenger_phone", "seat_name"}
+		for i, ticketID := range ticketIDsArg(args) {
+			_, tripBegin, tripEnd := pageTicket(ticketIndex(ticketID))
+			legs := []string{tripBegin}
+			if tripEnd.Valid {
+				legs = append(legs, tripEnd.String)
+			}
+			for leg, tripID := range legs {
+				id := int64(i*2 + leg + 1)
+				rows.values = append(rows.values, []driver.Value{id, id, ticketID, int64(1), tripID, now, now,
+					int64(models.TripStartStop), int64(models.TripEndStop), "Nguyen Van A", "0900000000", fmt.Sprintf("A%02d", leg+1)})
+			}
+		}
+	case strings.Contains(query, "FROM Ticket_Details"):
+		table = "Ticket_Details"
+		rows.columns = []string{"detail_id", "ticket_id", "pickup_location_begin", "dropoff_location_begin",
+			"pickup_location_end", "dropoff_location_end", "created_at", "updated_at"}
+		for i, ticketID := range ticketIDsArg(args) {
+			rows.values = append(rows.values, []driver.Value{int64(i + 1), ticketID, int64(1), int64(4), nil, nil, now, now})
+		}
+	case strings.Contains(query, "FROM Ticket"):
+		table = "Ticket"
+		if len(args) != 2 {
+			return nil, fmt.Errorf("countingConn: unexpected %d args for %q", len(args), query)
+		}
+		limit, _ := args[0].Value.(int64)
+		offset, _ := args[1].Value.(int64)
+		rows.columns = []string{"ticket_id", "trip_id_begin", "trip_id_end", "type", "customer_id", "phone", "email", "name",
+			"price", "status", "booking_time", "payment_status", "booking_channel", "created_at", "updated_at",
+			"policy_id", "booked_by", "fare_breakdown"}
+		for i := offset; i < offset+limit; i++ {
+			ticketID, tripBegin, tripEnd := pageTicket(int(i))
+			var end driver.Value
+			ticketType := int64(0)
+			if tripEnd.Valid {
+				end, ticketType = tripEnd.String, 1
+			}
+			rows.values = append(rows.values, []driver.Value{ticketID, tripBegin, end, ticketType, int64(7), nil, nil, nil,
+				250000.0, int64(1), now, int64(1), int64(0), now, now, int64(1), nil, []byte(`{}`)})
+		}
+	default:
+		return nil, fmt.Errorf("countingConn: unexpected query %q", query)
+	}
+
+	c.mu.Lock()
+	c.queries[table]++
+	c.mu.Unlock()
+	return rows, nil
+}
+
+// ticketIDsArg đọc mảng Postgres {"T1","T2"} của tham số đầu tiên.
+func ticketIDsArg(args []driver.NamedValue) []string {
+	if len(args) != 1 {
+		return nil
+	}
+	arg, _ := args[0].Value.(string)
+	return strings.Split(strings.NewReplacer("{", "", "}", "", `"`, "").Replace(arg), ",")
+}
+
+func ticketIndex(ticketID string) int {
+	var i int
+	fmt.Sscanf(ticketID, "T%06d", &i)
+	return i
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
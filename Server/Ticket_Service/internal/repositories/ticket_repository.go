@@ -9,9 +9,11 @@ import (
 	"strconv"
 	"ticket-service/domain/models"
 	"ticket-service/internal/db" // sqlc generated package
+	"ticket-service/pkg/tripclient"
 	"ticket-service/pkg/utils"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	// "github.com/jackc/pgx/v4" // No longer needed for pgx.ErrNoRows
 )
@@ -30,24 +32,86 @@ type CreateTicketTransactionParams struct {
 	TicketDetail db.CreateTicketDetailsParams
 	SeatIDsBegin []int32
 	SeatIDsEnd   []int32
+	SegmentBegin models.SeatSegment              // Đoạn đường giữ ghế của chiều đi
+	SegmentEnd   models.SeatSegment              // Đoạn đường giữ ghế của chiều về
+	Passengers   map[int32]models.GroupPassenger // Hành khách theo ghế chiều đi (vé đoàn), nil với vé thường
+	BookingID    uuid.UUID                       // Yêu cầu đặt vé được hoàn tất cùng transaction, uuid.Nil khi tạo vé trực tiếp
 	OutboxEvents []db.CreateOutboxEventParams
 }
 
+// CreateGroupBookingTransactionParams gom việc tạo đơn đoàn và toàn bộ vé của đoàn vào một transaction.
+type CreateGroupBookingTransactionParams struct {
+	Group        db.CreateTicketGroupParams
+	Tickets      []CreateTicketTransactionParams
+	OutboxEvents []db.CreateOutboxEventParams
+}
+
+// CancelSeatTicketTransactionParams gom việc huỷ một ghế (một hành khách của đoàn), cập nhật giá vé,
+// huỷ vé nếu không còn ghế nào, ghi log và outbox event vào một transaction.
+type CancelSeatTicketTransactionParams struct {
+	TicketID     string
+	SeatTicketID int32
+	Ticket       db.UpdateTicketFareParams
+	// CancelTicket khác nil khi đây là ghế cuối cùng của vé: vé chuyển sang trạng thái huỷ
+	CancelTicket *db.UpdateTicketPaymentStatusParams
+	Change       models.StatusChange
+	LogAction    string
+	OutboxEvents []db.CreateOutboxEventParams
+}
+
+// CancelTicketTransactionParams gom việc huỷ vé (ticket + seat_tickets), ghi Ticket_Logs và outbox event vào một transaction.
+type CancelTicketTransactionParams struct {
+	UpdateStatusTransactionParams
+	LogAction string
+}
+
+// ChangeSeatsTransactionParams gom việc trả ghế cũ, giữ ghế mới, cập nhật giá vé, ghi log và outbox event vào một transaction.
+type ChangeSeatsTransactionParams struct {
+	TicketID       string
+	ReleaseSeatIDs []int32
+	ClaimSeatIDs   []int32
+	NewTripID      string
+	Segment        models.SeatSegment              // Đoạn đường giữ ghế mới (giữ theo điểm đón/trả của vé)
+	SeatStatus     int16                           // Trạng thái của seat_tickets mới (giữ theo trạng thái ghế cũ)
+	Passengers     map[int32]models.GroupPassenger // Hành khách của vé đoàn theo ghế mới
+	Ticket         db.UpdateTicketFareParams
+	LogActions     []string
+	OutboxEvents   []db.CreateOutboxEventParams
+	// RebookingOfferTripID là chuyến bị dời giờ có lượt đổi miễn phí được dùng cho lần đổi này (rỗng nếu không dùng).
+	RebookingOfferTripID string
+}
+
 // TicketRepositoryInterface defines the methods for ticket repository
 // Note: Renamed original TicketRepository to TicketRepositoryInterface for clarity with implementation struct
 type TicketRepositoryInterface interface {
 	GetTicketByID(ctx context.Context, ticketID string) (*models.TicketReturn, error)
 	GetTicketByCustomer(ctx context.Context, customerID sql.NullInt32) ([]*models.TicketReturn, error) // Use sql.NullInt32 from sqlc
 	GetInfoTicketByPhone(ctx context.Context, info *models.TicketInfoInput) (*models.TicketReturn, error)
+	AttachTripDetails(ctx context.Context, tickets []*models.TicketReturn)
 	FindTicketByTripAndSeat(ctx context.Context, seatID int32) (*db.SeatTicket, error) // seatID is int32 in db.SeatTicket
 	AllTicketsStatus2BySeat(ctx context.Context, seatID int32) (bool, error)           // seatID is int32
 	GenerateUniqueTicketID(ctx context.Context) (string, error)
 	CreateTicket(ctx context.Context, ticket *db.Ticket, ticketDetail *db.TicketDetail, seatIDs []int32, tripID string) error // << UPDATED signature
 
 	CreateTicketInTransaction(ctx context.Context, params CreateTicketTransactionParams) error
-
-	UpdateTicketPaymentStatus(ctx context.Context, ticketID string, paymentStatus int16, generalStatus int16, tripID string) error
-	UpdateSeatTicketsStatus(ctx context.Context, ticketID string, status int16) error // status is int16
+	CancelTicketInTransaction(ctx context.Context, params CancelTicketTransactionParams) error
+	ChangeSeatsInTransaction(ctx context.Context, params ChangeSeatsTransactionParams) error
+	CreateGroupBookingInTransaction(ctx context.Context, params CreateGroupBookingTransactionParams) error
+	CancelSeatTicketInTransaction(ctx context.Context, params CancelSeatTicketTransactionParams) error
+	GenerateUniqueGroupRef(ctx context.Context) (string, error)
+	GetTicketGroup(ctx context.Context, groupRef string) (*db.TicketGroup, []*models.TicketReturn, error)
+	GetGroupRefByTicketID(ctx context.Context, ticketID string) (string, error)
+
+	CreateBookingRequest(ctx context.Context, params db.CreateBookingRequestParams) error
+	GetBookingRequest(ctx context.Context, bookingID uuid.UUID) (db.BookingRequest, error)
+	StartBookingRequest(ctx context.Context, bookingID uuid.UUID) (db.BookingRequest, error)
+	FailBookingRequest(ctx context.Context, bookingID uuid.UUID, reason string) error
+	ConnectBookingRequest(ctx context.Context, bookingID uuid.UUID) (db.BookingRequest, error)
+	ExpireUnconnectedBookingRequests(ctx context.Context, limit int32) ([]db.ExpireUnconnectedBookingRequestsRow, error)
+	ReopenExpiredBookingRequest(ctx context.Context, bookingID uuid.UUID) error
+
+	UpdateTicketPaymentStatus(ctx context.Context, ticketID string, paymentStatus int16, generalStatus int16, tripID string, change models.StatusChange) error
+	UpdateSeatTicketsStatus(ctx context.Context, ticketID string, status int16, change models.StatusChange) error // status is int16
 
 	GetTicketFromCache(ctx context.Context, ticketID string) (*db.Ticket, error) // Cache db.Ticket
 	CacheTicket(ctx context.Context, ticket *db.Ticket) error                    // Cache db.Ticket
@@ -56,24 +120,33 @@ type TicketRepositoryInterface interface {
 	ReleaseSeat(ctx context.Context, seatID int32) error // seatID consistent with db
 	IsSeatHeld(ctx context.Context, seatID int32) (bool, error)
 
-	AreSeatsAvailable(ctx context.Context, seatIDs []int32) ([]db.AreSeatsAvailableRow, error)
+	AreSeatsAvailable(ctx context.Context, seatIDs []int32, segment models.SeatSegment, holderCustomerID int32) ([]db.AreSeatsAvailableRow, error)
 
-	GetAvailableSeatsByTripID(ctx context.Context, tripID string) ([]models.SeatReturn, error)
+	GetAvailableSeatsByTripID(ctx context.Context, tripID string, segment models.SeatSegment) ([]models.SeatReturn, error)
 	AcquireLock(ctx context.Context, lockKey string, ttl time.Duration) (bool, func(), error)
 	CleanupTicketCache(ctx context.Context, ticketID string, seatIDs []int32, tripID string) error
 
 	SetupExpirationHandler(ctx context.Context) error
-	UpdateTicketStatus(ctx context.Context, ticketID string, status int16) error // status is int16
-	GetSeatIDsByTicketID(ctx context.Context, ticketID string) ([]int32, error)  // Returns []int32 from sqlc
+	UpdateTicketStatus(ctx context.Context, ticketID string, status int16, change models.StatusChange) error // status is int16
+	GetTicketHistory(ctx context.Context, ticketID string) ([]db.TicketLog, error)
+	LogTicketAction(ctx context.Context, ticketID string, action string) error
+	GetSeatIDsByTicketID(ctx context.Context, ticketID string) ([]int32, error) // Returns []int32 from sqlc
 
-	CacheAvailableSeats(ctx context.Context, tripID string, seats []models.SeatReturn) error
-	GetAvailableSeatsFromCache(ctx context.Context, tripID string) ([]models.SeatReturn, error)
+	CacheAvailableSeats(ctx context.Context, tripID string, segment models.SeatSegment, seats []models.SeatReturn) error
+	GetAvailableSeatsFromCache(ctx context.Context, tripID string, segment models.SeatSegment) ([]models.SeatReturn, error)
 	PublishSeatStatusChange(ctx context.Context, message *models.SeatStatusMessage) error
 	SubscribeToSeatStatusChanges(ctx context.Context) error
-	UpdateCachedAvailableSeats(ctx context.Context, tripID string, seatIDs []int32, action string) error
+	InvalidateAvailableSeatsCache(ctx context.Context, tripID string) error
 
 	GetAllTickets(ctx context.Context, limit int, offset int) ([]*models.TicketReturn, error)
 	GetTotalTicketCount(ctx context.Context) (int64, error)
+	SearchTickets(ctx context.Context, params db.SearchTicketsParams) ([]*models.TicketReturn, error)
+	CountSearchTickets(ctx context.Context, params db.CountSearchTicketsParams) (int64, error)
+
+	ListActiveTicketIDsByTrip(ctx context.Context, tripID string) ([]string, error)
+	OpenRebookingOffer(ctx context.Context, params db.UpsertTripRebookingOfferParams, notification *db.CreateOutboxEventParams) (bool, error)
+	GetOpenRebookingOffer(ctx context.Context, ticketID, tripID string) (*db.TripRebookingOffer, error)
+	CloseCancelledTrip(ctx context.Context, tripID string, notify func(entry db.TripWaitlist) (db.CreateOutboxEventParams, error)) (int, int64, error)
 }
 
 type ticketRepositoryImpl struct {
@@ -82,24 +155,32 @@ type ticketRepositoryImpl struct {
 	redis  *redis.Client
 	utils  *utils.Utils
 	logger utils.Logger
+	trips  tripclient.Client // nil: không gắn thông tin chuyến (AttachTripDetails)
 }
 
-func NewTicketRepository(sqlDB *sql.DB, redis *redis.Client, utils *utils.Utils, logger utils.Logger) TicketRepositoryInterface {
+func NewTicketRepository(sqlDB *sql.DB, redis *redis.Client, utils *utils.Utils, logger utils.Logger, trips tripclient.Client) TicketRepositoryInterface {
 	return &ticketRepositoryImpl{
 		sqlDB:  sqlDB,
 		q:      db.New(sqlDB),
 		redis:  redis,
 		utils:  utils,
 		logger: logger,
+		trips:  trips,
 	}
 }
 
-// AreSeatsAvailable performs a single database query to check the status of multiple seats.
-func (r *ticketRepositoryImpl) AreSeatsAvailable(ctx context.Context, seatIDs []int32) ([]db.AreSeatsAvailableRow, error) {
+// AreSeatsAvailable performs a single database query to check the status of multiple seats on a segment of the trip.
+// Seats held from the waitlist count as free only for holderCustomerID (0 when the booker is not a customer).
+func (r *ticketRepositoryImpl) AreSeatsAvailable(ctx context.Context, seatIDs []int32, segment models.SeatSegment, holderCustomerID int32) ([]db.AreSeatsAvailableRow, error) {
 	if len(seatIDs) == 0 {
 		return []db.AreSeatsAvailableRow{}, nil
 	}
-	return r.q.AreSeatsAvailable(ctx, seatIDs)
+	return r.q.AreSeatsAvailable(ctx, db.AreSeatsAvailableParams{
+		ToStop:           segment.ToStop,
+		FromStop:         segment.FromStop,
+		HolderCustomerID: holderCustomerID,
+		SeatIds:          seatIDs,
+	})
 }
 
 func (r *ticketRepositoryImpl) AcquireLock(ctx context.Context, lockKey string, ttl time.Duration) (bool, func(), error) {
@@ -136,50 +217,300 @@ func (r *ticketRepositoryImpl) CreateTicketInTransaction(ctx context.Context, pa
 
 	qtx := r.q.WithTx(tx)
 
-	// 1. Create Ticket
+	// 1. Create Ticket, Ticket Details, Seat Tickets
+	if err := createTicketRecords(ctx, qtx, params); err != nil {
+		return err
+	}
+
+	// 2. Create Outbox Event
+	for _, OutboxEvent := range params.OutboxEvents {
+		err = qtx.CreateOutboxEvent(ctx, OutboxEvent)
+		if err != nil {
+			return fmt.Errorf("failed to create outbox event: %w", err)
+		}
+	}
+
+	// 3. Hoàn tất yêu cầu đặt vé bất đồng bộ; yêu cầu đã hết hạn thì rollback vé
+	if params.BookingID != uuid.Nil {
+		if err := completeBookingRequest(ctx, qtx, params.BookingID, params.Ticket.TicketID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// createTicketRecords ghi vé, chi tiết vé, các ghế và đánh dấu ghế giữ từ danh sách chờ trong transaction qtx.
+func createTicketRecords(ctx context.Context, qtx *db.Queries, params CreateTicketTransactionParams) error {
+	// 1. Ticket
+	if len(params.Ticket.FareBreakdown) == 0 {
+		params.Ticket.FareBreakdown = json.RawMessage(`{}`) // Fare_Breakdown là JSONB NOT NULL
+	}
 	createdTicket, err := qtx.CreateTicket(ctx, params.Ticket)
 	if err != nil {
 		return fmt.Errorf("failed to insert ticket: %w", err)
 	}
 
-	// 2. Create Ticket Details
+	// 2. Ticket Details
 	params.TicketDetail.TicketID = createdTicket.TicketID
-	_, err = qtx.CreateTicketDetails(ctx, params.TicketDetail)
-	if err != nil {
+	if _, err := qtx.CreateTicketDetails(ctx, params.TicketDetail); err != nil {
 		return fmt.Errorf("failed to insert ticket details: %w", err)
 	}
 
-	// 3. Create Seat Tickets
+	// 3. Seat Tickets
 	for _, seatID := range params.SeatIDsBegin {
-		_, err = qtx.CreateSeatTicket(ctx, db.CreateSeatTicketParams{
-			SeatID:   seatID,
-			TicketID: createdTicket.TicketID,
-			Status:   int16(models.SeatStatusPendingPayment),
-			TripID:   params.Ticket.TripIDBegin,
-		})
-		if err != nil {
+		passenger := params.Passengers[seatID]
+		if _, err := qtx.CreateSeatTicket(ctx, db.CreateSeatTicketParams{
+			SeatID:         seatID,
+			TicketID:       createdTicket.TicketID,
+			Status:         int16(models.SeatStatusPendingPayment),
+			TripID:         params.Ticket.TripIDBegin,
+			FromStop:       params.SegmentBegin.FromStop,
+			ToStop:         params.SegmentBegin.ToStop,
+			PassengerName:  utils.ToNullString(passenger.Name),
+			PassengerPhone: utils.ToNullString(passenger.Phone),
+		}); err != nil {
 			return fmt.Errorf("failed to insert seat ticket for seat %d: %w", seatID, err)
 		}
 	}
 	if createdTicket.Type == 1 {
 		for _, seatID := range params.SeatIDsEnd {
-			_, err = qtx.CreateSeatTicket(ctx, db.CreateSeatTicketParams{
+			if _, err := qtx.CreateSeatTicket(ctx, db.CreateSeatTicketParams{
 				SeatID:   seatID,
 				TicketID: createdTicket.TicketID,
 				Status:   int16(models.SeatStatusPendingPayment),
 				TripID:   params.Ticket.TripIDEnd.String,
-			})
-			if err != nil {
+				FromStop: params.SegmentEnd.FromStop,
+				ToStop:   params.SegmentEnd.ToStop,
+			}); err != nil {
 				return fmt.Errorf("failed to insert seat ticket for seat %d: %w", seatID, err)
 			}
 		}
 	}
 
-	// 4. Create Outbox Event
-	for _, OutboxEvent := range params.OutboxEvents {
-		err = qtx.CreateOutboxEvent(ctx, OutboxEvent)
+	// 4. Ghế đang giữ cho khách từ danh sách chờ: đánh dấu đã đặt để không bị trả lại khi hết hạn giữ
+	if params.Ticket.CustomerID.Valid {
+		if _, err := qtx.ClaimWaitlistOffers(ctx, db.ClaimWaitlistOffersParams{
+			CustomerID: params.Ticket.CustomerID.Int32,
+			TripID:     params.Ticket.TripIDBegin,
+			SeatIds:    params.SeatIDsBegin,
+		}); err != nil {
+			return fmt.Errorf("failed to claim waitlist offers: %w", err)
+		}
+		if createdTicket.Type == 1 {
+			if _, err := qtx.ClaimWaitlistOffers(ctx, db.ClaimWaitlistOffersParams{
+				CustomerID: params.Ticket.CustomerID.Int32,
+				TripID:     params.Ticket.TripIDEnd.String,
+				SeatIds:    params.SeatIDsEnd,
+			}); err != nil {
+				return fmt.Errorf("failed to claim waitlist offers: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// CancelTicketInTransaction cập nhật trạng thái vé và seat_tickets, ghi log và tạo outbox event trong một transaction.
+func (r *ticketRepositoryImpl) CancelTicketInTransaction(ctx context.Context, params CancelTicketTransactionParams) error {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := r.q.WithTx(tx)
+
+	// 1. Update ticket's payment status and general status (state machine checked)
+	if _, err := transitionTicket(ctx, qtx, params.TicketID, params.GeneralTicketStatus, params.PaymentStatus, params.Change); err != nil {
+		return err
+	}
+
+	// 2. Update status of associated seat_tickets
+	if err := transitionSeatTickets(ctx, qtx, params.TicketID, params.SeatTicketStatus, params.Change); err != nil {
+		return err
+	}
+
+	// 3. Ticket log
+	if params.LogAction != "" {
+		if _, err := qtx.CreateTicketLog(ctx, db.CreateTicketLogParams{
+			TicketID: params.TicketID,
+			Action:   params.LogAction,
+		}); err != nil {
+			return fmt.Errorf("failed to create ticket log: %w", err)
+		}
+	}
+
+	// 4. Outbox events
+	for _, event := range params.OutboxEvents {
+		if err := qtx.CreateOutboxEvent(ctx, event); err != nil {
+			return fmt.Errorf("failed to create outbox event for topic %s: %w", event.Topic, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ChangeSeatsInTransaction trả các ghế cũ và giữ các ghế mới của vé trong một transaction.
+func (r *ticketRepositoryImpl) ChangeSeatsInTransaction(ctx context.Context, params ChangeSeatsTransactionParams) error {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := r.q.WithTx(tx)
+
+	// 1. Release old seats
+	if err := qtx.DeleteSeatTicketsBySeatIDs(ctx, db.DeleteSeatTicketsBySeatIDsParams{
+		TicketID: params.TicketID,
+		SeatIds:  params.ReleaseSeatIDs,
+	}); err != nil {
+		return fmt.Errorf("failed to release old seats: %w", err)
+	}
+
+	// 2. Claim new seats
+	for _, seatID := range params.ClaimSeatIDs {
+		if _, err := qtx.CreateSeatTicket(ctx, db.CreateSeatTicketParams{
+			SeatID:         seatID,
+			TicketID:       params.TicketID,
+			Status:         params.SeatStatus,
+			TripID:         params.NewTripID,
+			FromStop:       params.Segment.FromStop,
+			ToStop:         params.Segment.ToStop,
+			PassengerName:  utils.ToNullString(params.Passengers[seatID].Name),
+			PassengerPhone: utils.ToNullString(params.Passengers[seatID].Phone),
+		}); err != nil {
+			return fmt.Errorf("failed to insert seat ticket for seat %d: %w", seatID, err)
+		}
+	}
+
+	// 3. Update ticket trips and price
+	if len(params.Ticket.FareBreakdown) == 0 {
+		params.Ticket.FareBreakdown = json.RawMessage(`{}`)
+	}
+	if _, err := qtx.UpdateTicketFare(ctx, params.Ticket); err != nil {
+		return fmt.Errorf("failed to update ticket fare: %w", err)
+	}
+
+	// 4. Ticket logs
+	for _, action := range params.LogActions {
+		if _, err := qtx.CreateTicketLog(ctx, db.CreateTicketLogParams{
+			TicketID: params.TicketID,
+			Action:   action,
+		}); err != nil {
+			return fmt.Errorf("failed to create ticket log: %w", err)
+		}
+	}
+
+	// 5. Outbox events
+	for _, event := range params.OutboxEvents {
+		if err := qtx.CreateOutboxEvent(ctx, event); err != nil {
+			return fmt.Errorf("failed to create outbox event for topic %s: %w", event.Topic, err)
+		}
+	}
+
+	// 6. Free rebooking offer of a rescheduled trip
+	if params.RebookingOfferTripID != "" {
+		used, err := qtx.UseTripRebookingOffer(ctx, db.UseTripRebookingOfferParams{
+			TicketID: params.TicketID,
+			TripID:   params.RebookingOfferTripID,
+		})
 		if err != nil {
-			return fmt.Errorf("failed to create outbox event: %w", err)
+			return fmt.Errorf("failed to use rebooking offer: %w", err)
+		}
+		if used == 0 {
+			return fmt.Errorf("ticket %s trip %s: %w", params.TicketID, params.RebookingOfferTripID, ErrRebookingOfferUsed)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CreateGroupBookingInTransaction tạo đơn đoàn và toàn bộ vé của đoàn trong một transaction:
+// một ghế không còn trống thì cả đơn không được tạo.
+func (r *ticketRepositoryImpl) CreateGroupBookingInTransaction(ctx context.Context, params CreateGroupBookingTransactionParams) error {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := r.q.WithTx(tx)
+
+	// 1. Group
+	if _, err := qtx.CreateTicketGroup(ctx, params.Group); err != nil {
+		return fmt.Errorf("failed to insert ticket group: %w", err)
+	}
+
+	// 2. Tickets of the group
+	for _, ticketParams := range params.Tickets {
+		if err := createTicketRecords(ctx, qtx, ticketParams); err != nil {
+			return err
+		}
+		if err := qtx.AddTicketToGroup(ctx, db.AddTicketToGroupParams{
+			TicketID: ticketParams.Ticket.TicketID,
+			GroupRef: params.Group.GroupRef,
+		}); err != nil {
+			return fmt.Errorf("failed to add ticket %s to group: %w", ticketParams.Ticket.TicketID, err)
+		}
+	}
+
+	// 3. Outbox events
+	for _, event := range params.OutboxEvents {
+		if err := qtx.CreateOutboxEvent(ctx, event); err != nil {
+			return fmt.Errorf("failed to create outbox event for topic %s: %w", event.Topic, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CancelSeatTicketInTransaction huỷ một ghế của vé (một hành khách của đoàn) và cập nhật giá vé trong một transaction.
+// Trả về models.ErrIllegalTransition nếu ghế đã bị huỷ hoặc đã check-in, sql.ErrNoRows nếu ghế không thuộc vé.
+func (r *ticketRepositoryImpl) CancelSeatTicketInTransaction(ctx context.Context, params CancelSeatTicketTransactionParams) error {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := r.q.WithTx(tx)
+
+	// 1. Cancel the seat
+	if _, err := transitionSeatTicket(ctx, qtx, params.TicketID, params.SeatTicketID, models.SeatStatusCancelled, params.Change); err != nil {
+		return err
+	}
+
+	// 2. Update ticket price
+	if len(params.Ticket.FareBreakdown) == 0 {
+		params.Ticket.FareBreakdown = json.RawMessage(`{}`)
+	}
+	if _, err := qtx.UpdateTicketFare(ctx, params.Ticket); err != nil {
+		return fmt.Errorf("failed to update ticket fare: %w", err)
+	}
+
+	// 3. Last seat of the ticket: cancel the ticket
+	if params.CancelTicket != nil {
+		if _, err := transitionTicket(ctx, qtx, params.TicketID, params.CancelTicket.Status, params.CancelTicket.PaymentStatus, params.Change); err != nil {
+			return err
+		}
+	}
+
+	// 4. Ticket log
+	if params.LogAction != "" {
+		if _, err := qtx.CreateTicketLog(ctx, db.CreateTicketLogParams{
+			TicketID: params.TicketID,
+			Action:   params.LogAction,
+		}); err != nil {
+			return fmt.Errorf("failed to create ticket log: %w", err)
+		}
+	}
+
+	// 5. Outbox events
+	for _, event := range params.OutboxEvents {
+		if err := qtx.CreateOutboxEvent(ctx, event); err != nil {
+			return fmt.Errorf("failed to create outbox event for topic %s: %w", event.Topic, err)
 		}
 	}
 
@@ -218,6 +549,10 @@ func (r *ticketRepositoryImpl) CreateTicket(
 		BookingChannel: ticket.BookingChannel,
 		PolicyID:       ticket.PolicyID,
 		BookedBy:       ticket.BookedBy,
+		FareBreakdown:  ticket.FareBreakdown,
+	}
+	if len(ticketParams.FareBreakdown) == 0 {
+		ticketParams.FareBreakdown = json.RawMessage(`{}`)
 	}
 	createdTicket, err := qtx.CreateTicket(ctx, ticketParams)
 	if err != nil {
@@ -260,6 +595,8 @@ func (r *ticketRepositoryImpl) CreateTicket(
 			SeatID:   seatIDValue,
 			TicketID: createdTicket.TicketID,
 			Status:   0, // Assuming 0 is 'pending' or initial status
+			FromStop: models.FullTripSegment.FromStop,
+			ToStop:   models.FullTripSegment.ToStop,
 		}
 		_, err = qtx.CreateSeatTicket(ctx, seatTicketParams)
 		if err != nil {
@@ -270,26 +607,18 @@ func (r *ticketRepositoryImpl) CreateTicket(
 	return tx.Commit()
 }
 
-func (r *ticketRepositoryImpl) UpdateCachedAvailableSeats(ctx context.Context, tripID string, seatIDs []int32, action string) error {
-	availableSeatsKey := fmt.Sprintf("available_seats:%s", tripID)
-
-	// Convert seatIDs to a slice of interface{} for Redis commands
-	members := make([]interface{}, len(seatIDs))
-	for i, id := range seatIDs {
-		members[i] = id
-	}
-
-	var err error
-	if action == "REMOVE" {
-		err = r.redis.SRem(ctx, availableSeatsKey, members...).Err()
-	} else if action == "ADD" {
-		err = r.redis.SAdd(ctx, availableSeatsKey, members...).Err()
-	} else {
-		return fmt.Errorf("invalid action: %s", action)
-	}
+// availableSeatsKey là hash ghế trống của chuyến: mỗi field là một đoạn đường (from-to), value là JSON danh sách seat ID.
+func availableSeatsKey(tripID string) string {
+	return fmt.Sprintf("available_seats_by_segment:%s", tripID)
+}
 
+// InvalidateAvailableSeatsCache được gọi khi ghế của chuyến được giữ hoặc trả lại.
+// Ghế giữ/trả trên một đoạn làm thay đổi ghế trống của mọi đoạn giao với nó, nên xoá cache của cả chuyến
+// thay vì sửa từng đoạn; lần đọc sau sẽ lấy lại từ DB.
+func (r *ticketRepositoryImpl) InvalidateAvailableSeatsCache(ctx context.Context, tripID string) error {
+	err := r.redis.Del(ctx, availableSeatsKey(tripID)).Err()
 	if err != nil {
-		r.logger.Error("Failed to update available seats cache for trip %s: %v", tripID, err)
+		r.logger.Error("Failed to invalidate available seats cache for trip %s: %v", tripID, err)
 	}
 	return err
 }
@@ -327,7 +656,7 @@ func (r *ticketRepositoryImpl) CacheTemporarySeat(ctx context.Context, seatID in
 	pipe := r.redis.Pipeline()
 	pipe.HMSet(ctx, key, holdData)
 	pipe.Expire(ctx, key, 10*time.Minute) // TODO: Make expiration configurable
-	pipe.SRem(ctx, fmt.Sprintf("available_seats:%s", tripID), seatID)
+	pipe.Del(ctx, availableSeatsKey(tripID))
 	_, err := pipe.Exec(ctx)
 	return err
 }
@@ -405,88 +734,7 @@ func (r *ticketRepositoryImpl) GetTicketByCustomer(ctx context.Context, customer
 		}
 		return nil, fmt.Errorf("failed to get core tickets by customer ID: %w", err)
 	}
-
-	var ticketsReturn []*models.TicketReturn
-	for _, coreTicket := range coreTickets {
-		t := &models.TicketReturn{
-			TicketID:       coreTicket.TicketID,
-			TripIDBegin:    coreTicket.TripIDBegin, // << ADDED
-			TripIDEnd:      coreTicket.TripIDEnd,
-			Type:           coreTicket.Type,
-			CustomerID:     coreTicket.CustomerID,
-			Phone:          coreTicket.Phone,
-			Email:          coreTicket.Email,
-			Name:           coreTicket.Name,
-			Price:          coreTicket.Price,
-			Status:         coreTicket.Status,
-			BookingTime:    coreTicket.BookingTime,
-			PaymentStatus:  coreTicket.PaymentStatus,
-			BookingChannel: coreTicket.BookingChannel,
-			CreatedAt:      coreTicket.CreatedAt,
-			UpdatedAt:      coreTicket.UpdatedAt,
-			PolicyID:       coreTicket.PolicyID,
-			BookedBy:       coreTicket.BookedBy,
-		}
-
-		details, err := r.q.GetTicketDetailsByTicketID(ctx, coreTicket.TicketID)
-		if err != nil && err != sql.ErrNoRows {
-			return nil, fmt.Errorf("failed to get ticket details for ticket %s: %w", coreTicket.TicketID, err)
-		}
-		for _, det := range details {
-			t.Details = append(t.Details, db.TicketDetail{
-				DetailID:             det.DetailID,
-				TicketID:             det.TicketID,
-				PickupLocationBegin:  det.PickupLocationBegin,
-				DropoffLocationBegin: det.DropoffLocationBegin,
-				PickupLocationEnd:    det.PickupLocationEnd,
-				DropoffLocationEnd:   det.DropoffLocationEnd,
-				CreatedAt:            det.CreatedAt,
-				UpdatedAt:            det.UpdatedAt,
-			})
-		}
-
-		seatTicketRows, err := r.q.GetSeatTicketsByTicketID(ctx, coreTicket.TicketID)
-		if err != nil && err != sql.ErrNoRows {
-			return nil, fmt.Errorf("failed to get seat tickets for ticket %s: %w", coreTicket.TicketID, err)
-		}
-		// 		type GetSeatTicketsByTicketIDRow struct {
-		// 	ID         int32          `json:"id"`
-		// 	SeatID     int32          `json:"seat_id"`
-		// 	TicketID   string         `json:"ticket_id"`
-		// 	Status     int16          `json:"status"`
-		// 	CreatedAt  sql.NullTime   `json:"created_at"`
-		// 	UpdatedAt  sql.NullTime   `json:"updated_at"`
-		// 	SeatName   sql.NullString `json:"seat_name"`
-		// 	SeatTripID string         `json:"seat_trip_id"`
-		// }
-		for _, stRow := range seatTicketRows {
-			if stRow.TripID == t.TripIDBegin {
-				t.SeatTicketsBegin = append(t.SeatTicketsBegin, db.GetSeatTicketsByTicketIDRow{ // Map to models.SeatTicket
-					ID:        stRow.ID,
-					SeatID:    stRow.SeatID,
-					TicketID:  stRow.TicketID,
-					Status:    stRow.Status,
-					CreatedAt: stRow.CreatedAt,
-					UpdatedAt: stRow.UpdatedAt,
-					SeatName:  stRow.SeatName,
-					TripID:    stRow.TripID,
-				})
-			} else {
-				t.SeatTicketsEnd = append(t.SeatTicketsEnd, db.GetSeatTicketsByTicketIDRow{ // Map to models.SeatTicket
-					ID:        stRow.ID,
-					SeatID:    stRow.SeatID,
-					TicketID:  stRow.TicketID,
-					Status:    stRow.Status,
-					CreatedAt: stRow.CreatedAt,
-					UpdatedAt: stRow.UpdatedAt,
-					SeatName:  stRow.SeatName,
-					TripID:    stRow.TripID,
-				})
-			}
-		}
-		ticketsReturn = append(ticketsReturn, t)
-	}
-	return ticketsReturn, nil
+	return r.assembleTickets(ctx, coreTickets)
 }
 
 func (r *ticketRepositoryImpl) GetTicketByID(ctx context.Context, ticketID string) (*models.TicketReturn, error) {
@@ -497,74 +745,7 @@ func (r *ticketRepositoryImpl) GetTicketByID(ctx context.Context, ticketID strin
 		}
 		return nil, fmt.Errorf("failed to get core ticket by ID %s: %w", ticketID, err)
 	}
-
-	t := &models.TicketReturn{
-		TicketID:       coreTicket.TicketID,
-		TripIDBegin:    coreTicket.TripIDBegin, // << ADDED
-		TripIDEnd:      coreTicket.TripIDEnd,
-		Type:           coreTicket.Type,
-		CustomerID:     coreTicket.CustomerID,
-		Phone:          coreTicket.Phone,
-		Email:          coreTicket.Email,
-		Name:           coreTicket.Name,
-		Price:          coreTicket.Price,
-		Status:         coreTicket.Status,
-		BookingTime:    coreTicket.BookingTime,
-		PaymentStatus:  coreTicket.PaymentStatus,
-		BookingChannel: coreTicket.BookingChannel,
-		CreatedAt:      coreTicket.CreatedAt,
-		UpdatedAt:      coreTicket.UpdatedAt,
-		PolicyID:       coreTicket.PolicyID,
-		BookedBy:       coreTicket.BookedBy,
-	}
-
-	details, err := r.q.GetTicketDetailsByTicketID(ctx, ticketID)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to get ticket details for ticket %s: %w", ticketID, err)
-	}
-	for _, det := range details {
-		t.Details = append(t.Details, db.TicketDetail{
-			DetailID:             det.DetailID,
-			TicketID:             det.TicketID,
-			PickupLocationBegin:  det.PickupLocationBegin,
-			DropoffLocationBegin: det.DropoffLocationBegin,
-			PickupLocationEnd:    det.PickupLocationEnd,
-			DropoffLocationEnd:   det.DropoffLocationEnd,
-			CreatedAt:            det.CreatedAt,
-			UpdatedAt:            det.UpdatedAt,
-		})
-	}
-
-	seatTicketRows, err := r.q.GetSeatTicketsByTicketID(ctx, ticketID)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to get seat tickets for ticket %s: %w", ticketID, err)
-	}
-	for _, stRow := range seatTicketRows {
-		if stRow.TripID == t.TripIDBegin {
-			t.SeatTicketsBegin = append(t.SeatTicketsBegin, db.GetSeatTicketsByTicketIDRow{ // Map to models.SeatTicket
-				ID:        stRow.ID,
-				SeatID:    stRow.SeatID,
-				TicketID:  stRow.TicketID,
-				Status:    stRow.Status,
-				CreatedAt: stRow.CreatedAt,
-				UpdatedAt: stRow.UpdatedAt,
-				SeatName:  stRow.SeatName,
-				TripID:    stRow.TripID,
-			})
-		} else {
-			t.SeatTicketsEnd = append(t.SeatTicketsEnd, db.GetSeatTicketsByTicketIDRow{ // Map to models.SeatTicket
-				ID:        stRow.ID,
-				SeatID:    stRow.SeatID,
-				TicketID:  stRow.TicketID,
-				Status:    stRow.Status,
-				CreatedAt: stRow.CreatedAt,
-				UpdatedAt: stRow.UpdatedAt,
-				SeatName:  stRow.SeatName,
-				TripID:    stRow.TripID,
-			})
-		}
-	}
-	return t, nil
+	return r.assembleTicket(ctx, coreTicket)
 }
 
 func (r *ticketRepositoryImpl) GetInfoTicketByPhone(ctx context.Context, info *models.TicketInfoInput) (*models.TicketReturn, error) {
@@ -579,74 +760,7 @@ func (r *ticketRepositoryImpl) GetInfoTicketByPhone(ctx context.Context, info *m
 		}
 		return nil, fmt.Errorf("failed to get core ticket by ID %s and phone %s: %w", info.TicketID, info.Phone, err)
 	}
-
-	t := &models.TicketReturn{
-		TicketID:       coreTicket.TicketID,
-		TripIDBegin:    coreTicket.TripIDBegin, // << ADDED
-		TripIDEnd:      coreTicket.TripIDEnd,
-		Type:           coreTicket.Type,
-		CustomerID:     coreTicket.CustomerID,
-		Phone:          coreTicket.Phone,
-		Email:          coreTicket.Email,
-		Name:           coreTicket.Name,
-		Price:          coreTicket.Price,
-		Status:         coreTicket.Status,
-		BookingTime:    coreTicket.BookingTime,
-		PaymentStatus:  coreTicket.PaymentStatus,
-		BookingChannel: coreTicket.BookingChannel,
-		CreatedAt:      coreTicket.CreatedAt,
-		UpdatedAt:      coreTicket.UpdatedAt,
-		PolicyID:       coreTicket.PolicyID,
-		BookedBy:       coreTicket.BookedBy,
-	}
-
-	details, err := r.q.GetTicketDetailsByTicketID(ctx, info.TicketID)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to get ticket details for ticket %s: %w", info.TicketID, err)
-	}
-	for _, det := range details {
-		t.Details = append(t.Details, db.TicketDetail{
-			DetailID:             det.DetailID,
-			TicketID:             det.TicketID,
-			PickupLocationBegin:  det.PickupLocationBegin,
-			DropoffLocationBegin: det.DropoffLocationBegin,
-			PickupLocationEnd:    det.PickupLocationEnd,
-			DropoffLocationEnd:   det.DropoffLocationEnd,
-			CreatedAt:            det.CreatedAt,
-			UpdatedAt:            det.UpdatedAt,
-		})
-	}
-
-	seatTicketRows, err := r.q.GetSeatTicketsByTicketID(ctx, info.TicketID)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to get seat tickets for ticket %s: %w", info.TicketID, err)
-	}
-	for _, stRow := range seatTicketRows {
-		if stRow.TripID == t.TripIDBegin {
-			t.SeatTicketsBegin = append(t.SeatTicketsBegin, db.GetSeatTicketsByTicketIDRow{ // Map to models.SeatTicket
-				ID:        stRow.ID,
-				SeatID:    stRow.SeatID,
-				TicketID:  stRow.TicketID,
-				Status:    stRow.Status,
-				CreatedAt: stRow.CreatedAt,
-				UpdatedAt: stRow.UpdatedAt,
-				SeatName:  stRow.SeatName,
-				TripID:    stRow.TripID,
-			})
-		} else {
-			t.SeatTicketsEnd = append(t.SeatTicketsEnd, db.GetSeatTicketsByTicketIDRow{ // Map to models.SeatTicket
-				ID:        stRow.ID,
-				SeatID:    stRow.SeatID,
-				TicketID:  stRow.TicketID,
-				Status:    stRow.Status,
-				CreatedAt: stRow.CreatedAt,
-				UpdatedAt: stRow.UpdatedAt,
-				SeatName:  stRow.SeatName,
-				TripID:    stRow.TripID,
-			})
-		}
-	}
-	return t, nil
+	return r.assembleTicket(ctx, coreTicket)
 }
 
 func (r *ticketRepositoryImpl) ticketIDExists(ctx context.Context, ticketID string) (bool, error) {
@@ -676,11 +790,60 @@ func (r *ticketRepositoryImpl) GenerateUniqueTicketID(ctx context.Context) (stri
 	return "", errors.New("failed to generate unique ticket_id after multiple attempts")
 }
 
-func (r *ticketRepositoryImpl) GetAvailableSeatsByTripID(ctx context.Context, tripID string) ([]models.SeatReturn, error) {
+// GenerateUniqueGroupRef sinh mã đơn đoàn dạng GRP-XXXXXX chưa được dùng.
+func (r *ticketRepositoryImpl) GenerateUniqueGroupRef(ctx context.Context) (string, error) {
+	const maxAttempts = 5
+	for i := 0; i < maxAttempts; i++ {
+		ref := models.GroupRefPrefix + r.utils.GenerateRandomID(6)
+		exists, err := r.q.GroupRefExists(ctx, ref)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return ref, nil
+		}
+	}
+	return "", errors.New("failed to generate unique group_ref after multiple attempts")
+}
+
+// GetTicketGroup trả về đơn đoàn và đầy đủ các vé của đoàn; (nil, nil, nil) nếu không tồn tại.
+func (r *ticketRepositoryImpl) GetTicketGroup(ctx context.Context, groupRef string) (*db.TicketGroup, []*models.TicketReturn, error) {
+	group, err := r.q.GetTicketGroup(ctx, groupRef)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get ticket group %s: %w", groupRef, err)
+	}
+	ticketIDs, err := r.q.ListTicketIDsByGroupRef(ctx, groupRef)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list tickets of group %s: %w", groupRef, err)
+	}
+	tickets, err := r.getTicketsByIDs(ctx, ticketIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &group, tickets, nil
+}
+
+// GetGroupRefByTicketID trả về mã đơn đoàn của vé, chuỗi rỗng nếu vé không thuộc đơn đoàn nào.
+func (r *ticketRepositoryImpl) GetGroupRefByTicketID(ctx context.Context, ticketID string) (string, error) {
+	groupRef, err := r.q.GetGroupRefByTicketID(ctx, ticketID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return groupRef, err
+}
+
+func (r *ticketRepositoryImpl) GetAvailableSeatsByTripID(ctx context.Context, tripID string, segment models.SeatSegment) ([]models.SeatReturn, error) {
 	// sqlc.ListAvailableSeatsByTripID returns []db.ListAvailableSeatsByTripIDRow
 	// db.ListAvailableSeatsByTripIDRow has ID, TripID, SeatName
 	// models.SeatReturn has ID, Name
-	dbSeats, err := r.q.ListAvailableSeatsByTripID(ctx, tripID)
+	dbSeats, err := r.q.ListAvailableSeatsByTripID(ctx, db.ListAvailableSeatsByTripIDParams{
+		TripID:   tripID,
+		ToStop:   segment.ToStop,
+		FromStop: segment.FromStop,
+	})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return []models.SeatReturn{}, nil
@@ -700,15 +863,15 @@ func (r *ticketRepositoryImpl) GetAvailableSeatsByTripID(ctx context.Context, tr
 
 // UpdateTicketPaymentStatus updates payment_status and general status of a ticket.
 // The sqlc.UpdateTicketPaymentStatus requires both.
-func (r *ticketRepositoryImpl) UpdateTicketPaymentStatus(ctx context.Context, ticketID string, paymentStatus int16, generalStatus int16, tripID string) error {
-	params := db.UpdateTicketPaymentStatusParams{
-		TicketID:      ticketID,
-		PaymentStatus: paymentStatus,
-		Status:        generalStatus, // The general status of the ticket
-	}
-	updatedTicket, err := r.q.UpdateTicketPaymentStatus(ctx, params)
+func (r *ticketRepositoryImpl) UpdateTicketPaymentStatus(ctx context.Context, ticketID string, paymentStatus int16, generalStatus int16, tripID string, change models.StatusChange) error {
+	var updatedTicket db.Ticket
+	err := r.inTransaction(ctx, func(qtx *db.Queries) error {
+		var err error
+		updatedTicket, err = transitionTicket(ctx, qtx, ticketID, generalStatus, paymentStatus, change)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update ticket payment status: %w", err)
+		return err
 	}
 
 	// If payment is successful, update cache with the full updated ticket from DB.
@@ -721,16 +884,10 @@ func (r *ticketRepositoryImpl) UpdateTicketPaymentStatus(ctx context.Context, ti
 	return nil
 }
 
-func (r *ticketRepositoryImpl) UpdateSeatTicketsStatus(ctx context.Context, ticketID string, status int16) error {
-	params := db.UpdateSeatTicketStatusByTicketIDParams{
-		TicketID: ticketID,
-		Status:   status,
-	}
-	_, err := r.q.UpdateSeatTicketStatusByTicketID(ctx, params)
-	if err != nil {
-		return fmt.Errorf("failed to update seat tickets status: %w", err)
-	}
-	return nil
+func (r *ticketRepositoryImpl) UpdateSeatTicketsStatus(ctx context.Context, ticketID string, status int16, change models.StatusChange) error {
+	return r.inTransaction(ctx, func(qtx *db.Queries) error {
+		return transitionSeatTickets(ctx, qtx, ticketID, status, change)
+	})
 }
 
 func (r *ticketRepositoryImpl) SetupExpirationHandler(ctx context.Context) error {
@@ -786,14 +943,15 @@ func (r *ticketRepositoryImpl) SetupExpirationHandler(ctx context.Context) error
 
 				// Only cancel if it's in a state that should be cancelled on expiration (e.g., pending payment)
 				if currentSeatTicketStatus == int16(models.SeatStatusPendingPayment) { // Assuming 0 is pending
-					err = r.UpdateSeatTicketsStatus(expCtx, seatTicket.TicketID, int16(models.SeatStatusCancelled))
+					expired := models.StatusChange{Actor: "system", Reason: fmt.Sprintf("seat hold of seat %d expired", seatID)}
+					err = r.UpdateSeatTicketsStatus(expCtx, seatTicket.TicketID, int16(models.SeatStatusCancelled), expired)
 					if err != nil {
 						r.logger.Error("Failed to update seat status to cancelled for ticket %s: %v", seatTicket.TicketID, err)
 						cancel()
 						continue
 					}
 
-					err = r.UpdateTicketStatus(expCtx, seatTicket.TicketID, int16(models.TicketStatusCancelled))
+					err = r.UpdateTicketStatus(expCtx, seatTicket.TicketID, int16(models.TicketStatusCancelled), expired)
 					if err != nil {
 						r.logger.Error("Failed to update ticket status to cancelled for ticket %s: %v", seatTicket.TicketID, err)
 						cancel()
@@ -828,12 +986,13 @@ func (r *ticketRepositoryImpl) SetupExpirationHandler(ctx context.Context) error
 	return nil
 }
 
-func (r *ticketRepositoryImpl) UpdateTicketStatus(ctx context.Context, ticketID string, status int16) error {
-	params := db.UpdateTicketStatusParams{
-		TicketID: ticketID,
-		Status:   status,
-	}
-	updatedTicket, err := r.q.UpdateTicketStatus(ctx, params)
+func (r *ticketRepositoryImpl) UpdateTicketStatus(ctx context.Context, ticketID string, status int16, change models.StatusChange) error {
+	var updatedTicket db.Ticket
+	err := r.inTransaction(ctx, func(qtx *db.Queries) error {
+		var err error
+		updatedTicket, err = transitionTicketStatus(ctx, qtx, ticketID, status, change)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to update ticket status: %w", err)
 	}
@@ -880,51 +1039,56 @@ func (r *ticketRepositoryImpl) CleanupTicketCache(ctx context.Context, ticketID
 	return nil
 }
 
-func (r *ticketRepositoryImpl) CacheAvailableSeats(ctx context.Context, tripID string, seats []models.SeatReturn) error {
-	pipe := r.redis.Pipeline()
-	availableSeatsKey := fmt.Sprintf("available_seats:%s", tripID)
+func (r *ticketRepositoryImpl) CacheAvailableSeats(ctx context.Context, tripID string, segment models.SeatSegment, seats []models.SeatReturn) error {
+	seatsKey := availableSeatsKey(tripID)
 	seatInfoKey := fmt.Sprintf("seat_info:%s", tripID)
 
-	pipe.Del(ctx, availableSeatsKey)
-	pipe.Del(ctx, seatInfoKey) // Also delete seat info key
-
-	if len(seats) > 0 {
-		seatInfoMap := make(map[string]interface{})
-		for _, seat := range seats {
-			pipe.SAdd(ctx, availableSeatsKey, seat.ID) // seat.ID is int, SAdd handles it
-			seatInfoMap[fmt.Sprintf("%d", seat.ID)] = seat.Name
-		}
-		if len(seatInfoMap) > 0 {
-			pipe.HMSet(ctx, seatInfoKey, seatInfoMap)
-		}
+	seatIDs := make([]int, 0, len(seats))
+	seatInfoMap := make(map[string]interface{}, len(seats))
+	for _, seat := range seats {
+		seatIDs = append(seatIDs, seat.ID)
+		seatInfoMap[fmt.Sprintf("%d", seat.ID)] = seat.Name
+	}
+	data, err := json.Marshal(seatIDs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal available seats of trip %s: %w", tripID, err)
 	}
 
-	pipe.Expire(ctx, availableSeatsKey, 30*time.Minute)
-	pipe.Expire(ctx, seatInfoKey, 30*time.Minute) // Expire seat info key as well
-	_, err := pipe.Exec(ctx)
+	pipe := r.redis.Pipeline()
+	pipe.HSet(ctx, seatsKey, segment.String(), data)
+	// Tên ghế không phụ thuộc đoạn đường nên dùng chung cho mọi đoạn của chuyến
+	if len(seatInfoMap) > 0 {
+		pipe.HMSet(ctx, seatInfoKey, seatInfoMap)
+	}
+	pipe.Expire(ctx, seatsKey, 30*time.Minute)
+	pipe.Expire(ctx, seatInfoKey, 30*time.Minute)
+	_, err = pipe.Exec(ctx)
 	return err
 }
 
-func (r *ticketRepositoryImpl) GetAvailableSeatsFromCache(ctx context.Context, tripID string) ([]models.SeatReturn, error) {
-	availableSeatsKey := fmt.Sprintf("available_seats:%s", tripID)
+func (r *ticketRepositoryImpl) GetAvailableSeatsFromCache(ctx context.Context, tripID string, segment models.SeatSegment) ([]models.SeatReturn, error) {
 	seatInfoKey := fmt.Sprintf("seat_info:%s", tripID)
 
-	exists, err := r.redis.Exists(ctx, availableSeatsKey, seatInfoKey).Result()
+	data, err := r.redis.HGet(ctx, availableSeatsKey(tripID), segment.String()).Result()
 	if err != nil {
-		return nil, fmt.Errorf("redis error checking cache keys for trip %s: %w", tripID, err)
-	}
-	if exists < 2 { // Both keys must exist
-		return nil, nil // Cache miss
+		if err == redis.Nil {
+			return nil, nil // Cache miss
+		}
+		return nil, fmt.Errorf("redis error reading available seats of trip %s: %w", tripID, err)
 	}
 
-	seatIDStrings, err := r.redis.SMembers(ctx, availableSeatsKey).Result()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get available seat IDs from cache for trip %s: %w", tripID, err)
+	var seatIDs []int
+	if err := json.Unmarshal([]byte(data), &seatIDs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal available seats from cache for trip %s: %w", tripID, err)
 	}
-	if len(seatIDStrings) == 0 {
+	if len(seatIDs) == 0 {
 		return []models.SeatReturn{}, nil
 	}
 
+	seatIDStrings := make([]string, len(seatIDs))
+	for i, seatID := range seatIDs {
+		seatIDStrings[i] = strconv.Itoa(seatID)
+	}
 	// Fetch seat names using HMGet
 	seatNamesResult, err := r.redis.HMGet(ctx, seatInfoKey, seatIDStrings...).Result()
 	if err != nil {
@@ -932,23 +1096,14 @@ func (r *ticketRepositoryImpl) GetAvailableSeatsFromCache(ctx context.Context, t
 	}
 
 	var result []models.SeatReturn
-	for i, seatIDStr := range seatIDStrings {
-		seatID, convErr := strconv.Atoi(seatIDStr)
-		if convErr != nil {
-			r.logger.Error("Failed to parse seat ID string '%s' from cache for trip %s: %v", seatIDStr, tripID, convErr)
-			continue
-		}
-		if seatNamesResult[i] != nil {
-			seatName, ok := seatNamesResult[i].(string)
-			if !ok {
-				r.logger.Error("Seat name for ID %d is not a string in cache for trip %s", seatID, tripID)
-				continue
-			}
-			result = append(result, models.SeatReturn{ID: seatID, Name: seatName})
-		} else {
-			// This case (seat ID in set but not in hash) should ideally not happen if cache is consistent
-			r.logger.Info("Seat ID %d found in available_seats set but not in seat_info hash for trip %s", seatID, tripID)
+	for i, seatID := range seatIDs {
+		seatName, ok := seatNamesResult[i].(string)
+		if !ok {
+			// Thiếu tên ghế (seat_info hết hạn trước) thì coi như cache miss để đọc lại từ DB
+			r.logger.Info("Seat ID %d found in available seats cache but not in seat_info hash for trip %s", seatID, tripID)
+			return nil, nil
 		}
+		result = append(result, models.SeatReturn{ID: seatID, Name: seatName})
 	}
 	return result, nil
 }
@@ -1005,53 +1160,28 @@ func (r *ticketRepositoryImpl) SubscribeToSeatStatusChanges(ctx context.Context)
 }
 
 func (r *ticketRepositoryImpl) handleSeatReserved(ctx context.Context, msg *models.SeatStatusMessage) {
-	availableSeatsKey := fmt.Sprintf("available_seats:%s", msg.TripID)
-	// Seat info key is not directly modified here, only the set of available IDs
-	pipe := r.redis.Pipeline()
-	pipe.SRem(ctx, availableSeatsKey, msg.SeatID)
-	_, err := pipe.Exec(ctx)
+	// Ghế trống được cache theo đoạn đường nên xoá cache của cả chuyến
+	err := r.redis.Del(ctx, availableSeatsKey(msg.TripID)).Err()
 	if err != nil {
 		r.logger.Error("Failed to update cache for seat reservation (trip %s, seat %d): %v", msg.TripID, msg.SeatID, err)
 	} else {
-		r.logger.Info("Seat %d reserved for trip %s, removed from available_seats cache.", msg.SeatID, msg.TripID)
+		r.logger.Info("Seat %d reserved for trip %s, invalidated available seats cache.", msg.SeatID, msg.TripID)
 	}
 }
 
 func (r *ticketRepositoryImpl) handleSeatReleased(ctx context.Context, msg *models.SeatStatusMessage) {
-	availableSeatsKey := fmt.Sprintf("available_seats:%s", msg.TripID)
-	seatInfoKey := fmt.Sprintf("seat_info:%s", msg.TripID)
-
-	// Fetch seat name from DB to ensure consistency if it was removed or info is stale
-	var seatName string
-	// Use sqlc GetSeatByID
-	dbSeat, err := r.q.GetSeatByID(ctx, int32(msg.SeatID))
-	if err != nil {
-		r.logger.Error("Failed to get seat details for seat %d (trip %s) during release: %v", msg.SeatID, msg.TripID, err)
-		// If we can't get seat details, it's risky to add to cache.
-		// Consider invalidating/deleting cache for the trip.
-		r.redis.Del(ctx, availableSeatsKey, seatInfoKey)
-		return
-	}
-	seatName = dbSeat.SeatName.String
-
-	pipe := r.redis.Pipeline()
-	pipe.SAdd(ctx, availableSeatsKey, msg.SeatID)
-	pipe.HSet(ctx, seatInfoKey, fmt.Sprintf("%d", msg.SeatID), seatName)
-	_, err = pipe.Exec(ctx)
-
+	// Also delete any temporary holds on "seat:<id>"
+	seatKey := fmt.Sprintf("seat:%d", msg.SeatID)
+	err := r.redis.Del(ctx, availableSeatsKey(msg.TripID), seatKey).Err()
 	if err != nil {
 		r.logger.Error("Failed to update cache for seat release (trip %s, seat %d): %v", msg.TripID, msg.SeatID, err)
 	} else {
-		r.logger.Info("Seat %d released for trip %s, added back to available_seats and seat_info cache.", msg.SeatID, msg.TripID)
+		r.logger.Info("Seat %d released for trip %s, invalidated available seats cache.", msg.SeatID, msg.TripID)
 	}
-
-	// Also delete any temporary holds on "seat:<id>"
-	seatKey := fmt.Sprintf("seat:%d", msg.SeatID)
-	r.redis.Del(ctx, seatKey)
 }
 
 func (r *ticketRepositoryImpl) handlePaymentCompleted(ctx context.Context, msg *models.SeatStatusMessage) {
-	// Seat was already removed from available_seats when reserved.
+	// Available seats cache was already invalidated when the seat was reserved.
 	// Just clean up any "seat:<id>" temporary hold key.
 	seatKey := fmt.Sprintf("seat:%d", msg.SeatID)
 	r.redis.Del(ctx, seatKey)
@@ -1089,81 +1219,6 @@ func (r *ticketRepositoryImpl) GetAllTickets(ctx context.Context, limit int, off
 		return []*models.TicketReturn{}, nil
 	}
 
-	// 2. Collect ticket IDs
-	ticketIDs := make([]string, len(coreTickets))
-	ticketsMap := make(map[string]*models.TicketReturn, len(coreTickets))
-	ticketsReturn := make([]*models.TicketReturn, len(coreTickets))
-
-	for i, coreTicket := range coreTickets {
-		t := &models.TicketReturn{
-			TicketID:       coreTicket.TicketID,
-			TripIDBegin:    coreTicket.TripIDBegin,
-			TripIDEnd:      coreTicket.TripIDEnd,
-			Type:           coreTicket.Type,
-			CustomerID:     coreTicket.CustomerID,
-			Phone:          coreTicket.Phone,
-			Email:          coreTicket.Email,
-			Name:           coreTicket.Name,
-			Price:          coreTicket.Price,
-			Status:         coreTicket.Status,
-			BookingTime:    coreTicket.BookingTime,
-			PaymentStatus:  coreTicket.PaymentStatus,
-			BookingChannel: coreTicket.BookingChannel,
-			CreatedAt:      coreTicket.CreatedAt,
-			UpdatedAt:      coreTicket.UpdatedAt,
-			PolicyID:       coreTicket.PolicyID,
-			BookedBy:       coreTicket.BookedBy,
-		}
-		ticketIDs[i] = t.TicketID
-		ticketsMap[t.TicketID] = t
-		ticketsReturn[i] = t
-	}
-
-	// 3. Fetch all details and seat tickets for the collected ticket IDs in bulk
-	details, err := r.q.GetTicketDetailsByTicketIDs(ctx, ticketIDs)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to get ticket details for tickets: %w", err)
-	}
-
-	seatTicketRows, err := r.q.GetSeatTicketsByTicketIDs(ctx, ticketIDs)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to get seat tickets for tickets: %w", err)
-	}
-
-	// 4. Map the details back to their respective tickets
-	for _, det := range details {
-		if ticket, ok := ticketsMap[det.TicketID]; ok {
-			ticket.Details = append(ticket.Details, db.TicketDetail{
-				DetailID:             det.DetailID,
-				TicketID:             det.TicketID,
-				PickupLocationBegin:  det.PickupLocationBegin,
-				DropoffLocationBegin: det.DropoffLocationBegin,
-				PickupLocationEnd:    det.PickupLocationEnd,
-				DropoffLocationEnd:   det.DropoffLocationEnd,
-			})
-		}
-	}
-
-	// 5. Map the seat tickets back to their respective tickets
-	for _, stRow := range seatTicketRows {
-		if ticket, ok := ticketsMap[stRow.TicketID]; ok {
-			seatTicket := db.GetSeatTicketsByTicketIDRow{
-				ID:        stRow.ID,
-				SeatID:    stRow.SeatID,
-				TicketID:  stRow.TicketID,
-				Status:    stRow.Status,
-				TripID:    stRow.TripID,
-				CreatedAt: stRow.CreatedAt,
-				UpdatedAt: stRow.UpdatedAt,
-				SeatName:  stRow.SeatName,
-			}
-			if stRow.TripID == ticket.TripIDBegin {
-				ticket.SeatTicketsBegin = append(ticket.SeatTicketsBegin, seatTicket)
-			} else {
-				ticket.SeatTicketsEnd = append(ticket.SeatTicketsEnd, seatTicket)
-			}
-		}
-	}
-
-	return ticketsReturn, nil
+	// 2. Load seats and details for all of them in bulk
+	return r.assembleTickets(ctx, coreTickets)
 }
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+)
+
+// SearchTickets trả về một trang vé khớp bộ lọc, kèm ghế, chi tiết và trạng thái hoá đơn.
+// Ghế và chi tiết của cả trang được lấy bằng một truy vấn mỗi loại (attachTicketRelations).
+func (r *ticketRepositoryImpl) SearchTickets(ctx context.Context, params db.SearchTicketsParams) ([]*models.TicketReturn, error) {
+	rows, err := r.q.SearchTickets(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tickets: %w", err)
+	}
+
+	tickets := make([]*models.TicketReturn, len(rows))
+	for i, row := range rows {
+		ticket := newTicketReturn(db.Ticket{
+			TicketID:       row.TicketID,
+			TripIDBegin:    row.TripIDBegin,
+			TripIDEnd:      row.TripIDEnd,
+			Type:           row.Type,
+			CustomerID:     row.CustomerID,
+			Phone:          row.Phone,
+			Email:          row.Email,
+			Name:           row.Name,
+			Price:          row.Price,
+			Status:         row.Status,
+			BookingTime:    row.BookingTime,
+			PaymentStatus:  row.PaymentStatus,
+			BookingChannel: row.BookingChannel,
+			CreatedAt:      row.CreatedAt,
+			UpdatedAt:      row.UpdatedAt,
+			PolicyID:       row.PolicyID,
+			BookedBy:       row.BookedBy,
+			FareBreakdown:  row.FareBreakdown,
+		})
+		invoiceRef := row.GroupRef
+		if invoiceRef == "" {
+			invoiceRef = row.TicketID
+		}
+		ticket.Invoice = &models.TicketInvoice{
+			Ref:           invoiceRef,
+			GroupBooking:  row.GroupRef != "",
+			PaymentStatus: row.PaymentStatus,
+			Status:        models.PaymentStatusLabel(row.PaymentStatus),
+		}
+		tickets[i] = ticket
+	}
+
+	if err := r.attachTicketRelations(ctx, tickets); err != nil {
+		return nil, err
+	}
+	return tickets, nil
+}
+
+// CountSearchTickets đếm số vé khớp bộ lọc tìm kiếm.
+func (r *ticketRepositoryImpl) CountSearchTickets(ctx context.Context, params db.CountSearchTicketsParams) (int64, error) {
+	total, err := r.q.CountSearchTickets(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tickets: %w", err)
+	}
+	return total, nil
+}
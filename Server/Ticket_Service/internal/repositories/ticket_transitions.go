@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+	"ticket-service/pkg/utils"
+)
+
+// Các hàm dưới đây là điểm duy nhất ghi trạng thái của Ticket / seat_tickets.
+// Mỗi hàm chạy trong transaction của caller (qtx): khoá dòng (FOR UPDATE), kiểm tra state machine
+// (models.Validate*Transition), cập nhật và ghi một dòng Ticket_Logs cho mỗi trạng thái thực sự thay đổi.
+
+// transitionTicket chuyển trạng thái chung và trạng thái thanh toán của vé.
+func transitionTicket(ctx context.Context, qtx *db.Queries, ticketID string, status, paymentStatus int16, change models.StatusChange) (db.Ticket, error) {
+	current, err := qtx.GetTicketStatesForUpdate(ctx, ticketID)
+	if err != nil {
+		return db.Ticket{}, fmt.Errorf("failed to lock ticket %s: %w", ticketID, err)
+	}
+	if err := models.ValidateTicketTransition(current.Status, status); err != nil {
+		return db.Ticket{}, fmt.Errorf("ticket %s: %w", ticketID, err)
+	}
+	if err := models.ValidatePaymentTransition(current.PaymentStatus, paymentStatus); err != nil {
+		return db.Ticket{}, fmt.Errorf("ticket %s: %w", ticketID, err)
+	}
+
+	ticket, err := qtx.UpdateTicketPaymentStatus(ctx, db.UpdateTicketPaymentStatusParams{
+		TicketID:      ticketID,
+		PaymentStatus: paymentStatus,
+		Status:        status,
+	})
+	if err != nil {
+		return db.Ticket{}, fmt.Errorf("failed to update ticket payment status: %w", err)
+	}
+
+	if err := logStatusChange(ctx, qtx, ticketID, models.StatusEntityTicket, 0, current.Status, status, change); err != nil {
+		return db.Ticket{}, err
+	}
+	if err := logStatusChange(ctx, qtx, ticketID, models.StatusEntityPayment, 0, current.PaymentStatus, paymentStatus, change); err != nil {
+		return db.Ticket{}, err
+	}
+	return ticket, nil
+}
+
+// transitionTicketStatus chuyển trạng thái chung của vé, giữ nguyên trạng thái thanh toán.
+func transitionTicketStatus(ctx context.Context, qtx *db.Queries, ticketID string, status int16, change models.StatusChange) (db.Ticket, error) {
+	current, err := qtx.GetTicketStatesForUpdate(ctx, ticketID)
+	if err != nil {
+		return db.Ticket{}, fmt.Errorf("failed to lock ticket %s: %w", ticketID, err)
+	}
+	return transitionTicket(ctx, qtx, ticketID, status, current.PaymentStatus, change)
+}
+
+// transitionSeatTickets chuyển trạng thái toàn bộ ghế của vé. Ghế đã huỷ riêng (hành khách rời đoàn) được bỏ qua.
+func transitionSeatTickets(ctx context.Context, qtx *db.Queries, ticketID string, status int16, change models.StatusChange) error {
+	seatTickets, err := qtx.ListSeatTicketStatusesForUpdate(ctx, ticketID)
+	if err != nil {
+		return fmt.Errorf("failed to lock seat_tickets of ticket %s: %w", ticketID, err)
+	}
+	for _, seatTicket := range seatTickets {
+		if seatTicket.Status == models.SeatStatusCancelled {
+			continue
+		}
+		if err := models.ValidateSeatTransition(seatTicket.Status, status); err != nil {
+			return fmt.Errorf("ticket %s seat_ticket %d: %w", ticketID, seatTicket.ID, err)
+		}
+	}
+
+	if _, err := qtx.UpdateSeatTicketStatusByTicketID(ctx, db.UpdateSeatTicketStatusByTicketIDParams{
+		TicketID: ticketID,
+		Status:   status,
+	}); err != nil {
+		return fmt.Errorf("failed to update seat_tickets status: %w", err)
+	}
+
+	for _, seatTicket := range seatTickets {
+		if seatTicket.Status == models.SeatStatusCancelled {
+			continue
+		}
+		if err := logStatusChange(ctx, qtx, ticketID, models.StatusEntitySeat, seatTicket.ID, seatTicket.Status, status, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transitionSeatTicket chuyển trạng thái một ghế của vé. Trả về sql.ErrNoRows nếu ghế không thuộc vé.
+func transitionSeatTicket(ctx context.Context, qtx *db.Queries, ticketID string, seatTicketID int32, status int16, change models.StatusChange) (db.SeatTicket, error) {
+	current, err := qtx.GetSeatTicketStatusForUpdate(ctx, db.GetSeatTicketStatusForUpdateParams{ID: seatTicketID, TicketID: ticketID})
+	if err != nil {
+		return db.SeatTicket{}, fmt.Errorf("failed to lock seat_ticket %d: %w", seatTicketID, err)
+	}
+	if err := models.ValidateSeatTransition(current, status); err != nil {
+		return db.SeatTicket{}, fmt.Errorf("ticket %s seat_ticket %d: %w", ticketID, seatTicketID, err)
+	}
+
+	seatTicket, err := qtx.UpdateSeatTicketStatus(ctx, db.UpdateSeatTicketStatusParams{ID: seatTicketID, Status: status})
+	if err != nil {
+		return db.SeatTicket{}, fmt.Errorf("failed to update seat_ticket %d status: %w", seatTicketID, err)
+	}
+	if err := logStatusChange(ctx, qtx, ticketID, models.StatusEntitySeat, seatTicketID, current, status, change); err != nil {
+		return db.SeatTicket{}, err
+	}
+	return seatTicket, nil
+}
+
+// maxLogReasonLength là độ dài cột Ticket_Logs.Reason.
+const maxLogReasonLength = 255
+
+// logStatusChange ghi một dòng Ticket_Logs cho lần chuyển trạng thái; không ghi gì nếu trạng thái không đổi.
+// seatTicketID = 0 với trạng thái của vé / thanh toán.
+func logStatusChange(ctx context.Context, qtx *db.Queries, ticketID, entity string, seatTicketID int32, from, to int16, change models.StatusChange) error {
+	if from == to {
+		return nil
+	}
+	if err := qtx.CreateTicketStatusLog(ctx, db.CreateTicketStatusLogParams{
+		TicketID:     ticketID,
+		Action:       fmt.Sprintf("%s_STATUS %s -> %s", entity, models.StatusName(entity, from), models.StatusName(entity, to)),
+		Entity:       utils.ToNullString(entity),
+		SeatTicketID: sql.NullInt32{Int32: seatTicketID, Valid: seatTicketID != 0},
+		FromStatus:   sql.NullInt16{Int16: from, Valid: true},
+		ToStatus:     sql.NullInt16{Int16: to, Valid: true},
+		Actor:        utils.ToNullString(change.Actor),
+		Reason:       utils.ToNullString(truncate(change.Reason, maxLogReasonLength)),
+	}); err != nil {
+		return fmt.Errorf("failed to record %s status change of ticket %s: %w", entity, ticketID, err)
+	}
+	return nil
+}
+
+func truncate(s string, maxRunes int) string {
+	runes := []rune(s)
+	if len(runes) <= maxRunes {
+		return s
+	}
+	return string(runes[:maxRunes])
+}
+
+// inTransaction chạy fn trong một transaction, dùng cho các thao tác đổi trạng thái không đi kèm outbox event.
+func (r *ticketRepositoryImpl) inTransaction(ctx context.Context, fn func(qtx *db.Queries) error) error {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(r.q.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetTicketHistory trả về toàn bộ Ticket_Logs của vé theo thứ tự thời gian.
+func (r *ticketRepositoryImpl) GetTicketHistory(ctx context.Context, ticketID string) ([]db.TicketLog, error) {
+	logs, err := r.q.ListTicketLogs(ctx, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list logs of ticket %s: %w", ticketID, err)
+	}
+	return logs, nil
+}
+
+// LogTicketAction ghi một dòng Ticket_Logs không gắn với chuyển trạng thái (VD: chuyển trạng thái bị từ chối).
+func (r *ticketRepositoryImpl) LogTicketAction(ctx context.Context, ticketID string, action string) error {
+	if _, err := r.q.CreateTicketLog(ctx, db.CreateTicketLogParams{
+		TicketID: ticketID,
+		Action:   truncate(action, maxLogReasonLength),
+	}); err != nil {
+		return fmt.Errorf("failed to create ticket log: %w", err)
+	}
+	return nil
+}
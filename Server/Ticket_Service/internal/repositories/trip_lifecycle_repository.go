@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ticket-service/internal/db"
+)
+
+// ErrRebookingOfferUsed được trả về khi lượt đổi chuyến miễn phí đã được dùng (hoặc bị mở lại) giữa lúc đọc và lúc ghi.
+var ErrRebookingOfferUsed = errors.New("free rebooking offer is no longer available")
+
+// ListActiveTicketIDsByTrip trả về các vé chờ thanh toán / còn hiệu lực có một chiều trên chuyến.
+func (r *ticketRepositoryImpl) ListActiveTicketIDsByTrip(ctx context.Context, tripID string) ([]string, error) {
+	ticketIDs, err := r.q.ListActiveTicketIDsByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active tickets of trip %s: %w", tripID, err)
+	}
+	return ticketIDs, nil
+}
+
+// OpenRebookingOffer mở lượt đổi chuyến miễn phí cho vé và ghi outbox thông báo trong cùng transaction.
+// Trả về false (không ghi thông báo) nếu lượt đổi với cùng giờ khởi hành mới đã có, tức sự kiện bị gửi lại.
+func (r *ticketRepositoryImpl) OpenRebookingOffer(ctx context.Context, params db.UpsertTripRebookingOfferParams, notification *db.CreateOutboxEventParams) (bool, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := r.q.WithTx(tx)
+	affected, err := qtx.UpsertTripRebookingOffer(ctx, params)
+	if err != nil {
+		return false, fmt.Errorf("failed to open rebooking offer for ticket %s: %w", params.TicketID, err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	if _, err := qtx.CreateTicketLog(ctx, db.CreateTicketLogParams{
+		TicketID: params.TicketID,
+		Action: fmt.Sprintf("TRIP_RESCHEDULED: trip %s %s -> %s, free rebooking offered",
+			params.TripID, params.OldDeparture.Format("15:04 02/01/2006"), params.NewDeparture.Format("15:04 02/01/2006")),
+	}); err != nil {
+		return false, fmt.Errorf("failed to create ticket log: %w", err)
+	}
+	if notification != nil {
+		if err := qtx.CreateOutboxEvent(ctx, *notification); err != nil {
+			return false, fmt.Errorf("failed to create outbox event: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// GetOpenRebookingOffer trả về lượt đổi chuyến miễn phí chưa dùng của vé trên chuyến, nil nếu không có.
+func (r *ticketRepositoryImpl) GetOpenRebookingOffer(ctx context.Context, ticketID, tripID string) (*db.TripRebookingOffer, error) {
+	offer, err := r.q.GetOpenTripRebookingOffer(ctx, db.GetOpenTripRebookingOfferParams{TicketID: ticketID, TripID: tripID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get rebooking offer of ticket %s: %w", ticketID, err)
+	}
+	return &offer, nil
+}
+
+// CloseCancelledTrip đóng danh sách chờ của chuyến bị huỷ và xoá các ghế chưa từng được đặt, trong một transaction.
+// notify tạo outbox thông báo cho từng lượt chờ bị đóng. Trả về số lượt chờ bị đóng và số ghế bị xoá.
+func (r *ticketRepositoryImpl) CloseCancelledTrip(ctx context.Context, tripID string, notify func(entry db.TripWaitlist) (db.CreateOutboxEventParams, error)) (int, int64, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := r.q.WithTx(tx)
+	entries, err := qtx.CloseTripWaitlist(ctx, tripID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to close waitlist of trip %s: %w", tripID, err)
+	}
+	for _, entry := range entries {
+		event, err := notify(entry)
+		if err != nil {
+			return 0, 0, err
+		}
+		if err := qtx.CreateOutboxEvent(ctx, event); err != nil {
+			return 0, 0, fmt.Errorf("failed to create outbox event: %w", err)
+		}
+	}
+	// Ghế đã có seat_tickets (kể cả vé đã huỷ) được giữ lại cho lịch sử vé
+	deleted, err := qtx.DeleteUnusedSeatsByTripID(ctx, tripID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to delete seats of trip %s: %w", tripID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(entries), deleted, nil
+}
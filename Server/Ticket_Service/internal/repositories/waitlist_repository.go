@@ -0,0 +1,203 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+	"ticket-service/pkg/utils"
+
+	"github.com/jackc/pgconn"
+)
+
+var (
+	ErrWaitlistEntryNotFound = errors.New("waitlist entry not found")
+	ErrAlreadyWaitlisted     = errors.New("customer is already on the waitlist of this trip")
+	ErrWaitlistEntryChanged  = errors.New("waitlist entry is no longer waiting")
+)
+
+// uniqueViolation là mã lỗi Postgres khi vi phạm unique index.
+const uniqueViolation = "23505"
+
+type WaitlistRepositoryInterface interface {
+	CreateEntry(ctx context.Context, params db.CreateWaitlistEntryParams) (*db.TripWaitlist, error)
+	ListByCustomer(ctx context.Context, customerID int32) ([]db.ListWaitlistEntriesByCustomerRow, []db.ListActiveWaitlistOffersByCustomerRow, error)
+	CancelEntry(ctx context.Context, entryID, customerID int32) (*db.TripWaitlist, int64, error)
+	ListWaitingEntries(ctx context.Context, tripID string, limit int32) ([]db.TripWaitlist, error)
+	ListAvailableSeats(ctx context.Context, tripID string, segment models.SeatSegment) ([]db.ListAvailableSeatsByTripIDRow, error)
+	OfferSeats(ctx context.Context, entryID int32, seatIDs []int32, holdSeconds int32, notification db.CreateOutboxEventParams) ([]db.WaitlistOffer, error)
+	ExpireOffers(ctx context.Context) ([]db.TripWaitlist, []string, error)
+}
+
+type WaitlistRepository struct {
+	sqlDB  *sql.DB
+	q      *db.Queries
+	logger utils.Logger
+}
+
+func NewWaitlistRepository(sqlDB *sql.DB, logger utils.Logger) WaitlistRepositoryInterface {
+	return &WaitlistRepository{
+		sqlDB:  sqlDB,
+		q:      db.New(sqlDB),
+		logger: logger,
+	}
+}
+
+func (r *WaitlistRepository) CreateEntry(ctx context.Context, params db.CreateWaitlistEntryParams) (*db.TripWaitlist, error) {
+	entry, err := r.q.CreateWaitlistEntry(ctx, params)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return nil, fmt.Errorf("trip %s: %w", params.TripID, ErrAlreadyWaitlisted)
+		}
+		r.logger.Error("Error adding customer %d to waitlist of trip %s: %v", params.CustomerID, params.TripID, err)
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// ListByCustomer trả về các lượt chờ của khách và các ghế đang được giữ cho khách.
+func (r *WaitlistRepository) ListByCustomer(ctx context.Context, customerID int32) ([]db.ListWaitlistEntriesByCustomerRow, []db.ListActiveWaitlistOffersByCustomerRow, error) {
+	entries, err := r.q.ListWaitlistEntriesByCustomer(ctx, customerID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list waitlist entries of customer %d: %w", customerID, err)
+	}
+	offers, err := r.q.ListActiveWaitlistOffersByCustomer(ctx, customerID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list waitlist offers of customer %d: %w", customerID, err)
+	}
+	return entries, offers, nil
+}
+
+// CancelEntry đưa khách ra khỏi danh sách chờ và trả lại các ghế đang giữ cho khách.
+// Trả về số ghế được trả lại để service mời người kế tiếp.
+func (r *WaitlistRepository) CancelEntry(ctx context.Context, entryID, customerID int32) (*db.TripWaitlist, int64, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := r.q.WithTx(tx)
+	entry, err := qtx.CancelWaitlistEntry(ctx, db.CancelWaitlistEntryParams{ID: entryID, CustomerID: customerID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, 0, fmt.Errorf("entry %d: %w", entryID, ErrWaitlistEntryNotFound)
+		}
+		return nil, 0, fmt.Errorf("failed to cancel waitlist entry %d: %w", entryID, err)
+	}
+	released, err := qtx.ReleaseWaitlistOffers(ctx, entryID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to release seats held for waitlist entry %d: %w", entryID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &entry, released, nil
+}
+
+func (r *WaitlistRepository) ListWaitingEntries(ctx context.Context, tripID string, limit int32) ([]db.TripWaitlist, error) {
+	return r.q.ListWaitingEntriesByTripID(ctx, db.ListWaitingEntriesByTripIDParams{TripID: tripID, Limit: limit})
+}
+
+// ListAvailableSeats đọc ghế trống trực tiếp từ DB (không qua cache), đã loại các ghế đang giữ cho danh sách chờ.
+func (r *WaitlistRepository) ListAvailableSeats(ctx context.Context, tripID string, segment models.SeatSegment) ([]db.ListAvailableSeatsByTripIDRow, error) {
+	return r.q.ListAvailableSeatsByTripID(ctx, db.ListAvailableSeatsByTripIDParams{
+		TripID:   tripID,
+		ToStop:   segment.ToStop,
+		FromStop: segment.FromStop,
+	})
+}
+
+// OfferSeats giữ các ghế cho lượt chờ trong holdSeconds và ghi outbox thông báo cho khách trong cùng transaction.
+// Trả về ErrWaitlistEntryChanged nếu lượt chờ đã không còn ở trạng thái chờ (khách rời đi hoặc đã được mời).
+func (r *WaitlistRepository) OfferSeats(ctx context.Context, entryID int32, seatIDs []int32, holdSeconds int32, notification db.CreateOutboxEventParams) ([]db.WaitlistOffer, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := r.q.WithTx(tx)
+	entry, err := qtx.GetWaitlistEntryForUpdate(ctx, entryID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("entry %d: %w", entryID, ErrWaitlistEntryNotFound)
+		}
+		return nil, fmt.Errorf("failed to lock waitlist entry %d: %w", entryID, err)
+	}
+	if entry.Status != models.WaitlistStatusWaiting {
+		return nil, fmt.Errorf("entry %d has status %d: %w", entryID, entry.Status, ErrWaitlistEntryChanged)
+	}
+
+	offers := make([]db.WaitlistOffer, 0, len(seatIDs))
+	for _, seatID := range seatIDs {
+		offer, err := qtx.CreateWaitlistOffer(ctx, db.CreateWaitlistOfferParams{
+			WaitlistID:  entry.ID,
+			TripID:      entry.TripID,
+			SeatID:      seatID,
+			CustomerID:  entry.CustomerID,
+			FromStop:    entry.FromStop,
+			ToStop:      entry.ToStop,
+			HoldSeconds: holdSeconds,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to hold seat %d for waitlist entry %d: %w", seatID, entryID, err)
+		}
+		offers = append(offers, offer)
+	}
+	if err := qtx.UpdateWaitlistEntryStatus(ctx, db.UpdateWaitlistEntryStatusParams{ID: entry.ID, Status: models.WaitlistStatusOffered}); err != nil {
+		return nil, fmt.Errorf("failed to update waitlist entry %d: %w", entryID, err)
+	}
+	if err := qtx.CreateOutboxEvent(ctx, notification); err != nil {
+		return nil, fmt.Errorf("failed to create outbox event: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return offers, nil
+}
+
+// ExpireOffers thu hồi các ghế giữ quá hạn, trả về các lượt chờ bị hết hạn và các chuyến có ghế được trả lại.
+func (r *WaitlistRepository) ExpireOffers(ctx context.Context) ([]db.TripWaitlist, []string, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := r.q.WithTx(tx)
+	expiredOffers, err := qtx.ExpireWaitlistOffers(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to expire waitlist offers: %w", err)
+	}
+	if len(expiredOffers) == 0 {
+		return nil, nil, nil
+	}
+
+	seenEntries := make(map[int32]bool)
+	seenTrips := make(map[string]bool)
+	var entryIDs []int32
+	var tripIDs []string
+	for _, offer := range expiredOffers {
+		if !seenEntries[offer.WaitlistID] {
+			seenEntries[offer.WaitlistID] = true
+			entryIDs = append(entryIDs, offer.WaitlistID)
+		}
+		if !seenTrips[offer.TripID] {
+			seenTrips[offer.TripID] = true
+			tripIDs = append(tripIDs, offer.TripID)
+		}
+	}
+	// Lượt chờ đã đặt một phần ghế giữ nguyên trạng thái đã đặt, chỉ lượt chưa đặt mới bị hết hạn
+	entries, err := qtx.ExpireWaitlistEntries(ctx, entryIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to expire waitlist entries: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return entries, tripIDs, nil
+}
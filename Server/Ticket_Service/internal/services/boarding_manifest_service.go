@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"ticket-service/domain/models"
+	"ticket-service/internal/repositories"
+	"ticket-service/pkg/utils"
+	"time"
+)
+
+// IBoardingManifestService cung cấp manifest lên xe cho điều độ viên và đánh dấu khách không đi sau khi xe chạy.
+type IBoardingManifestService interface {
+	GetBoardingManifest(ctx context.Context, tripID string) (*models.BoardingManifest, error)
+	MarkNoShowsForDepartedTrips(ctx context.Context, now time.Time) (int, error)
+}
+
+// tripSchedule là giờ chạy của chuyến đã lấy từ trip-service, giữ lại giữa các lần quét no-show.
+type tripSchedule struct {
+	departureAt time.Time
+	arrivalAt   time.Time // zero nếu trip-service không trả giờ đến
+}
+
+type BoardingManifestService struct {
+	checkinRepo repositories.CheckinRepositoryInterface
+	tripDetails func(ctx context.Context, tripID string) (*models.TripInfo, error)
+	noShowGrace time.Duration
+	logger      utils.Logger
+
+	mu        sync.Mutex
+	schedules map[string]tripSchedule
+}
+
+func NewBoardingManifestService(checkinRepo repositories.CheckinRepositoryInterface, tripDetails func(ctx context.Context, tripID string) (*models.TripInfo, error), noShowGrace time.Duration, logger utils.Logger) IBoardingManifestService {
+	return &BoardingManifestService{
+		checkinRepo: checkinRepo,
+		tripDetails: tripDetails,
+		noShowGrace: noShowGrace,
+		logger:      logger,
+		schedules:   make(map[string]tripSchedule),
+	}
+}
+
+// GetBoardingManifest trả về mọi ghế đã bán của chuyến kèm tên, số điện thoại, điểm đón/trả, thanh toán và check-in.
+func (s *BoardingManifestService) GetBoardingManifest(ctx context.Context, tripID string) (*models.BoardingManifest, error) {
+	rows, err := s.checkinRepo.GetBoardingManifest(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &models.BoardingManifest{
+		TripID:      tripID,
+		GeneratedAt: time.Now(),
+		Passengers:  make([]models.BoardingPassenger, 0, len(rows)),
+	}
+	// Thông tin chuyến chỉ để hiển thị, lỗi trip-service không chặn việc xuất manifest
+	if trip, err := s.tripDetails(ctx, tripID); err == nil && trip != nil {
+		if departureAt, err := trip.DepartureAt(); err == nil {
+			manifest.DepartureAt = &departureAt
+		}
+		if trip.Route != nil && trip.Route.Origin != "" {
+			manifest.Route = trip.Route.Origin + " - " + trip.Route.Destination
+		}
+	} else {
+		s.logger.Info("Warning: could not load trip %s for boarding manifest header: %v", tripID, err)
+	}
+
+	for _, row := range rows {
+		passenger := models.BoardingPassenger{
+			SeatName:       row.SeatName.String,
+			TicketID:       row.TicketID,
+			PassengerName:  row.Name.String,
+			Phone:          row.Phone.String,
+			PaymentStatus:  models.PaymentStatusLabel(row.PaymentStatus),
+			BoardingStatus: models.BoardingStatusOf(row.Status),
+		}
+		if row.FromStop > models.TripStartStop {
+			pickup := row.FromStop
+			passenger.PickupStop = &pickup
+		}
+		if row.ToStop < models.TripEndStop {
+			dropoff := row.ToStop
+			passenger.DropoffStop = &dropoff
+		}
+		if row.CheckedInAt.Valid {
+			checkedInAt := row.CheckedInAt.Time
+			passenger.CheckedInAt = &checkedInAt
+		}
+
+		switch passenger.BoardingStatus {
+		case models.BoardingStatusCheckedIn:
+			manifest.Summary.CheckedIn++
+		case models.BoardingStatusAwaiting:
+			manifest.Summary.Awaiting++
+		case models.BoardingStatusNoShow:
+			manifest.Summary.NoShow++
+		default:
+			manifest.Summary.PendingPayment++
+		}
+		manifest.Passengers = append(manifest.Passengers, passenger)
+	}
+	manifest.Summary.Total = len(manifest.Passengers)
+	return manifest, nil
+}
+
+// MarkNoShowsForDepartedTrips đánh dấu no-show các ghế đã xác nhận nhưng chưa check-in của những chuyến đã chạy:
+// khách lên ở điểm đầu sau giờ khởi hành + noShowGrace, khách lên dọc đường sau giờ đến của chuyến
+// (không biết giờ xe qua từng điểm dừng). Trả về số ghế đã đánh dấu.
+func (s *BoardingManifestService) MarkNoShowsForDepartedTrips(ctx context.Context, now time.Time) (int, error) {
+	tripIDs, err := s.checkinRepo.ListTripIDsWithConfirmedSeats(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	marked := 0
+	active := make(map[string]bool, len(tripIDs))
+	for _, tripID := range tripIDs {
+		active[tripID] = true
+		schedule, err := s.scheduleOf(ctx, tripID)
+		if err != nil {
+			s.logger.Error("No-show: could not resolve schedule of trip %s: %v", tripID, err)
+			continue
+		}
+
+		var maxFromStop int16
+		switch {
+		case !schedule.arrivalAt.IsZero() && now.After(schedule.arrivalAt):
+			maxFromStop = models.TripEndStop
+		case now.After(schedule.departureAt.Add(s.noShowGrace)):
+			maxFromStop = 1 // Điểm đầu tuyến (1) và vé cũ giữ cả chuyến (0)
+		default:
+			continue
+		}
+
+		seatTickets, err := s.checkinRepo.MarkNoShows(ctx, tripID, maxFromStop)
+		if err != nil {
+			s.logger.Error("No-show: failed to mark trip %s: %v", tripID, err)
+			continue
+		}
+		if len(seatTickets) > 0 {
+			s.logger.Info("No-show: marked %d seat(s) of trip %s boarding at or before stop %d", len(seatTickets), tripID, maxFromStop)
+		}
+		marked += len(seatTickets)
+	}
+
+	// Bỏ lịch của các chuyến không còn ghế chờ lên xe
+	s.mu.Lock()
+	for tripID := range s.schedules {
+		if !active[tripID] {
+			delete(s.schedules, tripID)
+		}
+	}
+	s.mu.Unlock()
+	return marked, nil
+}
+
+func (s *BoardingManifestService) scheduleOf(ctx context.Context, tripID string) (tripSchedule, error) {
+	s.mu.Lock()
+	schedule, ok := s.schedules[tripID]
+	s.mu.Unlock()
+	if ok {
+		return schedule, nil
+	}
+
+	trip, err := s.tripDetails(ctx, tripID)
+	if err != nil {
+		return tripSchedule{}, err
+	}
+	if trip == nil {
+		return tripSchedule{}, fmt.Errorf("trip %s: %w", tripID, ErrTripNotFound)
+	}
+	if schedule.departureAt, err = trip.DepartureAt(); err != nil {
+		return tripSchedule{}, err
+	}
+	if arrivalAt, err := trip.ArrivalAt(); err == nil {
+		schedule.arrivalAt = arrivalAt
+	}
+
+	s.mu.Lock()
+	s.schedules[tripID] = schedule
+	s.mu.Unlock()
+	return schedule, nil
+}
@@ -2,33 +2,67 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"ticket-service/domain/models"
 	"ticket-service/internal/repositories"
 	"ticket-service/pkg/utils"
+	"time"
 )
 
 type ICheckinService interface {
-	ProcessCheckin(ctx context.Context, ticketID string, seatTicketID int32, tripID string, note string) (*models.CheckinResponse, error)
+	ProcessCheckin(ctx context.Context, qrContent string, tripID string, note string) (*models.CheckinResponse, error)
 	// Mới: Lấy danh sách check-in cho một chuyến đi
 	GetCheckinsForTrip(ctx context.Context, tripID string) ([]models.CheckinResponse, error)
+	// Soát vé offline: tải manifest trước chuyến, đồng bộ các lượt quét khi có sóng trở lại
+	GetTripManifest(ctx context.Context, tripID string) (*models.SignedTripManifest, error)
+	SyncOfflineCheckins(ctx context.Context, req *models.OfflineCheckinBatchRequest, note string) (*models.OfflineCheckinBatchResponse, error)
 }
 
+// maxScanClockSkew là độ lệch đồng hồ tối đa cho phép giữa thiết bị soát vé và server.
+const maxScanClockSkew = 5 * time.Minute
+
 type CheckinService struct {
 	checkinRepo repositories.CheckinRepositoryInterface
+	qrService   ITicketQRService
 	logger      utils.Logger
 	// You might need ticketRepository if you need more ticket details or validation not covered by checkinRepo
 }
 
-func NewCheckinService(checkinRepo repositories.CheckinRepositoryInterface, logger utils.Logger) ICheckinService {
+func NewCheckinService(checkinRepo repositories.CheckinRepositoryInterface, qrService ITicketQRService, logger utils.Logger) ICheckinService {
 	return &CheckinService{
 		checkinRepo: checkinRepo,
+		qrService:   qrService,
 		logger:      logger,
 	}
 }
 
-// Sửa đổi: Logic xử lý check-in
-func (s *CheckinService) ProcessCheckin(ctx context.Context, ticketID string, seatTicketID int32, tripID string, note string) (*models.CheckinResponse, error) {
+// ProcessCheckin xác thực nội dung QR quét được (chữ ký, hạn, chưa bị quét lại) rồi check-in ghế ghi trong QR.
+func (s *CheckinService) ProcessCheckin(ctx context.Context, qrContent string, tripID string, note string) (*models.CheckinResponse, error) {
+	claims, err := s.qrService.Verify(qrContent)
+	if err != nil {
+		return nil, err
+	}
+	// QR đã ký mang theo chuyến; QR cũ chưa ký thì so với DB ở bước dưới
+	if claims.TripID != "" && claims.TripID != tripID {
+		s.logger.Info("TripID mismatch in QR of ticket %s. Expected %s, got %s", claims.TicketID, claims.TripID, tripID)
+		return nil, fmt.Errorf("check-in failed: this ticket is not for this trip")
+	}
+	if err := s.qrService.MarkUsed(ctx, claims); err != nil {
+		return nil, err
+	}
+
+	response, err := s.checkinSeat(ctx, claims.TicketID, claims.SeatID, tripID, note)
+	if err != nil {
+		s.qrService.ReleaseUsed(ctx, claims)
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *CheckinService) checkinSeat(ctx context.Context, ticketID string, seatTicketID int32, tripID string, note string) (*models.CheckinResponse, error) {
 	s.logger.Info("Processing check-in for SeatTicketID: %d, TicketID: %s, TripID: %s", seatTicketID, ticketID, tripID)
 
 	// Lấy thông tin chi tiết của vé ghế bằng ID của nó
@@ -48,8 +82,8 @@ func (s *CheckinService) ProcessCheckin(ctx context.Context, ticketID string, se
 		return nil, fmt.Errorf("check-in failed: this ticket is not for this trip")
 	}
 
-	// Xác thực trạng thái vé
-	if seatTicketDetails.Status != models.SeatStatusConfirmed {
+	// Xác thực trạng thái vé; ghế đã bị đánh dấu no-show vẫn check-in được khi khách lên xe muộn
+	if seatTicketDetails.Status != models.SeatStatusConfirmed && seatTicketDetails.Status != models.SeatStatusMissed {
 		s.logger.Info("SeatTicket %d is not in a checkable state. Current status: %d", seatTicketID, seatTicketDetails.Status)
 		var statusMsg string
 		switch seatTicketDetails.Status {
@@ -118,3 +152,122 @@ func (s *CheckinService) GetCheckinsForTrip(ctx context.Context, tripID string)
 
 	return response, nil
 }
+
+// GetTripManifest chụp danh sách ghế đã xác nhận của chuyến và ký lại để thiết bị soát vé dùng khi mất sóng.
+func (s *CheckinService) GetTripManifest(ctx context.Context, tripID string) (*models.SignedTripManifest, error) {
+	rows, err := s.checkinRepo.GetTripManifest(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := models.TripManifest{
+		TripID:      tripID,
+		GeneratedAt: time.Now().UTC().Truncate(time.Second),
+		Entries:     make([]models.ManifestEntry, 0, len(rows)),
+	}
+	for _, row := range rows {
+		phoneSuffix := row.Phone.String
+		if len(phoneSuffix) > 3 {
+			phoneSuffix = phoneSuffix[len(phoneSuffix)-3:]
+		}
+		manifest.Entries = append(manifest.Entries, models.ManifestEntry{
+			SeatTicketID:  row.ID,
+			SeatID:        row.SeatID,
+			SeatName:      row.SeatName.String,
+			TicketID:      row.TicketID,
+			PassengerName: row.Name.String,
+			PhoneSuffix:   phoneSuffix,
+			Segment:       models.SeatSegment{FromStop: row.FromStop, ToStop: row.ToStop},
+			CheckedIn:     row.Status == models.SeatStatusCheckedIn,
+		})
+	}
+
+	document, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	keyID, signature := s.qrService.SignDocument(document)
+	s.logger.Info("Generated offline manifest for trip %s with %d seats", tripID, len(manifest.Entries))
+	return &models.SignedTripManifest{Manifest: manifest, KeyID: keyID, Signature: signature}, nil
+}
+
+// SyncOfflineCheckins áp dụng lần lượt các lượt quét offline theo thời điểm quét gốc, lượt quét sớm nhất thắng.
+// Mỗi lượt quét có kết quả riêng; lỗi của một lượt không làm hỏng cả lô.
+func (s *CheckinService) SyncOfflineCheckins(ctx context.Context, req *models.OfflineCheckinBatchRequest, note string) (*models.OfflineCheckinBatchResponse, error) {
+	scans := append([]models.OfflineScan(nil), req.Scans...)
+	sort.SliceStable(scans, func(i, j int) bool { return scans[i].ScannedAt.Before(scans[j].ScannedAt) })
+
+	response := &models.OfflineCheckinBatchResponse{TripID: req.TripID, Results: make([]models.OfflineScanResult, 0, len(scans))}
+	now := time.Now()
+	for _, scan := range scans {
+		result := s.applyOfflineScan(ctx, req, scan, now, note)
+		switch result.Outcome {
+		case models.OfflineScanAccepted:
+			response.Accepted++
+		case models.OfflineScanDuplicate:
+			response.Duplicates++
+		case models.OfflineScanConflict:
+			response.Conflicts++
+		case models.OfflineScanRejected:
+			response.Rejected++
+		case models.OfflineScanRetry:
+			response.Retry++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	s.logger.Info("Synced %d offline scans for trip %s from device %s: %d accepted, %d duplicate, %d conflict, %d rejected, %d retry",
+		len(scans), req.TripID, req.DeviceID, response.Accepted, response.Duplicates, response.Conflicts, response.Rejected, response.Retry)
+	return response, nil
+}
+
+func (s *CheckinService) applyOfflineScan(ctx context.Context, req *models.OfflineCheckinBatchRequest, scan models.OfflineScan, now time.Time, note string) models.OfflineScanResult {
+	result := models.OfflineScanResult{ClientScanID: scan.ClientScanID}
+	if scan.ScannedAt.After(now.Add(maxScanClockSkew)) {
+		result.Outcome, result.Reason = models.OfflineScanRejected, models.OfflineReasonScanInFuture
+		return result
+	}
+
+	claims, err := s.qrService.VerifyAt(scan.QRContent, scan.ScannedAt)
+	if err != nil {
+		result.Outcome, result.Reason = models.OfflineScanRejected, models.OfflineReasonInvalidQR
+		if errors.Is(err, ErrQRCodeExpired) {
+			result.Reason = models.OfflineReasonQRExpired
+		}
+		return result
+	}
+	result.TicketID = claims.TicketID
+	if claims.TripID != "" && claims.TripID != req.TripID {
+		result.Outcome, result.Reason = models.OfflineScanRejected, models.OfflineReasonWrongTrip
+		return result
+	}
+
+	outcome, err := s.checkinRepo.ApplyOfflineCheckin(ctx, repositories.OfflineCheckinParams{
+		DeviceID:     req.DeviceID,
+		ClientScanID: scan.ClientScanID,
+		TicketID:     claims.TicketID,
+		SeatID:       claims.SeatID,
+		TripID:       req.TripID,
+		ScannedAt:    scan.ScannedAt,
+		SnapshotAt:   req.ManifestGeneratedAt,
+		Note:         note,
+	})
+	if err != nil {
+		s.logger.Error("Failed to apply offline scan %s of ticket %s from device %s: %v", scan.ClientScanID, claims.TicketID, req.DeviceID, err)
+		result.Outcome, result.Reason = models.OfflineScanRetry, models.OfflineReasonInternalError
+		return result
+	}
+
+	result.Outcome, result.Reason = outcome.Outcome, outcome.Reason
+	if outcome.Checkin != nil {
+		result.SeatName = outcome.Checkin.SeatName.String
+		if outcome.Checkin.CheckedInAt.Valid {
+			checkedInAt := outcome.Checkin.CheckedInAt.Time
+			result.CheckedInAt = &checkedInAt
+		}
+	}
+	if outcome.Outcome == models.OfflineScanConflict {
+		s.logger.Info("Warning: offline scan %s of ticket %s seat %d on trip %s needs review: %s", scan.ClientScanID, claims.TicketID, claims.SeatID, req.TripID, outcome.Reason)
+	}
+	return result
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"ticket-service/domain/models"
+	"ticket-service/internal/repositories"
+	"ticket-service/pkg/utils"
+	"time"
+)
+
+var (
+	ErrTripNotFound    = errors.New("trip not found")
+	ErrFareUnavailable = errors.New("fare is not available for this trip")
+	ErrPriceMismatch   = errors.New("client price does not match the computed fare")
+	ErrTripDeparted    = errors.New("trip has already departed")
+)
+
+// defaultRefundPolicyID là policy chứa refund rule mặc định (seed trong migration 0003).
+const defaultRefundPolicyID int32 = 0
+
+// TripFetcher lấy thông tin chuyến từ trip-service (VD tripclient.Client.GetTrip), dừng khi ctx bị huỷ.
+type TripFetcher func(ctx context.Context, tripID string) (*models.TripInfo, error)
+
+type IFareService interface {
+	// QuoteTicket tính giá vé phía server từ chuyến, số ghế, loại vé, policy và ngày đặc biệt.
+	QuoteTicket(ctx context.Context, input *models.TicketInput) (*models.FareBreakdown, error)
+	// QuoteRefund tính số tiền hoàn lại khi huỷ vé theo refund rule của policy và thời gian còn lại trước giờ khởi hành.
+	QuoteRefund(ctx context.Context, ticket *models.TicketReturn) (*models.RefundQuote, error)
+	// RepriceLeg tính lại giá khi một chiều của vé đổi sang chuyến/số ghế khác, giữ nguyên các mức giảm đã áp dụng lúc đặt.
+	// Trả về giá cũ và giá mới.
+	RepriceLeg(ctx context.Context, ticket *models.TicketReturn, leg string, newTripID string, seatCount int) (*models.FareBreakdown, *models.FareBreakdown, error)
+	// ResizeLeg tính lại giá khi số ghế của một chiều thay đổi (huỷ hành khách của đoàn), giữ nguyên đơn giá lúc đặt.
+	// Trả về giá cũ và giá mới.
+	ResizeLeg(ctx context.Context, ticket *models.TicketReturn, leg string, seatCount int) (*models.FareBreakdown, *models.FareBreakdown, error)
+}
+
+type FareService struct {
+	policyRepository repositories.PolicyRepositoryInterface
+	fetchTrip        TripFetcher
+	logger           utils.Logger
+}
+
+func NewFareService(policyRepository repositories.PolicyRepositoryInterface, fetchTrip TripFetcher, logger utils.Logger) IFareService {
+	return &FareService{
+		policyRepository: policyRepository,
+		fetchTrip:        fetchTrip,
+		logger:           logger,
+	}
+}
+
+func (f *FareService) QuoteTicket(ctx context.Context, input *models.TicketInput) (*models.FareBreakdown, error) {
+	if len(input.SeatIDBegin) == 0 {
+		return nil, errors.New("at least one seat must be selected")
+	}
+
+	breakdown := &models.FareBreakdown{
+		PolicyID:    input.PolicyID,
+		ClientPrice: input.Price,
+		Currency:    models.FareCurrencyVND,
+	}
+
+	leg, err := f.quoteLeg(ctx, input.TripIDBegin, len(input.SeatIDBegin))
+	if err != nil {
+		return nil, err
+	}
+	breakdown.Legs = append(breakdown.Legs, *leg)
+
+	if input.TicketType == 1 {
+		if len(input.SeatIDEnd) == 0 {
+			return nil, errors.New("at least one seat must be selected for return trip")
+		}
+		legEnd, err := f.quoteLeg(ctx, input.TripIDEnd, len(input.SeatIDEnd))
+		if err != nil {
+			return nil, err
+		}
+		breakdown.Legs = append(breakdown.Legs, *legEnd)
+	}
+
+	// Policy 0 nghĩa là không áp dụng chính sách giảm giá nào.
+	if input.PolicyID != 0 {
+		policy, err := f.policyRepository.GetActivePolicy(ctx, input.PolicyID)
+		if err != nil {
+			return nil, err
+		}
+		breakdown.PolicyName = policy.Name
+		breakdown.PolicyDiscountPercent = policy.DiscountPercent
+		if input.TicketType == 1 {
+			breakdown.RoundTripDiscountPercent = policy.RoundTripDiscountPercent
+		}
+	}
+
+	applyTotals(breakdown)
+	return breakdown, nil
+}
+
+func (f *FareService) RepriceLeg(ctx context.Context, ticket *models.TicketReturn, leg string, newTripID string, seatCount int) (*models.FareBreakdown, *models.FareBreakdown, error) {
+	oldFare, legIndex, err := f.currentFare(ctx, ticket, leg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newLeg, err := f.quoteLeg(ctx, newTripID, seatCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newFare := *oldFare
+	newFare.Legs = append([]models.FareLeg(nil), oldFare.Legs...)
+	newFare.Legs[legIndex] = *newLeg
+	newFare.Adjustments = append([]models.FareAdjustment(nil), oldFare.Adjustments...)
+	applyTotals(&newFare)
+	return oldFare, &newFare, nil
+}
+
+func (f *FareService) ResizeLeg(ctx context.Context, ticket *models.TicketReturn, leg string, seatCount int) (*models.FareBreakdown, *models.FareBreakdown, error) {
+	oldFare, legIndex, err := f.currentFare(ctx, ticket, leg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newFare := *oldFare
+	newFare.Legs = append([]models.FareLeg(nil), oldFare.Legs...)
+	newFare.Legs[legIndex].SeatCount = seatCount
+	newFare.Legs[legIndex].Subtotal = roundMoney(newFare.Legs[legIndex].UnitFare * float64(seatCount))
+	newFare.Adjustments = append([]models.FareAdjustment(nil), oldFare.Adjustments...)
+	applyTotals(&newFare)
+	return oldFare, &newFare, nil
+}
+
+// currentFare đọc bảng giá đã lưu trên vé và vị trí của chiều leg trong bảng giá.
+func (f *FareService) currentFare(ctx context.Context, ticket *models.TicketReturn, leg string) (*models.FareBreakdown, int, error) {
+	oldFare := &models.FareBreakdown{}
+	if len(ticket.FareBreakdown) > 0 {
+		if err := json.Unmarshal(ticket.FareBreakdown, oldFare); err != nil {
+			return nil, 0, fmt.Errorf("invalid fare breakdown on ticket %s: %w", ticket.TicketID, err)
+		}
+	}
+	// Vé cũ (trước khi lưu Fare_Breakdown) thì tính lại giá hiện tại làm mốc so sánh.
+	if len(oldFare.Legs) == 0 {
+		input := &models.TicketInput{
+			TicketType:  int(ticket.Type),
+			PolicyID:    ticket.PolicyID,
+			Price:       ticket.Price,
+			TripIDBegin: ticket.TripIDBegin,
+			TripIDEnd:   ticket.TripIDEnd.String,
+		}
+		for _, st := range ticket.SeatTicketsBegin {
+			input.SeatIDBegin = append(input.SeatIDBegin, st.SeatID)
+		}
+		for _, st := range ticket.SeatTicketsEnd {
+			input.SeatIDEnd = append(input.SeatIDEnd, st.SeatID)
+		}
+		quoted, err := f.QuoteTicket(ctx, input)
+		if err != nil {
+			return nil, 0, err
+		}
+		oldFare = quoted
+	}
+
+	legIndex := 0
+	if leg == models.TicketLegEnd {
+		legIndex = 1
+	}
+	if legIndex >= len(oldFare.Legs) {
+		return nil, 0, fmt.Errorf("ticket %s has no %s leg", ticket.TicketID, leg)
+	}
+	return oldFare, legIndex, nil
+}
+
+// applyTotals tính GrossAmount, các khoản giảm theo phần trăm đã lưu và Total.
+func applyTotals(b *models.FareBreakdown) {
+	b.GrossAmount = 0
+	for _, l := range b.Legs {
+		b.GrossAmount += l.Subtotal
+	}
+	b.PolicyDiscount = roundMoney(b.GrossAmount * b.PolicyDiscountPercent / 100)
+	b.RoundTripDiscount = roundMoney(b.GrossAmount * b.RoundTripDiscountPercent / 100)
+	b.Total = roundMoney(b.GrossAmount - b.PolicyDiscount - b.RoundTripDiscount)
+	if b.Total < 0 {
+		b.Total = 0
+	}
+}
+
+func (f *FareService) quoteLeg(ctx context.Context, tripID string, seatCount int) (*models.FareLeg, error) {
+	trip, err := f.fetchTrip(ctx, tripID)
+	if err != nil {
+		f.logger.Error("FareService: failed to fetch trip %s: %v", tripID, err)
+		return nil, fmt.Errorf("could not fetch trip %s for pricing: %w", tripID, err)
+	}
+	if trip == nil {
+		return nil, fmt.Errorf("trip %s: %w", tripID, ErrTripNotFound)
+	}
+	if trip.Route == nil || trip.Route.Price <= 0 {
+		return nil, fmt.Errorf("trip %s: %w", tripID, ErrFareUnavailable)
+	}
+
+	leg := &models.FareLeg{
+		TripID:    tripID,
+		SeatCount: seatCount,
+		BaseFare:  float64(trip.Route.Price),
+		UnitFare:  float64(trip.Route.Price),
+	}
+	if trip.Special != nil && trip.Special.Percent != 0 {
+		leg.SpecialDayName = trip.Special.Name
+		leg.SpecialDayPercent = trip.Special.Percent
+		leg.UnitFare = roundMoney(leg.BaseFare * (1 + float64(trip.Special.Percent)/100))
+	}
+	leg.Subtotal = roundMoney(leg.UnitFare * float64(seatCount))
+	return leg, nil
+}
+
+func (f *FareService) QuoteRefund(ctx context.Context, ticket *models.TicketReturn) (*models.RefundQuote, error) {
+	trip, err := f.fetchTrip(ctx, ticket.TripIDBegin)
+	if err != nil {
+		f.logger.Error("FareService: failed to fetch trip %s: %v", ticket.TripIDBegin, err)
+		return nil, fmt.Errorf("could not fetch trip %s for refund: %w", ticket.TripIDBegin, err)
+	}
+	if trip == nil {
+		return nil, fmt.Errorf("trip %s: %w", ticket.TripIDBegin, ErrTripNotFound)
+	}
+	departureAt, err := trip.DepartureAt()
+	if err != nil {
+		return nil, err
+	}
+
+	hoursLeft := time.Until(departureAt).Hours()
+	if hoursLeft <= 0 {
+		return nil, fmt.Errorf("trip %s departed at %s: %w", ticket.TripIDBegin, departureAt.Format(time.RFC3339), ErrTripDeparted)
+	}
+
+	rules, err := f.policyRepository.ListRefundRules(ctx, ticket.PolicyID)
+	if err != nil {
+		return nil, err
+	}
+	// Policy không khai báo refund rule thì dùng rule mặc định.
+	if len(rules) == 0 && ticket.PolicyID != defaultRefundPolicyID {
+		rules, err = f.policyRepository.ListRefundRules(ctx, defaultRefundPolicyID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	quote := &models.RefundQuote{
+		PolicyID:             ticket.PolicyID,
+		DepartureAt:          departureAt,
+		HoursBeforeDeparture: math.Floor(hoursLeft*100) / 100,
+	}
+	// rules đã sắp xếp theo min_hours giảm dần, rule đầu tiên thoả mãn là rule có lợi nhất.
+	for _, rule := range rules {
+		if hoursLeft >= float64(rule.MinHoursBeforeDeparture) {
+			quote.RuleMinHours = rule.MinHoursBeforeDeparture
+			quote.RefundPercent = rule.RefundPercent
+			break
+		}
+	}
+
+	// Chỉ hoàn tiền cho vé đã thanh toán.
+	if ticket.PaymentStatus == models.PaymentStatusPaid {
+		quote.PaidAmount = ticket.Price
+		quote.RefundAmount = roundMoney(ticket.Price * quote.RefundPercent / 100)
+	}
+	return quote, nil
+}
+
+// roundMoney làm tròn về 2 chữ số thập phân, khớp với DECIMAL(10,2) của cột Price.
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
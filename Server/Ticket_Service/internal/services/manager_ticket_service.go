@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"ticket-service/config"
 	"ticket-service/domain/models"
@@ -10,13 +12,14 @@ import (
 	"ticket-service/internal/repositories"
 	"ticket-service/pkg/emailclient"
 	"ticket-service/pkg/kafkaclient"
+	"ticket-service/pkg/tripclient"
 	"ticket-service/pkg/utils"
 
 	"github.com/google/uuid"
 )
 
 type IManagerTicketService interface {
-	GenerateSeats() []string
+	GenerateSeats(ctx context.Context, tripID string, vehicle *models.VehicleInfo) ([]db.CreateSeatFromLayoutParams, error)
 	CreateManagerTicketsForTrip(ctx context.Context, tripID string) ([]db.Seat, error)
 	UpdateStatusByTicketID(ctx context.Context, ticketID string, statusCode string) error
 	InitializeExpirationHandlers(ctx context.Context) error
@@ -25,56 +28,91 @@ type IManagerTicketService interface {
 type ManagerTicketService struct {
 	managerTicketRepository repositories.ManagerTicketInterface
 	ticketRepository        repositories.TicketRepositoryInterface
+	seatLayoutService       ISeatLayoutService
+	qrService               ITicketQRService
 	logger                  utils.Logger
 	cfg                     config.Config
 	publisher               *kafkaclient.Publisher // << UPDATED
 	KafkaEmailPublisher     *emailclient.EmailClient
+	tripClient              tripclient.Client
 }
 
 func NewManagerTicketService(
 	managerTicketRepository repositories.ManagerTicketInterface,
 	ticketRepository repositories.TicketRepositoryInterface,
+	seatLayoutService ISeatLayoutService,
+	qrService ITicketQRService,
 	logger utils.Logger,
 	cfg config.Config,
 	publisher *kafkaclient.Publisher, // << UPDATED,
 	emailclient *emailclient.EmailClient,
-
+	tripClient tripclient.Client,
 ) IManagerTicketService {
 	return &ManagerTicketService{
 		managerTicketRepository: managerTicketRepository,
 		ticketRepository:        ticketRepository,
+		seatLayoutService:       seatLayoutService,
+		qrService:               qrService,
 		logger:                  logger,
 		cfg:                     cfg,
 		publisher:               publisher, // << UPDATED
 		KafkaEmailPublisher:     emailclient,
+		tripClient:              tripClient,
 	}
 }
 
-func (m *ManagerTicketService) GenerateSeats() []string {
-	seats := []string{}
-	for i := 1; i <= 15; i++ {
-		seatNumber := fmt.Sprintf("%d", i) // Convert i to string
-		seats = append(seats, "A"+seatNumber)
-		seats = append(seats, "B"+seatNumber)
+// GenerateSeats sinh danh sách ghế của chuyến theo mẫu sơ đồ của loại xe (xem ISeatLayoutService.ResolveLayout).
+func (m *ManagerTicketService) GenerateSeats(ctx context.Context, tripID string, vehicle *models.VehicleInfo) ([]db.CreateSeatFromLayoutParams, error) {
+	layout, err := m.seatLayoutService.ResolveLayout(ctx, vehicle)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve seat layout for trip %s: %w", tripID, err)
 	}
-	return seats
+
+	seats := make([]db.CreateSeatFromLayoutParams, 0, len(layout.Seats))
+	for _, seat := range layout.Seats {
+		seats = append(seats, db.CreateSeatFromLayoutParams{
+			TripID:    tripID,
+			SeatName:  utils.ToNullString(seat.Name),
+			LayoutID:  sql.NullInt32{Int32: layout.ID, Valid: true},
+			Deck:      sql.NullInt16{Int16: seat.Deck, Valid: true},
+			RowNo:     sql.NullInt16{Int16: seat.Row, Valid: true},
+			ColNo:     sql.NullInt16{Int16: seat.Col, Valid: true},
+			SeatClass: utils.ToNullString(seat.Class),
+		})
+	}
+	return seats, nil
 }
 
+// CreateManagerTicketsForTrip tạo ghế cho chuyến mới theo loại xe của chuyến.
+// Nếu chuyến đã có ghế (message được xử lý lại) thì trả về ghế hiện có.
 func (s *ManagerTicketService) CreateManagerTicketsForTrip(ctx context.Context, tripID string) ([]db.Seat, error) {
-	seats := s.GenerateSeats() // giả sử trả về danh sách seatID như ["A1", "A2", ...]
-	var created []db.Seat
+	existing, err := s.managerTicketRepository.GetSeatsByTripID(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		s.logger.Info("Trip %s already has %d seats, skipping seat generation", tripID, len(existing))
+		return existing, nil
+	}
 
-	for _, seatID := range seats {
-		seat := db.Seat{
-			TripID:   tripID,
-			SeatName: utils.ToNullString(seatID),
-		}
-		err := s.managerTicketRepository.CreateManagerTicket(ctx, &seat)
-		if err != nil {
-			return nil, err
-		}
-		created = append(created, seat)
+	trip, err := s.tripClient.GetTrip(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	var vehicle *models.VehicleInfo
+	if trip != nil {
+		vehicle = trip.Vehicle
+	}
+
+	seats, err := s.GenerateSeats(ctx, tripID, vehicle)
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.managerTicketRepository.CreateSeatsForTrip(ctx, seats)
+	if err != nil {
+		return nil, err
 	}
+	s.logger.Info("Created %d seats for trip %s", len(created), tripID)
 	return created, nil
 }
 
@@ -168,38 +206,107 @@ func (s *ManagerTicketService) InitializeExpirationHandlers(ctx context.Context)
 func (s *ManagerTicketService) UpdateStatusByTicketID(ctx context.Context, ticketID string, statusCode string) error {
 	s.logger.Info("Processing status update for ticket %s with status code %s", ticketID, statusCode)
 
+	if models.IsGroupRef(ticketID) {
+		return s.updateGroupStatus(ctx, ticketID, statusCode)
+	}
+
 	ticket, err := s.ticketRepository.GetTicketByID(ctx, ticketID)
 	if err != nil || ticket == nil {
 		s.logger.Error("Failed to get ticket info for ticket %s: %v", ticketID, err)
 		return fmt.Errorf("ticket %s not found", ticketID)
 	}
+	return s.rejectIllegalTransition(ctx, ticketID, statusCode, s.applyPaymentStatus(ctx, ticket, statusCode))
+}
+
+// rejectIllegalTransition ghi nhận kết quả thanh toán không hợp lệ với trạng thái hiện tại của vé
+// (VD: thanh toán thành công cho vé đã hết hạn) vào Ticket_Logs và bỏ qua để consumer không retry mãi.
+func (s *ManagerTicketService) rejectIllegalTransition(ctx context.Context, ticketID string, statusCode string, err error) error {
+	if !errors.Is(err, models.ErrIllegalTransition) {
+		return err
+	}
+	s.logger.Error("Rejected payment status %s for ticket %s: %v", statusCode, ticketID, err)
+	if logErr := s.ticketRepository.LogTicketAction(ctx, ticketID, fmt.Sprintf("TRANSITION_REJECTED payment status %s: %v", statusCode, err)); logErr != nil {
+		s.logger.Error("Failed to log rejected transition for ticket %s: %v", ticketID, logErr)
+	}
+	return nil
+}
 
-	isSuccess := statusCode == "1"
+// updateGroupStatus áp dụng kết quả thanh toán của hoá đơn chung cho từng vé của đơn đoàn.
+// Vé đã huỷ trước khi đoàn thanh toán không được xác nhận lại.
+func (s *ManagerTicketService) updateGroupStatus(ctx context.Context, groupRef string, statusCode string) error {
+	group, tickets, err := s.ticketRepository.GetTicketGroup(ctx, groupRef)
+	if err != nil {
+		s.logger.Error("Failed to get group booking %s: %v", groupRef, err)
+		return err
+	}
+	if group == nil {
+		return fmt.Errorf("group booking %s not found", groupRef)
+	}
+	for _, ticket := range tickets {
+		if statusCode == models.PaymentResultPaid && ticket.Status == models.TicketStatusCancelled {
+			s.logger.Info("Ticket %s of group %s was cancelled before payment. Skipping confirmation.", ticket.TicketID, groupRef)
+			continue
+		}
+		if err := s.rejectIllegalTransition(ctx, ticket.TicketID, statusCode, s.applyPaymentStatus(ctx, ticket, statusCode)); err != nil {
+			return fmt.Errorf("group %s: %w", groupRef, err)
+		}
+	}
+	s.logger.Info("Successfully processed status update for group %s (%d tickets) to %s.", groupRef, len(tickets), statusCode)
+	return nil
+}
+
+// applyPaymentStatus cập nhật trạng thái vé, ghế theo kết quả thanh toán và ghi outbox QR / trả ghế.
+func (s *ManagerTicketService) applyPaymentStatus(ctx context.Context, ticket *models.TicketReturn, statusCode string) error {
+	ticketID := ticket.TicketID
+	isSuccess := statusCode == models.PaymentResultPaid
 	expectedStatus := models.TicketStatusConfirmed
 	if !isSuccess {
 		expectedStatus = int(models.TicketStatusCancelled)
 	}
-	if int(ticket.Status) == expectedStatus {
+	// Status "2" (refund) vẫn phải cập nhật Payment_Status cho vé đã huỷ đang chờ hoàn tiền.
+	alreadyRefunded := statusCode != models.PaymentResultRefunded || ticket.PaymentStatus == models.PaymentStatusRefunded
+	if int(ticket.Status) == expectedStatus && alreadyRefunded {
 		s.logger.Info("Ticket %s is already in the target status %d. Skipping update.", ticketID, expectedStatus)
 		return nil
 	}
 
 	var params repositories.UpdateStatusTransactionParams
 	params.TicketID = ticketID
+	params.Change = models.StatusChange{Actor: "payment-service", Reason: "payment status code " + statusCode}
 	params.OutboxEvents = []db.CreateOutboxEventParams{}
 
 	switch statusCode {
-	case "1":
+	case models.PaymentResultPaid:
 		params.PaymentStatus = models.PaymentStatusPaid
 		params.GeneralTicketStatus = models.TicketStatusConfirmed
 		params.SeatTicketStatus = models.SeatStatusConfirmed
+		if err := validatePaymentResult(ticket, params); err != nil {
+			return err
+		}
 
+		// QR ký theo từng chiều vì mỗi chiều có chuyến và hạn QR riêng
 		var ticketDetails []kafkaclient.TicketDetailForQR
-		for _, seatTicket := range append(ticket.SeatTicketsBegin, ticket.SeatTicketsEnd...) {
-			ticketDetails = append(ticketDetails, kafkaclient.TicketDetailForQR{
-				SeatID:    seatTicket.SeatID,
-				QRContent: fmt.Sprintf("TICKET:%s-SEAT:%d", ticket.TicketID, seatTicket.SeatID),
-			})
+		legs := []struct {
+			tripID      string
+			seatTickets []db.GetSeatTicketsByTicketIDRow
+		}{
+			{ticket.TripIDBegin, ticket.SeatTicketsBegin},
+			{ticket.TripIDEnd.String, ticket.SeatTicketsEnd},
+		}
+		for _, leg := range legs {
+			if len(leg.seatTickets) == 0 {
+				continue
+			}
+			// Ghế đã huỷ riêng (hành khách rời đoàn) không được cấp QR
+			seatIDs := activeSeatIDs(leg.seatTickets)
+			if len(seatIDs) == 0 {
+				continue
+			}
+			legDetails, err := s.qrService.IssueSeatQRs(ctx, ticket.TicketID, leg.tripID, seatIDs)
+			if err != nil {
+				return err
+			}
+			ticketDetails = append(ticketDetails, legDetails...)
 		}
 
 		// Tạo sự kiện tổng cho cả đơn hàng
@@ -228,12 +335,24 @@ func (s *ManagerTicketService) UpdateStatusByTicketID(ctx context.Context, ticke
 			ID: uuid.New(), Topic: s.cfg.Kafka.Topics.OrderQRRequests.Topic, Key: ticketID, Payload: payloadBytes,
 		})
 
-	case "3":
+	case models.PaymentResultRefunded, models.PaymentResultFailed:
+		// "2": payment_service đã hoàn tiền, "3": thanh toán thất bại / hết hạn
 		params.PaymentStatus = models.PaymentStatusFailed
+		if statusCode == models.PaymentResultRefunded {
+			params.PaymentStatus = models.PaymentStatusRefunded
+		}
 		params.GeneralTicketStatus = models.TicketStatusCancelled
 		params.SeatTicketStatus = models.SeatStatusCancelled
+		// Kiểm tra trước khi trả ghế về cache; transaction vẫn kiểm tra lại trên dòng đã khoá
+		if err := validatePaymentResult(ticket, params); err != nil {
+			return err
+		}
+		// Vé đã huỷ (VD: khách tự huỷ) thì ghế đã được giải phóng, chỉ cần cập nhật Payment_Status.
+		if ticket.Status == models.TicketStatusCancelled {
+			break
+		}
 		var eventPayload kafkaclient.SeatUpdateEvent
-		seatCount := len(ticket.SeatTicketsBegin)
+		seatCount := len(activeSeatIDs(ticket.SeatTicketsBegin))
 		if seatCount > 0 {
 			eventPayload = kafkaclient.SeatUpdateEvent{
 				TripID:    ticket.TripIDBegin,
@@ -257,7 +376,7 @@ func (s *ManagerTicketService) UpdateStatusByTicketID(ctx context.Context, ticke
 
 		if ticket.Type == 1 {
 			var eventPayloadEnd kafkaclient.SeatUpdateEvent
-			seatCount := len(ticket.SeatTicketsEnd)
+			seatCount := len(activeSeatIDs(ticket.SeatTicketsEnd))
 			if seatCount > 0 {
 				eventPayloadEnd = kafkaclient.SeatUpdateEvent{
 					TripID:    ticket.TripIDEnd.String,
@@ -279,26 +398,15 @@ func (s *ManagerTicketService) UpdateStatusByTicketID(ctx context.Context, ticke
 				ID: uuid.New(), Topic: s.cfg.Kafka.Topics.SeatsReleased.Topic, Key: ticket.TripIDBegin, Payload: releasePayloadBytes,
 			})
 		}
-		var seatIDs []int32
-		for _, seatTicket := range ticket.SeatTicketsBegin {
-			seatIDs = append(seatIDs, seatTicket.SeatID)
-		}
-
-		var seatIDsEnd []int32
-		if ticket.Type == 1 {
-			for _, seatTicket := range ticket.SeatTicketsEnd {
-				seatIDsEnd = append(seatIDsEnd, seatTicket.SeatID)
-			}
-		}
-		if err := s.ticketRepository.UpdateCachedAvailableSeats(ctx, ticket.TripIDBegin, seatIDs, "ADD"); err != nil {
-			s.logger.Error("Failed to update cached available seats for trip %s: %v", ticket.TripIDBegin, err)
+		if err := s.ticketRepository.InvalidateAvailableSeatsCache(ctx, ticket.TripIDBegin); err != nil {
+			s.logger.Error("Failed to invalidate available seats cache for trip %s: %v", ticket.TripIDBegin, err)
 		}
 
 		go s.ticketRepository.CleanupTicketCache(context.Background(), ticketID, nil, ticket.TripIDBegin)
 
 		if ticket.Type == 1 {
-			if err := s.ticketRepository.UpdateCachedAvailableSeats(ctx, ticket.TripIDEnd.String, seatIDsEnd, "ADD"); err != nil {
-				s.logger.Error("Failed to update cached available seats for trip %s: %v", ticket.TripIDEnd, err)
+			if err := s.ticketRepository.InvalidateAvailableSeatsCache(ctx, ticket.TripIDEnd.String); err != nil {
+				s.logger.Error("Failed to invalidate available seats cache for trip %s: %v", ticket.TripIDEnd, err)
 			}
 
 			go s.ticketRepository.CleanupTicketCache(context.Background(), ticketID, nil, ticket.TripIDEnd.String)
@@ -316,3 +424,14 @@ func (s *ManagerTicketService) UpdateStatusByTicketID(ctx context.Context, ticke
 	s.logger.Info("Successfully committed status update for ticket %s to %s.", ticketID, statusCode)
 	return nil
 }
+
+// validatePaymentResult kiểm tra kết quả thanh toán hợp lệ với trạng thái hiện tại của vé theo state machine.
+func validatePaymentResult(ticket *models.TicketReturn, params repositories.UpdateStatusTransactionParams) error {
+	if err := models.ValidateTicketTransition(ticket.Status, params.GeneralTicketStatus); err != nil {
+		return fmt.Errorf("ticket %s: %w", ticket.TicketID, err)
+	}
+	if err := models.ValidatePaymentTransition(ticket.PaymentStatus, params.PaymentStatus); err != nil {
+		return fmt.Errorf("ticket %s: %w", ticket.TicketID, err)
+	}
+	return nil
+}
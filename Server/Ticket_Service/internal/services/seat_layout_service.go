@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+	"ticket-service/internal/repositories"
+	"ticket-service/pkg/utils"
+)
+
+var ErrInvalidSeatLayout = errors.New("invalid seat layout")
+
+type ISeatLayoutService interface {
+	ResolveLayout(ctx context.Context, vehicle *models.VehicleInfo) (*models.SeatLayout, error)
+	ListLayouts(ctx context.Context) ([]models.SeatLayout, error)
+	GetLayout(ctx context.Context, layoutID int32) (*models.SeatLayout, error)
+	UpsertLayout(ctx context.Context, req *models.UpsertSeatLayoutRequest) (*models.SeatLayout, error)
+	DeleteLayout(ctx context.Context, layoutID int32) error
+	GetTripSeatMap(ctx context.Context, tripID string, segment models.SeatSegment) (*models.TripSeatMap, error)
+}
+
+type SeatLayoutService struct {
+	seatLayoutRepository repositories.SeatLayoutRepositoryInterface
+	logger               utils.Logger
+}
+
+func NewSeatLayoutService(seatLayoutRepository repositories.SeatLayoutRepositoryInterface, logger utils.Logger) ISeatLayoutService {
+	return &SeatLayoutService{
+		seatLayoutRepository: seatLayoutRepository,
+		logger:               logger,
+	}
+}
+
+// ResolveLayout chọn mẫu sơ đồ theo loại xe và số ghế của xe; xe không rõ loại dùng mẫu mặc định.
+func (s *SeatLayoutService) ResolveLayout(ctx context.Context, vehicle *models.VehicleInfo) (*models.SeatLayout, error) {
+	vehicleTypeID, seatCount := int32(models.DefaultVehicleTypeID), int32(0)
+	if vehicle != nil {
+		seatCount = int32(vehicle.SeatNumber)
+		if vehicle.Type != nil {
+			vehicleTypeID = int32(vehicle.Type.ID)
+		}
+	}
+
+	layout, seats, err := s.seatLayoutRepository.FindLayoutForVehicle(ctx, vehicleTypeID, seatCount)
+	if err != nil {
+		return nil, err
+	}
+	if layout.VehicleTypeID != vehicleTypeID || (seatCount > 0 && layout.SeatCount != seatCount) {
+		s.logger.Info("Warning: no exact seat layout for vehicle type %d with %d seats, using layout %d (%s)", vehicleTypeID, seatCount, layout.ID, layout.Name)
+	}
+	return toSeatLayout(layout, seats), nil
+}
+
+func (s *SeatLayoutService) ListLayouts(ctx context.Context) ([]models.SeatLayout, error) {
+	layouts, err := s.seatLayoutRepository.ListLayouts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.SeatLayout, 0, len(layouts))
+	for i := range layouts {
+		result = append(result, *toSeatLayout(&layouts[i], nil))
+	}
+	return result, nil
+}
+
+func (s *SeatLayoutService) GetLayout(ctx context.Context, layoutID int32) (*models.SeatLayout, error) {
+	layout, seats, err := s.seatLayoutRepository.GetLayout(ctx, layoutID)
+	if err != nil {
+		return nil, err
+	}
+	return toSeatLayout(layout, seats), nil
+}
+
+// UpsertLayout kiểm tra vị trí ghế nằm trong lưới và không trùng rồi lưu mẫu.
+// Chỉ ảnh hưởng các chuyến tạo ghế sau đó, ghế của chuyến đã tạo giữ nguyên.
+func (s *SeatLayoutService) UpsertLayout(ctx context.Context, req *models.UpsertSeatLayoutRequest) (*models.SeatLayout, error) {
+	names := make(map[string]bool, len(req.Seats))
+	positions := make(map[[3]int16]bool, len(req.Seats))
+	seats := make([]db.CreateSeatLayoutSeatParams, 0, len(req.Seats))
+	for _, seat := range req.Seats {
+		if seat.Deck > req.Decks || seat.Row > req.Rows || seat.Col > req.Columns {
+			return nil, fmt.Errorf("seat %s at deck %d row %d col %d is outside the %dx%dx%d grid: %w", seat.Name, seat.Deck, seat.Row, seat.Col, req.Decks, req.Rows, req.Columns, ErrInvalidSeatLayout)
+		}
+		if names[seat.Name] {
+			return nil, fmt.Errorf("duplicate seat name %s: %w", seat.Name, ErrInvalidSeatLayout)
+		}
+		position := [3]int16{seat.Deck, seat.Row, seat.Col}
+		if positions[position] {
+			return nil, fmt.Errorf("seats overlap at deck %d row %d col %d: %w", seat.Deck, seat.Row, seat.Col, ErrInvalidSeatLayout)
+		}
+		names[seat.Name] = true
+		positions[position] = true
+
+		class := seat.Class
+		if class == "" {
+			class = models.SeatClassStandard
+		}
+		seats = append(seats, db.CreateSeatLayoutSeatParams{
+			SeatName:  seat.Name,
+			Deck:      seat.Deck,
+			RowNo:     seat.Row,
+			ColNo:     seat.Col,
+			SeatClass: class,
+		})
+	}
+	for _, col := range req.AisleAfterColumns {
+		if col < 1 || col >= int32(req.Columns) {
+			return nil, fmt.Errorf("aisle after column %d is outside 1..%d: %w", col, req.Columns-1, ErrInvalidSeatLayout)
+		}
+	}
+
+	aisles := req.AisleAfterColumns
+	if aisles == nil {
+		aisles = []int32{}
+	}
+	layout, savedSeats, err := s.seatLayoutRepository.UpsertLayout(ctx, db.UpsertSeatLayoutParams{
+		VehicleTypeID:     req.VehicleTypeID,
+		SeatCount:         int32(len(seats)),
+		Name:              req.Name,
+		Decks:             req.Decks,
+		RowCount:          req.Rows,
+		ColumnCount:       req.Columns,
+		AisleAfterColumns: aisles,
+	}, seats)
+	if err != nil {
+		s.logger.Error("Failed to save seat layout for vehicle type %d: %v", req.VehicleTypeID, err)
+		return nil, err
+	}
+	s.logger.Info("Saved seat layout %d (%s) for vehicle type %d with %d seats", layout.ID, layout.Name, layout.VehicleTypeID, layout.SeatCount)
+	return toSeatLayout(layout, savedSeats), nil
+}
+
+// DeleteLayout xoá mẫu sơ đồ; mẫu mặc định không được xoá vì là fallback khi tạo ghế.
+func (s *SeatLayoutService) DeleteLayout(ctx context.Context, layoutID int32) error {
+	layout, _, err := s.seatLayoutRepository.GetLayout(ctx, layoutID)
+	if err != nil {
+		return err
+	}
+	if layout.VehicleTypeID == models.DefaultVehicleTypeID {
+		return fmt.Errorf("layout %d is a default layout and cannot be deleted: %w", layoutID, ErrInvalidSeatLayout)
+	}
+	return s.seatLayoutRepository.DeleteLayout(ctx, layoutID)
+}
+
+// GetTripSeatMap trả về sơ đồ ghế của chuyến kèm trạng thái còn trống của từng ghế trên đoạn đường segment.
+func (s *SeatLayoutService) GetTripSeatMap(ctx context.Context, tripID string, segment models.SeatSegment) (*models.TripSeatMap, error) {
+	rows, err := s.seatLayoutRepository.GetSeatMap(ctx, tripID, segment)
+	if err != nil {
+		s.logger.Error("Error getting seat map for trip %s: %v", tripID, err)
+		return nil, err
+	}
+
+	seatMap := &models.TripSeatMap{TripID: tripID, Segment: segment, Seats: make([]models.TripSeat, 0, len(rows))}
+	for _, row := range rows {
+		seatMap.Seats = append(seatMap.Seats, models.TripSeat{
+			ID:        row.ID,
+			Name:      row.SeatName.String,
+			Deck:      row.Deck.Int16,
+			Row:       row.RowNo.Int16,
+			Col:       row.ColNo.Int16,
+			Class:     row.SeatClass.String,
+			Available: !row.IsBooked,
+		})
+	}
+
+	if len(rows) > 0 && rows[0].LayoutID.Valid {
+		layout, _, err := s.seatLayoutRepository.GetLayout(ctx, rows[0].LayoutID.Int32)
+		if err != nil && !errors.Is(err, repositories.ErrSeatLayoutNotFound) {
+			return nil, err
+		}
+		if layout != nil {
+			seatMap.Layout = toSeatLayout(layout, nil)
+		}
+	}
+	return seatMap, nil
+}
+
+func toSeatLayout(layout *db.SeatLayout, seats []db.SeatLayoutSeat) *models.SeatLayout {
+	result := &models.SeatLayout{
+		ID:                layout.ID,
+		VehicleTypeID:     layout.VehicleTypeID,
+		SeatCount:         layout.SeatCount,
+		Name:              layout.Name,
+		Decks:             layout.Decks,
+		Rows:              layout.RowCount,
+		Columns:           layout.ColumnCount,
+		AisleAfterColumns: layout.AisleAfterColumns,
+	}
+	for _, seat := range seats {
+		result.Seats = append(result.Seats, models.LayoutSeat{
+			Name:  seat.SeatName,
+			Deck:  seat.Deck,
+			Row:   seat.RowNo,
+			Col:   seat.ColNo,
+			Class: seat.SeatClass,
+		})
+	}
+	return result
+}
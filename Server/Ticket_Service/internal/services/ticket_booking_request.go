@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+	"ticket-service/pkg/kafkaclient"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// bookingConnectTimeout là thời gian client có để kết nối WebSocket hoặc poll booking sau khi gửi yêu cầu.
+	bookingConnectTimeout = 30 * time.Second
+	// bookingExpiryBatchSize là số yêu cầu tối đa TimeoutWorker xử lý mỗi lượt quét.
+	bookingExpiryBatchSize = 100
+)
+
+// ProcessBookingRequest tạo vé cho một yêu cầu đặt vé lấy từ Kafka và trả về trạng thái cuối cùng (COMPLETED / FAILED).
+// Trả về models.ErrBookingRequestClosed nếu yêu cầu đã hết hạn hoặc đã được xử lý: không có vé nào được tạo.
+// Các lỗi khác là lỗi tạm thời, message cần được xử lý lại.
+func (t *TicketService) ProcessBookingRequest(ctx context.Context, event kafkaclient.BookingRequestEvent) (*models.BookingRequestStatus, error) {
+	id, err := uuid.Parse(event.BookingID)
+	if err != nil {
+		return nil, fmt.Errorf("booking request %q: %w", event.BookingID, models.ErrBookingRequestNotFound)
+	}
+	if _, err := t.ticketRepository.StartBookingRequest(ctx, id); err != nil {
+		return nil, err
+	}
+
+	ticket, err := t.createTicket(ctx, &event.Input, event.CustomerID, id)
+	if errors.Is(err, models.ErrBookingRequestClosed) {
+		return nil, err
+	}
+	if err != nil {
+		t.logger.Error("[Booking] Failed to create ticket for bookingId %s: %v", event.BookingID, err)
+		if failErr := t.ticketRepository.FailBookingRequest(ctx, id, err.Error()); failErr != nil {
+			return nil, failErr
+		}
+	} else {
+		t.logger.Info("[Booking] Ticket %s created successfully for bookingId %s.", ticket.TicketID, event.BookingID)
+	}
+	return t.GetBookingRequestStatus(ctx, event.BookingID)
+}
+
+// TrackBookingRequest trả về trạng thái yêu cầu đặt vé và ghi nhận client đang theo dõi nó (WebSocket hoặc poll),
+// để TimeoutWorker không huỷ vé. customerID hợp lệ khi người gọi là khách hàng: khách khác không thấy được booking.
+func (t *TicketService) TrackBookingRequest(ctx context.Context, bookingID string, customerID sql.NullInt32) (*models.BookingRequestStatus, error) {
+	id, err := uuid.Parse(bookingID)
+	if err != nil {
+		return nil, models.ErrBookingRequestNotFound
+	}
+	request, err := t.ticketRepository.GetBookingRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if customerID.Valid && request.CustomerID.Valid && customerID.Int32 != request.CustomerID.Int32 {
+		return nil, models.ErrBookingRequestNotFound
+	}
+	if !request.ConnectedAt.Valid {
+		if request, err = t.ticketRepository.ConnectBookingRequest(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return t.toBookingRequestStatus(ctx, request), nil
+}
+
+// GetBookingRequestStatus trả về trạng thái yêu cầu đặt vé, kèm chi tiết vé khi đã COMPLETED.
+func (t *TicketService) GetBookingRequestStatus(ctx context.Context, bookingID string) (*models.BookingRequestStatus, error) {
+	id, err := uuid.Parse(bookingID)
+	if err != nil {
+		return nil, models.ErrBookingRequestNotFound
+	}
+	request, err := t.ticketRepository.GetBookingRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return t.toBookingRequestStatus(ctx, request), nil
+}
+
+// ExpireUnconnectedBookingRequests huỷ các yêu cầu đặt vé không được client theo dõi trước hạn,
+// kể cả vé đã tạo cho chúng. Trả về số yêu cầu đã hết hạn.
+func (t *TicketService) ExpireUnconnectedBookingRequests(ctx context.Context) (int, error) {
+	expired, err := t.ticketRepository.ExpireUnconnectedBookingRequests(ctx, bookingExpiryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, request := range expired {
+		if !request.TicketID.Valid {
+			// QUEUED / PROCESSING: consumer sẽ bỏ qua yêu cầu, hoặc rollback vé đang tạo dở.
+			// Yêu cầu còn trong phòng chờ được bỏ ra để không chiếm suất của người sau.
+			if err := t.waitingRoom.Leave(ctx, request.BookingID.String()); err != nil {
+				t.logger.Error("[WaitingRoom] %v", err)
+			}
+			t.logger.Info("[Booking] Booking %s expired before its ticket was created.", request.BookingID)
+			continue
+		}
+
+		t.logger.Info("[Booking] Booking %s expired. Cancelling associated ticket %s.", request.BookingID, request.TicketID.String)
+		if err := t.CancelTicket(ctx, request.TicketID.String); err != nil {
+			t.logger.Error("[Booking] Failed to cancel ticket %s of expired booking %s, will retry: %v", request.TicketID.String, request.BookingID, err)
+			if reopenErr := t.ticketRepository.ReopenExpiredBookingRequest(ctx, request.BookingID); reopenErr != nil {
+				t.logger.Error("[Booking] %v", reopenErr)
+			}
+		}
+	}
+	return len(expired), nil
+}
+
+// AdmitWaitingBookings đưa các yêu cầu tới lượt trong phòng chờ của mọi chuyến vào Kafka.
+// Trả về số yêu cầu đã được đưa vào xử lý.
+func (t *TicketService) AdmitWaitingBookings(ctx context.Context) (int, error) {
+	trips, err := t.waitingRoom.ActiveTrips(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	admitted := 0
+	for _, tripID := range trips {
+		bookingIDs, err := t.waitingRoom.Admit(ctx, tripID)
+		if err != nil {
+			t.logger.Error("[WaitingRoom] %v", err)
+			continue
+		}
+		for _, bookingID := range bookingIDs {
+			if t.admitBookingRequest(ctx, bookingID) {
+				admitted++
+			}
+		}
+	}
+	return admitted, nil
+}
+
+// admitBookingRequest publish một yêu cầu vừa rời phòng chờ. Yêu cầu đã hết hạn trong lúc chờ thì bỏ qua.
+func (t *TicketService) admitBookingRequest(ctx context.Context, bookingID string) bool {
+	id, err := uuid.Parse(bookingID)
+	if err != nil {
+		t.logger.Error("[WaitingRoom] Invalid booking id %q in waiting room, skipping.", bookingID)
+		return false
+	}
+	request, err := t.ticketRepository.GetBookingRequest(ctx, id)
+	if err != nil {
+		t.logger.Error("[WaitingRoom] Failed to load admitted booking %s: %v", bookingID, err)
+		return false
+	}
+	if request.Status != models.BookingRequestQueued {
+		t.logger.Info("[WaitingRoom] Booking %s is %s, skipping admission.", bookingID, request.Status)
+		return false
+	}
+
+	var input models.TicketInput
+	if err := json.Unmarshal(request.RequestData, &input); err != nil {
+		t.logger.Error("[WaitingRoom] Failed to decode booking %s: %v", bookingID, err)
+		if failErr := t.ticketRepository.FailBookingRequest(ctx, id, "invalid booking request data"); failErr != nil {
+			t.logger.Error("[WaitingRoom] %v", failErr)
+		}
+		return false
+	}
+	if err := t.publishBookingRequest(id, &input, request.CustomerID); err != nil {
+		return false
+	}
+	t.logger.Info("[WaitingRoom] Booking %s admitted for trip %s.", bookingID, input.TripIDBegin)
+	return true
+}
+
+// GetWaitingRoomPosition trả về vị trí hiện tại của yêu cầu trong phòng chờ, nil nếu không (còn) xếp hàng.
+func (t *TicketService) GetWaitingRoomPosition(ctx context.Context, bookingID string) (*models.WaitingRoomPosition, error) {
+	return t.waitingRoom.Position(ctx, bookingID)
+}
+
+func (t *TicketService) toBookingRequestStatus(ctx context.Context, request db.BookingRequest) *models.BookingRequestStatus {
+	status := &models.BookingRequestStatus{
+		BookingID:       request.BookingID.String(),
+		Status:          request.Status,
+		CustomerID:      request.CustomerID,
+		TicketID:        request.TicketID.String,
+		Error:           request.ErrorMessage.String,
+		ConnectDeadline: request.ConnectDeadline,
+		CreatedAt:       request.CreatedAt,
+		UpdatedAt:       request.UpdatedAt,
+	}
+	if request.Status == models.BookingRequestCompleted && request.TicketID.Valid {
+		ticket, err := t.GetTicketByID(ctx, request.TicketID.String)
+		if err != nil {
+			t.logger.Error("[Booking] Failed to retrieve ticket %s of booking %s: %v", request.TicketID.String, status.BookingID, err)
+		} else {
+			status.Ticket = ticket
+		}
+	}
+	if request.Status == models.BookingRequestQueued {
+		position, err := t.waitingRoom.Position(ctx, status.BookingID)
+		if err != nil {
+			t.logger.Error("[WaitingRoom] %v", err)
+		} else {
+			status.Queue = position
+		}
+	}
+	return status
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+	"ticket-service/internal/repositories"
+	"ticket-service/pkg/kafkaclient"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTicketNotFound       = errors.New("ticket not found")
+	ErrTicketNotOwned       = errors.New("ticket does not belong to the requester")
+	ErrTicketNotCancellable = errors.New("ticket can no longer be cancelled")
+	ErrTicketBusy           = errors.New("ticket is being processed, please try again shortly")
+)
+
+// CancelTicketByCustomer huỷ vé theo yêu cầu của khách (hoặc nhân viên), tính tiền hoàn theo policy,
+// giải phóng ghế và gửi yêu cầu hoàn tiền sang payment_service qua outbox.
+func (t *TicketService) CancelTicketByCustomer(ctx context.Context, ticketID string, req *models.CancelTicketRequest) (*models.CancellationResult, error) {
+	lockAcquired, unlock, err := t.ticketRepository.AcquireLock(ctx, ticketLockKey(ticketID), 10*time.Second)
+	if err != nil {
+		t.logger.Error("Error acquiring cancel lock for ticket %s: %v", ticketID, err)
+		return nil, errors.New("could not contact booking service, please try again")
+	}
+	if !lockAcquired {
+		return nil, ErrTicketBusy
+	}
+	defer unlock()
+
+	ticket, err := t.ticketRepository.GetTicketByID(ctx, ticketID)
+	if err != nil || ticket == nil {
+		t.logger.Error("Cancel: failed to get ticket %s: %v", ticketID, err)
+		return nil, fmt.Errorf("ticket %s: %w", ticketID, ErrTicketNotFound)
+	}
+
+	if !req.CanModify(ticket) {
+		return nil, ErrTicketNotOwned
+	}
+
+	switch ticket.Status {
+	case models.TicketStatusCancelled, models.TicketStatusUsed, models.TicketStatusExpired:
+		return nil, fmt.Errorf("ticket %s has status %d: %w", ticketID, ticket.Status, ErrTicketNotCancellable)
+	}
+	for _, seatTicket := range append(ticket.SeatTicketsBegin, ticket.SeatTicketsEnd...) {
+		if seatTicket.Status == models.SeatStatusCheckedIn {
+			return nil, fmt.Errorf("seat %d already checked in: %w", seatTicket.SeatID, ErrTicketNotCancellable)
+		}
+	}
+
+	quote, err := t.fareService.QuoteRefund(ctx, ticket)
+	if err != nil {
+		return nil, err
+	}
+
+	params := repositories.CancelTicketTransactionParams{
+		UpdateStatusTransactionParams: repositories.UpdateStatusTransactionParams{
+			TicketID:            ticketID,
+			GeneralTicketStatus: models.TicketStatusCancelled,
+			SeatTicketStatus:    models.SeatStatusCancelled,
+			Change:              models.StatusChange{Actor: req.Actor, Reason: req.Reason},
+			OutboxEvents:        t.seatReleaseEvents(ticket),
+		},
+		LogAction: fmt.Sprintf("CANCELLED by %s: refund %.2f%% (%.2f)", req.Actor, quote.RefundPercent, quote.RefundAmount),
+	}
+
+	groupRef, err := t.ticketRepository.GetGroupRefByTicketID(ctx, ticketID)
+	if err != nil {
+		t.logger.Error("Cancel: failed to resolve group of ticket %s: %v", ticketID, err)
+		return nil, fmt.Errorf("could not cancel ticket: %w", err)
+	}
+
+	refundRequested := quote.RefundAmount > 0
+	switch {
+	case refundRequested && groupRef != "":
+		// Vé thuộc đơn đoàn: hoá đơn chung mang mã đoàn nên chỉ hoàn một phần, các vé khác của đoàn vẫn đã thanh toán
+		params.PaymentStatus = models.PaymentStatusPaid
+		adjustmentPayload, _ := json.Marshal(kafkaclient.FareAdjustmentEvent{
+			TicketID:    groupRef,
+			Amount:      -quote.RefundAmount,
+			Reason:      fmt.Sprintf("Huỷ vé %s của đoàn. %s", ticketID, req.Reason),
+			RequestedBy: req.Actor,
+			RequestedAt: time.Now(),
+		})
+		params.OutboxEvents = append(params.OutboxEvents, db.CreateOutboxEventParams{
+			ID: uuid.New(), Topic: t.cfg.Kafka.Topics.FareAdjustments.Topic, Key: groupRef, Payload: adjustmentPayload,
+		})
+	case refundRequested:
+		params.PaymentStatus = models.PaymentStatusRefundPending
+		refundPayload, _ := json.Marshal(kafkaclient.RefundRequestEvent{
+			TicketID:      ticketID,
+			RefundPercent: quote.RefundPercent,
+			RefundAmount:  quote.RefundAmount,
+			Reason:        req.Reason,
+			RequestedBy:   req.Actor,
+			RequestedAt:   time.Now(),
+		})
+		params.OutboxEvents = append(params.OutboxEvents, db.CreateOutboxEventParams{
+			ID: uuid.New(), Topic: t.cfg.Kafka.Topics.RefundRequests.Topic, Key: ticketID, Payload: refundPayload,
+		})
+	case ticket.PaymentStatus == models.PaymentStatusPaid:
+		// Đã thanh toán nhưng refund rule cho 0%: vé huỷ, không hoàn tiền.
+		params.PaymentStatus = models.PaymentStatusPaid
+	default:
+		params.PaymentStatus = models.PaymentStatusFailed
+	}
+
+	if err := t.ticketRepository.CancelTicketInTransaction(ctx, params); err != nil {
+		if errors.Is(err, models.ErrIllegalTransition) {
+			// Vé vừa đổi trạng thái (VD: check-in) giữa lúc đọc và lúc khoá dòng
+			return nil, fmt.Errorf("ticket %s: %w", ticketID, ErrTicketNotCancellable)
+		}
+		t.logger.Error("Cancel: transaction failed for ticket %s: %v", ticketID, err)
+		return nil, fmt.Errorf("could not cancel ticket: %w", err)
+	}
+
+	t.releaseCachedSeats(ctx, ticket)
+
+	t.logger.Info("[Cancel] Ticket %s cancelled by %s. Refund %.2f (%.2f%%), requested=%t", ticketID, req.Actor, quote.RefundAmount, quote.RefundPercent, refundRequested)
+	return &models.CancellationResult{
+		TicketID:        ticketID,
+		Status:          models.TicketStatusCancelled,
+		PaymentStatus:   params.PaymentStatus,
+		Refund:          quote,
+		RefundRequested: refundRequested,
+	}, nil
+}
+
+// seatReleaseEvents tạo outbox event seats_released cho từng chiều của vé.
+// Ghế đã huỷ riêng trước đó (hành khách rời đoàn) đã được trả nên không tính lại.
+func (t *TicketService) seatReleaseEvents(ticket *models.TicketReturn) []db.CreateOutboxEventParams {
+	events := []db.CreateOutboxEventParams{}
+	if seatCount := len(activeSeatIDs(ticket.SeatTicketsBegin)); seatCount > 0 {
+		payload, _ := json.Marshal(kafkaclient.SeatUpdateEvent{TripID: ticket.TripIDBegin, SeatCount: seatCount})
+		events = append(events, db.CreateOutboxEventParams{
+			ID: uuid.New(), Topic: t.cfg.Kafka.Topics.SeatsReleased.Topic, Key: ticket.TripIDBegin, Payload: payload,
+		})
+	}
+	if ticket.Type == 1 {
+		if seatCount := len(activeSeatIDs(ticket.SeatTicketsEnd)); seatCount > 0 {
+			payload, _ := json.Marshal(kafkaclient.SeatUpdateEvent{TripID: ticket.TripIDEnd.String, SeatCount: seatCount})
+			events = append(events, db.CreateOutboxEventParams{
+				ID: uuid.New(), Topic: t.cfg.Kafka.Topics.SeatsReleased.Topic, Key: ticket.TripIDEnd.String, Payload: payload,
+			})
+		}
+	}
+	return events
+}
+
+// releaseCachedSeats trả ghế về cache available seats và xoá cache của vé.
+func (t *TicketService) releaseCachedSeats(ctx context.Context, ticket *models.TicketReturn) {
+	seatIDs := activeSeatIDs(ticket.SeatTicketsBegin)
+	if err := t.ticketRepository.InvalidateAvailableSeatsCache(ctx, ticket.TripIDBegin); err != nil {
+		t.logger.Error("Failed to invalidate available seats cache for trip %s: %v", ticket.TripIDBegin, err)
+	}
+	go t.ticketRepository.CleanupTicketCache(context.Background(), ticket.TicketID, seatIDs, ticket.TripIDBegin)
+
+	if ticket.Type == 1 {
+		seatIDsEnd := activeSeatIDs(ticket.SeatTicketsEnd)
+		if err := t.ticketRepository.InvalidateAvailableSeatsCache(ctx, ticket.TripIDEnd.String); err != nil {
+			t.logger.Error("Failed to invalidate available seats cache for trip %s: %v", ticket.TripIDEnd.String, err)
+		}
+		go t.ticketRepository.CleanupTicketCache(context.Background(), ticket.TicketID, seatIDsEnd, ticket.TripIDEnd.String)
+	}
+}
+
+// activeSeatIDs trả về các ghế chưa bị huỷ của một chiều.
+func activeSeatIDs(seatTickets []db.GetSeatTicketsByTicketIDRow) []int32 {
+	var seatIDs []int32
+	for _, seatTicket := range seatTickets {
+		if seatTicket.Status != models.SeatStatusCancelled {
+			seatIDs = append(seatIDs, seatTicket.SeatID)
+		}
+	}
+	return seatIDs
+}
@@ -0,0 +1,457 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+	"ticket-service/internal/repositories"
+	"ticket-service/pkg/kafkaclient"
+	"ticket-service/pkg/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrGroupBookingNotFound    = errors.New("group booking not found")
+	ErrGroupPassengerNotFound  = errors.New("passenger not found in group booking")
+	ErrDuplicateGroupSeat      = errors.New("a seat is assigned to more than one passenger")
+	ErrTooManyGroupPassengers  = fmt.Errorf("a group booking allows at most %d passengers per trip", models.MaxGroupPassengersPerLeg)
+	ErrGroupBookingUnavailable = errors.New("one or more seats of the group are not available")
+)
+
+// CreateGroupBooking đặt vé cho đoàn: mỗi chuyến của đoàn là một vé một chiều, mỗi ghế ghi tên hành khách riêng.
+// Các vé được tạo trong cùng một transaction và gắn với một mã đơn đoàn; client thanh toán một hoá đơn
+// ở payment_service với ticket_id là mã đơn đoàn.
+func (t *TicketService) CreateGroupBooking(ctx context.Context, req *models.CreateGroupBookingRequest) (*models.GroupBooking, error) {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	type pricedLeg struct {
+		leg       *models.GroupBookingLeg
+		segment   models.SeatSegment
+		fare      *models.FareBreakdown
+		fareBytes []byte
+	}
+
+	seenSeats := make(map[int32]bool)
+	legs := make([]pricedLeg, 0, len(req.Legs))
+	for i := range req.Legs {
+		leg := &req.Legs[i]
+		if len(leg.Passengers) > models.MaxGroupPassengersPerLeg {
+			return nil, fmt.Errorf("trip %s has %d passengers: %w", leg.TripID, len(leg.Passengers), ErrTooManyGroupPassengers)
+		}
+		for _, passenger := range leg.Passengers {
+			if seenSeats[passenger.SeatID] {
+				return nil, fmt.Errorf("seat %d: %w", passenger.SeatID, ErrDuplicateGroupSeat)
+			}
+			seenSeats[passenger.SeatID] = true
+		}
+		trip, err := t.segmentTrip(ctx, leg.TripID)
+		if err != nil {
+			return nil, err
+		}
+		segment, err := leg.Segment(trip)
+		if err != nil {
+			return nil, fmt.Errorf("trip %s: %w", leg.TripID, err)
+		}
+
+		fare, fareBytes, err := t.priceTicket(ctx, &models.TicketInput{
+			PolicyID:             req.PolicyID,
+			BookingChannel:       req.BookingChannel,
+			TripIDBegin:          leg.TripID,
+			SeatIDBegin:          leg.SeatIDs(),
+			PickupLocationBegin:  leg.PickupLocation,
+			DropoffLocationBegin: leg.DropoffLocation,
+		})
+		if err != nil {
+			return nil, err
+		}
+		legs = append(legs, pricedLeg{leg: leg, segment: segment, fare: fare, fareBytes: fareBytes})
+	}
+
+	// Dùng chung lock ghế với luồng đặt vé lẻ
+	for seatID := range seenSeats {
+		lockAcquired, unlock, err := t.ticketRepository.AcquireLock(ctx, seatLockKey(seatID), 10*time.Second)
+		if err != nil {
+			t.logger.Error("Error acquiring lock for seat %d: %v", seatID, err)
+			return nil, errors.New("could not contact booking service, please try again")
+		}
+		if !lockAcquired {
+			return nil, errors.New("booking for this trip is currently busy, please try again shortly")
+		}
+		defer unlock()
+	}
+
+	for _, l := range legs {
+		seatIDs := l.leg.SeatIDs()
+		rows, err := t.ticketRepository.AreSeatsAvailable(ctx, seatIDs, l.segment, req.Actor.CustomerID.Int32)
+		if err != nil {
+			t.logger.Error("Database error during group seat validation for trip %s: %v", l.leg.TripID, err)
+			return nil, errors.New("error checking seat availability")
+		}
+		if len(rows) != len(seatIDs) {
+			return nil, fmt.Errorf("trip %s: one or more seats do not exist: %w", l.leg.TripID, ErrGroupBookingUnavailable)
+		}
+		for _, seat := range rows {
+			if seat.IsBooked {
+				return nil, fmt.Errorf("seat %d is already booked or held by another user: %w", seat.ID, ErrGroupBookingUnavailable)
+			}
+		}
+	}
+
+	groupRef, err := t.ticketRepository.GenerateUniqueGroupRef(ctx)
+	if err != nil {
+		t.logger.Error("Error generating unique group ref: %v", err)
+		return nil, err
+	}
+
+	bookedBy := "customer"
+	if req.Actor.IsStaff {
+		bookedBy = req.Actor.Actor
+	}
+	params := repositories.CreateGroupBookingTransactionParams{
+		Group: db.CreateTicketGroupParams{
+			GroupRef:         groupRef,
+			CustomerID:       req.Actor.CustomerID,
+			OrganizationName: utils.ToNullString(req.OrganizationName),
+			ContactName:      req.ContactName,
+			ContactPhone:     req.ContactPhone,
+			ContactEmail:     utils.ToNullString(req.ContactEmail),
+			BookedBy:         utils.ToNullString(bookedBy),
+		},
+		OutboxEvents: []db.CreateOutboxEventParams{},
+	}
+
+	usedTicketIDs := make(map[string]bool)
+	for _, l := range legs {
+		ticketID, err := t.ticketRepository.GenerateUniqueTicketID(ctx)
+		for err == nil && usedTicketIDs[ticketID] {
+			ticketID, err = t.ticketRepository.GenerateUniqueTicketID(ctx)
+		}
+		if err != nil {
+			t.logger.Error("Error generating unique ticket ID: %v", err)
+			return nil, err
+		}
+		usedTicketIDs[ticketID] = true
+
+		passengers := make(map[int32]models.GroupPassenger, len(l.leg.Passengers))
+		for _, passenger := range l.leg.Passengers {
+			passengers[passenger.SeatID] = passenger
+		}
+		params.Tickets = append(params.Tickets, repositories.CreateTicketTransactionParams{
+			Ticket: db.CreateTicketParams{
+				TicketID:       ticketID,
+				TripIDBegin:    l.leg.TripID,
+				Type:           0,
+				CustomerID:     req.Actor.CustomerID,
+				Phone:          utils.ToNullString(req.ContactPhone),
+				Email:          utils.ToNullString(req.ContactEmail),
+				Name:           utils.ToNullString(req.ContactName),
+				Price:          l.fare.Total,
+				Status:         models.TicketStatusPendingConfirmation,
+				BookingTime:    time.Now(),
+				PaymentStatus:  models.PaymentStatusPending,
+				BookingChannel: req.BookingChannel,
+				PolicyID:       req.PolicyID,
+				BookedBy:       utils.ToNullString(bookedBy),
+				FareBreakdown:  l.fareBytes,
+			},
+			TicketDetail: db.CreateTicketDetailsParams{
+				TicketID:             ticketID,
+				PickupLocationBegin:  sql.NullInt32{Int32: l.leg.PickupLocation, Valid: true},
+				DropoffLocationBegin: sql.NullInt32{Int32: l.leg.DropoffLocation, Valid: true},
+			},
+			SeatIDsBegin: l.leg.SeatIDs(),
+			SegmentBegin: l.segment,
+			Passengers:   passengers,
+		})
+
+		payload, _ := json.Marshal(kafkaclient.SeatUpdateEvent{TripID: l.leg.TripID, SeatCount: len(l.leg.Passengers)})
+		params.OutboxEvents = append(params.OutboxEvents, db.CreateOutboxEventParams{
+			ID: uuid.New(), Topic: t.cfg.Kafka.Topics.SeatsReserved.Topic, Key: l.leg.TripID, Payload: payload,
+		})
+	}
+
+	if err := t.ticketRepository.CreateGroupBookingInTransaction(ctx, params); err != nil {
+		t.logger.Error("Error in repository's atomic transaction for group %s: %v", groupRef, err)
+		return nil, fmt.Errorf("failed to finalize group booking, please try again")
+	}
+
+	for _, l := range legs {
+		go func(tripID string, seatIDs []int32) {
+			bgCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			t.ticketRepository.InvalidateAvailableSeatsCache(bgCtx, tripID)
+		}(l.leg.TripID, l.leg.SeatIDs())
+	}
+
+	t.logger.Info("Group booking %s created with %d tickets and %d passengers.", groupRef, len(params.Tickets), len(seenSeats))
+	group, tickets, err := t.ticketRepository.GetTicketGroup(ctx, groupRef)
+	if err != nil || group == nil {
+		return nil, fmt.Errorf("group %s was created but could not be loaded: %v", groupRef, err)
+	}
+	return buildGroupBooking(group, tickets), nil
+}
+
+// GetGroupBooking trả về đơn đoàn kèm vé, hành khách và tổng tiền còn phải/đã thanh toán.
+func (t *TicketService) GetGroupBooking(ctx context.Context, groupRef string, actor *models.TicketActor) (*models.GroupBooking, error) {
+	group, tickets, err := t.ticketRepository.GetTicketGroup(ctx, groupRef)
+	if err != nil {
+		t.logger.Error("Failed to get group booking %s: %v", groupRef, err)
+		return nil, err
+	}
+	if group == nil {
+		return nil, fmt.Errorf("group %s: %w", groupRef, ErrGroupBookingNotFound)
+	}
+	if !canAccessGroup(actor, group) {
+		return nil, ErrTicketNotOwned
+	}
+	return buildGroupBooking(group, tickets), nil
+}
+
+// CancelGroupPassenger huỷ một hành khách (một ghế) của đơn đoàn: trả ghế, bớt giá vé theo đơn giá lúc đặt
+// và hoàn phần tiền của hành khách theo refund rule của policy trên hoá đơn chung của đoàn.
+// Vé không còn hành khách nào thì chuyển sang trạng thái huỷ.
+func (t *TicketService) CancelGroupPassenger(ctx context.Context, groupRef string, seatTicketID int32, req *models.CancelGroupPassengerRequest) (*models.GroupPassengerCancellation, error) {
+	group, tickets, err := t.ticketRepository.GetTicketGroup(ctx, groupRef)
+	if err != nil {
+		t.logger.Error("Failed to get group booking %s: %v", groupRef, err)
+		return nil, err
+	}
+	if group == nil {
+		return nil, fmt.Errorf("group %s: %w", groupRef, ErrGroupBookingNotFound)
+	}
+	if !canAccessGroup(&req.TicketActor, group) {
+		return nil, ErrTicketNotOwned
+	}
+	ticketID := ""
+	for _, ticket := range tickets {
+		for _, seatTicket := range ticket.SeatTicketsBegin {
+			if seatTicket.ID == seatTicketID {
+				ticketID = ticket.TicketID
+			}
+		}
+	}
+	if ticketID == "" {
+		return nil, fmt.Errorf("seat ticket %d: %w", seatTicketID, ErrGroupPassengerNotFound)
+	}
+
+	lockAcquired, unlock, err := t.ticketRepository.AcquireLock(ctx, ticketLockKey(ticketID), 10*time.Second)
+	if err != nil {
+		t.logger.Error("Error acquiring cancel lock for ticket %s: %v", ticketID, err)
+		return nil, errors.New("could not contact booking service, please try again")
+	}
+	if !lockAcquired {
+		return nil, ErrTicketBusy
+	}
+	defer unlock()
+
+	// Đọc lại vé sau khi đã giữ lock
+	ticket, err := t.ticketRepository.GetTicketByID(ctx, ticketID)
+	if err != nil || ticket == nil {
+		t.logger.Error("CancelGroupPassenger: failed to get ticket %s: %v", ticketID, err)
+		return nil, fmt.Errorf("ticket %s: %w", ticketID, ErrTicketNotFound)
+	}
+	switch ticket.Status {
+	case models.TicketStatusCancelled, models.TicketStatusUsed, models.TicketStatusExpired:
+		return nil, fmt.Errorf("ticket %s has status %d: %w", ticketID, ticket.Status, ErrTicketNotCancellable)
+	}
+
+	var target *db.GetSeatTicketsByTicketIDRow
+	remaining := 0
+	for i, seatTicket := range ticket.SeatTicketsBegin {
+		if seatTicket.ID == seatTicketID {
+			target = &ticket.SeatTicketsBegin[i]
+		}
+		if seatTicket.Status != models.SeatStatusCancelled {
+			remaining++
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("seat ticket %d: %w", seatTicketID, ErrGroupPassengerNotFound)
+	}
+	if target.Status != models.SeatStatusPendingPayment && target.Status != models.SeatStatusConfirmed {
+		return nil, fmt.Errorf("seat %d has status %d: %w", target.SeatID, target.Status, ErrTicketNotCancellable)
+	}
+	remaining--
+
+	oldFare, newFare, err := t.fareService.ResizeLeg(ctx, ticket, models.TicketLegBegin, remaining)
+	if err != nil {
+		return nil, err
+	}
+	share := roundMoney(oldFare.Total - newFare.Total)
+	newPrice := roundMoney(ticket.Price - share)
+	if remaining == 0 || newPrice < 0 {
+		newPrice = 0
+	}
+
+	// Refund rule áp dụng trên phần tiền của hành khách bị huỷ
+	quote, err := t.fareService.QuoteRefund(ctx, ticket)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.PaymentStatus == models.PaymentStatusPaid {
+		quote.PaidAmount = share
+		quote.RefundAmount = roundMoney(share * quote.RefundPercent / 100)
+	}
+
+	passengerName := target.PassengerName.String
+	if passengerName == "" {
+		passengerName = ticket.Name.String
+	}
+	newFare.Adjustments = append(newFare.Adjustments, models.FareAdjustment{
+		Reason:    fmt.Sprintf("Huỷ hành khách %s (ghế %s)", passengerName, target.SeatName.String),
+		Amount:    -share,
+		CreatedAt: time.Now(),
+	})
+	fareBytes, err := json.Marshal(newFare)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode fare breakdown: %w", err)
+	}
+
+	releasePayload, _ := json.Marshal(kafkaclient.SeatUpdateEvent{TripID: ticket.TripIDBegin, SeatCount: 1})
+	params := repositories.CancelSeatTicketTransactionParams{
+		TicketID:     ticketID,
+		SeatTicketID: seatTicketID,
+		Ticket: db.UpdateTicketFareParams{
+			TicketID:      ticketID,
+			TripIDBegin:   ticket.TripIDBegin,
+			TripIDEnd:     ticket.TripIDEnd,
+			Price:         newPrice,
+			FareBreakdown: fareBytes,
+		},
+		Change:    models.StatusChange{Actor: req.Actor, Reason: req.Reason},
+		LogAction: fmt.Sprintf("PASSENGER_CANCELLED by %s: seat %d (%s), refund %.2f%% (%.2f)", req.Actor, target.SeatID, passengerName, quote.RefundPercent, quote.RefundAmount),
+		OutboxEvents: []db.CreateOutboxEventParams{
+			{ID: uuid.New(), Topic: t.cfg.Kafka.Topics.SeatsReleased.Topic, Key: ticket.TripIDBegin, Payload: releasePayload},
+		},
+	}
+
+	refundRequested := quote.RefundAmount > 0
+	if refundRequested {
+		// Hoá đơn của đoàn mang mã đơn đoàn: hoàn một phần, hoá đơn vẫn ở trạng thái đã thanh toán
+		adjustmentPayload, _ := json.Marshal(kafkaclient.FareAdjustmentEvent{
+			TicketID:    groupRef,
+			Amount:      -quote.RefundAmount,
+			Reason:      fmt.Sprintf("Huỷ hành khách %s, vé %s. %s", passengerName, ticketID, req.Reason),
+			RequestedBy: req.Actor,
+			RequestedAt: time.Now(),
+		})
+		params.OutboxEvents = append(params.OutboxEvents, db.CreateOutboxEventParams{
+			ID: uuid.New(), Topic: t.cfg.Kafka.Topics.FareAdjustments.Topic, Key: groupRef, Payload: adjustmentPayload,
+		})
+	}
+
+	ticketStatus := ticket.Status
+	if remaining == 0 {
+		ticketStatus = models.TicketStatusCancelled
+		paymentStatus := int16(models.PaymentStatusFailed)
+		if ticket.PaymentStatus == models.PaymentStatusPaid {
+			paymentStatus = models.PaymentStatusPaid
+		}
+		params.CancelTicket = &db.UpdateTicketPaymentStatusParams{
+			TicketID:      ticketID,
+			PaymentStatus: paymentStatus,
+			Status:        ticketStatus,
+		}
+	}
+
+	if err := t.ticketRepository.CancelSeatTicketInTransaction(ctx, params); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, models.ErrIllegalTransition) {
+			return nil, fmt.Errorf("seat ticket %d: %w", seatTicketID, ErrTicketNotCancellable)
+		}
+		t.logger.Error("CancelGroupPassenger: transaction failed for ticket %s: %v", ticketID, err)
+		return nil, fmt.Errorf("could not cancel passenger: %w", err)
+	}
+
+	if err := t.ticketRepository.InvalidateAvailableSeatsCache(ctx, ticket.TripIDBegin); err != nil {
+		t.logger.Error("Failed to invalidate available seats cache for trip %s: %v", ticket.TripIDBegin, err)
+	}
+	go t.ticketRepository.CleanupTicketCache(context.Background(), ticketID, []int32{target.SeatID}, ticket.TripIDBegin)
+
+	t.logger.Info("[GroupCancel] Passenger seat %d of ticket %s (group %s) cancelled by %s. Refund %.2f, requested=%t", target.SeatID, ticketID, groupRef, req.Actor, quote.RefundAmount, refundRequested)
+	return &models.GroupPassengerCancellation{
+		GroupRef:        groupRef,
+		TicketID:        ticketID,
+		SeatTicketID:    seatTicketID,
+		SeatID:          target.SeatID,
+		TicketStatus:    ticketStatus,
+		TicketPrice:     newPrice,
+		Refund:          quote,
+		RefundRequested: refundRequested,
+	}, nil
+}
+
+// paymentRef trả về mã dùng làm ticket_id của hoá đơn bên payment_service: mã đơn đoàn nếu vé thuộc đoàn, ngược lại là mã vé.
+func (t *TicketService) paymentRef(ctx context.Context, ticketID string) (string, error) {
+	groupRef, err := t.ticketRepository.GetGroupRefByTicketID(ctx, ticketID)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve group of ticket %s: %w", ticketID, err)
+	}
+	if groupRef != "" {
+		return groupRef, nil
+	}
+	return ticketID, nil
+}
+
+// canAccessGroup kiểm tra quyền: nhân viên, khách đã đặt đơn (X-User-ID) hoặc đúng số điện thoại người liên hệ.
+func canAccessGroup(actor *models.TicketActor, group *db.TicketGroup) bool {
+	if actor.IsStaff {
+		return true
+	}
+	if actor.CustomerID.Valid && group.CustomerID.Valid && actor.CustomerID.Int32 == group.CustomerID.Int32 {
+		return true
+	}
+	return actor.Phone != "" && actor.Phone == group.ContactPhone
+}
+
+func buildGroupBooking(group *db.TicketGroup, tickets []*models.TicketReturn) *models.GroupBooking {
+	booking := &models.GroupBooking{
+		GroupRef:         group.GroupRef,
+		OrganizationName: group.OrganizationName.String,
+		ContactName:      group.ContactName,
+		ContactPhone:     group.ContactPhone,
+		ContactEmail:     group.ContactEmail.String,
+		BookedBy:         group.BookedBy.String,
+		CreatedAt:        group.CreatedAt,
+		Tickets:          make([]models.GroupTicket, 0, len(tickets)),
+	}
+	for _, ticket := range tickets {
+		groupTicket := models.GroupTicket{
+			TicketID:      ticket.TicketID,
+			TripID:        ticket.TripIDBegin,
+			Status:        ticket.Status,
+			PaymentStatus: ticket.PaymentStatus,
+			Price:         ticket.Price,
+			Passengers:    make([]models.GroupPassengerSeat, 0, len(ticket.SeatTicketsBegin)),
+		}
+		for _, seatTicket := range ticket.SeatTicketsBegin {
+			passenger := models.GroupPassengerSeat{
+				SeatTicketID: seatTicket.ID,
+				SeatID:       seatTicket.SeatID,
+				SeatName:     seatTicket.SeatName.String,
+				Name:         seatTicket.PassengerName.String,
+				Phone:        seatTicket.PassengerPhone.String,
+				Status:       seatTicket.Status,
+			}
+			if passenger.Name == "" {
+				passenger.Name = ticket.Name.String
+			}
+			if seatTicket.Status != models.SeatStatusCancelled {
+				booking.PassengerCount++
+			}
+			groupTicket.Passengers = append(groupTicket.Passengers, passenger)
+		}
+		if ticket.Status != models.TicketStatusCancelled {
+			booking.TotalPrice += ticket.Price
+		}
+		booking.Tickets = append(booking.Tickets, groupTicket)
+	}
+	booking.TotalPrice = roundMoney(booking.TotalPrice)
+	return booking
+}
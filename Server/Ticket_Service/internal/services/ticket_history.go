@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+)
+
+// GetTicketHistory trả về lịch sử thao tác và chuyển trạng thái của vé (Ticket_Logs) theo thứ tự thời gian.
+func (t *TicketService) GetTicketHistory(ctx context.Context, ticketID string, actor *models.TicketActor) ([]models.TicketHistoryEntry, error) {
+	ticket, err := t.ticketRepository.GetTicketByID(ctx, ticketID)
+	if err != nil || ticket == nil {
+		t.logger.Error("History: failed to get ticket %s: %v", ticketID, err)
+		return nil, fmt.Errorf("ticket %s: %w", ticketID, ErrTicketNotFound)
+	}
+	if !actor.CanModify(ticket) {
+		return nil, ErrTicketNotOwned
+	}
+
+	logs, err := t.ticketRepository.GetTicketHistory(ctx, ticketID)
+	if err != nil {
+		t.logger.Error("History: failed to list logs of ticket %s: %v", ticketID, err)
+		return nil, err
+	}
+	history := make([]models.TicketHistoryEntry, 0, len(logs))
+	for _, log := range logs {
+		history = append(history, toTicketHistoryEntry(log))
+	}
+	return history, nil
+}
+
+func toTicketHistoryEntry(log db.TicketLog) models.TicketHistoryEntry {
+	entry := models.TicketHistoryEntry{
+		ID:        log.LogID,
+		Action:    log.Action,
+		Entity:    log.Entity.String,
+		Actor:     log.Actor.String,
+		Reason:    log.Reason.String,
+		CreatedAt: log.CreatedAt,
+	}
+	if log.SeatTicketID.Valid {
+		entry.SeatTicketID = &log.SeatTicketID.Int32
+	}
+	if log.FromStatus.Valid {
+		entry.FromStatus = &log.FromStatus.Int16
+		entry.FromState = models.StatusName(entry.Entity, log.FromStatus.Int16)
+	}
+	if log.ToStatus.Valid {
+		entry.ToStatus = &log.ToStatus.Int16
+		entry.ToState = models.StatusName(entry.Entity, log.ToStatus.Int16)
+	}
+	return entry
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"ticket-service/config"
+	"ticket-service/domain/models"
+	"ticket-service/pkg/kafkaclient"
+	"ticket-service/pkg/qrsign"
+	"ticket-service/pkg/utils"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrInvalidQRCode  = errors.New("invalid or forged QR code")
+	ErrQRCodeExpired  = errors.New("QR code has expired")
+	ErrQRCodeReplayed = errors.New("QR code has already been used")
+)
+
+// legacyQRRegex là định dạng QR cũ chưa ký, chỉ chấp nhận khi bật QR_ACCEPT_UNSIGNED.
+var legacyQRRegex = regexp.MustCompile(`^TICKET:(.*)-SEAT:(\d+)$`)
+
+// ITicketQRService phát hành và xác thực nội dung QR check-in của vé.
+type ITicketQRService interface {
+	IssueSeatQRs(ctx context.Context, ticketID, tripID string, seatIDs []int32) ([]kafkaclient.TicketDetailForQR, error)
+	Verify(payload string) (*qrsign.Claims, error)
+	VerifyAt(payload string, at time.Time) (*qrsign.Claims, error)
+	SignDocument(document []byte) (keyID, signature string)
+	MarkUsed(ctx context.Context, claims *qrsign.Claims) error
+	ReleaseUsed(ctx context.Context, claims *qrsign.Claims)
+}
+
+type TicketQRService struct {
+	signer      *qrsign.Signer
+	redisClient *redis.Client
+	tripDetails func(ctx context.Context, tripID string) (*models.TripInfo, error)
+	cfg         config.Config
+	logger      utils.Logger
+}
+
+func NewTicketQRService(signer *qrsign.Signer, redisClient *redis.Client, tripDetails func(ctx context.Context, tripID string) (*models.TripInfo, error), cfg config.Config, logger utils.Logger) ITicketQRService {
+	return &TicketQRService{
+		signer:      signer,
+		redisClient: redisClient,
+		tripDetails: tripDetails,
+		cfg:         cfg,
+		logger:      logger,
+	}
+}
+
+func qrUsedKey(nonce string) string {
+	return fmt.Sprintf("qr:used:%s", nonce)
+}
+
+// IssueSeatQRs ký QR cho từng ghế của vé trên một chuyến; QR hết hạn sau giờ khởi hành của chuyến.
+func (s *TicketQRService) IssueSeatQRs(ctx context.Context, ticketID, tripID string, seatIDs []int32) ([]kafkaclient.TicketDetailForQR, error) {
+	expiresAt := s.expiryFor(ctx, tripID)
+	details := make([]kafkaclient.TicketDetailForQR, 0, len(seatIDs))
+	for _, seatID := range seatIDs {
+		content, err := s.signer.Sign(qrsign.Claims{
+			TicketID:  ticketID,
+			SeatID:    seatID,
+			TripID:    tripID,
+			ExpiresAt: expiresAt.Unix(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign QR for ticket %s seat %d: %w", ticketID, seatID, err)
+		}
+		details = append(details, kafkaclient.TicketDetailForQR{SeatID: seatID, QRContent: content})
+	}
+	return details, nil
+}
+
+func (s *TicketQRService) expiryFor(ctx context.Context, tripID string) time.Time {
+	trip, err := s.tripDetails(ctx, tripID)
+	if err == nil && trip == nil {
+		err = ErrTripNotFound
+	}
+	if err == nil {
+		var departureAt time.Time
+		if departureAt, err = trip.DepartureAt(); err == nil {
+			return departureAt.Add(s.cfg.QR.ExpiryAfterTrip)
+		}
+	}
+	s.logger.Info("Warning: could not resolve departure time of trip %s (%v), QR uses default TTL", tripID, err)
+	return time.Now().Add(s.cfg.QR.DefaultTTL)
+}
+
+// Verify kiểm tra chữ ký và hạn của nội dung QR quét được.
+func (s *TicketQRService) Verify(payload string) (*qrsign.Claims, error) {
+	return s.VerifyAt(payload, time.Now())
+}
+
+// VerifyAt xét hạn của QR tại thời điểm at, dùng cho lượt quét offline đồng bộ lên muộn.
+func (s *TicketQRService) VerifyAt(payload string, at time.Time) (*qrsign.Claims, error) {
+	if !qrsign.IsSigned(payload) {
+		return s.parseLegacy(payload)
+	}
+	claims, err := s.signer.VerifyAt(payload, at)
+	if err != nil {
+		if errors.Is(err, qrsign.ErrExpired) {
+			return nil, fmt.Errorf("%v: %w", err, ErrQRCodeExpired)
+		}
+		s.logger.Info("Warning: rejected QR payload: %v", err)
+		return nil, fmt.Errorf("%v: %w", err, ErrInvalidQRCode)
+	}
+	return claims, nil
+}
+
+func (s *TicketQRService) parseLegacy(payload string) (*qrsign.Claims, error) {
+	if !s.cfg.QR.AcceptUnsigned {
+		return nil, fmt.Errorf("unsigned QR payload: %w", ErrInvalidQRCode)
+	}
+	matches := legacyQRRegex.FindStringSubmatch(payload)
+	if len(matches) != 3 {
+		return nil, fmt.Errorf("expected a signed QR payload: %w", ErrInvalidQRCode)
+	}
+	seatID, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid seat ID in QR: %w", ErrInvalidQRCode)
+	}
+	s.logger.Info("Warning: accepted unsigned legacy QR for ticket %s", matches[1])
+	return &qrsign.Claims{TicketID: matches[1], SeatID: int32(seatID)}, nil
+}
+
+// SignDocument ký tài liệu phát cho thiết bị (vd: manifest chuyến) bằng khoá ký QR đang dùng.
+func (s *TicketQRService) SignDocument(document []byte) (keyID, signature string) {
+	return s.signer.SignDocument(document)
+}
+
+// MarkUsed đánh dấu QR đã được quét; QR đã đánh dấu trả về ErrQRCodeReplayed.
+// QR cũ chưa ký không có nonce nên chỉ dựa vào trạng thái ghế để chặn check-in lặp.
+func (s *TicketQRService) MarkUsed(ctx context.Context, claims *qrsign.Claims) error {
+	if claims.Nonce == "" {
+		return nil
+	}
+	ttl := time.Until(time.Unix(claims.ExpiresAt, 0))
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	ok, err := s.redisClient.SetNX(ctx, qrUsedKey(claims.Nonce), claims.TicketID, ttl).Result()
+	if err != nil {
+		return fmt.Errorf("could not record QR usage: %w", err)
+	}
+	if !ok {
+		return ErrQRCodeReplayed
+	}
+	return nil
+}
+
+// ReleaseUsed bỏ đánh dấu khi check-in thất bại để QR vẫn dùng được ở lần quét hợp lệ sau.
+func (s *TicketQRService) ReleaseUsed(ctx context.Context, claims *qrsign.Claims) {
+	if claims.Nonce == "" {
+		return
+	}
+	if err := s.redisClient.Del(ctx, qrUsedKey(claims.Nonce)).Err(); err != nil {
+		s.logger.Error("Failed to release QR nonce of ticket %s: %v", claims.TicketID, err)
+	}
+}
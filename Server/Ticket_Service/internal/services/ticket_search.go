@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+	"ticket-service/pkg/utils"
+	"time"
+)
+
+const (
+	defaultTicketSearchLimit = 20
+	maxTicketSearchLimit     = 100
+)
+
+// ticketSearchCursor là vị trí dòng cuối của trang trước, mã hoá base64 thành next_cursor.
+// Cursor gắn với cách sắp xếp: đổi sort mà dùng lại cursor cũ bị từ chối.
+type ticketSearchCursor struct {
+	SortBy      string    `json:"s"`
+	SortDesc    bool      `json:"d"`
+	BookingTime time.Time `json:"t,omitempty"`
+	Price       float64   `json:"p,omitempty"`
+	TicketID    string    `json:"id"`
+}
+
+// SearchTickets tìm vé cho nhân viên quầy theo bộ lọc, phân trang bằng cursor.
+// Trả về models.ErrInvalidTicketSearch khi tham số hoặc cursor không hợp lệ.
+func (t *TicketService) SearchTickets(ctx context.Context, filter *models.TicketSearchFilter) (*models.TicketSearchResult, error) {
+	switch filter.SortBy {
+	case "":
+		filter.SortBy = models.TicketSortBookingTime
+	case models.TicketSortBookingTime, models.TicketSortPrice:
+	default:
+		return nil, fmt.Errorf("%w: sort must be %q or %q", models.ErrInvalidTicketSearch, models.TicketSortBookingTime, models.TicketSortPrice)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultTicketSearchLimit
+	}
+	if filter.Limit > maxTicketSearchLimit {
+		filter.Limit = maxTicketSearchLimit
+	}
+	if filter.BookedFrom != nil && filter.BookedTo != nil && !filter.BookedFrom.Before(*filter.BookedTo) {
+		return nil, fmt.Errorf("%w: from must be before to", models.ErrInvalidTicketSearch)
+	}
+
+	countParams := db.CountSearchTicketsParams{
+		Phone:          utils.ToNullString(filter.Phone),
+		Email:          utils.ToNullString(filter.Email),
+		Name:           utils.ToNullString(filter.Name),
+		TripID:         utils.ToNullString(filter.TripID),
+		BookedFrom:     toNullTime(filter.BookedFrom),
+		BookedTo:       toNullTime(filter.BookedTo),
+		Status:         toNullInt16(filter.Status),
+		PaymentStatus:  toNullInt16(filter.PaymentStatus),
+		BookingChannel: toNullInt16(filter.BookingChannel),
+	}
+	searchParams := db.SearchTicketsParams{
+		Phone:          countParams.Phone,
+		Email:          countParams.Email,
+		Name:           countParams.Name,
+		TripID:         countParams.TripID,
+		BookedFrom:     countParams.BookedFrom,
+		BookedTo:       countParams.BookedTo,
+		Status:         countParams.Status,
+		PaymentStatus:  countParams.PaymentStatus,
+		BookingChannel: countParams.BookingChannel,
+		SortBy:         filter.SortBy,
+		SortDesc:       filter.SortDesc,
+		RowLimit:       int32(filter.Limit + 1), // Lấy dư một dòng để biết còn trang sau
+	}
+	if filter.Cursor != "" {
+		cursor, err := decodeTicketSearchCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.SortBy != filter.SortBy || cursor.SortDesc != filter.SortDesc {
+			return nil, fmt.Errorf("%w: cursor was issued for a different sort order", models.ErrInvalidTicketSearch)
+		}
+		searchParams.CursorID = sql.NullString{String: cursor.TicketID, Valid: true}
+		searchParams.CursorTime = sql.NullTime{Time: cursor.BookingTime, Valid: true}
+		searchParams.CursorPrice = sql.NullFloat64{Float64: cursor.Price, Valid: true}
+	}
+
+	total, err := t.ticketRepository.CountSearchTickets(ctx, countParams)
+	if err != nil {
+		t.logger.Error("Error counting tickets for search: %v", err)
+		return nil, errors.New("failed to retrieve ticket count")
+	}
+	tickets, err := t.ticketRepository.SearchTickets(ctx, searchParams)
+	if err != nil {
+		t.logger.Error("Error searching tickets: %v", err)
+		return nil, errors.New("failed to retrieve tickets")
+	}
+
+	result := &models.TicketSearchResult{Tickets: tickets, Total: total, Limit: filter.Limit}
+	if len(tickets) > filter.Limit {
+		result.Tickets = tickets[:filter.Limit]
+		last := result.Tickets[filter.Limit-1]
+		result.NextCursor = encodeTicketSearchCursor(ticketSearchCursor{
+			SortBy:      filter.SortBy,
+			SortDesc:    filter.SortDesc,
+			BookingTime: last.BookingTime,
+			Price:       last.Price,
+			TicketID:    last.TicketID,
+		})
+	}
+	t.ticketRepository.AttachTripDetails(ctx, result.Tickets)
+	return result, nil
+}
+
+func encodeTicketSearchCursor(cursor ticketSearchCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeTicketSearchCursor(s string) (*ticketSearchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", models.ErrInvalidTicketSearch)
+	}
+	var cursor ticketSearchCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.TicketID == "" {
+		return nil, fmt.Errorf("%w: malformed cursor", models.ErrInvalidTicketSearch)
+	}
+	return &cursor, nil
+}
+
+func toNullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func toNullInt16(v *int16) sql.NullInt16 {
+	if v == nil {
+		return sql.NullInt16{}
+	}
+	return sql.NullInt16{Int16: *v, Valid: true}
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+	"ticket-service/internal/repositories"
+	"ticket-service/pkg/kafkaclient"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSeatChangeNotAllowed      = errors.New("seats of this ticket can no longer be changed")
+	ErrSeatsUnavailable          = errors.New("one or more selected seats are not available")
+	ErrDifferentRoute            = errors.New("new trip is not on the same route")
+	ErrAdditionalPaymentRequired = errors.New("the new fare is higher, please change seats at the counter to pay the difference")
+)
+
+// ChangeSeats đổi ghế (hoặc đổi sang chuyến khác cùng tuyến) cho một chiều của vé:
+// trả ghế cũ, giữ ghế mới, tính lại giá và yêu cầu payment_service thu/hoàn phần chênh lệch.
+func (t *TicketService) ChangeSeats(ctx context.Context, ticketID string, req *models.ChangeSeatsRequest) (*models.ChangeSeatsResult, error) {
+	if req.Leg == "" {
+		req.Leg = models.TicketLegBegin
+	}
+	if req.Leg != models.TicketLegBegin && req.Leg != models.TicketLegEnd {
+		return nil, fmt.Errorf("invalid leg %q", req.Leg)
+	}
+	newSeatIDs := uniqueSeatIDs(req.NewSeatIDs)
+	if len(newSeatIDs) == 0 {
+		return nil, errors.New("at least one seat must be selected")
+	}
+
+	lockAcquired, unlock, err := t.ticketRepository.AcquireLock(ctx, ticketLockKey(ticketID), 10*time.Second)
+	if err != nil {
+		t.logger.Error("Error acquiring lock for ticket %s: %v", ticketID, err)
+		return nil, errors.New("could not contact booking service, please try again")
+	}
+	if !lockAcquired {
+		return nil, ErrTicketBusy
+	}
+	defer unlock()
+
+	ticket, err := t.ticketRepository.GetTicketByID(ctx, ticketID)
+	if err != nil || ticket == nil {
+		t.logger.Error("ChangeSeats: failed to get ticket %s: %v", ticketID, err)
+		return nil, fmt.Errorf("ticket %s: %w", ticketID, ErrTicketNotFound)
+	}
+	if !req.CanModify(ticket) {
+		return nil, ErrTicketNotOwned
+	}
+	switch ticket.Status {
+	case models.TicketStatusCancelled, models.TicketStatusUsed, models.TicketStatusExpired:
+		return nil, fmt.Errorf("ticket %s has status %d: %w", ticketID, ticket.Status, ErrSeatChangeNotAllowed)
+	}
+
+	oldTripID := ticket.TripIDBegin
+	oldSeatTickets := ticket.SeatTicketsBegin
+	if req.Leg == models.TicketLegEnd {
+		if ticket.Type != 1 {
+			return nil, fmt.Errorf("ticket %s is not a round-trip ticket: %w", ticketID, ErrSeatChangeNotAllowed)
+		}
+		oldTripID = ticket.TripIDEnd.String
+		oldSeatTickets = ticket.SeatTicketsEnd
+	}
+	oldSeatTickets = activeSeatTickets(oldSeatTickets)
+	if len(oldSeatTickets) == 0 {
+		return nil, fmt.Errorf("ticket %s has no seats on leg %s: %w", ticketID, req.Leg, ErrSeatChangeNotAllowed)
+	}
+	oldSeatIDs := make([]int32, 0, len(oldSeatTickets))
+	for _, st := range oldSeatTickets {
+		if st.Status == models.SeatStatusCheckedIn {
+			return nil, fmt.Errorf("seat %d already checked in: %w", st.SeatID, ErrSeatChangeNotAllowed)
+		}
+		oldSeatIDs = append(oldSeatIDs, st.SeatID)
+	}
+	// Hành khách của vé đoàn đi theo ghế mới theo thứ tự chọn ghế
+	passengers := make(map[int32]models.GroupPassenger)
+	for i, seatID := range newSeatIDs {
+		if i < len(oldSeatTickets) && oldSeatTickets[i].PassengerName.Valid {
+			passengers[seatID] = models.GroupPassenger{
+				SeatID: seatID,
+				Name:   oldSeatTickets[i].PassengerName.String,
+				Phone:  oldSeatTickets[i].PassengerPhone.String,
+			}
+		}
+	}
+	// Ghế mới được giữ trên cùng đoạn đường (điểm đón/trả) với ghế cũ
+	segment := models.SeatSegment{FromStop: oldSeatTickets[0].FromStop, ToStop: oldSeatTickets[0].ToStop}
+
+	newTripID := req.NewTripID
+	if newTripID == "" {
+		newTripID = oldTripID
+	}
+	if err := t.checkTripChange(ctx, oldTripID, newTripID); err != nil {
+		return nil, err
+	}
+
+	// Dùng chung lock ghế với luồng đặt vé
+	for _, seatID := range newSeatIDs {
+		lockAcquired, unlockSeat, err := t.ticketRepository.AcquireLock(ctx, seatLockKey(seatID), 5*time.Second)
+		if err != nil {
+			t.logger.Error("Error acquiring lock for seat %d: %v", seatID, err)
+			return nil, errors.New("could not contact booking service, please try again")
+		}
+		if !lockAcquired {
+			return nil, errors.New("booking for this trip is currently busy, please try again shortly")
+		}
+		defer unlockSeat()
+	}
+
+	availableSeatRows, err := t.ticketRepository.AreSeatsAvailable(ctx, newSeatIDs, segment, 0)
+	if err != nil {
+		t.logger.Error("Database error during seat validation for trip %s: %v", newTripID, err)
+		return nil, errors.New("error checking seat availability")
+	}
+	if len(availableSeatRows) != len(newSeatIDs) {
+		return nil, fmt.Errorf("one or more selected seats do not exist: %w", ErrSeatsUnavailable)
+	}
+	for _, seat := range availableSeatRows {
+		// Ghế đang thuộc chính vé này (cùng chuyến) vẫn được chọn lại
+		if seat.IsBooked && !(newTripID == oldTripID && containsSeat(oldSeatIDs, seat.ID)) {
+			return nil, fmt.Errorf("seat %d is already booked or held by another user: %w", seat.ID, ErrSeatsUnavailable)
+		}
+	}
+
+	oldFare, newFare, err := t.fareService.RepriceLeg(ctx, ticket, req.Leg, newTripID, len(newSeatIDs))
+	if err != nil {
+		return nil, err
+	}
+	difference := roundMoney(newFare.Total - oldFare.Total)
+
+	var logActions []string
+	logActions = append(logActions, fmt.Sprintf("SEAT_CHANGE by %s: trip %s seats %v -> trip %s seats %v", req.Actor, oldTripID, oldSeatIDs, newTripID, newSeatIDs))
+
+	// Chuyến cũ bị dời giờ: lần đổi sang chuyến khác đầu tiên không thu thêm tiền, trừ khi khách đổi sang nhiều ghế hơn
+	freeRebooking := false
+	if newTripID != oldTripID && len(newSeatIDs) <= len(oldSeatIDs) {
+		rebookingOffer, err := t.ticketRepository.GetOpenRebookingOffer(ctx, ticketID, oldTripID)
+		if err != nil {
+			t.logger.Error("ChangeSeats: %v", err)
+			return nil, fmt.Errorf("could not change seats: %w", err)
+		}
+		freeRebooking = rebookingOffer != nil
+	}
+	if freeRebooking && difference > 0 {
+		newFare.Adjustments = append(newFare.Adjustments, models.FareAdjustment{
+			Reason:    fmt.Sprintf("Miễn phí đổi chuyến do chuyến %s bị dời giờ (miễn %.2f)", oldTripID, difference),
+			Amount:    0,
+			CreatedAt: time.Now(),
+		})
+		logActions = append(logActions, fmt.Sprintf("FREE_REBOOKING by %s: trip %s rescheduled, waived %.2f", req.Actor, oldTripID, difference))
+		newFare.Total = oldFare.Total
+		difference = 0
+	} else if freeRebooking {
+		logActions = append(logActions, fmt.Sprintf("FREE_REBOOKING by %s: trip %s rescheduled", req.Actor, oldTripID))
+	}
+
+	newPrice := roundMoney(ticket.Price + difference)
+	if newPrice < 0 {
+		newPrice = 0
+	}
+
+	isPaid := ticket.PaymentStatus == models.PaymentStatusPaid
+	if isPaid && difference > 0 && !req.IsStaff {
+		return nil, ErrAdditionalPaymentRequired
+	}
+
+	outboxEvents := []db.CreateOutboxEventParams{}
+	releasePayload, _ := json.Marshal(kafkaclient.SeatUpdateEvent{TripID: oldTripID, SeatCount: len(oldSeatIDs)})
+	outboxEvents = append(outboxEvents, db.CreateOutboxEventParams{
+		ID: uuid.New(), Topic: t.cfg.Kafka.Topics.SeatsReleased.Topic, Key: oldTripID, Payload: releasePayload,
+	})
+	reservePayload, _ := json.Marshal(kafkaclient.SeatUpdateEvent{TripID: newTripID, SeatCount: len(newSeatIDs)})
+	outboxEvents = append(outboxEvents, db.CreateOutboxEventParams{
+		ID: uuid.New(), Topic: t.cfg.Kafka.Topics.SeatsReserved.Topic, Key: newTripID, Payload: reservePayload,
+	})
+
+	adjustmentRequested := false
+	if difference != 0 {
+		newFare.Adjustments = append(newFare.Adjustments, models.FareAdjustment{
+			Reason:    fmt.Sprintf("Đổi %s: chuyến %s -> %s", req.Leg, oldTripID, newTripID),
+			Amount:    difference,
+			CreatedAt: time.Now(),
+		})
+		logActions = append(logActions, fmt.Sprintf("FARE_ADJUSTMENT by %s: %.2f -> %.2f (%+.2f)", req.Actor, ticket.Price, newPrice, difference))
+
+		// Vé chưa thanh toán thì chỉ cần cập nhật giá, khách trả theo giá mới.
+		if isPaid {
+			// Vé đoàn được thanh toán trên hoá đơn chung mang mã đoàn
+			invoiceRef, err := t.paymentRef(ctx, ticketID)
+			if err != nil {
+				t.logger.Error("ChangeSeats: %v", err)
+				return nil, fmt.Errorf("could not change seats: %w", err)
+			}
+			adjustmentRequested = true
+			event := kafkaclient.FareAdjustmentEvent{
+				TicketID:    invoiceRef,
+				Amount:      difference,
+				Reason:      fmt.Sprintf("Đổi ghế vé %s. %s", ticketID, req.Reason),
+				RequestedBy: req.Actor,
+				RequestedAt: time.Now(),
+			}
+			if difference > 0 {
+				event.CollectedBy = req.Actor
+			}
+			adjustmentPayload, _ := json.Marshal(event)
+			outboxEvents = append(outboxEvents, db.CreateOutboxEventParams{
+				ID: uuid.New(), Topic: t.cfg.Kafka.Topics.FareAdjustments.Topic, Key: invoiceRef, Payload: adjustmentPayload,
+			})
+		}
+	}
+
+	fareBytes, err := json.Marshal(newFare)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode fare breakdown: %w", err)
+	}
+
+	updateTicket := db.UpdateTicketFareParams{
+		TicketID:      ticketID,
+		TripIDBegin:   ticket.TripIDBegin,
+		TripIDEnd:     ticket.TripIDEnd,
+		Price:         newPrice,
+		FareBreakdown: fareBytes,
+	}
+	if req.Leg == models.TicketLegEnd {
+		updateTicket.TripIDEnd.String = newTripID
+	} else {
+		updateTicket.TripIDBegin = newTripID
+	}
+
+	params := repositories.ChangeSeatsTransactionParams{
+		TicketID:       ticketID,
+		ReleaseSeatIDs: oldSeatIDs,
+		ClaimSeatIDs:   newSeatIDs,
+		NewTripID:      newTripID,
+		Segment:        segment,
+		SeatStatus:     oldSeatTickets[0].Status,
+		Passengers:     passengers,
+		Ticket:         updateTicket,
+		LogActions:     logActions,
+		OutboxEvents:   outboxEvents,
+	}
+	if freeRebooking {
+		params.RebookingOfferTripID = oldTripID
+	}
+	if err := t.ticketRepository.ChangeSeatsInTransaction(ctx, params); err != nil {
+		t.logger.Error("ChangeSeats: transaction failed for ticket %s: %v", ticketID, err)
+		return nil, fmt.Errorf("could not change seats: %w", err)
+	}
+
+	if err := t.ticketRepository.InvalidateAvailableSeatsCache(ctx, oldTripID); err != nil {
+		t.logger.Error("Failed to invalidate available seats cache for trip %s: %v", oldTripID, err)
+	}
+	if err := t.ticketRepository.InvalidateAvailableSeatsCache(ctx, newTripID); err != nil {
+		t.logger.Error("Failed to invalidate available seats cache for trip %s: %v", newTripID, err)
+	}
+	go t.ticketRepository.CleanupTicketCache(context.Background(), ticketID, nil, oldTripID)
+
+	t.logger.Info("[ChangeSeats] Ticket %s: trip %s %v -> trip %s %v, difference %.2f", ticketID, oldTripID, oldSeatIDs, newTripID, newSeatIDs, difference)
+	return &models.ChangeSeatsResult{
+		TicketID:            ticketID,
+		Leg:                 req.Leg,
+		OldTripID:           oldTripID,
+		NewTripID:           newTripID,
+		OldSeatIDs:          oldSeatIDs,
+		NewSeatIDs:          newSeatIDs,
+		OldPrice:            ticket.Price,
+		NewPrice:            newPrice,
+		FareDifference:      difference,
+		AdjustmentRequested: adjustmentRequested,
+		FreeRebooking:       freeRebooking,
+		Fare:                newFare,
+	}, nil
+}
+
+// checkTripChange kiểm tra chuyến cũ chưa khởi hành và chuyến mới (nếu khác) cùng tuyến, chưa khởi hành.
+func (t *TicketService) checkTripChange(ctx context.Context, oldTripID, newTripID string) error {
+	oldTrip, err := t.tripClient.GetTrip(ctx, oldTripID)
+	if err != nil {
+		return fmt.Errorf("could not fetch trip %s: %w", oldTripID, err)
+	}
+	if oldTrip == nil {
+		return fmt.Errorf("trip %s: %w", oldTripID, ErrTripNotFound)
+	}
+	if err := ensureNotDeparted(oldTrip); err != nil {
+		return err
+	}
+	if newTripID == oldTripID {
+		return nil
+	}
+
+	newTrip, err := t.tripClient.GetTrip(ctx, newTripID)
+	if err != nil {
+		return fmt.Errorf("could not fetch trip %s: %w", newTripID, err)
+	}
+	if newTrip == nil {
+		return fmt.Errorf("trip %s: %w", newTripID, ErrTripNotFound)
+	}
+	if oldTrip.Route == nil || newTrip.Route == nil || oldTrip.Route.ID != newTrip.Route.ID {
+		return ErrDifferentRoute
+	}
+	return ensureNotDeparted(newTrip)
+}
+
+func ensureNotDeparted(trip *models.TripInfo) error {
+	departureAt, err := trip.DepartureAt()
+	if err != nil {
+		return err
+	}
+	if !time.Now().Before(departureAt) {
+		return fmt.Errorf("trip %s departed at %s: %w", trip.ID, departureAt.Format(time.RFC3339), ErrTripDeparted)
+	}
+	return nil
+}
+
+// activeSeatTickets bỏ các ghế đã huỷ riêng (hành khách rời đoàn) khỏi danh sách ghế của một chiều.
+func activeSeatTickets(seatTickets []db.GetSeatTicketsByTicketIDRow) []db.GetSeatTicketsByTicketIDRow {
+	result := make([]db.GetSeatTicketsByTicketIDRow, 0, len(seatTickets))
+	for _, seatTicket := range seatTickets {
+		if seatTicket.Status != models.SeatStatusCancelled {
+			result = append(result, seatTicket)
+		}
+	}
+	return result
+}
+
+func uniqueSeatIDs(seatIDs []int32) []int32 {
+	seen := make(map[int32]bool, len(seatIDs))
+	result := make([]int32, 0, len(seatIDs))
+	for _, id := range seatIDs {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func containsSeat(seatIDs []int32, seatID int32) bool {
+	for _, id := range seatIDs {
+		if id == seatID {
+			return true
+		}
+	}
+	return false
+}
@@ -6,8 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"math"
 
 	// "strconv" // No longer needed here if payment logic is removed
 	"ticket-service/config"
@@ -15,7 +14,9 @@ import (
 	"ticket-service/internal/db"
 	"ticket-service/internal/repositories"
 	"ticket-service/pkg/kafkaclient"
+	"ticket-service/pkg/tripclient"
 	"ticket-service/pkg/utils"
+	"ticket-service/pkg/waitingroom"
 	"ticket-service/pkg/websocket"
 	"time"
 
@@ -24,8 +25,66 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// Helper to convert string to sql.NullString (already present in manager_ticket_service.go, ensure accessible or duplicate)
-const pendingWsConnectionsKey = "pending_ws_connections"
+// seatLockKey là key Redis lock cho một ghế khi đặt/đổi ghế.
+func seatLockKey(seatID int32) string {
+	return fmt.Sprintf("trip-lock:%s", string(rune(seatID)))
+}
+
+// ticketLockKey là key Redis lock cho các thao tác sửa vé (huỷ, đổi ghế).
+func ticketLockKey(ticketID string) string {
+	return fmt.Sprintf("ticket-lock:%s", ticketID)
+}
+
+// SeatSegmentResolver quy đổi điểm đón/trả client gửi (station id, <= 0 là cả chuyến) của một chuyến
+// sang đoạn đường giữ ghế theo thứ tự điểm dừng (xem TicketService.ResolveSeatSegment).
+type SeatSegmentResolver func(ctx context.Context, tripID string, pickupLocation, dropoffLocation int32) (models.SeatSegment, error)
+
+// ResolveSeatSegment lấy điểm dừng của chuyến từ trip-service rồi quy đổi điểm đón/trả sang đoạn đường giữ ghế.
+func (t *TicketService) ResolveSeatSegment(ctx context.Context, tripID string, pickupLocation, dropoffLocation int32) (models.SeatSegment, error) {
+	if pickupLocation <= 0 && dropoffLocation <= 0 {
+		return models.FullTripSegment, nil
+	}
+	trip, err := t.segmentTrip(ctx, tripID)
+	if err != nil {
+		return models.SeatSegment{}, err
+	}
+	return trip.SeatSegment(pickupLocation, dropoffLocation)
+}
+
+// segmentTrip lấy chuyến (kèm danh sách điểm dừng) để quy đổi điểm đón/trả.
+func (t *TicketService) segmentTrip(ctx context.Context, tripID string) (*models.TripInfo, error) {
+	trip, err := t.tripClient.GetTrip(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch trip %s: %w", tripID, err)
+	}
+	if trip == nil {
+		return nil, fmt.Errorf("trip %s: %w", tripID, ErrTripNotFound)
+	}
+	return trip, nil
+}
+
+// ticketSegments trả về đoạn đường giữ ghế của chiều đi và chiều về (vé một chiều: chiều về là cả chuyến, không dùng tới).
+func (t *TicketService) ticketSegments(ctx context.Context, input *models.TicketInput) (models.SeatSegment, models.SeatSegment, error) {
+	tripBegin, err := t.segmentTrip(ctx, input.TripIDBegin)
+	if err != nil {
+		return models.SeatSegment{}, models.SeatSegment{}, err
+	}
+	segmentBegin, err := input.SegmentBegin(tripBegin)
+	if err != nil {
+		return models.SeatSegment{}, models.SeatSegment{}, err
+	}
+	segmentEnd := models.FullTripSegment
+	if input.TicketType == 1 {
+		tripEnd, err := t.segmentTrip(ctx, input.TripIDEnd)
+		if err != nil {
+			return models.SeatSegment{}, models.SeatSegment{}, fmt.Errorf("return trip: %w", err)
+		}
+		if segmentEnd, err = input.SegmentEnd(tripEnd); err != nil {
+			return models.SeatSegment{}, models.SeatSegment{}, fmt.Errorf("return trip: %w", err)
+		}
+	}
+	return segmentBegin, segmentEnd, nil
+}
 
 type ITicketService interface {
 	GetTicketByID(ctx context.Context, ticketID string) (*models.TicketReturn, error)
@@ -34,15 +93,34 @@ type ITicketService interface {
 	// Updated CreateTicket to remove PaymentInitiationInfo
 	CreateTicket(ctx context.Context, input *models.TicketInput, customerID sql.NullInt32) (*db.Ticket, error)
 	CreateTicketByStaff(ctx context.Context, input *models.TicketInput, staffID string) (*db.Ticket, error)
-	GetAvailableSeatsByTripID(ctx context.Context, tripID string) ([]models.SeatReturn, error)
+	GetAvailableSeatsByTripID(ctx context.Context, tripID string, segment models.SeatSegment) ([]models.SeatReturn, error)
+	ResolveSeatSegment(ctx context.Context, tripID string, pickupLocation, dropoffLocation int32) (models.SeatSegment, error)
 	ExtendSeatHoldTime(ctx context.Context, ticketID string, seatIDs []int32, extendMinutes int) error
 	ReleaseHeldSeats(ctx context.Context, ticketID string, seatIDs []int32) error
 	UpdateTicketPaymentStatus(ctx context.Context, ticketID string, paymentStatus int16, ticketStatus int16, tripID string) error
 
 	CreateTicketAndNotify(ctx context.Context, input *models.TicketInput, customerID sql.NullInt32, bookingID string)
 	CancelTicket(ctx context.Context, ticketID string) error
-	QueueNewBooking(ctx context.Context, bookingID string, input *models.TicketInput, customerID sql.NullInt32) error
+	CancelTicketByCustomer(ctx context.Context, ticketID string, req *models.CancelTicketRequest) (*models.CancellationResult, error)
+	ChangeSeats(ctx context.Context, ticketID string, req *models.ChangeSeatsRequest) (*models.ChangeSeatsResult, error)
+	QueueNewBooking(ctx context.Context, bookingID string, input *models.TicketInput, customerID sql.NullInt32) (*models.WaitingRoomPosition, error)
+	AdmitWaitingBookings(ctx context.Context) (int, error)
+	GetWaitingRoomPosition(ctx context.Context, bookingID string) (*models.WaitingRoomPosition, error)
+	ProcessBookingRequest(ctx context.Context, event kafkaclient.BookingRequestEvent) (*models.BookingRequestStatus, error)
+	TrackBookingRequest(ctx context.Context, bookingID string, customerID sql.NullInt32) (*models.BookingRequestStatus, error)
+	GetBookingRequestStatus(ctx context.Context, bookingID string) (*models.BookingRequestStatus, error)
+	ExpireUnconnectedBookingRequests(ctx context.Context) (int, error)
 	GetAllTickets(ctx context.Context, page, limit int) (*models.PaginatedTickets, error)
+	SearchTickets(ctx context.Context, filter *models.TicketSearchFilter) (*models.TicketSearchResult, error)
+	QuoteFare(ctx context.Context, input *models.TicketInput) (*models.FareBreakdown, error)
+
+	CreateGroupBooking(ctx context.Context, req *models.CreateGroupBookingRequest) (*models.GroupBooking, error)
+	GetGroupBooking(ctx context.Context, groupRef string, actor *models.TicketActor) (*models.GroupBooking, error)
+	GetTicketHistory(ctx context.Context, ticketID string, actor *models.TicketActor) ([]models.TicketHistoryEntry, error)
+	CancelGroupPassenger(ctx context.Context, groupRef string, seatTicketID int32, req *models.CancelGroupPassengerRequest) (*models.GroupPassengerCancellation, error)
+
+	HandleTripCancelled(ctx context.Context, event models.TripCancellation) (*models.TripCancellationResult, error)
+	HandleTripRescheduled(ctx context.Context, event models.TripReschedule) (int, error)
 }
 
 type TicketService struct {
@@ -52,9 +130,13 @@ type TicketService struct {
 	cfg              config.Config
 	publisher        *kafkaclient.Publisher // << UPDATED
 	redisClient      *redis.Client
+	fareService      IFareService
+	qrService        ITicketQRService
+	waitingRoom      *waitingroom.Room
+	tripClient       tripclient.Client
 }
 
-func NewTicketService(ticketRepository repositories.TicketRepositoryInterface, utils *utils.Utils, logger utils.Logger, cfg config.Config, publisher *kafkaclient.Publisher, redisClient *redis.Client) ITicketService {
+func NewTicketService(ticketRepository repositories.TicketRepositoryInterface, utils *utils.Utils, logger utils.Logger, cfg config.Config, publisher *kafkaclient.Publisher, redisClient *redis.Client, fareService IFareService, qrService ITicketQRService, waitingRoom *waitingroom.Room, tripClient tripclient.Client) ITicketService {
 	return &TicketService{
 		ticketRepository: ticketRepository,
 		utils:            utils,
@@ -62,6 +144,10 @@ func NewTicketService(ticketRepository repositories.TicketRepositoryInterface, u
 		cfg:              cfg,
 		publisher:        publisher, // << UPDATED
 		redisClient:      redisClient,
+		fareService:      fareService,
+		qrService:        qrService,
+		waitingRoom:      waitingRoom,
+		tripClient:       tripClient,
 	}
 }
 
@@ -96,27 +182,7 @@ func (t *TicketService) GetTicketByCustomer(ctx context.Context, customerID sql.
 		return nil, err
 	}
 
-	// // Iterate through tickets and enrich them with trip details
-	// for i, ticket := range tickets { // Use index to modify the original slice element if tickets are values
-	// 	if ticket.TripID != "" {
-	// 		tripDetails, err := GetTripDetails(ticket.TripID) // Calling the new function
-	// 		if err != nil {
-	// 			// Decide on error handling:
-	// 			// 1. Fail the entire request (return nil, err)
-	// 			// 2. Log the error and continue (tripDetails will be nil for this ticket)
-	// 			// 3. Return partial data with an error indicator
-	// 			// For now, let's log and continue, so TripDetails might be nil.
-	// 			t.logger.Error("Failed to retrieve details for TripID %s: %v", ticket.TripID, err)
-	// 			// tickets[i].TripDetails will remain nil or you can assign an empty struct
-	// 		}
-	// 		// Ensure you are modifying the actual element in the slice if `tickets` is a slice of values.
-	// 		// If `tickets` is a slice of pointers (`[]*models.TicketReturn`), then `ticket.TripDetails = tripDetails` is fine.
-	// 		// Based on your original code, `tickets` is `[]*models.TicketReturn`, so direct assignment is fine.
-	// 		if tickets[i] != nil { // Defensive check
-	// 			tickets[i].TripDetails = tripDetails
-	// 		}
-	// 	}
-	// }
+	t.ticketRepository.AttachTripDetails(ctx, tickets)
 
 	return tickets, nil
 }
@@ -260,6 +326,11 @@ func (t *TicketService) GetTicketByCustomer(ctx context.Context, customerID sql.
 // }
 
 func (t *TicketService) CreateTicket(ctx context.Context, input *models.TicketInput, customerID sql.NullInt32) (*db.Ticket, error) {
+	return t.createTicket(ctx, input, customerID, uuid.Nil)
+}
+
+// createTicket tạo vé của khách; bookingID khác uuid.Nil thì yêu cầu đặt vé tương ứng được hoàn tất cùng transaction.
+func (t *TicketService) createTicket(ctx context.Context, input *models.TicketInput, customerID sql.NullInt32, bookingID uuid.UUID) (*db.Ticket, error) {
 	// ... (logic from previous refactor is correct and kept here)
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
@@ -278,8 +349,18 @@ func (t *TicketService) CreateTicket(ctx context.Context, input *models.TicketIn
 		}
 	}
 
+	segmentBegin, segmentEnd, err := t.ticketSegments(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	fare, fareBytes, err := t.priceTicket(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, seatID := range input.SeatIDBegin {
-		lockKey := fmt.Sprintf("trip-lock:%s", string(seatID))
+		lockKey := seatLockKey(seatID)
 		lockAcquired, unlock, err := t.ticketRepository.AcquireLock(ctx, lockKey, 5*time.Second)
 		if err != nil {
 			t.logger.Error("Error acquiring lock for trip %s: %v", seatID, err)
@@ -292,7 +373,7 @@ func (t *TicketService) CreateTicket(ctx context.Context, input *models.TicketIn
 	}
 	if input.TicketType == 1 {
 		for _, seatID := range input.SeatIDEnd {
-			lockKey := fmt.Sprintf("trip-lock:%s", string(seatID))
+			lockKey := seatLockKey(seatID)
 			lockAcquired, unlock, err := t.ticketRepository.AcquireLock(ctx, lockKey, 5*time.Second)
 			if err != nil {
 				t.logger.Error("Error acquiring lock for trip %s: %v", seatID, err)
@@ -304,7 +385,7 @@ func (t *TicketService) CreateTicket(ctx context.Context, input *models.TicketIn
 			defer unlock()
 		}
 	}
-	availableSeatRows, err := t.ticketRepository.AreSeatsAvailable(ctx, input.SeatIDBegin)
+	availableSeatRows, err := t.ticketRepository.AreSeatsAvailable(ctx, input.SeatIDBegin, segmentBegin, customerID.Int32)
 	if err != nil {
 		t.logger.Error("Database error during batch seat validation for trip %s: %v", input.TripIDBegin, err)
 		return nil, errors.New("error checking seat availability")
@@ -313,7 +394,7 @@ func (t *TicketService) CreateTicket(ctx context.Context, input *models.TicketIn
 	var availableSeatRowsEnd []db.AreSeatsAvailableRow
 
 	if input.TicketType == 1 {
-		availableSeatRowsEnd, err = t.ticketRepository.AreSeatsAvailable(ctx, input.SeatIDEnd)
+		availableSeatRowsEnd, err = t.ticketRepository.AreSeatsAvailable(ctx, input.SeatIDEnd, segmentEnd, customerID.Int32)
 		if err != nil {
 			t.logger.Error("Database error during batch seat validation for trip %s: %v", input.TripIDEnd, err)
 			return nil, errors.New("error checking seat availability")
@@ -346,7 +427,8 @@ func (t *TicketService) CreateTicket(ctx context.Context, input *models.TicketIn
 	ticket := &db.Ticket{
 		TicketID:       ticketID,
 		CustomerID:     customerID,
-		Price:          input.Price,
+		Price:          fare.Total,
+		FareBreakdown:  fareBytes,
 		TripIDBegin:    input.TripIDBegin,
 		TripIDEnd:      sql.NullString{String: input.TripIDEnd, Valid: input.TicketType == 1},
 		Status:         models.TicketStatusPendingConfirmation,
@@ -398,16 +480,20 @@ func (t *TicketService) CreateTicket(ctx context.Context, input *models.TicketIn
 			BookingChannel: ticket.BookingChannel,
 			PolicyID:       ticket.PolicyID,
 			BookedBy:       ticket.BookedBy,
+			FareBreakdown:  ticket.FareBreakdown,
 		},
 		TicketDetail: db.CreateTicketDetailsParams{
 			TicketID:             ticketID,
 			PickupLocationBegin:  sql.NullInt32{Int32: input.PickupLocationBegin, Valid: true},
-			DropoffLocationEnd:   sql.NullInt32{Int32: input.DropoffLocationEnd, Valid: true},
+			DropoffLocationBegin: sql.NullInt32{Int32: input.DropoffLocationBegin, Valid: true},
 			PickupLocationEnd:    sql.NullInt32{Int32: input.PickupLocationEnd, Valid: input.TicketType == 1},
-			DropoffLocationBegin: sql.NullInt32{Int32: input.DropoffLocationBegin, Valid: input.TicketType == 1},
+			DropoffLocationEnd:   sql.NullInt32{Int32: input.DropoffLocationEnd, Valid: input.TicketType == 1},
 		},
 		SeatIDsBegin: input.SeatIDBegin,
 		SeatIDsEnd:   input.SeatIDEnd,
+		SegmentBegin: segmentBegin,
+		SegmentEnd:   segmentEnd,
+		BookingID:    bookingID,
 		OutboxEvents: []db.CreateOutboxEventParams{
 			{
 				ID:      uuid.New(),
@@ -425,6 +511,10 @@ func (t *TicketService) CreateTicket(ctx context.Context, input *models.TicketIn
 	}
 
 	err = t.ticketRepository.CreateTicketInTransaction(ctx, repoParams)
+	if errors.Is(err, models.ErrBookingRequestClosed) {
+		t.logger.Info("Booking request %s closed while creating ticket %s, ticket rolled back.", bookingID, ticketID)
+		return nil, err
+	}
 	if err != nil {
 		t.logger.Error("Error in repository's atomic transaction for ticket %s: %v", ticketID, err)
 		return nil, fmt.Errorf("failed to finalize booking, please try again")
@@ -433,14 +523,14 @@ func (t *TicketService) CreateTicket(ctx context.Context, input *models.TicketIn
 	go func(tripID string, seatIDs []int32) {
 		bgCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		t.ticketRepository.UpdateCachedAvailableSeats(bgCtx, tripID, seatIDs, "REMOVE")
+		t.ticketRepository.InvalidateAvailableSeatsCache(bgCtx, tripID)
 	}(input.TripIDBegin, input.SeatIDBegin)
 
 	if input.TicketType == 1 {
 		go func(tripID string, seatIDs []int32) {
 			bgCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
-			t.ticketRepository.UpdateCachedAvailableSeats(bgCtx, tripID, seatIDs, "REMOVE")
+			t.ticketRepository.InvalidateAvailableSeatsCache(bgCtx, tripID)
 		}(input.TripIDEnd, input.SeatIDEnd)
 	}
 
@@ -589,9 +679,21 @@ func (t *TicketService) CreateTicketByStaff(ctx context.Context, input *models.T
 		}
 	}
 
+	// Đoạn đường giữ ghế theo điểm đón/trả
+	segmentBegin, segmentEnd, err := t.ticketSegments(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Giá vé do server tính, không dùng input.Price
+	fare, fareBytes, err := t.priceTicket(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
 	// 1. Acquire locks for begin trip seats (same locking strategy as CreateTicket)
 	for _, seatID := range input.SeatIDBegin {
-		lockKey := fmt.Sprintf("trip-lock:%s", string(seatID))
+		lockKey := seatLockKey(seatID)
 		lockAcquired, unlock, err := t.ticketRepository.AcquireLock(ctx, lockKey, 5*time.Second)
 		if err != nil {
 			t.logger.Error("CreateTicketByStaff: Error acquiring lock for trip %s: %v", seatID, err)
@@ -606,7 +708,7 @@ func (t *TicketService) CreateTicketByStaff(ctx context.Context, input *models.T
 	// Acquire locks for end trip seats if round trip
 	if input.TicketType == 1 {
 		for _, seatID := range input.SeatIDEnd {
-			lockKey := fmt.Sprintf("trip-lock:%s", string(seatID))
+			lockKey := seatLockKey(seatID)
 			lockAcquired, unlock, err := t.ticketRepository.AcquireLock(ctx, lockKey, 5*time.Second)
 			if err != nil {
 				t.logger.Error("CreateTicketByStaff: Error acquiring lock for trip %s: %v", seatID, err)
@@ -620,7 +722,7 @@ func (t *TicketService) CreateTicketByStaff(ctx context.Context, input *models.T
 	}
 
 	// 2. Batch validate seats for begin trip
-	availableSeatRows, err := t.ticketRepository.AreSeatsAvailable(ctx, input.SeatIDBegin)
+	availableSeatRows, err := t.ticketRepository.AreSeatsAvailable(ctx, input.SeatIDBegin, segmentBegin, 0)
 	if err != nil {
 		t.logger.Error("CreateTicketByStaff: Database error during batch seat validation for trip %s: %v", input.TripIDBegin, err)
 		return nil, errors.New("error checking seat availability")
@@ -630,7 +732,7 @@ func (t *TicketService) CreateTicketByStaff(ctx context.Context, input *models.T
 
 	// Validate seats for end trip if round trip
 	if input.TicketType == 1 {
-		availableSeatRowsEnd, err = t.ticketRepository.AreSeatsAvailable(ctx, input.SeatIDEnd)
+		availableSeatRowsEnd, err = t.ticketRepository.AreSeatsAvailable(ctx, input.SeatIDEnd, segmentEnd, 0)
 		if err != nil {
 			t.logger.Error("CreateTicketByStaff: Database error during batch seat validation for trip %s: %v", input.TripIDEnd, err)
 			return nil, errors.New("error checking seat availability")
@@ -669,7 +771,8 @@ func (t *TicketService) CreateTicketByStaff(ctx context.Context, input *models.T
 		CustomerID:     sql.NullInt32{Int32: 0, Valid: false},
 		TripIDBegin:    input.TripIDBegin,
 		TripIDEnd:      sql.NullString{String: input.TripIDEnd, Valid: input.TicketType == 1},
-		Price:          input.Price,
+		Price:          fare.Total,
+		FareBreakdown:  fareBytes,
 		Status:         models.TicketStatusConfirmed, // Confirmed directly
 		PaymentStatus:  models.PaymentStatusPaid,     // Paid directly
 		Type:           int16(input.TicketType),
@@ -684,23 +787,21 @@ func (t *TicketService) CreateTicketByStaff(ctx context.Context, input *models.T
 		UpdatedAt:      time.Now(),
 	}
 
-	// 5. Prepare QR generation event for begin trip
-	var ticketDetailsQR []kafkaclient.TicketDetailForQR
-	for _, seatID := range input.SeatIDBegin {
-		ticketDetailsQR = append(ticketDetailsQR, kafkaclient.TicketDetailForQR{
-			SeatID:    seatID,
-			QRContent: fmt.Sprintf("TICKET:%s-SEAT:%d", ticketID, seatID),
-		})
+	// 5. Prepare signed QR generation event for begin trip
+	ticketDetailsQR, err := t.qrService.IssueSeatQRs(ctx, ticketID, input.TripIDBegin, input.SeatIDBegin)
+	if err != nil {
+		t.logger.Error("CreateTicketByStaff: %v", err)
+		return nil, errors.New("could not issue ticket QR codes")
 	}
 
 	// Add QR details for end trip seats if round trip
 	if input.TicketType == 1 {
-		for _, seatID := range input.SeatIDEnd {
-			ticketDetailsQR = append(ticketDetailsQR, kafkaclient.TicketDetailForQR{
-				SeatID:    seatID,
-				QRContent: fmt.Sprintf("TICKET:%s-SEAT:%d", ticketID, seatID),
-			})
+		endDetailsQR, err := t.qrService.IssueSeatQRs(ctx, ticketID, input.TripIDEnd, input.SeatIDEnd)
+		if err != nil {
+			t.logger.Error("CreateTicketByStaff: %v", err)
+			return nil, errors.New("could not issue ticket QR codes")
 		}
+		ticketDetailsQR = append(ticketDetailsQR, endDetailsQR...)
 	}
 
 	eventPayload := kafkaclient.OrderQRGenerationRequestEvent{
@@ -730,16 +831,19 @@ func (t *TicketService) CreateTicketByStaff(ctx context.Context, input *models.T
 			BookingChannel: ticket.BookingChannel,
 			PolicyID:       ticket.PolicyID,
 			BookedBy:       ticket.BookedBy,
+			FareBreakdown:  ticket.FareBreakdown,
 		},
 		TicketDetail: db.CreateTicketDetailsParams{
 			TicketID:             ticketID,
 			PickupLocationBegin:  sql.NullInt32{Int32: input.PickupLocationBegin, Valid: true},
-			DropoffLocationEnd:   sql.NullInt32{Int32: input.DropoffLocationEnd, Valid: true},
+			DropoffLocationBegin: sql.NullInt32{Int32: input.DropoffLocationBegin, Valid: true},
 			PickupLocationEnd:    sql.NullInt32{Int32: input.PickupLocationEnd, Valid: input.TicketType == 1},
-			DropoffLocationBegin: sql.NullInt32{Int32: input.DropoffLocationBegin, Valid: input.TicketType == 1},
+			DropoffLocationEnd:   sql.NullInt32{Int32: input.DropoffLocationEnd, Valid: input.TicketType == 1},
 		},
 		SeatIDsBegin: input.SeatIDBegin,
 		SeatIDsEnd:   input.SeatIDEnd,
+		SegmentBegin: segmentBegin,
+		SegmentEnd:   segmentEnd,
 		OutboxEvents: []db.CreateOutboxEventParams{
 			{
 				ID:      uuid.New(),
@@ -761,7 +865,7 @@ func (t *TicketService) CreateTicketByStaff(ctx context.Context, input *models.T
 	go func(tripID string, seatIDs []int32) {
 		bgCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		t.ticketRepository.UpdateCachedAvailableSeats(bgCtx, tripID, seatIDs, "REMOVE")
+		t.ticketRepository.InvalidateAvailableSeatsCache(bgCtx, tripID)
 	}(input.TripIDBegin, input.SeatIDBegin)
 
 	// Update cache for end trip if round trip
@@ -769,7 +873,7 @@ func (t *TicketService) CreateTicketByStaff(ctx context.Context, input *models.T
 		go func(tripID string, seatIDs []int32) {
 			bgCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
-			t.ticketRepository.UpdateCachedAvailableSeats(bgCtx, tripID, seatIDs, "REMOVE")
+			t.ticketRepository.InvalidateAvailableSeatsCache(bgCtx, tripID)
 		}(input.TripIDEnd, input.SeatIDEnd)
 	}
 
@@ -777,20 +881,21 @@ func (t *TicketService) CreateTicketByStaff(ctx context.Context, input *models.T
 	return ticket, nil
 }
 
-func (t *TicketService) GetAvailableSeatsByTripID(ctx context.Context, tripID string) ([]models.SeatReturn, error) {
+// GetAvailableSeatsByTripID trả về các ghế còn trống trên đoạn đường segment của chuyến (cache theo từng đoạn).
+func (t *TicketService) GetAvailableSeatsByTripID(ctx context.Context, tripID string, segment models.SeatSegment) ([]models.SeatReturn, error) {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	seats, err := t.ticketRepository.GetAvailableSeatsFromCache(ctx, tripID)
+	seats, err := t.ticketRepository.GetAvailableSeatsFromCache(ctx, tripID, segment)
 	if err == nil && len(seats) > 0 {
-		t.logger.Info("Available seats for trip %s retrieved from cache", tripID)
+		t.logger.Info("Available seats for trip %s (segment %s) retrieved from cache", tripID, segment)
 		return seats, nil
 	}
 	if err != nil && !errors.Is(err, repositories.ErrRedisNotAvailable) && !errors.Is(err, repositories.ErrCacheMiss) {
 		t.logger.Error("Error getting available seats from cache for trip %s: %v", tripID, err)
 	}
 
-	seats, err = t.ticketRepository.GetAvailableSeatsByTripID(ctx, tripID)
+	seats, err = t.ticketRepository.GetAvailableSeatsByTripID(ctx, tripID, segment)
 	if err != nil {
 		t.logger.Error("Error getting available seats from DB for trip %s: %v", tripID, err)
 		return nil, err
@@ -799,7 +904,7 @@ func (t *TicketService) GetAvailableSeatsByTripID(ctx context.Context, tripID st
 	go func() {
 		cacheCtx, cacheCancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cacheCancel()
-		if err := t.ticketRepository.CacheAvailableSeats(cacheCtx, tripID, seats); err != nil {
+		if err := t.ticketRepository.CacheAvailableSeats(cacheCtx, tripID, segment, seats); err != nil {
 			t.logger.Error("Warning: Failed to cache available seats for trip %s: %v", tripID, err)
 		}
 	}()
@@ -846,9 +951,10 @@ func (t *TicketService) ReleaseHeldSeats(ctx context.Context, ticketID string, s
 		}
 	}
 
-	err := t.ticketRepository.UpdateSeatTicketsStatus(ctx, ticketID, models.SeatStatusAvailable)
+	// Ghế giữ chỗ được trả lại: seat_ticket chuyển sang huỷ (SeatStatusAvailable = 1 trùng với "đã xác nhận")
+	err := t.ticketRepository.UpdateSeatTicketsStatus(ctx, ticketID, models.SeatStatusCancelled, models.StatusChange{Actor: "system", Reason: "held seats released"})
 	if err != nil {
-		t.logger.Error("Error updating seat ticket status to available/cancelled in DB for ticket %s: %v", ticketID, err)
+		t.logger.Error("Error updating seat ticket status to cancelled in DB for ticket %s: %v", ticketID, err)
 		return err
 	}
 
@@ -857,7 +963,7 @@ func (t *TicketService) ReleaseHeldSeats(ctx context.Context, ticketID string, s
 }
 
 func (t *TicketService) UpdateTicketPaymentStatus(ctx context.Context, ticketID string, paymentStatus int16, ticketStatus int16, tripID string) error {
-	err := t.ticketRepository.UpdateTicketPaymentStatus(ctx, ticketID, paymentStatus, ticketStatus, tripID)
+	err := t.ticketRepository.UpdateTicketPaymentStatus(ctx, ticketID, paymentStatus, ticketStatus, tripID, models.StatusChange{Actor: "system", Reason: "payment status update"})
 	if err != nil {
 		t.logger.Error("Error updating payment/ticket status for ticket %s: %v", ticketID, err)
 		return err
@@ -918,55 +1024,35 @@ func (t *TicketService) UpdateTicketPaymentStatus(ctx context.Context, ticketID
 	return nil
 }
 
-var (
-	httpClient = &http.Client{Timeout: 10 * time.Second}
-	// This URL should come from configuration in a real application
-	tripServiceBaseURL = "http://localhost:8082" // Assuming trip-service runs on port 8081
-)
-
-// GetTripDetails fetches trip details from the trip-service.
-func GetTripDetails(tripID string) (*models.TripInfo, error) {
-	if tripID == "" {
-		return nil, nil // Or an error: fmt.Errorf("tripID cannot be empty")
+// QuoteFare trả về bảng giá do server tính cho input (không giữ ghế).
+func (t *TicketService) QuoteFare(ctx context.Context, input *models.TicketInput) (*models.FareBreakdown, error) {
+	if input.TicketType > 1 {
+		return nil, errors.New("invalid ticket type")
 	}
+	return t.fareService.QuoteTicket(ctx, input)
+}
 
-	requestURL := fmt.Sprintf("%s/api/v1/trips/%s", tripServiceBaseURL, tripID)
-
-	resp, err := httpClient.Get(requestURL)
+// priceTicket tính giá vé phía server và đối chiếu với giá client gửi lên.
+// Nếu lệch quá PriceTolerance: từ chối khi cấu hình Fare.RejectPriceMismatch, ngược lại ghi đè bằng giá server.
+func (t *TicketService) priceTicket(ctx context.Context, input *models.TicketInput) (*models.FareBreakdown, []byte, error) {
+	fare, err := t.fareService.QuoteTicket(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to request trip details from trip-service for tripID %s: %w", tripID, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, nil // Trip not found in the other service, not necessarily an error for the whole process
-	}
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("trip-service returned status %d for tripID %s: %s", resp.StatusCode, tripID, string(bodyBytes))
-	}
-
-	// The Java service wraps its response: {"code": ..., "message": ..., "data": TripObject}
-	var serviceResponse struct {
-		Code    int              `json:"code"`
-		Message string           `json:"message"`
-		Data    *models.TripInfo `json:"data"`
+		t.logger.Error("Error computing fare for trip %s: %v", input.TripIDBegin, err)
+		return nil, nil, err
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&serviceResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode trip details response for tripID %s: %w", tripID, err)
-	}
-
-	// Check the business logic code from the service if necessary
-	if serviceResponse.Code != http.StatusOK {
-		// This could mean the trip was not found or another business error occurred
-		if serviceResponse.Code == http.StatusNotFound {
-			return nil, nil // Consistent with direct 404 handling
+	if input.Price > 0 && math.Abs(input.Price-fare.Total) > t.cfg.Fare.PriceTolerance {
+		if t.cfg.Fare.RejectPriceMismatch {
+			return nil, nil, fmt.Errorf("%w: expected %.2f, got %.2f", ErrPriceMismatch, fare.Total, input.Price)
 		}
-		return nil, fmt.Errorf("trip-service reported an issue for tripID %s (message: %s, code: %d)", tripID, serviceResponse.Message, serviceResponse.Code)
+		t.logger.Info("Client price %.2f for trip %s differs from computed fare %.2f, overriding", input.Price, input.TripIDBegin, fare.Total)
 	}
 
-	return serviceResponse.Data, nil
+	fareBytes, err := json.Marshal(fare)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode fare breakdown: %w", err)
+	}
+	return fare, fareBytes, nil
 }
 
 // CreateTicketAndNotify xử lý việc tạo vé và publish kết quả vào Redis
@@ -1019,98 +1105,81 @@ func (t *TicketService) CancelTicket(ctx context.Context, ticketID string) error
 		t.logger.Error("Failed to get ticket info for ticket %s: %v", ticketID, err)
 		return fmt.Errorf("ticket %s not found", ticketID)
 	}
-	var params repositories.UpdateStatusTransactionParams
-	params.TicketID = ticketID
-	params.OutboxEvents = []db.CreateOutboxEventParams{}
-	params.PaymentStatus = models.TicketStatusCancelled
-	params.GeneralTicketStatus = models.TicketStatusCancelled
-	params.SeatTicketStatus = models.SeatStatusCancelled
-	var eventPayload kafkaclient.SeatUpdateEvent
-	seatCount := len(ticket.SeatTicketsBegin)
-	if seatCount > 0 {
-		eventPayload = kafkaclient.SeatUpdateEvent{
-			TripID:    ticket.TripIDBegin,
-			SeatCount: seatCount,
-		}
-	}
-	releasePayloadBytes, _ := json.Marshal(eventPayload)
-	params.OutboxEvents = append(params.OutboxEvents, db.CreateOutboxEventParams{
-		ID: uuid.New(), Topic: t.cfg.Kafka.Topics.SeatsReleased.Topic, Key: ticket.TripIDBegin, Payload: releasePayloadBytes,
-	})
-
-	if ticket.Type == 1 {
-		var eventPayloadEnd kafkaclient.SeatUpdateEvent
-		seatCount := len(ticket.SeatTicketsEnd)
-		if seatCount > 0 {
-			eventPayloadEnd = kafkaclient.SeatUpdateEvent{
-				TripID:    ticket.TripIDEnd.String,
-				SeatCount: seatCount,
-			}
-		}
-		releasePayloadBytes, _ := json.Marshal(eventPayloadEnd)
-		params.OutboxEvents = append(params.OutboxEvents, db.CreateOutboxEventParams{
-			ID: uuid.New(), Topic: t.cfg.Kafka.Topics.SeatsReleased.Topic, Key: ticket.TripIDBegin, Payload: releasePayloadBytes,
-		})
-	}
-	var seatIDs []int32
-	for _, seatTicket := range ticket.SeatTicketsBegin {
-		seatIDs = append(seatIDs, seatTicket.SeatID)
+	if ticket.Status == models.TicketStatusCancelled {
+		t.logger.Info("[Cancel] Ticket %s is already cancelled. Skipping.", ticketID)
+		return nil
 	}
 
-	var seatIDsEnd []int32
-	if ticket.Type == 1 {
-		for _, seatTicket := range ticket.SeatTicketsEnd {
-			seatIDsEnd = append(seatIDsEnd, seatTicket.SeatID)
-		}
+	params := repositories.CancelTicketTransactionParams{
+		UpdateStatusTransactionParams: repositories.UpdateStatusTransactionParams{
+			TicketID:            ticketID,
+			PaymentStatus:       models.PaymentStatusFailed,
+			GeneralTicketStatus: models.TicketStatusCancelled,
+			SeatTicketStatus:    models.SeatStatusCancelled,
+			Change:              models.StatusChange{Actor: "system", Reason: "booking ACK timeout"},
+			OutboxEvents:        t.seatReleaseEvents(ticket),
+		},
+		LogAction: "CANCELLED: booking ACK timeout",
 	}
-	if err := t.ticketRepository.UpdateCachedAvailableSeats(ctx, ticket.TripIDBegin, seatIDs, "ADD"); err != nil {
-		t.logger.Error("Failed to update cached available seats for trip %s: %v", ticket.TripIDBegin, err)
+	if err := t.ticketRepository.CancelTicketInTransaction(ctx, params); err != nil {
+		t.logger.Error("[Cancel] Failed to cancel ticket %s: %v", ticketID, err)
+		return err
 	}
 
-	go t.ticketRepository.CleanupTicketCache(context.Background(), ticketID, nil, ticket.TripIDBegin)
-
-	if ticket.Type == 1 {
-		if err := t.ticketRepository.UpdateCachedAvailableSeats(ctx, ticket.TripIDEnd.String, seatIDsEnd, "ADD"); err != nil {
-			t.logger.Error("Failed to update cached available seats for trip %s: %v", ticket.TripIDEnd, err)
-		}
-
-		go t.ticketRepository.CleanupTicketCache(context.Background(), ticketID, nil, ticket.TripIDEnd.String)
-	}
-	// TODO: Thêm logic để giải phóng ghế trong bảng seat_tickets và cập nhật lại cache Redis
-	// Ví dụ: t.ticketRepository.ReleaseSeatsForTicket(ctx, ticketID)
+	t.releaseCachedSeats(ctx, ticket)
 
 	t.logger.Info("[Cancel] Successfully cancelled ticket %s.", ticketID)
 	return nil
 }
 
-func (t *TicketService) QueueNewBooking(ctx context.Context, bookingID string, input *models.TicketInput, customerID sql.NullInt32) error {
+// QueueNewBooking ghi nhận yêu cầu đặt vé và đưa vào phòng chờ của chuyến.
+// Trả về vị trí trong hàng chờ nếu chuyến đang quá tải (yêu cầu được WaitingRoomWorker đưa vào Kafka khi tới lượt),
+// nil nếu yêu cầu đã được đưa thẳng vào Kafka.
+func (t *TicketService) QueueNewBooking(ctx context.Context, bookingID string, input *models.TicketInput, customerID sql.NullInt32) (*models.WaitingRoomPosition, error) {
 	t.logger.Info("Service: Queueing new booking request for bookingId: %s", bookingID)
 
-	// 1. Tạo trạng thái ban đầu trong Redis Hash
-	redisStateKey := fmt.Sprintf("booking:state:%s", bookingID)
-	inputBytes, _ := json.Marshal(input)
-	stateData := map[string]interface{}{
-		"status":       "QUEUED",
-		"request_data": string(inputBytes),
-		"submitted_at": time.Now().UTC().Format(time.RFC3339),
+	id, err := uuid.Parse(bookingID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid booking id %q: %w", bookingID, err)
 	}
-	if err := t.redisClient.HSet(ctx, redisStateKey, stateData).Err(); err != nil {
-		t.logger.Error("Failed to set initial state in Redis for %s: %v", bookingID, err)
-		return fmt.Errorf("failed to create booking session: %w", err)
+	inputBytes, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode booking request: %w", err)
 	}
-	// Đặt TTL dài (10 phút) để dọn dẹp nếu có lỗi không xử lý được
-	t.redisClient.Expire(ctx, redisStateKey, 10*time.Minute)
 
-	// --- THAY ĐỔI MỚI: Thêm vào Sorted Set để theo dõi timeout kết nối WebSocket ---
-	connectTimeout := 30 * time.Second // Timeout 30 giây
-	expirationTimestamp := time.Now().Add(connectTimeout).UnixMilli()
-	if err := t.redisClient.ZAdd(ctx, pendingWsConnectionsKey, redis.Z{Score: float64(expirationTimestamp), Member: bookingID}).Err(); err != nil {
-		t.logger.Error("Failed to add bookingId %s to timeout sorted set: %v", bookingID, err)
-		t.redisClient.Del(context.Background(), redisStateKey) // Rollback
-		return fmt.Errorf("failed to schedule booking timeout: %w", err)
+	// 1. Ghi trạng thái QUEUED vào booking_requests.
+	// Client phải theo dõi booking (WebSocket hoặc GET /bookings/:bookingId) trong bookingConnectTimeout, nếu không TimeoutWorker huỷ booking.
+	if err := t.ticketRepository.CreateBookingRequest(ctx, db.CreateBookingRequestParams{
+		BookingID:             id,
+		CustomerID:            customerID,
+		RequestData:           inputBytes,
+		ConnectTimeoutSeconds: int32(bookingConnectTimeout.Seconds()),
+	}); err != nil {
+		t.logger.Error("Failed to create booking request %s: %v", bookingID, err)
+		return nil, fmt.Errorf("failed to create booking session: %w", err)
 	}
 
-	// 2. Tạo và Publish sự kiện vào Kafka
+	// 2. Vào phòng chờ của chuyến. Redis lỗi thì xử lý ngay như trước khi có phòng chờ thay vì từ chối đặt vé.
+	position, err := t.waitingRoom.Enter(ctx, input.TripIDBegin, bookingID)
+	if err != nil {
+		t.logger.Error("[WaitingRoom] %v. Publishing booking %s directly.", err, bookingID)
+	} else if position != nil {
+		t.logger.Info("[WaitingRoom] Booking %s is waiting at position %d/%d for trip %s.", bookingID, position.Position, position.QueueLength, position.TripID)
+		return position, nil
+	}
+
+	// 3. Tạo và Publish sự kiện vào Kafka
+	if err := t.publishBookingRequest(id, input, customerID); err != nil {
+		return nil, err
+	}
+	t.logger.Info("Successfully queued booking request for bookingId: %s", bookingID)
+	return nil, nil
+}
+
+// publishBookingRequest đưa yêu cầu vào Kafka cho BookingRequestConsumer.
+// Publish thất bại thì yêu cầu bị đánh dấu FAILED để client poll không chờ mãi.
+func (t *TicketService) publishBookingRequest(id uuid.UUID, input *models.TicketInput, customerID sql.NullInt32) error {
+	bookingID := id.String()
 	event := kafkaclient.BookingRequestEvent{
 		BookingID:  bookingID,
 		Input:      *input,
@@ -1122,13 +1191,11 @@ func (t *TicketService) QueueNewBooking(ctx context.Context, bookingID string, i
 	err := t.publisher.Publish(publishCtx, t.cfg.Kafka.Topics.BookingRequests.Topic, []byte(bookingID), event)
 	if err != nil {
 		t.logger.Error("Failed to publish booking request event to Kafka for bookingId %s: %v", bookingID, err)
-		// Rollback Redis
-		t.redisClient.Del(context.Background(), redisStateKey)
-		t.redisClient.ZRem(context.Background(), pendingWsConnectionsKey, bookingID)
+		if failErr := t.ticketRepository.FailBookingRequest(context.Background(), id, "failed to queue booking request"); failErr != nil {
+			t.logger.Error("Failed to mark booking request %s as failed: %v", bookingID, failErr)
+		}
 		return fmt.Errorf("failed to queue booking request: %w", err)
 	}
-
-	t.logger.Info("Successfully queued booking request for bookingId: %s", bookingID)
 	return nil
 }
 
@@ -1152,6 +1219,7 @@ func (t *TicketService) GetAllTickets(ctx context.Context, page, limit int) (*mo
 		t.logger.Error("Error retrieving all tickets: %v", err)
 		return nil, errors.New("failed to retrieve tickets")
 	}
+	t.ticketRepository.AttachTripDetails(ctx, tickets)
 
 	return &models.PaginatedTickets{
 		Tickets: tickets,
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+	"ticket-service/internal/repositories"
+	"ticket-service/pkg/kafkaclient"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// tripLifecycleActor là actor ghi vào lịch sử vé khi vé thay đổi do chuyến bị huỷ / dời giờ.
+const tripLifecycleActor = "trip-service"
+
+// HandleTripCancelled huỷ và hoàn tiền toàn bộ vé còn hiệu lực của chuyến bị huỷ, báo cho khách,
+// rồi đóng danh sách chờ và dọn ghế / cache của chuyến.
+// Vé khứ hồi có một chiều trên chuyến bị huỷ cũng bị huỷ cả vé và hoàn đủ tiền vì khách không còn đi được như đã đặt.
+// Hàm an toàn khi sự kiện được gửi lại: vé đã huỷ không còn trong danh sách xử lý.
+func (t *TicketService) HandleTripCancelled(ctx context.Context, event models.TripCancellation) (*models.TripCancellationResult, error) {
+	ticketIDs, err := t.ticketRepository.ListActiveTicketIDsByTrip(ctx, event.TripID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.TripCancellationResult{}
+	var errs []error
+	for _, ticketID := range ticketIDs {
+		cancelled, err := t.cancelTicketForTrip(ctx, ticketID, event)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("ticket %s: %w", ticketID, err))
+		case cancelled:
+			result.CancelledTickets++
+		default:
+			result.SkippedTickets++
+		}
+	}
+	if len(errs) > 0 {
+		// Không dọn ghế khi còn vé chưa huỷ được; lần xử lý lại sẽ làm tiếp
+		return result, errors.Join(errs...)
+	}
+
+	result.ClosedWaitlist, result.DeletedSeats, err = t.ticketRepository.CloseCancelledTrip(ctx, event.TripID, func(entry db.TripWaitlist) (db.CreateOutboxEventParams, error) {
+		userID := strconv.Itoa(int(entry.CustomerID))
+		return t.notificationEvent(userID, models.NotificationTypeTripCancelled, "Chuyến bạn đang chờ đã bị huỷ",
+			fmt.Sprintf("Chuyến %s đã bị huỷ nên bạn đã được đưa ra khỏi danh sách chờ. Vui lòng chọn chuyến khác.", event.TripID))
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if err := t.ticketRepository.InvalidateAvailableSeatsCache(ctx, event.TripID); err != nil {
+		t.logger.Error("Failed to clear cached available seats of cancelled trip %s: %v", event.TripID, err)
+	}
+	if err := t.tripClient.Invalidate(ctx, event.TripID); err != nil {
+		t.logger.Error("Failed to invalidate cached trip %s: %v", event.TripID, err)
+	}
+
+	t.logger.Info("[TripCancelled] Trip %s: %d ticket(s) cancelled, %d skipped, %d waitlist entries closed, %d unused seats deleted",
+		event.TripID, result.CancelledTickets, result.SkippedTickets, result.ClosedWaitlist, result.DeletedSeats)
+	return result, nil
+}
+
+// cancelTicketForTrip huỷ một vé của chuyến bị huỷ với hoàn tiền 100%.
+// Trả về false nếu vé không cần / không thể huỷ (đã đổi trạng thái, đã có khách check-in).
+func (t *TicketService) cancelTicketForTrip(ctx context.Context, ticketID string, event models.TripCancellation) (bool, error) {
+	lockAcquired, unlock, err := t.ticketRepository.AcquireLock(ctx, ticketLockKey(ticketID), 10*time.Second)
+	if err != nil {
+		return false, fmt.Errorf("could not acquire ticket lock: %w", err)
+	}
+	if !lockAcquired {
+		return false, ErrTicketBusy
+	}
+	defer unlock()
+
+	ticket, err := t.ticketRepository.GetTicketByID(ctx, ticketID)
+	if err != nil || ticket == nil {
+		return false, fmt.Errorf("failed to get ticket: %w", err)
+	}
+	if ticket.Status != models.TicketStatusPendingConfirmation && ticket.Status != models.TicketStatusActive {
+		return false, nil
+	}
+	for _, seatTicket := range append(ticket.SeatTicketsBegin, ticket.SeatTicketsEnd...) {
+		if seatTicket.Status == models.SeatStatusCheckedIn {
+			t.logger.Error("[TripCancelled] Ticket %s of cancelled trip %s has checked-in seat %d, leaving it to staff", ticketID, event.TripID, seatTicket.SeatID)
+			return false, nil
+		}
+	}
+
+	reason := fmt.Sprintf("Chuyến %s bị huỷ", event.TripID)
+	if event.Reason != "" {
+		reason = fmt.Sprintf("%s: %s", reason, event.Reason)
+	}
+	params := repositories.CancelTicketTransactionParams{
+		UpdateStatusTransactionParams: repositories.UpdateStatusTransactionParams{
+			TicketID:            ticketID,
+			GeneralTicketStatus: models.TicketStatusCancelled,
+			SeatTicketStatus:    models.SeatStatusCancelled,
+			Change:              models.StatusChange{Actor: tripLifecycleActor, Reason: reason},
+			OutboxEvents:        t.seatReleaseEvents(ticket),
+		},
+		LogAction: fmt.Sprintf("CANCELLED by %s: trip %s cancelled, refund 100%% (%.2f)", tripLifecycleActor, event.TripID, ticket.Price),
+	}
+
+	refundRequested := ticket.PaymentStatus == models.PaymentStatusPaid && ticket.Price > 0
+	if refundRequested {
+		groupRef, err := t.ticketRepository.GetGroupRefByTicketID(ctx, ticketID)
+		if err != nil {
+			return false, fmt.Errorf("could not resolve group of ticket: %w", err)
+		}
+		if groupRef != "" {
+			// Vé thuộc đơn đoàn: hoàn phần của vé trên hoá đơn chung mang mã đoàn
+			params.PaymentStatus = models.PaymentStatusPaid
+			adjustmentPayload, _ := json.Marshal(kafkaclient.FareAdjustmentEvent{
+				TicketID:    groupRef,
+				Amount:      -ticket.Price,
+				Reason:      fmt.Sprintf("Huỷ vé %s của đoàn. %s", ticketID, reason),
+				RequestedBy: tripLifecycleActor,
+				RequestedAt: time.Now(),
+			})
+			params.OutboxEvents = append(params.OutboxEvents, db.CreateOutboxEventParams{
+				ID: uuid.New(), Topic: t.cfg.Kafka.Topics.FareAdjustments.Topic, Key: groupRef, Payload: adjustmentPayload,
+			})
+		} else {
+			params.PaymentStatus = models.PaymentStatusRefundPending
+			refundPayload, _ := json.Marshal(kafkaclient.RefundRequestEvent{
+				TicketID:      ticketID,
+				RefundPercent: 100,
+				RefundAmount:  ticket.Price,
+				Reason:        reason,
+				RequestedBy:   tripLifecycleActor,
+				RequestedAt:   time.Now(),
+			})
+			params.OutboxEvents = append(params.OutboxEvents, db.CreateOutboxEventParams{
+				ID: uuid.New(), Topic: t.cfg.Kafka.Topics.RefundRequests.Topic, Key: ticketID, Payload: refundPayload,
+			})
+		}
+	} else {
+		params.PaymentStatus = models.PaymentStatusFailed
+	}
+
+	// Vé khách vãng lai (không có CustomerID) không nhận được thông báo trong ứng dụng
+	if ticket.CustomerID.Valid {
+		message := fmt.Sprintf("Chuyến %s của vé %s đã bị huỷ nên vé đã được huỷ.", event.TripID, ticketID)
+		if refundRequested {
+			message += fmt.Sprintf(" Bạn sẽ được hoàn lại toàn bộ %.0f %s.", ticket.Price, models.FareCurrencyVND)
+		}
+		notification, err := t.notificationEvent(strconv.Itoa(int(ticket.CustomerID.Int32)), models.NotificationTypeTripCancelled, "Chuyến của bạn đã bị huỷ", message)
+		if err != nil {
+			return false, err
+		}
+		params.OutboxEvents = append(params.OutboxEvents, notification)
+	}
+
+	if err := t.ticketRepository.CancelTicketInTransaction(ctx, params); err != nil {
+		if errors.Is(err, models.ErrIllegalTransition) {
+			// Vé vừa đổi trạng thái (VD: check-in) giữa lúc đọc và lúc khoá dòng
+			t.logger.Error("[TripCancelled] Ticket %s changed status while cancelling: %v", ticketID, err)
+			return false, nil
+		}
+		return false, fmt.Errorf("could not cancel ticket: %w", err)
+	}
+
+	t.releaseCachedSeats(ctx, ticket)
+	return true, nil
+}
+
+// HandleTripRescheduled mở lượt đổi chuyến miễn phí cho các vé còn hiệu lực của chuyến bị dời giờ và báo giờ mới cho khách.
+// Ghế của vé được giữ nguyên. Trả về số vé được mở lượt đổi; sự kiện gửi lại với cùng giờ mới không được tính lại.
+func (t *TicketService) HandleTripRescheduled(ctx context.Context, event models.TripReschedule) (int, error) {
+	if err := t.tripClient.Invalidate(ctx, event.TripID); err != nil {
+		t.logger.Error("Failed to invalidate cached trip %s: %v", event.TripID, err)
+	}
+	if event.NewDeparture.Equal(event.OldDeparture) {
+		return 0, nil
+	}
+
+	ticketIDs, err := t.ticketRepository.ListActiveTicketIDsByTrip(ctx, event.TripID)
+	if err != nil {
+		return 0, err
+	}
+
+	opened := 0
+	var errs []error
+	for _, ticketID := range ticketIDs {
+		ticket, err := t.ticketRepository.GetTicketByID(ctx, ticketID)
+		if err != nil || ticket == nil {
+			errs = append(errs, fmt.Errorf("ticket %s: failed to get ticket: %w", ticketID, err))
+			continue
+		}
+
+		var notification *db.CreateOutboxEventParams
+		if ticket.CustomerID.Valid {
+			outboxEvent, err := t.notificationEvent(strconv.Itoa(int(ticket.CustomerID.Int32)), models.NotificationTypeTripRescheduled, "Chuyến của bạn đã đổi giờ khởi hành",
+				fmt.Sprintf("Chuyến %s của vé %s đổi giờ khởi hành từ %s sang %s. Ghế của bạn được giữ nguyên; bạn có thể đổi miễn phí sang chuyến khác cùng tuyến một lần trước giờ khởi hành mới.",
+					event.TripID, ticketID, event.OldDeparture.In(models.TripLocation).Format("15:04 02/01/2006"), event.NewDeparture.In(models.TripLocation).Format("15:04 02/01/2006")))
+			if err != nil {
+				return opened, err
+			}
+			notification = &outboxEvent
+		}
+
+		isNew, err := t.ticketRepository.OpenRebookingOffer(ctx, db.UpsertTripRebookingOfferParams{
+			TicketID:     ticketID,
+			TripID:       event.TripID,
+			OldDeparture: event.OldDeparture,
+			NewDeparture: event.NewDeparture,
+		}, notification)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ticket %s: %w", ticketID, err))
+			continue
+		}
+		if isNew {
+			opened++
+		}
+	}
+
+	t.logger.Info("[TripRescheduled] Trip %s moved %s -> %s: free rebooking offered to %d ticket(s)",
+		event.TripID, event.OldDeparture.Format(time.RFC3339), event.NewDeparture.Format(time.RFC3339), opened)
+	return opened, errors.Join(errs...)
+}
+
+// notificationEvent tạo outbox event thông báo cho khách qua notification_service.
+func (t *TicketService) notificationEvent(userID, notificationType, title, message string) (db.CreateOutboxEventParams, error) {
+	payload, err := json.Marshal(kafkaclient.NotificationEvent{
+		UserID:  &userID,
+		Type:    notificationType,
+		Title:   title,
+		Message: message,
+	})
+	if err != nil {
+		return db.CreateOutboxEventParams{}, fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	return db.CreateOutboxEventParams{
+		ID:      uuid.New(),
+		Topic:   t.cfg.Kafka.Topics.Notifications.Topic,
+		Key:     userID,
+		Payload: payload,
+	}, nil
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"ticket-service/config"
+	"ticket-service/domain/models"
+	"ticket-service/internal/db"
+	"ticket-service/internal/repositories"
+	"ticket-service/pkg/kafkaclient"
+	"ticket-service/pkg/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrWaitlistSeatsAvailable = errors.New("trip still has enough free seats, book them directly")
+
+// maxWaitlistScan giới hạn số lượt chờ được xét trong một lần phân phối ghế trống của chuyến.
+const maxWaitlistScan = 50
+
+// IWaitlistService quản lý danh sách chờ của chuyến hết ghế: ghế được trả lại sẽ được giữ
+// cho người chờ kế tiếp trong một khoảng thời gian, hết hạn thì chuyển cho người sau.
+type IWaitlistService interface {
+	JoinWaitlist(ctx context.Context, customerID int32, req *models.JoinWaitlistRequest) (*models.WaitlistEntry, error)
+	LeaveWaitlist(ctx context.Context, customerID, entryID int32) error
+	ListCustomerWaitlist(ctx context.Context, customerID int32) ([]models.WaitlistEntry, error)
+	OfferFreedSeats(ctx context.Context, tripID string) (int, error)
+	ExpireOffers(ctx context.Context) error
+}
+
+type WaitlistService struct {
+	waitlistRepo repositories.WaitlistRepositoryInterface
+	ticketRepo   repositories.TicketRepositoryInterface
+	tripDetails  func(ctx context.Context, tripID string) (*models.TripInfo, error)
+	cfg          config.Config
+	logger       utils.Logger
+}
+
+func NewWaitlistService(waitlistRepo repositories.WaitlistRepositoryInterface, ticketRepo repositories.TicketRepositoryInterface, tripDetails func(ctx context.Context, tripID string) (*models.TripInfo, error), cfg config.Config, logger utils.Logger) IWaitlistService {
+	return &WaitlistService{
+		waitlistRepo: waitlistRepo,
+		ticketRepo:   ticketRepo,
+		tripDetails:  tripDetails,
+		cfg:          cfg,
+		logger:       logger,
+	}
+}
+
+func waitlistLockKey(tripID string) string {
+	return fmt.Sprintf("waitlist:%s", tripID)
+}
+
+// JoinWaitlist thêm khách vào danh sách chờ; chỉ nhận khi chuyến không còn đủ ghế trống trên đoạn khách muốn đi.
+func (s *WaitlistService) JoinWaitlist(ctx context.Context, customerID int32, req *models.JoinWaitlistRequest) (*models.WaitlistEntry, error) {
+	trip, err := s.tripDetails(ctx, req.TripID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load trip %s: %w", req.TripID, err)
+	}
+	if trip == nil {
+		return nil, fmt.Errorf("trip %s: %w", req.TripID, ErrTripNotFound)
+	}
+	if departureAt, err := trip.DepartureAt(); err == nil && !time.Now().Before(departureAt) {
+		return nil, fmt.Errorf("trip %s: %w", req.TripID, ErrTripDeparted)
+	}
+	segment, err := req.Segment(trip)
+	if err != nil {
+		return nil, err
+	}
+
+	available, err := s.waitlistRepo.ListAvailableSeats(ctx, req.TripID, segment)
+	if err != nil {
+		s.logger.Error("Error listing available seats of trip %s: %v", req.TripID, err)
+		return nil, errors.New("error checking seat availability")
+	}
+	if len(available) >= int(req.SeatCount) {
+		return nil, fmt.Errorf("%d seats free on trip %s: %w", len(available), req.TripID, ErrWaitlistSeatsAvailable)
+	}
+
+	entry, err := s.waitlistRepo.CreateEntry(ctx, db.CreateWaitlistEntryParams{
+		TripID:     req.TripID,
+		CustomerID: customerID,
+		SeatCount:  req.SeatCount,
+		FromStop:   segment.FromStop,
+		ToStop:     segment.ToStop,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Customer %d joined waitlist of trip %s for %d seat(s) (entry %d)", customerID, req.TripID, req.SeatCount, entry.ID)
+
+	entries, err := s.ListCustomerWaitlist(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].ID == entry.ID {
+			return &entries[i], nil
+		}
+	}
+	return toWaitlistEntry(entry.ID, entry.TripID, entry.SeatCount, entry.FromStop, entry.ToStop, entry.Status, entry.CreatedAt.Time, 0), nil
+}
+
+// LeaveWaitlist đưa khách ra khỏi danh sách chờ; ghế đang giữ cho khách được mời cho người kế tiếp ngay.
+func (s *WaitlistService) LeaveWaitlist(ctx context.Context, customerID, entryID int32) error {
+	entry, released, err := s.waitlistRepo.CancelEntry(ctx, entryID, customerID)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("Customer %d left waitlist of trip %s (entry %d), %d held seat(s) released", customerID, entry.TripID, entryID, released)
+	if released == 0 {
+		return nil
+	}
+
+	if err := s.ticketRepo.InvalidateAvailableSeatsCache(ctx, entry.TripID); err != nil {
+		s.logger.Error("Failed to invalidate available seats cache for trip %s: %v", entry.TripID, err)
+	}
+	go func(tripID string) {
+		bgCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if _, err := s.OfferFreedSeats(bgCtx, tripID); err != nil {
+			s.logger.Error("Failed to offer seats released by waitlist entry %d of trip %s: %v", entryID, tripID, err)
+		}
+	}(entry.TripID)
+	return nil
+}
+
+// ListCustomerWaitlist trả về các lượt chờ gần đây của khách kèm ghế đang được giữ.
+func (s *WaitlistService) ListCustomerWaitlist(ctx context.Context, customerID int32) ([]models.WaitlistEntry, error) {
+	rows, offerRows, err := s.waitlistRepo.ListByCustomer(ctx, customerID)
+	if err != nil {
+		s.logger.Error("Error listing waitlist of customer %d: %v", customerID, err)
+		return nil, err
+	}
+
+	offers := make(map[int32][]models.WaitlistSeatOffer, len(offerRows))
+	for _, row := range offerRows {
+		offers[row.WaitlistID] = append(offers[row.WaitlistID], models.WaitlistSeatOffer{
+			SeatID:    row.SeatID,
+			SeatName:  row.SeatName.String,
+			ExpiresAt: row.ExpiresAt,
+		})
+	}
+
+	entries := make([]models.WaitlistEntry, 0, len(rows))
+	for _, row := range rows {
+		position := int64(0)
+		if row.Status == models.WaitlistStatusWaiting {
+			position = row.Position
+		}
+		entry := toWaitlistEntry(row.ID, row.TripID, row.SeatCount, row.FromStop, row.ToStop, row.Status, row.CreatedAt.Time, position)
+		entry.Offers = offers[row.ID]
+		entries = append(entries, *entry)
+	}
+	return entries, nil
+}
+
+// OfferFreedSeats giữ ghế trống của chuyến cho các khách đang chờ theo thứ tự đăng ký.
+// Khách cần nhiều ghế hơn số ghế trống trên đoạn của mình được bỏ qua để người sau có thể nhận ghế.
+// Trả về số lượt chờ vừa được giữ ghế.
+func (s *WaitlistService) OfferFreedSeats(ctx context.Context, tripID string) (int, error) {
+	locked, unlock, err := s.ticketRepo.AcquireLock(ctx, waitlistLockKey(tripID), 30*time.Second)
+	if err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, fmt.Errorf("waitlist of trip %s is being processed: %w", tripID, repositories.ErrLockNotAcquired)
+	}
+	defer unlock()
+
+	entries, err := s.waitlistRepo.ListWaitingEntries(ctx, tripID, maxWaitlistScan)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list waitlist of trip %s: %w", tripID, err)
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	holdFor, ok := s.holdDuration(ctx, tripID)
+	if !ok {
+		return 0, nil
+	}
+
+	offered := 0
+	for i := range entries {
+		ok, err := s.offerToEntry(ctx, &entries[i], holdFor)
+		if err != nil {
+			s.logger.Error("Failed to offer seats of trip %s to waitlist entry %d: %v", tripID, entries[i].ID, err)
+			continue
+		}
+		if ok {
+			offered++
+		}
+	}
+	return offered, nil
+}
+
+// holdDuration tính thời gian giữ ghế, không vượt quá giờ khởi hành; false nếu chuyến đã/sắp chạy.
+func (s *WaitlistService) holdDuration(ctx context.Context, tripID string) (time.Duration, bool) {
+	holdFor := s.cfg.Waitlist.OfferTTL
+	trip, err := s.tripDetails(ctx, tripID)
+	if err == nil && trip == nil {
+		err = ErrTripNotFound
+	}
+	if err != nil {
+		s.logger.Info("Warning: could not load trip %s for waitlist (%v), holding seats for %s", tripID, err, holdFor)
+		return holdFor, true
+	}
+	departureAt, err := trip.DepartureAt()
+	if err != nil {
+		return holdFor, true
+	}
+	untilDeparture := time.Until(departureAt)
+	if untilDeparture < time.Minute {
+		s.logger.Info("Trip %s departs at %s, waitlist is no longer served", tripID, departureAt.Format(time.RFC3339))
+		return 0, false
+	}
+	return min(holdFor, untilDeparture), true
+}
+
+// offerToEntry giữ ghế cho một lượt chờ nếu đoạn đường của khách còn đủ ghế trống.
+func (s *WaitlistService) offerToEntry(ctx context.Context, entry *db.TripWaitlist, holdFor time.Duration) (bool, error) {
+	segment := models.SeatSegment{FromStop: entry.FromStop, ToStop: entry.ToStop}
+	available, err := s.waitlistRepo.ListAvailableSeats(ctx, entry.TripID, segment)
+	if err != nil {
+		return false, err
+	}
+	if len(available) < int(entry.SeatCount) {
+		return false, nil
+	}
+
+	seatIDs := make([]int32, 0, entry.SeatCount)
+	seatNames := make([]string, 0, entry.SeatCount)
+	for _, seat := range available[:entry.SeatCount] {
+		seatIDs = append(seatIDs, seat.ID)
+		seatNames = append(seatNames, seat.SeatName.String)
+	}
+
+	// Dùng chung lock ghế với luồng đặt vé để không giữ ghế mà khách khác đang đặt
+	for _, seatID := range seatIDs {
+		lockAcquired, unlockSeat, err := s.ticketRepo.AcquireLock(ctx, seatLockKey(seatID), 5*time.Second)
+		if err != nil {
+			return false, err
+		}
+		if !lockAcquired {
+			return false, nil
+		}
+		defer unlockSeat()
+	}
+	rows, err := s.ticketRepo.AreSeatsAvailable(ctx, seatIDs, segment, 0)
+	if err != nil {
+		return false, err
+	}
+	for _, row := range rows {
+		if row.IsBooked {
+			return false, nil
+		}
+	}
+
+	notification, err := s.offerNotification(entry, seatNames, time.Now().Add(holdFor))
+	if err != nil {
+		return false, err
+	}
+	if _, err := s.waitlistRepo.OfferSeats(ctx, entry.ID, seatIDs, int32(holdFor/time.Second), notification); err != nil {
+		if errors.Is(err, repositories.ErrWaitlistEntryChanged) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err := s.ticketRepo.InvalidateAvailableSeatsCache(ctx, entry.TripID); err != nil {
+		s.logger.Error("Failed to invalidate available seats cache for trip %s: %v", entry.TripID, err)
+	}
+	s.logger.Info("Held seats %v of trip %s for waitlist entry %d (customer %d) for %s", seatIDs, entry.TripID, entry.ID, entry.CustomerID, holdFor)
+	return true, nil
+}
+
+// offerNotification tạo outbox event thông báo cho khách qua notification_service.
+func (s *WaitlistService) offerNotification(entry *db.TripWaitlist, seatNames []string, expiresAt time.Time) (db.CreateOutboxEventParams, error) {
+	userID := strconv.Itoa(int(entry.CustomerID))
+	payload, err := json.Marshal(kafkaclient.NotificationEvent{
+		UserID: &userID,
+		Type:   models.NotificationTypeWaitlistSeatOffered,
+		Title:  "Đã có ghế cho chuyến bạn đang chờ",
+		Message: fmt.Sprintf("Ghế %s của chuyến %s đang được giữ cho bạn đến %s. Vui lòng đặt vé trước thời hạn, sau đó ghế sẽ được chuyển cho khách chờ tiếp theo.",
+			strings.Join(seatNames, ", "), entry.TripID, expiresAt.Format("15:04 02/01/2006")),
+	})
+	if err != nil {
+		return db.CreateOutboxEventParams{}, fmt.Errorf("failed to marshal waitlist notification: %w", err)
+	}
+	return db.CreateOutboxEventParams{
+		ID:      uuid.New(),
+		Topic:   s.cfg.Kafka.Topics.Notifications.Topic,
+		Key:     userID,
+		Payload: payload,
+	}, nil
+}
+
+// ExpireOffers thu hồi ghế giữ quá hạn và mời người chờ tiếp theo của các chuyến liên quan.
+func (s *WaitlistService) ExpireOffers(ctx context.Context) error {
+	entries, tripIDs, err := s.waitlistRepo.ExpireOffers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		s.logger.Info("Waitlist offer of entry %d (customer %d, trip %s) expired without booking", entry.ID, entry.CustomerID, entry.TripID)
+	}
+
+	for _, tripID := range tripIDs {
+		if err := s.ticketRepo.InvalidateAvailableSeatsCache(ctx, tripID); err != nil {
+			s.logger.Error("Failed to invalidate available seats cache for trip %s: %v", tripID, err)
+		}
+		if _, err := s.OfferFreedSeats(ctx, tripID); err != nil {
+			s.logger.Error("Failed to offer expired waitlist seats of trip %s: %v", tripID, err)
+		}
+	}
+	return nil
+}
+
+func toWaitlistEntry(id int32, tripID string, seatCount, fromStop, toStop, status int16, createdAt time.Time, position int64) *models.WaitlistEntry {
+	return &models.WaitlistEntry{
+		ID:        id,
+		TripID:    tripID,
+		SeatCount: seatCount,
+		Segment:   models.SeatSegment{FromStop: fromStop, ToStop: toStop},
+		Status:    status,
+		Position:  position,
+		CreatedAt: createdAt,
+	}
+}
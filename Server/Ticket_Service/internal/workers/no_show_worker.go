@@ -0,0 +1,42 @@
+// file: internal/workers/no_show_worker.go
+package workers
+
+import (
+	"context"
+	"ticket-service/internal/services"
+	"ticket-service/pkg/utils"
+	"time"
+)
+
+// NoShowWorker định kỳ đánh dấu no-show các ghế chưa check-in của chuyến đã khởi hành.
+type NoShowWorker struct {
+	manifestService services.IBoardingManifestService
+	logger          utils.Logger
+	interval        time.Duration
+}
+
+func NewNoShowWorker(manifestService services.IBoardingManifestService, interval time.Duration, logger utils.Logger) *NoShowWorker {
+	return &NoShowWorker{
+		manifestService: manifestService,
+		logger:          logger,
+		interval:        interval,
+	}
+}
+
+func (w *NoShowWorker) Start(ctx context.Context) {
+	w.logger.Info("Starting No-show Worker...")
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := w.manifestService.MarkNoShowsForDepartedTrips(ctx, time.Now()); err != nil {
+				w.logger.Error("NoShowWorker: failed to mark no-shows: %v", err)
+			}
+		case <-ctx.Done():
+			w.logger.Info("Stopping No-show Worker.")
+			return
+		}
+	}
+}
@@ -3,27 +3,22 @@ package workers
 
 import (
 	"context"
-	"fmt"
-	"strconv"
 	"ticket-service/internal/services"
 	"ticket-service/pkg/utils"
 	"time"
-
-	"github.com/redis/go-redis/v9"
 )
 
-const pendingWsConnectionsKey = "pending_ws_connections"
-
+// TimeoutWorker huỷ các booking mà client không theo dõi (WebSocket hoặc poll GET /bookings/:bookingId) kịp thời.
+// Trạng thái đọc từ bảng booking_requests nên không cần chờ consumer xử lý xong: yêu cầu đang xử lý bị đánh dấu EXPIRED
+// và transaction tạo vé của consumer sẽ rollback; yêu cầu đã có vé thì vé bị huỷ.
 type TimeoutWorker struct {
-	redisClient   *redis.Client
 	ticketService services.ITicketService
 	logger        utils.Logger
 	interval      time.Duration
 }
 
-func NewTimeoutWorker(redisClient *redis.Client, ticketService services.ITicketService, logger utils.Logger) *TimeoutWorker {
+func NewTimeoutWorker(ticketService services.ITicketService, logger utils.Logger) *TimeoutWorker {
 	return &TimeoutWorker{
-		redisClient:   redisClient,
 		ticketService: ticketService,
 		logger:        logger,
 		interval:      1 * time.Second, // Quét mỗi giây để đảm bảo timeout gần như tức thì
@@ -47,69 +42,15 @@ func (w *TimeoutWorker) Start(ctx context.Context) {
 }
 
 func (w *TimeoutWorker) processExpiredBookings(ctx context.Context) {
-	now := time.Now().UnixMilli()
-	// Lấy tất cả các bookingId đã hết hạn (score < thời gian hiện tại)
-	expiredBookingIDs, err := w.redisClient.ZRangeByScore(ctx, pendingWsConnectionsKey, &redis.ZRangeBy{
-		Min: "0",
-		Max: strconv.FormatInt(now, 10),
-	}).Result()
+	sweepCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
 
+	expired, err := w.ticketService.ExpireUnconnectedBookingRequests(sweepCtx)
 	if err != nil {
-		w.logger.Error("TimeoutWorker: Error fetching expired bookings from Redis: %v", err)
-		return
-	}
-
-	if len(expiredBookingIDs) == 0 {
+		w.logger.Error("TimeoutWorker: Error expiring bookings: %v", err)
 		return
 	}
-
-	w.logger.Info("TimeoutWorker: Found %d expired booking(s) that did not connect to WebSocket.", len(expiredBookingIDs))
-
-	// Xóa các booking đã hết hạn khỏi sorted set để tránh xử lý lại
-	if _, err := w.redisClient.ZRem(ctx, pendingWsConnectionsKey, expiredBookingIDs).Result(); err != nil {
-		w.logger.Error("TimeoutWorker: Failed to remove expired bookings from sorted set: %v", err)
-	}
-
-	// Xử lý hủy vé cho từng bookingId
-	for _, bookingID := range expiredBookingIDs {
-		go w.handleCancellation(bookingID)
-	}
-}
-
-func (w *TimeoutWorker) handleCancellation(bookingID string) {
-	w.logger.Info("TimeoutWorker: Handling cancellation for bookingId %s", bookingID)
-
-	// Chúng ta cần tìm ticketId tương ứng. Trạng thái COMPLETED trong Redis Hash sẽ chứa ticketId.
-	redisStateKey := fmt.Sprintf("booking:state:%s", bookingID)
-
-	// Chờ một khoảng thời gian ngắn để consumer có thể xử lý xong và ghi ticketId vào hash
-	time.Sleep(5 * time.Second) // Chờ 5s phòng trường hợp consumer đang xử lý
-
-	stateData, err := w.redisClient.HGetAll(context.Background(), redisStateKey).Result()
-	if err != nil || len(stateData) == 0 {
-		w.logger.Error("TimeoutWorker: Could not find state for bookingId %s after timeout. It might have been processed and cleaned up, or never existed.", bookingID)
-		return
-	}
-
-	status, _ := stateData["status"]
-	ticketID, ok := stateData["ticket_id"]
-
-	if status == "COMPLETED" && ok && ticketID != "" {
-		// Đây là trường hợp consumer đã tạo vé xong, nhưng client không kết nối ws.
-		w.logger.Info("TimeoutWorker: Booking %s was COMPLETED. Cancelling associated ticket %s.", bookingID, ticketID)
-
-		cancelCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-		if err := w.ticketService.CancelTicket(cancelCtx, ticketID); err != nil {
-			w.logger.Error("TimeoutWorker: Failed to cancel ticket %s: %v", ticketID, err)
-		} else {
-			// Xóa key state sau khi đã hủy vé thành công
-			w.redisClient.Del(context.Background(), redisStateKey)
-		}
-	} else {
-		// Trường hợp QUEUED hoặc PROCESSING. Lúc này chưa có vé để hủy.
-		// Chỉ cần xóa key state để consumer sau này sẽ bỏ qua.
-		w.logger.Info("TimeoutWorker: Booking %s timed out with status '%s'. Deleting state key.", bookingID, status)
-		w.redisClient.Del(context.Background(), redisStateKey)
+	if expired > 0 {
+		w.logger.Info("TimeoutWorker: Expired %d booking(s) that were not tracked by their client in time.", expired)
 	}
 }
@@ -0,0 +1,46 @@
+// file: internal/workers/waiting_room_worker.go
+package workers
+
+import (
+	"context"
+	"ticket-service/internal/services"
+	"ticket-service/pkg/utils"
+	"time"
+)
+
+// WaitingRoomWorker định kỳ đưa các yêu cầu đặt vé tới lượt trong phòng chờ của các chuyến vào Kafka.
+// Tốc độ đưa vào do phòng chờ giới hạn (WAITING_ROOM_ADMIT_RATE), nên nhiều instance cùng chạy không vượt quá tốc độ đó.
+type WaitingRoomWorker struct {
+	ticketService services.ITicketService
+	logger        utils.Logger
+	interval      time.Duration
+}
+
+func NewWaitingRoomWorker(ticketService services.ITicketService, interval time.Duration, logger utils.Logger) *WaitingRoomWorker {
+	return &WaitingRoomWorker{
+		ticketService: ticketService,
+		logger:        logger,
+		interval:      interval,
+	}
+}
+
+func (w *WaitingRoomWorker) Start(ctx context.Context) {
+	w.logger.Info("Starting Waiting Room Worker...")
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			admitted, err := w.ticketService.AdmitWaitingBookings(ctx)
+			if err != nil {
+				w.logger.Error("WaitingRoomWorker: failed to admit waiting bookings: %v", err)
+			} else if admitted > 0 {
+				w.logger.Debug("WaitingRoomWorker: admitted %d booking(s) from waiting rooms.", admitted)
+			}
+		case <-ctx.Done():
+			w.logger.Info("Stopping Waiting Room Worker.")
+			return
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// file: internal/workers/waitlist_worker.go
+package workers
+
+import (
+	"context"
+	"ticket-service/internal/services"
+	"ticket-service/pkg/utils"
+	"time"
+)
+
+// WaitlistWorker định kỳ thu hồi ghế giữ cho danh sách chờ đã quá hạn và chuyển cho người chờ kế tiếp.
+type WaitlistWorker struct {
+	waitlistService services.IWaitlistService
+	logger          utils.Logger
+	interval        time.Duration
+}
+
+func NewWaitlistWorker(waitlistService services.IWaitlistService, interval time.Duration, logger utils.Logger) *WaitlistWorker {
+	return &WaitlistWorker{
+		waitlistService: waitlistService,
+		logger:          logger,
+		interval:        interval,
+	}
+}
+
+func (w *WaitlistWorker) Start(ctx context.Context) {
+	w.logger.Info("Starting Waitlist Worker...")
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.waitlistService.ExpireOffers(ctx); err != nil {
+				w.logger.Error("WaitlistWorker: failed to expire waitlist offers: %v", err)
+			}
+		case <-ctx.Done():
+			w.logger.Info("Stopping Waitlist Worker.")
+			return
+		}
+	}
+}
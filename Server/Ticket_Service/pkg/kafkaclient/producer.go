@@ -131,3 +131,32 @@ type BookingRequestEvent struct {
 	Input      models.TicketInput `json:"input"`
 	CustomerID sql.NullInt32      `json:"customer_id"`
 }
+
+// RefundRequestEvent yêu cầu payment_service hoàn tiền vé qua phương thức thanh toán gốc.
+type RefundRequestEvent struct {
+	TicketID      string    `json:"ticket_id"`
+	RefundPercent float64   `json:"refund_percent"` // Áp dụng trên final_amount của hoá đơn
+	RefundAmount  float64   `json:"refund_amount"`  // Số tiền ticket-service tính, để đối chiếu
+	Reason        string    `json:"reason"`
+	RequestedBy   string    `json:"requested_by"`
+	RequestedAt   time.Time `json:"requested_at"`
+}
+
+// FareAdjustmentEvent yêu cầu payment_service thu thêm (Amount > 0) hoặc hoàn lại (Amount < 0)
+// phần chênh lệch giá khi khách đổi ghế/chuyến.
+type FareAdjustmentEvent struct {
+	TicketID    string    `json:"ticket_id"`
+	Amount      float64   `json:"amount"`
+	Reason      string    `json:"reason"`
+	RequestedBy string    `json:"requested_by"`
+	CollectedBy string    `json:"collected_by,omitempty"` // Nhân viên đã thu tiền tại quầy (khi Amount > 0)
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// NotificationEvent là payload gửi tới notification_service (cùng định dạng với các service khác).
+type NotificationEvent struct {
+	UserID  *string `json:"user_id"`
+	Type    string  `json:"type"`
+	Title   string  `json:"title"`
+	Message string  `json:"message"`
+}
@@ -0,0 +1,75 @@
+// Package manifestexport xuất manifest lên xe của chuyến ra CSV và PDF cho điều độ viên.
+package manifestexport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"ticket-service/domain/models"
+	"time"
+)
+
+var header = []string{"Seat", "Ticket", "Passenger", "Phone", "Pickup", "Dropoff", "Payment", "Boarding", "Checked in at"}
+
+// rows trả về các dòng dữ liệu dùng chung cho CSV và PDF.
+func rows(manifest *models.BoardingManifest) [][]string {
+	result := make([][]string, 0, len(manifest.Passengers))
+	for _, p := range manifest.Passengers {
+		checkedInAt := ""
+		if p.CheckedInAt != nil {
+			checkedInAt = p.CheckedInAt.In(models.TripLocation).Format("2006-01-02 15:04")
+		}
+		result = append(result, []string{
+			p.SeatName,
+			p.TicketID,
+			p.PassengerName,
+			p.Phone,
+			stopLabel(p.PickupStop, "start"),
+			stopLabel(p.DropoffStop, "end"),
+			p.PaymentStatus,
+			p.BoardingStatus,
+			checkedInAt,
+		})
+	}
+	return result
+}
+
+func stopLabel(stop *int16, fallback string) string {
+	if stop == nil {
+		return fallback
+	}
+	return strconv.Itoa(int(*stop))
+}
+
+func title(manifest *models.BoardingManifest) string {
+	text := "Boarding manifest - trip " + manifest.TripID
+	if manifest.Route != "" {
+		text += " (" + manifest.Route + ")"
+	}
+	if manifest.DepartureAt != nil {
+		text += " - departs " + manifest.DepartureAt.In(models.TripLocation).Format("2006-01-02 15:04")
+	}
+	return text
+}
+
+func summary(manifest *models.BoardingManifest) string {
+	s := manifest.Summary
+	return fmt.Sprintf("Total %d | Checked in %d | Awaiting %d | No-show %d | Pending payment %d | Generated %s",
+		s.Total, s.CheckedIn, s.Awaiting, s.NoShow, s.PendingPayment, manifest.GeneratedAt.In(models.TripLocation).Format(time.RFC3339))
+}
+
+// WriteCSV ghi manifest dạng CSV (UTF-8 có BOM để Excel hiển thị đúng tiếng Việt).
+func WriteCSV(w io.Writer, manifest *models.BoardingManifest) error {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows(manifest)); err != nil {
+		return err
+	}
+	return cw.Error()
+}
@@ -0,0 +1,78 @@
+package manifestexport
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"ticket-service/domain/models"
+	"unicode"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/text/unicode/norm"
+)
+
+const unicodeFontFamily = "manifest-unicode"
+
+// Độ rộng cột (mm) trên khổ A4 ngang, theo thứ tự của header.
+var columnWidths = []float64{18, 20, 52, 30, 18, 18, 28, 28, 35}
+
+// WritePDF ghi manifest dạng PDF. fontPath là font TTF hỗ trợ tiếng Việt;
+// nếu không đọc được font thì dùng Helvetica và bỏ dấu để file vẫn xuất được.
+func WritePDF(w io.Writer, manifest *models.BoardingManifest, fontPath string) error {
+	// fpdf ghép thư mục font với tên file nên tách fontPath thành hai phần
+	pdf := fpdf.New("L", "mm", "A4", filepath.Dir(fontPath))
+	family, text := "Helvetica", stripDiacritics
+	if fontPath != "" {
+		if _, err := os.Stat(fontPath); err == nil {
+			pdf.AddUTF8Font(unicodeFontFamily, "", filepath.Base(fontPath))
+			family, text = unicodeFontFamily, func(s string) string { return s }
+		}
+	}
+
+	pdf.SetTitle(text(title(manifest)), true)
+	pdf.AddPage()
+	pdf.SetFont(family, "", 13)
+	pdf.CellFormat(0, 8, text(title(manifest)), "", 1, "L", false, 0, "")
+	pdf.SetFont(family, "", 9)
+	pdf.CellFormat(0, 6, text(summary(manifest)), "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+
+	writeRow := func(cells []string, fill bool) {
+		for i, cell := range cells {
+			pdf.CellFormat(columnWidths[i], 7, text(cell), "1", 0, "L", fill, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.SetFillColor(230, 230, 230)
+	writeRow(header, true)
+	for _, row := range rows(manifest) {
+		if pdf.GetY() > 190 {
+			pdf.AddPage()
+			writeRow(header, true)
+		}
+		writeRow(row, false)
+	}
+
+	return pdf.Output(w)
+}
+
+// stripDiacritics bỏ dấu tiếng Việt cho font không hỗ trợ Unicode ("Nguyễn Đức" -> "Nguyen Duc").
+func stripDiacritics(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r == 'đ':
+			b.WriteRune('d')
+		case r == 'Đ':
+			b.WriteRune('D')
+		case r > unicode.MaxASCII:
+			b.WriteRune('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,154 @@
+// Package qrsign ký và xác thực nội dung QR của vé bằng HMAC-SHA256.
+//
+// Định dạng: BQR1.<key id>.<base64url(JSON claims)>.<base64url(HMAC)>
+// Key id cho phép xoay vòng khoá: khoá mới dùng để ký, các khoá cũ vẫn được giữ
+// để xác thực QR đã phát hành cho tới khi chúng hết hạn.
+package qrsign
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	prefix = "BQR1"
+	// documentPrefix tách miền chữ ký tài liệu khỏi chữ ký QR dù dùng chung khoá.
+	documentPrefix = "BDOC1."
+)
+
+var (
+	ErrMalformed        = errors.New("malformed QR payload")
+	ErrUnknownKey       = errors.New("QR payload signed with unknown key")
+	ErrInvalidSignature = errors.New("invalid QR signature")
+	ErrExpired          = errors.New("QR payload expired")
+)
+
+// Claims là nội dung được ký trong QR của một ghế.
+type Claims struct {
+	TicketID  string `json:"tid"`
+	SeatID    int32  `json:"sid"`
+	TripID    string `json:"trp"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	Nonce     string `json:"jti"` // Mã duy nhất của QR, dùng để chặn quét lại
+}
+
+// Signer giữ bộ khoá (key id -> secret) và key id đang dùng để ký.
+type Signer struct {
+	activeKeyID string
+	keys        map[string][]byte
+	now         func() time.Time
+}
+
+func NewSigner(activeKeyID string, keys map[string][]byte) (*Signer, error) {
+	if _, ok := keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("active QR signing key %q is not configured", activeKeyID)
+	}
+	for keyID, secret := range keys {
+		if len(secret) < 16 {
+			return nil, fmt.Errorf("QR signing key %q must be at least 16 bytes", keyID)
+		}
+	}
+	return &Signer{activeKeyID: activeKeyID, keys: keys, now: time.Now}, nil
+}
+
+// ParseKeys đọc danh sách khoá dạng "k1:secret1,k2:secret2".
+func ParseKeys(spec string) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		keyID, secret, ok := strings.Cut(entry, ":")
+		if !ok || keyID == "" || secret == "" || strings.Contains(keyID, ".") {
+			return nil, fmt.Errorf("invalid QR signing key entry %q, expected <id>:<secret>", keyID)
+		}
+		keys[keyID] = []byte(secret)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no QR signing keys configured")
+	}
+	return keys, nil
+}
+
+// IsSigned cho biết nội dung quét được có phải QR đã ký không.
+func IsSigned(payload string) bool {
+	return strings.HasPrefix(payload, prefix+".")
+}
+
+// Sign ký claims bằng khoá đang dùng; IssuedAt và Nonce được điền nếu để trống.
+func (s *Signer) Sign(claims Claims) (string, error) {
+	if claims.IssuedAt == 0 {
+		claims.IssuedAt = s.now().Unix()
+	}
+	if claims.Nonce == "" {
+		nonce := make([]byte, 12)
+		if _, err := rand.Read(nonce); err != nil {
+			return "", fmt.Errorf("failed to generate QR nonce: %w", err)
+		}
+		claims.Nonce = base64.RawURLEncoding.EncodeToString(nonce)
+	}
+	body, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal QR claims: %w", err)
+	}
+
+	signingInput := prefix + "." + s.activeKeyID + "." + base64.RawURLEncoding.EncodeToString(body)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(s.mac(s.keys[s.activeKeyID], signingInput)), nil
+}
+
+// Verify kiểm tra chữ ký và hạn của payload, trả về claims nếu hợp lệ.
+func (s *Signer) Verify(payload string) (*Claims, error) {
+	return s.VerifyAt(payload, s.now())
+}
+
+// VerifyAt giống Verify nhưng xét hạn tại thời điểm at (vd: thời điểm quét offline trên thiết bị soát vé).
+func (s *Signer) VerifyAt(payload string, at time.Time) (*Claims, error) {
+	parts := strings.Split(strings.TrimSpace(payload), ".")
+	if len(parts) != 4 || parts[0] != prefix {
+		return nil, ErrMalformed
+	}
+	secret, ok := s.keys[parts[1]]
+	if !ok {
+		return nil, fmt.Errorf("key %q: %w", parts[1], ErrUnknownKey)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !hmac.Equal(signature, s.mac(secret, strings.Join(parts[:3], "."))) {
+		return nil, ErrInvalidSignature
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var claims Claims
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return nil, ErrMalformed
+	}
+	if claims.ExpiresAt > 0 && at.Unix() > claims.ExpiresAt {
+		return nil, fmt.Errorf("expired at %s: %w", time.Unix(claims.ExpiresAt, 0).Format(time.RFC3339), ErrExpired)
+	}
+	return &claims, nil
+}
+
+// SignDocument ký một tài liệu bất kỳ (vd: manifest chuyến), trả về key id và chữ ký base64url.
+func (s *Signer) SignDocument(document []byte) (keyID, signature string) {
+	return s.activeKeyID, base64.RawURLEncoding.EncodeToString(s.mac(s.keys[s.activeKeyID], documentPrefix+string(document)))
+}
+
+func (s *Signer) mac(secret []byte, signingInput string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(signingInput))
+	return h.Sum(nil)
+}
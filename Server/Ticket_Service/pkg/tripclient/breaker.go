@@ -0,0 +1,65 @@
+package tripclient
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker là circuit breaker đếm lỗi liên tiếp: mở sau threshold lỗi, sau cooldown cho đúng một request thử (half-open);
+// request thử thành công thì đóng lại, thất bại thì mở thêm một cooldown.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow cho biết request có được gửi đi không.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// abort kết thúc request mà không ghi nhận thành công hay lỗi (VD caller huỷ), để request thử khác được gửi khi half-open.
+func (b *breaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// failure ghi nhận một lỗi, trả về true nếu breaker vừa chuyển sang mở.
+func (b *breaker) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures < b.threshold {
+		return false
+	}
+	b.openUntil = time.Now().Add(b.cooldown)
+	return b.failures == b.threshold
+}
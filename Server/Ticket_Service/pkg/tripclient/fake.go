@@ -0,0 +1,79 @@
+package tripclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"ticket-service/domain/models"
+)
+
+// Fake là Client trong bộ nhớ dùng cho test và chạy local không có trip-service.
+type Fake struct {
+	mu    sync.RWMutex
+	trips map[string]*models.TripInfo
+	err   error
+	calls int
+}
+
+// NewFake tạo Fake chứa sẵn các chuyến (khoá theo TripInfo.ID).
+func NewFake(trips ...*models.TripInfo) *Fake {
+	f := &Fake{trips: make(map[string]*models.TripInfo, len(trips))}
+	for _, trip := range trips {
+		f.trips[trip.ID.String()] = trip
+	}
+	return f
+}
+
+// Put thêm hoặc thay thế một chuyến.
+func (f *Fake) Put(trip *models.TripInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.trips[trip.ID.String()] = trip
+}
+
+// Delete xoá chuyến, các lần gọi sau trả về như chuyến không tồn tại.
+func (f *Fake) Delete(tripID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.trips, tripID)
+}
+
+// SetError làm mọi lần gọi sau trả về err (nil để bỏ).
+func (f *Fake) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// Calls trả về số lần GetTrip / GetTrips đã được gọi.
+func (f *Fake) Calls() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.calls
+}
+
+func (f *Fake) GetTrip(ctx context.Context, tripID string) (*models.TripInfo, error) {
+	trips, err := f.GetTrips(ctx, []string{tripID})
+	if err != nil {
+		return nil, err
+	}
+	return trips[tripID], nil
+}
+
+func (f *Fake) GetTrips(_ context.Context, tripIDs []string) (map[string]*models.TripInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.err != nil {
+		return map[string]*models.TripInfo{}, fmt.Errorf("fake trip client: %w", f.err)
+	}
+	trips := make(map[string]*models.TripInfo, len(tripIDs))
+	for _, tripID := range tripIDs {
+		if trip, ok := f.trips[tripID]; ok {
+			trips[tripID] = trip
+		}
+	}
+	return trips, nil
+}
+
+func (f *Fake) Invalidate(context.Context, ...string) error { return nil }
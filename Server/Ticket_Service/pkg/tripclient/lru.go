@@ -0,0 +1,74 @@
+package tripclient
+
+import (
+	"container/list"
+	"sync"
+	"ticket-service/domain/models"
+	"time"
+)
+
+// lruCache là cache chuyến trong tiến trình, giới hạn số phần tử và thời gian sống.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List // Phần tử mới dùng nằm đầu danh sách
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	tripID    string
+	trip      *models.TripInfo
+	expiresAt time.Time
+}
+
+// newLRUCache trả về cache rỗng; size hoặc ttl <= 0 thì cache không lưu gì.
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{size: size, ttl: ttl, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *lruCache) get(tripID string) (*models.TripInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[tripID]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.items, tripID)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.trip, true
+}
+
+func (c *lruCache) set(tripID string, trip *models.TripInfo) {
+	if c.size <= 0 || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiresAt := time.Now().Add(c.ttl)
+	if elem, ok := c.items[tripID]; ok {
+		elem.Value = &lruEntry{tripID: tripID, trip: trip, expiresAt: expiresAt}
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[tripID] = c.order.PushFront(&lruEntry{tripID: tripID, trip: trip, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).tripID)
+	}
+}
+
+func (c *lruCache) remove(tripID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[tripID]; ok {
+		c.order.Remove(elem)
+		delete(c.items, tripID)
+	}
+}
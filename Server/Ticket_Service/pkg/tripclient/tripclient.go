@@ -0,0 +1,312 @@
+// Package tripclient là client gọi trip-service để lấy thông tin chuyến.
+//
+// Thông tin chuyến được cache hai tầng: LRU trong tiến trình (TTL ngắn) và Redis (dùng chung giữa các replica).
+// Lỗi liên tiếp từ trip-service mở circuit breaker: trong thời gian đó client trả về ErrCircuitOpen ngay
+// (dữ liệu còn trong cache vẫn được trả về) thay vì để mỗi request chờ hết timeout.
+package tripclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"ticket-service/domain/models"
+	"ticket-service/pkg/utils"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCircuitOpen được trả về khi circuit breaker đang mở và chuyến không có trong cache.
+var ErrCircuitOpen = errors.New("trip-service circuit breaker is open")
+
+// Client lấy thông tin chuyến từ trip-service. Chuyến không tồn tại trả về (nil, nil) với GetTrip
+// và không có mặt trong map của GetTrips.
+type Client interface {
+	GetTrip(ctx context.Context, tripID string) (*models.TripInfo, error)
+	// GetTrips lấy nhiều chuyến cùng lúc; mỗi chuyến chỉ được tra một lần. Lỗi của từng chuyến được gộp lại,
+	// các chuyến lấy được vẫn có trong kết quả.
+	GetTrips(ctx context.Context, tripIDs []string) (map[string]*models.TripInfo, error)
+	// Invalidate xoá chuyến khỏi cache, dùng khi trip-service báo chuyến thay đổi.
+	Invalidate(ctx context.Context, tripIDs ...string) error
+}
+
+// Config cấu hình client.
+type Config struct {
+	BaseURL          string        // VD: "http://trip-service:8082"
+	Timeout          time.Duration // Timeout mỗi request tới trip-service
+	CacheTTL         time.Duration // Thời gian cache trong Redis
+	LocalCacheSize   int           // Số chuyến tối đa trong LRU của tiến trình
+	LocalCacheTTL    time.Duration // Thời gian cache trong LRU (ngắn hơn CacheTTL để các replica không lệch nhau lâu)
+	BreakerThreshold int           // Số lỗi liên tiếp trước khi mở circuit breaker
+	BreakerCooldown  time.Duration // Thời gian mở trước khi cho một request thử lại
+	MaxConcurrency   int           // Số request đồng thời tối đa khi GetTrips gặp cache miss
+	KeyPrefix        string        // VD: "trip-info"
+}
+
+// DefaultConfig trả về cấu hình mặc định cho trip-service chạy local.
+func DefaultConfig() Config {
+	return Config{
+		BaseURL:          "http://localhost:8082",
+		Timeout:          3 * time.Second,
+		CacheTTL:         2 * time.Minute,
+		LocalCacheSize:   1000,
+		LocalCacheTTL:    30 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+		MaxConcurrency:   8,
+		KeyPrefix:        "trip-info",
+	}
+}
+
+type httpClient struct {
+	cfg     Config
+	http    *http.Client
+	rdb     *redis.Client // nil: chỉ dùng LRU
+	local   *lruCache
+	breaker *breaker
+	logger  utils.Logger
+}
+
+// New tạo client gọi trip-service tại cfg.BaseURL.
+func New(rdb *redis.Client, cfg Config, logger utils.Logger) Client {
+	defaults := DefaultConfig()
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaults.BaseURL
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaults.Timeout
+	}
+	if cfg.MaxConcurrency < 1 {
+		cfg.MaxConcurrency = defaults.MaxConcurrency
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = defaults.KeyPrefix
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &httpClient{
+		cfg:     cfg,
+		http:    &http.Client{Timeout: cfg.Timeout},
+		rdb:     rdb,
+		local:   newLRUCache(cfg.LocalCacheSize, cfg.LocalCacheTTL),
+		breaker: newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		logger:  logger,
+	}
+}
+
+func (c *httpClient) GetTrip(ctx context.Context, tripID string) (*models.TripInfo, error) {
+	if tripID == "" {
+		return nil, nil
+	}
+	trips, err := c.GetTrips(ctx, []string{tripID})
+	if trip, ok := trips[tripID]; ok {
+		return trip, nil
+	}
+	return nil, err
+}
+
+func (c *httpClient) GetTrips(ctx context.Context, tripIDs []string) (map[string]*models.TripInfo, error) {
+	trips := make(map[string]*models.TripInfo, len(tripIDs))
+
+	// 1. LRU trong tiến trình
+	var missing []string
+	seen := make(map[string]bool, len(tripIDs))
+	for _, tripID := range tripIDs {
+		if tripID == "" || seen[tripID] {
+			continue
+		}
+		seen[tripID] = true
+		if trip, ok := c.local.get(tripID); ok {
+			trips[tripID] = trip
+			continue
+		}
+		missing = append(missing, tripID)
+	}
+	if len(missing) == 0 {
+		return trips, nil
+	}
+
+	// 2. Redis, một MGET cho cả danh sách
+	missing = c.readRedis(ctx, missing, trips)
+	if len(missing) == 0 {
+		return trips, nil
+	}
+
+	// 3. trip-service cho các chuyến còn thiếu
+	fetched, err := c.fetchAll(ctx, missing)
+	for tripID, trip := range fetched {
+		trips[tripID] = trip
+	}
+	c.writeCache(ctx, fetched)
+	return trips, err
+}
+
+func (c *httpClient) Invalidate(ctx context.Context, tripIDs ...string) error {
+	if len(tripIDs) == 0 {
+		return nil
+	}
+	keys := make([]string, len(tripIDs))
+	for i, tripID := range tripIDs {
+		c.local.remove(tripID)
+		keys[i] = c.cacheKey(tripID)
+	}
+	if c.rdb == nil {
+		return nil
+	}
+	if err := c.rdb.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to invalidate trip cache: %w", err)
+	}
+	return nil
+}
+
+// readRedis đưa các chuyến có trong Redis vào trips (và LRU), trả về các chuyến còn thiếu.
+func (c *httpClient) readRedis(ctx context.Context, tripIDs []string, trips map[string]*models.TripInfo) []string {
+	if c.rdb == nil {
+		return tripIDs
+	}
+	keys := make([]string, len(tripIDs))
+	for i, tripID := range tripIDs {
+		keys[i] = c.cacheKey(tripID)
+	}
+	values, err := c.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		c.logger.Error("[TripClient] Failed to read trip cache: %v", err)
+		return tripIDs
+	}
+
+	var missing []string
+	for i, tripID := range tripIDs {
+		if raw, ok := values[i].(string); ok {
+			var trip models.TripInfo
+			if err := json.Unmarshal([]byte(raw), &trip); err == nil {
+				trips[tripID] = &trip
+				c.local.set(tripID, &trip)
+				continue
+			}
+		}
+		missing = append(missing, tripID)
+	}
+	return missing
+}
+
+func (c *httpClient) writeCache(ctx context.Context, trips map[string]*models.TripInfo) {
+	if len(trips) == 0 {
+		return
+	}
+	for tripID, trip := range trips {
+		c.local.set(tripID, trip)
+	}
+	if c.rdb == nil {
+		return
+	}
+	pipe := c.rdb.Pipeline()
+	for tripID, trip := range trips {
+		data, err := json.Marshal(trip)
+		if err != nil {
+			continue
+		}
+		pipe.Set(ctx, c.cacheKey(tripID), data, c.cfg.CacheTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		c.logger.Error("[TripClient] Failed to cache trips: %v", err)
+	}
+}
+
+// fetchAll gọi trip-service cho từng chuyến (trip-service chưa có API lấy nhiều chuyến), tối đa MaxConcurrency request cùng lúc.
+func (c *httpClient) fetchAll(ctx context.Context, tripIDs []string) (map[string]*models.TripInfo, error) {
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		sem   = make(chan struct{}, c.cfg.MaxConcurrency)
+		trips = make(map[string]*models.TripInfo, len(tripIDs))
+		errs  []error
+	)
+	for _, tripID := range tripIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(tripID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			trip, err := c.fetch(ctx, tripID)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			if trip != nil {
+				trips[tripID] = trip
+			}
+		}(tripID)
+	}
+	wg.Wait()
+	return trips, errors.Join(errs...)
+}
+
+// fetch gọi GET /api/v1/trips/{id} qua circuit breaker. 404 không tính là lỗi của trip-service.
+func (c *httpClient) fetch(ctx context.Context, tripID string) (*models.TripInfo, error) {
+	if !c.breaker.allow() {
+		return nil, fmt.Errorf("trip %s: %w", tripID, ErrCircuitOpen)
+	}
+	trip, err := c.request(ctx, tripID)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Caller huỷ hoặc hết hạn: không phải lỗi của trip-service
+			c.breaker.abort()
+			return nil, err
+		}
+		if c.breaker.failure() {
+			c.logger.Error("[TripClient] Circuit breaker opened after repeated trip-service failures: %v", err)
+		}
+		return nil, err
+	}
+	c.breaker.success()
+	return trip, nil
+}
+
+func (c *httpClient) request(ctx context.Context, tripID string) (*models.TripInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	requestURL := fmt.Sprintf("%s/api/v1/trips/%s", c.cfg.BaseURL, tripID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trip request for tripID %s: %w", tripID, err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request trip details from trip-service for tripID %s: %w", tripID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil // Trip not found in the other service, not necessarily an error for the whole process
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("trip-service returned status %d for tripID %s: %s", resp.StatusCode, tripID, string(bodyBytes))
+	}
+
+	// The Java service wraps its response: {"code": ..., "message": ..., "data": TripObject}
+	var serviceResponse struct {
+		Code    int              `json:"code"`
+		Message string           `json:"message"`
+		Data    *models.TripInfo `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&serviceResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode trip details response for tripID %s: %w", tripID, err)
+	}
+	if serviceResponse.Code != http.StatusOK {
+		if serviceResponse.Code == http.StatusNotFound {
+			return nil, nil // Consistent with direct 404 handling
+		}
+		return nil, fmt.Errorf("trip-service reported an issue for tripID %s (message: %s, code: %d)", tripID, serviceResponse.Message, serviceResponse.Code)
+	}
+	return serviceResponse.Data, nil
+}
+
+func (c *httpClient) cacheKey(tripID string) string { return c.cfg.KeyPrefix + ":" + tripID }
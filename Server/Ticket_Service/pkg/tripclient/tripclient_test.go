@@ -0,0 +1,341 @@
+package tripclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ticket-service/domain/models"
+)
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := newBreaker(3, time.Hour)
+	for i := 1; i <= 3; i++ {
+		if !b.allow() {
+			t.Fatalf("allow() = false before failure %d, want true", i)
+		}
+		if opened := b.failure(); opened != (i == 3) {
+			t.Fatalf("failure() #%d opened = %v, want %v", i, opened, i == 3)
+		}
+	}
+	if b.allow() {
+		t.Fatal("allow() = true while open, want false")
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b := newBreaker(3, time.Hour)
+	b.failure()
+	b.failure()
+	b.success()
+	b.failure()
+	b.failure()
+	if !b.allow() {
+		t.Fatal("allow() = false after success reset the failure count, want true")
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name        string
+		probeOK     bool
+		allowAfter  bool // allow() ngay sau khi request thử kết thúc
+		reopenAfter bool // breaker mở lại thêm một cooldown
+	}{
+		{name: "probe succeeds closes breaker", probeOK: true, allowAfter: true},
+		{name: "probe fails reopens breaker", probeOK: false, allowAfter: false, reopenAfter: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const cooldown = 20 * time.Millisecond
+			b := newBreaker(2, cooldown)
+			b.failure()
+			b.failure()
+			if b.allow() {
+				t.Fatal("allow() = true during cooldown, want false")
+			}
+
+			time.Sleep(cooldown + 5*time.Millisecond)
+			if !b.allow() {
+				t.Fatal("allow() = false after cooldown, want one probe request")
+			}
+			if b.allow() {
+				t.Fatal("allow() = true while probe is in flight, want false")
+			}
+
+			if tt.probeOK {
+				b.success()
+			} else {
+				b.failure()
+			}
+			if got := b.allow(); got != tt.allowAfter {
+				t.Fatalf("allow() after probe = %v, want %v", got, tt.allowAfter)
+			}
+			if tt.reopenAfter {
+				time.Sleep(cooldown + 5*time.Millisecond)
+				if !b.allow() {
+					t.Fatal("allow() = false after second cooldown, want another probe")
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerAbortAllowsAnotherProbe(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	b := newBreaker(1, cooldown)
+	b.failure()
+	time.Sleep(cooldown + 5*time.Millisecond)
+	if !b.allow() {
+		t.Fatal("allow() = false after cooldown, want one probe request")
+	}
+	b.abort()
+	if !b.allow() {
+		t.Fatal("allow() = false after aborted probe, want another probe")
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	t.Run("hit", func(t *testing.T) {
+		c := newLRUCache(2, time.Minute)
+		trip := &models.TripInfo{ID: "1"}
+		c.set("1", trip)
+		got, ok := c.get("1")
+		if !ok || got != trip {
+			t.Fatalf("get(1) = %v, %v; want cached trip", got, ok)
+		}
+	})
+	t.Run("expiry", func(t *testing.T) {
+		c := newLRUCache(2, 20*time.Millisecond)
+		c.set("1", &models.TripInfo{ID: "1"})
+		time.Sleep(30 * time.Millisecond)
+		if _, ok := c.get("1"); ok {
+			t.Fatal("get(1) hit after TTL, want miss")
+		}
+		if c.order.Len() != 0 || len(c.items) != 0 {
+			t.Fatalf("expired entry not removed: order=%d items=%d", c.order.Len(), len(c.items))
+		}
+	})
+	t.Run("evicts least recently used", func(t *testing.T) {
+		c := newLRUCache(2, time.Minute)
+		c.set("1", &models.TripInfo{ID: "1"})
+		c.set("2", &models.TripInfo{ID: "2"})
+		c.get("1") // "2" trở thành phần tử cũ nhất
+		c.set("3", &models.TripInfo{ID: "3"})
+		if _, ok := c.get("2"); ok {
+			t.Fatal("get(2) hit, want evicted")
+		}
+		for _, id := range []string{"1", "3"} {
+			if _, ok := c.get(id); !ok {
+				t.Fatalf("get(%s) miss, want hit", id)
+			}
+		}
+	})
+	t.Run("disabled", func(t *testing.T) {
+		c := newLRUCache(0, time.Minute)
+		c.set("1", &models.TripInfo{ID: "1"})
+		if _, ok := c.get("1"); ok {
+			t.Fatal("get(1) hit with size 0, want miss")
+		}
+	})
+	t.Run("remove", func(t *testing.T) {
+		c := newLRUCache(2, time.Minute)
+		c.set("1", &models.TripInfo{ID: "1"})
+		c.remove("1")
+		if _, ok := c.get("1"); ok {
+			t.Fatal("get(1) hit after remove, want miss")
+		}
+	})
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	const cooldown = 30 * time.Millisecond
+	srv := newTripServer(t)
+	srv.failing.Store(true)
+	client := newTestClient(t, srv, Config{BreakerThreshold: 3, BreakerCooldown: cooldown})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetTrip(ctx, "7"); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("GetTrip #%d err = %v, want trip-service error", i+1, err)
+		}
+	}
+	if got := srv.hits.Load(); got != 3 {
+		t.Fatalf("trip-service hits = %d, want 3", got)
+	}
+
+	// Breaker mở: không gọi trip-service nữa
+	if _, err := client.GetTrip(ctx, "7"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("GetTrip while open err = %v, want ErrCircuitOpen", err)
+	}
+	if got := srv.hits.Load(); got != 3 {
+		t.Fatalf("trip-service hits while open = %d, want 3", got)
+	}
+
+	// Half-open: request thử thành công đóng breaker
+	time.Sleep(cooldown + 10*time.Millisecond)
+	srv.failing.Store(false)
+	trip, err := client.GetTrip(ctx, "7")
+	if err != nil || trip == nil || trip.ID != "7" {
+		t.Fatalf("GetTrip after cooldown = %v, %v; want trip 7", trip, err)
+	}
+	if _, err := client.GetTrip(ctx, "8"); err != nil {
+		t.Fatalf("GetTrip after breaker closed err = %v, want nil", err)
+	}
+	if got := srv.hits.Load(); got != 5 {
+		t.Fatalf("trip-service hits = %d, want 5", got)
+	}
+}
+
+func TestClientServesCachedTripWhileOpen(t *testing.T) {
+	srv := newTripServer(t)
+	client := newTestClient(t, srv, Config{
+		LocalCacheSize: 10, LocalCacheTTL: time.Minute,
+		BreakerThreshold: 1, BreakerCooldown: time.Hour,
+	})
+	ctx := context.Background()
+
+	if _, err := client.GetTrip(ctx, "7"); err != nil {
+		t.Fatalf("GetTrip(7) err = %v", err)
+	}
+	srv.failing.Store(true)
+	if _, err := client.GetTrip(ctx, "8"); err == nil {
+		t.Fatal("GetTrip(8) err = nil, want trip-service error")
+	}
+
+	trips, err := client.GetTrips(ctx, []string{"7", "9"})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("GetTrips err = %v, want ErrCircuitOpen for trip 9", err)
+	}
+	if _, ok := trips["7"]; !ok {
+		t.Fatal("GetTrips missing cached trip 7")
+	}
+	if got := srv.hits.Load(); got != 2 {
+		t.Fatalf("trip-service hits = %d, want 2", got)
+	}
+}
+
+func TestClientLocalCache(t *testing.T) {
+	const ttl = 30 * time.Millisecond
+	srv := newTripServer(t)
+	client := newTestClient(t, srv, Config{LocalCacheSize: 10, LocalCacheTTL: ttl})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetTrip(ctx, "7"); err != nil {
+			t.Fatalf("GetTrip #%d err = %v", i+1, err)
+		}
+	}
+	if got := srv.hits.Load(); got != 1 {
+		t.Fatalf("trip-service hits before TTL = %d, want 1", got)
+	}
+
+	time.Sleep(ttl + 10*time.Millisecond)
+	if _, err := client.GetTrip(ctx, "7"); err != nil {
+		t.Fatalf("GetTrip after TTL err = %v", err)
+	}
+	if got := srv.hits.Load(); got != 2 {
+		t.Fatalf("trip-service hits after TTL = %d, want 2", got)
+	}
+
+	if err := client.Invalidate(ctx, "7"); err != nil {
+		t.Fatalf("Invalidate err = %v", err)
+	}
+	if _, err := client.GetTrip(ctx, "7"); err != nil {
+		t.Fatalf("GetTrip after Invalidate err = %v", err)
+	}
+	if got := srv.hits.Load(); got != 3 {
+		t.Fatalf("trip-service hits after Invalidate = %d, want 3", got)
+	}
+}
+
+func TestClientNotFoundIsNotFailure(t *testing.T) {
+	srv := newTripServer(t)
+	client := newTestClient(t, srv, Config{BreakerThreshold: 1, BreakerCooldown: time.Hour})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		trip, err := client.GetTrip(ctx, "404")
+		if trip != nil || err != nil {
+			t.Fatalf("GetTrip(404) #%d = %v, %v; want nil, nil", i+1, trip, err)
+		}
+	}
+	if _, err := client.GetTrip(ctx, "7"); err != nil {
+		t.Fatalf("GetTrip(7) after not-found err = %v, want breaker still closed", err)
+	}
+}
+
+func TestClientCallerCancelIsNotFailure(t *testing.T) {
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for name, ctx := range map[string]context.Context{"canceled": canceled, "deadline exceeded": expired} {
+		t.Run(name, func(t *testing.T) {
+			srv := newTripServer(t)
+			client := newTestClient(t, srv, Config{BreakerThreshold: 1, BreakerCooldown: time.Hour})
+
+			if _, err := client.GetTrip(ctx, "7"); err == nil {
+				t.Fatal("GetTrip with done ctx err = nil, want ctx error")
+			}
+			if _, err := client.GetTrip(context.Background(), "7"); err != nil {
+				t.Fatalf("GetTrip after caller cancel err = %v, want breaker still closed", err)
+			}
+		})
+	}
+}
+
+// tripServer giả lập GET /api/v1/trips/{id} của trip-service; chuyến "404" không tồn tại.
+type tripServer struct {
+	*httptest.Server
+	hits    atomic.Int32
+	failing atomic.Bool
+}
+
+func newTripServer(t *testing.T) *tripServer {
+	t.Helper()
+	s := &tripServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		if s.failing.Load() {
+			http.Error(w, "trip-service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		tripID := strings.TrimPrefix(r.URL.Path, "/api/v1/trips/")
+		if tripID == "404" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"code":    http.StatusOK,
+			"message": "OK",
+			"data":    models.TripInfo{ID: json.Number(tripID)},
+		})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// newTestClient tạo client không có Redis trỏ tới srv; các giá trị cfg bằng 0 tắt LRU / dùng mặc định.
+func newTestClient(t *testing.T, srv *tripServer, cfg Config) Client {
+	t.Helper()
+	cfg.BaseURL = srv.URL
+	cfg.Timeout = time.Second
+	if cfg.BreakerThreshold == 0 {
+		cfg.BreakerThreshold = 5
+	}
+	return New(nil, cfg, testLogger{t})
+}
+
+type testLogger struct{ t *testing.T }
+
+func (l testLogger) Info(format string, args ...interface{})  { l.t.Logf("INFO: "+format, args...) }
+func (l testLogger) Error(format string, args ...interface{}) { l.t.Logf("ERROR: "+format, args...) }
+func (l testLogger) Debug(format string, args ...interface{}) { l.t.Logf("DEBUG: "+format, args...) }
@@ -0,0 +1,180 @@
+// Package waitingroom là phòng chờ ảo theo chuyến: khi số yêu cầu đặt vé vượt quá tốc độ cho phép,
+// yêu cầu được xếp hàng theo thứ tự đến trong Redis và được đưa vào xử lý dần với tốc độ AdmitRate mỗi giây,
+// thay vì cùng lúc tranh nhau lock ghế (trip-lock:) và nhận lỗi "booking for this trip is currently busy".
+//
+// Chỉ thứ tự xếp hàng nằm trong Redis; nội dung yêu cầu nằm trong booking_requests nên Redis mất dữ liệu
+// chỉ làm mất vị trí trong hàng, yêu cầu sẽ hết hạn theo TimeoutWorker như bình thường.
+package waitingroom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"ticket-service/domain/models"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Config cấu hình phòng chờ.
+type Config struct {
+	KeyPrefix string // VD: "waiting-room"
+	AdmitRate int    // Số yêu cầu mỗi chuyến được đưa vào xử lý mỗi giây
+}
+
+// DefaultConfig cho mỗi chuyến 20 yêu cầu mỗi giây.
+func DefaultConfig() Config {
+	return Config{KeyPrefix: "waiting-room", AdmitRate: 20}
+}
+
+// admissionWindow là cửa sổ đếm số yêu cầu đã được đưa vào xử lý (AdmitRate mỗi cửa sổ).
+const admissionWindow = time.Second
+
+// enterScript: nếu hàng chờ trống và cửa sổ hiện tại còn suất thì cho vào ngay (trả về 0),
+// nếu không thì xếp cuối hàng và trả về vị trí (từ 1).
+// KEYS: queue, window, seq, trips, bookings; ARGV: bookingID, rate, window ms, tripID
+var enterScript = redis.NewScript(`
+if redis.call('ZCARD', KEYS[1]) == 0 then
+	local admitted = redis.call('INCR', KEYS[2])
+	if admitted == 1 then
+		redis.call('PEXPIRE', KEYS[2], ARGV[3])
+	end
+	if admitted <= tonumber(ARGV[2]) then
+		return 0
+	end
+end
+local seq = redis.call('INCR', KEYS[3])
+redis.call('ZADD', KEYS[1], 'NX', seq, ARGV[1])
+redis.call('SADD', KEYS[4], ARGV[4])
+redis.call('HSET', KEYS[5], ARGV[1], ARGV[4])
+return redis.call('ZRANK', KEYS[1], ARGV[1]) + 1
+`)
+
+// admitScript lấy tối đa số suất còn lại của cửa sổ hiện tại từ đầu hàng chờ.
+// KEYS: queue, window, trips, bookings; ARGV: rate, window ms, tripID
+var admitScript = redis.NewScript(`
+local used = tonumber(redis.call('GET', KEYS[2]) or '0')
+local free = tonumber(ARGV[1]) - used
+local ids = {}
+if free > 0 then
+	ids = redis.call('ZRANGE', KEYS[1], 0, free - 1)
+end
+if #ids > 0 then
+	redis.call('ZREM', KEYS[1], unpack(ids))
+	redis.call('HDEL', KEYS[4], unpack(ids))
+	if redis.call('INCRBY', KEYS[2], #ids) == #ids then
+		redis.call('PEXPIRE', KEYS[2], ARGV[2])
+	end
+end
+if redis.call('ZCARD', KEYS[1]) == 0 then
+	redis.call('SREM', KEYS[3], ARGV[3])
+end
+return ids
+`)
+
+// Room lưu hàng chờ của các chuyến trong Redis. Nhiều instance có thể dùng chung: mọi thao tác là một Lua script.
+type Room struct {
+	rdb *redis.Client
+	cfg Config
+}
+
+func New(rdb *redis.Client, cfg Config) *Room {
+	if cfg.AdmitRate < 1 {
+		cfg.AdmitRate = 1
+	}
+	return &Room{rdb: rdb, cfg: cfg}
+}
+
+// Enter đưa yêu cầu vào phòng chờ của chuyến. Trả về nil nếu yêu cầu được xử lý ngay,
+// ngược lại là vị trí trong hàng chờ; yêu cầu sẽ được trả về bởi Admit khi tới lượt.
+func (r *Room) Enter(ctx context.Context, tripID, bookingID string) (*models.WaitingRoomPosition, error) {
+	rank, err := enterScript.Run(ctx, r.rdb,
+		[]string{r.queueKey(tripID), r.windowKey(tripID), r.seqKey(tripID), r.tripsKey(), r.bookingsKey()},
+		bookingID, r.cfg.AdmitRate, admissionWindow.Milliseconds(), tripID,
+	).Int64()
+	if err != nil {
+		return nil, fmt.Errorf("failed to enter waiting room of trip %s: %w", tripID, err)
+	}
+	if rank == 0 {
+		return nil, nil
+	}
+	return r.position(ctx, tripID, rank)
+}
+
+// Admit lấy các yêu cầu tới lượt xử lý của chuyến, theo thứ tự vào hàng.
+func (r *Room) Admit(ctx context.Context, tripID string) ([]string, error) {
+	bookingIDs, err := admitScript.Run(ctx, r.rdb,
+		[]string{r.queueKey(tripID), r.windowKey(tripID), r.tripsKey(), r.bookingsKey()},
+		r.cfg.AdmitRate, admissionWindow.Milliseconds(), tripID,
+	).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to admit from waiting room of trip %s: %w", tripID, err)
+	}
+	return bookingIDs, nil
+}
+
+// ActiveTrips trả về các chuyến đang có người xếp hàng.
+func (r *Room) ActiveTrips(ctx context.Context) ([]string, error) {
+	trips, err := r.rdb.SMembers(ctx, r.tripsKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list waiting rooms: %w", err)
+	}
+	return trips, nil
+}
+
+// Position trả về vị trí hiện tại của yêu cầu, nil nếu yêu cầu không (còn) trong hàng chờ.
+func (r *Room) Position(ctx context.Context, bookingID string) (*models.WaitingRoomPosition, error) {
+	tripID, err := r.rdb.HGet(ctx, r.bookingsKey(), bookingID).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up waiting room of booking %s: %w", bookingID, err)
+	}
+	rank, err := r.rdb.ZRank(ctx, r.queueKey(tripID), bookingID).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil // Vừa được đưa vào xử lý
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get position of booking %s: %w", bookingID, err)
+	}
+	return r.position(ctx, tripID, rank+1)
+}
+
+// Leave bỏ yêu cầu khỏi hàng chờ (VD: booking đã hết hạn) để không chiếm suất của người sau.
+func (r *Room) Leave(ctx context.Context, bookingID string) error {
+	tripID, err := r.rdb.HGet(ctx, r.bookingsKey(), bookingID).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up waiting room of booking %s: %w", bookingID, err)
+	}
+	pipe := r.rdb.TxPipeline()
+	pipe.ZRem(ctx, r.queueKey(tripID), bookingID)
+	pipe.HDel(ctx, r.bookingsKey(), bookingID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to remove booking %s from waiting room: %w", bookingID, err)
+	}
+	return nil
+}
+
+func (r *Room) position(ctx context.Context, tripID string, rank int64) (*models.WaitingRoomPosition, error) {
+	length, err := r.rdb.ZCard(ctx, r.queueKey(tripID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get waiting room length of trip %s: %w", tripID, err)
+	}
+	return &models.WaitingRoomPosition{
+		TripID:        tripID,
+		Position:      rank,
+		QueueLength:   length,
+		EstimatedWait: int64(math.Ceil(float64(rank) / float64(r.cfg.AdmitRate) * admissionWindow.Seconds())),
+	}, nil
+}
+
+func (r *Room) queueKey(tripID string) string  { return r.cfg.KeyPrefix + ":queue:" + tripID }
+func (r *Room) windowKey(tripID string) string { return r.cfg.KeyPrefix + ":window:" + tripID }
+func (r *Room) seqKey(tripID string) string    { return r.cfg.KeyPrefix + ":seq:" + tripID }
+func (r *Room) tripsKey() string               { return r.cfg.KeyPrefix + ":trips" }
+func (r *Room) bookingsKey() string            { return r.cfg.KeyPrefix + ":bookings" }
@@ -3,10 +3,13 @@ package websocket
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
 	"ticket-service/domain/models"
 	"time"
@@ -16,38 +19,125 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-const pendingWsConnectionsKey = "pending_ws_connections"
-
 // Message định nghĩa cấu trúc tin nhắn chuẩn qua WebSocket
 type Message struct {
-	Type    string      `json:"type"` // "result", "error", "ack"
+	Type    string      `json:"type"` // "session", "queue", "result", "error", "reconnect" (server -> client); "ack" (client -> server)
 	Payload interface{} `json:"payload"`
 }
 
+// Config cấu hình phiên theo dõi booking. Trạng thái phiên và ACK nằm trong Redis nên mọi replica dùng chung.
+type Config struct {
+	KeyPrefix     string        // VD: "booking-ws"
+	AckTimeout    time.Duration // Thời gian client có để ACK kết quả trước khi vé bị huỷ
+	HandoverGrace time.Duration // Hạn ACK được lùi thêm khi replica tắt, để client kịp kết nối lại replica khác
+	SessionTTL    time.Duration // Thời gian giữ resume token và trạng thái ACK
+	AttachTTL     time.Duration // Kết nối được coi là còn giữ phiên nếu ping trong khoảng này (kết nối chết không được trả phiên)
+	SweepInterval time.Duration // Chu kỳ quét các kết quả quá hạn ACK
+}
+
+// DefaultConfig: ACK trong 15 giây, chuyển replica trong 30 giây.
+func DefaultConfig() Config {
+	return Config{
+		KeyPrefix:     "booking-ws",
+		AckTimeout:    15 * time.Second,
+		HandoverGrace: 30 * time.Second,
+		SessionTTL:    30 * time.Minute,
+		AttachTTL:     2 * pingInterval,
+		SweepInterval: time.Second,
+	}
+}
+
+// ackSweepBatchSize là số kết quả quá hạn ACK tối đa mỗi lượt quét.
+const ackSweepBatchSize = 100
+
+// BookingTracker cung cấp trạng thái booking từ bảng booking_requests (ticket_service.ITicketService thoả interface này).
+type BookingTracker interface {
+	// TrackBookingRequest ghi nhận client đã kết nối; trả về models.ErrBookingRequestExpired nếu kết nối quá hạn.
+	TrackBookingRequest(ctx context.Context, bookingID string, customerID sql.NullInt32) (*models.BookingRequestStatus, error)
+	GetBookingRequestStatus(ctx context.Context, bookingID string) (*models.BookingRequestStatus, error)
+	// GetWaitingRoomPosition trả về vị trí trong phòng chờ của chuyến, nil khi booking không (còn) xếp hàng.
+	GetWaitingRoomPosition(ctx context.Context, bookingID string) (*models.WaitingRoomPosition, error)
+}
+
+// queuePollInterval là chu kỳ cập nhật vị trí phòng chờ cho client.
+const queuePollInterval = 2 * time.Second
+
+// pingInterval là chu kỳ ping client, cũng là chu kỳ gia hạn việc kết nối đang giữ phiên.
+const pingInterval = 45 * time.Second
+
+// SessionMessage là tin nhắn "session" gửi đầu tiên trên mỗi kết nối. Client giữ resume_token để kết nối lại
+// (kể cả tới replica khác) bằng /ws/track/:bookingId?resume_token=... mà không mất trạng thái ACK.
+func SessionMessage(resumeToken string, ackTimeout time.Duration) Message {
+	return Message{Type: "session", Payload: map[string]interface{}{
+		"resume_token":        resumeToken,
+		"ack_timeout_seconds": int(ackTimeout.Seconds()),
+	}}
+}
+
+// ReconnectMessage báo client kết nối lại với resume_token vì replica đang tắt; booking không bị huỷ.
+func ReconnectMessage(resumeToken string) Message {
+	return Message{Type: "reconnect", Payload: map[string]string{
+		"resume_token": resumeToken,
+		"reason":       "server_shutdown",
+	}}
+}
+
+// QueueMessage là tin nhắn "queue" báo vị trí và thời gian chờ ước tính; client không cần ACK.
+func QueueMessage(position *models.WaitingRoomPosition) Message {
+	return Message{Type: "queue", Payload: position}
+}
+
+// ResultMessage chuyển trạng thái cuối cùng của booking thành tin nhắn gửi cho client.
+// Tin nhắn "result" mang chi tiết vé (có "ticket_id") để xử lý ACK timeout.
+func ResultMessage(status *models.BookingRequestStatus) Message {
+	switch {
+	case status.Status == models.BookingRequestCompleted && status.Ticket != nil:
+		return Message{Type: "result", Payload: status.Ticket}
+	case status.Status == models.BookingRequestCompleted:
+		return Message{Type: "error", Payload: map[string]string{"error": "Không thể lấy chi tiết vé sau khi tạo."}}
+	case status.Status == models.BookingRequestExpired:
+		return Message{Type: "error", Payload: map[string]string{"error": "Your booking session has expired."}}
+	default: // FAILED
+		return Message{Type: "error", Payload: map[string]string{"error": status.Error}}
+	}
+}
+
 // Client đại diện cho một kết nối WebSocket đang hoạt động
 type Client struct {
-	conn      *websocket.Conn
-	bookingID string
-	manager   *Manager
-	send      chan []byte
-	ack       chan bool
-	ctx       context.Context    // Context để quản lý vòng đời của goroutine
-	cancel    context.CancelFunc // Hàm để hủy context
+	conn        *websocket.Conn
+	bookingID   string
+	resumeToken string
+	connID      string // Định danh kết nối đang giữ phiên trong SessionStore
+	manager     *Manager
+	send        chan []byte // Không bao giờ bị đóng; writePump dừng theo ctx
+	ack         chan bool
+	ctx         context.Context    // Context để quản lý vòng đời của goroutine
+	cancel      context.CancelFunc // Hàm để hủy context
 }
 
-// Manager quản lý các kết nối WebSocket và Redis client
+// Manager quản lý các kết nối WebSocket của replica hiện tại. clients chỉ chứa kết nối cục bộ;
+// kết quả booking đến qua Redis Pub/Sub và trạng thái phiên / ACK nằm trong SessionStore, nên client
+// có thể kết nối lại bất kỳ replica nào.
 type Manager struct {
 	clients      map[string]*Client
 	mu           sync.RWMutex
+	draining     bool
 	redisClient  *redis.Client
+	sessions     *SessionStore
+	tracker      BookingTracker
+	cfg          Config
 	onAckTimeout func(bookingID string, ticketID string)
 }
 
-// NewManager khởi tạo một WebSocket Manager mới
-func NewManager(redisClient *redis.Client, onAckTimeout func(bookingID string, ticketID string)) *Manager {
+// NewManager khởi tạo một WebSocket Manager mới. onAckTimeout được gọi trên đúng một replica
+// (xem StartAckSweeper) khi kết quả không được ACK kịp.
+func NewManager(redisClient *redis.Client, tracker BookingTracker, onAckTimeout func(bookingID string, ticketID string), cfg Config) *Manager {
 	return &Manager{
 		clients:      make(map[string]*Client),
 		redisClient:  redisClient,
+		sessions:     NewSessionStore(redisClient, cfg),
+		tracker:      tracker,
+		cfg:          cfg,
 		onAckTimeout: onAckTimeout,
 	}
 }
@@ -57,9 +147,15 @@ func getRedisChannel(bookingID string) string {
 	return fmt.Sprintf("booking-result:%s", bookingID)
 }
 
-// register đăng ký một client mới và khởi chạy các goroutine cần thiết
-func (m *Manager) register(client *Client) {
+// register đăng ký một client mới và khởi chạy các goroutine cần thiết.
+// position khác nil khi booking đang xếp hàng trong phòng chờ của chuyến.
+func (m *Manager) register(client *Client, position *models.WaitingRoomPosition) {
 	m.mu.Lock()
+	if previous, ok := m.clients[client.bookingID]; ok {
+		// Client kết nối lại khi kết nối cũ chưa bị phát hiện là đã đứt: đóng kết nối cũ
+		m.closeClient(previous)
+		previous.conn.Close()
+	}
 	m.clients[client.bookingID] = client
 	m.mu.Unlock()
 
@@ -69,18 +165,125 @@ func (m *Manager) register(client *Client) {
 	// readPump sẽ được chạy trên goroutine chính của handler.
 	go client.writePump()
 	go client.redisListenPump()
+	if position != nil {
+		go client.queuePump(position)
+	}
 }
 
-// unregister xóa một client khỏi manager
+// unregister xóa một client khỏi manager và trả phiên trong SessionStore
 func (m *Manager) unregister(client *Client) {
 	m.mu.Lock()
-	if _, ok := m.clients[client.bookingID]; ok {
-		delete(m.clients, client.bookingID)
-		client.cancel() // Hủy context để dừng các goroutine liên quan
-		close(client.send)
+	current, ok := m.clients[client.bookingID]
+	if ok && current == client {
+		m.closeClient(client)
 		log.Printf("[WebSocket] Client unregistered locally for bookingId: %s", client.bookingID)
 	}
 	m.mu.Unlock()
+
+	if err := m.sessions.Detach(context.Background(), client.bookingID, client.connID); err != nil {
+		log.Printf("[WebSocket] %v", err)
+	}
+}
+
+// closeClient xoá client khỏi map và dừng các goroutine của nó. Gọi khi đang giữ m.mu.
+// Không đóng client.send: redisListenPump, queuePump và Drain vẫn có thể đang gửi, writePump tự dừng khi ctx bị huỷ.
+func (m *Manager) closeClient(client *Client) {
+	delete(m.clients, client.bookingID)
+	client.cancel() // Hủy context để dừng các goroutine liên quan
+}
+
+// acknowledge ghi nhận ACK vào Redis. Kết quả đã hết hạn ACK trước đó thì vé đã bị huỷ, ACK không còn tác dụng.
+func (m *Manager) acknowledge(ctx context.Context, bookingID string) {
+	accepted, err := m.sessions.Ack(ctx, bookingID)
+	switch {
+	case err != nil:
+		log.Printf("[ACK] Failed to store ACK for bookingId %s: %v", bookingID, err)
+	case accepted:
+		log.Printf("[ACK] Received for bookingId: %s.", bookingID)
+	default:
+		log.Printf("[ACK] Ignored for bookingId %s: no result awaiting acknowledgement.", bookingID)
+	}
+}
+
+// StartAckSweeper định kỳ huỷ vé của các kết quả không được ACK kịp. Mọi replica đều chạy sweeper;
+// SessionStore.ClaimExpired đảm bảo mỗi kết quả chỉ được một replica xử lý.
+func (m *Manager) StartAckSweeper(ctx context.Context) {
+	log.Printf("[ACK] Starting ACK sweeper...")
+	ticker := time.NewTicker(m.cfg.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			expired, err := m.sessions.ClaimExpired(ctx, ackSweepBatchSize)
+			if err != nil {
+				log.Printf("[ACK] %v", err)
+				continue
+			}
+			for _, e := range expired {
+				log.Printf("[ACK] Timeout for bookingId: %s. Initiating ticket cancellation.", e.BookingID)
+				if e.TicketID != "" {
+					m.onAckTimeout(e.BookingID, e.TicketID)
+				}
+			}
+		case <-ctx.Done():
+			log.Printf("[ACK] Stopping ACK sweeper.")
+			return
+		}
+	}
+}
+
+// Drain chuyển các kết nối của replica cho replica khác khi tắt: lùi hạn ACK thêm HandoverGrace,
+// gửi tin nhắn "reconnect" kèm resume_token rồi đóng kết nối, thay vì để vé bị huỷ vì mất ACK.
+// Chờ tới khi mọi kết nối đóng hoặc ctx hết hạn; trả về số kết nối đã chuyển.
+func (m *Manager) Drain(ctx context.Context) int {
+	m.mu.Lock()
+	m.draining = true
+	bookingIDs := make([]string, 0, len(m.clients))
+	for id := range m.clients {
+		bookingIDs = append(bookingIDs, id)
+	}
+	m.mu.Unlock()
+
+	if err := m.sessions.Postpone(ctx, bookingIDs, m.cfg.HandoverGrace); err != nil {
+		log.Printf("[WebSocket] %v", err)
+	}
+
+	m.mu.RLock()
+	for _, client := range m.clients {
+		msgBytes, _ := json.Marshal(ReconnectMessage(client.resumeToken))
+		select {
+		case client.send <- msgBytes:
+		default:
+			client.conn.Close() // Hàng đợi gửi đầy: đóng luôn, client vẫn kết nối lại được bằng resume_token
+		}
+	}
+	m.mu.RUnlock()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		m.mu.RLock()
+		remaining := len(m.clients)
+		m.mu.RUnlock()
+		if remaining == 0 {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Printf("[WebSocket] Drain timed out with %d connection(s) still open.", remaining)
+			return len(bookingIDs)
+		}
+	}
+	log.Printf("[WebSocket] Drained %d connection(s).", len(bookingIDs))
+	return len(bookingIDs)
+}
+
+func (m *Manager) isDraining() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.draining
 }
 
 // redisListenPump: Goroutine lắng nghe tin nhắn từ kênh Redis và chuyển tiếp đến client
@@ -93,26 +296,11 @@ func (c *Client) redisListenPump() {
 
 	// --- LOGIC MỚI: KIỂM TRA LẠI TRẠNG THÁI NGAY SAU KHI SUBSCRIBE ---
 	// Điều này để xử lý race condition khi consumer đã xử lý xong trước khi client kịp subscribe.
-	redisStateKey := fmt.Sprintf("booking:state:%s", c.bookingID)
-	stateData, err := c.manager.redisClient.HGetAll(c.ctx, redisStateKey).Result()
-	if err == nil && (stateData["status"] == "COMPLETED" || stateData["status"] == "FAILED") {
-		log.Printf("[Redis] Race condition detected for %s. State is already '%s'. Sending historical result.", c.bookingID, stateData["status"])
-
-		var messageToSend Message
-		if stateData["status"] == "COMPLETED" {
-			var payload models.TicketReturn // Hoặc bất kỳ struct nào bạn lưu trong result_payload
-			// Bạn có thể cần bỏ comment dòng dưới nếu dùng thư viện `models` trong package này
-			if json.Unmarshal([]byte(stateData["result_payload"]), &payload) == nil {
-				messageToSend = Message{Type: "result", Payload: payload}
-			} else {
-				// Fallback nếu unmarshal lỗi
-				messageToSend = Message{Type: "error", Payload: map[string]string{"error": "Failed to parse historical result."}}
-			}
-		} else { // FAILED
-			messageToSend = Message{Type: "error", Payload: map[string]string{"error": stateData["error_message"]}}
-		}
+	status, err := c.manager.tracker.GetBookingRequestStatus(c.ctx, c.bookingID)
+	if err == nil && status.Finished() {
+		log.Printf("[Redis] Race condition detected for %s. State is already '%s'. Sending historical result.", c.bookingID, status.Status)
 
-		payloadBytes, _ := json.Marshal(messageToSend)
+		payloadBytes, _ := json.Marshal(ResultMessage(status))
 		select {
 		case c.send <- payloadBytes:
 			log.Printf("[Redis] Sent historical result for %s and closing listener.", c.bookingID)
@@ -144,6 +332,39 @@ func (c *Client) redisListenPump() {
 	}
 }
 
+// queuePump: Goroutine gửi vị trí trong phòng chờ cho client mỗi khi thay đổi, dừng khi booking được đưa vào xử lý.
+func (c *Client) queuePump(position *models.WaitingRoomPosition) {
+	ticker := time.NewTicker(queuePollInterval)
+	defer ticker.Stop()
+
+	for position != nil {
+		payloadBytes, _ := json.Marshal(QueueMessage(position))
+		select {
+		case c.send <- payloadBytes:
+		case <-c.ctx.Done():
+			return
+		}
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-c.ctx.Done():
+				return
+			}
+			current, err := c.manager.tracker.GetWaitingRoomPosition(c.ctx, c.bookingID)
+			if err != nil {
+				log.Printf("[WebSocket] Failed to get waiting room position for %s: %v", c.bookingID, err)
+				continue
+			}
+			if current == nil || *current != *position {
+				position = current
+				break
+			}
+		}
+	}
+	log.Printf("[WebSocket] Booking %s left the waiting room.", c.bookingID)
+}
+
 // readPump: Goroutine đọc tin nhắn từ client (chủ yếu là tin nhắn 'ack')
 // **HÀM NÀY SẼ CHẶN (BLOCK) ĐỂ GIỮ KẾT NỐI**
 func (c *Client) readPump() {
@@ -167,6 +388,7 @@ func (c *Client) readPump() {
 		}
 		var msg Message
 		if err := json.Unmarshal(message, &msg); err == nil && msg.Type == "ack" {
+			c.manager.acknowledge(c.ctx, c.bookingID)
 			select {
 			case c.ack <- true:
 			default:
@@ -175,10 +397,10 @@ func (c *Client) readPump() {
 	}
 }
 
-// writePump: Goroutine ghi tin nhắn đến client và quản lý ACK timeout
+// writePump: Goroutine ghi tin nhắn đến client. Sau khi gửi kết quả, hạn ACK được lưu trong Redis (SessionStore)
+// để ACK nhận ở replica nào cũng có hiệu lực; timer cục bộ chỉ đóng kết nối, việc huỷ vé do StartAckSweeper đảm nhận.
 func (c *Client) writePump() {
-	ackTimeoutDuration := 15 * time.Second
-	pingTicker := time.NewTicker(45 * time.Second)
+	pingTicker := time.NewTicker(pingInterval)
 	var ackTimer *time.Timer
 
 	defer func() {
@@ -191,53 +413,64 @@ func (c *Client) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
+		case message := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				log.Printf("[WebSocket] Error writing message: %v", err)
 				return
 			}
 
-			// Khởi động bộ đếm thời gian chờ ACK sau khi đã gửi tin nhắn kết quả
+			var sent Message
+			_ = json.Unmarshal(message, &sent)
+			switch sent.Type {
+			case "session", "queue":
+				continue // Không phải kết quả, không chờ ACK
+			case "reconnect":
+				// Replica đang tắt: đóng với mã 1012 để client kết nối lại bằng resume_token
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server shutting down"))
+				return
+			case "result":
+				// Giả sử payload là một map có chứa "ticket_id"
+				if payloadMap, ok := sent.Payload.(map[string]interface{}); ok {
+					if ticketID, ok := payloadMap["ticket_id"].(string); ok {
+						if _, err := c.manager.sessions.ExpectAck(c.ctx, c.bookingID, ticketID); err != nil {
+							log.Printf("[ACK] %v", err)
+						}
+					}
+				}
+			}
+
+			// Chờ ACK rồi đóng kết nối
 			if ackTimer != nil {
 				ackTimer.Stop()
 			}
-			ackTimer = time.NewTimer(ackTimeoutDuration)
+			ackTimer = time.NewTimer(c.manager.cfg.AckTimeout)
 
-			go func(msgBytes []byte) {
+			go func(timer *time.Timer) {
 				select {
-				case <-ackTimer.C:
-					log.Printf("[ACK] Timeout for bookingId: %s. Initiating ticket cancellation.", c.bookingID)
-					var msgData Message
-					if json.Unmarshal(msgBytes, &msgData) == nil && msgData.Type == "result" {
-						// Giả sử payload là một map có chứa "ticket_id"
-						if payloadMap, ok := msgData.Payload.(map[string]interface{}); ok {
-							if ticketID, ok := payloadMap["ticket_id"].(string); ok {
-								c.manager.onAckTimeout(c.bookingID, ticketID)
-							}
-						}
-					}
+				case <-timer.C:
+					log.Printf("[ACK] No ACK on this connection for bookingId: %s. Closing connection.", c.bookingID)
 					c.conn.Close()
 				case <-c.ack:
-					ackTimer.Stop()
-					log.Printf("[ACK] Received for bookingId: %s. Closing connection.", c.bookingID)
+					timer.Stop()
+					log.Printf("[ACK] Closing connection for bookingId: %s.", c.bookingID)
 					c.conn.Close()
 				case <-c.ctx.Done():
-					ackTimer.Stop()
+					timer.Stop()
 					return
 				}
-			}(message)
+			}(ackTimer)
 
 		case <-pingTicker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+			if err := c.manager.sessions.Heartbeat(c.ctx, c.bookingID, c.connID); err != nil {
+				log.Printf("[WebSocket] %v", err)
+			}
 		case <-c.ctx.Done():
+			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 			return
 		}
 	}
@@ -245,45 +478,60 @@ func (c *Client) writePump() {
 
 // HandleConnection là handler cho Gin để xử lý kết nối WebSocket
 // Hàm này sẽ thay thế cho helper ServeWs cũ.
+// Kết nối lại (kể cả tới replica khác) gửi kèm ?resume_token=... nhận được trong tin nhắn "session".
+// Client mất kết nối trước khi nhận "session" được kết nối lại không cần token khi kết quả chưa được ACK / huỷ
+// và không kết nối nào còn giữ phiên.
 func (m *Manager) HandleConnection(c *gin.Context) {
 	bookingID := c.Param("bookingId")
 	if bookingID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "bookingId is required"})
 		return
 	}
-
-	removedCount, err := m.redisClient.ZRem(c.Request.Context(), pendingWsConnectionsKey, bookingID).Result()
-	if err != nil {
-		log.Printf("[WebSocket] Redis error defusing timeout for %s: %v", bookingID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error processing connection."})
+	if m.isDraining() {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down, please reconnect."})
 		return
 	}
-	if removedCount == 0 {
-		// ZREM trả về 0 có nghĩa là bookingId không có trong set.
-		// Lý do: nó đã hết hạn và đã bị TimeoutWorker xử lý.
+
+	// --- KIỂM TRA TRẠNG THÁI BOOKING (booking_requests) VÀ GHI NHẬN CLIENT ĐÃ KẾT NỐI ---
+	status, err := m.tracker.TrackBookingRequest(c.Request.Context(), bookingID, requesterCustomerID(c))
+	switch {
+	case errors.Is(err, models.ErrBookingRequestExpired):
+		// Quá hạn kết nối: TimeoutWorker đã (hoặc sắp) huỷ booking.
 		log.Printf("[WebSocket] Connection rejected for %s: timeout already processed.", bookingID)
 		c.JSON(http.StatusRequestTimeout, gin.H{"error": "Your booking session has expired because you did not connect in time."})
 		return
+	case errors.Is(err, models.ErrBookingRequestNotFound):
+		log.Printf("[WebSocket] Booking session not found for %s", bookingID)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking session not found or expired."})
+		return
+	case err != nil:
+		log.Printf("[WebSocket] Error checking state for %s: %v", bookingID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check booking status."})
+		return
 	}
 
 	log.Printf("[WebSocket] Connection for %s successful within timeout window.", bookingID)
 
-	// --- KIỂM TRA TRẠNG THÁI BOOKING TRONG REDIS ---
-	redisStateKey := fmt.Sprintf("booking:state:%s", bookingID)
-	stateData, err := m.redisClient.HGetAll(c.Request.Context(), redisStateKey).Result()
+	// --- MỞ / TIẾP TỤC PHIÊN THEO DÕI ---
+	connID, err := generateResumeToken()
 	if err != nil {
-		log.Printf("[WebSocket] Redis error checking state for %s: %v", bookingID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check booking status."})
+		log.Printf("[WebSocket] %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not open booking session."})
 		return
 	}
-	if len(stateData) == 0 {
-		log.Printf("[WebSocket] Booking session not found or expired for %s", bookingID)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Booking session not found or expired."})
+	resumeToken, err := m.sessions.Open(c.Request.Context(), bookingID, c.Query("resume_token"), connID)
+	switch {
+	case errors.Is(err, ErrResumeTokenMismatch):
+		log.Printf("[WebSocket] Connection rejected for %s: invalid resume token.", bookingID)
+		c.JSON(http.StatusConflict, gin.H{"error": "This booking is already being tracked. Reconnect with the resume_token of your session."})
+		return
+	case err != nil:
+		log.Printf("[WebSocket] %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not open booking session."})
 		return
 	}
 
-	status := stateData["status"]
-
 	// --- NÂNG CẤP LÊN WEBSOCKET ---
 	upgrader := websocket.Upgrader{
 		ReadBufferSize:  1024,
@@ -296,33 +544,62 @@ func (m *Manager) HandleConnection(c *gin.Context) {
 		return
 	}
 
-	// --- XỬ LÝ DỰA TRÊN TRẠNG THÁI ---
-	if status == "COMPLETED" || status == "FAILED" {
-		log.Printf("[WebSocket] Booking %s already processed (Status: %s). Sending immediate result.", bookingID, status)
-		var messageToSend Message
-		if status == "COMPLETED" {
-			var payload models.TicketReturn
-			json.Unmarshal([]byte(stateData["result_payload"]), &payload)
-			messageToSend = Message{Type: "result", Payload: payload}
-		} else {
-			payload := map[string]string{"error": stateData["error_message"]}
-			messageToSend = Message{Type: "error", Payload: payload}
-		}
-
-		msgBytes, _ := json.Marshal(messageToSend)
-		conn.WriteMessage(websocket.TextMessage, msgBytes)
-		conn.Close()
-		return
-	}
-
-	// --- Trạng thái là QUEUED hoặc PROCESSING -> Tiến hành kết nối và lắng nghe ---
+	// --- Kết nối và lắng nghe. Booking đã có kết quả thì redisListenPump gửi lại kết quả đó,
+	// để client kết nối lại sau khi mất kết nối vẫn ACK được kết quả đang chờ.
 	ctx, cancel := context.WithCancel(c.Request.Context())
 	client := &Client{
-		conn: conn, bookingID: bookingID, manager: m,
+		conn: conn, bookingID: bookingID, resumeToken: resumeToken, connID: connID, manager: m,
 		send: make(chan []byte, 256), ack: make(chan bool, 1),
 		ctx: ctx, cancel: cancel,
 	}
-	m.register(client)
+	sessionBytes, _ := json.Marshal(SessionMessage(resumeToken, m.cfg.AckTimeout))
+	client.send <- sessionBytes
+	m.register(client, status.Queue)
 
 	client.readPump() // Chặn handler để giữ kết nối
 }
+
+// HandleAck nhận ACK qua HTTP (POST /bookings/:bookingId/ack) cho client không giữ được WebSocket tới lúc nhận kết quả.
+// Body: {"resume_token": "..."}.
+func (m *Manager) HandleAck(c *gin.Context) {
+	bookingID := c.Param("bookingId")
+	var req struct {
+		ResumeToken string `json:"resume_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "resume_token is required.", "data": nil})
+		return
+	}
+	if err := m.sessions.Verify(c.Request.Context(), bookingID, req.ResumeToken); err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, ErrResumeTokenMismatch) {
+			statusCode = http.StatusForbidden
+		}
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": "Failed to acknowledge booking: " + err.Error(), "data": nil})
+		return
+	}
+
+	accepted, err := m.sessions.Ack(c.Request.Context(), bookingID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Failed to acknowledge booking: " + err.Error(), "data": nil})
+		return
+	}
+	if !accepted {
+		c.JSON(http.StatusConflict, gin.H{"code": http.StatusConflict, "message": "No booking result is awaiting acknowledgement.", "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Booking result acknowledged", "data": gin.H{"booking_id": bookingID}})
+}
+
+// requesterCustomerID trả về X-User-ID khi người kết nối là khách hàng, để khách không theo dõi được booking của người khác.
+// Nhân viên và khách vãng lai theo dõi được booking nếu có bookingId.
+func requesterCustomerID(c *gin.Context) sql.NullInt32 {
+	if c.GetHeader("X-User-Role") != "ROLE_CUSTOMER" {
+		return sql.NullInt32{}
+	}
+	id, err := strconv.Atoi(c.GetHeader("X-User-ID"))
+	if err != nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: int32(id), Valid: true}
+}
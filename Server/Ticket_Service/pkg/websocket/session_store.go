@@ -0,0 +1,280 @@
+// file: pkg/websocket/session_store.go
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrResumeTokenMismatch được trả về khi booking đã có phiên theo dõi mà client không gửi đúng resume_token.
+var ErrResumeTokenMismatch = errors.New("booking is already tracked by another session")
+
+// ExpiredAck là một kết quả booking không được ACK kịp, đã được replica hiện tại nhận xử lý.
+type ExpiredAck struct {
+	BookingID string
+	TicketID  string
+}
+
+// Mọi script chỉ đụng tới các key khai báo trong KEYS. Key phiên và key ACK của một booking dùng chung hash tag
+// {bookingId} nên nằm cùng slot khi chạy Redis Cluster; chỉ mục hạn ACK (ZSET) được cập nhật bằng lệnh riêng.
+
+// openSessionScript: tạo phiên mới, tiếp tục phiên nếu token khớp, hoặc cấp token mới cho client không có / sai token
+// khi kết quả booking chưa được ACK / huỷ và không kết nối nào đang giữ phiên (client mất kết nối trước khi nhận "session").
+// Kết nối mở phiên được ghi là đang giữ phiên tới attached_until.
+// KEYS: session, ack; ARGV: token gửi lên, token mới, ttl ms, connID, now ms, attach ttl ms.
+// Trả về token của phiên, "" nếu không được mở.
+var openSessionScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'token')
+if current and current ~= ARGV[1] then
+	local state = redis.call('HGET', KEYS[2], 'state')
+	local attachedUntil = tonumber(redis.call('HGET', KEYS[1], 'attached_until') or '0')
+	if (state and state ~= 'pending') or attachedUntil > tonumber(ARGV[5]) then
+		return ''
+	end
+	current = false
+end
+local token = current or ARGV[2]
+redis.call('HSET', KEYS[1], 'token', token, 'conn', ARGV[4], 'attached_until', tonumber(ARGV[5]) + tonumber(ARGV[6]))
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return token
+`)
+
+// heartbeatScript gia hạn attached_until nếu connID vẫn đang giữ phiên; attach ttl = 0 là trả phiên.
+// KEYS: session; ARGV: connID, now ms, attach ttl ms
+var heartbeatScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'conn') ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'attached_until', tonumber(ARGV[2]) + tonumber(ARGV[3]))
+return 1
+`)
+
+// expectAckScript: chờ ACK cho kết quả vừa gửi. Kết quả đã được ACK / đã hết hạn thì không chờ lại (trả về 0),
+// kết quả đang chờ thì hạn ACK được tính lại từ lần gửi này.
+// KEYS: ack; ARGV: ticketID, deadline ms, ttl ms
+var expectAckScript = redis.NewScript(`
+local state = redis.call('HGET', KEYS[1], 'state')
+if state and state ~= 'pending' then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'state', 'pending', 'ticket_id', ARGV[1], 'deadline', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// ackScript: ghi nhận ACK nếu kết quả còn đang chờ. Trả về 1 nếu ACK được chấp nhận.
+// KEYS: ack
+var ackScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'state') ~= 'pending' then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'state', 'acked')
+return 1
+`)
+
+// postponeScript lùi hạn ACK của kết quả đang chờ tới ARGV[1] (không rút ngắn). Trả về 1 nếu kết quả còn chờ.
+// KEYS: ack; ARGV: deadline ms
+var postponeScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'state') ~= 'pending' then
+	return 0
+end
+if tonumber(redis.call('HGET', KEYS[1], 'deadline') or '0') < tonumber(ARGV[1]) then
+	redis.call('HSET', KEYS[1], 'deadline', ARGV[1])
+end
+return 1
+`)
+
+// claimExpiredScript chuyển một kết quả đã quá hạn ACK sang timed_out. Trả về {-1, ticketID} nếu replica này nhận được,
+// {0, ''} nếu kết quả đã được ACK / đã được replica khác nhận, {hạn mới, ''} nếu hạn đã được lùi.
+// KEYS: ack; ARGV: now ms
+var claimExpiredScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'state') ~= 'pending' then
+	return {0, ''}
+end
+local deadline = tonumber(redis.call('HGET', KEYS[1], 'deadline') or '0')
+if deadline > tonumber(ARGV[1]) then
+	return {deadline, ''}
+end
+redis.call('HSET', KEYS[1], 'state', 'timed_out')
+return {-1, redis.call('HGET', KEYS[1], 'ticket_id') or ''}
+`)
+
+// SessionStore lưu phiên theo dõi booking (resume token) và trạng thái ACK trong Redis, dùng chung cho mọi replica:
+// replica nào giữ kết nối cũng nhận được ACK, và chỉ một replica huỷ vé khi hết hạn ACK.
+// Phiên (hash {prefix}:{bookingId}:session): token, conn, attached_until.
+// Trạng thái ACK (hash {prefix}:{bookingId}:ack): state pending -> acked | timed_out, ticket_id, deadline.
+// ZSET {prefix}:ack-deadlines chỉ là chỉ mục hạn ACK cho sweeper; hash ACK là nguồn đúng.
+type SessionStore struct {
+	rdb *redis.Client
+	cfg Config
+}
+
+func NewSessionStore(rdb *redis.Client, cfg Config) *SessionStore {
+	return &SessionStore{rdb: rdb, cfg: cfg}
+}
+
+// Open mở phiên theo dõi booking cho kết nối connID. Lần kết nối đầu tiên nhận token mới; các lần sau (kể cả tới
+// replica khác) phải gửi lại token đó. Không có / sai token chỉ được nhận phiên mới khi kết quả chưa được ACK / huỷ
+// và không kết nối nào còn giữ phiên, ngược lại trả về ErrResumeTokenMismatch.
+func (s *SessionStore) Open(ctx context.Context, bookingID, resumeToken, connID string) (string, error) {
+	newToken, err := generateResumeToken()
+	if err != nil {
+		return "", err
+	}
+	token, err := openSessionScript.Run(ctx, s.rdb, []string{s.sessionKey(bookingID), s.ackKey(bookingID)},
+		resumeToken, newToken, s.cfg.SessionTTL.Milliseconds(), connID, time.Now().UnixMilli(), s.cfg.AttachTTL.Milliseconds(),
+	).Text()
+	if err != nil {
+		return "", fmt.Errorf("failed to open session for booking %s: %w", bookingID, err)
+	}
+	if token == "" {
+		return "", ErrResumeTokenMismatch
+	}
+	return token, nil
+}
+
+// Verify kiểm tra resumeToken là token của phiên hiện tại, không đổi kết nối đang giữ phiên.
+func (s *SessionStore) Verify(ctx context.Context, bookingID, resumeToken string) error {
+	token, err := s.rdb.HGet(ctx, s.sessionKey(bookingID), "token").Result()
+	if errors.Is(err, redis.Nil) || (err == nil && token != resumeToken) {
+		return ErrResumeTokenMismatch
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read session for booking %s: %w", bookingID, err)
+	}
+	return nil
+}
+
+// Heartbeat gia hạn việc kết nối connID đang giữ phiên; gọi theo chu kỳ ping.
+func (s *SessionStore) Heartbeat(ctx context.Context, bookingID, connID string) error {
+	return s.touch(ctx, bookingID, connID, s.cfg.AttachTTL)
+}
+
+// Detach trả phiên khi kết nối connID đóng, để client kết nối lại không cần đợi attached_until hết hạn.
+func (s *SessionStore) Detach(ctx context.Context, bookingID, connID string) error {
+	return s.touch(ctx, bookingID, connID, 0)
+}
+
+func (s *SessionStore) touch(ctx context.Context, bookingID, connID string, ttl time.Duration) error {
+	err := heartbeatScript.Run(ctx, s.rdb, []string{s.sessionKey(bookingID)},
+		connID, time.Now().UnixMilli(), ttl.Milliseconds(),
+	).Err()
+	if err != nil {
+		return fmt.Errorf("failed to update session attachment for booking %s: %w", bookingID, err)
+	}
+	return nil
+}
+
+// ExpectAck bắt đầu chờ ACK cho kết quả chứa vé ticketID. Trả về false nếu kết quả đã được ACK hoặc đã hết hạn trước đó.
+func (s *SessionStore) ExpectAck(ctx context.Context, bookingID, ticketID string) (bool, error) {
+	deadline := time.Now().Add(s.cfg.AckTimeout).UnixMilli()
+	expecting, err := expectAckScript.Run(ctx, s.rdb, []string{s.ackKey(bookingID)},
+		ticketID, deadline, s.cfg.SessionTTL.Milliseconds(),
+	).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to store ack state for booking %s: %w", bookingID, err)
+	}
+	if expecting != 1 {
+		return false, nil
+	}
+	if err := s.rdb.ZAdd(ctx, s.deadlinesKey(), redis.Z{Score: float64(deadline), Member: bookingID}).Err(); err != nil {
+		return true, fmt.Errorf("failed to index ack deadline for booking %s: %w", bookingID, err)
+	}
+	return true, nil
+}
+
+// Ack ghi nhận client đã nhận kết quả. Trả về false nếu không có kết quả nào đang chờ ACK (đã ACK hoặc đã hết hạn).
+func (s *SessionStore) Ack(ctx context.Context, bookingID string) (bool, error) {
+	accepted, err := ackScript.Run(ctx, s.rdb, []string{s.ackKey(bookingID)}).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to ack booking %s: %w", bookingID, err)
+	}
+	if accepted != 1 {
+		return false, nil
+	}
+	// Bỏ khỏi chỉ mục; nếu lệnh này lỗi, sweeper sẽ thấy state acked và tự bỏ qua
+	if err := s.rdb.ZRem(ctx, s.deadlinesKey(), bookingID).Err(); err != nil {
+		return true, fmt.Errorf("failed to remove ack deadline of booking %s: %w", bookingID, err)
+	}
+	return true, nil
+}
+
+// Postpone lùi hạn ACK của các booking đang chờ thêm d, dùng khi replica chuyển kết nối cho replica khác.
+func (s *SessionStore) Postpone(ctx context.Context, bookingIDs []string, d time.Duration) error {
+	deadline := time.Now().Add(d).UnixMilli()
+	members := make([]redis.Z, 0, len(bookingIDs))
+	for _, id := range bookingIDs {
+		pending, err := postponeScript.Run(ctx, s.rdb, []string{s.ackKey(id)}, deadline).Int()
+		if err != nil {
+			return fmt.Errorf("failed to postpone ack deadline of booking %s: %w", id, err)
+		}
+		if pending == 1 {
+			members = append(members, redis.Z{Score: float64(deadline), Member: id})
+		}
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	// GT: không rút ngắn hạn đã có trong chỉ mục
+	if err := s.rdb.ZAddArgs(ctx, s.deadlinesKey(), redis.ZAddArgs{GT: true, Members: members}).Err(); err != nil {
+		return fmt.Errorf("failed to postpone ack deadlines: %w", err)
+	}
+	return nil
+}
+
+// ClaimExpired nhận xử lý tối đa limit kết quả đã quá hạn ACK. Mỗi kết quả chỉ được một replica nhận:
+// việc chuyển pending -> timed_out chạy nguyên tử trên hash ACK của từng booking.
+func (s *SessionStore) ClaimExpired(ctx context.Context, limit int) ([]ExpiredAck, error) {
+	now := time.Now().UnixMilli()
+	ids, err := s.rdb.ZRangeArgs(ctx, redis.ZRangeArgs{
+		Key: s.deadlinesKey(), Start: "-inf", Stop: now, ByScore: true, Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired acks: %w", err)
+	}
+
+	expired := make([]ExpiredAck, 0, len(ids))
+	for _, id := range ids {
+		values, err := claimExpiredScript.Run(ctx, s.rdb, []string{s.ackKey(id)}, now).Slice()
+		if err != nil {
+			return expired, fmt.Errorf("failed to claim expired ack of booking %s: %w", id, err)
+		}
+		if len(values) != 2 {
+			return expired, fmt.Errorf("unexpected claim result for booking %s: %v", id, values)
+		}
+		deadline, _ := values[0].(int64)
+		ticketID, _ := values[1].(string)
+		if deadline > 0 {
+			// Hạn đã được lùi (Postpone) nhưng chỉ mục chưa kịp cập nhật
+			s.rdb.ZAddArgs(ctx, s.deadlinesKey(), redis.ZAddArgs{GT: true, Members: []redis.Z{{Score: float64(deadline), Member: id}}})
+			continue
+		}
+		s.rdb.ZRem(ctx, s.deadlinesKey(), id)
+		if deadline < 0 {
+			expired = append(expired, ExpiredAck{BookingID: id, TicketID: ticketID})
+		}
+	}
+	return expired, nil
+}
+
+func (s *SessionStore) sessionKey(bookingID string) string {
+	return s.cfg.KeyPrefix + ":{" + bookingID + "}:session"
+}
+func (s *SessionStore) ackKey(bookingID string) string {
+	return s.cfg.KeyPrefix + ":{" + bookingID + "}:ack"
+}
+func (s *SessionStore) deadlinesKey() string { return s.cfg.KeyPrefix + ":ack-deadlines" }
+
+func generateResumeToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate resume token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
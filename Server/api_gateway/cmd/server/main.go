@@ -53,6 +53,10 @@ func registerAllServices(registry *services.ServiceRegistry, serviceURLs config.
 	registry.RegisterService("payment-service-invoices", serviceURLs.PaymentServiceURL, "/api/v1/invoices", 2)
 	registry.RegisterService("payment-service-generic", serviceURLs.PaymentServiceURL, "/api/v1/payments", 1)
 	registry.RegisterService("payment-service-bank", serviceURLs.PaymentServiceURL, "/api/v1/bank", 1)
+	registry.RegisterService("payment-service-refunds", serviceURLs.PaymentServiceURL, "/api/v1/refunds", 2)
+	registry.RegisterService("payment-service-reconciliation", serviceURLs.PaymentServiceURL, "/api/v1/reconciliation", 2)
+	registry.RegisterService("payment-service-vouchers", serviceURLs.PaymentServiceURL, "/api/v1/vouchers", 2)
+	registry.RegisterService("payment-service-outbox", serviceURLs.PaymentServiceURL, "/api/v1/admin/payment-outbox", 2)
 
 	// Trip Services
 	registry.RegisterService("trip-service-locations", serviceURLs.TripServiceURL, "/api/v1/locations", 1)
@@ -68,6 +72,7 @@ func registerAllServices(registry *services.ServiceRegistry, serviceURLs config.
 	// Ticket Services
 	registry.RegisterService("ticket-service-initiate-booking", serviceURLs.TicketServiceURL, "/api/v1/initiate-booking", 2) // NEW
 	registry.RegisterService("ticket-service-track", serviceURLs.TicketServiceURL, "/api/v1/ws/track", 2)                    // NEW
+	registry.RegisterService("ticket-service-bookings", serviceURLs.TicketServiceURL, "/api/v1/bookings", 2)
 	registry.RegisterService("ticket-service-main", serviceURLs.TicketServiceURL, "/api/v1/tickets", 1)
 	registry.RegisterService("ticket-service-phone", serviceURLs.TicketServiceURL, "/api/v1/ticket-by-phone", 2)
 	registry.RegisterService("ticket-service-staff", serviceURLs.TicketServiceURL, "/api/v1/staff/tickets", 2)
@@ -77,6 +82,12 @@ func registerAllServices(registry *services.ServiceRegistry, serviceURLs config.
 	registry.RegisterService("ticket-service-checkin", serviceURLs.TicketServiceURL, "/api/v1/checkin", 2)
 	registry.RegisterService("ticket-service-token-test", serviceURLs.TicketServiceURL, "/api/v1/token-test", 2)
 	registry.RegisterService("ticket-service-checkin-trip", serviceURLs.TicketServiceURL, "/api/v1/public", 2)
+	registry.RegisterService("ticket-service-seat-layouts", serviceURLs.TicketServiceURL, "/api/v1/seat-layouts", 2)
+	registry.RegisterService("ticket-service-seat-maps", serviceURLs.TicketServiceURL, "/api/v1/seat-maps", 2)
+	registry.RegisterService("ticket-service-boarding-manifests", serviceURLs.TicketServiceURL, "/api/v1/boarding-manifests", 2)
+	registry.RegisterService("ticket-service-waitlist", serviceURLs.TicketServiceURL, "/api/v1/waitlist", 2)
+	registry.RegisterService("ticket-service-group-bookings", serviceURLs.TicketServiceURL, "/api/v1/group-bookings", 2)
+	registry.RegisterService("ticket-service-outbox", serviceURLs.TicketServiceURL, "/api/v1/admin/ticket-outbox", 2)
 
 	// User Services
 	registry.RegisterService("user-service-auth", serviceURLs.UserServiceURL, "/api/v1/auth", 2)
@@ -88,6 +99,7 @@ func registerAllServices(registry *services.ServiceRegistry, serviceURLs config.
 
 	//Bank Services
 	registry.RegisterService("bank-service-accounts", serviceURLs.BankServiceURL, "/api/v1/accounts", 1)
+	registry.RegisterService("bank-service-outbox", serviceURLs.BankServiceURL, "/api/v1/admin/bank-outbox", 2)
 	//News Services
 	registry.RegisterService("news-service-news", serviceURLs.NewsServiceURL, "/api/v1/news", 1)
 	//Notification services
@@ -33,12 +33,14 @@ func SetupRouter(
 		"/api/v1/users/by-role": {"ROLE_ADMIN", "ROLE_OPERATOR"},
 		"/api/v1/staff/tickets": {"ROLE_RECEPTION", "ROLE_OPERATOR", "ROLE_ADMIN"},
 		"/api/v1/create-seats":  {"ROLE_ADMIN", "ROLE_OPERATOR"},
+		"/api/v1/seat-layouts":  {"ROLE_ADMIN", "ROLE_OPERATOR"},
 
 		// Actions của Ticket:
 		// - POST /api/v1/tickets là public cho guests.
 		// - GET /api/v1/tickets được bảo vệ cho người dùng đã xác thực (để xem vé của chính họ).
 		"/api/v1/tickets":           {"ROLE_CUSTOMER", "ROLE_RECEPTION", "ROLE_OPERATOR", "ROLE_ADMIN"}, // ĐÃ BỎ COMMENT
 		"/api/v1/tickets/all":       {"ROLE_ADMIN", "ROLE_OPERATOR", "ROLE_RECEPTION"},                  // Lấy tất cả vé (có phân trang)
+		"/api/v1/tickets/search":    {"ROLE_ADMIN", "ROLE_OPERATOR", "ROLE_RECEPTION"},                  // Tìm vé cho nhân viên quầy
 		"/api/v1/public/ticket/:id": {"ROLE_CUSTOMER", "ROLE_RECEPTION", "ROLE_OPERATOR", "ROLE_ADMIN"}, // Lấy thông tin vé công khai
 		// Actions khác
 		"/api/v1/checkin":    {"ROLE_CUSTOMER", "ROLE_RECEPTION", "ROLE_DRIVER"},
@@ -49,6 +51,38 @@ func SetupRouter(
 		"/api/v1/email":      {"ROLE_CUSTOMER", "ROLE_RECEPTION", "ROLE_OPERATOR", "ROLE_ADMIN"},
 		"/api/v1/ws/track":   {"ROLE_CUSTOMER", "ROLE_DRIVER", "ROLE_RECEPTION", "ROLE_OPERATOR", "ROLE_ADMIN", "ROLE_GUEST"}, // ĐÃ THÊM
 
+		// Manifest lên xe cho điều độ / soát vé
+		"/api/v1/boarding-manifests": {"ROLE_DRIVER", "ROLE_RECEPTION", "ROLE_OPERATOR", "ROLE_ADMIN"},
+
+		// Poll trạng thái booking (thay cho WebSocket /ws/track)
+		"/api/v1/bookings": {"ROLE_CUSTOMER", "ROLE_DRIVER", "ROLE_RECEPTION", "ROLE_OPERATOR", "ROLE_ADMIN", "ROLE_GUEST"},
+
+		// Danh sách chờ khi chuyến hết ghế
+		"/api/v1/waitlist": {"ROLE_CUSTOMER"},
+
+		// Đặt vé đoàn (công ty, trường học) qua tài khoản khách hoặc tại quầy
+		"/api/v1/group-bookings": {"ROLE_CUSTOMER", "ROLE_RECEPTION", "ROLE_OPERATOR", "ROLE_ADMIN"},
+
+		// Hoàn tiền hoá đơn (toàn phần / một phần) do nhân viên thực hiện
+		"/api/v1/refunds":                  {"ROLE_ADMIN", "ROLE_RECEPTION"},
+		"/api/v1/vnpay/refund-transaction": {"ROLE_ADMIN", "ROLE_RECEPTION"},
+		"/api/v1/stripe/refund":            {"ROLE_ADMIN", "ROLE_RECEPTION"},
+		"/api/v1/bank/refund":              {"ROLE_ADMIN", "ROLE_RECEPTION"},
+
+		// Đối soát thanh toán với VNPay / Stripe, báo cáo chênh lệch cho kế toán
+		"/api/v1/reconciliation": {"ROLE_ADMIN"},
+
+		// Voucher: quản trị chiến dịch chỉ ROLE_ADMIN, khách / quầy kiểm tra và áp voucher khi thanh toán
+		"/api/v1/vouchers":          {"ROLE_ADMIN"},
+		"/api/v1/vouchers/validate": {"ROLE_CUSTOMER", "ROLE_RECEPTION", "ROLE_ADMIN"},
+		"/api/v1/vouchers/apply":    {"ROLE_CUSTOMER", "ROLE_RECEPTION", "ROLE_ADMIN"},
+		"/api/v1/vouchers/redeem":   {"ROLE_ADMIN", "ROLE_RECEPTION"},
+
+		// Dead letter của outbox từng service (liệt kê, replay, huỷ)
+		"/api/v1/admin/ticket-outbox":  {"ROLE_ADMIN"},
+		"/api/v1/admin/payment-outbox": {"ROLE_ADMIN"},
+		"/api/v1/admin/bank-outbox":    {"ROLE_ADMIN"},
+
 		// Quản lý các entity của TripService (CUD được bảo vệ)
 		"/api/v1/vehicles":     {"ROLE_ADMIN", "ROLE_OPERATOR"},
 		"/api/v1/routes":       {"ROLE_ADMIN", "ROLE_OPERATOR"},
@@ -137,6 +171,10 @@ func SetupRouter(
 		apiV1.POST("/ticket-by-phone", serviceRegistry.ProxyHandler)
 		apiV1.GET("/tickets-available/:id", serviceRegistry.ProxyHandler)
 		apiV1.POST("/trips-available-seats", serviceRegistry.ProxyHandler)
+		apiV1.GET("/seat-maps/:tripId", serviceRegistry.ProxyHandler)
+		apiV1.GET("/seat-layouts", serviceRegistry.ProxyHandler)
+		apiV1.GET("/seat-layouts/:id", serviceRegistry.ProxyHandler)
+		apiV1.GET("/seat-layouts/vehicle-types/:typeId", serviceRegistry.ProxyHandler)
 
 		// Payment (Public)
 		apiV1.POST("/payments", serviceRegistry.ProxyHandler)
@@ -150,6 +188,12 @@ func SetupRouter(
 		apiV1.POST("/bank/confirm-payment", serviceRegistry.ProxyHandler)
 		apiV1.POST("/bank/payment-failed", serviceRegistry.ProxyHandler)
 		apiV1.POST("/invoices/fail", serviceRegistry.ProxyHandler)
+		// Cổng thanh toán dùng chung (momo, zalopay, vnpay, stripe, bank); IPN do cổng gọi trực tiếp
+		apiV1.POST("/payments/:provider/create", serviceRegistry.ProxyHandler)
+		apiV1.GET("/payments/:provider/return", serviceRegistry.ProxyHandler)
+		apiV1.GET("/payments/:provider/ipn", serviceRegistry.ProxyHandler)
+		apiV1.POST("/payments/:provider/ipn", serviceRegistry.ProxyHandler)
+		apiV1.GET("/payments/:provider/query/:invoice_id", serviceRegistry.ProxyHandler)
 
 		// QR & Upload (Public)
 		apiV1.GET("/qr/image", serviceRegistry.ProxyHandler)
@@ -173,6 +217,14 @@ func SetupRouter(
 		websocketGroup.GET("/track/:bookingId", serviceRegistry.ProxyHandler)
 	}
 
+	// Poll trạng thái booking và ACK kết quả qua HTTP (Protected)
+	bookingsGroup := apiV1.Group("/bookings")
+	bookingsGroup.Use(authMw...)
+	{
+		bookingsGroup.GET("/:bookingId", serviceRegistry.ProxyHandler)
+		bookingsGroup.POST("/:bookingId/ack", serviceRegistry.ProxyHandler)
+	}
+
 	// Initiate Booking (Protected)
 	initticket := apiV1.Group("/initiate-booking")
 	initticket.Use(authMw...)
@@ -308,6 +360,12 @@ func SetupRouter(
 		ticketActionsProtected.GET("", serviceRegistry.ProxyHandler)     // Lấy vé của user
 		ticketActionsProtected.GET("/:id", serviceRegistry.ProxyHandler) // Lấy chi tiết vé
 		ticketActionsProtected.GET("/all", serviceRegistry.ProxyHandler) // Lấy tất cả vé (có phân trang)
+
+		// Tìm vé theo bộ lọc cho nhân viên quầy, phân trang bằng cursor
+		ticketActionsProtected.GET("/search", serviceRegistry.ProxyHandler)
+
+		// Lịch sử trạng thái vé (khách vãng lai truyền ?phone=)
+		ticketActionsProtected.GET("/:id/history", serviceRegistry.ProxyHandler)
 	}
 
 	//User get our ticket
@@ -315,6 +373,48 @@ func SetupRouter(
 
 	apiV1.POST("/staff/tickets", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
 	apiV1.POST("/create-seats", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.PUT("/seat-layouts", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.DELETE("/seat-layouts/:id", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+
+	apiV1.GET("/boarding-manifests/:tripId", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+
+	apiV1.POST("/waitlist", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.GET("/waitlist", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.DELETE("/waitlist/:id", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+
+	apiV1.POST("/group-bookings", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.GET("/group-bookings/:groupRef", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.POST("/group-bookings/:groupRef/passengers/:seatTicketId/cancel", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+
+	apiV1.POST("/refunds", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.GET("/refunds/invoice/:invoice_id", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.POST("/vnpay/refund-transaction", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.POST("/stripe/refund", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.POST("/bank/refund", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+
+	apiV1.POST("/reconciliation/runs", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.POST("/reconciliation/runs/upload", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.GET("/reconciliation/runs", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.GET("/reconciliation/runs/:run_id", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.POST("/reconciliation/items/:item_id/resolve", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+
+	apiV1.POST("/vouchers", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.GET("/vouchers", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.POST("/vouchers/validate", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.POST("/vouchers/apply", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.POST("/vouchers/redeem", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.GET("/vouchers/:voucher_id", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.POST("/vouchers/:voucher_id/deactivate", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+
+	apiV1.GET("/admin/ticket-outbox/dead-letters", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.POST("/admin/ticket-outbox/dead-letters/:id/replay", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.DELETE("/admin/ticket-outbox/dead-letters/:id", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.GET("/admin/payment-outbox/dead-letters", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.POST("/admin/payment-outbox/dead-letters/:id/replay", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.DELETE("/admin/payment-outbox/dead-letters/:id", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.GET("/admin/bank-outbox/dead-letters", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.POST("/admin/bank-outbox/dead-letters/:id/replay", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
+	apiV1.DELETE("/admin/bank-outbox/dead-letters/:id", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
 
 	checkinAPI := apiV1.Group("/checkin")
 	checkinAPI.Use(authMw...)
@@ -322,6 +422,8 @@ func SetupRouter(
 		// SỬA ĐỔI: Không cần áp dụng authMw lần nữa vì group đã có
 		checkinAPI.POST("/", serviceRegistry.ProxyHandler)
 		checkinAPI.GET("/trip/:tripID", serviceRegistry.ProxyHandler)
+		checkinAPI.GET("/trip/:tripID/manifest", serviceRegistry.ProxyHandler)
+		checkinAPI.POST("/offline-sync", serviceRegistry.ProxyHandler)
 	}
 
 	apiV1.GET("/token-test", authMw[0], authMw[1], serviceRegistry.ProxyHandler)
@@ -0,0 +1,215 @@
+// Package idempotency xử lý header Idempotency-Key cho các endpoint tạo vé / hoá đơn.
+// Response đầu tiên của mỗi (user hoặc khách vãng lai, key) được lưu trong Redis; request lặp lại nhận lại đúng response đó,
+// request trùng đến khi request gốc chưa xử lý xong bị từ chối với 409.
+//
+// Package nằm trong module shared (Server/shared), được ticket_service và payment_service import qua
+// replace shared => ../shared trong go.mod của từng service.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed" // "true" trên response được trả lại từ lần gọi trước
+
+	maxKeyLength = 255
+
+	stateInFlight  = "in_flight"
+	stateCompleted = "completed"
+)
+
+// Config cấu hình thời gian giữ key.
+type Config struct {
+	KeyPrefix   string        // VD: "idempotency:ticket"
+	InFlightTTL time.Duration // Thời gian giữ khoá khi request gốc đang xử lý (phòng khi instance chết giữa chừng)
+	ResponseTTL time.Duration // Thời gian lưu response để trả lại cho request lặp
+}
+
+// DefaultConfig giữ khoá xử lý 2 phút và lưu response 24 giờ.
+func DefaultConfig(keyPrefix string) Config {
+	return Config{
+		KeyPrefix:   keyPrefix,
+		InFlightTTL: 2 * time.Minute,
+		ResponseTTL: 24 * time.Hour,
+	}
+}
+
+// record là giá trị lưu trong Redis cho một (user, key).
+type record struct {
+	State       string `json:"state"`
+	Fingerprint string `json:"fingerprint"` // sha256 của method, path và body: cùng key phải đi với cùng request
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Store lưu trạng thái các Idempotency-Key trong Redis.
+type Store struct {
+	rdb *redis.Client
+	cfg Config
+}
+
+func NewStore(rdb *redis.Client, cfg Config) *Store {
+	return &Store{rdb: rdb, cfg: cfg}
+}
+
+// Middleware áp dụng Idempotency-Key cho route. Request không có header được xử lý như bình thường.
+// Redis lỗi thì bỏ qua idempotency thay vì chặn việc đặt vé / thanh toán.
+func (s *Store) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(HeaderKey)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Idempotency-Key must be at most 255 characters.", "data": nil})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Could not read request body.", "data": nil})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		redisKey := s.redisKey(c, key)
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		acquired, err := s.acquire(ctx, redisKey, fingerprint)
+		if err != nil {
+			log.Printf("ERROR: Idempotency: Redis unavailable for key %s, processing without idempotency: %v", redisKey, err)
+			c.Next()
+			return
+		}
+		if !acquired {
+			s.replay(c, redisKey, fingerprint)
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Dùng context riêng: request có thể đã bị client huỷ nhưng kết quả vẫn phải được lưu / nhả khoá
+		saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		status := recorder.Status()
+		if !cacheable(status) {
+			if err := s.rdb.Del(saveCtx, redisKey).Err(); err != nil {
+				log.Printf("ERROR: Idempotency: failed to release key %s: %v", redisKey, err)
+			}
+			return
+		}
+		completed, _ := json.Marshal(record{
+			State:       stateCompleted,
+			Fingerprint: fingerprint,
+			StatusCode:  status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err := s.rdb.Set(saveCtx, redisKey, completed, s.cfg.ResponseTTL).Err(); err != nil {
+			log.Printf("ERROR: Idempotency: failed to store response for key %s: %v", redisKey, err)
+		}
+	}
+}
+
+// acquire giữ key cho request hiện tại; false nếu key đã được một request khác giữ hoặc đã có response.
+func (s *Store) acquire(ctx context.Context, redisKey, fingerprint string) (bool, error) {
+	inFlight, _ := json.Marshal(record{State: stateInFlight, Fingerprint: fingerprint})
+	return s.rdb.SetNX(ctx, redisKey, inFlight, s.cfg.InFlightTTL).Result()
+}
+
+// replay trả lại response đã lưu, hoặc từ chối nếu request gốc chưa xong / key dùng cho request khác.
+func (s *Store) replay(c *gin.Context, redisKey, fingerprint string) {
+	raw, err := s.rdb.Get(c.Request.Context(), redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Request gốc vừa thất bại và nhả key: client có thể gửi lại
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"code": http.StatusConflict, "message": "A request with this Idempotency-Key was just processed, please retry.", "data": nil})
+		return
+	}
+	var stored record
+	if err == nil {
+		err = json.Unmarshal(raw, &stored)
+	}
+	if err != nil {
+		log.Printf("ERROR: Idempotency: failed to read key %s: %v", redisKey, err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"code": http.StatusServiceUnavailable, "message": "Could not verify Idempotency-Key, please retry.", "data": nil})
+		return
+	}
+
+	switch {
+	case stored.Fingerprint != fingerprint:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"code": http.StatusUnprocessableEntity, "message": "Idempotency-Key was already used for a different request.", "data": nil})
+	case stored.State == stateInFlight:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"code": http.StatusConflict, "message": "A request with this Idempotency-Key is still being processed.", "data": nil})
+	default:
+		c.Header(HeaderReplayed, "true")
+		contentType := stored.ContentType
+		if contentType == "" {
+			contentType = "application/json; charset=utf-8"
+		}
+		c.Data(stored.StatusCode, contentType, stored.Body)
+		c.Abort()
+	}
+}
+
+// redisKey gắn key với người gọi (X-User-ID do gateway gắn). Khách vãng lai được tách theo dấu vân tay
+// của client để hai khách chọn trùng Idempotency-Key không nhận response của nhau.
+func (s *Store) redisKey(c *gin.Context, key string) string {
+	owner := c.GetHeader("X-User-ID")
+	if owner == "" {
+		owner = "guest-" + guestFingerprint(c)
+	}
+	return s.cfg.KeyPrefix + ":" + owner + ":" + key
+}
+
+// guestFingerprint là sha256 (rút gọn) của IP client (gateway gắn X-Real-IP / X-Forwarded-For) và User-Agent.
+func guestFingerprint(c *gin.Context) string {
+	sum := sha256.Sum256([]byte(c.ClientIP() + "\n" + c.GetHeader("User-Agent")))
+	return hex.EncodeToString(sum[:16])
+}
+
+func requestFingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cacheable: lỗi server và lỗi tạm thời (409 tranh chấp, 429) không được lưu để client có thể gửi lại cùng key.
+func cacheable(status int) bool {
+	return status < http.StatusInternalServerError && status != http.StatusConflict && status != http.StatusTooManyRequests
+}
+
+// bodyRecorder ghi lại body response trong khi vẫn trả về cho client.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,38 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedisKeyOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewStore(nil, DefaultConfig("idempotency:test"))
+	keyFor := func(remoteAddr, userAgent, userID string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/tickets", nil)
+		c.Request.RemoteAddr = remoteAddr
+		c.Request.Header.Set("User-Agent", userAgent)
+		if userID != "" {
+			c.Request.Header.Set("X-User-ID", userID)
+		}
+		return store.redisKey(c, "same-key")
+	}
+
+	guestA := keyFor("10.0.0.1:5000", "Mozilla/5.0", "")
+	if guestA != keyFor("10.0.0.1:6000", "Mozilla/5.0", "") {
+		t.Fatal("same guest client got different keys")
+	}
+	if guestA == keyFor("10.0.0.2:5000", "Mozilla/5.0", "") {
+		t.Fatal("guests from different IPs share a key")
+	}
+	if guestA == keyFor("10.0.0.1:5000", "okhttp/4.12", "") {
+		t.Fatal("guests with different user agents share a key")
+	}
+	if got, want := keyFor("10.0.0.1:5000", "Mozilla/5.0", "42"), "idempotency:test:42:same-key"; got != want {
+		t.Fatalf("redisKey() for user = %q, want %q", got, want)
+	}
+}
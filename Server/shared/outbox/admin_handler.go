@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler là API quản trị dead letter của outbox, chỉ dành cho ROLE_ADMIN (header X-User-Role do gateway gắn).
+type AdminHandler struct {
+	store *Store
+}
+
+func NewAdminHandler(store *Store) *AdminHandler {
+	return &AdminHandler{store: store}
+}
+
+// RegisterRoutes gắn các route quản trị vào group, VD: /api/v1/admin/ticket-outbox.
+func (h *AdminHandler) RegisterRoutes(group *gin.RouterGroup) {
+	group.Use(requireAdmin)
+	group.GET("/dead-letters", h.ListDeadLettersHandler)
+	group.POST("/dead-letters/:id/replay", h.ReplayDeadLetterHandler)
+	group.DELETE("/dead-letters/:id", h.DiscardDeadLetterHandler)
+}
+
+// ListDeadLettersHandler liệt kê dead letter (GET /dead-letters?topic=&page=1&limit=20).
+func (h *AdminHandler) ListDeadLettersHandler(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	deadLetters, total, err := h.store.ListDeadLetters(c.Request.Context(), c.Query("topic"), limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusInternalServerError, "message": "Failed to list dead letters: " + err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Dead letters retrieved successfully", "data": gin.H{
+		"items": deadLetters,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	}})
+}
+
+// ReplayDeadLetterHandler đưa dead letter trở lại outbox để publish lại (POST /dead-letters/:id/replay).
+func (h *AdminHandler) ReplayDeadLetterHandler(c *gin.Context) {
+	id := c.Param("id")
+	if !isUUID(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid dead letter id", "data": nil})
+		return
+	}
+	if err := h.store.ReplayDeadLetter(c.Request.Context(), id); err != nil {
+		statusCode := deadLetterErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": "Failed to replay dead letter: " + err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Dead letter queued for replay", "data": gin.H{"id": id}})
+}
+
+// DiscardDeadLetterHandler xoá một dead letter (DELETE /dead-letters/:id).
+func (h *AdminHandler) DiscardDeadLetterHandler(c *gin.Context) {
+	id := c.Param("id")
+	if !isUUID(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid dead letter id", "data": nil})
+		return
+	}
+	if err := h.store.DiscardDeadLetter(c.Request.Context(), id); err != nil {
+		statusCode := deadLetterErrorStatus(err)
+		c.JSON(statusCode, gin.H{"code": statusCode, "message": "Failed to discard dead letter: " + err.Error(), "data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": http.StatusOK, "message": "Dead letter discarded", "data": gin.H{"id": id}})
+}
+
+func requireAdmin(c *gin.Context) {
+	if c.GetHeader("X-User-Role") != "ROLE_ADMIN" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": http.StatusForbidden, "message": "Only administrators can manage the outbox.", "data": nil})
+		return
+	}
+	c.Next()
+}
+
+func deadLetterErrorStatus(err error) int {
+	if errors.Is(err, ErrDeadLetterNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// isUUID kiểm tra id có dạng UUID để không gửi chuỗi sai định dạng xuống PostgreSQL.
+func isUUID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i, r := range id {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Publisher gửi một message lên Kafka; kafkaclient.Publisher của các service đều thoả interface này.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, key []byte, payload interface{}) error
+}
+
+// Logger là phần log mà poller cần; utils.Logger của ticket_service / payment_service thoả interface này.
+type Logger interface {
+	Info(format string, args ...interface{})
+	Error(format string, args ...interface{})
+}
+
+// Config cấu hình poller.
+type Config struct {
+	Interval    time.Duration // Chu kỳ quét outbox
+	BatchSize   int           // Số event tối đa mỗi lượt quét
+	Lease       time.Duration // Thời gian một event đã nhận bị ẩn khỏi các instance khác
+	MaxAttempts int           // Số lần publish thất bại tối đa trước khi chuyển sang dead letter
+	BaseBackoff time.Duration // Khoảng chờ sau lần thất bại đầu tiên, nhân đôi sau mỗi lần
+	MaxBackoff  time.Duration
+}
+
+// DefaultConfig trả về cấu hình mặc định: quét mỗi 2 giây, tối đa 10 lần thử, backoff 1s -> 5 phút.
+func DefaultConfig() Config {
+	return Config{
+		Interval:    2 * time.Second,
+		BatchSize:   50,
+		Lease:       time.Minute,
+		MaxAttempts: 10,
+		BaseBackoff: time.Second,
+		MaxBackoff:  5 * time.Minute,
+	}
+}
+
+// backoff trả về khoảng chờ sau lần thất bại thứ attempts (bắt đầu từ 1).
+func (c Config) backoff(attempts int32) time.Duration {
+	delay := c.BaseBackoff
+	for i := int32(1); i < attempts && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > c.MaxBackoff {
+		delay = c.MaxBackoff
+	}
+	return delay
+}
+
+// Poller publish các event trong outbox lên Kafka.
+// Đảm bảo at-least-once: event chỉ bị xoá sau khi publish thành công, nên consumer phải xử lý trùng lặp.
+// Event lỗi được hẹn lại theo backoff nên có thể bị publish sau các event tạo sau nó.
+type Poller struct {
+	store     *Store
+	publisher Publisher
+	logger    Logger
+	cfg       Config
+}
+
+func NewPoller(store *Store, publisher Publisher, logger Logger, cfg Config) *Poller {
+	if logger == nil {
+		logger = stdLogger{}
+	}
+	return &Poller{store: store, publisher: publisher, logger: logger, cfg: cfg}
+}
+
+func (p *Poller) Start(ctx context.Context) {
+	p.logger.Info("Starting Outbox Poller worker...")
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Info("Stopping Outbox Poller worker.")
+			return
+		case <-ticker.C:
+			p.processBatch(ctx)
+		}
+	}
+}
+
+func (p *Poller) processBatch(ctx context.Context) {
+	events, err := p.store.Claim(ctx, p.cfg.BatchSize, p.cfg.Lease)
+	if err != nil {
+		p.logger.Error("Failed to claim outbox events: %v", err)
+		return
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	p.logger.Info("Processing %d events from outbox...", len(events))
+	for _, event := range events {
+		// Cột payload là JSONB nên luôn là JSON hợp lệ
+		if err := p.publisher.Publish(ctx, event.Topic, []byte(event.Key), json.RawMessage(event.Payload)); err != nil {
+			p.handleFailure(ctx, event, err)
+			continue
+		}
+
+		if err := p.store.MarkPublished(ctx, event.ID); err != nil {
+			// Event sẽ được publish lại khi hết lease
+			p.logger.Error("CRITICAL: %v", err)
+		}
+	}
+}
+
+func (p *Poller) handleFailure(ctx context.Context, event Event, publishErr error) {
+	attempts := event.Attempts + 1
+	if attempts >= int32(p.cfg.MaxAttempts) {
+		p.logger.Error("Event %s to topic %s failed %d times, moving to dead letters: %v", event.ID, event.Topic, attempts, publishErr)
+		p.deadLetter(ctx, event, publishErr.Error())
+		return
+	}
+
+	retryIn := p.cfg.backoff(attempts)
+	p.logger.Error("Failed to publish event %s to topic %s (attempt %d/%d): %v. Retrying in %s.", event.ID, event.Topic, attempts, p.cfg.MaxAttempts, publishErr, retryIn)
+	if err := p.store.MarkFailed(ctx, event.ID, publishErr.Error(), retryIn); err != nil {
+		p.logger.Error("%v", err)
+	}
+}
+
+func (p *Poller) deadLetter(ctx context.Context, event Event, reason string) {
+	if err := p.store.MoveToDeadLetter(ctx, event.ID, reason); err != nil {
+		p.logger.Error("CRITICAL: %v", err)
+	}
+}
+
+// stdLogger dùng package log cho các service không có utils.Logger.
+type stdLogger struct{}
+
+func (stdLogger) Info(format string, args ...interface{}) {
+	log.Printf("INFO: "+format, args...)
+}
+
+func (stdLogger) Error(format string, args ...interface{}) {
+	log.Printf("ERROR: "+format, args...)
+}
@@ -0,0 +1,212 @@
+// Package outbox là relay của Transactional Outbox Pattern: đọc outbox_events, publish lên Kafka,
+// retry với backoff luỹ thừa và chuyển event lỗi quá số lần cho phép sang outbox_dead_letters.
+//
+// Package nằm trong module shared, được ticket_service, payment_service và bank_service dùng chung.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrDeadLetterNotFound được trả về khi replay / discard một dead letter không tồn tại.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// Event là một dòng outbox_events đã được poller nhận xử lý.
+type Event struct {
+	ID        string
+	Topic     string
+	Key       string
+	Payload   []byte
+	Attempts  int32 // Số lần publish thất bại trước lượt này
+	CreatedAt time.Time
+}
+
+// DeadLetter là event đã publish thất bại quá số lần cho phép.
+type DeadLetter struct {
+	ID        string          `json:"id"`
+	Topic     string          `json:"topic"`
+	Key       string          `json:"key"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int32           `json:"attempts"`
+	LastError string          `json:"last_error"`
+	CreatedAt time.Time       `json:"created_at"`
+	FailedAt  time.Time       `json:"failed_at"`
+}
+
+// DBTX là phần chung của *sql.DB và *sql.Tx, dùng để ghi event trong transaction nghiệp vụ.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Store đọc / ghi outbox_events và outbox_dead_letters.
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Enqueue ghi một event vào outbox. Truyền *sql.Tx để event được commit cùng thay đổi nghiệp vụ.
+func Enqueue(ctx context.Context, exec DBTX, topic, key string, payload []byte) error {
+	if _, err := exec.ExecContext(ctx,
+		`INSERT INTO outbox_events (id, topic, key, payload) VALUES (gen_random_uuid(), $1, $2, $3)`,
+		topic, key, payload,
+	); err != nil {
+		return fmt.Errorf("failed to enqueue outbox event for topic %s: %w", topic, err)
+	}
+	return nil
+}
+
+// claimEventsSQL nhận tối đa $1 event đến hạn và đẩy next_attempt_at lên $2 giây (lease).
+// FOR UPDATE SKIP LOCKED cho phép nhiều instance cùng chạy: mỗi dòng chỉ được một instance nhận,
+// và lease giữ dòng khỏi các instance khác cho tới khi publish xong hoặc instance bị dừng giữa chừng.
+const claimEventsSQL = `
+UPDATE outbox_events
+SET next_attempt_at = NOW() + make_interval(secs => $2::double precision)
+WHERE id IN (
+    SELECT id FROM outbox_events
+    WHERE next_attempt_at <= NOW()
+    ORDER BY created_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, topic, key, payload, attempts, created_at`
+
+// Claim nhận một lô event đến hạn publish, sắp theo thời điểm tạo.
+func (s *Store) Claim(ctx context.Context, limit int, lease time.Duration) ([]Event, error) {
+	rows, err := s.db.QueryContext(ctx, claimEventsSQL, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.Topic, &e.Key, &e.Payload, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox events: %w", err)
+	}
+	// RETURNING không giữ thứ tự của subquery
+	sort.Slice(events, func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) })
+	return events, nil
+}
+
+// MarkPublished xoá event đã publish thành công.
+func (s *Store) MarkPublished(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM outbox_events WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete published outbox event %s: %w", id, err)
+	}
+	return nil
+}
+
+// MarkFailed tăng số lần thất bại và hẹn lần publish tiếp theo sau retryIn (tính theo đồng hồ của DB).
+func (s *Store) MarkFailed(ctx context.Context, id string, lastErr string, retryIn time.Duration) error {
+	if _, err := s.db.ExecContext(ctx, `
+UPDATE outbox_events
+SET attempts = attempts + 1, last_error = $2, next_attempt_at = NOW() + make_interval(secs => $3::double precision)
+WHERE id = $1`,
+		id, lastErr, retryIn.Seconds(),
+	); err != nil {
+		return fmt.Errorf("failed to reschedule outbox event %s: %w", id, err)
+	}
+	return nil
+}
+
+// MoveToDeadLetter chuyển event sang outbox_dead_letters trong một câu lệnh.
+func (s *Store) MoveToDeadLetter(ctx context.Context, id string, lastErr string) error {
+	if _, err := s.db.ExecContext(ctx, `
+WITH moved AS (
+    DELETE FROM outbox_events WHERE id = $1
+    RETURNING id, topic, key, payload, attempts, created_at
+)
+INSERT INTO outbox_dead_letters (id, topic, key, payload, attempts, last_error, created_at)
+SELECT id, topic, key, payload, attempts + 1, $2, created_at FROM moved`,
+		id, lastErr,
+	); err != nil {
+		return fmt.Errorf("failed to move outbox event %s to dead letters: %w", id, err)
+	}
+	return nil
+}
+
+// ListDeadLetters trả về các dead letter mới nhất trước, lọc theo topic nếu topic khác rỗng.
+func (s *Store) ListDeadLetters(ctx context.Context, topic string, limit, offset int) ([]DeadLetter, int64, error) {
+	var total int64
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM outbox_dead_letters WHERE ($1::text = '' OR topic = $1::text)`, topic,
+	).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id, topic, key, payload, attempts, COALESCE(last_error, ''), created_at, failed_at
+FROM outbox_dead_letters
+WHERE ($1::text = '' OR topic = $1::text)
+ORDER BY failed_at DESC
+LIMIT $2 OFFSET $3`, topic, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	deadLetters := []DeadLetter{}
+	for rows.Next() {
+		var d DeadLetter
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.Topic, &d.Key, &payload, &d.Attempts, &d.LastError, &d.CreatedAt, &d.FailedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan dead letter: %w", err)
+		}
+		d.Payload = json.RawMessage(payload)
+		deadLetters = append(deadLetters, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read dead letters: %w", err)
+	}
+	return deadLetters, total, nil
+}
+
+// ReplayDeadLetter đưa dead letter trở lại outbox_events với bộ đếm lần thử về 0.
+func (s *Store) ReplayDeadLetter(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `
+WITH replayed AS (
+    DELETE FROM outbox_dead_letters WHERE id = $1
+    RETURNING id, topic, key, payload
+)
+INSERT INTO outbox_events (id, topic, key, payload)
+SELECT id, topic, key, payload FROM replayed`, id)
+	if err != nil {
+		return fmt.Errorf("failed to replay dead letter %s: %w", id, err)
+	}
+	return requireAffected(result, id)
+}
+
+// DiscardDeadLetter xoá hẳn một dead letter.
+func (s *Store) DiscardDeadLetter(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM outbox_dead_letters WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to discard dead letter %s: %w", id, err)
+	}
+	return requireAffected(result, id)
+}
+
+func requireAffected(result sql.Result, id string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("dead letter %s: %w", id, ErrDeadLetterNotFound)
+	}
+	return nil
+}
@@ -54,6 +54,7 @@ public class TripController {
     public ResponseEntity<Map<String, Object>> getById(@PathVariable Integer id) {
         Trip t = service.findById(id);
         if (t != null) {
+            t.setStops(service.findStops(t));
             return ResponseEntity.ok(Map.of(
                     "code", HttpStatus.OK.value(),
                     "message", "Trip found",
@@ -0,0 +1,11 @@
+package com.example.trip_service.dto;
+
+import java.util.Date;
+
+// Gửi khi chuyến bị huỷ (status = 3) hoặc bị xoá; ticket-service huỷ và hoàn tiền các vé đã bán.
+public record TripCancelledEvent(
+    String tripId,
+    String reason,
+    Integer cancelledBy,
+    Date cancelledAt
+) {}
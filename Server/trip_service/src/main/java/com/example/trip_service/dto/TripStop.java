@@ -0,0 +1,10 @@
+package com.example.trip_service.dto;
+
+// Một điểm dừng của chuyến theo thứ tự hành trình (1 = điểm đầu), tính từ chuỗi pickup.self_id.
+// ticket-service dùng stopOrder để giữ ghế theo đoạn đường; stationId là id client gửi lên làm điểm đón/trả.
+public record TripStop(
+    Integer stopOrder,
+    String pickupId,
+    Integer stationId,
+    String stationName
+) {}
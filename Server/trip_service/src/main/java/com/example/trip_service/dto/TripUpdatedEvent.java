@@ -0,0 +1,13 @@
+package com.example.trip_service.dto;
+
+import java.util.Date;
+
+// Gửi khi giờ khởi hành của chuyến thay đổi; ticket-service báo cho khách và cho đổi chuyến miễn phí.
+public record TripUpdatedEvent(
+    String tripId,
+    String oldDepartureDate,
+    String oldDepartureTime,
+    String departureDate,
+    String departureTime,
+    Date updatedAt
+) {}
@@ -6,6 +6,9 @@ import lombok.Data;
 import java.time.LocalDate;
 import java.time.LocalTime;
 import java.util.Date;
+import java.util.List;
+
+import com.example.trip_service.dto.TripStop;
 
 @Entity
 @Table(name = "trip")
@@ -54,6 +57,18 @@ public class Trip {
 
     private Integer createdBy;
 
+    // Điểm dừng theo thứ tự hành trình, chỉ trả về ở GET /api/v1/trips/{id}
+    @Transient
+    private List<TripStop> stops;
+
+    public List<TripStop> getStops() {
+        return stops;
+    }
+
+    public void setStops(List<TripStop> stops) {
+        this.stops = stops;
+    }
+
     public Date getCreatedAt() {
         return createdAt;
     }
@@ -3,10 +3,16 @@ package com.example.trip_service.repository;
 import com.example.trip_service.model.Pickup;
 import com.example.trip_service.model.Province;
 import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.data.jpa.repository.Query;
+import org.springframework.data.repository.query.Param;
 
 import java.util.List;
 
 public interface PickupRepository extends JpaRepository<Pickup, String> {
     List<Pickup> findByStatusIn(List<Integer> statuses);
     List<Pickup> findByRouteIdAndSelfId(Integer routeId, String selfId);
+
+    // Các điểm dừng của một nhánh (path) trong tuyến, chưa sắp theo thứ tự hành trình
+    @Query("SELECT p FROM Pickup p WHERE p.route.id = :routeId AND p.path_id = :pathId")
+    List<Pickup> findByRouteIdAndPathId(@Param("routeId") Integer routeId, @Param("pathId") int pathId);
 }
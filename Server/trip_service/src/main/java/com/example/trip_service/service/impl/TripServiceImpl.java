@@ -1,8 +1,12 @@
 package com.example.trip_service.service.impl;
 
 import java.time.LocalDateTime;
+import java.util.ArrayList;
 import java.util.Date;
+import java.util.HashMap;
+import java.util.HashSet;
 import java.util.List;
+import java.util.Map;
 import java.util.Optional;
 
 import org.apache.kafka.clients.producer.KafkaProducer;
@@ -13,12 +17,17 @@ import org.springframework.stereotype.Service;
 import org.springframework.web.client.RestTemplate;
 import org.springframework.web.server.ResponseStatusException;
 
+import com.example.trip_service.dto.TripCancelledEvent;
 import com.example.trip_service.dto.TripCreatedEvent;
 import com.example.trip_service.dto.TripInfoProjection;
 import com.example.trip_service.dto.TripSearchEvent;
 import com.example.trip_service.dto.TripStatusUpdateEvent;
+import com.example.trip_service.dto.TripStop;
+import com.example.trip_service.dto.TripUpdatedEvent;
+import com.example.trip_service.model.Pickup;
 import com.example.trip_service.model.Trip;
 import com.example.trip_service.model.log.Log_trip;
+import com.example.trip_service.repository.PickupRepository;
 import com.example.trip_service.repository.TripRepository;
 import com.example.trip_service.repository.log.LogTripRepository;
 import com.example.trip_service.service.intef.TripService;
@@ -35,6 +44,9 @@ public class TripServiceImpl implements TripService {
     @Autowired
     private RestTemplate restTemplate;
 
+    @Autowired
+    private PickupRepository pickupRepository;
+
     private final KafkaProducer<String, String> kafkaProducer;
     private final ObjectMapper objectMapper;
 
@@ -42,6 +54,9 @@ public class TripServiceImpl implements TripService {
     private static final String TRIP_CREATED_TOPIC = "trip_created";
     private static final String TRIP_SEARCH_TOPIC = "trip_search";
     private static final String TRIP_STATUS_UPDATED_TOPIC = "trip_status_updated"; // Topic mới
+    private static final String TRIP_UPDATED_TOPIC = "trip_updated";
+    private static final String TRIP_CANCELLED_TOPIC = "trip_cancelled";
+    private static final int TRIP_STATUS_CANCELLED = 3;
 
     @Autowired
     public TripServiceImpl(TripRepository repository,
@@ -93,6 +108,10 @@ public class TripServiceImpl implements TripService {
         }
 
         boolean isNewTrip = (trip.getId() == null);
+        Trip previous = isNewTrip ? null : repository.findById(trip.getId()).orElse(null);
+        // Giữ lại giờ cũ trước khi save (entity cũ có thể bị cập nhật cùng persistence context)
+        String oldDepartureDate = previous != null && previous.getDepartureDate() != null ? previous.getDepartureDate().toString() : null;
+        String oldDepartureTime = previous != null && previous.getDepartureTime() != null ? previous.getDepartureTime().toString() : null;
 
         if (trip.getCreatedAt() == null) {
             trip.setCreatedAt(new Date());
@@ -102,6 +121,10 @@ public class TripServiceImpl implements TripService {
 
         if (isNewTrip && savedTrip.getId() != null) {
             sendTripCreationEvent(savedTrip);
+        } else if (previous != null && savedTrip.getDepartureDate() != null && savedTrip.getDepartureTime() != null
+                && (!savedTrip.getDepartureDate().toString().equals(oldDepartureDate)
+                        || !savedTrip.getDepartureTime().toString().equals(oldDepartureTime))) {
+            sendTripUpdatedEvent(savedTrip, oldDepartureDate, oldDepartureTime);
         }
 
         return savedTrip;
@@ -148,6 +171,47 @@ public class TripServiceImpl implements TripService {
         }
     }
 
+    // Báo ticket-service giờ khởi hành đã đổi
+    private void sendTripUpdatedEvent(Trip trip, String oldDepartureDate, String oldDepartureTime) {
+        try {
+            TripUpdatedEvent event = new TripUpdatedEvent(trip.getId().toString(), oldDepartureDate, oldDepartureTime,
+                    trip.getDepartureDate().toString(), trip.getDepartureTime().toString(), new Date());
+            String eventJson = objectMapper.writeValueAsString(event);
+            ProducerRecord<String, String> record = new ProducerRecord<>(TRIP_UPDATED_TOPIC, trip.getId().toString(),
+                    eventJson);
+
+            kafkaProducer.send(record, (metadata, exception) -> {
+                if (exception == null) {
+                    System.out.printf("Sent trip updated event to topic %s for trip %d\n", metadata.topic(), trip.getId());
+                } else {
+                    System.err.println("Failed to send trip updated event: " + exception.getMessage());
+                }
+            });
+        } catch (Exception e) {
+            System.err.println("Error creating or sending trip updated event: " + e.getMessage());
+        }
+    }
+
+    // Báo ticket-service chuyến đã bị huỷ
+    private void sendTripCancelledEvent(Integer tripId, String reason, Integer cancelledBy) {
+        try {
+            TripCancelledEvent event = new TripCancelledEvent(tripId.toString(), reason, cancelledBy, new Date());
+            String eventJson = objectMapper.writeValueAsString(event);
+            ProducerRecord<String, String> record = new ProducerRecord<>(TRIP_CANCELLED_TOPIC, tripId.toString(),
+                    eventJson);
+
+            kafkaProducer.send(record, (metadata, exception) -> {
+                if (exception == null) {
+                    System.out.printf("Sent trip cancelled event to topic %s for trip %d\n", metadata.topic(), tripId);
+                } else {
+                    System.err.println("Failed to send trip cancelled event: " + exception.getMessage());
+                }
+            });
+        } catch (Exception e) {
+            System.err.println("Error creating or sending trip cancelled event: " + e.getMessage());
+        }
+    }
+
     private void sendTripSearchEvent(Integer fromProvinceId, Integer toProvinceId, String departureDate,
             Integer quantity, Integer userId) {
         try {
@@ -178,6 +242,7 @@ public class TripServiceImpl implements TripService {
     @Override
     public void deleteById(int id) {
         repository.deleteById(id);
+        sendTripCancelledEvent(id, "Chuyến đã bị xoá", null);
     }
 
     @Override
@@ -206,6 +271,45 @@ public class TripServiceImpl implements TripService {
         return tripInfos.get(0);
     }
 
+    @Override
+    public List<TripStop> findStops(Trip trip) {
+        List<TripStop> stops = new ArrayList<>();
+        if (trip == null || trip.getPickupId() == null) {
+            return stops;
+        }
+        Pickup current = pickupRepository.findById(trip.getPickupId()).orElse(null);
+        if (current == null || current.getRoute() == null) {
+            return stops;
+        }
+
+        // Nối chuỗi self_id: điểm kế tiếp có self_id = id điểm trước, điểm cuối có self_id = '-2'
+        Map<String, Pickup> nextBySelfId = new HashMap<>();
+        Pickup end = null;
+        for (Pickup p : pickupRepository.findByRouteIdAndPathId(current.getRoute().getId(), current.getPath_id())) {
+            if ("-2".equals(p.getSelfId())) {
+                end = p;
+            } else if (!"-1".equals(p.getSelfId())) {
+                nextBySelfId.put(p.getSelfId(), p);
+            }
+        }
+
+        HashSet<String> visited = new HashSet<>();
+        while (current != null && visited.add(current.getId())) {
+            stops.add(toTripStop(stops.size() + 1, current));
+            current = nextBySelfId.get(current.getId());
+        }
+        if (end != null && visited.add(end.getId())) {
+            stops.add(toTripStop(stops.size() + 1, end));
+        }
+        return stops;
+    }
+
+    private TripStop toTripStop(int stopOrder, Pickup pickup) {
+        Integer stationId = pickup.getStation() != null ? pickup.getStation().getId() : null;
+        String stationName = pickup.getStation() != null ? pickup.getStation().getName() : null;
+        return new TripStop(stopOrder, pickup.getId(), stationId, stationName);
+    }
+
     @Override
     public List<TripInfoProjection> searchTripsByLocationsWithSeats(Integer fromProvinceId, Integer toProvinceId,
             String departureDate, Integer quantity, Integer userId) {
@@ -258,6 +362,9 @@ public class TripServiceImpl implements TripService {
 
             // Gửi sự kiện tới Kafka
             sendTripStatusUpdateEvent(id, oldStatus, newStatus, userId);
+            if (newStatus != null && newStatus == TRIP_STATUS_CANCELLED) {
+                sendTripCancelledEvent(id, "Chuyến đã bị huỷ", userId);
+            }
 
             return true;
         }
@@ -3,6 +3,7 @@ package com.example.trip_service.service.intef;
 import java.util.List;
 
 import com.example.trip_service.dto.TripInfoProjection;
+import com.example.trip_service.dto.TripStop;
 import com.example.trip_service.model.Trip;
 
 public interface TripService {
@@ -17,6 +18,9 @@ public interface TripService {
 
     TripInfoProjection findByIdWithSeats(int id);
 
+    // Điểm dừng của chuyến theo thứ tự hành trình, bắt đầu từ pickup_id của chuyến
+    List<TripStop> findStops(Trip trip);
+
     // New methods that return TripInfoWithSeats
     List<TripInfoProjection> searchTripsByLocationsWithSeats(Integer fromProvinceId, Integer toProvinceId, String departureDate, Integer quantity, Integer userId);
 